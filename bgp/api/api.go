@@ -91,3 +91,25 @@ func SendRouteNotification(add []*config.RouteInfo, remove []*config.RouteInfo)
 		Remove: remove,
 	}
 }
+
+/*  Send local VTEP information from EVPN manager to server
+ */
+func SendEVPNVtepNotification(vni uint32, vtepIp string, oper config.Operation) {
+	bgpapi.server.EVPNVtepCh <- config.EVPNVtepInfo{
+		Oper:   oper,
+		Vni:    vni,
+		VtepIp: vtepIp,
+	}
+}
+
+/*  Send local MAC information from EVPN manager to server
+ */
+func SendEVPNMacNotification(vni uint32, mac string, ip string, vtepIp string, oper config.Operation) {
+	bgpapi.server.EVPNMacCh <- config.EVPNMacInfo{
+		Oper:   oper,
+		Vni:    vni,
+		Mac:    mac,
+		Ip:     ip,
+		VtepIp: vtepIp,
+	}
+}
@@ -48,6 +48,14 @@ type NeighborConf struct {
 	AfiSafiMap           map[uint32]bool
 	MaxPrefixesThreshold uint32
 	ignoreBfdFaultsTimer *time.Timer
+	PeerAfiSafiMap       map[uint32]bool // Protocol families negotiated with the peer
+	GRFamilies           map[uint32]bool // Graceful restart families of the peer and their forwarding state
+	StaleFamilies        map[uint32]bool // Protocol families with stale paths retained from the previous session
+	EORPending           map[uint32]bool // Protocol families waiting for End-of-RIB from the peer
+	LocalRestarting      bool
+	ListenRange          string   // Listen range of a dynamic neighbor, empty for the configured neighbors
+	AllowedPeerAS        []uint32 // AS numbers that a dynamic neighbor is allowed to peer from
+	AddPathsFamilies     map[uint32]config.AddPathsConfig
 }
 
 func NewNeighborConf(logger *logging.Writer, globalConf *config.GlobalConfig, peerGroup *config.PeerGroupConfig,
@@ -57,6 +65,11 @@ func NewNeighborConf(logger *logging.Writer, globalConf *config.GlobalConfig, pe
 		Global:               globalConf,
 		Group:                peerGroup,
 		AfiSafiMap:           make(map[uint32]bool),
+		PeerAfiSafiMap:       make(map[uint32]bool),
+		GRFamilies:           make(map[uint32]bool),
+		StaleFamilies:        make(map[uint32]bool),
+		EORPending:           make(map[uint32]bool),
+		AddPathsFamilies:     make(map[uint32]config.AddPathsConfig),
 		BGPId:                net.IP{},
 		MaxPrefixesThreshold: 0,
 		RunningConf:          config.NeighborConfig{},
@@ -69,7 +82,7 @@ func NewNeighborConf(logger *logging.Writer, globalConf *config.GlobalConfig, pe
 	conf.SetRunningConf(peerGroup, &conf.RunningConf)
 	conf.SetNeighborState(&conf.RunningConf)
 	conf.setOtherStates()
-	conf.AfiSafiMap, _ = packet.GetProtocolFromConfig(&conf.Neighbor.AfiSafis, conf.Neighbor.NeighborAddress)
+	conf.setAfiSafiMap()
 	return &conf
 }
 
@@ -89,6 +102,7 @@ func (n *NeighborConf) ResetNeighborAddress() {
 
 func (n *NeighborConf) SetNeighborState(peerConf *config.NeighborConfig) {
 	n.Neighbor.State = config.NeighborState{
+		Vrf:                     peerConf.Vrf,
 		Disabled:                peerConf.Disabled,
 		PeerAS:                  peerConf.PeerAS,
 		LocalAS:                 peerConf.LocalAS,
@@ -108,6 +122,10 @@ func (n *NeighborConf) SetNeighborState(peerConf *config.NeighborConfig) {
 		PeerGroup:               peerConf.PeerGroup,
 		AddPathsRx:              false,
 		AddPathsMaxTx:           0,
+		RouteRefresh:            false,
+		EnhancedRouteRefresh:    false,
+		GracefulRestart:         false,
+		PeerRestartTime:         0,
 		MaxPrefixes:             peerConf.MaxPrefixes,
 		MaxPrefixesThresholdPct: peerConf.MaxPrefixesThresholdPct,
 		MaxPrefixesDisconnect:   peerConf.MaxPrefixesDisconnect,
@@ -115,13 +133,47 @@ func (n *NeighborConf) SetNeighborState(peerConf *config.NeighborConfig) {
 		TotalPrefixes:           0,
 		AdjRIBInFilter:          peerConf.AdjRIBInFilter,
 		AdjRIBOutFilter:         peerConf.AdjRIBOutFilter,
+		AllowAsIn:               peerConf.AllowAsIn,
+		RemovePrivateAS:         peerConf.RemovePrivateAS,
+		LocalASNoPrepend:        peerConf.LocalASNoPrepend,
+		LocalASReplaceAS:        peerConf.LocalASReplaceAS,
+		AsOverride:              peerConf.AsOverride,
+		DefaultOriginate:        peerConf.DefaultOriginate,
+		DefaultOriginateCond:    peerConf.DefaultOriginateCond,
+		ConditionalAdv:          peerConf.ConditionalAdv,
+		LabeledUnicast:          peerConf.LabeledUnicast,
+		AddPaths:                make([]config.AddPathsConfig, 0),
+	}
+	if dampConf := n.GetDampeningConfig(); dampConf != nil {
+		n.Neighbor.State.Dampening = *dampConf
 	}
 	n.MaxPrefixesThreshold = uint32(float64(peerConf.MaxPrefixes*uint32(peerConf.MaxPrefixesThresholdPct)) / 100)
 }
 
+// setAfiSafiMap sets the protocol families enabled for the neighbor. The labeled unicast families of the
+// enabled unicast families are added when labeled unicast is configured.
+func (n *NeighborConf) setAfiSafiMap() {
+	n.AfiSafiMap, _ = packet.GetProtocolFromConfig(&n.Neighbor.AfiSafis, n.Neighbor.NeighborAddress)
+	if !n.RunningConf.LabeledUnicast {
+		return
+	}
+
+	labeledFamilies := make([]uint32, 0)
+	for protoFamily, ok := range n.AfiSafiMap {
+		if labeledFamily, found := packet.UnicastFamilyToLabeledFamilyMap[protoFamily]; ok && found {
+			labeledFamilies = append(labeledFamilies, labeledFamily)
+		}
+	}
+	for _, labeledFamily := range labeledFamilies {
+		n.AfiSafiMap[labeledFamily] = true
+	}
+}
+
 func (n *NeighborConf) setOtherStates() {
 	if n.RunningConf.LocalAS == n.RunningConf.PeerAS {
 		n.Neighbor.State.PeerType = config.PeerTypeInternal
+	} else if n.IsConfedExternal() {
+		n.Neighbor.State.PeerType = config.PeerTypeConfedExternal
 	} else {
 		n.Neighbor.State.PeerType = config.PeerTypeExternal
 	}
@@ -156,6 +208,7 @@ func (n *NeighborConf) UpdateNeighborConf(nConf config.NeighborConfig, bgp *conf
 	n.GetConfFromNeighbor(&n.Neighbor.Config, &n.RunningConf)
 	n.logger.Infof("UpdateNeighborConf - running conf=%+v", n.Neighbor.Config)
 	n.SetNeighborState(&n.RunningConf)
+	n.setAfiSafiMap()
 	n.logger.Infof("UpdateNeighborConf - neigh state=%+v", n.Neighbor.State)
 }
 
@@ -174,6 +227,7 @@ func (n *NeighborConf) UpdatePeerGroup(peerGroup *config.PeerGroupConfig) {
 	n.RunningConf = config.NeighborConfig{}
 	n.SetRunningConf(peerGroup, &n.RunningConf)
 	n.SetNeighborState(&n.RunningConf)
+	n.setAfiSafiMap()
 }
 
 func (n *NeighborConf) SetRunningConf(peerGroup *config.PeerGroupConfig, peerConf *config.NeighborConfig) {
@@ -289,8 +343,53 @@ func (n *NeighborConf) GetConfFromNeighbor(inConf *config.NeighborConfig, outCon
 		outConf.AdjRIBOutFilter = inConf.AdjRIBOutFilter
 	}
 
+	if inConf.Dampening.Enabled != false {
+		outConf.Dampening = inConf.Dampening
+	}
+
+	if inConf.AllowAsIn != 0 {
+		outConf.AllowAsIn = inConf.AllowAsIn
+	}
+
+	if inConf.RemovePrivateAS != "" {
+		outConf.RemovePrivateAS = inConf.RemovePrivateAS
+	}
+
+	if inConf.LocalASNoPrepend != false {
+		outConf.LocalASNoPrepend = inConf.LocalASNoPrepend
+	}
+
+	if inConf.LocalASReplaceAS != false {
+		outConf.LocalASReplaceAS = inConf.LocalASReplaceAS
+	}
+
+	if inConf.AsOverride != false {
+		outConf.AsOverride = inConf.AsOverride
+	}
+
+	if inConf.DefaultOriginate != false {
+		outConf.DefaultOriginate = inConf.DefaultOriginate
+	}
+
+	if inConf.DefaultOriginateCond != "" {
+		outConf.DefaultOriginateCond = inConf.DefaultOriginateCond
+	}
+
+	if inConf.ConditionalAdv.ConditionPrefix != "" {
+		outConf.ConditionalAdv = inConf.ConditionalAdv
+	}
+
+	if inConf.LabeledUnicast != false {
+		outConf.LabeledUnicast = inConf.LabeledUnicast
+	}
+
+	if len(inConf.AddPaths) > 0 {
+		outConf.AddPaths = inConf.AddPaths
+	}
+
 	n.setDefaults(outConf)
 	outConf.PeerAddressType = inConf.PeerAddressType
+	outConf.Vrf = inConf.Vrf
 	outConf.NeighborAddress = inConf.NeighborAddress
 	outConf.IfIndex = inConf.IfIndex
 	outConf.IfName = inConf.IfName
@@ -314,6 +413,20 @@ func (n *NeighborConf) setDefaults(nConf *config.NeighborConfig) {
 	}
 }
 
+// GetDampeningConfig returns the route flap dampening config of the peer. The neighbor or peer group config
+// takes precedence over the global config. It returns nil if dampening is not enabled for the peer.
+func (n *NeighborConf) GetDampeningConfig() *config.DampeningConfig {
+	if n.RunningConf.Dampening.Enabled {
+		return &n.RunningConf.Dampening
+	}
+
+	if n.Global != nil && n.Global.Dampening.Enabled {
+		return &n.Global.Dampening
+	}
+
+	return nil
+}
+
 func (n *NeighborConf) IsInternal() bool {
 	return n.RunningConf.PeerAS == n.RunningConf.LocalAS
 }
@@ -322,10 +435,130 @@ func (n *NeighborConf) IsExternal() bool {
 	return n.RunningConf.LocalAS != n.RunningConf.PeerAS
 }
 
+// IsConfedExternal returns true if the peer is in another member AS of the confederation, RFC 5065. The
+// session is an eBGP session but the NEXT_HOP, MED and LOCAL_PREF of the routes are kept like for the
+// internal peers.
+func (n *NeighborConf) IsConfedExternal() bool {
+	if n.Global == nil || n.Global.ConfederationId == 0 || n.IsInternal() {
+		return false
+	}
+
+	for _, as := range n.Global.ConfederationPeers {
+		if as == n.RunningConf.PeerAS {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAdvertisedAS returns the AS that is sent to the peer in the OPEN message and prepended to the AS path. It's
+// the local AS if it's configured for the peer, the confederation identifier for the peers outside the
+// confederation and the global AS for the others.
+func (n *NeighborConf) GetAdvertisedAS() uint32 {
+	if n.IsLocalASConfigured() {
+		return n.RunningConf.LocalAS
+	}
+
+	return n.GetRouterAS()
+}
+
+// GetRouterAS returns the AS of the router as seen by the peer without the local AS of the peer. It's the
+// confederation identifier for the peers outside the confederation and the global AS for the others.
+func (n *NeighborConf) GetRouterAS() uint32 {
+	if n.Global == nil {
+		return n.RunningConf.LocalAS
+	}
+
+	if n.Global.ConfederationId != 0 && n.IsExternal() && !n.IsConfedExternal() {
+		return n.Global.ConfederationId
+	}
+
+	return n.Global.AS
+}
+
+// IsLocalASConfigured returns true if the peer uses a local AS that is different from the global AS, e.g. while
+// the AS of the router is migrated.
+func (n *NeighborConf) IsLocalASConfigured() bool {
+	return n.Global != nil && n.RunningConf.LocalAS != 0 && n.RunningConf.LocalAS != n.Global.AS
+}
+
+// HasASLoop returns true if the AS path received from the peer has the local AS, or the AS of the router, more
+// times than allowed by the allowas-in config of the peer. The local AS that is prepended to the received routes
+// is not counted.
+func (n *NeighborConf) HasASLoop(pathAttrs []packet.BGPPathAttr) bool {
+	allowed := int(n.RunningConf.AllowAsIn)
+	localASCount := allowed
+	if n.IsLocalASConfigured() && n.IsExternal() && !n.RunningConf.LocalASNoPrepend {
+		localASCount++
+	}
+
+	if packet.GetASCount(pathAttrs, n.RunningConf.LocalAS) > localASCount {
+		return true
+	}
+
+	if routerAS := n.GetRouterAS(); routerAS != n.RunningConf.LocalAS &&
+		packet.GetASCount(pathAttrs, routerAS) > allowed {
+		return true
+	}
+	return false
+}
+
+// IsDefaultOriginate returns true if the default route of the unicast protocol family is originated to the peer.
+// It's enabled by the neighbor config or by SendDefaultRoute of the AFI-SAFI config of the neighbor.
+func (n *NeighborConf) IsDefaultOriginate(protoFamily uint32) bool {
+	afi, safi := packet.GetAfiSafi(protoFamily)
+	if safi != packet.SafiUnicast || !n.AfiSafiMap[protoFamily] {
+		return false
+	}
+
+	if n.RunningConf.DefaultOriginate {
+		return true
+	}
+
+	for _, afiSafi := range n.Neighbor.AfiSafis {
+		if (afi == packet.AfiIP && afiSafi.IPv4Unicast.SendDefaultRoute) ||
+			(afi == packet.AfiIP6 && afiSafi.IPv6Unicast.SendDefaultRoute) {
+			return true
+		}
+	}
+	return false
+}
+
 func (n *NeighborConf) IsRouteReflectorClient() bool {
 	return n.RunningConf.RouteReflectorClient
 }
 
+// IsDynamic returns true if the neighbor was created from a listen range when the peer connected.
+func (n *NeighborConf) IsDynamic() bool {
+	return n.ListenRange != ""
+}
+
+// IsDynamicPeerASAllowed returns true if a dynamic neighbor is allowed to peer from the AS that it sent in the
+// OPEN message. The AS must match the peer AS of the peer group if it is set, otherwise it must be one of the
+// allowed AS numbers of the listen range.
+func (n *NeighborConf) IsDynamicPeerASAllowed(peerAS uint32) bool {
+	if n.Group != nil && n.Group.PeerAS != 0 {
+		return n.Group.PeerAS == peerAS
+	}
+
+	if len(n.AllowedPeerAS) == 0 {
+		return true
+	}
+	for _, as := range n.AllowedPeerAS {
+		if as == peerAS {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDynamicPeerAS sets the peer AS of a dynamic neighbor to the AS that it sent in the OPEN message.
+func (n *NeighborConf) SetDynamicPeerAS(peerAS uint32) {
+	n.RunningConf.PeerAS = peerAS
+	n.Neighbor.State.PeerAS = peerAS
+	n.setOtherStates()
+}
+
 func (n *NeighborConf) IncrPrefixCount() {
 	n.Neighbor.State.TotalPrefixes++
 }
@@ -383,27 +616,145 @@ func (n *NeighborConf) FSMStateChange(state uint32) {
 }
 
 func (n *NeighborConf) SetPeerAttrs(bgpId net.IP, asSize uint8, holdTime uint32, keepaliveTime uint32,
-	addPathFamily map[packet.AFI]map[packet.SAFI]uint8) {
+	addPathFamily map[packet.AFI]map[packet.SAFI]uint8, routeRefresh bool, enhancedRouteRefresh bool) {
 	n.BGPId = bgpId
 	n.ASSize = asSize
 	n.Neighbor.State.HoldTime = holdTime
 	n.Neighbor.State.KeepaliveTime = keepaliveTime
+	n.Neighbor.State.RouteRefresh = routeRefresh
+	n.Neighbor.State.EnhancedRouteRefresh = enhancedRouteRefresh
+	n.AddPathsFamilies = make(map[uint32]config.AddPathsConfig)
+	n.Neighbor.State.AddPaths = make([]config.AddPathsConfig, 0)
 	for afi, safiMap := range addPathFamily {
-		if afi == packet.AfiIP || afi == packet.AfiIP6 {
-			for _, val := range safiMap {
-				if (val & packet.BGPCapAddPathRx) != 0 {
-					n.logger.Infof("SetPeerAttrs - Neighbor %s set add paths maxtx to %d",
-						n.Neighbor.NeighborAddress, n.RunningConf.AddPathsMaxTx)
-					n.Neighbor.State.AddPathsMaxTx = n.RunningConf.AddPathsMaxTx
-				}
-				if (val & packet.BGPCapAddPathTx) != 0 {
-					n.logger.Infof("SetPeerAttrs - Neighbor %s set add paths rx to %s",
-						n.Neighbor.NeighborAddress, n.RunningConf.AddPathsRx)
-					n.Neighbor.State.AddPathsRx = true
+		for safi, val := range safiMap {
+			protoFamily := packet.GetProtocolFamily(afi, safi)
+			addPathsConf := n.GetAddPathsConfig(protoFamily)
+			negotiated := config.AddPathsConfig{AfiSafiName: addPathsConf.AfiSafiName}
+			if addPathsConf.Rx && (val&packet.BGPCapAddPathTx) != 0 {
+				negotiated.Rx = true
+				n.Neighbor.State.AddPathsRx = true
+			}
+			if addPathsConf.TxMode != "" && (val&packet.BGPCapAddPathRx) != 0 {
+				negotiated.TxMode = addPathsConf.TxMode
+				negotiated.MaxTx = addPathsConf.MaxTx
+				if negotiated.MaxTx > n.Neighbor.State.AddPathsMaxTx {
+					n.Neighbor.State.AddPathsMaxTx = negotiated.MaxTx
 				}
 			}
+			if negotiated.Rx || negotiated.TxMode != "" {
+				n.logger.Infof("SetPeerAttrs - Neighbor %s negotiated add paths %+v", n.Neighbor.NeighborAddress,
+					negotiated)
+				n.AddPathsFamilies[protoFamily] = negotiated
+				n.Neighbor.State.AddPaths = append(n.Neighbor.State.AddPaths, negotiated)
+			}
+		}
+	}
+}
+
+// GetAddPathsConfig returns the add path config of the protocol family. The per family config takes precedence
+// over AddPathsRx and AddPathsMaxTx, that apply to all the families. Add path is only supported for the unicast
+// families.
+func (n *NeighborConf) GetAddPathsConfig(protoFamily uint32) config.AddPathsConfig {
+	addPathsConf := config.AddPathsConfig{AfiSafiName: packet.GetProtocolFamilyName(protoFamily)}
+	if !packet.IsUnicastFamily(protoFamily) || !n.AfiSafiMap[protoFamily] {
+		return addPathsConf
+	}
+
+	for _, familyConf := range n.RunningConf.AddPaths {
+		if pf, ok := packet.ProtocolFamilyMap[familyConf.AfiSafiName]; ok && pf == protoFamily {
+			return familyConf
 		}
 	}
+
+	addPathsConf.Rx = n.RunningConf.AddPathsRx
+	if n.RunningConf.AddPathsMaxTx > 0 {
+		addPathsConf.TxMode = config.AddPathsTxModeBestN
+		addPathsConf.MaxTx = n.RunningConf.AddPathsMaxTx
+	}
+	return addPathsConf
+}
+
+// GetAddPathsFlags returns the add path capability flags of the protocol families sent to the peer.
+func (n *NeighborConf) GetAddPathsFlags() map[uint32]uint8 {
+	addPathFlags := make(map[uint32]uint8)
+	for protoFamily, ok := range n.AfiSafiMap {
+		addPathsConf := n.GetAddPathsConfig(protoFamily)
+		flags := uint8(0)
+		if addPathsConf.Rx {
+			flags |= packet.BGPCapAddPathRx
+		}
+		if addPathsConf.TxMode != "" {
+			flags |= packet.BGPCapAddPathTx
+		}
+		if ok && flags != 0 {
+			addPathFlags[protoFamily] = flags
+		}
+	}
+	return addPathFlags
+}
+
+// GetAddPathsTx returns the add path config negotiated with the peer for the protocol family. TxMode is empty
+// if the paths of the family are not advertised with path ids.
+func (n *NeighborConf) GetAddPathsTx(protoFamily uint32) config.AddPathsConfig {
+	return n.AddPathsFamilies[protoFamily]
+}
+
+// GetAddPathCount returns the number of paths, in addition to the best path, that are ranked in the Loc-RIB for
+// the add path TX modes negotiated with the peer.
+func (n *NeighborConf) GetAddPathCount() int {
+	count := 0
+	for _, addPathsConf := range n.AddPathsFamilies {
+		switch addPathsConf.TxMode {
+		case config.AddPathsTxModeBestN:
+			if int(addPathsConf.MaxTx) > count {
+				count = int(addPathsConf.MaxTx)
+			}
+		case config.AddPathsTxModeAll, config.AddPathsTxModeECMP, config.AddPathsTxModeBackup:
+			return config.BGPAddPathCountAll
+		}
+	}
+	return count
+}
+
+func (n *NeighborConf) SetPeerGRAttrs(grCap *packet.BGPCapGracefulRestart, afiSafiMap map[uint32]bool) {
+	n.PeerAfiSafiMap = make(map[uint32]bool)
+	n.GRFamilies = make(map[uint32]bool)
+	n.EORPending = make(map[uint32]bool)
+	for protoFamily, ok := range afiSafiMap {
+		if ok && n.AfiSafiMap[protoFamily] {
+			n.PeerAfiSafiMap[protoFamily] = true
+		}
+	}
+
+	if grCap == nil || !n.Global.GracefulRestart {
+		n.Neighbor.State.GracefulRestart = false
+		n.Neighbor.State.PeerRestartTime = 0
+		return
+	}
+
+	n.logger.Infof("SetPeerGRAttrs - Neighbor %s graceful restart capability %+v", n.Neighbor.NeighborAddress,
+		grCap)
+	n.Neighbor.State.GracefulRestart = true
+	n.Neighbor.State.PeerRestartTime = grCap.RestartTime
+	for protoFamily, forwarding := range grCap.GetForwardingFamilies() {
+		if n.PeerAfiSafiMap[protoFamily] {
+			n.GRFamilies[protoFamily] = forwarding
+		}
+	}
+	for protoFamily, _ := range n.PeerAfiSafiMap {
+		n.EORPending[protoFamily] = true
+	}
+}
+
+// CanRetainStaleFamily returns true if the paths of the protocol family retained during the graceful restart
+// of the peer can be kept after the session is established again.
+func (n *NeighborConf) CanRetainStaleFamily(protoFamily uint32) bool {
+	forwarding, ok := n.GRFamilies[protoFamily]
+	return ok && forwarding
+}
+
+func (n *NeighborConf) HasStalePaths() bool {
+	return len(n.StaleFamilies) > 0
 }
 
 func (n *NeighborConf) BfdFaultSet() {
@@ -436,5 +787,13 @@ func (n *NeighborConf) PeerConnBroken() {
 	n.Neighbor.State.KeepaliveTime = n.RunningConf.KeepaliveTime
 	n.Neighbor.State.AddPathsRx = false
 	n.Neighbor.State.AddPathsMaxTx = 0
+	n.Neighbor.State.AddPaths = make([]config.AddPathsConfig, 0)
+	n.Neighbor.State.RouteRefresh = false
+	n.Neighbor.State.EnhancedRouteRefresh = false
+	n.Neighbor.State.GracefulRestart = false
+	n.Neighbor.State.PeerRestartTime = 0
 	n.Neighbor.State.TotalPrefixes = 0
+	n.PeerAfiSafiMap = make(map[uint32]bool)
+	n.EORPending = make(map[uint32]bool)
+	n.AddPathsFamilies = make(map[uint32]config.AddPathsConfig)
 }
@@ -0,0 +1,177 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// bmp_test.go
+package bmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"l3/bgp/config"
+	"net"
+	"testing"
+	"time"
+	"utils/logging"
+)
+
+func getTestPeerHeader() *PeerHeader {
+	return &PeerHeader{
+		Address:   net.ParseIP("10.1.1.2"),
+		AS:        65001,
+		BGPId:     net.ParseIP("2.2.2.2"),
+		Timestamp: time.Unix(1000, 5000),
+	}
+}
+
+func verifyCommonHeader(t *testing.T, pkt []byte, msgType BMPMsgType) {
+	if pkt[0] != BMPVersion {
+		t.Fatal("BMP version", pkt[0], "expected", BMPVersion)
+	}
+	if int(binary.BigEndian.Uint32(pkt[1:5])) != len(pkt) {
+		t.Fatal("BMP message length", binary.BigEndian.Uint32(pkt[1:5]), "expected", len(pkt))
+	}
+	if BMPMsgType(pkt[5]) != msgType {
+		t.Fatal("BMP message type", pkt[5], "expected", msgType)
+	}
+}
+
+func TestBMPPeerHeader(t *testing.T) {
+	pkt := NewRouteMonitoringMessage(getTestPeerHeader(), []byte{1, 2, 3})
+	verifyCommonHeader(t, pkt, BMPMsgTypeRouteMonitoring)
+	peer := pkt[BMPCommonHeaderLen:]
+	if peer[1] != 0 {
+		t.Fatal("BMP peer flags", peer[1], "expected 0")
+	}
+	if !bytes.Equal(peer[10:26], append(make([]byte, 12), 10, 1, 1, 2)) {
+		t.Fatal("BMP peer address", peer[10:26], "not encoded in the last 4 bytes")
+	}
+	if binary.BigEndian.Uint32(peer[26:30]) != 65001 || !bytes.Equal(peer[30:34], []byte{2, 2, 2, 2}) {
+		t.Fatal("BMP peer AS or BGP id not encoded correctly", peer[26:34])
+	}
+	if binary.BigEndian.Uint32(peer[34:38]) != 1000 || binary.BigEndian.Uint32(peer[38:42]) != 5 {
+		t.Fatal("BMP peer timestamp not encoded correctly", peer[34:42])
+	}
+	if !bytes.Equal(peer[BMPPeerHeaderLen:], []byte{1, 2, 3}) {
+		t.Fatal("BMP route monitoring update", peer[BMPPeerHeaderLen:], "expected [1 2 3]")
+	}
+
+	header := getTestPeerHeader()
+	header.Address = net.ParseIP("2001:db8::2")
+	pkt = NewRouteMonitoringMessage(header, nil)
+	if pkt[BMPCommonHeaderLen+1] != BMPPeerFlagIPv6 {
+		t.Fatal("BMP peer flags", pkt[BMPCommonHeaderLen+1], "expected", BMPPeerFlagIPv6)
+	}
+}
+
+func TestBMPStatsReport(t *testing.T) {
+	stats := map[uint16]uint64{BMPStatAdjRIBInRoutes: 100, BMPStatPrefixesRejected: 3}
+	pkt := NewStatsReportMessage(getTestPeerHeader(), stats)
+	verifyCommonHeader(t, pkt, BMPMsgTypeStatsReport)
+	body := pkt[BMPCommonHeaderLen+BMPPeerHeaderLen:]
+	if binary.BigEndian.Uint32(body[0:4]) != 2 {
+		t.Fatal("BMP stats count", binary.BigEndian.Uint32(body[0:4]), "expected 2")
+	}
+	expected := []byte{0, 0, 0, 4, 0, 0, 0, 3, 0, 7, 0, 8, 0, 0, 0, 0, 0, 0, 0, 100}
+	if !bytes.Equal(body[4:], expected) {
+		t.Fatal("BMP stats", body[4:], "expected", expected)
+	}
+}
+
+func readMessage(t *testing.T, conn net.Conn) []byte {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, BMPCommonHeaderLen)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatal("Failed to read BMP message header with error:", err)
+	}
+	pkt := make([]byte, binary.BigEndian.Uint32(header[1:5]))
+	copy(pkt, header)
+	if _, err := io.ReadFull(conn, pkt[BMPCommonHeaderLen:]); err != nil {
+		t.Fatal("Failed to read BMP message with error:", err)
+	}
+	return pkt
+}
+
+func TestBMPClientCollector(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to start the collector with error:", err)
+	}
+	defer listener.Close()
+	addr := listener.Addr().(*net.TCPAddr)
+
+	logger, _ := logging.NewLogger("bgpd", "BGP", false)
+	client := NewClient(logger, "router1", "bgpd")
+	openMsg := []byte{0xff, 0xff}
+	client.PeerUp("10.1.1.2", getTestPeerHeader(), net.ParseIP("10.1.1.1"), 179, 40000, openMsg, openMsg)
+
+	err = client.AddCollector(config.BMPCollector{
+		Name:                  "station1",
+		Address:               addr.IP,
+		Port:                  uint16(addr.Port),
+		RouteMonitoringPolicy: config.BMPRouteMonitoringPostPolicy,
+	})
+	if err != nil {
+		t.Fatal("AddCollector failed with error:", err)
+	}
+	if err = client.AddCollector(config.BMPCollector{Name: "station1"}); err == nil {
+		t.Fatal("AddCollector did not fail for a duplicate collector")
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal("Collector failed to accept the connection with error:", err)
+	}
+	defer conn.Close()
+
+	verifyCommonHeader(t, readMessage(t, conn), BMPMsgTypeInitiation)
+	pkt := readMessage(t, conn)
+	verifyCommonHeader(t, pkt, BMPMsgTypePeerUp)
+	if !bytes.Equal(pkt[len(pkt)-4:], []byte{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatal("BMP peer up OPEN messages", pkt[len(pkt)-4:], "not encoded")
+	}
+
+	client.RouteMonitoring("10.1.1.2", []byte{1}, false)
+	client.RouteMonitoring("10.1.1.2", []byte{2}, true)
+	pkt = readMessage(t, conn)
+	verifyCommonHeader(t, pkt, BMPMsgTypeRouteMonitoring)
+	if pkt[BMPCommonHeaderLen+1] != BMPPeerFlagPostPolicy || pkt[len(pkt)-1] != 2 {
+		t.Fatal("BMP route monitoring expected the post-policy update, got", pkt)
+	}
+
+	client.PeerDown("10.1.1.2", BMPPeerDownDeconfigured, nil)
+	pkt = readMessage(t, conn)
+	verifyCommonHeader(t, pkt, BMPMsgTypePeerDown)
+	if pkt[BMPCommonHeaderLen+BMPPeerHeaderLen] != BMPPeerDownDeconfigured {
+		t.Fatal("BMP peer down reason", pkt[BMPCommonHeaderLen+BMPPeerHeaderLen], "expected",
+			BMPPeerDownDeconfigured)
+	}
+
+	if err = client.RemoveCollector("station1"); err != nil {
+		t.Fatal("RemoveCollector failed with error:", err)
+	}
+	verifyCommonHeader(t, readMessage(t, conn), BMPMsgTypeTermination)
+	if client.HasCollectors() {
+		t.Fatal("BMP client has collectors after the collector was removed")
+	}
+}
@@ -0,0 +1,320 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// client.go
+package bmp
+
+import (
+	"errors"
+	"fmt"
+	"l3/bgp/config"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"utils/logging"
+)
+
+const (
+	BMPConnectRetryTime = 30 * time.Second
+	BMPConnectTimeout   = 10 * time.Second
+	BMPMsgQueueSize     = 4096
+)
+
+type peerState struct {
+	header PeerHeader
+	peerUp []byte
+	stats  map[uint16]uint64
+}
+
+type collector struct {
+	config config.BMPCollector
+	msgCh  chan []byte
+	resync bool
+	stopCh chan bool
+}
+
+// Client streams the state of the BGP peers to the configured BMP monitoring stations. The peer up messages
+// are cached so that a station that (re)connects gets the state of all the established peers.
+type Client struct {
+	logger     *logging.Writer
+	sysName    string
+	sysDescr   string
+	mutex      sync.RWMutex
+	collectors map[string]*collector
+	peers      map[string]*peerState
+}
+
+func NewClient(logger *logging.Writer, sysName string, sysDescr string) *Client {
+	return &Client{
+		logger:     logger,
+		sysName:    sysName,
+		sysDescr:   sysDescr,
+		collectors: make(map[string]*collector),
+		peers:      make(map[string]*peerState),
+	}
+}
+
+func (c *Client) HasCollectors() bool {
+	defer c.mutex.RUnlock()
+	c.mutex.RLock()
+	return len(c.collectors) > 0
+}
+
+func (c *Client) AddCollector(conf config.BMPCollector) error {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	if _, ok := c.collectors[conf.Name]; ok {
+		return errors.New(fmt.Sprintf("BMP collector %s already exists", conf.Name))
+	}
+
+	col := &collector{
+		config: conf,
+		stopCh: make(chan bool),
+	}
+	c.collectors[conf.Name] = col
+	go c.runCollector(col)
+	return nil
+}
+
+func (c *Client) RemoveCollector(name string) error {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	col, ok := c.collectors[name]
+	if !ok {
+		return errors.New(fmt.Sprintf("BMP collector %s not found", name))
+	}
+
+	delete(c.collectors, name)
+	close(col.stopCh)
+	return nil
+}
+
+// PeerUp caches the peer up message of the peer and sends it to the connected stations.
+func (c *Client) PeerUp(key string, header *PeerHeader, localAddr net.IP, localPort uint16, remotePort uint16,
+	sentOpen []byte, recvOpen []byte) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	peer := &peerState{
+		header: *header,
+		peerUp: NewPeerUpMessage(header, localAddr, localPort, remotePort, sentOpen, recvOpen),
+		stats:  make(map[uint16]uint64),
+	}
+	c.peers[key] = peer
+	c.sendToAll(peer.peerUp, false, false)
+}
+
+func (c *Client) PeerDown(key string, reason uint8, data []byte) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	peer, ok := c.peers[key]
+	if !ok {
+		return
+	}
+
+	delete(c.peers, key)
+	c.sendToAll(NewPeerDownMessage(&peer.header, reason, data), false, false)
+}
+
+// RouteMonitoring sends the UPDATE message of the peer to the stations that monitor the Adj-RIB-In of the
+// peer before or after the import policies are applied.
+func (c *Client) RouteMonitoring(key string, update []byte, postPolicy bool) {
+	defer c.mutex.RUnlock()
+	c.mutex.RLock()
+	peer, ok := c.peers[key]
+	if !ok {
+		return
+	}
+
+	header := peer.header
+	if postPolicy {
+		header.Flags |= BMPPeerFlagPostPolicy
+	}
+	header.Timestamp = time.Now()
+	c.sendToAll(NewRouteMonitoringMessage(&header, update), true, postPolicy)
+}
+
+func (c *Client) IncrementStat(key string, statType uint16, delta uint64) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	if peer, ok := c.peers[key]; ok {
+		peer.stats[statType] += delta
+	}
+}
+
+func (c *Client) SetStat(key string, statType uint16, value uint64) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	if peer, ok := c.peers[key]; ok {
+		peer.stats[statType] = value
+	}
+}
+
+func monitorsPolicy(policy string, postPolicy bool) bool {
+	switch policy {
+	case config.BMPRouteMonitoringAll:
+		return true
+	case config.BMPRouteMonitoringPostPolicy:
+		return postPolicy
+	default:
+		return !postPolicy
+	}
+}
+
+// sendToAll queues the message to all the connected stations, it should be called with the client mutex held.
+func (c *Client) sendToAll(msg []byte, routeMonitoring bool, postPolicy bool) {
+	for _, col := range c.collectors {
+		if routeMonitoring && !monitorsPolicy(col.config.RouteMonitoringPolicy, postPolicy) {
+			continue
+		}
+		c.send(col, msg)
+	}
+}
+
+// send queues the message to the station without blocking. If the queue is full, the messages are dropped
+// and the connection to the station is reset to resynchronize the state of the peers.
+func (c *Client) send(col *collector, msg []byte) {
+	if col.msgCh == nil || col.resync {
+		return
+	}
+
+	select {
+	case col.msgCh <- msg:
+	default:
+		c.logger.Err("BMP collector", col.config.Name, "message queue full, resetting the connection")
+		col.resync = true
+	}
+}
+
+// connected sets up a new message queue for the station with the initiation message and the peer up
+// messages of all the established peers.
+func (c *Client) connected(col *collector) chan []byte {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	col.msgCh = make(chan []byte, BMPMsgQueueSize)
+	col.resync = false
+	col.msgCh <- NewInitiationMessage(c.sysDescr, c.sysName)
+	for _, peer := range c.peers {
+		c.send(col, peer.peerUp)
+	}
+	return col.msgCh
+}
+
+func (c *Client) disconnected(col *collector) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	col.msgCh = nil
+}
+
+func (c *Client) needsResync(col *collector) bool {
+	defer c.mutex.RUnlock()
+	c.mutex.RLock()
+	return col.resync
+}
+
+func (c *Client) sendStats(col *collector) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	now := time.Now()
+	for _, peer := range c.peers {
+		header := peer.header
+		header.Timestamp = now
+		c.send(col, NewStatsReportMessage(&header, peer.stats))
+	}
+}
+
+func (c *Client) runCollector(col *collector) {
+	addr := net.JoinHostPort(col.config.Address.String(), strconv.Itoa(int(col.config.Port)))
+	for {
+		c.logger.Info("BMP collector", col.config.Name, "connecting to", addr)
+		conn, err := net.DialTimeout("tcp", addr, BMPConnectTimeout)
+		if err != nil {
+			c.logger.Err("BMP collector", col.config.Name, "failed to connect to", addr, "with error", err)
+		} else {
+			c.logger.Info("BMP collector", col.config.Name, "connected to", addr)
+			stopped := c.serveCollector(col, conn)
+			conn.Close()
+			c.disconnected(col)
+			if stopped {
+				return
+			}
+		}
+
+		select {
+		case <-col.stopCh:
+			return
+
+		case <-time.After(BMPConnectRetryTime):
+		}
+	}
+}
+
+// serveCollector writes the queued messages to the station until the connection fails or the collector is
+// removed. It returns true if the collector is removed.
+func (c *Client) serveCollector(col *collector, conn net.Conn) bool {
+	msgCh := c.connected(col)
+
+	// The stations do not send any messages, reading from the connection detects when it is closed.
+	closedCh := make(chan bool)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(closedCh)
+				return
+			}
+		}
+	}()
+
+	var statsCh <-chan time.Time
+	if col.config.StatsInterval > 0 {
+		ticker := time.NewTicker(time.Duration(col.config.StatsInterval) * time.Second)
+		defer ticker.Stop()
+		statsCh = ticker.C
+	}
+
+	for {
+		select {
+		case msg := <-msgCh:
+			if _, err := conn.Write(msg); err != nil {
+				c.logger.Err("BMP collector", col.config.Name, "write failed with error", err)
+				return false
+			}
+			if len(msgCh) == 0 && c.needsResync(col) {
+				return false
+			}
+
+		case <-statsCh:
+			c.sendStats(col)
+
+		case <-closedCh:
+			c.logger.Info("BMP collector", col.config.Name, "connection closed by the station")
+			return false
+
+		case <-col.stopCh:
+			c.logger.Info("BMP collector", col.config.Name, "removed, closing the connection")
+			conn.Write(NewTerminationMessage(BMPTermReasonAdminClose))
+			return true
+		}
+	}
+}
@@ -0,0 +1,244 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// message.go
+package bmp
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"time"
+)
+
+const (
+	BMPVersion         uint8 = 3
+	BMPCommonHeaderLen       = 6
+	BMPPeerHeaderLen         = 42
+	BMPAddressLen            = 16
+	BMPTLVHeaderLen          = 4
+)
+
+type BMPMsgType uint8
+
+const (
+	BMPMsgTypeRouteMonitoring BMPMsgType = iota
+	BMPMsgTypeStatsReport
+	BMPMsgTypePeerDown
+	BMPMsgTypePeerUp
+	BMPMsgTypeInitiation
+	BMPMsgTypeTermination
+)
+
+const (
+	BMPPeerTypeGlobal uint8 = iota
+	BMPPeerTypeRD
+)
+
+const (
+	BMPPeerFlagIPv6       uint8 = 0x80
+	BMPPeerFlagPostPolicy uint8 = 0x40
+	BMPPeerFlagAS2Byte    uint8 = 0x20
+)
+
+const (
+	BMPInfoTypeString uint16 = iota
+	BMPInfoTypeSysDescr
+	BMPInfoTypeSysName
+)
+
+const (
+	BMPTermTypeString uint16 = iota
+	BMPTermTypeReason
+)
+
+const (
+	BMPTermReasonAdminClose uint16 = iota
+	BMPTermReasonUnspecified
+	BMPTermReasonOutOfResources
+	BMPTermReasonRedundantConn
+	BMPTermReasonPermAdminClose
+)
+
+const (
+	_ uint8 = iota
+	BMPPeerDownLocalNotification
+	BMPPeerDownLocalNoNotification
+	BMPPeerDownRemoteNotification
+	BMPPeerDownRemoteNoNotification
+	BMPPeerDownDeconfigured
+)
+
+const (
+	BMPStatPrefixesRejected uint16 = iota
+	BMPStatDuplicatePrefixes
+	BMPStatDuplicateWithdraws
+	BMPStatClusterListLoop
+	BMPStatASPathLoop
+	BMPStatOriginatorIdLoop
+	BMPStatASConfedLoop
+	BMPStatAdjRIBInRoutes
+	BMPStatLocRIBRoutes
+)
+
+// PeerHeader is the per peer header of the BMP messages about a BGP peer, RFC 7854 section 4.2.
+type PeerHeader struct {
+	PeerType      uint8
+	Flags         uint8
+	Distinguisher [8]byte
+	Address       net.IP
+	AS            uint32
+	BGPId         net.IP
+	Timestamp     time.Time
+}
+
+func (p *PeerHeader) encode(pkt []byte) {
+	pkt[0] = p.PeerType
+	pkt[1] = p.Flags
+	if p.Address.To4() == nil {
+		pkt[1] |= BMPPeerFlagIPv6
+	}
+	copy(pkt[2:10], p.Distinguisher[:])
+	encodeAddress(pkt[10:26], p.Address)
+	binary.BigEndian.PutUint32(pkt[26:30], p.AS)
+	copy(pkt[30:34], p.BGPId.To4())
+	binary.BigEndian.PutUint32(pkt[34:38], uint32(p.Timestamp.Unix()))
+	binary.BigEndian.PutUint32(pkt[38:42], uint32(p.Timestamp.Nanosecond()/1000))
+}
+
+// encodeAddress encodes the IPv4 address in the last 4 bytes of the 16 byte address field, the first 12 bytes
+// are zero.
+func encodeAddress(pkt []byte, ip net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(pkt[BMPAddressLen-net.IPv4len:BMPAddressLen], ip4)
+	} else {
+		copy(pkt[:BMPAddressLen], ip.To16())
+	}
+}
+
+func newMessage(msgType BMPMsgType, bodyLen int) []byte {
+	pkt := make([]byte, BMPCommonHeaderLen+bodyLen)
+	pkt[0] = BMPVersion
+	binary.BigEndian.PutUint32(pkt[1:5], uint32(len(pkt)))
+	pkt[5] = uint8(msgType)
+	return pkt
+}
+
+func encodeTLV(pkt []byte, tlvType uint16, value []byte) int {
+	binary.BigEndian.PutUint16(pkt[0:2], tlvType)
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(value)))
+	copy(pkt[BMPTLVHeaderLen:], value)
+	return BMPTLVHeaderLen + len(value)
+}
+
+// NewInitiationMessage returns the initiation message sent when the connection to a station is established.
+func NewInitiationMessage(sysDescr string, sysName string) []byte {
+	pkt := newMessage(BMPMsgTypeInitiation, 2*BMPTLVHeaderLen+len(sysDescr)+len(sysName))
+	idx := BMPCommonHeaderLen
+	idx += encodeTLV(pkt[idx:], BMPInfoTypeSysDescr, []byte(sysDescr))
+	encodeTLV(pkt[idx:], BMPInfoTypeSysName, []byte(sysName))
+	return pkt
+}
+
+// NewTerminationMessage returns the termination message sent before the connection to a station is closed.
+func NewTerminationMessage(reason uint16) []byte {
+	pkt := newMessage(BMPMsgTypeTermination, BMPTLVHeaderLen+2)
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, reason)
+	encodeTLV(pkt[BMPCommonHeaderLen:], BMPTermTypeReason, value)
+	return pkt
+}
+
+// NewPeerUpMessage returns the peer up notification with the OPEN messages sent and received by the peer.
+func NewPeerUpMessage(peer *PeerHeader, localAddr net.IP, localPort uint16, remotePort uint16, sentOpen []byte,
+	recvOpen []byte) []byte {
+	pkt := newMessage(BMPMsgTypePeerUp, BMPPeerHeaderLen+BMPAddressLen+4+len(sentOpen)+len(recvOpen))
+	idx := BMPCommonHeaderLen
+	peer.encode(pkt[idx:])
+	idx += BMPPeerHeaderLen
+	encodeAddress(pkt[idx:idx+BMPAddressLen], localAddr)
+	idx += BMPAddressLen
+	binary.BigEndian.PutUint16(pkt[idx:idx+2], localPort)
+	binary.BigEndian.PutUint16(pkt[idx+2:idx+4], remotePort)
+	idx += 4
+	idx += copy(pkt[idx:], sentOpen)
+	copy(pkt[idx:], recvOpen)
+	return pkt
+}
+
+// NewPeerDownMessage returns the peer down notification. The data is the NOTIFICATION message for the reasons
+// BMPPeerDownLocalNotification and BMPPeerDownRemoteNotification, and the FSM event code for the reason
+// BMPPeerDownLocalNoNotification.
+func NewPeerDownMessage(peer *PeerHeader, reason uint8, data []byte) []byte {
+	pkt := newMessage(BMPMsgTypePeerDown, BMPPeerHeaderLen+1+len(data))
+	idx := BMPCommonHeaderLen
+	peer.encode(pkt[idx:])
+	idx += BMPPeerHeaderLen
+	pkt[idx] = reason
+	copy(pkt[idx+1:], data)
+	return pkt
+}
+
+// NewRouteMonitoringMessage returns the route monitoring message with an UPDATE message of the peer.
+func NewRouteMonitoringMessage(peer *PeerHeader, update []byte) []byte {
+	pkt := newMessage(BMPMsgTypeRouteMonitoring, BMPPeerHeaderLen+len(update))
+	peer.encode(pkt[BMPCommonHeaderLen:])
+	copy(pkt[BMPCommonHeaderLen+BMPPeerHeaderLen:], update)
+	return pkt
+}
+
+func getStatLen(statType uint16) int {
+	if statType == BMPStatAdjRIBInRoutes || statType == BMPStatLocRIBRoutes {
+		return 8
+	}
+	return 4
+}
+
+// NewStatsReportMessage returns the statistics report of the peer. The counters are 32 bits and the gauges
+// of the routes in the RIBs are 64 bits.
+func NewStatsReportMessage(peer *PeerHeader, stats map[uint16]uint64) []byte {
+	statTypes := make([]int, 0, len(stats))
+	bodyLen := BMPPeerHeaderLen + 4
+	for statType, _ := range stats {
+		statTypes = append(statTypes, int(statType))
+		bodyLen += BMPTLVHeaderLen + getStatLen(statType)
+	}
+	sort.Ints(statTypes)
+
+	pkt := newMessage(BMPMsgTypeStatsReport, bodyLen)
+	idx := BMPCommonHeaderLen
+	peer.encode(pkt[idx:])
+	idx += BMPPeerHeaderLen
+	binary.BigEndian.PutUint32(pkt[idx:idx+4], uint32(len(statTypes)))
+	idx += 4
+	for _, statType := range statTypes {
+		value := make([]byte, getStatLen(uint16(statType)))
+		if len(value) == 8 {
+			binary.BigEndian.PutUint64(value, stats[uint16(statType)])
+		} else {
+			binary.BigEndian.PutUint32(value, uint32(stats[uint16(statType)]))
+		}
+		idx += encodeTLV(pkt[idx:], uint16(statType), value)
+	}
+	return pkt
+}
@@ -25,6 +25,7 @@
 package config
 
 import (
+	"math"
 	"net"
 	"time"
 )
@@ -34,6 +35,36 @@ type SourcePolicyMap struct {
 	Policy  string
 }
 
+// DampeningConfig holds the RFC 2439 route flap dampening parameters. HalfLife and MaxSuppressTime are
+// in minutes, ReuseLimit and SuppressLimit are figure of merit thresholds.
+type DampeningConfig struct {
+	Enabled         bool
+	HalfLife        uint16
+	ReuseLimit      uint32
+	SuppressLimit   uint32
+	MaxSuppressTime uint16
+}
+
+// ConditionalAdvConfig holds the conditional advertisement of the routes to a peer. The routes of Prefixes are
+// advertised only while ConditionPrefix is in the Loc-RIB, or only while it isn't if NonExist is set. The
+// prefixes are in the CIDR notation.
+type ConditionalAdvConfig struct {
+	Prefixes        []string
+	ConditionPrefix string
+	NonExist        bool
+}
+
+// AddPathsConfig holds the add path config of a protocol family of the neighbor. Paths are received with path
+// ids if Rx is set and advertised with path ids in TxMode. MaxTx is the number of paths advertised in the best-n
+// mode. The per family config overrides AddPathsRx and AddPathsMaxTx of the neighbor. In the neighbor state it
+// holds the add path config negotiated with the peer.
+type AddPathsConfig struct {
+	AfiSafiName string
+	Rx          bool
+	TxMode      string
+	MaxTx       uint8
+}
+
 type GlobalBase struct {
 	Vrf                 string
 	AS                  uint32
@@ -43,6 +74,16 @@ type GlobalBase struct {
 	EBGPMaxPaths        uint32
 	EBGPAllowMultipleAS bool
 	IBGPMaxPaths        uint32
+	GracefulRestart     bool
+	RestartTime         uint16
+	StalePathTime       uint16
+	Dampening           DampeningConfig
+	RouteDistinguisher  string
+	ImportRouteTargets  []string
+	ExportRouteTargets  []string
+	OriginValidation    string
+	ConfederationId     uint32   // AS number of the confederation advertised to the peers outside it, RFC 5065
+	ConfederationPeers  []uint32 // Member AS numbers of the other sub-ASes in the confederation
 }
 
 type GlobalConfig struct {
@@ -67,6 +108,7 @@ type PeerType int
 const (
 	PeerTypeInternal PeerType = iota
 	PeerTypeExternal
+	PeerTypeConfedExternal // Peer in another member AS of the confederation
 )
 
 type PeerAddressType int
@@ -117,10 +159,22 @@ type BaseConfig struct {
 	MaxPrefixesRestartTimer uint8
 	AdjRIBInFilter          string
 	AdjRIBOutFilter         string
+	Dampening               DampeningConfig
+	AllowAsIn               uint8  // Number of times the local AS is allowed in the AS path of the received routes
+	RemovePrivateAS         string // Private ASes are removed or replaced in the AS path sent to the eBGP peer
+	LocalASNoPrepend        bool   // Local AS is not prepended to the AS path of the received routes
+	LocalASReplaceAS        bool   // Only the local AS, and not the global AS, is prepended to the advertised routes
+	AsOverride              bool   // Peer AS is replaced with the local AS in the AS path of the advertised routes
+	DefaultOriginate        bool   // Default route is originated to the peer
+	DefaultOriginateCond    string // Default route is originated only while the prefix is in the Loc-RIB
+	ConditionalAdv          ConditionalAdvConfig
+	LabeledUnicast          bool // Labeled unicast family of the neighbor address family is negotiated, RFC 8277
+	AddPaths                []AddPathsConfig
 }
 
 type NeighborConfig struct {
 	BaseConfig
+	Vrf             string
 	NeighborAddress net.IP
 	IfIndex         int32
 	IfName          string
@@ -129,6 +183,7 @@ type NeighborConfig struct {
 }
 
 type NeighborState struct {
+	Vrf                     string
 	NeighborAddress         net.IP
 	IfIndex                 int32
 	Disabled                bool
@@ -154,6 +209,10 @@ type NeighborState struct {
 	PeerGroup               string
 	AddPathsRx              bool
 	AddPathsMaxTx           uint8
+	RouteRefresh            bool
+	EnhancedRouteRefresh    bool
+	GracefulRestart         bool
+	PeerRestartTime         uint16
 	MaxPrefixes             uint32
 	MaxPrefixesThresholdPct uint8
 	MaxPrefixesDisconnect   bool
@@ -161,6 +220,17 @@ type NeighborState struct {
 	TotalPrefixes           uint32
 	AdjRIBInFilter          string
 	AdjRIBOutFilter         string
+	Dampening               DampeningConfig
+	AllowAsIn               uint8
+	RemovePrivateAS         string
+	LocalASNoPrepend        bool
+	LocalASReplaceAS        bool
+	AsOverride              bool
+	DefaultOriginate        bool
+	DefaultOriginateCond    string
+	ConditionalAdv          ConditionalAdvConfig
+	LabeledUnicast          bool
+	AddPaths                []AddPathsConfig
 	SessionStateUpdatedTime time.Time
 }
 
@@ -229,10 +299,25 @@ type AfiSafiConfig struct {
 }
 
 type PeerCommand struct {
+	Vrf     string
 	IP      net.IP
 	Command int
 }
 
+type SoftResetDir int
+
+const (
+	SoftResetDirIn SoftResetDir = 1 << iota
+	SoftResetDirOut
+	SoftResetDirBoth = SoftResetDirIn | SoftResetDirOut
+)
+
+type SoftResetCommand struct {
+	Vrf       string
+	IP        net.IP
+	Direction SoftResetDir
+}
+
 type Neighbor struct {
 	NeighborAddress net.IP
 	Config          NeighborConfig
@@ -243,6 +328,7 @@ type Neighbor struct {
 
 type PeerGroupConfig struct {
 	BaseConfig
+	Vrf  string
 	Name string
 }
 
@@ -253,6 +339,7 @@ type PeerGroup struct {
 }
 
 type BGPAggregate struct {
+	Vrf             string
 	IPPrefix        string
 	GenerateASSet   bool
 	SendSummaryOnly bool
@@ -263,6 +350,104 @@ type AddressFamily struct {
 	BgpAggs map[string]*BGPAggregate
 }
 
+// Route monitoring policies of a BMP station, the routes are sent before and/or after the inbound policy
+// is applied.
+const (
+	BMPRouteMonitoringPrePolicy  string = "pre-policy"
+	BMPRouteMonitoringPostPolicy string = "post-policy"
+	BMPRouteMonitoringAll        string = "all"
+)
+
+// BMPCollector is a BGP Monitoring Protocol station, RFC 7854. StatsInterval is in seconds, statistics
+// reports are not sent if it is 0.
+type BMPCollector struct {
+	Name                  string
+	Address               net.IP
+	Port                  uint16
+	RouteMonitoringPolicy string
+	StatsInterval         uint32
+}
+
+const (
+	MRTDumpDirDefault        string = "/var/log/bgpd/mrt"
+	MRTRotateIntervalDefault uint32 = 900
+)
+
+// MRTConfig is the config of the MRT dumps, RFC 6396. The RIBs of the VRFs are dumped every RIBDumpInterval
+// seconds, they are not dumped periodically if it is 0. The received UPDATE messages are recorded to files
+// that are rotated every RotateInterval seconds.
+type MRTConfig struct {
+	Directory       string
+	RIBDumpInterval uint32
+	RecordUpdates   bool
+	RotateInterval  uint32
+}
+
+// MRTDumpCommand requests a RIB dump of the VRF, all the VRFs are dumped if Vrf is empty. The dump is written
+// to Directory.
+type MRTDumpCommand struct {
+	Vrf       string
+	Directory string
+}
+
+// Origin validation policies of the best path selection, RFC 6811. The validation state is not used in the
+// best path selection if the policy is not set.
+const (
+	OriginValidationPreferValid    string = "prefer-valid"
+	OriginValidationExcludeInvalid string = "exclude-invalid"
+)
+
+// Remove private AS options of the neighbor. The private ASes in the AS path are either all removed or all
+// replaced with the local AS when the route is advertised to an eBGP peer.
+const (
+	RemovePrivateASAll     string = "all"
+	RemovePrivateASReplace string = "replace"
+)
+
+const BGPAllowAsInMax uint8 = 10
+
+// BGPAddPathCountAll is the add path count of the Loc-RIB when all the paths are ranked for the add path peers
+const BGPAddPathCountAll int = math.MaxInt32
+
+// Add path TX selection modes of the paths advertised to the peer in addition to the best path.
+const (
+	AddPathsTxModeAll    string = "all"         // All the paths
+	AddPathsTxModeBestN  string = "best-n"      // Next best paths up to MaxTx paths in all
+	AddPathsTxModeECMP   string = "ecmp"        // Multipaths of the best path
+	AddPathsTxModeBackup string = "best-backup" // Best path from a neighbor AS other than that of the best path
+)
+
+const (
+	RPKICachePortDefault       uint16 = 323
+	RPKIRefreshIntervalDefault uint32 = 3600
+	RPKIRetryIntervalDefault   uint32 = 600
+	RPKIExpireIntervalDefault  uint32 = 7200
+)
+
+// RPKICacheServer is the RPKI cache that the ROAs are fetched from with the RPKI-Router protocol, RFC 8210.
+// The intervals are in seconds, they are replaced by the intervals sent by the cache in the End of Data PDU.
+// The RPKI client is stopped if Address is nil.
+type RPKICacheServer struct {
+	Address         net.IP
+	Port            uint16
+	RefreshInterval uint32
+	RetryInterval   uint32
+	ExpireInterval  uint32
+}
+
+// ListenRange is a prefix that inbound BGP sessions are accepted from without configuring the neighbors. The
+// neighbors are created dynamically with the config of PeerGroup when the TCP connection is accepted and are
+// removed when the session goes down. The AS number in the OPEN message of a dynamic neighbor must match the
+// peer AS of the peer group if it is set, otherwise it must be one of AllowedAS. Any AS is accepted if
+// AllowedAS is empty. The number of dynamic neighbors of the range is not limited if MaxPeers is 0.
+type ListenRange struct {
+	Vrf       string
+	Prefix    *net.IPNet
+	PeerGroup string
+	AllowedAS []uint32
+	MaxPeers  uint32
+}
+
 type Bgp struct {
 	Global     Global
 	PeerGroups map[uint32]map[string]*PeerGroup
@@ -278,6 +463,7 @@ type ConditionInfo struct {
 }
 
 type RouteConfig struct {
+	Vrf               string
 	Cost              int32
 	IntfType          int32
 	Protocol          string
@@ -287,4 +473,5 @@ type RouteConfig struct {
 	OutgoingInterface string
 	IsIPv6            bool
 	NullRoute         bool
+	Labels            []uint32
 }
@@ -63,6 +63,10 @@ const (
 	NOTIFY_POLICY_DEFINITION_CREATED
 	NOTIFY_POLICY_DEFINITION_DELETED
 	NOTIFY_POLICY_DEFINITION_UPDATED
+	EVPN_VTEP_CREATED
+	EVPN_VTEP_DELETED
+	EVPN_MAC_CREATED
+	EVPN_MAC_DELETED
 )
 
 type BfdInfo struct {
@@ -71,6 +75,24 @@ type BfdInfo struct {
 	State  bool
 }
 
+/*  Local VTEP of a VNI learned from the VXLAN daemon
+ */
+type EVPNVtepInfo struct {
+	Oper   Operation
+	Vni    uint32
+	VtepIp string
+}
+
+/*  Local MAC of a VNI learned from the VXLAN daemon
+ */
+type EVPNMacInfo struct {
+	Oper   Operation
+	Vni    uint32
+	Mac    string
+	Ip     string
+	VtepIp string
+}
+
 type IntfStateInfo struct {
 	Idx         int32
 	IPAddr      string
@@ -95,6 +117,7 @@ func NewIntfStateInfo(idx int32, ipAddr string, linklocalIp string, state Operat
 /*  This is mimic of ribd object...@TODO: need to change this to bgp server object
  */
 type RouteInfo struct {
+	Vrf              string
 	IPAddr           string
 	Mask             string
 	NextHopIp        string
@@ -27,6 +27,14 @@ package config
 const BGPConnectRetryTime uint32 = 120 // seconds
 const BGPHoldTimeDefault uint32 = 180  // 180 seconds
 
+const BGPGRRestartTimeDefault uint16 = 120   // seconds
+const BGPGRStalePathTimeDefault uint16 = 360 // seconds
+
+const BGPDampeningHalfLifeDefault uint16 = 15 // minutes
+const BGPDampeningReuseLimitDefault uint32 = 750
+const BGPDampeningSuppressLimitDefault uint32 = 2000
+const BGPDampeningMaxSuppressTimeDefault uint16 = 60 // minutes
+
 type BGPFSMState int
 
 const (
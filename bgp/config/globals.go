@@ -25,3 +25,6 @@
 package config
 
 const BGPPort string = "179"
+
+// BGPDefaultVrf is the name of the VRF used when none is configured.
+const BGPDefaultVrf string = "default"
@@ -54,6 +54,10 @@ type RouteMgrIntf interface {
 	UpdateRoute(cfg *RouteConfig, op string)
 	ApplyPolicy(applyList []*ApplyPolicyInfo, undoList []*ApplyPolicyInfo)
 	GetRoutes() ([]*RouteInfo, []*RouteInfo)
+	CreateVrfLabel(vrf string, label uint32)
+	DeleteVrfLabel(vrf string, label uint32)
+	CreateLocalLabel(label uint32, cfg *RouteConfig)
+	DeleteLocalLabel(label uint32)
 }
 
 /*  Interface for handling policy related operations
@@ -70,6 +74,16 @@ type BfdMgrIntf interface {
 	DeleteBfdSession(ipAddr string, iface string) (bool, error)
 }
 
+/*  Interface for exchanging the EVPN VTEPs and MACs with the VXLAN daemon
+ */
+type EVPNMgrIntf interface {
+	Start()
+	CreateRemoteVtep(vni uint32, vtepIp string)
+	DeleteRemoteVtep(vni uint32, vtepIp string)
+	CreateRemoteMac(vni uint32, mac string, ip string, vtepIp string)
+	DeleteRemoteMac(vni uint32, mac string, ip string, vtepIp string)
+}
+
 type ModelRouteIntf interface {
 	GetModelObject() objects.ConfigObj
 	GetThriftObject() interface{}
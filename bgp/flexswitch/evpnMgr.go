@@ -0,0 +1,206 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package FSMgr
+
+import (
+	"encoding/json"
+	"l3/bgp/api"
+	"l3/bgp/config"
+	"l3/tunnel/vxlan/vxlanCommonDefs"
+	"utils/logging"
+
+	nanomsg "github.com/op/go-nanomsg"
+)
+
+/*  Init EVPN manager, the VTEPs and MACs are exchanged with vxland over nano msg
+ */
+func NewFSEVPNMgr(logger *logging.Writer) *FSEVPNMgr {
+	mgr := &FSEVPNMgr{
+		plugin: "ovsdb",
+		logger: logger,
+	}
+
+	return mgr
+}
+
+/*  Start nano msg sockets with vxland
+ */
+func (mgr *FSEVPNMgr) Start() {
+	mgr.vxlanPubSocket, _ = mgr.setupPubSocket(vxlanCommonDefs.PUB_SOCKET_BGPD_ADDR)
+	mgr.vxlanSubSocket, _ = mgr.setupSubSocket(vxlanCommonDefs.PUB_SOCKET_ADDR)
+	if mgr.vxlanSubSocket != nil {
+		go mgr.listenForVXLANUpdates()
+	}
+}
+
+func (mgr *FSEVPNMgr) setupPubSocket(address string) (*nanomsg.PubSocket, error) {
+	var err error
+	var socket *nanomsg.PubSocket
+	if socket, err = nanomsg.NewPubSocket(); err != nil {
+		mgr.logger.Errf("Failed to create publish socket %s, error:%s", address, err)
+		return nil, err
+	}
+
+	if _, err = socket.Bind(address); err != nil {
+		mgr.logger.Errf("Failed to bind publish socket %s, error:%s", address, err)
+		return nil, err
+	}
+
+	if err = socket.SetSendBuffer(1024 * 1024); err != nil {
+		mgr.logger.Errf("Failed to set the buffer size for publish socket %s, error:%s", address, err)
+		return nil, err
+	}
+	return socket, nil
+}
+
+func (mgr *FSEVPNMgr) setupSubSocket(address string) (*nanomsg.SubSocket, error) {
+	var err error
+	var socket *nanomsg.SubSocket
+	if socket, err = nanomsg.NewSubSocket(); err != nil {
+		mgr.logger.Errf("Failed to create subscribe socket %s, error:%s", address, err)
+		return nil, err
+	}
+
+	if err = socket.Subscribe(""); err != nil {
+		mgr.logger.Errf("Failed to subscribe to \"\" on subscribe socket %s, error:%s", address, err)
+		return nil, err
+	}
+
+	if _, err = socket.Connect(address); err != nil {
+		mgr.logger.Errf("Failed to connect to publisher socket %s, error:%s", address, err)
+		return nil, err
+	}
+
+	mgr.logger.Infof("Connected to publisher socket %s", address)
+	if err = socket.SetRecvBuffer(1024 * 1024); err != nil {
+		mgr.logger.Err("Failed to set the buffer size for subsriber socket %s, error:", address, err)
+		return nil, err
+	}
+	return socket, nil
+}
+
+/*  Listen for the local VTEPs and MACs from vxland
+ */
+func (mgr *FSEVPNMgr) listenForVXLANUpdates() {
+	for {
+		mgr.logger.Info("Read on VXLAN subscriber socket...")
+		rxBuf, err := mgr.vxlanSubSocket.Recv(0)
+		if err != nil {
+			mgr.logger.Err("Recv on VXLAN subscriber socket failed with error:", err)
+			continue
+		}
+		mgr.logger.Info("VXLAN subscriber recv returned:", rxBuf)
+		mgr.handleVXLANUpdates(rxBuf)
+	}
+}
+
+func (mgr *FSEVPNMgr) handleVXLANUpdates(rxBuf []byte) {
+	var msg vxlanCommonDefs.VxlanNotifyMsg
+	err := json.Unmarshal(rxBuf, &msg)
+	if err != nil {
+		mgr.logger.Errf("Unmarshal VXLAN notification failed with err %s", err)
+		return
+	}
+
+	switch msg.MsgType {
+	case vxlanCommonDefs.NOTIFY_LOCAL_VTEP_CREATED, vxlanCommonDefs.NOTIFY_LOCAL_VTEP_DELETED:
+		var vtep vxlanCommonDefs.VtepNotifyMsgInfo
+		if err = json.Unmarshal(msg.MsgBuf, &vtep); err != nil {
+			mgr.logger.Errf("Unmarshal VXLAN VTEP notification failed with err %s", err)
+			return
+		}
+
+		oper := config.EVPN_VTEP_CREATED
+		if msg.MsgType == vxlanCommonDefs.NOTIFY_LOCAL_VTEP_DELETED {
+			oper = config.EVPN_VTEP_DELETED
+		}
+		api.SendEVPNVtepNotification(vtep.Vni, vtep.VtepIp, oper)
+
+	case vxlanCommonDefs.NOTIFY_LOCAL_MAC_LEARNED, vxlanCommonDefs.NOTIFY_LOCAL_MAC_DELETED:
+		var mac vxlanCommonDefs.MacNotifyMsgInfo
+		if err = json.Unmarshal(msg.MsgBuf, &mac); err != nil {
+			mgr.logger.Errf("Unmarshal VXLAN MAC notification failed with err %s", err)
+			return
+		}
+
+		oper := config.EVPN_MAC_CREATED
+		if msg.MsgType == vxlanCommonDefs.NOTIFY_LOCAL_MAC_DELETED {
+			oper = config.EVPN_MAC_DELETED
+		}
+		api.SendEVPNMacNotification(mac.Vni, mac.Mac, mac.Ip, mac.VtepIp, oper)
+	}
+}
+
+func (mgr *FSEVPNMgr) publish(msgType uint16, msgInfo interface{}) {
+	if mgr.vxlanPubSocket == nil {
+		return
+	}
+
+	msgBuf, err := json.Marshal(msgInfo)
+	if err != nil {
+		mgr.logger.Errf("Marshal VXLAN msg %v failed with err %s", msgInfo, err)
+		return
+	}
+	buf, err := json.Marshal(vxlanCommonDefs.VxlanNotifyMsg{MsgType: msgType, MsgBuf: msgBuf})
+	if err != nil {
+		mgr.logger.Errf("Marshal VXLAN notification %d failed with err %s", msgType, err)
+		return
+	}
+	mgr.logger.Info("Publish VXLAN notification", msgType, msgInfo)
+	if _, err = mgr.vxlanPubSocket.Send(buf, nanomsg.DontWait); err != nil {
+		mgr.logger.Errf("Publish VXLAN notification %d failed with err %s", msgType, err)
+	}
+}
+
+func (mgr *FSEVPNMgr) CreateRemoteVtep(vni uint32, vtepIp string) {
+	mgr.publish(vxlanCommonDefs.NOTIFY_REMOTE_VTEP_CREATED, vxlanCommonDefs.VtepNotifyMsgInfo{
+		Vni:    vni,
+		VtepIp: vtepIp,
+	})
+}
+
+func (mgr *FSEVPNMgr) DeleteRemoteVtep(vni uint32, vtepIp string) {
+	mgr.publish(vxlanCommonDefs.NOTIFY_REMOTE_VTEP_DELETED, vxlanCommonDefs.VtepNotifyMsgInfo{
+		Vni:    vni,
+		VtepIp: vtepIp,
+	})
+}
+
+func (mgr *FSEVPNMgr) CreateRemoteMac(vni uint32, mac string, ip string, vtepIp string) {
+	mgr.publish(vxlanCommonDefs.NOTIFY_REMOTE_MAC_CREATED, vxlanCommonDefs.MacNotifyMsgInfo{
+		Vni:    vni,
+		Mac:    mac,
+		Ip:     ip,
+		VtepIp: vtepIp,
+	})
+}
+
+func (mgr *FSEVPNMgr) DeleteRemoteMac(vni uint32, mac string, ip string, vtepIp string) {
+	mgr.publish(vxlanCommonDefs.NOTIFY_REMOTE_MAC_DELETED, vxlanCommonDefs.MacNotifyMsgInfo{
+		Vni:    vni,
+		Mac:    mac,
+		Ip:     ip,
+		VtepIp: vtepIp,
+	})
+}
@@ -71,6 +71,15 @@ type FSBfdMgr struct {
 	bfdSubSocket *nanomsg.SubSocket
 }
 
+/*  EVPN manager will handle all the communication with vxlan daemon
+ */
+type FSEVPNMgr struct {
+	plugin         string
+	logger         *logging.Writer
+	vxlanSubSocket *nanomsg.SubSocket
+	vxlanPubSocket *nanomsg.PubSocket
+}
+
 func (mgr *FSIntfMgr) PortStateChange() {
 
 }
@@ -114,6 +114,7 @@ func (mgr *FSRouteMgr) listenForRIBUpdates(socket *nanomsg.SubSocket) {
 
 func (mgr *FSRouteMgr) populateConfigRoute(route *ribdInt.Routes) *config.RouteInfo {
 	rv := &config.RouteInfo{
+		Vrf:              route.Vrf,
 		IPAddr:           route.Ipaddr,
 		Mask:             route.Mask,
 		NextHopIp:        route.NextHopIp,
@@ -179,6 +180,7 @@ func (mgr *FSRouteMgr) GetNextHopInfo(ipAddr string, ifIndex int32) (*config.Nex
 
 func (mgr *FSRouteMgr) createRibdIPv4RouteCfg(cfg *config.RouteConfig, create bool) *ribd.IPv4Route {
 	rCfg := ribd.IPv4Route{
+		Vrf:           cfg.Vrf,
 		Cost:          cfg.Cost,
 		Protocol:      cfg.Protocol,
 		NetworkMask:   cfg.NetworkMask,
@@ -196,6 +198,7 @@ func (mgr *FSRouteMgr) createRibdIPv4RouteCfg(cfg *config.RouteConfig, create bo
 
 func (mgr *FSRouteMgr) createRibdIPv6RouteCfg(cfg *config.RouteConfig, create bool) *ribd.IPv6Route {
 	rCfg := ribd.IPv6Route{
+		Vrf:           cfg.Vrf,
 		Cost:          cfg.Cost,
 		Protocol:      cfg.Protocol,
 		NetworkMask:   cfg.NetworkMask,
@@ -229,6 +232,7 @@ func (mgr *FSRouteMgr) DeleteRoute(cfg *config.RouteConfig) {
 
 func (mgr *FSRouteMgr) UpdateV4Route(cfg *config.RouteConfig, nhInfo []*ribd.NextHopInfo, patch []*ribd.PatchOpInfo) {
 	rCfg := ribd.IPv4Route{
+		Vrf:           cfg.Vrf,
 		Cost:          cfg.Cost,
 		Protocol:      cfg.Protocol,
 		NetworkMask:   cfg.NetworkMask,
@@ -241,6 +245,7 @@ func (mgr *FSRouteMgr) UpdateV4Route(cfg *config.RouteConfig, nhInfo []*ribd.Nex
 
 func (mgr *FSRouteMgr) UpdateV6Route(cfg *config.RouteConfig, nhInfo []*ribd.NextHopInfo, patch []*ribd.PatchOpInfo) {
 	rCfg := ribd.IPv6Route{
+		Vrf:           cfg.Vrf,
 		Cost:          cfg.Cost,
 		Protocol:      cfg.Protocol,
 		NetworkMask:   cfg.NetworkMask,
@@ -350,3 +355,27 @@ func (mgr *FSRouteMgr) GetRoutes() ([]*config.RouteInfo, []*config.RouteInfo) {
 
 	return routes, (make([]*config.RouteInfo, 0))
 }
+
+// CreateVrfLabel is called when a label is allocated for the routes exported from the VRF. ribd does not
+// program the MPLS labels yet, the label is only logged.
+func (mgr *FSRouteMgr) CreateVrfLabel(vrf string, label uint32) {
+	mgr.logger.Infof("RouteMgr:CreateVrfLabel - label %d allocated for VRF %s", label, vrf)
+}
+
+// DeleteVrfLabel is called when the label allocated for the routes exported from the VRF is released.
+func (mgr *FSRouteMgr) DeleteVrfLabel(vrf string, label uint32) {
+	mgr.logger.Infof("RouteMgr:DeleteVrfLabel - label %d released for VRF %s", label, vrf)
+}
+
+// CreateLocalLabel is called when a local label is bound to a labeled unicast route. The label is swapped with
+// the labels of the route, or popped if the route has no labels. ribd does not program the MPLS labels yet, the
+// label is only logged.
+func (mgr *FSRouteMgr) CreateLocalLabel(label uint32, cfg *config.RouteConfig) {
+	mgr.logger.Infof("RouteMgr:CreateLocalLabel - label %d bound to route %s/%s, next hop %s, labels %v", label,
+		cfg.DestinationNw, cfg.NetworkMask, cfg.NextHopIp, cfg.Labels)
+}
+
+// DeleteLocalLabel is called when the local label of a labeled unicast route is released.
+func (mgr *FSRouteMgr) DeleteLocalLabel(label uint32) {
+	mgr.logger.Infof("RouteMgr:DeleteLocalLabel - label %d released", label)
+}
@@ -146,6 +146,17 @@ func (o *OutTCPConn) Connect(seconds uint32, remote, local string, connCh chan n
 		return
 	}
 
+	if vrf := o.fsm.gConf.Vrf; vrf != "" && vrf != config.BGPDefaultVrf {
+		o.logger.Info("Neighbor:", o.fsm.pConf.NeighborAddress, "FSM", o.fsm.id, "Bind the socket to VRF", vrf)
+		err = utils.BindToVrf(socket, vrf)
+		if err != nil {
+			o.logger.Err("Neighbor:", o.fsm.pConf.NeighborAddress, "FSM", o.fsm.id,
+				"Bind the socket to VRF", vrf, "failed with error", err)
+			errCh <- err
+			return
+		}
+	}
+
 	if o.fsm.pConf.AuthPassword != "" {
 		o.logger.Info("Neighbor:", o.fsm.pConf.NeighborAddress, "FSM", o.fsm.id, "Set MD5 option on the socket:",
 			socket, "password:", o.fsm.pConf.AuthPassword)
@@ -336,6 +347,23 @@ func (p *PeerConn) readPartialPkt(length int) ([]byte, error) {
 	return buf, err
 }
 
+// recordMessage passes the received message to the recorder of the FSM manager, if any.
+func (p *PeerConn) recordMessage(headerBuf []byte, buf []byte) {
+	recorder := p.fsm.Manager.msgRecorder
+	if recorder == nil {
+		return
+	}
+
+	var localIP net.IP
+	if p.conn != nil {
+		if addr, ok := (*p.conn).LocalAddr().(*net.TCPAddr); ok {
+			localIP = addr.IP
+		}
+	}
+	recorder.RecordMessage(p.peerAttrs.ASSize == 4, p.fsm.pConf.PeerAS, p.fsm.pConf.LocalAS,
+		p.fsm.pConf.NeighborAddress, localIP, headerBuf, buf)
+}
+
 func (p *PeerConn) DecodeMessage(header *packet.BGPHeader, buf []byte) (*packet.BGPMessage, *packet.BGPMessageError,
 	bool) {
 	var msgErr *packet.BGPMessageError
@@ -357,11 +385,16 @@ func (p *PeerConn) DecodeMessage(header *packet.BGPHeader, buf []byte) (*packet.
 	} else if header.Type == packet.BGPMsgTypeOpen {
 		p.peerAttrs.ASSize = packet.GetASSize(msg.Body.(*packet.BGPOpen))
 		p.peerAttrs.AddPathFamily = packet.GetAddPathFamily(msg.Body.(*packet.BGPOpen))
-		addPathsTxFarEnd := packet.IsAddPathsTxEnabledForIPv4(p.peerAttrs.AddPathFamily)
-		p.logger.Info("Neighbor:", p.fsm.pConf.NeighborAddress, "Far end can send add paths")
-		if addPathsTxFarEnd && p.fsm.pConf.AddPathsRx {
-			p.peerAttrs.AddPathsRxActual = true
-			p.logger.Info("Neighbor:", p.fsm.pConf.NeighborAddress, "negotiated to recieve add paths from far end")
+		p.peerAttrs.AddPathsRxFamilies = make(map[uint32]bool)
+		for afi, safiMap := range p.peerAttrs.AddPathFamily {
+			for safi, flags := range safiMap {
+				protoFamily := packet.GetProtocolFamily(afi, safi)
+				if flags&packet.BGPCapAddPathTx != 0 && p.fsm.neighborConf.GetAddPathsConfig(protoFamily).Rx {
+					p.peerAttrs.AddPathsRxFamilies[protoFamily] = true
+					p.logger.Info("Neighbor:", p.fsm.pConf.NeighborAddress,
+						"negotiated to recieve add paths from far end for afi", afi, "safi", safi)
+				}
+			}
 		}
 	}
 
@@ -391,6 +424,7 @@ func (p *PeerConn) ReadPkt(doneCh chan bool, stopCh chan bool, exitCh chan bool)
 				}
 			}
 
+			headerBuf := buf
 			header = packet.NewBGPHeader()
 			err = header.Decode(buf)
 			if err != nil {
@@ -424,6 +458,10 @@ func (p *PeerConn) ReadPkt(doneCh chan bool, stopCh chan bool, exitCh chan bool)
 				p.logger.Infof("Neighbor:%s FSM %d Received BGP packet %x", p.fsm.pConf.NeighborAddress, p.fsm.id, buf)
 			}
 
+			if header.Type == packet.BGPMsgTypeUpdate {
+				p.recordMessage(headerBuf, buf)
+			}
+
 			msg, msgErr, msgOk := p.DecodeMessage(header, buf)
 			p.fsm.pktRxCh <- packet.NewBGPPktInfo(msg, msgErr)
 			doneCh <- msgOk
@@ -54,7 +54,7 @@ func TestDecodeMessageBGPOpen(t *testing.T) {
 	pConf := config.NeighborConfig{}
 	nConf := base.NewNeighborConf(logger, gConf, peerGroup, pConf)
 	fsmMgr := NewFSMManager(logger, nConf, make(chan *packet.BGPPktSrc), make(chan PeerFSMConn),
-		make(chan config.ReachabilityInfo))
+		make(chan PeerGRTimerExp), make(chan config.ReachabilityInfo))
 	stateMachine := NewFSM(fsmMgr, 0, nConf)
 	peerConn := NewPeerConn(stateMachine, config.ConnDirOut, nil, 1)
 
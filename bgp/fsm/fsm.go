@@ -84,6 +84,7 @@ const (
 	BGPEventKeepAliveMsg
 	BGPEventUpdateMsg
 	BGPEventUpdateMsgErr
+	BGPEventRouteRefresh
 )
 
 var BGPEventTypeToStr = map[BGPFSMEvent]string{
@@ -115,6 +116,7 @@ var BGPEventTypeToStr = map[BGPFSMEvent]string{
 	BGPEventKeepAliveMsg:                    "KeepAliveMsg",
 	BGPEventUpdateMsg:                       "UpdateMsg",
 	BGPEventUpdateMsgErr:                    "UpdateMsgErr",
+	BGPEventRouteRefresh:                    "RouteRefresh",
 }
 
 type BaseStateIface interface {
@@ -287,7 +289,7 @@ func (st *ConnectState) processEvent(event BGPFSMEvent, data interface{}) {
 
 	case BGPEventAutoStop, BGPEventHoldTimerExp, BGPEventKeepAliveTimerExp, BGPEventIdleHoldTimerExp,
 		BGPEventBGPOpen, BGPEventOpenCollisionDump, BGPEventNotifMsg, BGPEventKeepAliveMsg,
-		BGPEventUpdateMsg, BGPEventUpdateMsgErr: // 8, 10, 11, 13, 19, 23, 25-28
+		BGPEventUpdateMsg, BGPEventUpdateMsgErr, BGPEventRouteRefresh: // 8, 10, 11, 13, 19, 23, 25-28
 		st.fsm.StopConnectRetryTimer()
 		st.fsm.StopConnToPeer()
 		st.fsm.IncrConnectRetryCounter()
@@ -387,7 +389,7 @@ func (st *ActiveState) processEvent(event BGPFSMEvent, data interface{}) {
 
 	case BGPEventAutoStop, BGPEventHoldTimerExp, BGPEventKeepAliveTimerExp, BGPEventIdleHoldTimerExp,
 		BGPEventBGPOpen, BGPEventOpenCollisionDump, BGPEventNotifMsg, BGPEventKeepAliveMsg,
-		BGPEventUpdateMsg, BGPEventUpdateMsgErr: // 8, 10, 11, 13, 19, 23, 25-28
+		BGPEventUpdateMsg, BGPEventUpdateMsgErr, BGPEventRouteRefresh: // 8, 10, 11, 13, 19, 23, 25-28
 		st.fsm.StopConnectRetryTimer()
 		st.fsm.ClearPeerConn()
 		st.fsm.StopConnToPeer()
@@ -498,7 +500,8 @@ func (st *OpenSentState) processEvent(event BGPFSMEvent, data interface{}) {
 
 	case BGPEventConnRetryTimerExp, BGPEventKeepAliveTimerExp, BGPEventDelayOpenTimerExp,
 		BGPEventIdleHoldTimerExp, BGPEventBGPOpenDelayOpenTimer, BGPEventNotifMsg,
-		BGPEventKeepAliveMsg, BGPEventUpdateMsg, BGPEventUpdateMsgErr: // 9, 11, 12, 13, 20, 25-28
+		BGPEventKeepAliveMsg, BGPEventUpdateMsg, BGPEventUpdateMsgErr,
+		BGPEventRouteRefresh: // 9, 11, 12, 13, 20, 25-28
 		st.fsm.SendNotificationMessage(packet.BGPFSMError, 0, nil)
 		st.fsm.StopConnectRetryTimer()
 		st.fsm.ClearPeerConn()
@@ -610,7 +613,8 @@ func (st *OpenConfirmState) processEvent(event BGPFSMEvent, data interface{}) {
 		st.fsm.ChangeState(NewEstablishedState(st.fsm))
 
 	case BGPEventConnRetryTimerExp, BGPEventDelayOpenTimerExp, BGPEventIdleHoldTimerExp,
-		BGPEventBGPOpenDelayOpenTimer, BGPEventUpdateMsg, BGPEventUpdateMsgErr: // 9, 12, 13, 20, 27, 28
+		BGPEventBGPOpenDelayOpenTimer, BGPEventUpdateMsg, BGPEventUpdateMsgErr,
+		BGPEventRouteRefresh: // 9, 12, 13, 20, 27, 28
 		st.fsm.SendNotificationMessage(packet.BGPCease, 0, nil)
 		st.fsm.StopConnectRetryTimer()
 		st.fsm.ClearPeerConn()
@@ -714,6 +718,11 @@ func (st *EstablishedState) processEvent(event BGPFSMEvent, data interface{}) {
 		bgpMsg := data.(*packet.BGPMessage)
 		st.fsm.ProcessUpdateMessage(bgpMsg)
 
+	case BGPEventRouteRefresh:
+		st.fsm.StartHoldTimer()
+		bgpMsg := data.(*packet.BGPMessage)
+		st.fsm.ProcessRouteRefreshMessage(bgpMsg)
+
 	case BGPEventUpdateMsgErr:
 		bgpMsgErr := data.(*packet.BGPMessageError)
 		st.fsm.SendNotificationMessage(bgpMsgErr.TypeCode, bgpMsgErr.SubTypeCode, bgpMsgErr.Data)
@@ -737,12 +746,21 @@ func (st *EstablishedState) processEvent(event BGPFSMEvent, data interface{}) {
 func (st *EstablishedState) enter() {
 	st.logger.Info("Neighbor:", st.fsm.pConf.NeighborAddress, "FSM:", st.fsm.id, "State: Established - enter")
 	st.fsm.SetIdleHoldTime(BGPIdleHoldTimeDefault)
+	st.fsm.peerRestarting = false
+	if st.fsm.gConf.GracefulRestart && st.fsm.peerGRCap != nil {
+		st.fsm.StartStalePathTimer()
+	}
 }
 
 func (st *EstablishedState) leave() {
 	st.logger.Info("Neighbor:", st.fsm.pConf.NeighborAddress, "FSM:", st.fsm.id, "State: Established - leave")
 	st.fsm.SetHoldTime(st.fsm.neighborConf.RunningConf.HoldTime,
 		st.fsm.neighborConf.RunningConf.KeepaliveTime)
+	st.fsm.StopStalePathTimer()
+	if st.fsm.canPeerRestartGracefully() {
+		st.fsm.peerRestarting = true
+		st.fsm.StartGRRestartTimer(st.fsm.peerGRCap.RestartTime)
+	}
 }
 
 func (st *EstablishedState) state() config.BGPFSMState {
@@ -815,6 +833,11 @@ type FSM struct {
 	restartTime  uint32
 	restartTimer *time.Timer
 
+	peerGRCap      *packet.BGPCapGracefulRestart
+	peerRestarting bool
+	grRestartTimer *time.Timer
+	stalePathTimer *time.Timer
+
 	autoStart       bool
 	autoStop        bool
 	passiveTcpEst   bool
@@ -829,11 +852,18 @@ type FSM struct {
 
 	afiSafiMap  map[uint32]bool
 	pktTxCh     chan *packet.BGPMessage
+	encodedTxCh chan [][]byte
 	pktRxCh     chan *packet.BGPPktInfo
 	eventRxCh   chan PeerFSMEvent
 	bfdStatusCh chan bool
 	rxPktsFlag  bool
 
+	// OPEN messages and the last NOTIFICATION message of the connection, reported to the BMP stations
+	sentOpenMsg  *packet.BGPMessage
+	recvOpenMsg  *packet.BGPMessage
+	notifMsg     *packet.BGPMessage
+	notifMsgSent bool
+
 	close bool
 }
 
@@ -868,6 +898,7 @@ func NewFSM(fsmManager *FSMManager, id uint8, neighborConf *base.NeighborConf) *
 	}
 
 	fsm.pktTxCh = make(chan *packet.BGPMessage)
+	fsm.encodedTxCh = make(chan [][]byte)
 	fsm.pktRxCh = make(chan *packet.BGPPktInfo, 2)
 	fsm.eventRxCh = make(chan PeerFSMEvent, 5)
 	fsm.bfdStatusCh = make(chan bool, 5)
@@ -886,6 +917,12 @@ func NewFSM(fsmManager *FSMManager, id uint8, neighborConf *base.NeighborConf) *
 	fsm.restartTimer = time.NewTimer(time.Duration(5) * time.Second)
 	fsm.restartTimer.Stop()
 
+	fsm.grRestartTimer = time.NewTimer(time.Duration(config.BGPGRRestartTimeDefault) * time.Second)
+	fsm.grRestartTimer.Stop()
+
+	fsm.stalePathTimer = time.NewTimer(time.Duration(config.BGPGRStalePathTimeDefault) * time.Second)
+	fsm.stalePathTimer.Stop()
+
 	return &fsm
 }
 
@@ -951,10 +988,24 @@ func (fsm *FSM) StartFSM() {
 		case bgpMsg := <-fsm.pktTxCh:
 			if fsm.State.state() != config.BGPFSMEstablished {
 				fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
-					"is not in Established state, can't send the UPDATE message")
+					"is not in Established state, can't send the message type", bgpMsg.Header.Type)
+				continue
+			}
+			if bgpMsg.Header.Type == packet.BGPMsgTypeRouteRefresh {
+				fsm.sendRouteRefreshMessage(bgpMsg)
+			} else if _, ok := packet.GetEndOfRIBProtoFamily(bgpMsg); ok {
+				fsm.sendEndOfRIBMessage(bgpMsg)
+			} else {
+				fsm.sendUpdateMessage(bgpMsg)
+			}
+
+		case pkts := <-fsm.encodedTxCh:
+			if fsm.State.state() != config.BGPFSMEstablished {
+				fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
+					"is not in Established state, can't send the encoded update message")
 				continue
 			}
-			fsm.sendUpdateMessage(bgpMsg)
+			fsm.sendUpdatePkts(pkts)
 
 		case bgpPktInfo := <-fsm.pktRxCh:
 			fsm.ProcessPacket(bgpPktInfo.Msg, bgpPktInfo.MsgError)
@@ -991,6 +1042,14 @@ func (fsm *FSM) StartFSM() {
 
 		case <-fsm.restartTimer.C:
 			fsm.sendAutoStartEvent()
+
+		case <-fsm.grRestartTimer.C:
+			fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "Graceful restart timer expired")
+			fsm.Manager.grTimerExpired(fsm.id, GRTimerRestart)
+
+		case <-fsm.stalePathTimer.C:
+			fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "Stale path timer expired")
+			fsm.Manager.grTimerExpired(fsm.id, GRTimerStalePath)
 		}
 	}
 }
@@ -1015,18 +1074,39 @@ func (fsm *FSM) ProcessPacket(msg *packet.BGPMessage, msgErr *packet.BGPMessageE
 
 		case packet.BGPUpdateMsgError:
 			event = BGPEventUpdateMsgErr
+
+		case packet.BGPRouteRefreshMsgError:
+			// A malformed ROUTE-REFRESH is handled like a malformed UPDATE,
+			// send a notification and reset the session
+			event = BGPEventUpdateMsgErr
 		}
 	} else {
 		data = msg
 		switch msg.Header.Type {
 		case packet.BGPMsgTypeOpen:
 			event = BGPEventBGPOpen
+			peerAS := packet.GetPeerAS(msg.Body.(*packet.BGPOpen))
+			if fsm.neighborConf.IsDynamic() && !fsm.neighborConf.IsDynamicPeerASAllowed(peerAS) {
+				fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "Dynamic peer AS", peerAS,
+					"is not allowed")
+				event = BGPEventOpenMsgErr
+				data = &packet.BGPMessageError{
+					TypeCode:    packet.BGPOpenMsgError,
+					SubTypeCode: packet.BGPBadPeerAS,
+					Message:     "Bad peer AS",
+				}
+			}
 
 		case packet.BGPMsgTypeUpdate:
 			event = BGPEventUpdateMsg
 
+		case packet.BGPMsgTypeRouteRefresh:
+			event = BGPEventRouteRefresh
+
 		case packet.BGPMsgTypeNotification:
 			fsm.neighborConf.Neighbor.State.Messages.Received.Notification++
+			fsm.notifMsg = msg
+			fsm.notifMsgSent = false
 			event = BGPEventNotifMsg
 			notifyMsg := msg.Body.(*packet.BGPNotification)
 			fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "Received notification message:",
@@ -1057,6 +1137,8 @@ func (fsm *FSM) ChangeState(newState BaseStateIface) {
 		fsm.ConnBroken()
 	} else if oldState != config.BGPFSMEstablished && fsm.State.state() == config.BGPFSMEstablished {
 		fsm.ConnEstablished()
+	} else if oldState != config.BGPFSMIdle && fsm.State.state() == config.BGPFSMIdle {
+		fsm.Manager.fsmIdle(fsm.id)
 	}
 	fsm.Manager.fsmStateChange(fsm.id, fsm.State.state())
 }
@@ -1090,6 +1172,37 @@ func (fsm *FSM) StopRestartTimer() {
 	fsm.restartTimer.Stop()
 }
 
+// canPeerRestartGracefully returns true if the routes of the peer can be retained when the session goes down.
+// Only the loss of the TCP connection is handled gracefully, the session is reset normally when a
+// NOTIFICATION message is sent or received.
+func (fsm *FSM) canPeerRestartGracefully() bool {
+	return fsm.gConf.GracefulRestart && fsm.peerGRCap != nil && fsm.event == BGPEventTcpConnFails
+}
+
+func (fsm *FSM) StartGRRestartTimer(seconds uint16) {
+	fsm.StopGRRestartTimer()
+	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "Start graceful restart timer for",
+		seconds, "seconds")
+	fsm.grRestartTimer.Reset(time.Duration(seconds) * time.Second)
+}
+
+func (fsm *FSM) StopGRRestartTimer() {
+	fsm.grRestartTimer.Stop()
+}
+
+func (fsm *FSM) StartStalePathTimer() {
+	fsm.StopStalePathTimer()
+	stalePathTime := fsm.gConf.StalePathTime
+	if stalePathTime == 0 {
+		stalePathTime = config.BGPGRStalePathTimeDefault
+	}
+	fsm.stalePathTimer.Reset(time.Duration(stalePathTime) * time.Second)
+}
+
+func (fsm *FSM) StopStalePathTimer() {
+	fsm.stalePathTimer.Stop()
+}
+
 func (fsm *FSM) SetPassiveTcpEstablishment(flag bool) {
 	fsm.passiveTcpEst = flag
 }
@@ -1173,11 +1286,14 @@ func (fsm *FSM) HandleAnotherConnection(data interface{}) {
 
 func (fsm *FSM) ProcessOpenMessage(pkt *packet.BGPMessage) bool {
 	body := pkt.Body.(*packet.BGPOpen)
+	fsm.recvOpenMsg = pkt
 	if uint32(body.HoldTime) < fsm.holdTime {
 		fsm.SetHoldTime(uint32(body.HoldTime), uint32(body.HoldTime/3))
 	}
 	if body.MyAS == fsm.Manager.gConf.AS {
 		fsm.peerType = config.PeerTypeInternal
+	} else if fsm.neighborConf.IsConfedExternal() {
+		fsm.peerType = config.PeerTypeConfedExternal
 	} else {
 		fsm.peerType = config.PeerTypeExternal
 	}
@@ -1189,6 +1305,11 @@ func (fsm *FSM) ProcessOpenMessage(pkt *packet.BGPMessage) bool {
 		}
 	}
 
+	fsm.peerGRCap = packet.GetGracefulRestartCap(body)
+	if fsm.peerGRCap != nil && fsm.peerGRCap.IsRestarting() {
+		fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "Peer is restarting gracefully")
+	}
+
 	return fsm.Manager.receivedBGPOpenMessage(fsm.id, fsm.peerConn.dir, body)
 }
 
@@ -1211,10 +1332,62 @@ func (fsm *FSM) ProcessUpdateMessage(pkt *packet.BGPMessage) {
 	}()
 }
 
+func (fsm *FSM) ProcessRouteRefreshMessage(pkt *packet.BGPMessage) {
+	routeRefresh := pkt.Body.(*packet.BGPRouteRefresh)
+	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
+		"ProcessRouteRefreshMessage: AFI", routeRefresh.AFI, "SAFI", routeRefresh.SAFI, "subtype", routeRefresh.SubType)
+	if !fsm.neighborConf.Neighbor.State.RouteRefresh {
+		fsm.logger.Warning("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
+			"Received ROUTE-REFRESH message but the capability was not advertised by the peer")
+	}
+	go func() {
+		fsm.Manager.bgpPktSrcCh <- packet.NewBGPPktSrc(fsm.Manager.neighborConf.Neighbor.NeighborAddress.String(), pkt)
+	}()
+}
+
+func (fsm *FSM) sendRouteRefreshMessage(bgpMsg *packet.BGPMessage) {
+	packet, err := bgpMsg.Encode()
+	if err != nil {
+		fsm.logger.Errf("Neighbor:%s FSM %d Failed to encode ROUTE-REFRESH packet", fsm.pConf.NeighborAddress, fsm.id)
+		return
+	}
+	fsm.logger.Infof("Neighbor:%s FSM %d Tx BGP ROUTE-REFRESH %x", fsm.pConf.NeighborAddress, fsm.id, packet)
+
+	num, err := (*fsm.peerConn.conn).Write(packet)
+	if err != nil {
+		fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
+			"Conn.Write failed to send Route Refresh message with error:", err)
+		return
+	}
+	fsm.StartKeepAliveTimer()
+	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
+		"Conn.Write succeeded. sent Route Refresh message of", num, "bytes")
+}
+
+func (fsm *FSM) sendEndOfRIBMessage(bgpMsg *packet.BGPMessage) {
+	packet, err := bgpMsg.Encode()
+	if err != nil {
+		fsm.logger.Errf("Neighbor:%s FSM %d Failed to encode End-of-RIB packet", fsm.pConf.NeighborAddress, fsm.id)
+		return
+	}
+	fsm.logger.Infof("Neighbor:%s FSM %d Tx BGP End-of-RIB %x", fsm.pConf.NeighborAddress, fsm.id, packet)
+
+	num, err := (*fsm.peerConn.conn).Write(packet)
+	if err != nil {
+		fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
+			"Conn.Write failed to send End-of-RIB message with error:", err)
+		return
+	}
+	fsm.StartKeepAliveTimer()
+	fsm.neighborConf.Neighbor.State.Messages.Sent.Update++
+	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
+		"Conn.Write succeeded. sent End-of-RIB message of", num, "bytes")
+}
+
 func (fsm *FSM) sendUpdateMessage(bgpMsg *packet.BGPMessage) {
 	fsm.logger.Infof("Neighbor:%s FSM %d Send BGP update message %+v", fsm.pConf.NeighborAddress, fsm.id, bgpMsg)
 	updateMsgs := packet.ConstructMaxSizedUpdatePackets(bgpMsg)
-	atomic.AddUint32(&fsm.neighborConf.Neighbor.State.Queues.Output, ^uint32(0))
+	pkts := make([][]byte, 0, len(updateMsgs))
 
 	for idx, _ := range updateMsgs {
 		packet, err := updateMsgs[idx].Encode()
@@ -1222,6 +1395,17 @@ func (fsm *FSM) sendUpdateMessage(bgpMsg *packet.BGPMessage) {
 			fsm.logger.Errf("Neighbor:%s FSM %d Failed to encode packet", fsm.pConf.NeighborAddress, fsm.id)
 			continue
 		}
+		pkts = append(pkts, packet)
+	}
+	fsm.sendUpdatePkts(pkts)
+}
+
+// sendUpdatePkts writes the encoded update messages to the peer connection. The update groups encode the
+// messages once and send the same packets to all the members.
+func (fsm *FSM) sendUpdatePkts(pkts [][]byte) {
+	atomic.AddUint32(&fsm.neighborConf.Neighbor.State.Queues.Output, ^uint32(0))
+
+	for _, packet := range pkts {
 		fsm.logger.Infof("Neighbor:%s FSM %d Tx BGP UPDATE %x", fsm.pConf.NeighborAddress, fsm.id, packet)
 
 		num, err := (*fsm.peerConn.conn).Write(packet)
@@ -1240,9 +1424,22 @@ func (fsm *FSM) sendUpdateMessage(bgpMsg *packet.BGPMessage) {
 func (fsm *FSM) sendOpenMessage() {
 	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
 		"sendOpenMessage: send address family", fsm.neighborConf.AfiSafiMap)
-	optParams := packet.ConstructOptParams(uint32(fsm.pConf.LocalAS), fsm.neighborConf.AfiSafiMap,
-		fsm.neighborConf.RunningConf.AddPathsRx, fsm.neighborConf.RunningConf.AddPathsMaxTx)
-	bgpOpenMsg := packet.NewBGPOpenMessage(fsm.pConf.LocalAS, uint16(fsm.holdTime), fsm.gConf.RouterId.To4().String(), optParams)
+	var grCap *packet.BGPCapGracefulRestart
+	if fsm.gConf.GracefulRestart {
+		restartTime := fsm.gConf.RestartTime
+		if restartTime == 0 {
+			restartTime = config.BGPGRRestartTimeDefault
+		}
+		// The routes installed in RIBd are not retained across a restart of BGP, so the forwarding state is
+		// not preserved and the F bit is not set. The peers then don't keep the routes they learnt from this
+		// speaker while it restarts.
+		grCap = packet.ConstructGracefulRestartCap(fsm.neighborConf.LocalRestarting, restartTime,
+			fsm.neighborConf.AfiSafiMap, false)
+	}
+	optParams := packet.ConstructOptParams(fsm.neighborConf.GetAdvertisedAS(), fsm.neighborConf.AfiSafiMap,
+		fsm.neighborConf.GetAddPathsFlags(), grCap)
+	bgpOpenMsg := packet.NewBGPOpenMessage(fsm.neighborConf.GetAdvertisedAS(), uint16(fsm.holdTime), fsm.gConf.RouterId.To4().String(), optParams)
+	fsm.sentOpenMsg = bgpOpenMsg
 	packet, _ := bgpOpenMsg.Encode()
 	num, err := (*fsm.peerConn.conn).Write(packet)
 	if err != nil {
@@ -1275,6 +1472,8 @@ func (fsm *FSM) SendNotificationMessage(code uint8, subCode uint8, data []byte)
 		return
 	}
 	fsm.neighborConf.Neighbor.State.Messages.Sent.Notification++
+	fsm.notifMsg = bgpNotifMsg
+	fsm.notifMsgSent = true
 	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id,
 		"Conn.Write succeeded. sent Notification message with", num, "bytes")
 }
@@ -1294,6 +1493,10 @@ func (fsm *FSM) SetPeerConn(data interface{}) {
 		fsm.connId = r.Uint32()
 	}
 
+	fsm.sentOpenMsg = nil
+	fsm.recvOpenMsg = nil
+	fsm.notifMsg = nil
+	fsm.notifMsgSent = false
 	pConnDir := data.(PeerConnDir)
 	fsm.peerConn = NewPeerConn(fsm, pConnDir.connDir, pConnDir.conn, fsm.connId)
 	go fsm.peerConn.StartReading()
@@ -1319,13 +1522,13 @@ func (fsm *FSM) ClearPeerConn() {
 
 func (fsm *FSM) ConnEstablished() {
 	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "ConnEstablished - start")
-	fsm.Manager.fsmEstablished(fsm.id, fsm.peerConn.conn)
+	fsm.Manager.fsmEstablished(fsm.id, fsm.peerConn.conn, fsm.sentOpenMsg, fsm.recvOpenMsg)
 	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "ConnEstablished - end")
 }
 
 func (fsm *FSM) ConnBroken() {
 	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "ConnBroken - start")
-	fsm.Manager.fsmBroken(fsm.id, false)
+	fsm.Manager.fsmBroken(fsm.id, false, fsm.peerRestarting, fsm.notifMsg, fsm.notifMsgSent)
 	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "ConnBroken - end")
 }
 
@@ -1345,6 +1548,10 @@ func (fsm *FSM) InitiateConnToPeer() {
 		fsm.logger.Info("Unknown neighbor address")
 		return
 	}
+	if fsm.neighborConf.IsDynamic() {
+		fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "Dynamic neighbor is passive")
+		return
+	}
 	ip := fsm.pConf.NeighborAddress.String()
 	fsm.logger.Info("Neighbor:", fsm.pConf.NeighborAddress, "FSM", fsm.id, "InitiateConnToPeer islinklocal",
 		fsm.pConf.NeighborAddress.IsLinkLocalUnicast(), "ifName:", fsm.pConf.IfName)
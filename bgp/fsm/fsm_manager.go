@@ -35,9 +35,26 @@ import (
 )
 
 type PeerFSMConn struct {
-	PeerIP      string
-	Established bool
-	Conn        *net.Conn
+	PeerIP          string
+	Established     bool
+	Conn            *net.Conn
+	GracefulRestart bool
+	SentOpenMsg     *packet.BGPMessage
+	RecvOpenMsg     *packet.BGPMessage
+	NotifMsg        *packet.BGPMessage
+	NotifMsgSent    bool
+}
+
+type GRTimer int
+
+const (
+	GRTimerRestart GRTimer = iota
+	GRTimerStalePath
+)
+
+type PeerGRTimerExp struct {
+	PeerIP string
+	Timer  GRTimer
 }
 
 type PeerFSMState struct {
@@ -64,12 +81,19 @@ type PeerFSMCommand struct {
 	Reason  int
 }
 
+// MsgRecorder records the BGP messages received from the peer.
+type MsgRecorder interface {
+	RecordMessage(as4 bool, peerAS uint32, localAS uint32, peerIP net.IP, localIP net.IP, header []byte,
+		body []byte)
+}
+
 type FSMManager struct {
 	logger         *logging.Writer
 	neighborConf   *base.NeighborConf
 	gConf          *config.GlobalConfig
 	pConf          *config.NeighborConfig
 	fsmConnCh      chan PeerFSMConn
+	grTimerCh      chan PeerGRTimerExp
 	fsmStateCh     chan PeerFSMState
 	peerAttrsCh    chan PeerAttrs
 	bgpPktSrcCh    chan *packet.BGPPktSrc
@@ -85,16 +109,20 @@ type FSMManager struct {
 	activeFSM      uint8
 	newConnCh      chan PeerFSMConnState
 	fsmMutex       sync.RWMutex
+	msgRecorder    MsgRecorder
+	pendingConn    net.Conn
+	acceptReadyCh  chan bool
 }
 
 func NewFSMManager(logger *logging.Writer, neighborConf *base.NeighborConf, bgpPktSrcCh chan *packet.BGPPktSrc,
-	fsmConnCh chan PeerFSMConn, reachabilityCh chan config.ReachabilityInfo) *FSMManager {
+	fsmConnCh chan PeerFSMConn, grTimerCh chan PeerGRTimerExp, reachabilityCh chan config.ReachabilityInfo) *FSMManager {
 	mgr := FSMManager{
 		logger:         logger,
 		neighborConf:   neighborConf,
 		gConf:          neighborConf.Global,
 		pConf:          &neighborConf.RunningConf,
 		fsmConnCh:      fsmConnCh,
+		grTimerCh:      grTimerCh,
 		bgpPktSrcCh:    bgpPktSrcCh,
 		reachabilityCh: reachabilityCh,
 	}
@@ -108,10 +136,15 @@ func NewFSMManager(logger *logging.Writer, neighborConf *base.NeighborConf, bgpP
 	mgr.BfdStatusCh = make(chan bool, 4)
 	mgr.activeFSM = uint8(config.ConnDirInvalid)
 	mgr.newConnCh = make(chan PeerFSMConnState, 2)
+	mgr.acceptReadyCh = make(chan bool, 1)
 	mgr.fsmMutex = sync.RWMutex{}
 	return &mgr
 }
 
+func (mgr *FSMManager) SetMsgRecorder(recorder MsgRecorder) {
+	mgr.msgRecorder = recorder
+}
+
 func (mgr *FSMManager) Init() {
 	fsmId := uint8(config.ConnDirOut)
 	fsm := NewFSM(mgr, fsmId, mgr.neighborConf)
@@ -125,28 +158,23 @@ func (mgr *FSMManager) Init() {
 		case inConn := <-mgr.AcceptCh:
 			mgr.logger.Infof("Neighbor %s: Received a connection OPEN from far end", mgr.pConf.NeighborAddress)
 			if !mgr.acceptConn {
-				mgr.logger.Info("Can't accept connection from ", mgr.pConf.NeighborAddress, "yet.")
-				inConn.Close()
-			} else {
-				foundInConn := false
-				for _, fsm = range mgr.fsms {
-					if fsm != nil && fsm.peerConn != nil && fsm.peerConn.dir == config.ConnDirIn {
-						mgr.logger.Info("A FSM is already created for a incoming connection")
-						foundInConn = true
-						inConn.Close()
-						break
-					}
-				}
-				if !foundInConn {
-					for fsmId, fsm = range mgr.fsms {
-						if fsm != nil {
-							mgr.logger.Infof("Neighbor %s: Send inConn message to FSM %d", mgr.pConf.NeighborAddress,
-								fsmId)
-							fsm.inConnCh <- inConn
-							break
-						}
-					}
+				if mgr.neighborConf.IsDynamic() && mgr.pendingConn == nil {
+					// The dynamic neighbor is created by the connection, hold it till the FSM is started
+					mgr.logger.Info("Hold connection from", mgr.pConf.NeighborAddress, "till the FSM is started")
+					mgr.pendingConn = inConn
+				} else {
+					mgr.logger.Info("Can't accept connection from ", mgr.pConf.NeighborAddress, "yet.")
+					inConn.Close()
 				}
+			} else {
+				mgr.acceptInConn(inConn)
+			}
+
+		case <-mgr.acceptReadyCh:
+			if mgr.pendingConn != nil && mgr.acceptConn {
+				mgr.logger.Infof("Neighbor %s: Accept the held connection", mgr.pConf.NeighborAddress)
+				mgr.acceptInConn(mgr.pendingConn)
+				mgr.pendingConn = nil
 			}
 
 		case fsmId := <-mgr.tcpConnFailCh:
@@ -199,8 +227,32 @@ func (mgr *FSMManager) handleBfdStatusChange(status bool) {
 	}
 }
 
+func (mgr *FSMManager) acceptInConn(inConn net.Conn) {
+	for _, fsm := range mgr.fsms {
+		if fsm != nil && fsm.peerConn != nil && fsm.peerConn.dir == config.ConnDirIn {
+			mgr.logger.Info("A FSM is already created for a incoming connection")
+			inConn.Close()
+			return
+		}
+	}
+
+	for fsmId, fsm := range mgr.fsms {
+		if fsm != nil {
+			mgr.logger.Infof("Neighbor %s: Send inConn message to FSM %d", mgr.pConf.NeighborAddress, fsmId)
+			fsm.inConnCh <- inConn
+			break
+		}
+	}
+}
+
 func (mgr *FSMManager) AcceptPeerConn() {
 	mgr.acceptConn = true
+	if mgr.neighborConf.IsDynamic() {
+		select {
+		case mgr.acceptReadyCh <- true:
+		default:
+		}
+	}
 }
 
 func (mgr *FSMManager) RejectPeerConn() {
@@ -213,15 +265,15 @@ func (mgr *FSMManager) fsmTcpConnFailed(id uint8) {
 
 	mgr.logger.Infof("FSMManager: Peer %s FSM %d TCP conn failed", mgr.pConf.NeighborAddress.String(), id)
 	if len(mgr.fsms) != 1 && mgr.activeFSM != id {
-		mgr.fsmClose(id)
+		mgr.fsmClose(id, false)
 	}
 }
 
-func (mgr *FSMManager) fsmClose(id uint8) {
+func (mgr *FSMManager) fsmClose(id uint8, gracefulRestart bool) {
 	if closeFSM, ok := mgr.fsms[id]; ok {
 		mgr.logger.Infof("FSMManager: Peer %s, close FSM %d", mgr.pConf.NeighborAddress.String(), id)
 		closeFSM.closeCh <- true
-		mgr.fsmBroken(id, false)
+		mgr.fsmBroken(id, false, gracefulRestart, nil, false)
 		mgr.fsms[id] = nil
 		delete(mgr.fsms, id)
 		mgr.logger.Infof("FSMManager: Peer %s, closed FSM %d", mgr.pConf.NeighborAddress.String(), id)
@@ -231,11 +283,22 @@ func (mgr *FSMManager) fsmClose(id uint8) {
 	}
 }
 
-func (mgr *FSMManager) fsmEstablished(id uint8, conn *net.Conn) {
+func (mgr *FSMManager) fsmEstablished(id uint8, conn *net.Conn, sentOpenMsg, recvOpenMsg *packet.BGPMessage) {
 	mgr.logger.Infof("FSMManager: Peer %s FSM %d connection established", mgr.pConf.NeighborAddress.String(), id)
 	if _, ok := mgr.fsms[id]; ok {
 		mgr.activeFSM = id
-		mgr.fsmConnCh <- PeerFSMConn{mgr.neighborConf.Neighbor.NeighborAddress.String(), true, conn}
+		for _, fsm := range mgr.fsms {
+			if fsm != nil {
+				fsm.StopGRRestartTimer()
+			}
+		}
+		mgr.fsmConnCh <- PeerFSMConn{
+			PeerIP:      mgr.neighborConf.Neighbor.NeighborAddress.String(),
+			Established: true,
+			Conn:        conn,
+			SentOpenMsg: sentOpenMsg,
+			RecvOpenMsg: recvOpenMsg,
+		}
 	} else {
 		mgr.logger.Infof("FSMManager: Peer %s FSM %d not found in fsms dict %v", mgr.pConf.NeighborAddress.String(),
 			id, mgr.fsms)
@@ -243,15 +306,42 @@ func (mgr *FSMManager) fsmEstablished(id uint8, conn *net.Conn) {
 	//mgr.Peer.PeerConnEstablished(conn)
 }
 
-func (mgr *FSMManager) fsmBroken(id uint8, fsmDelete bool) {
-	mgr.logger.Infof("FSMManager: Peer %s FSM %d connection broken", mgr.pConf.NeighborAddress.String(), id)
+func (mgr *FSMManager) fsmBroken(id uint8, fsmDelete bool, gracefulRestart bool, notifMsg *packet.BGPMessage,
+	notifMsgSent bool) {
+	mgr.logger.Infof("FSMManager: Peer %s FSM %d connection broken, graceful restart %t",
+		mgr.pConf.NeighborAddress.String(), id, gracefulRestart)
 	if mgr.activeFSM == id {
 		mgr.activeFSM = uint8(config.ConnDirInvalid)
-		mgr.fsmConnCh <- PeerFSMConn{mgr.neighborConf.Neighbor.NeighborAddress.String(), false, nil}
+		mgr.fsmConnCh <- PeerFSMConn{
+			PeerIP:          mgr.neighborConf.Neighbor.NeighborAddress.String(),
+			GracefulRestart: gracefulRestart,
+			NotifMsg:        notifMsg,
+			NotifMsgSent:    notifMsgSent,
+		}
 		//mgr.Peer.PeerConnBroken(fsmDelete)
 	}
 }
 
+// fsmIdle is called when a FSM goes back to Idle state before the connection is established. The server is
+// notified that the session of a dynamic neighbor went down so that the neighbor is removed.
+func (mgr *FSMManager) fsmIdle(id uint8) {
+	if !mgr.neighborConf.IsDynamic() || mgr.activeFSM != uint8(config.ConnDirInvalid) {
+		return
+	}
+
+	mgr.logger.Infof("FSMManager: Dynamic peer %s FSM %d went to Idle state", mgr.pConf.NeighborAddress.String(),
+		id)
+	mgr.fsmConnCh <- PeerFSMConn{
+		PeerIP: mgr.neighborConf.Neighbor.NeighborAddress.String(),
+	}
+}
+
+func (mgr *FSMManager) grTimerExpired(id uint8, timer GRTimer) {
+	mgr.logger.Infof("FSMManager: Peer %s FSM %d graceful restart timer %d expired",
+		mgr.pConf.NeighborAddress.String(), id, timer)
+	mgr.grTimerCh <- PeerGRTimerExp{mgr.neighborConf.Neighbor.NeighborAddress.String(), timer}
+}
+
 func (mgr *FSMManager) fsmStateChange(id uint8, state config.BGPFSMState) {
 	mgr.fsmMutex.Lock()
 	defer mgr.fsmMutex.Unlock()
@@ -273,16 +363,61 @@ func (mgr *FSMManager) SendUpdateMsg(bgpMsg *packet.BGPMessage) {
 	mgr.fsms[mgr.activeFSM].pktTxCh <- bgpMsg
 }
 
+// SendEncodedUpdateMsg sends the update message packets that are already encoded by the update group.
+func (mgr *FSMManager) SendEncodedUpdateMsg(pkts [][]byte) {
+	mgr.fsmMutex.RLock()
+	defer mgr.fsmMutex.RUnlock()
+
+	if mgr.activeFSM == uint8(config.ConnDirInvalid) {
+		mgr.logger.Infof("FSMManager: Neighbor %s FSM is not in ESTABLISHED state", mgr.pConf.NeighborAddress)
+		return
+	}
+	mgr.logger.Infof("FSMManager: Neighbor %s FSM %d - send encoded update", mgr.pConf.NeighborAddress,
+		mgr.activeFSM)
+	mgr.fsms[mgr.activeFSM].encodedTxCh <- pkts
+}
+
+func (mgr *FSMManager) SendRouteRefreshMsg(afi packet.AFI, safi packet.SAFI, subType uint8) {
+	mgr.fsmMutex.RLock()
+	defer mgr.fsmMutex.RUnlock()
+
+	if mgr.activeFSM == uint8(config.ConnDirInvalid) {
+		mgr.logger.Infof("FSMManager: Neighbor %s FSM is not in ESTABLISHED state", mgr.pConf.NeighborAddress)
+		return
+	}
+	mgr.logger.Infof("FSMManager: Neighbor %s FSM %d - send route refresh, afi %d safi %d subtype %d",
+		mgr.pConf.NeighborAddress, mgr.activeFSM, afi, safi, subType)
+	mgr.fsms[mgr.activeFSM].pktTxCh <- packet.NewBGPRouteRefreshMessage(afi, safi, subType)
+}
+
+func (mgr *FSMManager) SendEndOfRIBMsg(protoFamily uint32) {
+	mgr.fsmMutex.RLock()
+	defer mgr.fsmMutex.RUnlock()
+
+	if mgr.activeFSM == uint8(config.ConnDirInvalid) {
+		mgr.logger.Infof("FSMManager: Neighbor %s FSM is not in ESTABLISHED state", mgr.pConf.NeighborAddress)
+		return
+	}
+	mgr.logger.Infof("FSMManager: Neighbor %s FSM %d - send End-of-RIB for protocol family %d",
+		mgr.pConf.NeighborAddress, mgr.activeFSM, protoFamily)
+	mgr.fsms[mgr.activeFSM].pktTxCh <- packet.NewBGPEndOfRIBMessage(protoFamily)
+}
+
 func (mgr *FSMManager) Cleanup() {
 	mgr.fsmMutex.Lock()
 	defer mgr.fsmMutex.Unlock()
 
+	if mgr.pendingConn != nil {
+		mgr.pendingConn.Close()
+		mgr.pendingConn = nil
+	}
+
 	for id, fsm := range mgr.fsms {
 		if fsm != nil {
 			mgr.logger.Infof("FSMManager: Neighbor %s FSM %d - cleanup FSM", mgr.pConf.NeighborAddress, id)
 			fsm.closeCh <- true
 			fsm = nil
-			mgr.fsmBroken(id, true)
+			mgr.fsmBroken(id, true, false, nil, false)
 			mgr.fsmStateChange(id, config.BGPFSMIdle)
 			mgr.fsms[id] = nil
 			delete(mgr.fsms, id)
@@ -298,7 +433,7 @@ func (mgr *FSMManager) StopFSM(stopMsg string) {
 		if fsm != nil {
 			mgr.logger.Infof("FSMManager: Neighbor %s FSM %d - Stop FSM", mgr.pConf.NeighborAddress, id)
 			fsm.eventRxCh <- PeerFSMEvent{BGPEventTcpConnFails, BGPCmdReasonNone}
-			mgr.fsmBroken(id, false)
+			mgr.fsmBroken(id, false, false, nil, false)
 		}
 	}
 }
@@ -361,9 +496,18 @@ func (mgr *FSMManager) receivedBGPOpenMessage(id uint8, connDir config.ConnDir,
 
 	localBGPId := packet.ConvertIPBytesToUint(mgr.gConf.RouterId.To4())
 	bgpIdInt := packet.ConvertIPBytesToUint(openMsg.BGPId.To4())
+	grCap := packet.GetGracefulRestartCap(openMsg)
+	gracefulRestart := false
 	for fsmId, fsm := range mgr.fsms {
 		if fsmId != id && fsm != nil && fsm.State.state() >= config.BGPFSMOpensent {
-			if fsm.State.state() == config.BGPFSMEstablished {
+			if fsm.State.state() == config.BGPFSMEstablished && mgr.gConf.GracefulRestart && fsm.peerGRCap != nil &&
+				grCap != nil && fsm.peerConn != nil && fsm.peerConn.dir != connDir {
+				// The peer restarted before the session went down, close the old session and retain the
+				// routes received from the peer
+				mgr.logger.Infof("FSMManager - Neighbor %s: Peer restarted gracefully", mgr.pConf.NeighborAddress)
+				closeConnDir = fsm.peerConn.dir
+				gracefulRestart = true
+			} else if fsm.State.state() == config.BGPFSMEstablished {
 				closeConnDir = connDir
 			} else if localBGPId > bgpIdInt {
 				closeConnDir = config.ConnDirIn
@@ -372,17 +516,19 @@ func (mgr *FSMManager) receivedBGPOpenMessage(id uint8, connDir config.ConnDir,
 			}
 			closeFSMId := mgr.getFSMIdByDir(closeConnDir)
 			mgr.logger.Infof("FSMManager - Neighbor %s: Close FSM id %d", mgr.pConf.NeighborAddress, closeFSMId)
-			mgr.fsmClose(closeFSMId)
+			mgr.fsmClose(closeFSMId, gracefulRestart)
 		}
 	}
 
 	if closeFSMId == uint8(config.ConnDirInvalid) || closeFSMId != id {
 		asSize := packet.GetASSize(openMsg)
 		addPathFamily := packet.GetAddPathFamily(openMsg)
+		routeRefresh, enhancedRouteRefresh := packet.GetRouteRefreshCaps(openMsg)
 		if mgr.fsms[id] != nil {
 			mgr.logger.Infof("FSMManager - Neighbor %s: FSM %d set peer attr", mgr.pConf.NeighborAddress, id)
 			mgr.neighborConf.SetPeerAttrs(openMsg.BGPId, asSize, mgr.fsms[id].holdTime, mgr.fsms[id].keepAliveTime,
-				addPathFamily)
+				addPathFamily, routeRefresh, enhancedRouteRefresh)
+			mgr.neighborConf.SetPeerGRAttrs(packet.GetGracefulRestartCap(openMsg), mgr.fsms[id].afiSafiMap)
 		}
 	}
 
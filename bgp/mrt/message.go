@@ -0,0 +1,234 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// message.go
+package mrt
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+const (
+	MRTCommonHeaderLen = 12
+)
+
+type MRTType uint16
+
+const (
+	MRTTypeTableDumpV2 MRTType = 13
+	MRTTypeBGP4MP      MRTType = 16
+)
+
+const (
+	TableDumpV2PeerIndexTable uint16 = 1
+	TableDumpV2RIBIPv4Unicast uint16 = 2
+	TableDumpV2RIBIPv6Unicast uint16 = 4
+)
+
+const (
+	BGP4MPMessage    uint16 = 1
+	BGP4MPMessageAS4 uint16 = 4
+)
+
+const asTrans uint32 = 23456
+
+const (
+	peerTypeIPv6 uint8 = 0x01
+	peerTypeAS4  uint8 = 0x02
+)
+
+const (
+	afiIPv4 uint16 = 1
+	afiIPv6 uint16 = 2
+)
+
+// bgpPathAttrMPReachNLRI is the type code of the MP_REACH_NLRI attribute. In the RIB entries, the attribute
+// only has the next hop.
+const bgpPathAttrMPReachNLRI uint8 = 14
+
+// PeerEntry is a peer of the PEER_INDEX_TABLE, the RIB entries refer to the peers by their index in the table.
+type PeerEntry struct {
+	BGPId   net.IP
+	Address net.IP
+	AS      uint32
+}
+
+// RIBEntry is a path of a prefix in the RIB_IPV4_UNICAST and RIB_IPV6_UNICAST records.
+type RIBEntry struct {
+	PeerIndex      uint16
+	OriginatedTime time.Time
+	PathAttrs      []byte
+}
+
+func newRecord(timestamp time.Time, mrtType MRTType, subType uint16, bodyLen int) []byte {
+	pkt := make([]byte, MRTCommonHeaderLen+bodyLen)
+	binary.BigEndian.PutUint32(pkt[0:4], uint32(timestamp.Unix()))
+	binary.BigEndian.PutUint16(pkt[4:6], uint16(mrtType))
+	binary.BigEndian.PutUint16(pkt[6:8], subType)
+	binary.BigEndian.PutUint32(pkt[8:12], uint32(bodyLen))
+	return pkt
+}
+
+func getAS2(as uint32) uint32 {
+	if as > 0xffff {
+		return asTrans
+	}
+	return as
+}
+
+func getIPBytes(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	if ip16 := ip.To16(); ip16 != nil {
+		return ip16
+	}
+	return net.IPv4zero.To4()
+}
+
+// NewPeerIndexTable returns the PEER_INDEX_TABLE record that starts a RIB dump. The view name is the VRF of the
+// RIB.
+func NewPeerIndexTable(timestamp time.Time, collectorId net.IP, viewName string, peers []PeerEntry) []byte {
+	bodyLen := 4 + 2 + len(viewName) + 2
+	for _, peer := range peers {
+		bodyLen += 1 + 4 + len(getIPBytes(peer.Address)) + 4
+	}
+
+	pkt := newRecord(timestamp, MRTTypeTableDumpV2, TableDumpV2PeerIndexTable, bodyLen)
+	idx := MRTCommonHeaderLen
+	copy(pkt[idx:idx+4], getIPBytes(collectorId))
+	idx += 4
+	binary.BigEndian.PutUint16(pkt[idx:idx+2], uint16(len(viewName)))
+	idx += 2
+	idx += copy(pkt[idx:], viewName)
+	binary.BigEndian.PutUint16(pkt[idx:idx+2], uint16(len(peers)))
+	idx += 2
+
+	for _, peer := range peers {
+		addr := getIPBytes(peer.Address)
+		pkt[idx] = peerTypeAS4
+		if len(addr) == net.IPv6len {
+			pkt[idx] |= peerTypeIPv6
+		}
+		idx++
+		copy(pkt[idx:idx+4], getIPBytes(peer.BGPId))
+		idx += 4
+		idx += copy(pkt[idx:], addr)
+		binary.BigEndian.PutUint32(pkt[idx:idx+4], peer.AS)
+		idx += 4
+	}
+	return pkt
+}
+
+// NewRIBRecord returns the RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record with the paths of the prefix.
+func NewRIBRecord(timestamp time.Time, seqNum uint32, prefix net.IP, prefixLen uint8, entries []RIBEntry) []byte {
+	subType := TableDumpV2RIBIPv4Unicast
+	prefixBytes := getIPBytes(prefix)
+	if len(prefixBytes) == net.IPv6len {
+		subType = TableDumpV2RIBIPv6Unicast
+	}
+	prefixBytes = prefixBytes[:(prefixLen+7)/8]
+
+	bodyLen := 4 + 1 + len(prefixBytes) + 2
+	for _, entry := range entries {
+		bodyLen += 2 + 4 + 2 + len(entry.PathAttrs)
+	}
+
+	pkt := newRecord(timestamp, MRTTypeTableDumpV2, subType, bodyLen)
+	idx := MRTCommonHeaderLen
+	binary.BigEndian.PutUint32(pkt[idx:idx+4], seqNum)
+	idx += 4
+	pkt[idx] = prefixLen
+	idx++
+	idx += copy(pkt[idx:], prefixBytes)
+	binary.BigEndian.PutUint16(pkt[idx:idx+2], uint16(len(entries)))
+	idx += 2
+
+	for _, entry := range entries {
+		binary.BigEndian.PutUint16(pkt[idx:idx+2], entry.PeerIndex)
+		binary.BigEndian.PutUint32(pkt[idx+2:idx+6], uint32(entry.OriginatedTime.Unix()))
+		binary.BigEndian.PutUint16(pkt[idx+6:idx+8], uint16(len(entry.PathAttrs)))
+		idx += 8
+		idx += copy(pkt[idx:], entry.PathAttrs)
+	}
+	return pkt
+}
+
+// EncodeMPReachNextHop returns the MP_REACH_NLRI attribute of the RIB entries, RFC 6396 section 4.3.4. The
+// attribute only has the next hop length and the next hop address.
+func EncodeMPReachNextHop(nextHop net.IP) []byte {
+	nextHopBytes := getIPBytes(nextHop)
+	pkt := make([]byte, 3+1+len(nextHopBytes))
+	pkt[0] = 0x80
+	pkt[1] = bgpPathAttrMPReachNLRI
+	pkt[2] = uint8(1 + len(nextHopBytes))
+	pkt[3] = uint8(len(nextHopBytes))
+	copy(pkt[4:], nextHopBytes)
+	return pkt
+}
+
+// NewBGP4MPMessage returns the BGP4MP_MESSAGE_AS4 record with the BGP message received from the peer. The
+// BGP4MP_MESSAGE record is used if the peer does not support 4 byte AS numbers, the AS_PATH of its messages has
+// 2 byte AS numbers.
+func NewBGP4MPMessage(timestamp time.Time, as4 bool, peerAS uint32, localAS uint32, ifIndex uint16, peerIP net.IP,
+	localIP net.IP, msg []byte) []byte {
+	peerAddr := getIPBytes(peerIP)
+	localAddr := getIPBytes(localIP)
+	afi := afiIPv4
+	if len(peerAddr) == net.IPv6len || len(localAddr) == net.IPv6len {
+		afi = afiIPv6
+		peerAddr = peerIP.To16()
+		localAddr = localIP.To16()
+		if localAddr == nil {
+			localAddr = net.IPv6zero
+		}
+	}
+
+	if !as4 {
+		pkt := newRecord(timestamp, MRTTypeBGP4MP, BGP4MPMessage, 2+2+2+2+2*len(peerAddr)+len(msg))
+		idx := MRTCommonHeaderLen
+		binary.BigEndian.PutUint16(pkt[idx:idx+2], uint16(getAS2(peerAS)))
+		binary.BigEndian.PutUint16(pkt[idx+2:idx+4], uint16(getAS2(localAS)))
+		binary.BigEndian.PutUint16(pkt[idx+4:idx+6], ifIndex)
+		binary.BigEndian.PutUint16(pkt[idx+6:idx+8], afi)
+		idx += 8
+		idx += copy(pkt[idx:], peerAddr)
+		idx += copy(pkt[idx:], localAddr)
+		copy(pkt[idx:], msg)
+		return pkt
+	}
+
+	pkt := newRecord(timestamp, MRTTypeBGP4MP, BGP4MPMessageAS4, 4+4+2+2+2*len(peerAddr)+len(msg))
+	idx := MRTCommonHeaderLen
+	binary.BigEndian.PutUint32(pkt[idx:idx+4], peerAS)
+	binary.BigEndian.PutUint32(pkt[idx+4:idx+8], localAS)
+	binary.BigEndian.PutUint16(pkt[idx+8:idx+10], ifIndex)
+	binary.BigEndian.PutUint16(pkt[idx+10:idx+12], afi)
+	idx += 12
+	idx += copy(pkt[idx:], peerAddr)
+	idx += copy(pkt[idx:], localAddr)
+	copy(pkt[idx:], msg)
+	return pkt
+}
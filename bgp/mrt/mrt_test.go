@@ -0,0 +1,142 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// mrt_test.go
+package mrt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"utils/logging"
+)
+
+func verifyCommonHeader(t *testing.T, pkt []byte, mrtType MRTType, subType uint16) {
+	if MRTType(binary.BigEndian.Uint16(pkt[4:6])) != mrtType || binary.BigEndian.Uint16(pkt[6:8]) != subType {
+		t.Fatal("MRT record type", pkt[4:8], "expected", mrtType, subType)
+	}
+	if int(binary.BigEndian.Uint32(pkt[8:12])) != len(pkt)-MRTCommonHeaderLen {
+		t.Fatal("MRT record length", binary.BigEndian.Uint32(pkt[8:12]), "expected", len(pkt)-MRTCommonHeaderLen)
+	}
+}
+
+func TestMRTPeerIndexTable(t *testing.T) {
+	peers := []PeerEntry{
+		PeerEntry{BGPId: net.ParseIP("1.1.1.1"), AS: 65000},
+		PeerEntry{BGPId: net.ParseIP("2.2.2.2"), Address: net.ParseIP("2001:db8::2"), AS: 65001},
+	}
+	pkt := NewPeerIndexTable(time.Unix(1000, 0), net.ParseIP("1.1.1.1"), "default", peers)
+	verifyCommonHeader(t, pkt, MRTTypeTableDumpV2, TableDumpV2PeerIndexTable)
+	if binary.BigEndian.Uint32(pkt[0:4]) != 1000 {
+		t.Fatal("MRT timestamp", binary.BigEndian.Uint32(pkt[0:4]), "expected 1000")
+	}
+
+	expected := []byte{1, 1, 1, 1, 0, 7, 'd', 'e', 'f', 'a', 'u', 'l', 't', 0, 2,
+		peerTypeAS4, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0xfd, 0xe8,
+		peerTypeAS4 | peerTypeIPv6, 2, 2, 2, 2}
+	expected = append(expected, net.ParseIP("2001:db8::2")...)
+	expected = append(expected, 0, 0, 0xfd, 0xe9)
+	if !bytes.Equal(pkt[MRTCommonHeaderLen:], expected) {
+		t.Fatal("MRT peer index table", pkt[MRTCommonHeaderLen:], "expected", expected)
+	}
+}
+
+func TestMRTRIBRecord(t *testing.T) {
+	entries := []RIBEntry{RIBEntry{PeerIndex: 1, OriginatedTime: time.Unix(500, 0), PathAttrs: []byte{0x40, 1, 1, 0}}}
+	pkt := NewRIBRecord(time.Unix(1000, 0), 7, net.ParseIP("20.1.0.0"), 16, entries)
+	verifyCommonHeader(t, pkt, MRTTypeTableDumpV2, TableDumpV2RIBIPv4Unicast)
+	expected := []byte{0, 0, 0, 7, 16, 20, 1, 0, 1, 0, 1, 0, 0, 0x01, 0xf4, 0, 4, 0x40, 1, 1, 0}
+	if !bytes.Equal(pkt[MRTCommonHeaderLen:], expected) {
+		t.Fatal("MRT RIB record", pkt[MRTCommonHeaderLen:], "expected", expected)
+	}
+
+	pkt = NewRIBRecord(time.Unix(1000, 0), 8, net.ParseIP("2001:db8::"), 32, nil)
+	verifyCommonHeader(t, pkt, MRTTypeTableDumpV2, TableDumpV2RIBIPv6Unicast)
+	expected = []byte{0, 0, 0, 8, 32, 0x20, 0x01, 0x0d, 0xb8, 0, 0}
+	if !bytes.Equal(pkt[MRTCommonHeaderLen:], expected) {
+		t.Fatal("MRT RIB record", pkt[MRTCommonHeaderLen:], "expected", expected)
+	}
+
+	mpReach := EncodeMPReachNextHop(net.ParseIP("2001:db8::1"))
+	if len(mpReach) != 20 || mpReach[2] != 17 || mpReach[3] != 16 {
+		t.Fatal("MRT MP_REACH_NLRI next hop attribute", mpReach, "not encoded correctly")
+	}
+}
+
+func TestMRTBGP4MPMessage(t *testing.T) {
+	msg := []byte{0xff, 0xff, 0, 19, 4}
+	pkt := NewBGP4MPMessage(time.Unix(1000, 0), true, 70000, 65000, 0, net.ParseIP("10.1.1.2"),
+		net.ParseIP("10.1.1.1"), msg)
+	verifyCommonHeader(t, pkt, MRTTypeBGP4MP, BGP4MPMessageAS4)
+	expected := []byte{0, 1, 0x11, 0x70, 0, 0, 0xfd, 0xe8, 0, 0, 0, 1, 10, 1, 1, 2, 10, 1, 1, 1}
+	expected = append(expected, msg...)
+	if !bytes.Equal(pkt[MRTCommonHeaderLen:], expected) {
+		t.Fatal("MRT BGP4MP message", pkt[MRTCommonHeaderLen:], "expected", expected)
+	}
+
+	pkt = NewBGP4MPMessage(time.Unix(1000, 0), false, 70000, 65000, 0, net.ParseIP("10.1.1.2"),
+		net.ParseIP("10.1.1.1"), msg)
+	verifyCommonHeader(t, pkt, MRTTypeBGP4MP, BGP4MPMessage)
+	if binary.BigEndian.Uint16(pkt[MRTCommonHeaderLen:]) != uint16(asTrans) {
+		t.Fatal("MRT BGP4MP peer AS", pkt[MRTCommonHeaderLen:MRTCommonHeaderLen+2], "expected AS_TRANS")
+	}
+}
+
+func TestMRTUpdateRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mrt")
+	if err != nil {
+		t.Fatal("Failed to create the temp dir with error:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger, _ := logging.NewLogger("bgpd", "BGP", false)
+	recorder := NewUpdateRecorder(logger)
+	recorder.RecordMessage(true, 65001, 65000, net.ParseIP("10.1.1.2"), nil, []byte{1}, []byte{2})
+	recorder.Start(dir, time.Hour)
+	recorder.RecordMessage(true, 65001, 65000, net.ParseIP("10.1.1.2"), nil, []byte{1}, []byte{2})
+
+	var files []string
+	for i := 0; i < 100 && len(files) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		files, _ = filepath.Glob(filepath.Join(dir, MRTUpdatesPrefix+".*"))
+	}
+	recorder.Stop()
+	if len(files) != 1 {
+		t.Fatal("MRT update recorder files", files, "expected 1 file")
+	}
+
+	var data []byte
+	for i := 0; i < 100 && len(data) == 0; i++ {
+		data, _ = ioutil.ReadFile(files[0])
+		time.Sleep(10 * time.Millisecond)
+	}
+	verifyCommonHeader(t, data, MRTTypeBGP4MP, BGP4MPMessageAS4)
+	if !bytes.Equal(data[len(data)-2:], []byte{1, 2}) {
+		t.Fatal("MRT update recorder message", data, "expected to end with the recorded message")
+	}
+}
@@ -0,0 +1,192 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// writer.go
+package mrt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+	"utils/logging"
+)
+
+const (
+	MRTFileTimeFormat  = "20060102.150405"
+	MRTRecordQueueSize = 4096
+	MRTFilePermissions = 0644
+	MRTDirPermissions  = 0755
+	MRTUpdatesPrefix   = "updates"
+	MRTRIBPrefix       = "rib"
+)
+
+// FileWriter writes the MRT records to the files in a directory. A new file is started when the rotation
+// interval expires, the files are named prefix.YYYYMMDD.HHMMSS after the time they were started.
+type FileWriter struct {
+	dir            string
+	prefix         string
+	rotateInterval time.Duration
+	file           *os.File
+	startTime      time.Time
+}
+
+func NewFileWriter(dir string, prefix string, rotateInterval time.Duration) *FileWriter {
+	return &FileWriter{
+		dir:            dir,
+		prefix:         prefix,
+		rotateInterval: rotateInterval,
+	}
+}
+
+func (w *FileWriter) open(now time.Time) error {
+	if err := os.MkdirAll(w.dir, MRTDirPermissions); err != nil {
+		return errors.New(fmt.Sprintf("Failed to create MRT directory %s, error: %s", w.dir, err))
+	}
+
+	name := filepath.Join(w.dir, w.prefix+"."+now.Format(MRTFileTimeFormat))
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, MRTFilePermissions)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to open MRT file %s, error: %s", name, err))
+	}
+
+	w.file = file
+	w.startTime = now
+	return nil
+}
+
+// Write writes the records to the current file, the file is rotated first if the rotation interval expired.
+func (w *FileWriter) Write(records ...[]byte) error {
+	now := time.Now()
+	if w.file != nil && w.rotateInterval > 0 && now.Sub(w.startTime) >= w.rotateInterval {
+		w.Close()
+	}
+
+	if w.file == nil {
+		if err := w.open(now); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		if _, err := w.file.Write(record); err != nil {
+			w.Close()
+			return errors.New(fmt.Sprintf("Failed to write MRT record to %s, error: %s", w.dir, err))
+		}
+	}
+	return nil
+}
+
+func (w *FileWriter) Close() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+}
+
+// UpdateRecorder records the BGP messages received from the peers in BGP4MP_MESSAGE_AS4 records. The messages
+// are queued to a goroutine so that the peer connections are not blocked on the file writes.
+type UpdateRecorder struct {
+	logger  *logging.Writer
+	mutex   sync.RWMutex
+	writer  *FileWriter
+	msgCh   chan []byte
+	stopCh  chan bool
+	dropped uint64
+}
+
+func NewUpdateRecorder(logger *logging.Writer) *UpdateRecorder {
+	return &UpdateRecorder{
+		logger: logger,
+	}
+}
+
+// Start starts recording the messages to the directory, the recording is restarted if it is already running.
+func (r *UpdateRecorder) Start(dir string, rotateInterval time.Duration) {
+	r.Stop()
+
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.writer = NewFileWriter(dir, MRTUpdatesPrefix, rotateInterval)
+	r.msgCh = make(chan []byte, MRTRecordQueueSize)
+	r.stopCh = make(chan bool)
+	go r.run(r.writer, r.msgCh, r.stopCh)
+}
+
+func (r *UpdateRecorder) Stop() {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	if r.msgCh == nil {
+		return
+	}
+
+	close(r.stopCh)
+	r.writer = nil
+	r.msgCh = nil
+	r.stopCh = nil
+}
+
+func (r *UpdateRecorder) IsRunning() bool {
+	defer r.mutex.RUnlock()
+	r.mutex.RLock()
+	return r.msgCh != nil
+}
+
+// RecordMessage queues the BGP message received from the peer, the message is passed as the header and the body
+// read from the connection. The message is dropped if the queue is full.
+func (r *UpdateRecorder) RecordMessage(as4 bool, peerAS uint32, localAS uint32, peerIP net.IP, localIP net.IP,
+	header []byte, body []byte) {
+	defer r.mutex.RUnlock()
+	r.mutex.RLock()
+	if r.msgCh == nil {
+		return
+	}
+
+	msg := make([]byte, 0, len(header)+len(body))
+	msg = append(append(msg, header...), body...)
+	select {
+	case r.msgCh <- NewBGP4MPMessage(time.Now(), as4, peerAS, localAS, 0, peerIP, localIP, msg):
+	default:
+		dropped := atomic.AddUint64(&r.dropped, 1)
+		r.logger.Err("MRT update recorder queue full, dropped", dropped, "messages")
+	}
+}
+
+func (r *UpdateRecorder) run(writer *FileWriter, msgCh chan []byte, stopCh chan bool) {
+	defer writer.Close()
+	for {
+		select {
+		case record := <-msgCh:
+			if err := writer.Write(record); err != nil {
+				r.logger.Err("MRT update recorder:", err)
+			}
+
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -38,3 +38,7 @@ type OvsPolicyMgr struct {
 type OvsBfdMgr struct {
 	plugin string
 }
+
+type OvsEVPNMgr struct {
+	plugin string
+}
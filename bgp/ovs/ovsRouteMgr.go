@@ -64,3 +64,19 @@ func (mgr *OvsRouteMgr) ApplyPolicy(applyList []*config.ApplyPolicyInfo, undoLis
 func (mgr *OvsRouteMgr) GetRoutes() ([]*config.RouteInfo, []*config.RouteInfo) {
 	return nil, nil
 }
+
+func (mgr *OvsRouteMgr) CreateVrfLabel(vrf string, label uint32) {
+
+}
+
+func (mgr *OvsRouteMgr) DeleteVrfLabel(vrf string, label uint32) {
+
+}
+
+func (mgr *OvsRouteMgr) CreateLocalLabel(label uint32, cfg *config.RouteConfig) {
+
+}
+
+func (mgr *OvsRouteMgr) DeleteLocalLabel(label uint32) {
+
+}
@@ -45,9 +45,24 @@ const (
 	SafiMulticast
 )
 
+const (
+	AfiL2VPN AFI = 25
+)
+
+const (
+	SafiLabeledUnicast SAFI = 4
+	SafiEVPN           SAFI = 70
+	SafiMPLSVPN        SAFI = 128
+)
+
 var ProtocolFamilyMap = map[string]uint32{
-	"ipv4-unicast": GetProtocolFamily(AfiIP, SafiUnicast),
-	"ipv6-unicast": GetProtocolFamily(AfiIP6, SafiUnicast),
+	"ipv4-unicast":         GetProtocolFamily(AfiIP, SafiUnicast),
+	"ipv6-unicast":         GetProtocolFamily(AfiIP6, SafiUnicast),
+	"ipv4-labeled-unicast": GetProtocolFamily(AfiIP, SafiLabeledUnicast),
+	"ipv6-labeled-unicast": GetProtocolFamily(AfiIP6, SafiLabeledUnicast),
+	"l3vpn-ipv4-unicast":   GetProtocolFamily(AfiIP, SafiMPLSVPN),
+	"l3vpn-ipv6-unicast":   GetProtocolFamily(AfiIP6, SafiMPLSVPN),
+	"l2vpn-evpn":           GetProtocolFamily(AfiL2VPN, SafiEVPN),
 	//"ipv4-multicast": GetProtocolFamily(AfiIP, SafiMulticast),
 	//"ipv6-multicast": GetProtocolFamily(AfiIP6, SafiMulticast),
 }
@@ -118,13 +133,44 @@ func GetZeroNextHopForFamily(protoFamily uint32) net.IP {
 	return nil
 }
 
+// GetProtocolFamilyName returns the AFI-SAFI name of the protocol family in ProtocolFamilyMap.
+func GetProtocolFamilyName(protoFamily uint32) string {
+	for name, pf := range ProtocolFamilyMap {
+		if pf == protoFamily {
+			return name
+		}
+	}
+	return ""
+}
+
+func IsProtocolFamilySupported(protoFamily uint32) bool {
+	for _, pf := range ProtocolFamilyMap {
+		if pf == protoFamily {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnicastFamily returns true for the IPv4 and IPv6 unicast families. The routes of the other families are
+// not installed in the RIB and are advertised without add-path.
+func IsUnicastFamily(protoFamily uint32) bool {
+	afi, safi := GetAfiSafi(protoFamily)
+	return (afi == AfiIP || afi == AfiIP6) && safi == SafiUnicast
+}
+
+// GetProtocolFromOpenMsg returns the protocol families advertised in the multiprotocol capabilities of the
+// OPEN message. The families that are not supported are ignored.
 func GetProtocolFromOpenMsg(openMsg *BGPOpen) map[uint32]bool {
 	afiSafiMap := make(map[uint32]bool)
 	for _, optParam := range openMsg.OptParams {
 		if capabilities, ok := optParam.(*BGPOptParamCapability); ok {
 			for _, capability := range capabilities.Value {
 				if val, ok := capability.(*BGPCapMPExt); ok {
-					afiSafiMap[GetProtocolFamily(val.AFI, val.SAFI)] = true
+					protoFamily := GetProtocolFamily(val.AFI, val.SAFI)
+					if IsProtocolFamilySupported(protoFamily) {
+						afiSafiMap[protoFamily] = true
+					}
 				}
 			}
 		}
@@ -52,13 +52,22 @@ func NewBGPPktSrc(src string, msg *BGPMessage) *BGPPktSrc {
 }
 
 type BGPPeerAttrs struct {
-	ASSize           uint8
-	AddPathFamily    map[AFI]map[SAFI]uint8
-	AddPathsRxActual bool
+	ASSize             uint8
+	AddPathFamily      map[AFI]map[SAFI]uint8
+	AddPathsRxActual   bool            // Path ids are received for all the protocol families
+	AddPathsRxFamilies map[uint32]bool // Protocol families for which path ids are received
 }
 
 const BGPASTrans uint16 = 23456
 
+// Private AS ranges, RFC 6996
+const (
+	BGPPrivateASMin  uint32 = 64512
+	BGPPrivateASMax  uint32 = 65534
+	BGPPrivateAS4Min uint32 = 4200000000
+	BGPPrivateAS4Max uint32 = 4294967294
+)
+
 const BGPHeaderMarkerLen int = 16
 
 const (
@@ -67,6 +76,7 @@ const (
 	BGPMsgTypeUpdate
 	BGPMsgTypeNotification
 	BGPMsgTypeKeepAlive
+	BGPMsgTypeRouteRefresh
 )
 
 const (
@@ -85,6 +95,7 @@ const (
 	BGPHoldTimerExpired
 	BGPFSMError
 	BGPCease
+	BGPRouteRefreshMsgError
 )
 
 const (
@@ -120,6 +131,19 @@ const (
 	BGPMalformedASPath
 )
 
+const (
+	_ uint8 = iota
+	BGPInvalidRouteRefreshMsgLen
+)
+
+const (
+	BGPRouteRefreshNormal uint8 = iota
+	BGPRouteRefreshBoRR
+	BGPRouteRefreshEoRR
+)
+
+const BGPRouteRefreshMsgLen = 4
+
 type BGPOptParamType uint8
 
 const (
@@ -137,14 +161,20 @@ type BGPCapabilityType uint8
 const (
 	_ BGPCapabilityType = iota
 	BGPCapTypeMPExt
-	BGPCapTypeAS4Path BGPCapabilityType = 65
-	BGPCapTypeAddPath BGPCapabilityType = 69
+	BGPCapTypeRouteRefresh
+	BGPCapTypeGracefulRestart      BGPCapabilityType = 64
+	BGPCapTypeAS4Path              BGPCapabilityType = 65
+	BGPCapTypeAddPath              BGPCapabilityType = 69
+	BGPCapTypeEnhancedRouteRefresh BGPCapabilityType = 70
 )
 
 var BGPCapTypeToStruct = map[BGPCapabilityType]BGPCapability{
-	BGPCapTypeMPExt:   &BGPCapMPExt{},
-	BGPCapTypeAS4Path: &BGPCapAS4Path{},
-	BGPCapTypeAddPath: &BGPCapAddPath{},
+	BGPCapTypeMPExt:                &BGPCapMPExt{},
+	BGPCapTypeRouteRefresh:         &BGPCapRouteRefresh{},
+	BGPCapTypeGracefulRestart:      &BGPCapGracefulRestart{},
+	BGPCapTypeAS4Path:              &BGPCapAS4Path{},
+	BGPCapTypeAddPath:              &BGPCapAddPath{},
+	BGPCapTypeEnhancedRouteRefresh: &BGPCapEnhancedRouteRefresh{},
 }
 
 const (
@@ -176,7 +206,7 @@ const (
 	BGPPathAttrTypeLocalPref
 	BGPPathAttrTypeAtomicAggregate
 	BGPPathAttrTypeAggregator
-	_
+	BGPPathAttrTypeCommunities
 	BGPPathAttrTypeOriginatorId
 	BGPPathAttrTypeClusterList
 	_
@@ -184,12 +214,15 @@ const (
 	_
 	BGPPathAttrTypeMPReachNLRI
 	BGPPathAttrTypeMPUnreachNLRI
-	_
+	BGPPathAttrTypeExtCommunities
 	BGPPathAttrTypeAS4Path
 	BGPPathAttrTypeAS4Aggregator
 	BGPPathAttrTypeUnknown
 )
 
+const BGPPathAttrTypePMSITunnel BGPPathAttrType = 22
+const BGPPathAttrTypeLargeCommunities BGPPathAttrType = 32
+
 type BGPPathAttrOriginType uint8
 
 const (
@@ -211,42 +244,58 @@ type BGPASPathSegmentType uint8
 const (
 	BGPASPathSegmentSet BGPASPathSegmentType = iota + 1
 	BGPASPathSegmentSequence
+	BGPASPathSegmentConfedSequence // RFC 5065
+	BGPASPathSegmentConfedSet
 	BGPASPathSegmentUnknown
 )
 
+// IsConfedSegment returns true if the AS path segment is an AS_CONFED_SEQUENCE or AS_CONFED_SET segment. The
+// confederation segments are not counted in the AS path length, RFC 5065.
+func IsConfedSegment(seg BGPASPathSegment) bool {
+	return seg.GetType() == BGPASPathSegmentConfedSequence || seg.GetType() == BGPASPathSegmentConfedSet
+}
+
 var BGPPathAttrWellKnownMandatory = []BGPPathAttrType{
 	BGPPathAttrTypeOrigin, BGPPathAttrTypeASPath, BGPPathAttrTypeNextHop}
 
 var BGPPathAttrTypeToStructMap = map[BGPPathAttrType]BGPPathAttr{
-	BGPPathAttrTypeOrigin:          &BGPPathAttrOrigin{},
-	BGPPathAttrTypeASPath:          &BGPPathAttrASPath{},
-	BGPPathAttrTypeNextHop:         &BGPPathAttrNextHop{},
-	BGPPathAttrTypeMultiExitDisc:   &BGPPathAttrMultiExitDisc{},
-	BGPPathAttrTypeLocalPref:       &BGPPathAttrLocalPref{},
-	BGPPathAttrTypeAtomicAggregate: &BGPPathAttrAtomicAggregate{},
-	BGPPathAttrTypeAggregator:      &BGPPathAttrAggregator{},
-	BGPPathAttrTypeOriginatorId:    &BGPPathAttrOriginatorId{},
-	BGPPathAttrTypeClusterList:     &BGPPathAttrClusterList{},
-	BGPPathAttrTypeMPReachNLRI:     &BGPPathAttrMPReachNLRI{},
-	BGPPathAttrTypeMPUnreachNLRI:   &BGPPathAttrMPUnreachNLRI{},
-	BGPPathAttrTypeAS4Path:         &BGPPathAttrAS4Path{},
-	BGPPathAttrTypeAS4Aggregator:   &BGPPathAttrAS4Aggregator{},
+	BGPPathAttrTypeOrigin:           &BGPPathAttrOrigin{},
+	BGPPathAttrTypeASPath:           &BGPPathAttrASPath{},
+	BGPPathAttrTypeNextHop:          &BGPPathAttrNextHop{},
+	BGPPathAttrTypeMultiExitDisc:    &BGPPathAttrMultiExitDisc{},
+	BGPPathAttrTypeLocalPref:        &BGPPathAttrLocalPref{},
+	BGPPathAttrTypeAtomicAggregate:  &BGPPathAttrAtomicAggregate{},
+	BGPPathAttrTypeAggregator:       &BGPPathAttrAggregator{},
+	BGPPathAttrTypeCommunities:      &BGPPathAttrCommunities{},
+	BGPPathAttrTypeOriginatorId:     &BGPPathAttrOriginatorId{},
+	BGPPathAttrTypeClusterList:      &BGPPathAttrClusterList{},
+	BGPPathAttrTypeMPReachNLRI:      &BGPPathAttrMPReachNLRI{},
+	BGPPathAttrTypeMPUnreachNLRI:    &BGPPathAttrMPUnreachNLRI{},
+	BGPPathAttrTypeExtCommunities:   &BGPPathAttrExtCommunities{},
+	BGPPathAttrTypeAS4Path:          &BGPPathAttrAS4Path{},
+	BGPPathAttrTypeAS4Aggregator:    &BGPPathAttrAS4Aggregator{},
+	BGPPathAttrTypePMSITunnel:       &BGPPathAttrPMSITunnel{},
+	BGPPathAttrTypeLargeCommunities: &BGPPathAttrLargeCommunities{},
 }
 
 var BGPPathAttrTypeFlagsMap = map[BGPPathAttrType][]BGPPathAttrFlag{
-	BGPPathAttrTypeOrigin:          []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeASPath:          []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeNextHop:         []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeMultiExitDisc:   []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeLocalPref:       []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeAtomicAggregate: []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeAggregator:      []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeOriginatorId:    []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeClusterList:     []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeMPReachNLRI:     []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeMPUnreachNLRI:   []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeAS4Path:         []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
-	BGPPathAttrTypeAS4Aggregator:   []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeOrigin:           []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeASPath:           []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeNextHop:          []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeMultiExitDisc:    []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeLocalPref:        []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeAtomicAggregate:  []BGPPathAttrFlag{BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeAggregator:       []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeCommunities:      []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeOriginatorId:     []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeClusterList:      []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeMPReachNLRI:      []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeMPUnreachNLRI:    []BGPPathAttrFlag{BGPPathAttrFlagOptional, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeExtCommunities:   []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeAS4Path:          []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeAS4Aggregator:    []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypePMSITunnel:       []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
+	BGPPathAttrTypeLargeCommunities: []BGPPathAttrFlag{BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive, BGPPathAttrFlagAllMinusExtendedLen},
 }
 
 var BGPPathAttrTypeLenMap = map[BGPPathAttrType]uint16{
@@ -438,6 +487,170 @@ func NewBGPCap4ByteASPath(as uint32) *BGPCapAS4Path {
 	}
 }
 
+type BGPCapRouteRefresh struct {
+	BGPCapabilityBase
+}
+
+func (msg *BGPCapRouteRefresh) New() BGPCapability {
+	return &BGPCapRouteRefresh{}
+}
+
+func NewBGPCapRouteRefresh() *BGPCapRouteRefresh {
+	return &BGPCapRouteRefresh{
+		BGPCapabilityBase: BGPCapabilityBase{
+			Type: BGPCapTypeRouteRefresh,
+			Len:  0,
+		},
+	}
+}
+
+type BGPCapEnhancedRouteRefresh struct {
+	BGPCapabilityBase
+}
+
+func (msg *BGPCapEnhancedRouteRefresh) New() BGPCapability {
+	return &BGPCapEnhancedRouteRefresh{}
+}
+
+func NewBGPCapEnhancedRouteRefresh() *BGPCapEnhancedRouteRefresh {
+	return &BGPCapEnhancedRouteRefresh{
+		BGPCapabilityBase: BGPCapabilityBase{
+			Type: BGPCapTypeEnhancedRouteRefresh,
+			Len:  0,
+		},
+	}
+}
+
+const (
+	BGPCapGRRestartState    uint8 = 0x8  // Restart flags - R bit
+	BGPCapGRForwardingState uint8 = 0x80 // AFI/SAFI flags - F bit
+)
+
+const BGPCapGRRestartTimeMax uint16 = 0xFFF
+
+type GracefulRestartAFISAFI struct {
+	AFI   AFI
+	SAFI  SAFI
+	Flags uint8
+}
+
+func (g *GracefulRestartAFISAFI) Encode(pkt []byte) error {
+	binary.BigEndian.PutUint16(pkt, uint16(g.AFI))
+	pkt[2] = uint8(g.SAFI)
+	pkt[3] = g.Flags
+	return nil
+}
+
+func (g *GracefulRestartAFISAFI) Decode(pkt []byte) error {
+	if len(pkt) < 4 {
+		return BGPMessageError{BGPOpenMsgError, BGPUnspecific, nil,
+			"Not enough data to decode Graceful restart capability"}
+	}
+
+	g.AFI = AFI(binary.BigEndian.Uint16(pkt))
+	g.SAFI = SAFI(pkt[2])
+	g.Flags = pkt[3]
+	return nil
+}
+
+func (g *GracefulRestartAFISAFI) Len() uint8 {
+	return 4
+}
+
+type BGPCapGracefulRestart struct {
+	BGPCapabilityBase
+	RestartFlags uint8
+	RestartTime  uint16
+	Value        []GracefulRestartAFISAFI
+}
+
+func (msg *BGPCapGracefulRestart) New() BGPCapability {
+	return &BGPCapGracefulRestart{}
+}
+
+func (msg *BGPCapGracefulRestart) Encode() ([]byte, error) {
+	pkt, err := msg.BGPCapabilityBase.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	binary.BigEndian.PutUint16(pkt[2:], (uint16(msg.RestartFlags)<<12)|(msg.RestartTime&BGPCapGRRestartTimeMax))
+	offset := uint8(4)
+	for _, val := range msg.Value {
+		val.Encode(pkt[offset:])
+		offset += val.Len()
+	}
+	return pkt, nil
+}
+
+func (msg *BGPCapGracefulRestart) Decode(pkt []byte) error {
+	err := msg.BGPCapabilityBase.Decode(pkt)
+	if err != nil {
+		return err
+	}
+
+	if msg.Len < 2 || (msg.Len-2)%4 != 0 {
+		return BGPMessageError{BGPOpenMsgError, BGPUnspecific, nil,
+			"Length of Graceful restart capability is not valid"}
+	}
+
+	restart := binary.BigEndian.Uint16(pkt[2:])
+	msg.RestartFlags = uint8(restart >> 12)
+	msg.RestartTime = restart & BGPCapGRRestartTimeMax
+	msg.Value = make([]GracefulRestartAFISAFI, 0)
+	offset := uint16(4)
+	for offset < msg.TotalLen() {
+		grAFISAFI := GracefulRestartAFISAFI{}
+		err := grAFISAFI.Decode(pkt[offset:])
+		if err != nil {
+			return err
+		}
+		msg.Value = append(msg.Value, grAFISAFI)
+		offset += uint16(grAFISAFI.Len())
+	}
+	return nil
+}
+
+func (msg *BGPCapGracefulRestart) AddGracefulRestartAFISAFI(afi AFI, safi SAFI, flags uint8) {
+	grAFISAFI := GracefulRestartAFISAFI{afi, safi, flags}
+	msg.Value = append(msg.Value, grAFISAFI)
+	msg.Len += grAFISAFI.Len()
+}
+
+func (msg *BGPCapGracefulRestart) IsRestarting() bool {
+	return msg.RestartFlags&BGPCapGRRestartState != 0
+}
+
+// GetForwardingFamilies returns the protocol families advertised in the capability and whether the
+// forwarding state of the family was preserved by the peer.
+func (msg *BGPCapGracefulRestart) GetForwardingFamilies() map[uint32]bool {
+	families := make(map[uint32]bool)
+	for _, val := range msg.Value {
+		families[GetProtocolFamily(val.AFI, val.SAFI)] = val.Flags&BGPCapGRForwardingState != 0
+	}
+	return families
+}
+
+func NewBGPCapGracefulRestart(restarting bool, restartTime uint16) *BGPCapGracefulRestart {
+	restartFlags := uint8(0)
+	if restarting {
+		restartFlags |= BGPCapGRRestartState
+	}
+	if restartTime > BGPCapGRRestartTimeMax {
+		restartTime = BGPCapGRRestartTimeMax
+	}
+
+	return &BGPCapGracefulRestart{
+		BGPCapabilityBase: BGPCapabilityBase{
+			Type: BGPCapTypeGracefulRestart,
+			Len:  2,
+		},
+		RestartFlags: restartFlags,
+		RestartTime:  restartTime,
+		Value:        make([]GracefulRestartAFISAFI, 0),
+	}
+}
+
 type AddPathAFISAFI struct {
 	AFI   AFI
 	SAFI  SAFI
@@ -862,6 +1075,44 @@ func NewBGPNotificationMessage(errorCode uint8, errorSubCode uint8, data []byte)
 	}
 }
 
+type BGPRouteRefresh struct {
+	AFI     AFI
+	SubType uint8
+	SAFI    SAFI
+}
+
+func (msg *BGPRouteRefresh) Clone() BGPBody {
+	x := *msg
+	return &x
+}
+
+func (msg *BGPRouteRefresh) Encode() ([]byte, error) {
+	pkt := make([]byte, BGPRouteRefreshMsgLen)
+	binary.BigEndian.PutUint16(pkt[0:2], uint16(msg.AFI))
+	pkt[2] = msg.SubType
+	pkt[3] = uint8(msg.SAFI)
+	return pkt, nil
+}
+
+func (msg *BGPRouteRefresh) Decode(header *BGPHeader, pkt []byte, data interface{}) error {
+	if len(pkt) != BGPRouteRefreshMsgLen {
+		return BGPMessageError{BGPRouteRefreshMsgError, BGPInvalidRouteRefreshMsgLen, pkt,
+			fmt.Sprintf("Route refresh message length %d is not %d", len(pkt), BGPRouteRefreshMsgLen)}
+	}
+
+	msg.AFI = AFI(binary.BigEndian.Uint16(pkt[0:2]))
+	msg.SubType = pkt[2]
+	msg.SAFI = SAFI(pkt[3])
+	return nil
+}
+
+func NewBGPRouteRefreshMessage(afi AFI, safi SAFI, subType uint8) *BGPMessage {
+	return &BGPMessage{
+		Header: BGPHeader{Length: uint16(BGPMsgHeaderLen + BGPRouteRefreshMsgLen), Type: BGPMsgTypeRouteRefresh},
+		Body:   &BGPRouteRefresh{AFI: afi, SubType: subType, SAFI: safi},
+	}
+}
+
 type NLRI interface {
 	Clone() NLRI
 	Encode(AFI) ([]byte, error)
@@ -1129,6 +1380,17 @@ func (pa *BGPPathAttrBase) TotalLen() uint32 {
 	return uint32(pa.Length) + uint32(pa.BGPPathAttrLen)
 }
 
+func (pa *BGPPathAttrBase) setLength(length uint16) {
+	pa.Length = length
+	if length > 255 {
+		pa.Flags |= BGPPathAttrFlagExtendedLen
+		pa.BGPPathAttrLen = 4
+	} else {
+		pa.Flags &^= BGPPathAttrFlagExtendedLen
+		pa.BGPPathAttrLen = 3
+	}
+}
+
 func (pa *BGPPathAttrBase) GetCode() BGPPathAttrType {
 	return pa.Code
 }
@@ -1289,7 +1551,9 @@ func (ps *BGPAS2PathSegment) Decode(pkt []byte, data interface{}) error {
 }
 
 func (ps *BGPAS2PathSegment) GetNumASes() uint8 {
-	if ps.Type == BGPASPathSegmentSet {
+	if IsConfedSegment(ps) {
+		return 0
+	} else if ps.Type == BGPASPathSegmentSet {
 		utils.Logger.Info("BGPAS2PathSegment:GetNumASes - AS SET num =", 1)
 		return 1
 	} else {
@@ -1408,7 +1672,9 @@ func (ps *BGPAS4PathSegment) Decode(pkt []byte, data interface{}) error {
 }
 
 func (ps *BGPAS4PathSegment) GetNumASes() uint8 {
-	if ps.Type == BGPASPathSegmentSet {
+	if IsConfedSegment(ps) {
+		return 0
+	} else if ps.Type == BGPASPathSegmentSet {
 		utils.Logger.Info("BGPAS2PathSegment:GetNumASes - AS SET num =", 1)
 		return 1
 	} else {
@@ -1558,6 +1824,60 @@ func (as *BGPPathAttrASPath) AppendASPathSegment(pathSeg BGPASPathSegment) {
 	as.BGPPathAttrBase.Length += pathSeg.TotalLen()
 }
 
+// RemoveConfedSegments removes the AS_CONFED_SEQUENCE and AS_CONFED_SET segments from the AS path.
+func (as *BGPPathAttrASPath) RemoveConfedSegments() {
+	segments := make([]BGPASPathSegment, 0, len(as.Value))
+	for _, seg := range as.Value {
+		if IsConfedSegment(seg) {
+			as.BGPPathAttrBase.Length -= seg.TotalLen()
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	as.Value = segments
+}
+
+// rewriteASes calls rewrite for every AS in the AS_SEQUENCE and AS_SET segments of the AS path. The AS is
+// replaced with the AS returned by rewrite or removed if rewrite returns false. The segments that don't have
+// any AS left are removed.
+func (as *BGPPathAttrASPath) rewriteASes(rewrite func(uint32) (uint32, bool)) {
+	segments := make([]BGPASPathSegment, 0, len(as.Value))
+	as.BGPPathAttrBase.Length = 0
+	for _, seg := range as.Value {
+		if !IsConfedSegment(seg) {
+			switch asSeg := seg.(type) {
+			case *BGPAS4PathSegment:
+				newSeg := NewBGPAS4PathSegment(asSeg.Type)
+				for _, asNum := range asSeg.AS {
+					if newAS, ok := rewrite(asNum); ok {
+						newSeg.AppendAS(newAS)
+					}
+				}
+				seg = newSeg
+
+			case *BGPAS2PathSegment:
+				newSeg := NewBGPAS2PathSegment(asSeg.Type)
+				for _, asNum := range asSeg.AS {
+					if newAS, ok := rewrite(uint32(asNum)); ok {
+						if newAS > math.MaxUint16 {
+							newAS = uint32(BGPASTrans)
+						}
+						newSeg.AppendAS(newAS)
+					}
+				}
+				seg = newSeg
+			}
+		}
+
+		if seg.GetLen() == 0 {
+			continue
+		}
+		segments = append(segments, seg)
+		as.BGPPathAttrBase.Length += seg.TotalLen()
+	}
+	as.Value = segments
+}
+
 func (as *BGPPathAttrASPath) New() BGPPathAttr {
 	return &BGPPathAttrASPath{}
 }
@@ -1640,6 +1960,19 @@ func (as *BGPPathAttrAS4Path) Decode(pkt []byte, data interface{}) error {
 	return nil
 }
 
+// removeConfedSegments removes the confederation segments, they are not carried in the AS4_PATH, RFC 6793.
+func (as *BGPPathAttrAS4Path) removeConfedSegments() {
+	segments := make([]*BGPAS4PathSegment, 0, len(as.Value))
+	for _, seg := range as.Value {
+		if IsConfedSegment(seg) {
+			as.BGPPathAttrBase.Length -= seg.TotalLen()
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	as.Value = segments
+}
+
 func (as *BGPPathAttrAS4Path) AddASPathSegment(pathSeg *BGPAS4PathSegment) {
 	as.Value = append(as.Value, pathSeg)
 	copy(as.Value[1:], as.Value[0:])
@@ -2313,7 +2646,13 @@ func decodeNLRI(pkt []byte, ipPrefix *[]NLRI, length uint32, afi AFI, safi SAFI,
 	peerAttrs := data.(BGPPeerAttrs)
 
 	for ptr < length {
-		if peerAttrs.AddPathsRxActual {
+		if safi == SafiMPLSVPN {
+			ip = &VPNPrefix{}
+		} else if safi == SafiLabeledUnicast {
+			ip = &LabeledPrefix{}
+		} else if safi == SafiEVPN {
+			ip = &EVPNNLRI{}
+		} else if peerAttrs.AddPathsRxActual || peerAttrs.AddPathsRxFamilies[GetProtocolFamily(afi, safi)] {
 			ip = &ExtNLRI{}
 		} else {
 			ip = &IPPrefix{}
@@ -2464,6 +2803,9 @@ func (msg *BGPMessage) Decode(header *BGPHeader, pkt []byte, data interface{}) e
 	case BGPMsgTypeNotification:
 		msg.Body = &BGPNotification{}
 
+	case BGPMsgTypeRouteRefresh:
+		msg.Body = &BGPRouteRefresh{}
+
 	default:
 		return nil
 	}
@@ -436,3 +436,168 @@ func TestBGPUpdateEncode(t *testing.T) {
 		t.Fatal("Cloned update message is not the same as the original message")
 	}
 }
+
+func TestBGPRouteRefreshEncodeDecode(t *testing.T) {
+	refreshMsg := NewBGPRouteRefreshMessage(AfiIP6, SafiUnicast, BGPRouteRefreshBoRR)
+	pkt, err := refreshMsg.Encode()
+	if err != nil {
+		t.Fatal("BGP route refresh message encode failed with error:", err)
+	}
+	t.Log("BGP route refresh message:", pkt)
+
+	if len(pkt) != BGPMsgHeaderLen+BGPRouteRefreshMsgLen {
+		t.Fatal("BGP route refresh message length expected", BGPMsgHeaderLen+BGPRouteRefreshMsgLen, "got", len(pkt))
+	}
+
+	bgpHeader := NewBGPHeader()
+	err = bgpHeader.Decode(pkt[:BGPMsgHeaderLen])
+	if err != nil {
+		t.Fatal("BGP packet header decode failed with error", err)
+	}
+
+	bgpMessage := NewBGPMessage()
+	err = bgpMessage.Decode(bgpHeader, pkt[BGPMsgHeaderLen:], BGPPeerAttrs{ASSize: 4})
+	if err != nil {
+		t.Fatal("BGP route refresh message decode failed with error:", err)
+	}
+
+	refresh, ok := bgpMessage.Body.(*BGPRouteRefresh)
+	if !ok {
+		t.Fatal("BGP route refresh message decoded as", bgpMessage.Body)
+	}
+	if refresh.AFI != AfiIP6 || refresh.SAFI != SafiUnicast || refresh.SubType != BGPRouteRefreshBoRR {
+		t.Fatal("BGP route refresh message decoded with unexpected values", refresh)
+	}
+
+	err = bgpMessage.Decode(bgpHeader, pkt[BGPMsgHeaderLen:len(pkt)-1], BGPPeerAttrs{ASSize: 4})
+	if err == nil {
+		t.Fatal("BGP route refresh message decode called with bad length... expected failure, got NO error")
+	}
+	if msgErr := err.(BGPMessageError); msgErr.TypeCode != BGPRouteRefreshMsgError ||
+		msgErr.SubTypeCode != BGPInvalidRouteRefreshMsgLen {
+		t.Fatal("BGP route refresh message decode with bad length returned unexpected error:", err)
+	}
+}
+
+func TestBGPRouteRefreshCapability(t *testing.T) {
+	afiSafiMap := map[uint32]bool{GetProtocolFamily(AfiIP, SafiUnicast): true}
+	optParams := ConstructOptParams(65000, afiSafiMap, nil, nil)
+	openMsg := NewBGPOpenMessage(65000, 180, "10.1.1.1", optParams)
+	pkt, err := openMsg.Encode()
+	if err != nil {
+		t.Fatal("BGP open message encode failed with error:", err)
+	}
+
+	bgpHeader := NewBGPHeader()
+	bgpHeader.Decode(pkt[:BGPMsgHeaderLen])
+	bgpMessage := NewBGPMessage()
+	err = bgpMessage.Decode(bgpHeader, pkt[BGPMsgHeaderLen:], BGPPeerAttrs{ASSize: 4})
+	if err != nil {
+		t.Fatal("BGP open message decode failed with error:", err)
+	}
+
+	routeRefresh, enhancedRouteRefresh := GetRouteRefreshCaps(bgpMessage.Body.(*BGPOpen))
+	if !routeRefresh || !enhancedRouteRefresh {
+		t.Fatal("BGP open message expected route refresh and enhanced route refresh capabilities, got",
+			routeRefresh, enhancedRouteRefresh)
+	}
+}
+
+func TestBGPAddPathCapability(t *testing.T) {
+	ipv4Family := GetProtocolFamily(AfiIP, SafiUnicast)
+	ipv6Family := GetProtocolFamily(AfiIP6, SafiUnicast)
+	afiSafiMap := map[uint32]bool{ipv4Family: true, ipv6Family: true}
+	addPathFlags := map[uint32]uint8{ipv4Family: BGPCapAddPathRx | BGPCapAddPathTx}
+	optParams := ConstructOptParams(65000, afiSafiMap, addPathFlags, nil)
+	openMsg := NewBGPOpenMessage(65000, 180, "10.1.1.1", optParams)
+	pkt, err := openMsg.Encode()
+	if err != nil {
+		t.Fatal("BGP open message encode failed with error:", err)
+	}
+
+	bgpHeader := NewBGPHeader()
+	bgpHeader.Decode(pkt[:BGPMsgHeaderLen])
+	bgpMessage := NewBGPMessage()
+	err = bgpMessage.Decode(bgpHeader, pkt[BGPMsgHeaderLen:], BGPPeerAttrs{ASSize: 4})
+	if err != nil {
+		t.Fatal("BGP open message decode failed with error:", err)
+	}
+
+	addPathFamily := GetAddPathFamily(bgpMessage.Body.(*BGPOpen))
+	if flags := addPathFamily[AfiIP][SafiUnicast]; flags != BGPCapAddPathRx|BGPCapAddPathTx {
+		t.Fatal("Add path capability expected rx and tx for IPv4 unicast, got", addPathFamily)
+	}
+	if _, ok := addPathFamily[AfiIP6]; ok {
+		t.Fatal("Add path capability expected no flags for IPv6 unicast, got", addPathFamily)
+	}
+}
+
+func TestBGPGracefulRestartCapability(t *testing.T) {
+	afiSafiMap := map[uint32]bool{
+		GetProtocolFamily(AfiIP, SafiUnicast):  true,
+		GetProtocolFamily(AfiIP6, SafiUnicast): true,
+	}
+	grCap := ConstructGracefulRestartCap(true, 120, afiSafiMap, true)
+	optParams := ConstructOptParams(65000, afiSafiMap, nil, grCap)
+	openMsg := NewBGPOpenMessage(65000, 180, "10.1.1.1", optParams)
+	pkt, err := openMsg.Encode()
+	if err != nil {
+		t.Fatal("BGP open message encode failed with error:", err)
+	}
+
+	bgpHeader := NewBGPHeader()
+	bgpHeader.Decode(pkt[:BGPMsgHeaderLen])
+	bgpMessage := NewBGPMessage()
+	err = bgpMessage.Decode(bgpHeader, pkt[BGPMsgHeaderLen:], BGPPeerAttrs{ASSize: 4})
+	if err != nil {
+		t.Fatal("BGP open message decode failed with error:", err)
+	}
+
+	decodedCap := GetGracefulRestartCap(bgpMessage.Body.(*BGPOpen))
+	if decodedCap == nil {
+		t.Fatal("BGP open message expected graceful restart capability, got none")
+	}
+	if !decodedCap.IsRestarting() || decodedCap.RestartTime != 120 {
+		t.Fatal("Graceful restart capability expected restart state set and restart time 120, got", decodedCap)
+	}
+
+	families := decodedCap.GetForwardingFamilies()
+	for protoFamily, _ := range afiSafiMap {
+		if forwarding, ok := families[protoFamily]; !ok || !forwarding {
+			t.Fatal("Graceful restart capability expected forwarding state for protocol family", protoFamily,
+				"got", families)
+		}
+	}
+}
+
+func TestBGPEndOfRIB(t *testing.T) {
+	protoFamilies := []uint32{
+		GetProtocolFamily(AfiIP, SafiUnicast),
+		GetProtocolFamily(AfiIP6, SafiUnicast),
+	}
+	for _, protoFamily := range protoFamilies {
+		pkt, err := NewBGPEndOfRIBMessage(protoFamily).Encode()
+		if err != nil {
+			t.Fatal("End-of-RIB encode failed for protocol family", protoFamily, "with error:", err)
+		}
+
+		bgpHeader := NewBGPHeader()
+		bgpHeader.Decode(pkt[:BGPMsgHeaderLen])
+		bgpMessage := NewBGPMessage()
+		err = bgpMessage.Decode(bgpHeader, pkt[BGPMsgHeaderLen:], BGPPeerAttrs{ASSize: 4})
+		if err != nil {
+			t.Fatal("End-of-RIB decode failed for protocol family", protoFamily, "with error:", err)
+		}
+
+		eorFamily, ok := GetEndOfRIBProtoFamily(bgpMessage)
+		if !ok || eorFamily != protoFamily {
+			t.Fatal("End-of-RIB expected protocol family", protoFamily, "got", eorFamily, ok)
+		}
+	}
+
+	ipPrefix := ConstructIPPrefix("10.1.1.0", "255.255.255.0")
+	updateMsg := NewBGPUpdateMessage([]NLRI{ipPrefix}, nil, nil)
+	if _, ok := GetEndOfRIBProtoFamily(updateMsg); ok {
+		t.Fatal("UPDATE message with withdrawn routes detected as End-of-RIB")
+	}
+}
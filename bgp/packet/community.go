@@ -0,0 +1,509 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// community.go
+package packet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Well-known communities, RFC 1997
+const (
+	BGPCommunityNoExport          uint32 = 0xFFFFFF01
+	BGPCommunityNoAdvertise       uint32 = 0xFFFFFF02
+	BGPCommunityNoExportSubconfed uint32 = 0xFFFFFF03
+)
+
+var BGPWellKnownCommunityToStrMap = map[uint32]string{
+	BGPCommunityNoExport:          "no-export",
+	BGPCommunityNoAdvertise:       "no-advertise",
+	BGPCommunityNoExportSubconfed: "no-export-subconfed",
+}
+
+// Extended community type (high order octet), RFC 4360 and RFC 5668
+const (
+	BGPExtCommTypeAS2Byte uint8 = 0x00
+	BGPExtCommTypeIPv4    uint8 = 0x01
+	BGPExtCommTypeAS4Byte uint8 = 0x02
+)
+
+// Extended community sub types, RFC 4360
+const (
+	BGPExtCommSubTypeRouteTarget uint8 = 0x02
+	BGPExtCommSubTypeRouteOrigin uint8 = 0x03
+)
+
+var BGPExtCommSubTypeToStrMap = map[uint8]string{
+	BGPExtCommSubTypeRouteTarget: "rt",
+	BGPExtCommSubTypeRouteOrigin: "soo",
+}
+
+var BGPExtCommStrToSubTypeMap = map[string]uint8{
+	"rt":  BGPExtCommSubTypeRouteTarget,
+	"soo": BGPExtCommSubTypeRouteOrigin,
+}
+
+func ParseCommunity(str string) (uint32, error) {
+	str = strings.TrimSpace(str)
+	for community, name := range BGPWellKnownCommunityToStrMap {
+		if strings.EqualFold(str, name) {
+			return community, nil
+		}
+	}
+
+	vals := strings.Split(str, ":")
+	if len(vals) == 1 {
+		community, err := strconv.ParseUint(vals[0], 10, 32)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("Invalid community %s", str))
+		}
+		return uint32(community), nil
+	}
+
+	if len(vals) != 2 {
+		return 0, errors.New(fmt.Sprintf("Invalid community %s, expected format AS:value", str))
+	}
+
+	as, err := strconv.ParseUint(vals[0], 10, 16)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid AS in community %s", str))
+	}
+	val, err := strconv.ParseUint(vals[1], 10, 16)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid value in community %s", str))
+	}
+	return uint32(as)<<16 | uint32(val), nil
+}
+
+func CommunityToStr(community uint32) string {
+	if name, ok := BGPWellKnownCommunityToStrMap[community]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d:%d", community>>16, community&0xFFFF)
+}
+
+func ParseExtCommunity(str string) (uint64, error) {
+	vals := strings.Split(strings.TrimSpace(str), ":")
+	if len(vals) != 3 {
+		return 0, errors.New(fmt.Sprintf("Invalid extended community %s, expected format type:admin:value", str))
+	}
+
+	subType, ok := BGPExtCommStrToSubTypeMap[strings.ToLower(vals[0])]
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("Unsupported extended community type %s", vals[0]))
+	}
+
+	extComm := make([]byte, 8)
+	extComm[1] = subType
+	if ip := net.ParseIP(vals[1]); ip != nil && ip.To4() != nil {
+		val, err := strconv.ParseUint(vals[2], 10, 16)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("Invalid value in extended community %s", str))
+		}
+		extComm[0] = BGPExtCommTypeIPv4
+		copy(extComm[2:6], ip.To4())
+		binary.BigEndian.PutUint16(extComm[6:8], uint16(val))
+		return binary.BigEndian.Uint64(extComm), nil
+	}
+
+	as, err := strconv.ParseUint(vals[1], 10, 32)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid administrator in extended community %s", str))
+	}
+
+	if as > 0xFFFF {
+		val, err := strconv.ParseUint(vals[2], 10, 16)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("Invalid value in extended community %s", str))
+		}
+		extComm[0] = BGPExtCommTypeAS4Byte
+		binary.BigEndian.PutUint32(extComm[2:6], uint32(as))
+		binary.BigEndian.PutUint16(extComm[6:8], uint16(val))
+	} else {
+		val, err := strconv.ParseUint(vals[2], 10, 32)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("Invalid value in extended community %s", str))
+		}
+		extComm[0] = BGPExtCommTypeAS2Byte
+		binary.BigEndian.PutUint16(extComm[2:4], uint16(as))
+		binary.BigEndian.PutUint32(extComm[4:8], uint32(val))
+	}
+	return binary.BigEndian.Uint64(extComm), nil
+}
+
+func ExtCommunityToStr(extComm uint64) string {
+	bytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(bytes, extComm)
+	subTypeStr, ok := BGPExtCommSubTypeToStrMap[bytes[1]]
+	if !ok {
+		return fmt.Sprintf("0x%016x", extComm)
+	}
+
+	switch bytes[0] {
+	case BGPExtCommTypeAS2Byte:
+		return fmt.Sprintf("%s:%d:%d", subTypeStr, binary.BigEndian.Uint16(bytes[2:4]),
+			binary.BigEndian.Uint32(bytes[4:8]))
+	case BGPExtCommTypeIPv4:
+		return fmt.Sprintf("%s:%s:%d", subTypeStr, net.IP(bytes[2:6]).String(), binary.BigEndian.Uint16(bytes[6:8]))
+	case BGPExtCommTypeAS4Byte:
+		return fmt.Sprintf("%s:%d:%d", subTypeStr, binary.BigEndian.Uint32(bytes[2:6]),
+			binary.BigEndian.Uint16(bytes[6:8]))
+	}
+	return fmt.Sprintf("0x%016x", extComm)
+}
+
+type BGPLargeCommunity struct {
+	GlobalAdmin uint32
+	LocalData1  uint32
+	LocalData2  uint32
+}
+
+func (l BGPLargeCommunity) String() string {
+	return fmt.Sprintf("%d:%d:%d", l.GlobalAdmin, l.LocalData1, l.LocalData2)
+}
+
+func ParseLargeCommunity(str string) (BGPLargeCommunity, error) {
+	var largeComm BGPLargeCommunity
+	vals := strings.Split(strings.TrimSpace(str), ":")
+	if len(vals) != 3 {
+		return largeComm, errors.New(fmt.Sprintf("Invalid large community %s, expected format AS:value:value", str))
+	}
+
+	nums := make([]uint32, 3)
+	for idx, val := range vals {
+		num, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return largeComm, errors.New(fmt.Sprintf("Invalid large community %s", str))
+		}
+		nums[idx] = uint32(num)
+	}
+
+	largeComm.GlobalAdmin = nums[0]
+	largeComm.LocalData1 = nums[1]
+	largeComm.LocalData2 = nums[2]
+	return largeComm, nil
+}
+
+type BGPPathAttrCommunities struct {
+	BGPPathAttrBase
+	Value []uint32
+}
+
+func (c *BGPPathAttrCommunities) Clone() BGPPathAttr {
+	x := *c
+	x.BGPPathAttrBase = c.BGPPathAttrBase.Clone()
+	x.Value = make([]uint32, len(c.Value))
+	copy(x.Value, c.Value)
+	return &x
+}
+
+func (c *BGPPathAttrCommunities) Encode() ([]byte, error) {
+	pkt, err := c.BGPPathAttrBase.Encode()
+	if err != nil {
+		return pkt, err
+	}
+
+	idx := c.BGPPathAttrBase.BGPPathAttrLen
+	for _, community := range c.Value {
+		binary.BigEndian.PutUint32(pkt[idx:], community)
+		idx += 4
+	}
+	return pkt, nil
+}
+
+func (c *BGPPathAttrCommunities) Decode(pkt []byte, data interface{}) error {
+	err := c.BGPPathAttrBase.Decode(pkt, data)
+	if err != nil {
+		return err
+	}
+
+	if c.Length%4 != 0 {
+		return BGPMessageError{BGPUpdateMsgError, BGPOptionalAttrError, pkt[:c.TotalLen()],
+			fmt.Sprintf("COMMUNITIES length %d is not a multiple of 4", c.Length)}
+	}
+
+	c.Value = make([]uint32, c.Length/4)
+	idx := c.BGPPathAttrLen
+	for i := 0; i < len(c.Value); i++ {
+		c.Value[i] = binary.BigEndian.Uint32(pkt[idx : idx+4])
+		idx += 4
+	}
+	return nil
+}
+
+func (c *BGPPathAttrCommunities) New() BGPPathAttr {
+	return &BGPPathAttrCommunities{}
+}
+
+func (c *BGPPathAttrCommunities) String() string {
+	strList := make([]string, 0, len(c.Value))
+	for _, community := range c.Value {
+		strList = append(strList, CommunityToStr(community))
+	}
+	return fmt.Sprintf("{COMMUNITIES %s}", strings.Join(strList, " "))
+}
+
+func (c *BGPPathAttrCommunities) HasCommunity(community uint32) bool {
+	for _, val := range c.Value {
+		if val == community {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *BGPPathAttrCommunities) AddCommunity(community uint32) {
+	if c.HasCommunity(community) {
+		return
+	}
+	c.Value = append(c.Value, community)
+	c.setLength(uint16(len(c.Value) * 4))
+}
+
+func (c *BGPPathAttrCommunities) RemoveCommunity(community uint32) {
+	for idx, val := range c.Value {
+		if val == community {
+			c.Value = append(c.Value[:idx], c.Value[idx+1:]...)
+			c.setLength(uint16(len(c.Value) * 4))
+			return
+		}
+	}
+}
+
+func NewBGPPathAttrCommunities() *BGPPathAttrCommunities {
+	return &BGPPathAttrCommunities{
+		BGPPathAttrBase: BGPPathAttrBase{
+			Flags:          BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive,
+			Code:           BGPPathAttrTypeCommunities,
+			Length:         0,
+			BGPPathAttrLen: 3,
+		},
+		Value: make([]uint32, 0),
+	}
+}
+
+type BGPPathAttrExtCommunities struct {
+	BGPPathAttrBase
+	Value []uint64
+}
+
+func (e *BGPPathAttrExtCommunities) Clone() BGPPathAttr {
+	x := *e
+	x.BGPPathAttrBase = e.BGPPathAttrBase.Clone()
+	x.Value = make([]uint64, len(e.Value))
+	copy(x.Value, e.Value)
+	return &x
+}
+
+func (e *BGPPathAttrExtCommunities) Encode() ([]byte, error) {
+	pkt, err := e.BGPPathAttrBase.Encode()
+	if err != nil {
+		return pkt, err
+	}
+
+	idx := e.BGPPathAttrBase.BGPPathAttrLen
+	for _, extComm := range e.Value {
+		binary.BigEndian.PutUint64(pkt[idx:], extComm)
+		idx += 8
+	}
+	return pkt, nil
+}
+
+func (e *BGPPathAttrExtCommunities) Decode(pkt []byte, data interface{}) error {
+	err := e.BGPPathAttrBase.Decode(pkt, data)
+	if err != nil {
+		return err
+	}
+
+	if e.Length%8 != 0 {
+		return BGPMessageError{BGPUpdateMsgError, BGPOptionalAttrError, pkt[:e.TotalLen()],
+			fmt.Sprintf("EXTENDED COMMUNITIES length %d is not a multiple of 8", e.Length)}
+	}
+
+	e.Value = make([]uint64, e.Length/8)
+	idx := e.BGPPathAttrLen
+	for i := 0; i < len(e.Value); i++ {
+		e.Value[i] = binary.BigEndian.Uint64(pkt[idx : idx+8])
+		idx += 8
+	}
+	return nil
+}
+
+func (e *BGPPathAttrExtCommunities) New() BGPPathAttr {
+	return &BGPPathAttrExtCommunities{}
+}
+
+func (e *BGPPathAttrExtCommunities) String() string {
+	strList := make([]string, 0, len(e.Value))
+	for _, extComm := range e.Value {
+		strList = append(strList, ExtCommunityToStr(extComm))
+	}
+	return fmt.Sprintf("{EXTENDED COMMUNITIES %s}", strings.Join(strList, " "))
+}
+
+func (e *BGPPathAttrExtCommunities) HasExtCommunity(extComm uint64) bool {
+	for _, val := range e.Value {
+		if val == extComm {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *BGPPathAttrExtCommunities) AddExtCommunity(extComm uint64) {
+	if e.HasExtCommunity(extComm) {
+		return
+	}
+	e.Value = append(e.Value, extComm)
+	e.setLength(uint16(len(e.Value) * 8))
+}
+
+func (e *BGPPathAttrExtCommunities) RemoveExtCommunity(extComm uint64) {
+	for idx, val := range e.Value {
+		if val == extComm {
+			e.Value = append(e.Value[:idx], e.Value[idx+1:]...)
+			e.setLength(uint16(len(e.Value) * 8))
+			return
+		}
+	}
+}
+
+func NewBGPPathAttrExtCommunities() *BGPPathAttrExtCommunities {
+	return &BGPPathAttrExtCommunities{
+		BGPPathAttrBase: BGPPathAttrBase{
+			Flags:          BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive,
+			Code:           BGPPathAttrTypeExtCommunities,
+			Length:         0,
+			BGPPathAttrLen: 3,
+		},
+		Value: make([]uint64, 0),
+	}
+}
+
+type BGPPathAttrLargeCommunities struct {
+	BGPPathAttrBase
+	Value []BGPLargeCommunity
+}
+
+func (l *BGPPathAttrLargeCommunities) Clone() BGPPathAttr {
+	x := *l
+	x.BGPPathAttrBase = l.BGPPathAttrBase.Clone()
+	x.Value = make([]BGPLargeCommunity, len(l.Value))
+	copy(x.Value, l.Value)
+	return &x
+}
+
+func (l *BGPPathAttrLargeCommunities) Encode() ([]byte, error) {
+	pkt, err := l.BGPPathAttrBase.Encode()
+	if err != nil {
+		return pkt, err
+	}
+
+	idx := l.BGPPathAttrBase.BGPPathAttrLen
+	for _, largeComm := range l.Value {
+		binary.BigEndian.PutUint32(pkt[idx:], largeComm.GlobalAdmin)
+		binary.BigEndian.PutUint32(pkt[idx+4:], largeComm.LocalData1)
+		binary.BigEndian.PutUint32(pkt[idx+8:], largeComm.LocalData2)
+		idx += 12
+	}
+	return pkt, nil
+}
+
+func (l *BGPPathAttrLargeCommunities) Decode(pkt []byte, data interface{}) error {
+	err := l.BGPPathAttrBase.Decode(pkt, data)
+	if err != nil {
+		return err
+	}
+
+	if l.Length%12 != 0 {
+		return BGPMessageError{BGPUpdateMsgError, BGPOptionalAttrError, pkt[:l.TotalLen()],
+			fmt.Sprintf("LARGE COMMUNITIES length %d is not a multiple of 12", l.Length)}
+	}
+
+	l.Value = make([]BGPLargeCommunity, l.Length/12)
+	idx := l.BGPPathAttrLen
+	for i := 0; i < len(l.Value); i++ {
+		l.Value[i].GlobalAdmin = binary.BigEndian.Uint32(pkt[idx : idx+4])
+		l.Value[i].LocalData1 = binary.BigEndian.Uint32(pkt[idx+4 : idx+8])
+		l.Value[i].LocalData2 = binary.BigEndian.Uint32(pkt[idx+8 : idx+12])
+		idx += 12
+	}
+	return nil
+}
+
+func (l *BGPPathAttrLargeCommunities) New() BGPPathAttr {
+	return &BGPPathAttrLargeCommunities{}
+}
+
+func (l *BGPPathAttrLargeCommunities) String() string {
+	strList := make([]string, 0, len(l.Value))
+	for _, largeComm := range l.Value {
+		strList = append(strList, largeComm.String())
+	}
+	return fmt.Sprintf("{LARGE COMMUNITIES %s}", strings.Join(strList, " "))
+}
+
+func (l *BGPPathAttrLargeCommunities) HasLargeCommunity(largeComm BGPLargeCommunity) bool {
+	for _, val := range l.Value {
+		if val == largeComm {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *BGPPathAttrLargeCommunities) AddLargeCommunity(largeComm BGPLargeCommunity) {
+	if l.HasLargeCommunity(largeComm) {
+		return
+	}
+	l.Value = append(l.Value, largeComm)
+	l.setLength(uint16(len(l.Value) * 12))
+}
+
+func (l *BGPPathAttrLargeCommunities) RemoveLargeCommunity(largeComm BGPLargeCommunity) {
+	for idx, val := range l.Value {
+		if val == largeComm {
+			l.Value = append(l.Value[:idx], l.Value[idx+1:]...)
+			l.setLength(uint16(len(l.Value) * 12))
+			return
+		}
+	}
+}
+
+func NewBGPPathAttrLargeCommunities() *BGPPathAttrLargeCommunities {
+	return &BGPPathAttrLargeCommunities{
+		BGPPathAttrBase: BGPPathAttrBase{
+			Flags:          BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive,
+			Code:           BGPPathAttrTypeLargeCommunities,
+			Length:         0,
+			BGPPathAttrLen: 3,
+		},
+		Value: make([]BGPLargeCommunity, 0),
+	}
+}
@@ -0,0 +1,184 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// community_test.go
+package packet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestParseCommunity(t *testing.T) {
+	strs := []string{"65000:100", "no-export", "NO-ADVERTISE", "no-export-subconfed", "4259840100"}
+	expected := []uint32{0xFDE80064, BGPCommunityNoExport, BGPCommunityNoAdvertise, BGPCommunityNoExportSubconfed,
+		0xFDE80064}
+	for idx, str := range strs {
+		community, err := ParseCommunity(str)
+		if err != nil {
+			t.Fatal("ParseCommunity failed for", str, "with error:", err)
+		}
+		if community != expected[idx] {
+			t.Fatalf("ParseCommunity for %s, expected 0x%x, got 0x%x", str, expected[idx], community)
+		}
+	}
+
+	badStrs := []string{"65536:1", "1:65536", "1:2:3", "no-export-all", ""}
+	for _, str := range badStrs {
+		_, err := ParseCommunity(str)
+		if err == nil {
+			t.Error("ParseCommunity for", str, "expected failure, got NO error")
+		}
+	}
+
+	if CommunityToStr(0xFDE80064) != "65000:100" || CommunityToStr(BGPCommunityNoExport) != "no-export" {
+		t.Error("CommunityToStr did not return the expected strings")
+	}
+}
+
+func TestParseExtCommunity(t *testing.T) {
+	strs := []string{"rt:65000:100", "soo:10.1.1.1:200", "rt:4200000000:300"}
+	for _, str := range strs {
+		extComm, err := ParseExtCommunity(str)
+		if err != nil {
+			t.Fatal("ParseExtCommunity failed for", str, "with error:", err)
+		}
+		if ExtCommunityToStr(extComm) != str {
+			t.Fatal("ExtCommunityToStr for", str, "returned", ExtCommunityToStr(extComm))
+		}
+	}
+
+	badStrs := []string{"rt:65000", "xx:65000:100", "soo:10.1.1.1:65536", "rt:4200000000:65536"}
+	for _, str := range badStrs {
+		_, err := ParseExtCommunity(str)
+		if err == nil {
+			t.Error("ParseExtCommunity for", str, "expected failure, got NO error")
+		}
+	}
+}
+
+func TestParseLargeCommunity(t *testing.T) {
+	largeComm, err := ParseLargeCommunity("4200000000:1:2")
+	if err != nil {
+		t.Fatal("ParseLargeCommunity failed with error:", err)
+	}
+	if largeComm != (BGPLargeCommunity{4200000000, 1, 2}) || largeComm.String() != "4200000000:1:2" {
+		t.Fatal("ParseLargeCommunity returned unexpected value", largeComm)
+	}
+
+	if _, err = ParseLargeCommunity("1:2"); err == nil {
+		t.Error("ParseLargeCommunity for 1:2 expected failure, got NO error")
+	}
+}
+
+func TestCommunitiesDecode(t *testing.T) {
+	packets := []string{
+		// COMMUNITIES - 65000:100, NO_EXPORT
+		"C00808FDE80064FFFFFF01",
+		// EXTENDED COMMUNITIES - rt:65000:100
+		"C010080002FDE800000064",
+		// LARGE COMMUNITIES - 4200000000:1:2
+		"C0200CFA56EA000000000100000002",
+	}
+
+	for _, strPkt := range packets {
+		hexPkt, err := hex.DecodeString(strPkt)
+		fmt.Printf("packet = %x, len = %d\n", hexPkt, len(hexPkt))
+		if err != nil {
+			t.Fatal("Failed to decode the string to hex, string =", strPkt)
+		}
+
+		pa := BGPGetPathAttr(hexPkt)
+		err = pa.Decode(hexPkt, BGPPeerAttrs{ASSize: 4})
+		if err != nil {
+			t.Fatal("Path attr decode failed for packet", strPkt, "with error:", err)
+		}
+		t.Log("Decoded path attr:", pa)
+
+		pkt, err := pa.Encode()
+		if err != nil {
+			t.Fatal("Path attr encode failed for packet", strPkt, "with error:", err)
+		}
+
+		if !bytes.Equal(pkt, hexPkt) {
+			t.Fatalf("Encoded path attr %x is not the same as the decoded packet %x", pkt, hexPkt)
+		}
+	}
+
+	attrs := []BGPPathAttr{}
+	attrs = SetCommunities(attrs, []uint32{BGPCommunityNoAdvertise})
+	if !HasCommunity(attrs, BGPCommunityNoAdvertise) || HasCommunity(attrs, BGPCommunityNoExport) {
+		t.Fatal("SetCommunities did not set the expected communities, path attrs:", attrs)
+	}
+	attrs = SetCommunities(attrs, nil)
+	if len(attrs) != 0 {
+		t.Fatal("SetCommunities with empty list did not remove the attribute, path attrs:", attrs)
+	}
+}
+
+func TestCommunitiesBadLength(t *testing.T) {
+	packets := []string{
+		"C00806FDE80064FFFF",
+		"C0100400020001",
+		"C02008FA56EA0000000001",
+	}
+
+	for _, strPkt := range packets {
+		hexPkt, err := hex.DecodeString(strPkt)
+		if err != nil {
+			t.Fatal("Failed to decode the string to hex, string =", strPkt)
+		}
+
+		pa := BGPGetPathAttr(hexPkt)
+		err = pa.Decode(hexPkt, BGPPeerAttrs{ASSize: 4})
+		if err == nil {
+			t.Error("Path attr decode for packet", strPkt, "expected failure, got NO error")
+		} else {
+			t.Log("Path attr decode for packet", strPkt, "expected failure, error:", err)
+		}
+	}
+}
+
+func TestCommunitiesExtendedLength(t *testing.T) {
+	communities := NewBGPPathAttrCommunities()
+	for i := 0; i < 100; i++ {
+		communities.AddCommunity(uint32(65000)<<16 | uint32(i))
+	}
+
+	pkt, err := communities.Encode()
+	if err != nil {
+		t.Fatal("COMMUNITIES encode failed with error:", err)
+	}
+
+	decoded := BGPGetPathAttr(pkt)
+	err = decoded.Decode(pkt, BGPPeerAttrs{ASSize: 4})
+	if err != nil {
+		t.Fatal("COMMUNITIES decode failed with error:", err)
+	}
+
+	if len(decoded.(*BGPPathAttrCommunities).Value) != 100 {
+		t.Fatal("Decoded COMMUNITIES expected 100 values, got", len(decoded.(*BGPPathAttrCommunities).Value))
+	}
+}
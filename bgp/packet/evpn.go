@@ -0,0 +1,455 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// evpn.go
+package packet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// EVPN route types, RFC 7432 section 7
+const (
+	BGPEVPNRouteTypeMACIPAdvertisement uint8 = 2
+	BGPEVPNRouteTypeInclusiveMulticast uint8 = 3
+)
+
+const (
+	BGPEVPNESILen         = 10
+	BGPEVPNEthernetTagLen = 4
+	BGPEVPNMACLen         = 6
+)
+
+// Tunnel types of the encapsulation extended community and the PMSI tunnel attribute, RFC 5512, RFC 6514
+// and RFC 8365
+const (
+	BGPEncapTunnelTypeVXLAN             uint16 = 8
+	BGPPMSITunnelTypeIngressReplication uint8  = 6
+)
+
+// Opaque extended community carrying the tunnel encapsulation, RFC 5512 section 4.5
+const (
+	BGPExtCommTypeOpaque           uint8 = 0x03
+	BGPExtCommSubTypeEncapsulation uint8 = 0x0c
+)
+
+func IsEVPNFamily(protoFamily uint32) bool {
+	afi, safi := GetAfiSafi(protoFamily)
+	return afi == AfiL2VPN && safi == SafiEVPN
+}
+
+func encodeEVPNIP(ip net.IP) []byte {
+	if ip == nil {
+		return []byte{0}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return append([]byte{uint8(net.IPv4len * 8)}, ip4...)
+	}
+	return append([]byte{uint8(net.IPv6len * 8)}, ip.To16()...)
+}
+
+func decodeEVPNIP(pkt []byte) (net.IP, int, error) {
+	if len(pkt) < 1 {
+		return nil, 0, BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+			"EVPN NLRI does not contain IP address length"}
+	}
+
+	switch pkt[0] {
+	case 0:
+		return nil, 1, nil
+	case net.IPv4len * 8:
+		if len(pkt) < 1+net.IPv4len {
+			break
+		}
+		return net.IPv4(pkt[1], pkt[2], pkt[3], pkt[4]), 1 + net.IPv4len, nil
+	case net.IPv6len * 8:
+		if len(pkt) < 1+net.IPv6len {
+			break
+		}
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, pkt[1:1+net.IPv6len])
+		return ip, 1 + net.IPv6len, nil
+	}
+	return nil, 0, BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+		fmt.Sprintf("EVPN NLRI IP address length %d is invalid", pkt[0])}
+}
+
+// EVPNNLRI is the EVPN NLRI as defined in RFC 7432. Only the MAC/IP advertisement and the inclusive multicast
+// Ethernet tag routes are parsed, the value of the other route types is kept as is. The labels of the
+// VXLAN encapsulated routes carry the 24 bit VNI, RFC 8365 section 5.1.3.
+type EVPNNLRI struct {
+	RouteType   uint8
+	RD          RouteDistinguisher
+	ESI         [BGPEVPNESILen]byte
+	EthernetTag uint32
+	MAC         net.HardwareAddr
+	IP          net.IP
+	Labels      []uint32
+	Value       []byte
+}
+
+func (e *EVPNNLRI) Clone() NLRI {
+	x := *e
+	x.MAC = make(net.HardwareAddr, len(e.MAC))
+	copy(x.MAC, e.MAC)
+	if e.IP != nil {
+		x.IP = make(net.IP, len(e.IP))
+		copy(x.IP, e.IP)
+	}
+	x.Labels = make([]uint32, len(e.Labels))
+	copy(x.Labels, e.Labels)
+	x.Value = make([]byte, len(e.Value))
+	copy(x.Value, e.Value)
+	return &x
+}
+
+func (e *EVPNNLRI) encodeValue() []byte {
+	tag := make([]byte, BGPEVPNEthernetTagLen)
+	binary.BigEndian.PutUint32(tag, e.EthernetTag)
+
+	switch e.RouteType {
+	case BGPEVPNRouteTypeMACIPAdvertisement:
+		pkt := make([]byte, 0, BGPRouteDistinguisherLen+BGPEVPNESILen+BGPEVPNEthernetTagLen+BGPEVPNMACLen+
+			net.IPv6len+(2*BGPLabelLen)+3)
+		pkt = append(pkt, e.RD[:]...)
+		pkt = append(pkt, e.ESI[:]...)
+		pkt = append(pkt, tag...)
+		mac := make([]byte, BGPEVPNMACLen)
+		copy(mac, e.MAC)
+		pkt = append(pkt, uint8(BGPEVPNMACLen*8))
+		pkt = append(pkt, mac...)
+		pkt = append(pkt, encodeEVPNIP(e.IP)...)
+		labels := e.Labels
+		if len(labels) == 0 {
+			labels = []uint32{0}
+		}
+		for _, label := range labels {
+			pkt = append(pkt, uint8(label>>16), uint8(label>>8), uint8(label))
+		}
+		return pkt
+
+	case BGPEVPNRouteTypeInclusiveMulticast:
+		pkt := make([]byte, 0, BGPRouteDistinguisherLen+BGPEVPNEthernetTagLen+net.IPv6len+1)
+		pkt = append(pkt, e.RD[:]...)
+		pkt = append(pkt, tag...)
+		pkt = append(pkt, encodeEVPNIP(e.IP)...)
+		return pkt
+	}
+	return e.Value
+}
+
+func (e *EVPNNLRI) Encode(afi AFI) ([]byte, error) {
+	value := e.encodeValue()
+	if len(value) > 255 {
+		return nil, errors.New(fmt.Sprintf("EVPN NLRI route type %d length %d is too long", e.RouteType,
+			len(value)))
+	}
+
+	pkt := make([]byte, 2, 2+len(value))
+	pkt[0] = e.RouteType
+	pkt[1] = uint8(len(value))
+	pkt = append(pkt, value...)
+	return pkt, nil
+}
+
+func (e *EVPNNLRI) decodeMACIPAdvertisement(pkt []byte) error {
+	minLen := BGPRouteDistinguisherLen + BGPEVPNESILen + BGPEVPNEthernetTagLen + 1 + BGPEVPNMACLen
+	if len(pkt) < minLen {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+			"EVPN MAC/IP advertisement route is too short"}
+	}
+
+	idx := 0
+	copy(e.RD[:], pkt[idx:idx+BGPRouteDistinguisherLen])
+	idx += BGPRouteDistinguisherLen
+	copy(e.ESI[:], pkt[idx:idx+BGPEVPNESILen])
+	idx += BGPEVPNESILen
+	e.EthernetTag = binary.BigEndian.Uint32(pkt[idx : idx+BGPEVPNEthernetTagLen])
+	idx += BGPEVPNEthernetTagLen
+	if pkt[idx] != BGPEVPNMACLen*8 {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+			fmt.Sprintf("EVPN MAC/IP advertisement route MAC address length %d is invalid", pkt[idx])}
+	}
+	idx++
+	e.MAC = make(net.HardwareAddr, BGPEVPNMACLen)
+	copy(e.MAC, pkt[idx:idx+BGPEVPNMACLen])
+	idx += BGPEVPNMACLen
+
+	ip, ipLen, err := decodeEVPNIP(pkt[idx:])
+	if err != nil {
+		return err
+	}
+	e.IP = ip
+	idx += ipLen
+
+	labelsLen := len(pkt) - idx
+	if labelsLen != BGPLabelLen && labelsLen != 2*BGPLabelLen {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+			fmt.Sprintf("EVPN MAC/IP advertisement route labels length %d is invalid", labelsLen)}
+	}
+	e.Labels = make([]uint32, 0, labelsLen/BGPLabelLen)
+	for ; idx < len(pkt); idx += BGPLabelLen {
+		e.Labels = append(e.Labels, uint32(pkt[idx])<<16|uint32(pkt[idx+1])<<8|uint32(pkt[idx+2]))
+	}
+	return nil
+}
+
+func (e *EVPNNLRI) decodeInclusiveMulticast(pkt []byte) error {
+	minLen := BGPRouteDistinguisherLen + BGPEVPNEthernetTagLen + 1
+	if len(pkt) < minLen {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+			"EVPN inclusive multicast route is too short"}
+	}
+
+	copy(e.RD[:], pkt[:BGPRouteDistinguisherLen])
+	e.EthernetTag = binary.BigEndian.Uint32(pkt[BGPRouteDistinguisherLen:])
+	ip, ipLen, err := decodeEVPNIP(pkt[BGPRouteDistinguisherLen+BGPEVPNEthernetTagLen:])
+	if err != nil {
+		return err
+	}
+	if ip == nil || minLen-1+ipLen != len(pkt) {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+			"EVPN inclusive multicast route originating router IP is invalid"}
+	}
+	e.IP = ip
+	return nil
+}
+
+func (e *EVPNNLRI) Decode(pkt []byte, afi AFI) error {
+	if len(pkt) < 2 || len(pkt) < int(pkt[1])+2 {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil, "EVPN NLRI is too short"}
+	}
+
+	e.RouteType = pkt[0]
+	value := pkt[2 : 2+int(pkt[1])]
+	e.Value = make([]byte, len(value))
+	copy(e.Value, value)
+	switch e.RouteType {
+	case BGPEVPNRouteTypeMACIPAdvertisement:
+		return e.decodeMACIPAdvertisement(value)
+	case BGPEVPNRouteTypeInclusiveMulticast:
+		return e.decodeInclusiveMulticast(value)
+	}
+	return nil
+}
+
+func (e *EVPNNLRI) Len() uint32 {
+	return uint32(len(e.encodeValue()) + 2)
+}
+
+func (e *EVPNNLRI) GetIPPrefix() *IPPrefix {
+	return NewIPPrefix(e.IP, e.GetLength())
+}
+
+// GetPrefix returns the IP address of the MAC/IP advertisement route or the originating router IP of the
+// inclusive multicast route.
+func (e *EVPNNLRI) GetPrefix() net.IP {
+	return e.IP
+}
+
+func (e *EVPNNLRI) GetLength() uint8 {
+	if e.IP == nil {
+		return 0
+	} else if e.IP.To4() != nil {
+		return net.IPv4len * 8
+	}
+	return net.IPv6len * 8
+}
+
+func (e *EVPNNLRI) GetPathId() uint32 {
+	return 0
+}
+
+func (e *EVPNNLRI) ipStr() string {
+	if e.IP == nil {
+		return ""
+	}
+	return e.IP.String()
+}
+
+// GetCIDR returns the route key. The key of the MAC/IP advertisement route is the route distinguisher, the
+// Ethernet tag, the MAC and the IP address. The ESI and the labels are not part of the key, RFC 7432
+// section 7.2.
+func (e *EVPNNLRI) GetCIDR() string {
+	switch e.RouteType {
+	case BGPEVPNRouteTypeMACIPAdvertisement:
+		return fmt.Sprintf("[%d]:[%s]:[%d]:[%s]:[%s]", e.RouteType, e.RD, e.EthernetTag, e.MAC, e.ipStr())
+	case BGPEVPNRouteTypeInclusiveMulticast:
+		return fmt.Sprintf("[%d]:[%s]:[%d]:[%s]", e.RouteType, e.RD, e.EthernetTag, e.ipStr())
+	}
+	return fmt.Sprintf("[%d]:[%s]", e.RouteType, hex.EncodeToString(e.Value))
+}
+
+func (e *EVPNNLRI) String() string {
+	return "{" + e.GetCIDR() + " " + fmt.Sprint(e.Labels) + "}"
+}
+
+// GetVNI returns the VNI of the MAC/IP advertisement route.
+func (e *EVPNNLRI) GetVNI() uint32 {
+	if len(e.Labels) == 0 {
+		return 0
+	}
+	return e.Labels[0]
+}
+
+func NewEVPNMACIPAdvertisementRoute(rd RouteDistinguisher, ethernetTag uint32, mac net.HardwareAddr, ip net.IP,
+	vni uint32) *EVPNNLRI {
+	return &EVPNNLRI{
+		RouteType:   BGPEVPNRouteTypeMACIPAdvertisement,
+		RD:          rd,
+		EthernetTag: ethernetTag,
+		MAC:         mac,
+		IP:          ip,
+		Labels:      []uint32{vni},
+	}
+}
+
+func NewEVPNInclusiveMulticastRoute(rd RouteDistinguisher, ethernetTag uint32, originatorIP net.IP) *EVPNNLRI {
+	return &EVPNNLRI{
+		RouteType:   BGPEVPNRouteTypeInclusiveMulticast,
+		RD:          rd,
+		EthernetTag: ethernetTag,
+		IP:          originatorIP,
+	}
+}
+
+// BGPPathAttrPMSITunnel is the P-Multicast Service Interface tunnel attribute, RFC 6514 section 5. The EVPN
+// inclusive multicast routes use ingress replication with the VNI in the label, RFC 8365 section 5.1.3.
+type BGPPathAttrPMSITunnel struct {
+	BGPPathAttrBase
+	TunnelFlags uint8
+	TunnelType  uint8
+	Label       uint32
+	TunnelId    net.IP
+}
+
+func (p *BGPPathAttrPMSITunnel) Clone() BGPPathAttr {
+	x := *p
+	x.BGPPathAttrBase = p.BGPPathAttrBase.Clone()
+	x.TunnelId = make(net.IP, len(p.TunnelId))
+	copy(x.TunnelId, p.TunnelId)
+	return &x
+}
+
+func (p *BGPPathAttrPMSITunnel) tunnelIdBytes() []byte {
+	if ip4 := p.TunnelId.To4(); ip4 != nil {
+		return ip4
+	}
+	return p.TunnelId.To16()
+}
+
+func (p *BGPPathAttrPMSITunnel) Encode() ([]byte, error) {
+	pkt, err := p.BGPPathAttrBase.Encode()
+	if err != nil {
+		return pkt, err
+	}
+
+	idx := p.BGPPathAttrBase.BGPPathAttrLen
+	pkt[idx] = p.TunnelFlags
+	pkt[idx+1] = p.TunnelType
+	pkt[idx+2] = uint8(p.Label >> 16)
+	pkt[idx+3] = uint8(p.Label >> 8)
+	pkt[idx+4] = uint8(p.Label)
+	copy(pkt[idx+5:], p.tunnelIdBytes())
+	return pkt, nil
+}
+
+func (p *BGPPathAttrPMSITunnel) Decode(pkt []byte, data interface{}) error {
+	err := p.BGPPathAttrBase.Decode(pkt, data)
+	if err != nil {
+		return err
+	}
+
+	if len(pkt) < int(p.BGPPathAttrLen)+int(p.Length) {
+		return BGPMessageError{BGPUpdateMsgError, BGPOptionalAttrError, nil,
+			fmt.Sprintf("Not enough data to decode PMSI TUNNEL, length %d", p.Length)}
+	}
+	if p.Length < 5 {
+		return BGPMessageError{BGPUpdateMsgError, BGPOptionalAttrError, pkt[:p.TotalLen()],
+			fmt.Sprintf("PMSI TUNNEL length %d is less than 5", p.Length)}
+	}
+
+	idx := p.BGPPathAttrLen
+	p.TunnelFlags = pkt[idx]
+	p.TunnelType = pkt[idx+1]
+	p.Label = uint32(pkt[idx+2])<<16 | uint32(pkt[idx+3])<<8 | uint32(pkt[idx+4])
+	tunnelId := pkt[idx+5 : idx+p.Length]
+	if len(tunnelId) == net.IPv4len {
+		p.TunnelId = net.IPv4(tunnelId[0], tunnelId[1], tunnelId[2], tunnelId[3])
+	} else {
+		p.TunnelId = make(net.IP, len(tunnelId))
+		copy(p.TunnelId, tunnelId)
+	}
+	return nil
+}
+
+func (p *BGPPathAttrPMSITunnel) New() BGPPathAttr {
+	return &BGPPathAttrPMSITunnel{}
+}
+
+func (p *BGPPathAttrPMSITunnel) String() string {
+	return fmt.Sprintf("{PMSI TUNNEL type %d label %d id %s}", p.TunnelType, p.Label, p.TunnelId)
+}
+
+func NewBGPPathAttrPMSITunnel(tunnelType uint8, label uint32, tunnelId net.IP) *BGPPathAttrPMSITunnel {
+	pmsi := &BGPPathAttrPMSITunnel{
+		BGPPathAttrBase: BGPPathAttrBase{
+			Flags:          BGPPathAttrFlagOptional | BGPPathAttrFlagTransitive,
+			Code:           BGPPathAttrTypePMSITunnel,
+			BGPPathAttrLen: 3,
+		},
+		TunnelType: tunnelType,
+		Label:      label,
+		TunnelId:   tunnelId,
+	}
+	pmsi.setLength(uint16(5 + len(pmsi.tunnelIdBytes())))
+	return pmsi
+}
+
+func GetPMSITunnel(pathAttrs []BGPPathAttr) *BGPPathAttrPMSITunnel {
+	if attr := getTypeFromPathAttrs(pathAttrs, BGPPathAttrTypePMSITunnel); attr != nil {
+		return attr.(*BGPPathAttrPMSITunnel)
+	}
+	return nil
+}
+
+// NewEncapExtCommunity returns the encapsulation extended community with the tunnel type.
+func NewEncapExtCommunity(tunnelType uint16) uint64 {
+	return uint64(BGPExtCommTypeOpaque)<<56 | uint64(BGPExtCommSubTypeEncapsulation)<<48 | uint64(tunnelType)
+}
+
+// HasEncapTunnelType returns true if the path attrs have the encapsulation extended community with the
+// tunnel type.
+func HasEncapTunnelType(pathAttrs []BGPPathAttr, tunnelType uint16) bool {
+	encap := NewEncapExtCommunity(tunnelType)
+	for _, extComm := range GetExtCommunities(pathAttrs) {
+		if extComm == encap {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// evpn_test.go
+package packet
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestEVPNNLRIEncodeDecode(t *testing.T) {
+	rd, _ := ParseRouteDistinguisher("10.1.1.1:100")
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	nlris := []*EVPNNLRI{
+		NewEVPNMACIPAdvertisementRoute(rd, 0, mac, net.ParseIP("20.1.1.1"), 10100),
+		NewEVPNMACIPAdvertisementRoute(rd, 0, mac, nil, 10100),
+		NewEVPNInclusiveMulticastRoute(rd, 0, net.ParseIP("1.1.1.1")),
+		NewEVPNInclusiveMulticastRoute(rd, 0, net.ParseIP("2001:db8::1")),
+	}
+	for _, nlri := range nlris {
+		pkt, err := nlri.Encode(AfiL2VPN)
+		if err != nil {
+			t.Fatal("EVPN NLRI encode failed with error:", err)
+		}
+		if uint32(len(pkt)) != nlri.Len() {
+			t.Fatal("EVPN NLRI encoded length", len(pkt), "expected", nlri.Len())
+		}
+
+		newNLRI := &EVPNNLRI{}
+		err = newNLRI.Decode(pkt, AfiL2VPN)
+		if err != nil {
+			t.Fatal("EVPN NLRI decode failed with error:", err)
+		}
+		if newNLRI.GetCIDR() != nlri.GetCIDR() || newNLRI.GetVNI() != nlri.GetVNI() {
+			t.Fatal("EVPN NLRI decode expected", nlri, "got", newNLRI)
+		}
+
+		if err = newNLRI.Decode(pkt[:len(pkt)-1], AfiL2VPN); err == nil {
+			t.Fatal("EVPN NLRI decode of truncated NLRI, expected failure, got NO error")
+		}
+	}
+
+	if nlris[0].GetCIDR() == nlris[1].GetCIDR() || nlris[2].GetCIDR() == nlris[3].GetCIDR() {
+		t.Fatal("EVPN NLRIs with different IPs have the same key", nlris)
+	}
+}
+
+func TestEVPNMPReachNLRIEncodeDecode(t *testing.T) {
+	rd, _ := ParseRouteDistinguisher("10.1.1.1:100")
+	protoFamily := GetProtocolFamily(AfiL2VPN, SafiEVPN)
+	nlriList := []NLRI{NewEVPNInclusiveMulticastRoute(rd, 0, net.ParseIP("1.1.1.1"))}
+	mpReach := ConstructIPv6MPReachNLRI(protoFamily, net.ParseIP("1.1.1.1"), nil, nlriList)
+	pkt, err := mpReach.Encode()
+	if err != nil {
+		t.Fatal("EVPN MPReachNLRI encode failed with error:", err)
+	}
+
+	newMPReach := NewBGPPathAttrMPReachNLRI()
+	err = newMPReach.Decode(pkt, BGPPeerAttrs{ASSize: 4})
+	if err != nil {
+		t.Fatal("EVPN MPReachNLRI decode failed with error:", err)
+	}
+	if !newMPReach.NextHop.GetNextHop().Equal(net.ParseIP("1.1.1.1")) {
+		t.Fatal("EVPN MPReachNLRI next hop expected 1.1.1.1, got", newMPReach.NextHop.GetNextHop())
+	}
+	if len(newMPReach.NLRI) != 1 || newMPReach.NLRI[0].GetCIDR() != nlriList[0].GetCIDR() {
+		t.Fatal("EVPN MPReachNLRI decode expected NLRI", nlriList, "got", newMPReach.NLRI)
+	}
+
+	newPkt, _ := newMPReach.Encode()
+	if !bytes.Equal(pkt, newPkt) {
+		t.Fatal("EVPN MPReachNLRI encoded packets differ", pkt, newPkt)
+	}
+}
+
+func TestPMSITunnelAndEncap(t *testing.T) {
+	pmsi := NewBGPPathAttrPMSITunnel(BGPPMSITunnelTypeIngressReplication, 10100, net.ParseIP("1.1.1.1"))
+	pkt, err := pmsi.Encode()
+	if err != nil {
+		t.Fatal("PMSI tunnel encode failed with error:", err)
+	}
+
+	newPMSI := &BGPPathAttrPMSITunnel{}
+	err = newPMSI.Decode(pkt, BGPPeerAttrs{ASSize: 4})
+	if err != nil {
+		t.Fatal("PMSI tunnel decode failed with error:", err)
+	}
+	if newPMSI.TunnelType != BGPPMSITunnelTypeIngressReplication || newPMSI.Label != 10100 ||
+		!newPMSI.TunnelId.Equal(net.ParseIP("1.1.1.1")) {
+		t.Fatal("PMSI tunnel decode expected", pmsi, "got", newPMSI)
+	}
+	shortPkts := [][]byte{
+		pkt[:len(pkt)-2],
+		[]byte{byte(pmsi.Flags | BGPPathAttrFlagExtendedLen), byte(BGPPathAttrTypePMSITunnel), 0xff, 0xff, 0},
+	}
+	for _, shortPkt := range shortPkts {
+		if err = (&BGPPathAttrPMSITunnel{}).Decode(shortPkt, BGPPeerAttrs{ASSize: 4}); err == nil {
+			t.Fatal("PMSI tunnel decode of short packet", shortPkt, "did not fail")
+		}
+	}
+
+	pathAttrs := ConstructPathAttrForConnRoutes(65000)
+	pathAttrs = AddPathAttrToPathAttrsByCode(pathAttrs, BGPPathAttrTypePMSITunnel, pmsi)
+	if GetPMSITunnel(pathAttrs) != pmsi {
+		t.Fatal("GetPMSITunnel did not return the PMSI tunnel attr")
+	}
+
+	if HasEncapTunnelType(pathAttrs, BGPEncapTunnelTypeVXLAN) {
+		t.Fatal("HasEncapTunnelType returned true for path attrs without extended communities")
+	}
+	pathAttrs = SetExtCommunities(pathAttrs, []uint64{NewEncapExtCommunity(BGPEncapTunnelTypeVXLAN)})
+	if !HasEncapTunnelType(pathAttrs, BGPEncapTunnelTypeVXLAN) || HasEncapTunnelType(pathAttrs, 1) {
+		t.Fatal("HasEncapTunnelType returned unexpected results")
+	}
+}
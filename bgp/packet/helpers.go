@@ -39,7 +39,7 @@ func PrependAS(updateMsg *BGPMessage, AS uint32, asSize uint8) {
 		if pa.GetCode() == BGPPathAttrTypeASPath {
 			asPathSegments := pa.(*BGPPathAttrASPath).Value
 			var newASPathSegment BGPASPathSegment
-			if len(asPathSegments) == 0 || asPathSegments[0].GetType() == BGPASPathSegmentSet || asPathSegments[0].GetLen() >= 255 {
+			if len(asPathSegments) == 0 || asPathSegments[0].GetType() != BGPASPathSegmentSequence || asPathSegments[0].GetLen() >= 255 {
 				if asSize == 4 {
 					newASPathSegment = NewBGPAS4PathSegmentSeq()
 				} else {
@@ -64,11 +64,94 @@ func PrependAS(updateMsg *BGPMessage, AS uint32, asSize uint8) {
 			}
 			asPathSegments = pa.(*BGPPathAttrAS4Path).Value
 			asPathSegments[0].PrependAS(AS)
-			pa.(*BGPPathAttrASPath).BGPPathAttrBase.Length += uint16(asSize)
+			pa.(*BGPPathAttrAS4Path).BGPPathAttrBase.Length += 4
 		}
 	}
 }
 
+// PrependConfedAS prepends the member AS to the AS_CONFED_SEQUENCE at the start of the AS path. It's called
+// when the update is sent to a peer in another member AS of the confederation, RFC 5065.
+func PrependConfedAS(updateMsg *BGPMessage, AS uint32, asSize uint8) {
+	body := updateMsg.Body.(*BGPUpdate)
+	for _, pa := range body.PathAttributes {
+		if pa.GetCode() != BGPPathAttrTypeASPath {
+			continue
+		}
+
+		asPath := pa.(*BGPPathAttrASPath)
+		if asSize != 4 && AS > math.MaxUint16 {
+			AS = uint32(BGPASTrans)
+		}
+		if len(asPath.Value) == 0 || asPath.Value[0].GetType() != BGPASPathSegmentConfedSequence ||
+			asPath.Value[0].GetLen() >= 255 {
+			if asSize == 4 {
+				asPath.PrependASPathSegment(NewBGPAS4PathSegment(BGPASPathSegmentConfedSequence))
+			} else {
+				asPath.PrependASPathSegment(NewBGPAS2PathSegment(BGPASPathSegmentConfedSequence))
+			}
+		}
+		asPath.Value[0].PrependAS(AS)
+		asPath.BGPPathAttrBase.Length += uint16(asSize)
+		break
+	}
+}
+
+// RemoveConfedSegments removes the confederation segments from the AS path. It's called when the update is
+// sent to a peer outside the confederation, RFC 5065.
+func RemoveConfedSegments(updateMsg *BGPMessage) {
+	body := updateMsg.Body.(*BGPUpdate)
+	for _, pa := range body.PathAttributes {
+		if pa.GetCode() == BGPPathAttrTypeASPath {
+			pa.(*BGPPathAttrASPath).RemoveConfedSegments()
+			break
+		}
+	}
+}
+
+// IsPrivateAS returns true if the AS is in one of the private AS ranges, RFC 6996.
+func IsPrivateAS(AS uint32) bool {
+	return (AS >= BGPPrivateASMin && AS <= BGPPrivateASMax) || (AS >= BGPPrivateAS4Min && AS <= BGPPrivateAS4Max)
+}
+
+func rewriteASPath(updateMsg *BGPMessage, rewrite func(uint32) (uint32, bool)) {
+	body := updateMsg.Body.(*BGPUpdate)
+	for _, pa := range body.PathAttributes {
+		if pa.GetCode() == BGPPathAttrTypeASPath {
+			pa.(*BGPPathAttrASPath).rewriteASes(rewrite)
+			break
+		}
+	}
+}
+
+// RemovePrivateAS removes all the private ASes from the AS path. The confederation segments are not changed.
+func RemovePrivateAS(updateMsg *BGPMessage) {
+	rewriteASPath(updateMsg, func(as uint32) (uint32, bool) {
+		return as, !IsPrivateAS(as)
+	})
+}
+
+// ReplacePrivateAS replaces all the private ASes in the AS path with the AS. The confederation segments are not
+// changed.
+func ReplacePrivateAS(updateMsg *BGPMessage, AS uint32) {
+	rewriteASPath(updateMsg, func(as uint32) (uint32, bool) {
+		if IsPrivateAS(as) {
+			return AS, true
+		}
+		return as, true
+	})
+}
+
+// ReplaceAS replaces all the occurrences of oldAS in the AS path with newAS. It's used to override the AS of
+// the peer so that the peer doesn't drop the route because of an AS loop.
+func ReplaceAS(updateMsg *BGPMessage, oldAS, newAS uint32) {
+	rewriteASPath(updateMsg, func(as uint32) (uint32, bool) {
+		if as == oldAS {
+			return newAS, true
+		}
+		return as, true
+	})
+}
+
 func AppendASToAS4PathSeg(asPath *BGPPathAttrASPath, pathSeg BGPASPathSegment, asPathType BGPASPathSegmentType,
 	asNum uint32) BGPASPathSegment {
 	if pathSeg == nil {
@@ -271,6 +354,35 @@ func HasMPReachNLRI(pathAttrs []BGPPathAttr) bool {
 	return false
 }
 
+// GetASCount returns the number of times the AS occurs in the AS path.
+func GetASCount(pathAttrs []BGPPathAttr, AS uint32) int {
+	count := 0
+	for _, attr := range pathAttrs {
+		if attr.GetCode() == BGPPathAttrTypeASPath {
+			for _, asSegment := range attr.(*BGPPathAttrASPath).Value {
+				switch seg := asSegment.(type) {
+				case *BGPAS4PathSegment:
+					for _, as := range seg.AS {
+						if as == AS {
+							count++
+						}
+					}
+
+				case *BGPAS2PathSegment:
+					for _, as := range seg.AS {
+						if uint32(as) == AS {
+							count++
+						}
+					}
+				}
+			}
+			break
+		}
+	}
+
+	return count
+}
+
 func HasASLoop(pathAttrs []BGPPathAttr, localAS uint32) bool {
 	for _, attr := range pathAttrs {
 		if attr.GetCode() == BGPPathAttrTypeASPath {
@@ -316,6 +428,79 @@ func GetNumASes(pathAttrs []BGPPathAttr) uint32 {
 	return total
 }
 
+// GetOriginAS returns the AS that originated the route, RFC 6811. It is the last AS of the AS_PATH if the last
+// segment is an AS_SEQUENCE and 0 if the last segment is an AS_SET. The confederation segments are skipped. The
+// second return value is false if the AS_PATH is empty.
+func GetOriginAS(pathAttrs []BGPPathAttr) (uint32, bool) {
+	for _, attr := range pathAttrs {
+		if attr.GetCode() != BGPPathAttrTypeASPath {
+			continue
+		}
+
+		asPaths := attr.(*BGPPathAttrASPath).Value
+		for idx := len(asPaths) - 1; idx >= 0; idx-- {
+			switch seg := asPaths[idx].(type) {
+			case *BGPAS4PathSegment:
+				if len(seg.AS) == 0 || IsConfedSegment(seg) {
+					continue
+				}
+				if seg.Type != BGPASPathSegmentSequence {
+					return 0, true
+				}
+				return seg.AS[len(seg.AS)-1], true
+
+			case *BGPAS2PathSegment:
+				if len(seg.AS) == 0 || IsConfedSegment(seg) {
+					continue
+				}
+				if seg.Type != BGPASPathSegmentSequence {
+					return 0, true
+				}
+				return uint32(seg.AS[len(seg.AS)-1]), true
+			}
+		}
+		break
+	}
+
+	return 0, false
+}
+
+// GetNeighborAS returns the AS the route was received from, the first AS of the AS_PATH if the first segment
+// that is not a confederation segment is an AS_SEQUENCE, RFC 4271 section 9.1.2.2. The second return value is
+// false if the route was not received from a neighboring AS.
+func GetNeighborAS(pathAttrs []BGPPathAttr) (uint32, bool) {
+	for _, attr := range pathAttrs {
+		if attr.GetCode() != BGPPathAttrTypeASPath {
+			continue
+		}
+
+		for _, asPath := range attr.(*BGPPathAttrASPath).Value {
+			switch seg := asPath.(type) {
+			case *BGPAS4PathSegment:
+				if len(seg.AS) == 0 || IsConfedSegment(seg) {
+					continue
+				}
+				if seg.Type != BGPASPathSegmentSequence {
+					return 0, false
+				}
+				return seg.AS[0], true
+
+			case *BGPAS2PathSegment:
+				if len(seg.AS) == 0 || IsConfedSegment(seg) {
+					continue
+				}
+				if seg.Type != BGPASPathSegmentSequence {
+					return 0, false
+				}
+				return uint32(seg.AS[0]), true
+			}
+		}
+		break
+	}
+
+	return 0, false
+}
+
 func GetOrigin(pathAttrs []BGPPathAttr) uint8 {
 	for _, attr := range pathAttrs {
 		if attr.GetCode() == BGPPathAttrTypeOrigin {
@@ -367,6 +552,78 @@ func GetNumClusters(pathAttrs []BGPPathAttr) uint16 {
 	return total
 }
 
+func GetCommunities(pathAttrs []BGPPathAttr) []uint32 {
+	if attr := getTypeFromPathAttrs(pathAttrs, BGPPathAttrTypeCommunities); attr != nil {
+		return attr.(*BGPPathAttrCommunities).Value
+	}
+	return nil
+}
+
+func GetExtCommunities(pathAttrs []BGPPathAttr) []uint64 {
+	if attr := getTypeFromPathAttrs(pathAttrs, BGPPathAttrTypeExtCommunities); attr != nil {
+		return attr.(*BGPPathAttrExtCommunities).Value
+	}
+	return nil
+}
+
+func GetLargeCommunities(pathAttrs []BGPPathAttr) []BGPLargeCommunity {
+	if attr := getTypeFromPathAttrs(pathAttrs, BGPPathAttrTypeLargeCommunities); attr != nil {
+		return attr.(*BGPPathAttrLargeCommunities).Value
+	}
+	return nil
+}
+
+func HasCommunity(pathAttrs []BGPPathAttr, community uint32) bool {
+	if attr := getTypeFromPathAttrs(pathAttrs, BGPPathAttrTypeCommunities); attr != nil {
+		return attr.(*BGPPathAttrCommunities).HasCommunity(community)
+	}
+	return false
+}
+
+// The Set*Communities functions return a new slice of path attrs, the path attrs passed in are not modified.
+// An empty list removes the attribute.
+func SetCommunities(pathAttrs []BGPPathAttr, communities []uint32) []BGPPathAttr {
+	pathAttrs = CopyPathAttrs(pathAttrs)
+	removeTypeFromPathAttrs(&pathAttrs, BGPPathAttrTypeCommunities)
+	if len(communities) == 0 {
+		return pathAttrs
+	}
+
+	attr := NewBGPPathAttrCommunities()
+	for _, community := range communities {
+		attr.AddCommunity(community)
+	}
+	return AddPathAttrToPathAttrsByCode(pathAttrs, BGPPathAttrTypeCommunities, attr)
+}
+
+func SetExtCommunities(pathAttrs []BGPPathAttr, extComms []uint64) []BGPPathAttr {
+	pathAttrs = CopyPathAttrs(pathAttrs)
+	removeTypeFromPathAttrs(&pathAttrs, BGPPathAttrTypeExtCommunities)
+	if len(extComms) == 0 {
+		return pathAttrs
+	}
+
+	attr := NewBGPPathAttrExtCommunities()
+	for _, extComm := range extComms {
+		attr.AddExtCommunity(extComm)
+	}
+	return AddPathAttrToPathAttrsByCode(pathAttrs, BGPPathAttrTypeExtCommunities, attr)
+}
+
+func SetLargeCommunities(pathAttrs []BGPPathAttr, largeComms []BGPLargeCommunity) []BGPPathAttr {
+	pathAttrs = CopyPathAttrs(pathAttrs)
+	removeTypeFromPathAttrs(&pathAttrs, BGPPathAttrTypeLargeCommunities)
+	if len(largeComms) == 0 {
+		return pathAttrs
+	}
+
+	attr := NewBGPPathAttrLargeCommunities()
+	for _, largeComm := range largeComms {
+		attr.AddLargeCommunity(largeComm)
+	}
+	return AddPathAttrToPathAttrsByCode(pathAttrs, BGPPathAttrTypeLargeCommunities, attr)
+}
+
 var AggRoutesDefaultBGPPathAttr = map[BGPPathAttrType]BGPPathAttr{
 	BGPPathAttrTypeOrigin:     NewBGPPathAttrOrigin(BGPPathAttrOriginIncomplete),
 	BGPPathAttrTypeASPath:     NewBGPPathAttrASPath(),
@@ -572,6 +829,14 @@ func ConstructMPUnreachNLRI(afi AFI, safi SAFI, nlriList []NLRI) *BGPPathAttrMPU
 	mpUnreachNLRI := NewBGPPathAttrMPUnreachNLRI()
 	mpUnreachNLRI.AFI = afi
 	mpUnreachNLRI.SAFI = safi
+	if safi == SafiLabeledUnicast {
+		// RFC 8277 section 2.4, the label field of the withdrawn routes is set to the withdrawn value
+		withdrawnList := make([]NLRI, 0, len(nlriList))
+		for _, nlri := range nlriList {
+			withdrawnList = append(withdrawnList, NewLabeledPrefix(nil, nlri.GetIPPrefix()))
+		}
+		nlriList = withdrawnList
+	}
 	mpUnreachNLRI.AddNLRIList(nlriList)
 	return mpUnreachNLRI
 }
@@ -587,12 +852,27 @@ func ConstructIPv6MPReachNLRI(protoFamily uint32, nextHop, nextHopLinkLocal net.
 	mpReachNLRI := NewBGPPathAttrMPReachNLRI()
 	mpReachNLRI.AFI = afi
 	mpReachNLRI.SAFI = safi
-	mpNextHop := NewMPNextHopIP6()
-	mpNextHop.SetGlobalNextHop(nextHop)
-	if nextHopLinkLocal != nil && nextHopLinkLocal.To16() == nil {
-		mpNextHop.SetLinkLocalNextHop(nextHopLinkLocal)
+	if safi == SafiMPLSVPN {
+		mpNextHop := NewMPNextHopVPN()
+		mpNextHop.SetNextHop(nextHop)
+		if afi == AfiIP6 {
+			// RFC 4659 - IPv4 next hop is encoded as IPv4-mapped IPv6 address
+			mpNextHop.Value = nextHop.To16()
+			mpNextHop.Length = uint8(BGPRouteDistinguisherLen + net.IPv6len)
+		}
+		mpReachNLRI.SetNextHop(mpNextHop)
+	} else if safi == SafiEVPN || afi == AfiIP {
+		mpNextHop := NewMPNextHopIP()
+		mpNextHop.SetNextHop(nextHop)
+		mpReachNLRI.SetNextHop(mpNextHop)
+	} else {
+		mpNextHop := NewMPNextHopIP6()
+		mpNextHop.SetGlobalNextHop(nextHop)
+		if nextHopLinkLocal != nil && nextHopLinkLocal.To16() == nil {
+			mpNextHop.SetLinkLocalNextHop(nextHopLinkLocal)
+		}
+		mpReachNLRI.SetNextHop(mpNextHop)
 	}
-	mpReachNLRI.SetNextHop(mpNextHop)
 	mpReachNLRI.SetNLRIList(nlriList)
 	return mpReachNLRI
 }
@@ -687,20 +967,17 @@ func ConvertIPBytesToUint(bytes []byte) uint32 {
 	return uint32(bytes[0])<<24 | uint32(bytes[1]<<16) | uint32(bytes[2]<<8) | uint32(bytes[3])
 }
 
-func ConstructOptParams(as uint32, afiSAfiMap map[uint32]bool, addPathsRx bool, addPathsMaxTx uint8) []BGPOptParam {
+func ConstructOptParams(as uint32, afiSAfiMap map[uint32]bool, addPathFlags map[uint32]uint8,
+	grCap *BGPCapGracefulRestart) []BGPOptParam {
 	optParams := make([]BGPOptParam, 0)
 	capParams := make([]BGPCapability, 0)
 
 	cap4ByteASPath := NewBGPCap4ByteASPath(as)
 	capParams = append(capParams, cap4ByteASPath)
+	capParams = append(capParams, NewBGPCapRouteRefresh())
+	capParams = append(capParams, NewBGPCapEnhancedRouteRefresh())
 	capAddPaths := NewBGPCapAddPath()
-	addPathFlags := uint8(0)
-	if addPathsRx {
-		addPathFlags |= BGPCapAddPathRx
-	}
-	if addPathsMaxTx > 0 {
-		addPathFlags |= BGPCapAddPathTx
-	}
+	addPaths := false
 
 	for protoFamily, _ := range afiSAfiMap {
 		afi, safi := GetAfiSafi(protoFamily)
@@ -708,15 +985,23 @@ func ConstructOptParams(as uint32, afiSAfiMap map[uint32]bool, addPathsRx bool,
 		capAfiSafi := NewBGPCapMPExt(afi, safi)
 		capParams = append(capParams, capAfiSafi)
 
-		addPathAfiSafi := NewAddPathAFISAFI(afi, safi, addPathFlags)
-		capAddPaths.AddAddPathAFISAFI(addPathAfiSafi)
+		if flags := addPathFlags[protoFamily]; flags != 0 {
+			addPathAfiSafi := NewAddPathAFISAFI(afi, safi, flags)
+			capAddPaths.AddAddPathAFISAFI(addPathAfiSafi)
+			addPaths = true
+		}
 	}
 
-	if addPathFlags != 0 {
+	if addPaths {
 		utils.Logger.Infof("Advertising capability for addPaths %+v", capAddPaths.Value)
 		capParams = append(capParams, capAddPaths)
 	}
 
+	if grCap != nil {
+		utils.Logger.Infof("Advertising capability for graceful restart %+v", grCap)
+		capParams = append(capParams, grCap)
+	}
+
 	optCapability := NewBGPOptParamCapability(capParams)
 	optParams = append(optParams, optCapability)
 
@@ -738,6 +1023,107 @@ func GetASSize(openMsg *BGPOpen) uint8 {
 	return 2
 }
 
+// GetPeerAS returns the AS number of the speaker that sent the OPEN message. The 4-octet AS number from the
+// AS4 capability takes precedence over the My Autonomous System field, RFC 6793.
+func GetPeerAS(openMsg *BGPOpen) uint32 {
+	for _, optParam := range openMsg.OptParams {
+		if capabilities, ok := optParam.(*BGPOptParamCapability); ok {
+			for _, capability := range capabilities.Value {
+				if as4Cap, ok := capability.(*BGPCapAS4Path); ok {
+					return as4Cap.Value
+				}
+			}
+		}
+	}
+
+	return openMsg.MyAS
+}
+
+func ConstructGracefulRestartCap(restarting bool, restartTime uint16, afiSafiMap map[uint32]bool,
+	forwarding bool) *BGPCapGracefulRestart {
+	grCap := NewBGPCapGracefulRestart(restarting, restartTime)
+	flags := uint8(0)
+	if forwarding {
+		flags |= BGPCapGRForwardingState
+	}
+
+	for protoFamily, ok := range afiSafiMap {
+		if ok {
+			afi, safi := GetAfiSafi(protoFamily)
+			grCap.AddGracefulRestartAFISAFI(afi, safi, flags)
+		}
+	}
+	return grCap
+}
+
+func GetGracefulRestartCap(openMsg *BGPOpen) *BGPCapGracefulRestart {
+	for _, optParam := range openMsg.OptParams {
+		if capabilities, ok := optParam.(*BGPOptParamCapability); ok {
+			for _, capability := range capabilities.Value {
+				if grCap, ok := capability.(*BGPCapGracefulRestart); ok {
+					return grCap
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// NewBGPEndOfRIBMessage constructs the End-of-RIB marker for the protocol family as defined in RFC 4724.
+// The marker for IPv4 unicast is an empty UPDATE message, for the other families it is an UPDATE message with
+// an empty MP_UNREACH_NLRI path attribute.
+func NewBGPEndOfRIBMessage(protoFamily uint32) *BGPMessage {
+	if protoFamily == GetProtocolFamily(AfiIP, SafiUnicast) {
+		return NewBGPUpdateMessage(make([]NLRI, 0), make([]BGPPathAttr, 0), make([]NLRI, 0))
+	}
+
+	pathAttrs := make([]BGPPathAttr, 0)
+	pathAttrs = append(pathAttrs, ConstructMPUnreachNLRIFromProtoFamily(protoFamily, make([]NLRI, 0)))
+	return NewBGPUpdateMessage(make([]NLRI, 0), pathAttrs, make([]NLRI, 0))
+}
+
+// GetEndOfRIBProtoFamily returns the protocol family of the End-of-RIB marker and true if the message is an
+// End-of-RIB marker.
+func GetEndOfRIBProtoFamily(msg *BGPMessage) (uint32, bool) {
+	if msg == nil || msg.Header.Type != BGPMsgTypeUpdate {
+		return 0, false
+	}
+
+	updateMsg, ok := msg.Body.(*BGPUpdate)
+	if !ok || len(updateMsg.WithdrawnRoutes) != 0 || len(updateMsg.NLRI) != 0 {
+		return 0, false
+	}
+
+	if len(updateMsg.PathAttributes) == 0 {
+		return GetProtocolFamily(AfiIP, SafiUnicast), true
+	}
+
+	if len(updateMsg.PathAttributes) == 1 {
+		if mpUnreach, ok := updateMsg.PathAttributes[0].(*BGPPathAttrMPUnreachNLRI); ok && len(mpUnreach.NLRI) == 0 {
+			return GetProtocolFamily(mpUnreach.AFI, mpUnreach.SAFI), true
+		}
+	}
+	return 0, false
+}
+
+func GetRouteRefreshCaps(openMsg *BGPOpen) (routeRefresh bool, enhancedRouteRefresh bool) {
+	for _, optParam := range openMsg.OptParams {
+		if capabilities, ok := optParam.(*BGPOptParamCapability); ok {
+			for _, capability := range capabilities.Value {
+				switch capability.GetCode() {
+				case BGPCapTypeRouteRefresh:
+					routeRefresh = true
+				case BGPCapTypeEnhancedRouteRefresh:
+					enhancedRouteRefresh = true
+				}
+			}
+		}
+	}
+
+	// RFC 7313 - Enhanced route refresh is used only if the route refresh capability is also advertised
+	return routeRefresh, routeRefresh && enhancedRouteRefresh
+}
+
 func GetAddPathFamily(openMsg *BGPOpen) map[AFI]map[SAFI]uint8 {
 	addPathFamily := make(map[AFI]map[SAFI]uint8)
 	for _, optParam := range openMsg.OptParams {
@@ -877,11 +1263,12 @@ func Convert4ByteTo2ByteASPath(updateMsg *BGPMessage) {
 			asPath := pa.(*BGPPathAttrASPath)
 			addAS4Path := false
 			newAS4Path := asPath.CloneAsAS4Path()
+			newAS4Path.removeConfedSegments()
 			newAS2Path := NewBGPPathAttrASPath()
 			for _, seg := range asPath.Value {
 				as4Seg := seg.(*BGPAS4PathSegment)
 				as2Seg, mappable := as4Seg.CloneAsAS2PathSegment()
-				if !mappable {
+				if !mappable && !IsConfedSegment(as4Seg) {
 					addAS4Path = true
 				}
 				newAS2Path.AppendASPathSegment(as2Seg)
@@ -1133,3 +1520,18 @@ func ConstructMaxSizedUpdatePackets(bgpMsg *BGPMessage) []*BGPMessage {
 
 	return newUpdateMsgs
 }
+
+// EncodeMaxSizedUpdatePackets splits the update message in max sized update messages and encodes them. The
+// encoded packets can be sent to several neighbors without encoding the message again.
+func EncodeMaxSizedUpdatePackets(bgpMsg *BGPMessage) ([][]byte, error) {
+	updateMsgs := ConstructMaxSizedUpdatePackets(bgpMsg)
+	pkts := make([][]byte, 0, len(updateMsgs))
+	for idx, _ := range updateMsgs {
+		pkt, err := updateMsgs[idx].Encode()
+		if err != nil {
+			return nil, err
+		}
+		pkts = append(pkts, pkt)
+	}
+	return pkts, nil
+}
@@ -181,3 +181,164 @@ func TestBGPAggregateASPaths(t *testing.T) {
 		}
 	}
 }
+
+func TestGetOriginAS(t *testing.T) {
+	tests := []struct {
+		segTypes []BGPASPathSegmentType
+		asNums   [][]uint32
+		originAS uint32
+		found    bool
+	}{
+		{[]BGPASPathSegmentType{}, [][]uint32{}, 0, false},
+		{[]BGPASPathSegmentType{BGPASPathSegmentSequence}, [][]uint32{{1, 2, 3}}, 3, true},
+		{[]BGPASPathSegmentType{BGPASPathSegmentSequence, BGPASPathSegmentSet}, [][]uint32{{1, 2}, {3, 4}}, 0,
+			true},
+		{[]BGPASPathSegmentType{BGPASPathSegmentSet, BGPASPathSegmentSequence}, [][]uint32{{1, 2}, {3, 4}}, 4,
+			true},
+		{[]BGPASPathSegmentType{BGPASPathSegmentConfedSequence, BGPASPathSegmentSequence},
+			[][]uint32{{65001, 65002}, {3, 4}}, 4, true},
+		{[]BGPASPathSegmentType{BGPASPathSegmentConfedSequence}, [][]uint32{{65001}}, 0, false},
+	}
+
+	for idx, test := range tests {
+		asPath := NewBGPPathAttrASPath()
+		for i, segType := range test.segTypes {
+			seg := NewBGPAS4PathSegment(segType)
+			for _, as := range test.asNums[i] {
+				seg.AppendAS(as)
+			}
+			asPath.AppendASPathSegment(seg)
+		}
+
+		originAS, found := GetOriginAS([]BGPPathAttr{asPath})
+		if originAS != test.originAS || found != test.found {
+			t.Fatalf("Test %d origin AS %d found %t, expected %d %t", idx, originAS, found, test.originAS,
+				test.found)
+		}
+	}
+}
+
+func TestEncodeMaxSizedUpdatePackets(t *testing.T) {
+	pathAttrs := ConstructPathAttrForConnRoutes(12345)
+	bgpMsg := NewBGPUpdateMessage(nil, pathAttrs, nil)
+	PrependAS(bgpMsg, 12345, 4)
+	pathAttrs = bgpMsg.Body.(*BGPUpdate).PathAttributes
+
+	nlris := make([]NLRI, 0)
+	for i := 0; i < 2027; i++ {
+		ip := []byte{0x0A, byte(i >> 8), byte(i), 0x00}
+		nlris = append(nlris, NewIPPrefix(ip, 24))
+	}
+
+	pkts, err := EncodeMaxSizedUpdatePackets(NewBGPUpdateMessage(nil, pathAttrs, nlris))
+	if err != nil {
+		t.Fatal("EncodeMaxSizedUpdatePackets failed with error", err)
+	}
+	if len(pkts) != 3 {
+		t.Fatal("EncodeMaxSizedUpdatePackets called... expected 3 packets, got", len(pkts))
+	}
+	for idx, pkt := range pkts {
+		pktLen := int(pkt[16])<<8 | int(pkt[17])
+		if len(pkt) > BGPMsgMaxLen || pktLen != len(pkt) || pkt[18] != BGPMsgTypeUpdate {
+			t.Errorf("Packet %d is %d bytes long, header length %d, type %d", idx, len(pkt), pktLen, pkt[18])
+		}
+	}
+}
+
+func TestGetPeerAS(t *testing.T) {
+	openMsg := NewBGPOpenMessage(65001, 90, "1.1.1.1", nil).Body.(*BGPOpen)
+	if as := GetPeerAS(openMsg); as != 65001 {
+		t.Error("GetPeerAS called... expected AS 65001, got", as)
+	}
+
+	optParams := []BGPOptParam{NewBGPOptParamCapability([]BGPCapability{NewBGPCap4ByteASPath(4200000001)})}
+	openMsg = NewBGPOpenMessage(4200000001, 90, "1.1.1.1", optParams).Body.(*BGPOpen)
+	if openMsg.MyAS != uint32(BGPASTrans) {
+		t.Fatal("NewBGPOpenMessage called... expected AS_TRANS in My AS, got", openMsg.MyAS)
+	}
+	if as := GetPeerAS(openMsg); as != 4200000001 {
+		t.Error("GetPeerAS called... expected AS 4200000001, got", as)
+	}
+}
+
+func TestConfedSegments(t *testing.T) {
+	bgpMsg := NewBGPUpdateMessage(nil, ConstructPathAttrForConnRoutes(100), nil)
+	PrependAS(bgpMsg, 100, 4)
+	PrependConfedAS(bgpMsg, 65001, 4)
+	PrependConfedAS(bgpMsg, 65002, 4)
+
+	pathAttrs := bgpMsg.Body.(*BGPUpdate).PathAttributes
+	asPath := pathAttrs[1].(*BGPPathAttrASPath)
+	if len(asPath.Value) != 2 || asPath.Value[0].GetType() != BGPASPathSegmentConfedSequence ||
+		asPath.Value[0].String() != "[65002 65001]" {
+		t.Fatal("PrependConfedAS called... expected AS_CONFED_SEQUENCE [65002 65001], got", asPath.Value)
+	}
+	if asPath.Length != 16 {
+		t.Error("PrependConfedAS called... expected AS path length 16, got", asPath.Length)
+	}
+	if numASes := GetNumASes(pathAttrs); numASes != 1 {
+		t.Error("GetNumASes called... expected confederation segments not to be counted, got", numASes)
+	}
+
+	RemoveConfedSegments(bgpMsg)
+	if len(asPath.Value) != 1 || asPath.Value[0].GetType() != BGPASPathSegmentSequence || asPath.Length != 6 {
+		t.Fatal("RemoveConfedSegments called... expected AS_SEQUENCE [100] of length 6, got", asPath.Value,
+			"length", asPath.Length)
+	}
+
+	PrependConfedAS(bgpMsg, 65001, 4)
+	PrependAS(bgpMsg, 200, 4)
+	if len(asPath.Value) != 3 || asPath.Value[0].GetType() != BGPASPathSegmentSequence ||
+		asPath.Value[0].String() != "[200]" {
+		t.Error("PrependAS called... expected a new AS_SEQUENCE before the confederation segment, got",
+			asPath.Value)
+	}
+}
+
+func TestRewriteASPath(t *testing.T) {
+	bgpMsg := NewBGPUpdateMessage(nil, ConstructPathAttrForConnRoutes(100), nil)
+	PrependAS(bgpMsg, 100, 4)
+	PrependAS(bgpMsg, 64600, 4)
+	PrependAS(bgpMsg, 200, 4)
+	PrependAS(bgpMsg, 4200000001, 4)
+	PrependAS(bgpMsg, 200, 4)
+
+	pathAttrs := bgpMsg.Body.(*BGPUpdate).PathAttributes
+	if count := GetASCount(pathAttrs, 200); count != 2 {
+		t.Error("GetASCount called... expected AS 200 count 2, got", count)
+	}
+
+	asPath := pathAttrs[1].(*BGPPathAttrASPath)
+	ReplaceAS(bgpMsg, 200, 300)
+	if asPath.Value[0].String() != "[300 4200000001 300 64600 100]" || asPath.Length != 22 {
+		t.Error("ReplaceAS called... expected AS_SEQUENCE [300 4200000001 300 64600 100] of length 22, got",
+			asPath.Value, "length", asPath.Length)
+	}
+
+	ReplacePrivateAS(bgpMsg, 100)
+	if asPath.Value[0].String() != "[300 100 300 100 100]" || asPath.Length != 22 {
+		t.Error("ReplacePrivateAS called... expected AS_SEQUENCE [300 100 300 100 100] of length 22, got",
+			asPath.Value, "length", asPath.Length)
+	}
+
+	bgpMsg = NewBGPUpdateMessage(nil, ConstructPathAttrForConnRoutes(100), nil)
+	PrependAS(bgpMsg, 100, 4)
+	PrependAS(bgpMsg, 65000, 4)
+	PrependConfedAS(bgpMsg, 65001, 4)
+	asPath = bgpMsg.Body.(*BGPUpdate).PathAttributes[1].(*BGPPathAttrASPath)
+	RemovePrivateAS(bgpMsg)
+	if len(asPath.Value) != 2 || asPath.Value[0].GetType() != BGPASPathSegmentConfedSequence ||
+		asPath.Value[1].String() != "[100]" || asPath.Length != 12 {
+		t.Error("RemovePrivateAS called... expected the confederation segment and AS_SEQUENCE [100] of length 12,",
+			"got", asPath.Value, "length", asPath.Length)
+	}
+
+	RemoveConfedSegments(bgpMsg)
+	ReplaceAS(bgpMsg, 100, 200)
+	RemovePrivateAS(bgpMsg)
+	ReplaceAS(bgpMsg, 200, 65000)
+	RemovePrivateAS(bgpMsg)
+	if len(asPath.Value) != 0 || asPath.Length != 0 {
+		t.Error("RemovePrivateAS called... expected empty AS path, got", asPath.Value, "length", asPath.Length)
+	}
+}
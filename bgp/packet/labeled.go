@@ -0,0 +1,162 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// labeled.go
+package packet
+
+import (
+	"fmt"
+)
+
+var LabeledFamilyToUnicastFamilyMap = map[uint32]uint32{
+	GetProtocolFamily(AfiIP, SafiLabeledUnicast):  GetProtocolFamily(AfiIP, SafiUnicast),
+	GetProtocolFamily(AfiIP6, SafiLabeledUnicast): GetProtocolFamily(AfiIP6, SafiUnicast),
+}
+
+var UnicastFamilyToLabeledFamilyMap = map[uint32]uint32{
+	GetProtocolFamily(AfiIP, SafiUnicast):  GetProtocolFamily(AfiIP, SafiLabeledUnicast),
+	GetProtocolFamily(AfiIP6, SafiUnicast): GetProtocolFamily(AfiIP6, SafiLabeledUnicast),
+}
+
+func IsLabeledUnicastFamily(protoFamily uint32) bool {
+	afi, safi := GetAfiSafi(protoFamily)
+	return (afi == AfiIP || afi == AfiIP6) && safi == SafiLabeledUnicast
+}
+
+// labelStackLen returns the length of the encoded label stack. An empty stack is encoded as a single label
+// with the withdrawn value.
+func labelStackLen(labels []uint32) int {
+	if len(labels) == 0 {
+		return BGPLabelLen
+	}
+	return len(labels) * BGPLabelLen
+}
+
+// encodeLabelStack encodes the labels in pkt with the bottom of stack bit set in the last label.
+func encodeLabelStack(pkt []byte, labels []uint32) {
+	if len(labels) == 0 {
+		pkt[0] = uint8(BGPLabelWithdrawn >> 16)
+		return
+	}
+
+	for i, label := range labels {
+		val := label << 4
+		if i == len(labels)-1 {
+			val |= BGPLabelBOS
+		}
+		idx := i * BGPLabelLen
+		pkt[idx] = uint8(val >> 16)
+		pkt[idx+1] = uint8(val >> 8)
+		pkt[idx+2] = uint8(val)
+	}
+}
+
+// decodeLabelStack decodes the labels up to the label with the bottom of stack bit set or the withdrawn value.
+// bits is the number of bits of the NLRI left to decode. It returns the labels and the number of bytes decoded.
+func decodeLabelStack(pkt []byte, bits int) ([]uint32, int, error) {
+	labels := make([]uint32, 0)
+	idx := 0
+	for {
+		if bits < BGPLabelLen*8 || len(pkt) < idx+BGPLabelLen {
+			return nil, idx, BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+				"NLRI label stack invalid"}
+		}
+		val := uint32(pkt[idx])<<16 | uint32(pkt[idx+1])<<8 | uint32(pkt[idx+2])
+		idx += BGPLabelLen
+		bits -= BGPLabelLen * 8
+		if val == BGPLabelWithdrawn {
+			break
+		}
+		labels = append(labels, val>>4)
+		if val&BGPLabelBOS != 0 {
+			break
+		}
+	}
+	return labels, idx, nil
+}
+
+// LabeledPrefix is the labeled unicast NLRI as defined in RFC 8277. The label stack is followed by the IP
+// prefix.
+type LabeledPrefix struct {
+	*IPPrefix
+	Labels []uint32
+}
+
+func (l *LabeledPrefix) Clone() NLRI {
+	x := *l
+	prefix := l.IPPrefix.Clone()
+	x.IPPrefix = prefix.(*IPPrefix)
+	x.Labels = make([]uint32, len(l.Labels))
+	copy(x.Labels, l.Labels)
+	return &x
+}
+
+func (l *LabeledPrefix) Len() uint32 {
+	return l.IPPrefix.Len() + uint32(labelStackLen(l.Labels))
+}
+
+func (l *LabeledPrefix) Encode(afi AFI) ([]byte, error) {
+	ipBytes, err := l.IPPrefix.Encode(afi)
+	if err != nil {
+		return nil, err
+	}
+
+	labelsLen := labelStackLen(l.Labels)
+	pkt := make([]byte, 1+labelsLen)
+	pkt[0] = uint8(labelsLen*8) + l.Length
+	encodeLabelStack(pkt[1:], l.Labels)
+	pkt = append(pkt, ipBytes[1:]...)
+	return pkt, nil
+}
+
+func (l *LabeledPrefix) Decode(pkt []byte, afi AFI) error {
+	if len(pkt) < 1 {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil, "NLRI does not contain prefix lenght"}
+	}
+
+	bits := int(pkt[0])
+	labels, labelsLen, err := decodeLabelStack(pkt[1:], bits)
+	if err != nil {
+		return err
+	}
+	l.Labels = labels
+	idx := 1 + labelsLen
+	bits -= labelsLen * 8
+
+	prefixPkt := make([]byte, len(pkt)-idx+1)
+	prefixPkt[0] = uint8(bits)
+	copy(prefixPkt[1:], pkt[idx:])
+	l.IPPrefix = &IPPrefix{}
+	return l.IPPrefix.Decode(prefixPkt, afi)
+}
+
+func (l *LabeledPrefix) String() string {
+	return "{" + fmt.Sprint(l.Labels) + " " + l.IPPrefix.GetCIDR() + "}"
+}
+
+func NewLabeledPrefix(labels []uint32, prefix *IPPrefix) *LabeledPrefix {
+	return &LabeledPrefix{
+		IPPrefix: prefix,
+		Labels:   labels,
+	}
+}
@@ -0,0 +1,110 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// labeled_test.go
+package packet
+
+import (
+	"net"
+	"testing"
+)
+
+func TestLabeledPrefixEncodeDecode(t *testing.T) {
+	prefixes := []*IPPrefix{NewIPPrefix(net.ParseIP("10.1.0.0"), 16), NewIPPrefix(net.ParseIP("2001:db8::"), 32)}
+	afis := []AFI{AfiIP, AfiIP6}
+	for idx, prefix := range prefixes {
+		labeledPrefix := NewLabeledPrefix([]uint32{16, 1000}, prefix)
+		pkt, err := labeledPrefix.Encode(afis[idx])
+		if err != nil {
+			t.Fatal("LabeledPrefix encode failed with error:", err)
+		}
+		if uint32(len(pkt)) != labeledPrefix.Len() {
+			t.Fatal("LabeledPrefix encoded length", len(pkt), "expected", labeledPrefix.Len())
+		}
+
+		newPrefix := &LabeledPrefix{}
+		err = newPrefix.Decode(pkt, afis[idx])
+		if err != nil {
+			t.Fatal("LabeledPrefix decode failed with error:", err)
+		}
+		if newPrefix.GetCIDR() != prefix.GetCIDR() || len(newPrefix.Labels) != 2 ||
+			newPrefix.Labels[0] != 16 || newPrefix.Labels[1] != 1000 {
+			t.Fatal("LabeledPrefix decode expected", labeledPrefix, "got", newPrefix)
+		}
+	}
+
+	withdrawn := NewLabeledPrefix(nil, NewIPPrefix(net.ParseIP("10.1.0.0"), 16))
+	pkt, _ := withdrawn.Encode(AfiIP)
+	if pkt[0] != 40 || pkt[1] != 0x80 || pkt[2] != 0 || pkt[3] != 0 {
+		t.Fatal("LabeledPrefix encode of withdrawn route expected the withdrawn label, got", pkt)
+	}
+	newPrefix := &LabeledPrefix{}
+	if err := newPrefix.Decode(pkt, AfiIP); err != nil || len(newPrefix.Labels) != 0 {
+		t.Fatal("LabeledPrefix decode of withdrawn route failed, error:", err, "prefix:", newPrefix)
+	}
+
+	if err := newPrefix.Decode(pkt[:2], AfiIP); err == nil {
+		t.Fatal("LabeledPrefix decode of truncated NLRI, expected failure, got NO error")
+	}
+}
+
+func TestLabeledMPReachNLRIEncodeDecode(t *testing.T) {
+	protoFamilies := []uint32{GetProtocolFamily(AfiIP, SafiLabeledUnicast),
+		GetProtocolFamily(AfiIP6, SafiLabeledUnicast)}
+	prefixes := []*IPPrefix{NewIPPrefix(net.ParseIP("20.1.1.0"), 24), NewIPPrefix(net.ParseIP("2001:db8:1::"), 48)}
+	nextHops := []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("2001:db8::1")}
+	for idx, protoFamily := range protoFamilies {
+		if !IsLabeledUnicastFamily(protoFamily) || !IsProtocolFamilySupported(protoFamily) {
+			t.Fatal("Protocol family", protoFamily, "is not a supported labeled unicast family")
+		}
+
+		nlriList := []NLRI{NewLabeledPrefix([]uint32{100}, prefixes[idx])}
+		mpReach := ConstructIPv6MPReachNLRI(protoFamily, nextHops[idx], nil, nlriList)
+		pkt, err := mpReach.Encode()
+		if err != nil {
+			t.Fatal("Labeled MPReachNLRI encode failed with error:", err)
+		}
+
+		newMPReach := NewBGPPathAttrMPReachNLRI()
+		err = newMPReach.Decode(pkt, BGPPeerAttrs{ASSize: 4})
+		if err != nil {
+			t.Fatal("Labeled MPReachNLRI decode failed with error:", err)
+		}
+		if !newMPReach.NextHop.GetNextHop().Equal(nextHops[idx]) {
+			t.Fatal("Labeled MPReachNLRI next hop expected", nextHops[idx], "got", newMPReach.NextHop.GetNextHop())
+		}
+		if len(newMPReach.NLRI) != 1 {
+			t.Fatal("Labeled MPReachNLRI decode expected NLRI", nlriList, "got", newMPReach.NLRI)
+		}
+		labeledPrefix, ok := newMPReach.NLRI[0].(*LabeledPrefix)
+		if !ok || labeledPrefix.GetCIDR() != prefixes[idx].GetCIDR() || len(labeledPrefix.Labels) != 1 ||
+			labeledPrefix.Labels[0] != 100 {
+			t.Fatal("Labeled MPReachNLRI decode expected NLRI", nlriList, "got", newMPReach.NLRI)
+		}
+
+		mpUnreach := ConstructMPUnreachNLRIFromProtoFamily(protoFamily, nlriList)
+		if labeledPrefix, ok := mpUnreach.NLRI[0].(*LabeledPrefix); !ok || len(labeledPrefix.Labels) != 0 {
+			t.Fatal("Labeled MPUnreachNLRI expected NLRI with the withdrawn label, got", mpUnreach.NLRI)
+		}
+	}
+}
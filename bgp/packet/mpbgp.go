@@ -34,8 +34,9 @@ import (
 )
 
 var BGPAFIToStructMap = map[AFI]MPNextHop{
-	AfiIP:  &MPNextHopIP{},
-	AfiIP6: &MPNextHopIP6{},
+	AfiIP:    &MPNextHopIP{},
+	AfiIP6:   &MPNextHopIP6{},
+	AfiL2VPN: &MPNextHopIP{},
 }
 
 type MPNextHop interface {
@@ -272,10 +273,12 @@ func NewMPNextHopUnknown() *MPNextHopUnknown {
 	}
 }
 
-func BGPGetMPNextHop(afi AFI) MPNextHop {
+func BGPGetMPNextHop(afi AFI, safi SAFI) MPNextHop {
 	var nextHop MPNextHop
 	var ok bool
-	if nextHop, ok = BGPAFIToStructMap[afi]; ok {
+	if safi == SafiMPLSVPN {
+		nextHop = &MPNextHopVPN{}
+	} else if nextHop, ok = BGPAFIToStructMap[afi]; ok {
 		nextHop = nextHop.New()
 	} else {
 		nextHop = &MPNextHopUnknown{}
@@ -346,7 +349,7 @@ func (r *BGPPathAttrMPReachNLRI) Decode(pkt []byte, data interface{}) error {
 	r.SAFI = SAFI(pkt[idx+2])
 	idx += 3
 
-	nextHop := BGPGetMPNextHop(r.AFI)
+	nextHop := BGPGetMPNextHop(r.AFI, r.SAFI)
 	nextHop.Decode(pkt[idx:])
 	r.NextHop = nextHop
 	idx += int(nextHop.Len())
@@ -0,0 +1,329 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// vpn.go
+package packet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Route distinguisher types, RFC 4364 section 4.2
+const (
+	BGPRouteDistinguisherTypeAS2Byte uint16 = 0
+	BGPRouteDistinguisherTypeIPv4    uint16 = 1
+	BGPRouteDistinguisherTypeAS4Byte uint16 = 2
+)
+
+const (
+	BGPRouteDistinguisherLen        = 8
+	BGPLabelLen                     = 3
+	BGPLabelMax              uint32 = 0xFFFFF
+	BGPLabelBOS              uint32 = 0x1
+	BGPLabelWithdrawn        uint32 = 0x800000 // RFC 3107, label field value of withdrawn routes
+)
+
+var VPNFamilyToUnicastFamilyMap = map[uint32]uint32{
+	GetProtocolFamily(AfiIP, SafiMPLSVPN):  GetProtocolFamily(AfiIP, SafiUnicast),
+	GetProtocolFamily(AfiIP6, SafiMPLSVPN): GetProtocolFamily(AfiIP6, SafiUnicast),
+}
+
+var UnicastFamilyToVPNFamilyMap = map[uint32]uint32{
+	GetProtocolFamily(AfiIP, SafiUnicast):  GetProtocolFamily(AfiIP, SafiMPLSVPN),
+	GetProtocolFamily(AfiIP6, SafiUnicast): GetProtocolFamily(AfiIP6, SafiMPLSVPN),
+}
+
+func IsVPNFamily(protoFamily uint32) bool {
+	_, safi := GetAfiSafi(protoFamily)
+	return safi == SafiMPLSVPN
+}
+
+type RouteDistinguisher [BGPRouteDistinguisherLen]byte
+
+// ParseRouteDistinguisher parses the route distinguisher in the format AS:value or IP:value. The value
+// encoding is the same as the value of the AS and IP specific extended communities.
+func ParseRouteDistinguisher(str string) (RouteDistinguisher, error) {
+	var rd RouteDistinguisher
+	extComm, err := ParseExtCommunity("rt:" + str)
+	if err != nil {
+		return rd, errors.New(fmt.Sprintf("Invalid route distinguisher %s, expected format AS:value or IP:value",
+			str))
+	}
+
+	binary.BigEndian.PutUint64(rd[:], extComm)
+	rd[1] = rd[0]
+	rd[0] = 0
+	return rd, nil
+}
+
+func (rd RouteDistinguisher) Type() uint16 {
+	return binary.BigEndian.Uint16(rd[0:2])
+}
+
+func (rd RouteDistinguisher) String() string {
+	switch rd.Type() {
+	case BGPRouteDistinguisherTypeAS2Byte:
+		return fmt.Sprintf("%d:%d", binary.BigEndian.Uint16(rd[2:4]), binary.BigEndian.Uint32(rd[4:8]))
+	case BGPRouteDistinguisherTypeIPv4:
+		return fmt.Sprintf("%s:%d", net.IP(rd[2:6]).String(), binary.BigEndian.Uint16(rd[6:8]))
+	case BGPRouteDistinguisherTypeAS4Byte:
+		return fmt.Sprintf("%d:%d", binary.BigEndian.Uint32(rd[2:6]), binary.BigEndian.Uint16(rd[6:8]))
+	}
+	return fmt.Sprintf("0x%x", rd[:])
+}
+
+// VPNPrefix is the labeled VPN-IPv4/VPN-IPv6 NLRI as defined in RFC 4364 and RFC 4659. The label stack is
+// followed by the route distinguisher and the IP prefix.
+type VPNPrefix struct {
+	*IPPrefix
+	Labels []uint32
+	RD     RouteDistinguisher
+}
+
+func (v *VPNPrefix) Clone() NLRI {
+	x := *v
+	prefix := v.IPPrefix.Clone()
+	x.IPPrefix = prefix.(*IPPrefix)
+	x.Labels = make([]uint32, len(v.Labels))
+	copy(x.Labels, v.Labels)
+	return &x
+}
+
+func (v *VPNPrefix) Len() uint32 {
+	return v.IPPrefix.Len() + uint32(labelStackLen(v.Labels)+BGPRouteDistinguisherLen)
+}
+
+func (v *VPNPrefix) Encode(afi AFI) ([]byte, error) {
+	ipBytes, err := v.IPPrefix.Encode(afi)
+	if err != nil {
+		return nil, err
+	}
+
+	labelsLen := labelStackLen(v.Labels)
+	pkt := make([]byte, 1+labelsLen+BGPRouteDistinguisherLen)
+	pkt[0] = uint8((labelsLen+BGPRouteDistinguisherLen)*8) + v.Length
+	encodeLabelStack(pkt[1:], v.Labels)
+	copy(pkt[1+labelsLen:], v.RD[:])
+	pkt = append(pkt, ipBytes[1:]...)
+	return pkt, nil
+}
+
+func (v *VPNPrefix) Decode(pkt []byte, afi AFI) error {
+	if len(pkt) < 1 {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil, "NLRI does not contain prefix lenght"}
+	}
+
+	bits := int(pkt[0])
+	labels, labelsLen, err := decodeLabelStack(pkt[1:], bits)
+	if err != nil {
+		return err
+	}
+	v.Labels = labels
+	idx := 1 + labelsLen
+	bits -= labelsLen * 8
+
+	if bits < BGPRouteDistinguisherLen*8 || len(pkt) < idx+BGPRouteDistinguisherLen {
+		return BGPMessageError{BGPUpdateMsgError, BGPInvalidNetworkField, nil,
+			"VPN NLRI does not contain route distinguisher"}
+	}
+	copy(v.RD[:], pkt[idx:idx+BGPRouteDistinguisherLen])
+	idx += BGPRouteDistinguisherLen
+	bits -= BGPRouteDistinguisherLen * 8
+
+	prefixPkt := make([]byte, len(pkt)-idx+1)
+	prefixPkt[0] = uint8(bits)
+	copy(prefixPkt[1:], pkt[idx:])
+	v.IPPrefix = &IPPrefix{}
+	return v.IPPrefix.Decode(prefixPkt, afi)
+}
+
+func (v *VPNPrefix) GetCIDR() string {
+	return v.RD.String() + ":" + v.IPPrefix.GetCIDR()
+}
+
+func (v *VPNPrefix) String() string {
+	return "{" + v.RD.String() + " " + fmt.Sprint(v.Labels) + " " + v.Prefix.String() + "/" +
+		strconv.Itoa(int(v.Length)) + "}"
+}
+
+func NewVPNPrefix(rd RouteDistinguisher, labels []uint32, prefix *IPPrefix) *VPNPrefix {
+	return &VPNPrefix{
+		IPPrefix: prefix,
+		Labels:   labels,
+		RD:       rd,
+	}
+}
+
+// MPNextHopVPN is the next hop of the VPN families, a route distinguisher set to zero followed by the
+// IPv4 or IPv6 address as defined in RFC 4364 and RFC 4659.
+type MPNextHopVPN struct {
+	Length uint8
+	RD     RouteDistinguisher
+	Value  net.IP
+}
+
+func (v *MPNextHopVPN) Clone() MPNextHop {
+	x := *v
+	x.Value = make(net.IP, len(v.Value), cap(v.Value))
+	copy(x.Value, v.Value)
+	return &x
+}
+
+func (v *MPNextHopVPN) ipLen() int {
+	switch v.Length {
+	case BGPRouteDistinguisherLen + net.IPv4len:
+		return net.IPv4len
+	case BGPRouteDistinguisherLen + net.IPv6len, (BGPRouteDistinguisherLen + net.IPv6len) * 2:
+		return net.IPv6len
+	}
+	return -1
+}
+
+func (v *MPNextHopVPN) Encode(pkt []byte) error {
+	ipLen := v.ipLen()
+	if ipLen < 0 {
+		return errors.New(fmt.Sprintf("Wrong VPN next hop len %d", v.Length))
+	}
+	pkt[0] = v.Length
+	copy(pkt[1:], v.RD[:])
+	copy(pkt[1+BGPRouteDistinguisherLen:], v.Value[cap(v.Value)-ipLen:])
+	return nil
+}
+
+func (v *MPNextHopVPN) Decode(pkt []byte) error {
+	if len(pkt) < 1 {
+		return BGPMessageError{BGPUpdateMsgError, BGPOptionalAttrError, nil, "Not enough data to decode VPN next hop"}
+	}
+	v.Length = pkt[0]
+	ipLen := v.ipLen()
+	if ipLen < 0 {
+		return errors.New(fmt.Sprintf("Wrong VPN next hop len %d", v.Length))
+	}
+	if len(pkt) < int(v.Length)+1 {
+		return BGPMessageError{BGPUpdateMsgError, BGPOptionalAttrError, nil,
+			fmt.Sprintf("Not enough data to decode VPN next hop, len %d", v.Length)}
+	}
+	copy(v.RD[:], pkt[1:1+BGPRouteDistinguisherLen])
+	v.Value = make(net.IP, net.IPv6len)
+	if ipLen == net.IPv4len {
+		idx := 1 + BGPRouteDistinguisherLen
+		v.Value = net.IPv4(pkt[idx], pkt[idx+1], pkt[idx+2], pkt[idx+3])
+	} else {
+		copy(v.Value, pkt[1+BGPRouteDistinguisherLen:])
+	}
+	return nil
+}
+
+func (v *MPNextHopVPN) Len() uint8 {
+	return v.Length + 1
+}
+
+func (v *MPNextHopVPN) New() MPNextHop {
+	return &MPNextHopVPN{}
+}
+
+func (v *MPNextHopVPN) String() string {
+	return fmt.Sprintf("{NEXTHOP %s:%v}", v.RD, v.Value)
+}
+
+func (v *MPNextHopVPN) GetNextHop() net.IP {
+	return v.Value
+}
+
+func (v *MPNextHopVPN) SetNextHop(ip net.IP) error {
+	if ip.To4() != nil {
+		v.Value = ip
+		v.Length = uint8(BGPRouteDistinguisherLen + net.IPv4len)
+	} else if ip.To16() != nil {
+		v.Value = ip
+		v.Length = uint8(BGPRouteDistinguisherLen + net.IPv6len)
+	} else {
+		return errors.New(fmt.Sprintf("Next hop IP address is NOT IPv4 or IPv6 address, ip=%s", ip))
+	}
+	return nil
+}
+
+func NewMPNextHopVPN() *MPNextHopVPN {
+	return &MPNextHopVPN{
+		Length: 0,
+		Value:  net.IP{},
+	}
+}
+
+// ParseRouteTarget parses the route target in the format rt:AS:value, rt:IP:value or without the rt prefix.
+func ParseRouteTarget(str string) (uint64, error) {
+	str = strings.TrimSpace(str)
+	if !strings.HasPrefix(strings.ToLower(str), "rt:") {
+		str = "rt:" + str
+	}
+	return ParseExtCommunity(str)
+}
+
+func IsRouteTarget(extComm uint64) bool {
+	bytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(bytes, extComm)
+	switch bytes[0] {
+	case BGPExtCommTypeAS2Byte, BGPExtCommTypeIPv4, BGPExtCommTypeAS4Byte:
+		return bytes[1] == BGPExtCommSubTypeRouteTarget
+	}
+	return false
+}
+
+func GetRouteTargets(pathAttrs []BGPPathAttr) []uint64 {
+	routeTargets := make([]uint64, 0)
+	for _, extComm := range GetExtCommunities(pathAttrs) {
+		if IsRouteTarget(extComm) {
+			routeTargets = append(routeTargets, extComm)
+		}
+	}
+	return routeTargets
+}
+
+func HasRouteTarget(pathAttrs []BGPPathAttr, routeTargets []uint64) bool {
+	for _, extComm := range GetExtCommunities(pathAttrs) {
+		for _, routeTarget := range routeTargets {
+			if extComm == routeTarget {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetRouteTargets returns a new slice of path attrs with the route targets replaced, the other extended
+// communities are retained.
+func SetRouteTargets(pathAttrs []BGPPathAttr, routeTargets []uint64) []BGPPathAttr {
+	extComms := make([]uint64, 0)
+	for _, extComm := range GetExtCommunities(pathAttrs) {
+		if !IsRouteTarget(extComm) {
+			extComms = append(extComms, extComm)
+		}
+	}
+	extComms = append(extComms, routeTargets...)
+	return SetExtCommunities(pathAttrs, extComms)
+}
@@ -0,0 +1,178 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// vpn_test.go
+package packet
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestParseRouteDistinguisher(t *testing.T) {
+	strs := []string{"65000:100", "10.1.1.1:200", "4200000000:300"}
+	types := []uint16{BGPRouteDistinguisherTypeAS2Byte, BGPRouteDistinguisherTypeIPv4,
+		BGPRouteDistinguisherTypeAS4Byte}
+	for idx, str := range strs {
+		rd, err := ParseRouteDistinguisher(str)
+		if err != nil {
+			t.Fatal("ParseRouteDistinguisher failed for", str, "with error:", err)
+		}
+		if rd.Type() != types[idx] || rd.String() != str {
+			t.Fatal("ParseRouteDistinguisher for", str, "returned type", rd.Type(), "string", rd.String())
+		}
+	}
+
+	badStrs := []string{"65000", "rt:65000:100", "10.1.1.1:65536", ""}
+	for _, str := range badStrs {
+		if _, err := ParseRouteDistinguisher(str); err == nil {
+			t.Error("ParseRouteDistinguisher for", str, "expected failure, got NO error")
+		}
+	}
+}
+
+func TestVPNPrefixEncodeDecode(t *testing.T) {
+	rd, _ := ParseRouteDistinguisher("65000:100")
+	prefixes := []*IPPrefix{NewIPPrefix(net.ParseIP("10.1.0.0"), 16), NewIPPrefix(net.ParseIP("2001:db8::"), 32)}
+	afis := []AFI{AfiIP, AfiIP6}
+	for idx, prefix := range prefixes {
+		vpnPrefix := NewVPNPrefix(rd, []uint32{16, 1000}, prefix)
+		pkt, err := vpnPrefix.Encode(afis[idx])
+		if err != nil {
+			t.Fatal("VPNPrefix encode failed with error:", err)
+		}
+		if uint32(len(pkt)) != vpnPrefix.Len() {
+			t.Fatal("VPNPrefix encoded length", len(pkt), "expected", vpnPrefix.Len())
+		}
+
+		newPrefix := &VPNPrefix{}
+		err = newPrefix.Decode(pkt, afis[idx])
+		if err != nil {
+			t.Fatal("VPNPrefix decode failed with error:", err)
+		}
+		if newPrefix.GetCIDR() != vpnPrefix.GetCIDR() || len(newPrefix.Labels) != 2 ||
+			newPrefix.Labels[0] != 16 || newPrefix.Labels[1] != 1000 {
+			t.Fatal("VPNPrefix decode expected", vpnPrefix, "got", newPrefix)
+		}
+	}
+
+	withdrawn := NewVPNPrefix(rd, nil, NewIPPrefix(net.ParseIP("10.1.0.0"), 16))
+	pkt, _ := withdrawn.Encode(AfiIP)
+	newPrefix := &VPNPrefix{}
+	if err := newPrefix.Decode(pkt, AfiIP); err != nil || len(newPrefix.Labels) != 0 {
+		t.Fatal("VPNPrefix decode of withdrawn route failed, error:", err, "prefix:", newPrefix)
+	}
+
+	if err := newPrefix.Decode(pkt[:5], AfiIP); err == nil {
+		t.Fatal("VPNPrefix decode of truncated NLRI, expected failure, got NO error")
+	}
+}
+
+func TestVPNMPReachNLRIEncodeDecode(t *testing.T) {
+	rd, _ := ParseRouteDistinguisher("10.1.1.1:1")
+	protoFamilies := []uint32{GetProtocolFamily(AfiIP, SafiMPLSVPN), GetProtocolFamily(AfiIP6, SafiMPLSVPN)}
+	prefixes := []*IPPrefix{NewIPPrefix(net.ParseIP("20.1.1.0"), 24), NewIPPrefix(net.ParseIP("2001:db8:1::"), 48)}
+	for idx, protoFamily := range protoFamilies {
+		nlriList := []NLRI{NewVPNPrefix(rd, []uint32{100}, prefixes[idx])}
+		mpReach := ConstructIPv6MPReachNLRI(protoFamily, net.ParseIP("1.1.1.1"), nil, nlriList)
+		pkt, err := mpReach.Encode()
+		if err != nil {
+			t.Fatal("VPN MPReachNLRI encode failed with error:", err)
+		}
+
+		newMPReach := NewBGPPathAttrMPReachNLRI()
+		err = newMPReach.Decode(pkt, BGPPeerAttrs{ASSize: 4})
+		if err != nil {
+			t.Fatal("VPN MPReachNLRI decode failed with error:", err)
+		}
+		if _, ok := newMPReach.NextHop.(*MPNextHopVPN); !ok {
+			t.Fatal("VPN MPReachNLRI decode expected VPN next hop, got", newMPReach.NextHop)
+		}
+		if !newMPReach.NextHop.GetNextHop().Equal(net.ParseIP("1.1.1.1")) {
+			t.Fatal("VPN MPReachNLRI next hop expected 1.1.1.1, got", newMPReach.NextHop.GetNextHop())
+		}
+		if len(newMPReach.NLRI) != 1 || newMPReach.NLRI[0].GetCIDR() != nlriList[0].GetCIDR() {
+			t.Fatal("VPN MPReachNLRI decode expected NLRI", nlriList, "got", newMPReach.NLRI)
+		}
+
+		newPkt, _ := newMPReach.Encode()
+		if !bytes.Equal(pkt, newPkt) {
+			t.Fatal("VPN MPReachNLRI encoded packets differ", pkt, newPkt)
+		}
+	}
+}
+
+func TestMPNextHopVPNDecodeShort(t *testing.T) {
+	shortPkts := [][]byte{
+		[]byte{},
+		[]byte{BGPRouteDistinguisherLen + 4, 0, 0, 0},
+		[]byte{BGPRouteDistinguisherLen + 16, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1},
+	}
+	for _, pkt := range shortPkts {
+		nextHop := &MPNextHopVPN{}
+		if err := nextHop.Decode(pkt); err == nil {
+			t.Fatal("VPN next hop decode of short packet", pkt, "did not fail")
+		}
+	}
+}
+
+func TestRouteTargets(t *testing.T) {
+	rt1, err := ParseRouteTarget("65000:1")
+	if err != nil {
+		t.Fatal("ParseRouteTarget failed with error:", err)
+	}
+	rt2, _ := ParseRouteTarget("rt:10.1.1.1:2")
+	soo, _ := ParseExtCommunity("soo:65000:1")
+	if !IsRouteTarget(rt1) || !IsRouteTarget(rt2) || IsRouteTarget(soo) {
+		t.Fatal("IsRouteTarget returned unexpected results")
+	}
+
+	pathAttrs := SetExtCommunities(ConstructPathAttrForConnRoutes(65000), []uint64{soo, rt1})
+	pathAttrs = SetRouteTargets(pathAttrs, []uint64{rt2})
+	routeTargets := GetRouteTargets(pathAttrs)
+	if len(routeTargets) != 1 || routeTargets[0] != rt2 {
+		t.Fatal("GetRouteTargets expected", rt2, "got", routeTargets)
+	}
+	if len(GetExtCommunities(pathAttrs)) != 2 {
+		t.Fatal("SetRouteTargets did not retain the other extended communities", GetExtCommunities(pathAttrs))
+	}
+	if !HasRouteTarget(pathAttrs, []uint64{rt1, rt2}) || HasRouteTarget(pathAttrs, []uint64{rt1}) {
+		t.Fatal("HasRouteTarget returned unexpected results")
+	}
+}
+
+func TestGetProtocolFromOpenMsgUnsupported(t *testing.T) {
+	capabilities := []BGPCapability{
+		NewBGPCapMPExt(AfiIP, SafiUnicast),
+		NewBGPCapMPExt(AfiIP, SafiMPLSVPN),
+		NewBGPCapMPExt(AfiIP, SAFI(70)),
+	}
+	optParams := []BGPOptParam{NewBGPOptParamCapability(capabilities)}
+	openMsg := NewBGPOpenMessage(65000, 180, "10.1.1.1", optParams)
+	afiSafiMap := GetProtocolFromOpenMsg(openMsg.Body.(*BGPOpen))
+	if len(afiSafiMap) != 2 || !afiSafiMap[GetProtocolFamily(AfiIP, SafiUnicast)] ||
+		!afiSafiMap[GetProtocolFamily(AfiIP, SafiMPLSVPN)] {
+		t.Fatal("GetProtocolFromOpenMsg expected IPv4 unicast and VPNv4 families, got", afiSafiMap)
+	}
+}
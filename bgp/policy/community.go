@@ -0,0 +1,327 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// community.go
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"l3/bgp/packet"
+	"strings"
+	"sync"
+)
+
+const (
+	ConditionTypeMatchCommunity = "MatchCommunity"
+)
+
+const (
+	ActionTypeSetCommunity    = "SetCommunity"
+	ActionTypeAddCommunity    = "AddCommunity"
+	ActionTypeDeleteCommunity = "DeleteCommunity"
+)
+
+const (
+	CommunityMatchAny = "any"
+	CommunityMatchAll = "all"
+)
+
+type CommunitySet struct {
+	Communities      []uint32
+	ExtCommunities   []uint64
+	LargeCommunities []packet.BGPLargeCommunity
+}
+
+func ParseCommunitySet(strs []string) (CommunitySet, error) {
+	var set CommunitySet
+	for _, str := range strs {
+		str = strings.TrimSpace(str)
+		if community, err := packet.ParseCommunity(str); err == nil {
+			set.Communities = append(set.Communities, community)
+		} else if extComm, err := packet.ParseExtCommunity(str); err == nil {
+			set.ExtCommunities = append(set.ExtCommunities, extComm)
+		} else if largeComm, err := packet.ParseLargeCommunity(str); err == nil {
+			set.LargeCommunities = append(set.LargeCommunities, largeComm)
+		} else {
+			return set, errors.New(fmt.Sprintf("Invalid community %s", str))
+		}
+	}
+	return set, nil
+}
+
+func (s CommunitySet) isEmpty() bool {
+	return len(s.Communities) == 0 && len(s.ExtCommunities) == 0 && len(s.LargeCommunities) == 0
+}
+
+type CommunityCondition struct {
+	Name      string
+	MatchType string
+	CommunitySet
+}
+
+func NewCommunityCondition(name, matchType string, communities []string) (*CommunityCondition, error) {
+	if matchType == "" {
+		matchType = CommunityMatchAny
+	}
+	if matchType != CommunityMatchAny && matchType != CommunityMatchAll {
+		return nil, errors.New(fmt.Sprintf("Invalid community match type %s", matchType))
+	}
+
+	set, err := ParseCommunitySet(communities)
+	if err != nil {
+		return nil, err
+	}
+	if set.isEmpty() {
+		return nil, errors.New(fmt.Sprintf("Community condition %s does not have any communities", name))
+	}
+
+	return &CommunityCondition{
+		Name:         name,
+		MatchType:    matchType,
+		CommunitySet: set,
+	}, nil
+}
+
+// Match returns true if the path attributes carry any (or all, based on the match type) of the communities in
+// the condition.
+func (c *CommunityCondition) Match(pathAttrs []packet.BGPPathAttr) bool {
+	matchAll := c.MatchType == CommunityMatchAll
+	communities := packet.GetCommunities(pathAttrs)
+	for _, community := range c.Communities {
+		found := false
+		for _, pathComm := range communities {
+			if pathComm == community {
+				found = true
+				break
+			}
+		}
+		if found != matchAll {
+			return found
+		}
+	}
+
+	extComms := packet.GetExtCommunities(pathAttrs)
+	for _, extComm := range c.ExtCommunities {
+		found := false
+		for _, pathExtComm := range extComms {
+			if pathExtComm == extComm {
+				found = true
+				break
+			}
+		}
+		if found != matchAll {
+			return found
+		}
+	}
+
+	largeComms := packet.GetLargeCommunities(pathAttrs)
+	for _, largeComm := range c.LargeCommunities {
+		found := false
+		for _, pathLargeComm := range largeComms {
+			if pathLargeComm == largeComm {
+				found = true
+				break
+			}
+		}
+		if found != matchAll {
+			return found
+		}
+	}
+
+	return matchAll
+}
+
+type CommunityAction struct {
+	Name       string
+	ActionType string
+	CommunitySet
+}
+
+func NewCommunityAction(name, actionType string, communities []string) (*CommunityAction, error) {
+	if actionType != ActionTypeSetCommunity && actionType != ActionTypeAddCommunity &&
+		actionType != ActionTypeDeleteCommunity {
+		return nil, errors.New(fmt.Sprintf("Invalid community action type %s", actionType))
+	}
+
+	set, err := ParseCommunitySet(communities)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommunityAction{
+		Name:         name,
+		ActionType:   actionType,
+		CommunitySet: set,
+	}, nil
+}
+
+// Apply returns a copy of the path attributes with the communities set, added or deleted. The path attributes
+// passed in are not modified.
+func (a *CommunityAction) Apply(pathAttrs []packet.BGPPathAttr) []packet.BGPPathAttr {
+	switch a.ActionType {
+	case ActionTypeSetCommunity:
+		pathAttrs = packet.SetCommunities(pathAttrs, a.Communities)
+		pathAttrs = packet.SetExtCommunities(pathAttrs, a.ExtCommunities)
+		pathAttrs = packet.SetLargeCommunities(pathAttrs, a.LargeCommunities)
+
+	case ActionTypeAddCommunity:
+		communities := append([]uint32(nil), packet.GetCommunities(pathAttrs)...)
+		for _, community := range a.Communities {
+			if !containsCommunity(communities, community) {
+				communities = append(communities, community)
+			}
+		}
+		pathAttrs = packet.SetCommunities(pathAttrs, communities)
+
+		extComms := append([]uint64(nil), packet.GetExtCommunities(pathAttrs)...)
+		for _, extComm := range a.ExtCommunities {
+			if !containsExtCommunity(extComms, extComm) {
+				extComms = append(extComms, extComm)
+			}
+		}
+		pathAttrs = packet.SetExtCommunities(pathAttrs, extComms)
+
+		largeComms := append([]packet.BGPLargeCommunity(nil), packet.GetLargeCommunities(pathAttrs)...)
+		for _, largeComm := range a.LargeCommunities {
+			if !containsLargeCommunity(largeComms, largeComm) {
+				largeComms = append(largeComms, largeComm)
+			}
+		}
+		pathAttrs = packet.SetLargeCommunities(pathAttrs, largeComms)
+
+	case ActionTypeDeleteCommunity:
+		communities := make([]uint32, 0)
+		for _, community := range packet.GetCommunities(pathAttrs) {
+			if !containsCommunity(a.Communities, community) {
+				communities = append(communities, community)
+			}
+		}
+		pathAttrs = packet.SetCommunities(pathAttrs, communities)
+
+		extComms := make([]uint64, 0)
+		for _, extComm := range packet.GetExtCommunities(pathAttrs) {
+			if !containsExtCommunity(a.ExtCommunities, extComm) {
+				extComms = append(extComms, extComm)
+			}
+		}
+		pathAttrs = packet.SetExtCommunities(pathAttrs, extComms)
+
+		largeComms := make([]packet.BGPLargeCommunity, 0)
+		for _, largeComm := range packet.GetLargeCommunities(pathAttrs) {
+			if !containsLargeCommunity(a.LargeCommunities, largeComm) {
+				largeComms = append(largeComms, largeComm)
+			}
+		}
+		pathAttrs = packet.SetLargeCommunities(pathAttrs, largeComms)
+	}
+
+	return pathAttrs
+}
+
+func containsCommunity(communities []uint32, community uint32) bool {
+	for _, comm := range communities {
+		if comm == community {
+			return true
+		}
+	}
+	return false
+}
+
+func containsExtCommunity(extComms []uint64, extComm uint64) bool {
+	for _, comm := range extComms {
+		if comm == extComm {
+			return true
+		}
+	}
+	return false
+}
+
+func containsLargeCommunity(largeComms []packet.BGPLargeCommunity, largeComm packet.BGPLargeCommunity) bool {
+	for _, comm := range largeComms {
+		if comm == largeComm {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCommunityActionsKey returns a key that identifies the list of actions, so that the paths modified by the
+// same actions can be grouped together. The actions are applied in order, so the key keeps the order of the
+// actions.
+func GetCommunityActionsKey(actions []*CommunityAction) string {
+	names := make([]string, 0, len(actions))
+	for _, action := range actions {
+		names = append(names, action.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+type CommunityPolicyDB struct {
+	sync.RWMutex
+	conditions map[string]*CommunityCondition
+	actions    map[string]*CommunityAction
+}
+
+func NewCommunityPolicyDB() *CommunityPolicyDB {
+	return &CommunityPolicyDB{
+		conditions: make(map[string]*CommunityCondition),
+		actions:    make(map[string]*CommunityAction),
+	}
+}
+
+func (db *CommunityPolicyDB) AddCondition(condition *CommunityCondition) {
+	db.Lock()
+	defer db.Unlock()
+	db.conditions[condition.Name] = condition
+}
+
+func (db *CommunityPolicyDB) DeleteCondition(name string) {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.conditions, name)
+}
+
+func (db *CommunityPolicyDB) GetCondition(name string) *CommunityCondition {
+	db.RLock()
+	defer db.RUnlock()
+	return db.conditions[name]
+}
+
+func (db *CommunityPolicyDB) AddAction(action *CommunityAction) {
+	db.Lock()
+	defer db.Unlock()
+	db.actions[action.Name] = action
+}
+
+func (db *CommunityPolicyDB) DeleteAction(name string) {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.actions, name)
+}
+
+func (db *CommunityPolicyDB) GetAction(name string) *CommunityAction {
+	db.RLock()
+	defer db.RUnlock()
+	return db.actions[name]
+}
@@ -0,0 +1,150 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// community_test.go
+package policy
+
+import (
+	"l3/bgp/packet"
+	"reflect"
+	"testing"
+)
+
+func getCommunityTestPathAttrs(t *testing.T, communities []string) []packet.BGPPathAttr {
+	set, err := ParseCommunitySet(communities)
+	if err != nil {
+		t.Fatal("Failed to parse communities", communities, "with error", err)
+	}
+
+	pathAttrs := make([]packet.BGPPathAttr, 0)
+	pathAttrs = packet.SetCommunities(pathAttrs, set.Communities)
+	pathAttrs = packet.SetExtCommunities(pathAttrs, set.ExtCommunities)
+	pathAttrs = packet.SetLargeCommunities(pathAttrs, set.LargeCommunities)
+	return pathAttrs
+}
+
+func TestCommunityConditionMatch(t *testing.T) {
+	tests := []struct {
+		matchType   string
+		communities []string
+		pathComms   []string
+		match       bool
+	}{
+		{CommunityMatchAny, []string{"65000:100", "65000:200"}, []string{"65000:200"}, true},
+		{CommunityMatchAny, []string{"65000:100", "65000:200"}, []string{"65000:300"}, false},
+		{CommunityMatchAny, []string{"65000:100"}, []string{}, false},
+		{CommunityMatchAll, []string{"65000:100", "65000:200"}, []string{"65000:100", "65000:200", "65000:300"}, true},
+		{CommunityMatchAll, []string{"65000:100", "65000:200"}, []string{"65000:100"}, false},
+		{CommunityMatchAny, []string{"rt:65000:10"}, []string{"65000:100", "rt:65000:10"}, true},
+		{CommunityMatchAll, []string{"65000:100", "rt:65000:10"}, []string{"65000:100", "rt:65000:20"}, false},
+		{CommunityMatchAny, []string{"65000:1:2"}, []string{"65000:1:2"}, true},
+		{CommunityMatchAll, []string{"65000:100", "rt:65000:10", "65000:1:2"},
+			[]string{"65000:100", "rt:65000:10", "65000:1:2"}, true},
+		{CommunityMatchAll, []string{"65000:100", "rt:65000:10", "65000:1:2"},
+			[]string{"65000:100", "rt:65000:10", "65000:1:3"}, false},
+	}
+
+	for idx, test := range tests {
+		cond, err := NewCommunityCondition("cond", test.matchType, test.communities)
+		if err != nil {
+			t.Fatal("Test", idx, "- failed to create community condition with error", err)
+		}
+		pathAttrs := getCommunityTestPathAttrs(t, test.pathComms)
+		if match := cond.Match(pathAttrs); match != test.match {
+			t.Error("Test", idx, "- community condition", test.matchType, test.communities, "match with",
+				test.pathComms, "returned", match, "expected", test.match)
+		}
+	}
+}
+
+func TestCommunityActionApply(t *testing.T) {
+	tests := []struct {
+		actionType  string
+		communities []string
+		pathComms   []string
+		result      []string
+	}{
+		{ActionTypeSetCommunity, []string{"65000:100"}, []string{"65000:200", "rt:65000:10"}, []string{"65000:100"}},
+		{ActionTypeSetCommunity, []string{}, []string{"65000:200", "65000:1:2"}, []string{}},
+		{ActionTypeAddCommunity, []string{"65000:100", "65000:200"}, []string{"65000:200"},
+			[]string{"65000:200", "65000:100"}},
+		{ActionTypeAddCommunity, []string{"rt:65000:10", "65000:1:2"}, []string{"65000:200"},
+			[]string{"65000:200", "rt:65000:10", "65000:1:2"}},
+		{ActionTypeDeleteCommunity, []string{"65000:100", "rt:65000:10"},
+			[]string{"65000:100", "65000:200", "rt:65000:10", "65000:1:2"}, []string{"65000:200", "65000:1:2"}},
+		{ActionTypeDeleteCommunity, []string{"65000:300"}, []string{"65000:100"}, []string{"65000:100"}},
+	}
+
+	for idx, test := range tests {
+		action, err := NewCommunityAction("action", test.actionType, test.communities)
+		if err != nil {
+			t.Fatal("Test", idx, "- failed to create community action with error", err)
+		}
+		pathAttrs := getCommunityTestPathAttrs(t, test.pathComms)
+		origAttrs := packet.CopyPathAttrs(pathAttrs)
+		newAttrs := action.Apply(pathAttrs)
+		if !reflect.DeepEqual(pathAttrs, origAttrs) {
+			t.Error("Test", idx, "- community action", test.actionType, "modified the path attrs passed in")
+		}
+
+		expected := getCommunityTestPathAttrs(t, test.result)
+		if !reflect.DeepEqual(packet.GetCommunities(newAttrs), packet.GetCommunities(expected)) ||
+			!reflect.DeepEqual(packet.GetExtCommunities(newAttrs), packet.GetExtCommunities(expected)) ||
+			!reflect.DeepEqual(packet.GetLargeCommunities(newAttrs), packet.GetLargeCommunities(expected)) {
+			t.Error("Test", idx, "- community action", test.actionType, test.communities, "applied to",
+				test.pathComms, "returned communities", packet.GetCommunities(newAttrs),
+				packet.GetExtCommunities(newAttrs), packet.GetLargeCommunities(newAttrs), "expected", test.result)
+		}
+	}
+}
+
+func TestCommunityActionsOrder(t *testing.T) {
+	addAction, err := NewCommunityAction("add", ActionTypeAddCommunity, []string{"65000:100"})
+	if err != nil {
+		t.Fatal("Failed to create add community action with error", err)
+	}
+	delAction, err := NewCommunityAction("delete", ActionTypeDeleteCommunity, []string{"65000:100"})
+	if err != nil {
+		t.Fatal("Failed to create delete community action with error", err)
+	}
+
+	pathAttrs := getCommunityTestPathAttrs(t, []string{"65000:200"})
+	addThenDel := delAction.Apply(addAction.Apply(pathAttrs))
+	if comms := packet.GetCommunities(addThenDel); !reflect.DeepEqual(comms,
+		packet.GetCommunities(getCommunityTestPathAttrs(t, []string{"65000:200"}))) {
+		t.Error("Add then delete community returned", comms)
+	}
+	delThenAdd := addAction.Apply(delAction.Apply(pathAttrs))
+	if comms := packet.GetCommunities(delThenAdd); !reflect.DeepEqual(comms,
+		packet.GetCommunities(getCommunityTestPathAttrs(t, []string{"65000:200", "65000:100"}))) {
+		t.Error("Delete then add community returned", comms)
+	}
+
+	keys := []string{
+		GetCommunityActionsKey([]*CommunityAction{addAction, delAction}),
+		GetCommunityActionsKey([]*CommunityAction{delAction, addAction}),
+	}
+	if keys[0] == keys[1] {
+		t.Error("Community actions key", keys[0], "is the same for the actions applied in different order")
+	}
+}
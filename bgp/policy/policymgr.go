@@ -28,6 +28,7 @@ import (
 	_ "fmt"
 	"l3/bgp/config"
 	"models/objects"
+	"sync"
 	"utils/dbutils"
 	"utils/logging"
 	utilspolicy "utils/policy"
@@ -38,6 +39,11 @@ var PolicyManager *BGPPolicyManager
 type BGPPolicyManager struct {
 	logger          *logging.Writer
 	policyEngines   []BGPPolicyEngine
+	engineMutex     sync.Mutex
+	conditionCfgs   map[string]utilspolicy.PolicyConditionConfig
+	actionCfgs      map[string]utilspolicy.PolicyActionConfig
+	stmtCfgs        map[string]utilspolicy.PolicyStmtConfig
+	definitionCfgs  map[string]utilspolicy.PolicyDefinitionConfig
 	ConditionCfgCh  chan utilspolicy.PolicyConditionConfig
 	ActionCfgCh     chan utilspolicy.PolicyActionConfig
 	StmtCfgCh       chan utilspolicy.PolicyStmtConfig
@@ -46,6 +52,8 @@ type BGPPolicyManager struct {
 	ActionDelCh     chan string
 	StmtDelCh       chan string
 	DefinitionDelCh chan string
+	CommunityDB     *CommunityPolicyDB
+	ValidationDB    *ValidationPolicyDB
 	policyPlugin    config.PolicyMgrIntf
 }
 
@@ -54,6 +62,10 @@ func NewPolicyManager(logger *logging.Writer, pMgr config.PolicyMgrIntf) *BGPPol
 		policyManager := &BGPPolicyManager{}
 		policyManager.logger = logger
 		policyManager.policyEngines = make([]BGPPolicyEngine, 0)
+		policyManager.conditionCfgs = make(map[string]utilspolicy.PolicyConditionConfig)
+		policyManager.actionCfgs = make(map[string]utilspolicy.PolicyActionConfig)
+		policyManager.stmtCfgs = make(map[string]utilspolicy.PolicyStmtConfig)
+		policyManager.definitionCfgs = make(map[string]utilspolicy.PolicyDefinitionConfig)
 		policyManager.ConditionCfgCh = make(chan utilspolicy.PolicyConditionConfig)
 		policyManager.ActionCfgCh = make(chan utilspolicy.PolicyActionConfig)
 		policyManager.StmtCfgCh = make(chan utilspolicy.PolicyStmtConfig)
@@ -62,6 +74,8 @@ func NewPolicyManager(logger *logging.Writer, pMgr config.PolicyMgrIntf) *BGPPol
 		policyManager.ActionDelCh = make(chan string)
 		policyManager.StmtDelCh = make(chan string)
 		policyManager.DefinitionDelCh = make(chan string)
+		policyManager.CommunityDB = NewCommunityPolicyDB()
+		policyManager.ValidationDB = NewValidationPolicyDB()
 		policyManager.policyPlugin = pMgr
 		PolicyManager = policyManager
 	}
@@ -69,8 +83,107 @@ func NewPolicyManager(logger *logging.Writer, pMgr config.PolicyMgrIntf) *BGPPol
 	return PolicyManager
 }
 
+// AddPolicyEngine registers a policy engine with the manager. The policy objects that are already configured are
+// replayed to the engine, so that the engines of a BGP instance created at run time are in sync with the others.
 func (eng *BGPPolicyManager) AddPolicyEngine(bgpPE BGPPolicyEngine) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
 	eng.policyEngines = append(eng.policyEngines, bgpPE)
+	for _, condCfg := range eng.conditionCfgs {
+		bgpPE.CreatePolicyCondition(condCfg)
+	}
+	for _, actionCfg := range eng.actionCfgs {
+		bgpPE.CreatePolicyAction(actionCfg)
+	}
+	for _, stmtCfg := range eng.stmtCfgs {
+		bgpPE.CreatePolicyStmt(stmtCfg)
+	}
+	for _, defCfg := range eng.definitionCfgs {
+		bgpPE.CreatePolicyDefinition(defCfg)
+	}
+}
+
+func (eng *BGPPolicyManager) RemovePolicyEngine(bgpPE BGPPolicyEngine) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	for idx, pe := range eng.policyEngines {
+		if pe == bgpPE {
+			eng.policyEngines = append(eng.policyEngines[:idx], eng.policyEngines[idx+1:]...)
+			break
+		}
+	}
+}
+
+func (eng *BGPPolicyManager) createPolicyCondition(condCfg utilspolicy.PolicyConditionConfig) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	eng.conditionCfgs[condCfg.Name] = condCfg
+	for _, pe := range eng.policyEngines {
+		pe.CreatePolicyCondition(condCfg)
+	}
+}
+
+func (eng *BGPPolicyManager) createPolicyAction(actionCfg utilspolicy.PolicyActionConfig) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	eng.actionCfgs[actionCfg.Name] = actionCfg
+	for _, pe := range eng.policyEngines {
+		pe.CreatePolicyAction(actionCfg)
+	}
+}
+
+func (eng *BGPPolicyManager) createPolicyStmt(stmtCfg utilspolicy.PolicyStmtConfig) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	eng.stmtCfgs[stmtCfg.Name] = stmtCfg
+	for _, pe := range eng.policyEngines {
+		pe.CreatePolicyStmt(stmtCfg)
+	}
+}
+
+func (eng *BGPPolicyManager) createPolicyDefinition(defCfg utilspolicy.PolicyDefinitionConfig) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	eng.definitionCfgs[defCfg.Name] = defCfg
+	for _, pe := range eng.policyEngines {
+		pe.CreatePolicyDefinition(defCfg)
+	}
+}
+
+func (eng *BGPPolicyManager) deletePolicyCondition(conditionName string) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	delete(eng.conditionCfgs, conditionName)
+	for _, pe := range eng.policyEngines {
+		pe.DeletePolicyCondition(conditionName)
+	}
+}
+
+func (eng *BGPPolicyManager) deletePolicyAction(actionName string) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	delete(eng.actionCfgs, actionName)
+	for _, pe := range eng.policyEngines {
+		pe.DeletePolicyAction(actionName)
+	}
+}
+
+func (eng *BGPPolicyManager) deletePolicyStmt(stmtName string) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	delete(eng.stmtCfgs, stmtName)
+	for _, pe := range eng.policyEngines {
+		pe.DeletePolicyStmt(stmtName)
+	}
+}
+
+func (eng *BGPPolicyManager) deletePolicyDefinition(policyName string) {
+	defer eng.engineMutex.Unlock()
+	eng.engineMutex.Lock()
+	delete(eng.definitionCfgs, policyName)
+	for _, pe := range eng.policyEngines {
+		pe.DeletePolicyDefinition(policyName)
+	}
 }
 
 func convertModelsToPolicyCondition(cfg objects.PolicyCondition) *utilspolicy.PolicyConditionConfig {
@@ -98,11 +211,65 @@ func (eng *BGPPolicyManager) readPolicyConditions(dbUtil *dbutils.DBUtil) error
 	}
 
 	for idx := 0; idx < len(conditionList); idx++ {
-		policyCondCfg := convertModelsToPolicyCondition(conditionList[idx].(objects.PolicyCondition))
+		conditionCfg := conditionList[idx].(objects.PolicyCondition)
+		if conditionCfg.ConditionType == ConditionTypeMatchCommunity {
+			commCond, err := NewCommunityCondition(conditionCfg.Name, conditionCfg.CommunityMatchType,
+				conditionCfg.Communities)
+			if err != nil {
+				eng.logger.Err("readPolicyConditions - create community condition", conditionCfg.Name,
+					"failed with error", err)
+				continue
+			}
+			eng.CommunityDB.AddCondition(commCond)
+		} else if conditionCfg.ConditionType == ConditionTypeMatchValidationState {
+			validationCond, err := NewValidationStateCondition(conditionCfg.Name, conditionCfg.ValidationState)
+			if err != nil {
+				eng.logger.Err("readPolicyConditions - create validation state condition", conditionCfg.Name,
+					"failed with error", err)
+				continue
+			}
+			eng.ValidationDB.AddCondition(validationCond)
+		}
+		policyCondCfg := convertModelsToPolicyCondition(conditionCfg)
 		eng.logger.Info("readPolicyConditions - create policy condition", policyCondCfg.Name)
-		for _, pe := range eng.policyEngines {
-			pe.CreatePolicyCondition(*policyCondCfg)
+		eng.createPolicyCondition(*policyCondCfg)
+	}
+	return nil
+}
+
+func convertModelsToPolicyAction(cfg objects.BGPPolicyAction) *utilspolicy.PolicyActionConfig {
+	return &utilspolicy.PolicyActionConfig{
+		Name:            cfg.Name,
+		ActionType:      cfg.ActionType,
+		GenerateASSet:   cfg.GenerateASSet,
+		SendSummaryOnly: cfg.SendSummaryOnly,
+	}
+}
+
+func (eng *BGPPolicyManager) readPolicyActions(dbUtil *dbutils.DBUtil) error {
+	eng.logger.Info("readPolicyActions")
+	var actionObj objects.BGPPolicyAction
+	actionList, err := dbUtil.GetAllObjFromDb(actionObj)
+	if err != nil {
+		eng.logger.Err("readPolicyActions - GetAllObjFromDb for policy action failed with error", err)
+		return err
+	}
+
+	for idx := 0; idx < len(actionList); idx++ {
+		actionCfg := actionList[idx].(objects.BGPPolicyAction)
+		switch actionCfg.ActionType {
+		case ActionTypeSetCommunity, ActionTypeAddCommunity, ActionTypeDeleteCommunity:
+			commAction, err := NewCommunityAction(actionCfg.Name, actionCfg.ActionType, actionCfg.Communities)
+			if err != nil {
+				eng.logger.Err("readPolicyActions - create community action", actionCfg.Name,
+					"failed with error", err)
+				continue
+			}
+			eng.CommunityDB.AddAction(commAction)
 		}
+		policyActionCfg := convertModelsToPolicyAction(actionCfg)
+		eng.logger.Info("readPolicyActions - create policy action", policyActionCfg.Name)
+		eng.createPolicyAction(*policyActionCfg)
 	}
 	return nil
 }
@@ -131,9 +298,7 @@ func (eng *BGPPolicyManager) readPolicyStmts(dbUtil *dbutils.DBUtil) error {
 	for idx := 0; idx < len(stmtList); idx++ {
 		policyStmtCfg := convertModelsToPolicyStmt(stmtList[idx].(objects.PolicyStmt))
 		eng.logger.Info("readPolicyStmts - create policy statement", policyStmtCfg.Name)
-		for _, pe := range eng.policyEngines {
-			pe.CreatePolicyStmt(*policyStmtCfg)
-		}
+		eng.createPolicyStmt(*policyStmtCfg)
 	}
 	return nil
 }
@@ -169,9 +334,7 @@ func (eng *BGPPolicyManager) readPolicyDefinitions(dbUtil *dbutils.DBUtil) error
 	for idx := 0; idx < len(definitionList); idx++ {
 		policyDefCfg := convertModelsToPolicyDefinition(definitionList[idx].(objects.PolicyDefinition))
 		eng.logger.Info("readPolicyDefinitions - create policy definition", policyDefCfg.Name)
-		for _, pe := range eng.policyEngines {
-			pe.CreatePolicyDefinition(*policyDefCfg)
-		}
+		eng.createPolicyDefinition(*policyDefCfg)
 	}
 	return nil
 }
@@ -179,6 +342,7 @@ func (eng *BGPPolicyManager) readPolicyDefinitions(dbUtil *dbutils.DBUtil) error
 func (eng *BGPPolicyManager) StartPolicyEngine(dbUtil *dbutils.DBUtil, doneCh chan bool) {
 	eng.policyPlugin.Start()
 	eng.readPolicyConditions(dbUtil)
+	eng.readPolicyActions(dbUtil)
 	eng.readPolicyStmts(dbUtil)
 	eng.readPolicyDefinitions(dbUtil)
 	doneCh <- true
@@ -186,51 +350,38 @@ func (eng *BGPPolicyManager) StartPolicyEngine(dbUtil *dbutils.DBUtil, doneCh ch
 		select {
 		case condCfg := <-eng.ConditionCfgCh:
 			eng.logger.Info("BGPPolicyEngine - create policy condition", condCfg.Name)
-			for _, pe := range eng.policyEngines {
-				pe.CreatePolicyCondition(condCfg)
-			}
+			eng.createPolicyCondition(condCfg)
 
 		case actionCfg := <-eng.ActionCfgCh:
 			eng.logger.Info("BGPPolicyEngine - create policy action", actionCfg.Name)
-			for _, pe := range eng.policyEngines {
-				pe.CreatePolicyAction(actionCfg)
-			}
+			eng.createPolicyAction(actionCfg)
 
 		case stmtCfg := <-eng.StmtCfgCh:
 			eng.logger.Info("BGPPolicyEngine - create policy statement", stmtCfg.Name)
-			for _, pe := range eng.policyEngines {
-				pe.CreatePolicyStmt(stmtCfg)
-			}
+			eng.createPolicyStmt(stmtCfg)
 
 		case defCfg := <-eng.DefinitionCfgCh:
 			eng.logger.Info("BGPPolicyEngine - create policy definition", defCfg.Name)
-			for _, pe := range eng.policyEngines {
-				pe.CreatePolicyDefinition(defCfg)
-			}
+			eng.createPolicyDefinition(defCfg)
 
 		case conditionName := <-eng.ConditionDelCh:
 			eng.logger.Info("BGPPolicyEngine - delete policy condition", conditionName)
-			for _, pe := range eng.policyEngines {
-				pe.DeletePolicyCondition(conditionName)
-			}
+			eng.CommunityDB.DeleteCondition(conditionName)
+			eng.ValidationDB.DeleteCondition(conditionName)
+			eng.deletePolicyCondition(conditionName)
 
 		case actionName := <-eng.ActionDelCh:
 			eng.logger.Info("BGPPolicyEngine - delete policy action", actionName)
-			for _, pe := range eng.policyEngines {
-				pe.DeletePolicyAction(actionName)
-			}
+			eng.CommunityDB.DeleteAction(actionName)
+			eng.deletePolicyAction(actionName)
 
 		case stmtName := <-eng.StmtDelCh:
 			eng.logger.Info("BGPPolicyEngine - delete policy statment", stmtName)
-			for _, pe := range eng.policyEngines {
-				pe.DeletePolicyStmt(stmtName)
-			}
+			eng.deletePolicyStmt(stmtName)
 
 		case policyName := <-eng.DefinitionDelCh:
 			eng.logger.Info("BGPPolicyEngine - delete policy definition", policyName)
-			for _, pe := range eng.policyEngines {
-				pe.DeletePolicyDefinition(policyName)
-			}
+			eng.deletePolicyDefinition(policyName)
 		}
 	}
 }
@@ -0,0 +1,85 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// validation.go
+package policy
+
+import (
+	"l3/bgp/rpki"
+	"sync"
+)
+
+const (
+	ConditionTypeMatchValidationState = "MatchValidationState"
+)
+
+// ValidationStateCondition matches the routes with the RPKI origin validation state, RFC 6811.
+type ValidationStateCondition struct {
+	Name  string
+	State rpki.ValidationState
+}
+
+func NewValidationStateCondition(name, state string) (*ValidationStateCondition, error) {
+	validationState, err := rpki.ParseValidationState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidationStateCondition{
+		Name:  name,
+		State: validationState,
+	}, nil
+}
+
+func (c *ValidationStateCondition) Match(state rpki.ValidationState) bool {
+	return c.State == state
+}
+
+type ValidationPolicyDB struct {
+	sync.RWMutex
+	conditions map[string]*ValidationStateCondition
+}
+
+func NewValidationPolicyDB() *ValidationPolicyDB {
+	return &ValidationPolicyDB{
+		conditions: make(map[string]*ValidationStateCondition),
+	}
+}
+
+func (db *ValidationPolicyDB) AddCondition(condition *ValidationStateCondition) {
+	db.Lock()
+	defer db.Unlock()
+	db.conditions[condition.Name] = condition
+}
+
+func (db *ValidationPolicyDB) DeleteCondition(name string) {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.conditions, name)
+}
+
+func (db *ValidationPolicyDB) GetCondition(name string) *ValidationStateCondition {
+	db.RLock()
+	defer db.RUnlock()
+	return db.conditions[name]
+}
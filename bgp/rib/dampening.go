@@ -0,0 +1,289 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// dampening.go
+package rib
+
+import (
+	"l3/bgp/baseobjects"
+	"l3/bgp/config"
+	"math"
+	"time"
+)
+
+const (
+	DampeningPenaltyWithdraw   float64 = 1000
+	DampeningPenaltyAttrChange float64 = 500
+)
+
+// DampeningReuseInterval is the interval at which the penalties of the dampened paths are decayed and the
+// suppressed paths are checked for reuse.
+const DampeningReuseInterval time.Duration = 5 * time.Second
+
+// DampeningInfo is the RFC 2439 figure of merit of the path received from a peer for a destination. It is kept
+// in the LocRib and not in the destination so that the history of a flapping prefix survives its withdrawal.
+type DampeningInfo struct {
+	neighborConf *base.NeighborConf
+	penalty      float64
+	flapCount    uint32
+	lastUpdated  time.Time
+	suppressed   bool
+	suppressTime time.Time
+	withdrawn    bool
+}
+
+func newDampeningInfo(neighborConf *base.NeighborConf, now time.Time) *DampeningInfo {
+	return &DampeningInfo{
+		neighborConf: neighborConf,
+		lastUpdated:  now,
+	}
+}
+
+func getDampeningParams(dampConf *config.DampeningConfig) (halfLife, maxSuppressTime time.Duration, reuse,
+	suppress float64) {
+	halfLife = time.Duration(config.BGPDampeningHalfLifeDefault) * time.Minute
+	maxSuppressTime = time.Duration(config.BGPDampeningMaxSuppressTimeDefault) * time.Minute
+	reuse = float64(config.BGPDampeningReuseLimitDefault)
+	suppress = float64(config.BGPDampeningSuppressLimitDefault)
+
+	if dampConf.HalfLife != 0 {
+		halfLife = time.Duration(dampConf.HalfLife) * time.Minute
+	}
+	if dampConf.MaxSuppressTime != 0 {
+		maxSuppressTime = time.Duration(dampConf.MaxSuppressTime) * time.Minute
+	}
+	if dampConf.ReuseLimit != 0 {
+		reuse = float64(dampConf.ReuseLimit)
+	}
+	if dampConf.SuppressLimit != 0 {
+		suppress = float64(dampConf.SuppressLimit)
+	}
+	return halfLife, maxSuppressTime, reuse, suppress
+}
+
+func (d *DampeningInfo) decay(halfLife time.Duration, now time.Time) {
+	if elapsed := now.Sub(d.lastUpdated); elapsed > 0 {
+		d.penalty = d.penalty * math.Pow(2, -elapsed.Seconds()/halfLife.Seconds())
+	}
+	d.lastUpdated = now
+}
+
+// addPenalty decays the figure of merit and adds the penalty for a flap. It returns true if the path is suppressed.
+func (d *DampeningInfo) addPenalty(dampConf *config.DampeningConfig, penalty float64, now time.Time) bool {
+	halfLife, maxSuppressTime, reuse, suppress := getDampeningParams(dampConf)
+	d.decay(halfLife, now)
+	d.penalty += penalty
+	d.flapCount++
+
+	// Cap the penalty so that a path is not suppressed for longer than the max suppress time
+	ceiling := reuse * math.Pow(2, maxSuppressTime.Seconds()/halfLife.Seconds())
+	if d.penalty > ceiling {
+		d.penalty = ceiling
+	}
+
+	if !d.suppressed && d.penalty >= suppress {
+		d.suppressed = true
+		d.suppressTime = now
+	}
+	return d.suppressed
+}
+
+// update decays the figure of merit. It returns true if a suppressed path can be reused.
+func (d *DampeningInfo) update(dampConf *config.DampeningConfig, now time.Time) bool {
+	halfLife, maxSuppressTime, reuse, _ := getDampeningParams(dampConf)
+	d.decay(halfLife, now)
+	if d.suppressed && (d.penalty < reuse || now.Sub(d.suppressTime) >= maxSuppressTime) {
+		d.suppressed = false
+		return true
+	}
+	return false
+}
+
+// canBeRemoved returns true if the figure of merit decayed below half of the reuse limit and the history of
+// the path is no longer needed.
+func (d *DampeningInfo) canBeRemoved(dampConf *config.DampeningConfig) bool {
+	_, _, reuse, _ := getDampeningParams(dampConf)
+	return !d.suppressed && d.penalty < reuse/2
+}
+
+func (d *DampeningInfo) getReuseTime(dampConf *config.DampeningConfig, now time.Time) time.Duration {
+	if !d.suppressed {
+		return 0
+	}
+
+	halfLife, maxSuppressTime, reuse, _ := getDampeningParams(dampConf)
+	reuseTime := time.Duration(float64(halfLife) * math.Log2(d.penalty/reuse))
+	if remaining := maxSuppressTime - now.Sub(d.suppressTime); remaining < reuseTime {
+		reuseTime = remaining
+	}
+	if reuseTime < 0 {
+		reuseTime = 0
+	}
+	return reuseTime
+}
+
+func (l *LocRib) getDampeningInfo(protoFamily uint32, cidr string, peerIP string, pathId uint32) *DampeningInfo {
+	if info, ok := l.dampeningMap[protoFamily][cidr][peerIP][pathId]; ok {
+		return info
+	}
+	return nil
+}
+
+func (l *LocRib) addDampeningInfo(protoFamily uint32, cidr string, peerIP string, pathId uint32,
+	info *DampeningInfo) {
+	if _, ok := l.dampeningMap[protoFamily]; !ok {
+		l.dampeningMap[protoFamily] = make(map[string]map[string]map[uint32]*DampeningInfo)
+	}
+	if _, ok := l.dampeningMap[protoFamily][cidr]; !ok {
+		l.dampeningMap[protoFamily][cidr] = make(map[string]map[uint32]*DampeningInfo)
+	}
+	if _, ok := l.dampeningMap[protoFamily][cidr][peerIP]; !ok {
+		l.dampeningMap[protoFamily][cidr][peerIP] = make(map[uint32]*DampeningInfo)
+	}
+	l.dampeningMap[protoFamily][cidr][peerIP][pathId] = info
+}
+
+// dampenPath adds the penalty for a flap of the path to the figure of merit of the path. Only the paths received
+// from eBGP peers with dampening enabled are dampened.
+func (l *LocRib) dampenPath(dest *Destination, peerIP string, pathId uint32, path *Path, penalty float64,
+	withdrawn bool) {
+	// Paths imported from the VPN table are dampened in the VPN table
+	if !path.IsExternal() || path.IsVPNPath() {
+		return
+	}
+
+	dampConf := path.NeighborConf.GetDampeningConfig()
+	if dampConf == nil {
+		return
+	}
+
+	now := time.Now()
+	cidr := dest.NLRI.GetCIDR()
+	info := l.getDampeningInfo(dest.protoFamily, cidr, peerIP, pathId)
+	if info == nil {
+		info = newDampeningInfo(path.NeighborConf, now)
+		l.addDampeningInfo(dest.protoFamily, cidr, peerIP, pathId, info)
+	}
+
+	wasSuppressed := info.suppressed
+	if info.addPenalty(dampConf, penalty, now) && !wasSuppressed {
+		l.logger.Infof("Destination %s path id %d from peer %s is suppressed, penalty %f", cidr, pathId, peerIP,
+			info.penalty)
+	}
+	info.withdrawn = withdrawn
+}
+
+// updateDampening updates the figure of merit of the path when an update is received from the peer. An update
+// that changes the attributes of the path is a flap. An update after the path was withdrawn ends the history
+// state of the path.
+func (l *LocRib) updateDampening(dest *Destination, peerIP string, pathId uint32, oldPath, newPath *Path) {
+	if oldPath != nil && !oldPath.IsStale() {
+		if !oldPath.HasSameAttrs(newPath) {
+			l.dampenPath(dest, peerIP, pathId, newPath, DampeningPenaltyAttrChange, false)
+		}
+		return
+	}
+
+	if info := l.getDampeningInfo(dest.protoFamily, dest.NLRI.GetCIDR(), peerIP, pathId); info != nil {
+		info.withdrawn = false
+	}
+}
+
+// RemoveDampeningInfoForNeighbor removes the figure of merit and the history of the paths from the peer.
+func (l *LocRib) RemoveDampeningInfoForNeighbor(peerIP string) {
+	for protoFamily, cidrMap := range l.dampeningMap {
+		for cidr, peerMap := range cidrMap {
+			delete(peerMap, peerIP)
+			if len(peerMap) == 0 {
+				delete(cidrMap, cidr)
+			}
+		}
+		if len(cidrMap) == 0 {
+			delete(l.dampeningMap, protoFamily)
+		}
+	}
+}
+
+// ProcessDampenedPaths decays the figure of merit of the dampened paths and runs the path selection for the
+// destinations with suppressed paths that can be reused. The history of the paths whose figure of merit decayed
+// below half of the reuse limit is removed.
+func (l *LocRib) ProcessDampenedPaths(addPathCount int) (map[uint32]map[*Path][]*Destination, []*Destination,
+	[]*Destination) {
+	withdrawn := make([]*Destination, 0)
+	updated := make(map[uint32]map[*Path][]*Destination)
+	updatedAddPaths := make([]*Destination, 0)
+	now := time.Now()
+
+	for protoFamily, cidrMap := range l.dampeningMap {
+		for cidr, peerMap := range cidrMap {
+			dest := l.destPathMap[protoFamily][cidr]
+			reuse := false
+			for peerIP, pathIdMap := range peerMap {
+				for pathId, info := range pathIdMap {
+					dampConf := info.neighborConf.GetDampeningConfig()
+					if dampConf == nil {
+						// Dampening was disabled for the peer, release the suppressed path
+						reuse = reuse || info.suppressed
+						delete(pathIdMap, pathId)
+					} else {
+						if info.update(dampConf, now) {
+							l.logger.Infof("Destination %s path id %d from peer %s is reused, penalty %f", cidr,
+								pathId, peerIP, info.penalty)
+							reuse = true
+						}
+						if info.canBeRemoved(dampConf) {
+							delete(pathIdMap, pathId)
+						}
+					}
+
+					if dest != nil {
+						if route := dest.GetPathRoute(dest.getPathForIP(peerIP, pathId)); route != nil {
+							route.setDampeningState(pathIdMap[pathId], now)
+						}
+					}
+				}
+				if len(pathIdMap) == 0 {
+					delete(peerMap, peerIP)
+				}
+			}
+			if len(peerMap) == 0 {
+				delete(cidrMap, cidr)
+			}
+
+			if !reuse || dest == nil {
+				continue
+			}
+
+			dest.recalculate = true
+			action, addPathsMod, addRoutes, updRoutes, delRoutes := dest.SelectRouteForLocRib(addPathCount)
+			updated, withdrawn, updatedAddPaths = l.updateRibOutInfo(action, addPathsMod, addRoutes, updRoutes,
+				delRoutes, dest, updated, withdrawn, updatedAddPaths)
+			l.stateDBMgr.UpdateObject(l.GetRouteStateConfigObj(dest.GetBGPRoute()))
+		}
+		if len(cidrMap) == 0 {
+			delete(l.dampeningMap, protoFamily)
+		}
+	}
+
+	return updated, withdrawn, updatedAddPaths
+}
@@ -29,10 +29,12 @@ import (
 	"fmt"
 	"l3/bgp/config"
 	"l3/bgp/packet"
+	"l3/bgp/rpki"
 	"math"
 	"net"
 	"sort"
 	"strconv"
+	"time"
 	"utils/logging"
 )
 
@@ -83,16 +85,22 @@ func NewDestination(rib *LocRib, nlri packet.NLRI, protoFamily uint32, gConf *co
 		PathInfoRouteMap:  make(map[*bgpd.PathInfo]*Route),
 	}
 
-	dest.setBGPRouteState(protoFamily, nlri.GetPrefix().String(), int16(nlri.GetLength()))
+	network := nlri.GetPrefix().String()
+	if vpnPrefix, ok := nlri.(*packet.VPNPrefix); ok {
+		network = vpnPrefix.RD.String() + ":" + network
+	} else if _, ok := nlri.(*packet.EVPNNLRI); ok {
+		network = nlri.GetCIDR()
+	}
+	dest.setBGPRouteState(protoFamily, network, int16(nlri.GetLength()))
 	return dest
 }
 
 func (d *Destination) setBGPRouteState(protoFamily uint32, network string, cidrLen int16) {
 	afi, _ := packet.GetAfiSafi(protoFamily)
 	if afi == packet.AfiIP6 {
-		d.BGPRouteState = NewIPv6Route(network, cidrLen)
+		d.BGPRouteState = NewIPv6Route(d.gConf.Vrf, network, cidrLen)
 	} else {
-		d.BGPRouteState = NewIPv4Route(network, cidrLen)
+		d.BGPRouteState = NewIPv4Route(d.gConf.Vrf, network, cidrLen)
 	}
 }
 
@@ -174,6 +182,41 @@ func (d *Destination) updateAddPaths(addPaths []*Path) (modified bool) {
 	return modified
 }
 
+// GetAdditionalPaths returns the paths advertised with add path in addition to the Loc-RIB path for the TX mode.
+// maxTx is the total number of paths advertised in the best-n mode.
+func (d *Destination) GetAdditionalPaths(txMode string, maxTx int) []*Path {
+	addPaths := make([]*Path, 0)
+	switch txMode {
+	case config.AddPathsTxModeAll:
+		addPaths = append(addPaths, d.AddPaths...)
+
+	case config.AddPathsTxModeBestN:
+		for i := 0; i < len(d.AddPaths) && i < maxTx-1; i++ {
+			addPaths = append(addPaths, d.AddPaths[i])
+		}
+
+	case config.AddPathsTxModeECMP:
+		for _, path := range d.AddPaths {
+			if _, ok := d.ecmpPaths[path]; ok && path != d.LocRibPath {
+				addPaths = append(addPaths, path)
+			}
+		}
+
+	case config.AddPathsTxModeBackup:
+		if d.LocRibPath == nil {
+			break
+		}
+		neighborAS := d.LocRibPath.GetNeighborAS()
+		for _, path := range d.AddPaths {
+			if path.GetNeighborAS() != neighborAS {
+				addPaths = append(addPaths, path)
+				break
+			}
+		}
+	}
+	return addPaths
+}
+
 func (d *Destination) getPathForIP(peerIP string, pathId uint32) (path *Path) {
 	if pathMap, ok := d.peerPathMap[peerIP]; ok {
 		path = pathMap[pathId]
@@ -271,6 +314,7 @@ func (d *Destination) AddOrUpdatePath(peerIp string, pathId uint32, path *Path)
 	}
 	d.PathInfoRouteMap[route.PathInfo] = route
 	route.setIdx(idx)
+	route.setDampeningState(d.rib.getDampeningInfo(d.protoFamily, d.NLRI.GetCIDR(), peerIp, pathId), time.Now())
 	d.peerPathMap[peerIp][pathId] = path
 	return added
 }
@@ -336,6 +380,36 @@ func (d *Destination) RemoveAllPaths(peerIP string, path *Path) {
 	}
 }
 
+func (d *Destination) MarkStalePaths(peerIP string) bool {
+	pathMap, ok := d.peerPathMap[peerIP]
+	if !ok {
+		return false
+	}
+
+	for pathId, path := range pathMap {
+		d.logger.Info("Mark path id", pathId, "from peer", peerIP, "as stale for", d.NLRI.GetCIDR())
+		path.MarkStale()
+	}
+	return len(pathMap) > 0
+}
+
+func (d *Destination) RemoveStalePaths(peerIP string, path *Path) bool {
+	pathMap, ok := d.peerPathMap[peerIP]
+	if !ok {
+		return false
+	}
+
+	removed := false
+	for pathId, stalePath := range pathMap {
+		if stalePath.IsStale() {
+			d.logger.Info("Remove stale path id", pathId, "from peer", peerIP, "for", d.NLRI.GetCIDR())
+			d.RemovePath(peerIP, pathId, path)
+			removed = true
+		}
+	}
+	return removed
+}
+
 func (d *Destination) RemoveAllNeighborPaths() {
 	for peerIP, pathMap := range d.peerPathMap {
 		for pathId, path := range pathMap {
@@ -415,6 +489,7 @@ func (d *Destination) ConstructRouteConfig(path *Path, reachInfo *ReachabilityIn
 	}
 
 	cfg := config.RouteConfig{
+		Vrf:               d.gConf.Vrf,
 		Cost:              int32(reachInfo.Metric),
 		IntfType:          int32(reachInfo.NextHopIfType),
 		Protocol:          protocol,
@@ -424,11 +499,27 @@ func (d *Destination) ConstructRouteConfig(path *Path, reachInfo *ReachabilityIn
 		OutgoingInterface: strconv.Itoa(int(reachInfo.NextHopIfIdx)),
 		IsIPv6:            isIPv6,
 		NullRoute:         nullRoute,
+		Labels:            path.Labels,
 	}
 
 	return &cfg
 }
 
+// isRouteInstalled returns true if the route of the path is installed by the route manager. The labeled unicast
+// routes are installed with the labels of the path. The routes of the VPN families are installed by the VRFs
+// that import them and the EVPN routes are programmed by the VXLAN daemon.
+func (d *Destination) isRouteInstalled(path *Path) bool {
+	return (!path.IsLocal() || path.IsAggregate()) &&
+		(packet.IsUnicastFamily(d.protoFamily) || packet.IsLabeledUnicastFamily(d.protoFamily))
+}
+
+// isPathSuppressed returns true if the path from the peer is suppressed by route flap dampening.
+// Suppressed paths are not considered in the best path selection.
+func (d *Destination) isPathSuppressed(peerIP string, pathId uint32) bool {
+	info := d.rib.getDampeningInfo(d.protoFamily, d.NLRI.GetCIDR(), peerIP, pathId)
+	return info != nil && info.suppressed
+}
+
 func (d *Destination) SelectRouteForLocRib(addPathCount int) (RouteAction, bool, []*Route, []*Route, []*Route) {
 	updatedPaths := make([]*Path, 0)
 	removedPaths := make([]*Path, 0)
@@ -461,7 +552,7 @@ func (d *Destination) SelectRouteForLocRib(addPathCount int) (RouteAction, bool,
 	}
 
 	for peerIP, pathMap := range d.peerPathMap {
-		for _, path := range pathMap {
+		for pathId, path := range pathMap {
 			if d.LocRibPath == nil || d.LocRibPath != path {
 				if !path.IsReachable(d.protoFamily) {
 					d.logger.Infof("Destination %s peer %s, NEXT_HOP %s is not reachable", d.NLRI.GetPrefix(), peerIP,
@@ -475,6 +566,19 @@ func (d *Destination) SelectRouteForLocRib(addPathCount int) (RouteAction, bool,
 					continue
 				}
 
+				if d.isPathSuppressed(peerIP, pathId) {
+					d.logger.Infof("Destination %s peer %s, path id %d is suppressed by route flap dampening",
+						d.NLRI.GetPrefix(), peerIP, pathId)
+					continue
+				}
+
+				if path.ValidationState == rpki.ValidationStateInvalid &&
+					d.gConf.OriginValidation == config.OriginValidationExcludeInvalid {
+					d.logger.Infof("Destination %s peer %s, path id %d origin AS %d is invalid", d.NLRI.GetPrefix(),
+						peerIP, pathId, path.GetOriginAS())
+					continue
+				}
+
 				currPathSource := getRouteSource(path.routeType)
 				if currPathSource > routeSrc {
 					removedPaths = append(removedPaths, path)
@@ -565,7 +669,7 @@ func (d *Destination) SelectRouteForLocRib(addPathCount int) (RouteAction, bool,
 				newRoute.setAction(RouteActionAdd)
 				newRoute.SetMultiPath()
 
-				if !paths[0].IsLocal() || paths[0].IsAggregate() {
+				if d.isRouteInstalled(paths[0]) {
 					d.logger.Infof("Add route for ip=%s, mask=%s, next hop=%s", d.NLRI.GetPrefix(),
 						d.constructNetmaskFromLen(int(d.NLRI.GetLength()), ipLength*8),
 						paths[0].GetReachability(d.protoFamily).NextHop)
@@ -595,7 +699,7 @@ func (d *Destination) SelectRouteForLocRib(addPathCount int) (RouteAction, bool,
 
 	for path, route := range d.ecmpPaths {
 		if route.action == RouteActionNone || route.action == RouteActionDelete {
-			if !path.IsLocal() || path.IsAggregate() {
+			if d.isRouteInstalled(path) {
 				reachInfo := path.GetReachability(d.protoFamily)
 				d.logger.Info("Remove route from ECMP paths, route =", route, "ip =",
 					d.NLRI.GetCIDR(), "next hop =", reachInfo.NextHop)
@@ -679,6 +783,63 @@ func (d *Destination) getRoutesWithHighestPref(updatedPaths []*Path, prunedPaths
 	return updatedPaths, prunedPaths
 }
 
+// getValidationStatePref returns the preference of the origin validation state, the valid routes are
+// preferred over the not found routes which are preferred over the invalid routes.
+func getValidationStatePref(state rpki.ValidationState) int {
+	switch state {
+	case rpki.ValidationStateValid:
+		return 2
+	case rpki.ValidationStateNotFound:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (d *Destination) getRoutesWithBestValidationState(updatedPaths []*Path, prunedPaths []PathSortIface) (
+	[]*Path, []PathSortIface) {
+	maxPref := -1
+	removedPaths := make([]*Path, 0)
+	n := len(updatedPaths)
+	idx := 0
+
+	for i := 0; i < n; i++ {
+		currPref := getValidationStatePref(updatedPaths[i].ValidationState)
+		if currPref < maxPref {
+			removedPaths = append(removedPaths, updatedPaths[i])
+		} else if currPref > maxPref {
+			d.logger.Infof("Destination %s path %v has better validation state %s", d.NLRI.GetPrefix(),
+				updatedPaths[i], updatedPaths[i].ValidationState)
+			removedPaths = append(removedPaths, updatedPaths[:idx]...)
+			maxPref = currPref
+			updatedPaths[0] = updatedPaths[i]
+			idx = 1
+		} else {
+			updatedPaths[idx] = updatedPaths[i]
+			idx++
+		}
+	}
+
+	if len(removedPaths) > 0 {
+		pathSortIface := PathSortIface{
+			paths: removedPaths,
+			iface: ByValidationState{removedPaths},
+		}
+		prunedPaths = append(prunedPaths, pathSortIface)
+	}
+
+	if idx > 0 {
+		for i := idx; i < n; i++ {
+			updatedPaths[i] = nil
+		}
+		updatedPaths = updatedPaths[:idx]
+	}
+
+	return updatedPaths, prunedPaths
+}
+
+// getRoutesWithSmallestAS keeps the paths with the shortest AS path. The AS_CONFED_SEQUENCE and AS_CONFED_SET
+// segments are not counted in the AS path length, RFC 5065.
 func (d *Destination) getRoutesWithSmallestAS(updatedPaths []*Path, prunedPaths []PathSortIface) ([]*Path,
 	[]PathSortIface) {
 	minASNums := uint32(4096)
@@ -771,7 +932,7 @@ func deleteIBGPRoutes(updatedPaths []*Path, prunedPaths []PathSortIface) ([]*Pat
 	i := 0
 
 	for i <= n {
-		if updatedPaths[i].NeighborConf.IsInternal() {
+		if updatedPaths[i].IsInternal() {
 			removedPaths = append(removedPaths, updatedPaths[i])
 			updatedPaths[i] = updatedPaths[n]
 			updatedPaths[n] = nil
@@ -795,7 +956,7 @@ func deleteIBGPRoutes(updatedPaths []*Path, prunedPaths []PathSortIface) ([]*Pat
 func (d *Destination) removeIBGPRoutesIfEBGPExist(updatedPaths []*Path, prunedPaths []PathSortIface) ([]*Path,
 	[]PathSortIface) {
 	for _, path := range updatedPaths {
-		if path.NeighborConf != nil && path.NeighborConf.IsExternal() {
+		if path.IsExternal() {
 			return deleteIBGPRoutes(updatedPaths, prunedPaths)
 		}
 	}
@@ -804,19 +965,11 @@ func (d *Destination) removeIBGPRoutesIfEBGPExist(updatedPaths []*Path, prunedPa
 }
 
 func (d *Destination) isEBGPRoute(path *Path) bool {
-	if path.NeighborConf != nil && path.NeighborConf.IsExternal() {
-		return true
-	}
-
-	return false
+	return path.IsExternal()
 }
 
 func (d *Destination) isIBGPRoute(path *Path) bool {
-	if path.NeighborConf != nil && path.NeighborConf.IsInternal() {
-		return true
-	}
-
-	return false
+	return path.IsInternal()
 }
 
 func (d *Destination) getRoutesWithLowestBGPId(updatedPaths []*Path, prunedPaths []PathSortIface) ([]*Path,
@@ -981,20 +1134,18 @@ func (d *Destination) getECMPPaths(updatedPaths []*Path) [][]*Path {
 	return ecmpPaths
 }
 
+// addAddPaths appends the paths in currPaths to the add paths in the order of currPaths, so that the add paths
+// are in the path selection order. Only the first path with a next hop is added.
 func (d *Destination) addAddPaths(addPaths, currPaths []*Path, pathMap map[string]*Path) ([]*Path, map[string]*Path) {
-	currPathMap := make(map[string]*Path)
 	for _, path := range currPaths {
 		reachInfo := path.GetReachability(d.protoFamily)
 		if _, ok := pathMap[reachInfo.NextHop]; !ok {
-			currPathMap[reachInfo.NextHop] = path
 			pathMap[reachInfo.NextHop] = path
+			addPaths = append(addPaths, path)
 		}
 	}
 
 	d.logger.Info("getAddPaths: add paths =", addPaths, "pathMap =", pathMap)
-	for _, path := range currPathMap {
-		addPaths = append(addPaths, path)
-	}
 	return addPaths, pathMap
 }
 
@@ -1008,6 +1159,11 @@ func (d *Destination) calculateBestPath(updatedPaths, removedPaths []*Path, ebgp
 	}
 	prunedPaths = append(prunedPaths, pathSortIface)
 
+	if len(updatedPaths) > 1 && d.gConf.OriginValidation == config.OriginValidationPreferValid {
+		d.logger.Info("calling getRoutesWithBestValidationState, update paths =", updatedPaths)
+		updatedPaths, prunedPaths = d.getRoutesWithBestValidationState(updatedPaths, prunedPaths)
+	}
+
 	if len(updatedPaths) > 1 {
 		d.logger.Info("calling getRoutesWithHighestPref, update paths =", updatedPaths)
 		updatedPaths, prunedPaths = d.getRoutesWithHighestPref(updatedPaths, prunedPaths)
@@ -28,8 +28,10 @@ import (
 	"l3/bgp/baseobjects"
 	"l3/bgp/config"
 	"l3/bgp/packet"
+	"l3/bgp/rpki"
 	"net"
 	"testing"
+	"time"
 	"utils/logging"
 )
 
@@ -68,6 +70,18 @@ func (r *RouteMgr) GetRoutes() (ri1 []*config.RouteInfo, ri2 []*config.RouteInfo
 	r.t.Log("RouteMgr:GetRoutes")
 	return ri1, ri2
 }
+func (r *RouteMgr) CreateVrfLabel(vrf string, label uint32) {
+	r.t.Log("RouteMgr:CreateVrfLabel:", vrf, "label:", label)
+}
+func (r *RouteMgr) DeleteVrfLabel(vrf string, label uint32) {
+	r.t.Log("RouteMgr:DeleteVrfLabel:", vrf, "label:", label)
+}
+func (r *RouteMgr) CreateLocalLabel(label uint32, cfg *config.RouteConfig) {
+	r.t.Log("RouteMgr:CreateLocalLabel:", label, "route:", cfg)
+}
+func (r *RouteMgr) DeleteLocalLabel(label uint32) {
+	r.t.Log("RouteMgr:DeleteLocalLabel:", label)
+}
 
 func constructRibAndDest(t *testing.T, logger *logging.Writer, gConf *config.GlobalConfig) (*LocRib, *Destination) {
 	routeMgr := &RouteMgr{t}
@@ -216,6 +230,49 @@ func TestRemoveAllPaths(t *testing.T) {
 	dest.RemoveAllPaths(peerIP2, path2)
 }
 
+func TestRemoveStalePaths(t *testing.T) {
+	logger := getLogger(t)
+	peerIP := "192.168.0.100"
+	gConf, pConf := getConfObjects(peerIP, uint32(1234), uint32(4321))
+	locRib, dest := constructRibAndDest(t, logger, gConf)
+
+	// Add paths with id 1 and 2 from neighbor1
+	nConf := base.NewNeighborConf(logger, gConf, nil, *pConf)
+	pathAttrs := constructPathAttrs(pConf.NeighborAddress, pConf.PeerAS, pConf.PeerAS+1)
+	path := NewPath(locRib, nConf, pathAttrs, nil, RouteTypeEGP)
+	dest.AddOrUpdatePath(peerIP, 1, path)
+	dest.AddOrUpdatePath(peerIP, 2, path)
+
+	// Add path with id 1 from neighbor2
+	peerIP2 := "172.16.0.1"
+	pConf2 := getNeighborConf(peerIP2, 0, 5432)
+	nConf2 := base.NewNeighborConf(logger, gConf, nil, *pConf2)
+	pathAttrs2 := constructPathAttrs(pConf2.NeighborAddress, pConf2.PeerAS, pConf2.PeerAS+2)
+	path2 := NewPath(locRib, nConf2, pathAttrs2, nil, RouteTypeEGP)
+	dest.AddOrUpdatePath(peerIP2, 1, path2)
+
+	// Neighbor1 restarts, refresh path with id 1 after the restart
+	if !dest.MarkStalePaths(peerIP) {
+		t.Fatal("MarkStalePaths did not find any paths from neighbor", peerIP)
+	}
+	pathAttrs = constructPathAttrs(pConf.NeighborAddress, pConf.PeerAS, pConf.PeerAS+3)
+	newPath := NewPath(locRib, nConf, pathAttrs, nil, RouteTypeEGP)
+	dest.AddOrUpdatePath(peerIP, 1, newPath)
+
+	if !dest.RemoveStalePaths(peerIP, path) {
+		t.Fatal("RemoveStalePaths did not remove any stale paths from neighbor", peerIP)
+	}
+	if dest.getPathForIP(peerIP, 1) != newPath {
+		t.Fatal("RemoveStalePaths removed the refreshed path with id 1 from neighbor", peerIP)
+	}
+	if dest.getPathForIP(peerIP, 2) != nil {
+		t.Fatal("RemoveStalePaths did not remove the stale path with id 2 from neighbor", peerIP)
+	}
+	if dest.getPathForIP(peerIP2, 1) != path2 || path2.IsStale() {
+		t.Fatal("RemoveStalePaths modified the path from neighbor", peerIP2)
+	}
+}
+
 func TestSelectRouteForLocRib(t *testing.T) {
 	logger := getLogger(t)
 	peerIP := "192.168.0.100"
@@ -224,7 +281,7 @@ func TestSelectRouteForLocRib(t *testing.T) {
 
 	// Add path with id 2 from neighbor1
 	nConf := base.NewNeighborConf(logger, gConf, nil, *pConf)
-	nConf.SetPeerAttrs(net.ParseIP(peerIP), 4, 3, 1, nil)
+	nConf.SetPeerAttrs(net.ParseIP(peerIP), 4, 3, 1, nil, false, false)
 	pathAttrs := constructPathAttrs(pConf.NeighborAddress, pConf.PeerAS, pConf.PeerAS+1)
 	path := NewPath(locRib, nConf, pathAttrs, nil, RouteTypeEGP)
 	reachInfo := NewReachabilityInfo("192.168.0.101", 0, 0, 0)
@@ -237,7 +294,7 @@ func TestSelectRouteForLocRib(t *testing.T) {
 	peerIP2 := "172.16.0.1"
 	pConf2 := getNeighborConf(peerIP2, 0, 5432)
 	nConf2 := base.NewNeighborConf(logger, gConf, nil, *pConf2)
-	nConf.SetPeerAttrs(net.ParseIP(peerIP2), 4, 3, 1, nil)
+	nConf.SetPeerAttrs(net.ParseIP(peerIP2), 4, 3, 1, nil, false, false)
 	pathAttrs2 := constructPathAttrs(pConf2.NeighborAddress, pConf2.PeerAS, pConf2.PeerAS+2)
 	path2 := NewPath(locRib, nConf2, pathAttrs2, nil, RouteTypeEGP)
 	reachInfo2 := NewReachabilityInfo("172.16.0.2", 0, 0, 0)
@@ -262,3 +319,195 @@ func TestSelectRouteForLocRib(t *testing.T) {
 	action, addPathsMod, _, _, _ = dest.SelectRouteForLocRib(2)
 	t.Log("SelectRouteForLocRib returned action:", action, "addPaths updated:", addPathsMod)
 }
+
+func TestSelectRouteForLocRibWithDampening(t *testing.T) {
+	logger := getLogger(t)
+	peerIP := "192.168.0.100"
+	gConf, pConf := getConfObjects(peerIP, uint32(1234), uint32(4321))
+	gConf.Dampening.Enabled = true
+	locRib, dest := constructRibAndDest(t, logger, gConf)
+
+	// Add path with id 1 from neighbor1
+	nConf := base.NewNeighborConf(logger, gConf, nil, *pConf)
+	nConf.SetPeerAttrs(net.ParseIP(peerIP), 4, 3, 1, nil, false, false)
+	pathAttrs := constructPathAttrs(pConf.NeighborAddress, pConf.PeerAS, pConf.PeerAS+1)
+	path := NewPath(locRib, nConf, pathAttrs, nil, RouteTypeEGP)
+	reachInfo := NewReachabilityInfo("192.168.0.101", 0, 0, 0)
+	path.SetReachabilityForNextHop(pConf.NeighborAddress.String(), reachInfo)
+	dest.AddOrUpdatePath(peerIP, 1, path)
+
+	// Three flaps of the path cross the default suppress limit
+	for i := 0; i < 3; i++ {
+		locRib.dampenPath(dest, peerIP, 1, path, DampeningPenaltyWithdraw, false)
+	}
+	if !dest.isPathSuppressed(peerIP, 1) {
+		t.Fatal("Path with id 1 from neighbor", peerIP, "is not suppressed after three flaps")
+	}
+	dest.SelectRouteForLocRib(0)
+	if dest.LocRibPath != nil {
+		t.Fatal("Suppressed path with id 1 from neighbor", peerIP, "selected as the best path")
+	}
+
+	// The path is reused after the penalty decays below the reuse limit
+	info := locRib.getDampeningInfo(dest.protoFamily, dest.NLRI.GetCIDR(), peerIP, 1)
+	halfLife := time.Duration(config.BGPDampeningHalfLifeDefault) * time.Minute
+	if !info.update(&gConf.Dampening, time.Now().Add(3*halfLife)) {
+		t.Fatal("Path with id 1 from neighbor", peerIP, "is not reused after three half lives, penalty",
+			info.penalty)
+	}
+	dest.recalculate = true
+	dest.SelectRouteForLocRib(0)
+	if dest.LocRibPath != path {
+		t.Fatal("Reused path with id 1 from neighbor", peerIP, "is not selected as the best path")
+	}
+}
+
+func TestSelectRouteForLocRibWithOriginValidation(t *testing.T) {
+	logger := getLogger(t)
+	peerIP := "192.168.0.100"
+	peerIP2 := "172.16.0.1"
+	gConf, pConf := getConfObjects(peerIP, uint32(1234), uint32(4321))
+	pConf2 := getNeighborConf(peerIP2, 0, 5432)
+
+	addPaths := func(dest *Destination, locRib *LocRib) (*Path, *Path) {
+		// The path from neighbor1 has a shorter AS path but an invalid origin
+		nConf := base.NewNeighborConf(logger, gConf, nil, *pConf)
+		nConf.SetPeerAttrs(net.ParseIP(peerIP), 4, 3, 1, nil, false, false)
+		path := NewPath(locRib, nConf, constructPathAttrs(pConf.NeighborAddress, pConf.PeerAS), nil,
+			RouteTypeEGP)
+		path.SetReachabilityForNextHop(pConf.NeighborAddress.String(),
+			NewReachabilityInfo("192.168.0.101", 0, 0, 0))
+		path.ValidationState = rpki.ValidationStateInvalid
+		dest.AddOrUpdatePath(peerIP, 1, path)
+
+		nConf2 := base.NewNeighborConf(logger, gConf, nil, *pConf2)
+		nConf2.SetPeerAttrs(net.ParseIP(peerIP2), 4, 3, 1, nil, false, false)
+		path2 := NewPath(locRib, nConf2, constructPathAttrs(pConf2.NeighborAddress, pConf2.PeerAS,
+			pConf2.PeerAS+1), nil, RouteTypeEGP)
+		path2.SetReachabilityForNextHop(pConf2.NeighborAddress.String(),
+			NewReachabilityInfo("172.16.0.2", 0, 0, 0))
+		path2.ValidationState = rpki.ValidationStateValid
+		dest.AddOrUpdatePath(peerIP2, 1, path2)
+		return path, path2
+	}
+
+	locRib, dest := constructRibAndDest(t, logger, gConf)
+	path, _ := addPaths(dest, locRib)
+	dest.SelectRouteForLocRib(0)
+	if dest.LocRibPath != path {
+		t.Fatal("Path with the shorter AS path is not selected without origin validation, selected",
+			dest.LocRibPath)
+	}
+
+	gConf.OriginValidation = config.OriginValidationPreferValid
+	locRib, dest = constructRibAndDest(t, logger, gConf)
+	_, path2 := addPaths(dest, locRib)
+	dest.SelectRouteForLocRib(0)
+	if dest.LocRibPath != path2 {
+		t.Fatal("Valid path is not preferred, selected", dest.LocRibPath)
+	}
+
+	gConf.OriginValidation = config.OriginValidationExcludeInvalid
+	locRib, dest = constructRibAndDest(t, logger, gConf)
+	path, path2 = addPaths(dest, locRib)
+	dest.RemovePath(peerIP2, 1, path2)
+	dest.SelectRouteForLocRib(0)
+	if dest.LocRibPath != nil {
+		t.Fatal("Invalid path", path, "is selected as the best path")
+	}
+}
+
+func TestSelectRouteForLocRibWithConfedPaths(t *testing.T) {
+	logger := getLogger(t)
+	peerIP := "192.168.0.100"
+	peerIP2 := "172.16.0.1"
+	gConf, pConf := getConfObjects(peerIP, uint32(65001), uint32(65002))
+	gConf.ConfederationId = 100
+	gConf.ConfederationPeers = []uint32{65002, 65005}
+	pConf2 := getNeighborConf(peerIP2, 0, 65005)
+	locRib, dest := constructRibAndDest(t, logger, gConf)
+
+	addConfedSeg := func(pathAttrs []packet.BGPPathAttr, asList ...uint32) []packet.BGPPathAttr {
+		confedSeg := packet.NewBGPAS4PathSegment(packet.BGPASPathSegmentConfedSequence)
+		for _, as := range asList {
+			confedSeg.AppendAS(as)
+		}
+		pathAttrs[1].(*packet.BGPPathAttrASPath).PrependASPathSegment(confedSeg)
+		return pathAttrs
+	}
+
+	// The path from neighbor1 has a longer AS path only if the confederation segment is counted
+	nConf := base.NewNeighborConf(logger, gConf, nil, *pConf)
+	nConf.SetPeerAttrs(net.ParseIP(peerIP), 4, 3, 1, nil, false, false)
+	pathAttrs := addConfedSeg(constructPathAttrs(pConf.NeighborAddress, 200), 65002, 65003, 65004)
+	path := NewPath(locRib, nConf, pathAttrs, nil, RouteTypeEGP)
+	path.SetReachabilityForNextHop(pConf.NeighborAddress.String(), NewReachabilityInfo("192.168.0.101", 0, 0, 0))
+	dest.AddOrUpdatePath(peerIP, 1, path)
+
+	nConf2 := base.NewNeighborConf(logger, gConf, nil, *pConf2)
+	nConf2.SetPeerAttrs(net.ParseIP(peerIP2), 4, 3, 1, nil, false, false)
+	pathAttrs2 := addConfedSeg(constructPathAttrs(pConf2.NeighborAddress, 200, 300), 65005)
+	path2 := NewPath(locRib, nConf2, pathAttrs2, nil, RouteTypeEGP)
+	path2.SetReachabilityForNextHop(pConf2.NeighborAddress.String(), NewReachabilityInfo("172.16.0.2", 0, 0, 0))
+	dest.AddOrUpdatePath(peerIP2, 1, path2)
+
+	if !path.IsInternal() || path.IsExternal() {
+		t.Fatal("Path from the confederation peer is not an internal path")
+	}
+
+	dest.SelectRouteForLocRib(0)
+	if dest.LocRibPath != path {
+		t.Fatal("Path with the shorter AS path is not selected, selected", dest.LocRibPath)
+	}
+}
+
+func TestGetAdditionalPaths(t *testing.T) {
+	logger := getLogger(t)
+	peerIP := "192.168.0.100"
+	peerIP2 := "172.16.0.1"
+	gConf, pConf := getConfObjects(peerIP, uint32(1234), uint32(4321))
+	pConf2 := getNeighborConf(peerIP2, 0, 5432)
+	locRib, dest := constructRibAndDest(t, logger, gConf)
+
+	nConf := base.NewNeighborConf(logger, gConf, nil, *pConf)
+	nConf.SetPeerAttrs(net.ParseIP(peerIP), 4, 3, 1, nil, false, false)
+	nConf2 := base.NewNeighborConf(logger, gConf, nil, *pConf2)
+	nConf2.SetPeerAttrs(net.ParseIP(peerIP2), 4, 3, 1, nil, false, false)
+
+	// Best path and the first add path from neighbor1, the second add path from neighbor2
+	paths := make([]*Path, 0)
+	for idx, asList := range [][]uint32{{4321}, {4321, 100}, {5432, 100, 200}} {
+		nh := pConf.NeighborAddress
+		nConfForPath := nConf
+		if idx == 2 {
+			nh = pConf2.NeighborAddress
+			nConfForPath = nConf2
+		}
+		path := NewPath(locRib, nConfForPath, constructPathAttrs(nh, asList...), nil, RouteTypeEGP)
+		path.SetReachabilityForNextHop(nh.String(), NewReachabilityInfo(nh.String(), 0, 0, 0))
+		dest.AddOrUpdatePath(nh.String(), uint32(idx+1), path)
+		paths = append(paths, path)
+	}
+
+	dest.SelectRouteForLocRib(config.BGPAddPathCountAll)
+	if dest.LocRibPath != paths[0] {
+		t.Fatal("Path with the shortest AS path is not selected, selected", dest.LocRibPath)
+	}
+
+	checkPaths := func(txMode string, maxTx int, expected ...*Path) {
+		addPaths := dest.GetAdditionalPaths(txMode, maxTx)
+		if len(addPaths) != len(expected) {
+			t.Fatal("Add paths for mode", txMode, "expected", expected, "got", addPaths)
+		}
+		for idx, path := range expected {
+			if addPaths[idx] != path {
+				t.Fatal("Add paths for mode", txMode, "expected", expected, "got", addPaths)
+			}
+		}
+	}
+
+	checkPaths(config.AddPathsTxModeAll, 0, paths[1], paths[2])
+	checkPaths(config.AddPathsTxModeBestN, 2, paths[1])
+	checkPaths(config.AddPathsTxModeECMP, 0)
+	checkPaths(config.AddPathsTxModeBackup, 0, paths[2])
+}
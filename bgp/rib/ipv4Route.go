@@ -35,9 +35,10 @@ type IPv4Route struct {
 	*bgpd.BGPv4RouteState
 }
 
-func NewIPv4Route(network string, cidrLen int16) *IPv4Route {
+func NewIPv4Route(vrf string, network string, cidrLen int16) *IPv4Route {
 	return &IPv4Route{
 		&bgpd.BGPv4RouteState{
+			Vrf:     vrf,
 			Network: network,
 			CIDRLen: cidrLen,
 		},
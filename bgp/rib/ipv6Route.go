@@ -35,9 +35,10 @@ type IPv6Route struct {
 	*bgpd.BGPv6RouteState
 }
 
-func NewIPv6Route(network string, cidrLen int16) *IPv6Route {
+func NewIPv6Route(vrf string, network string, cidrLen int16) *IPv6Route {
 	return &IPv6Route{
 		&bgpd.BGPv6RouteState{
+			Vrf:     vrf,
 			Network: network,
 			CIDRLen: cidrLen,
 		},
@@ -25,10 +25,12 @@
 package rib
 
 import (
+	"bytes"
 	"encoding/binary"
 	_ "fmt"
 	"l3/bgp/baseobjects"
 	"l3/bgp/packet"
+	"l3/bgp/rpki"
 	"net"
 	_ "ribd"
 	"strconv"
@@ -83,6 +85,10 @@ type Path struct {
 	MED                uint32
 	LocalPref          uint32
 	AggregatedPaths    map[string]*Path
+	stale              bool
+	vpnPath            bool
+	Labels             []uint32
+	ValidationState    rpki.ValidationState
 }
 
 func NewPath(locRib *LocRib, peer *base.NeighborConf, pa []packet.BGPPathAttr,
@@ -103,6 +109,16 @@ func NewPath(locRib *LocRib, peer *base.NeighborConf, pa []packet.BGPPathAttr,
 	return path
 }
 
+// NewVPNPath creates the path of a route that is exported from a VRF to the VPN families or imported from the
+// VPN families to a VRF. The labels are the MPLS labels of the VPN route.
+func NewVPNPath(locRib *LocRib, peer *base.NeighborConf, pa []packet.BGPPathAttr,
+	mpReach *packet.BGPPathAttrMPReachNLRI, routeType uint8, labels []uint32) *Path {
+	path := NewPath(locRib, peer, pa, mpReach, routeType)
+	path.vpnPath = true
+	path.Labels = labels
+	return path
+}
+
 func (p *Path) Clone() *Path {
 	path := &Path{
 		rib:                p.rib,
@@ -114,6 +130,10 @@ func (p *Path) Clone() *Path {
 		routeType:          p.routeType,
 		MED:                p.MED,
 		LocalPref:          p.LocalPref,
+		stale:              p.stale,
+		vpnPath:            p.vpnPath,
+		Labels:             p.Labels,
+		ValidationState:    p.ValidationState,
 	}
 
 	return path
@@ -218,6 +238,12 @@ func (p *Path) GetAS4ByteList() []string {
 						for _, as := range seg.AS {
 							asList = append(asList, strconv.Itoa(int(as)))
 						}
+					} else if packet.IsConfedSegment(seg) {
+						confedList := make([]string, 0, len(seg.AS))
+						for _, as := range seg.AS {
+							confedList = append(confedList, strconv.Itoa(int(as)))
+						}
+						asList = append(asList, getConfedSegStr(seg.Type, confedList))
 					}
 				} else {
 					seg := asSegment.(*packet.BGPAS2PathSegment)
@@ -233,6 +259,12 @@ func (p *Path) GetAS4ByteList() []string {
 						for _, as := range seg.AS {
 							asList = append(asList, strconv.Itoa(int(as)))
 						}
+					} else if packet.IsConfedSegment(seg) {
+						confedList := make([]string, 0, len(seg.AS))
+						for _, as := range seg.AS {
+							confedList = append(confedList, strconv.Itoa(int(as)))
+						}
+						asList = append(asList, getConfedSegStr(seg.Type, confedList))
 					}
 				}
 			}
@@ -243,27 +275,122 @@ func (p *Path) GetAS4ByteList() []string {
 	return asList
 }
 
+// getConfedSegStr returns the string of a confederation segment, the AS_CONFED_SEQUENCE is enclosed in
+// parentheses and the AS_CONFED_SET in square brackets.
+func getConfedSegStr(segType packet.BGPASPathSegmentType, asList []string) string {
+	if segType == packet.BGPASPathSegmentConfedSet {
+		return "[ " + strings.Join(asList, ", ") + " ]"
+	}
+	return "( " + strings.Join(asList, " ") + " )"
+}
+
+func (p *Path) GetCommunities() []string {
+	communities := packet.GetCommunities(p.PathAttrs)
+	commList := make([]string, 0, len(communities))
+	for _, community := range communities {
+		commList = append(commList, packet.CommunityToStr(community))
+	}
+	return commList
+}
+
+func (p *Path) GetExtCommunities() []string {
+	extComms := packet.GetExtCommunities(p.PathAttrs)
+	extCommList := make([]string, 0, len(extComms))
+	for _, extComm := range extComms {
+		extCommList = append(extCommList, packet.ExtCommunityToStr(extComm))
+	}
+	return extCommList
+}
+
+func (p *Path) GetLargeCommunities() []string {
+	largeComms := packet.GetLargeCommunities(p.PathAttrs)
+	largeCommList := make([]string, 0, len(largeComms))
+	for _, largeComm := range largeComms {
+		largeCommList = append(largeCommList, largeComm.String())
+	}
+	return largeCommList
+}
+
+// GetLabels returns the label stack of the path received with the labeled unicast or the VPN routes.
+func (p *Path) GetLabels() []int32 {
+	labels := make([]int32, 0, len(p.Labels))
+	for _, label := range p.Labels {
+		labels = append(labels, int32(label))
+	}
+	return labels
+}
+
+func (p *Path) HasCommunity(community uint32) bool {
+	return packet.HasCommunity(p.PathAttrs, community)
+}
+
+// MarkStale marks the path as stale when the peer that advertised it restarts gracefully.
+func (p *Path) MarkStale() {
+	p.stale = true
+}
+
+func (p *Path) IsStale() bool {
+	return p.stale
+}
+
+// HasSameAttrs returns true if the path attributes of the paths are the same. The MP_REACH_NLRI and
+// MP_UNREACH_NLRI attributes are not compared as they carry the NLRI.
+func (p *Path) HasSameAttrs(path *Path) bool {
+	pathAttrs := make([][]byte, 0, len(p.PathAttrs))
+	for _, attr := range p.PathAttrs {
+		if attr.GetCode() == packet.BGPPathAttrTypeMPReachNLRI ||
+			attr.GetCode() == packet.BGPPathAttrTypeMPUnreachNLRI {
+			continue
+		}
+		attrBytes, err := attr.Encode()
+		if err != nil {
+			return false
+		}
+		pathAttrs = append(pathAttrs, attrBytes)
+	}
+
+	idx := 0
+	for _, attr := range path.PathAttrs {
+		if attr.GetCode() == packet.BGPPathAttrTypeMPReachNLRI ||
+			attr.GetCode() == packet.BGPPathAttrTypeMPUnreachNLRI {
+			continue
+		}
+		attrBytes, err := attr.Encode()
+		if err != nil || idx >= len(pathAttrs) || !bytes.Equal(pathAttrs[idx], attrBytes) {
+			return false
+		}
+		idx++
+	}
+	return idx == len(pathAttrs)
+}
+
 func (p *Path) HasASLoop() bool {
 	if p.NeighborConf == nil {
 		return false
 	}
-	return packet.HasASLoop(p.PathAttrs, p.NeighborConf.RunningConf.LocalAS)
+	return p.NeighborConf.HasASLoop(p.PathAttrs)
 }
 
 func (p *Path) IsLocal() bool {
 	return getRouteSource(p.routeType) == RouteSrcLocal
 }
 
+func (p *Path) IsVPNPath() bool {
+	return p.vpnPath
+}
+
 func (p *Path) IsAggregate() bool {
 	return p.routeType == RouteTypeAgg
 }
 
+// IsExternal returns true if the path is received from an eBGP peer. The paths received from the peers in the
+// other member ASes of the confederation are internal paths, RFC 5065.
 func (p *Path) IsExternal() bool {
-	return p.NeighborConf != nil && p.NeighborConf.IsExternal()
+	return p.NeighborConf != nil && p.NeighborConf.IsExternal() && !p.NeighborConf.IsConfedExternal()
 }
 
 func (p *Path) IsInternal() bool {
-	return p.NeighborConf != nil && p.NeighborConf.IsInternal()
+	return p.NeighborConf != nil && (p.NeighborConf.IsInternal() || p.NeighborConf.IsConfedExternal())
 }
 
 func (p *Path) GetSourceStr() string {
@@ -275,6 +402,30 @@ func (p *Path) GetNumASes() uint32 {
 	return packet.GetNumASes(p.PathAttrs)
 }
 
+// GetOriginAS returns the origin AS of the path used for the origin validation. The routes with an empty
+// AS_PATH are originated by the local AS.
+func (p *Path) GetOriginAS() uint32 {
+	if originAS, ok := packet.GetOriginAS(p.PathAttrs); ok {
+		return originAS
+	}
+	if p.NeighborConf != nil {
+		return p.NeighborConf.RunningConf.LocalAS
+	}
+	return 0
+}
+
+// GetNeighborAS returns the AS the path was received from. The routes with an empty AS_PATH or an AS_PATH that
+// starts with an AS_SET are treated as received from the local AS.
+func (p *Path) GetNeighborAS() uint32 {
+	if neighborAS, ok := packet.GetNeighborAS(p.PathAttrs); ok {
+		return neighborAS
+	}
+	if p.NeighborConf != nil {
+		return p.NeighborConf.RunningConf.LocalAS
+	}
+	return 0
+}
+
 func (p *Path) GetOrigin() uint8 {
 	return packet.GetOrigin(p.PathAttrs)
 }
@@ -72,6 +72,14 @@ func (b ByPref) Less(i, j int) bool {
 	return b.Paths[i].Pref > b.Paths[j].Pref
 }
 
+type ByValidationState struct {
+	Paths
+}
+
+func (b ByValidationState) Less(i, j int) bool {
+	return getValidationStatePref(b.Paths[i].ValidationState) > getValidationStatePref(b.Paths[j].ValidationState)
+}
+
 type BySmallestAS struct {
 	Paths
 }
@@ -32,6 +32,7 @@ import (
 	"l3/bgp/packet"
 	"models/objects"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 	"utils/logging"
@@ -73,6 +74,7 @@ type LocRib struct {
 	routeListDirty   map[uint32]bool
 	activeGet        map[uint32]bool
 	timer            map[uint32]*time.Timer
+	dampeningMap     map[uint32]map[string]map[string]map[uint32]*DampeningInfo
 }
 
 func NewLocRib(logger *logging.Writer, rMgr config.RouteMgrIntf, sDBMgr statedbclient.StateDBClient,
@@ -91,6 +93,7 @@ func NewLocRib(logger *logging.Writer, rMgr config.RouteMgrIntf, sDBMgr statedbc
 		activeGet:        make(map[uint32]bool),
 		routeMutex:       sync.RWMutex{},
 		timer:            make(map[uint32]*time.Timer),
+		dampeningMap:     make(map[uint32]map[string]map[string]map[uint32]*DampeningInfo),
 	}
 
 	return rib
@@ -98,8 +101,7 @@ func NewLocRib(logger *logging.Writer, rMgr config.RouteMgrIntf, sDBMgr statedbc
 
 func isIpInList(prefixes []packet.NLRI, ip packet.NLRI) bool {
 	for _, nlri := range prefixes {
-		if nlri.GetPathId() == ip.GetPathId() &&
-			nlri.GetPrefix().Equal(ip.GetPrefix()) {
+		if nlri.GetPathId() == ip.GetPathId() && nlri.GetCIDR() == ip.GetCIDR() {
 			return true
 		}
 	}
@@ -133,8 +135,14 @@ func (l *LocRib) GetReachabilityInfo(ipStr string) *ReachabilityInfo {
 	return reachabilityInfo
 }
 
+// GetDestFromIPAndLen returns the destination of the prefix ip/cidrLen, nil if the prefix is not in the Loc-RIB.
 func (l *LocRib) GetDestFromIPAndLen(protoFamily uint32, ip string, cidrLen uint32) *Destination {
 	if nlriDestMap, ok := l.destPathMap[protoFamily]; ok {
+		if dest, ok := nlriDestMap[ip+"/"+strconv.Itoa(int(cidrLen))]; ok {
+			return dest
+		}
+
+		// The EVPN destinations are not keyed by the prefix length
 		if dest, ok := nlriDestMap[ip]; ok {
 			return dest
 		}
@@ -206,6 +214,9 @@ func (l *LocRib) ProcessRoutes(peerIP string, add, rem []packet.NLRI, addPath, r
 			}
 			op := l.stateDBMgr.UpdateObject
 			oldPath := dest.RemovePath(peerIP, nlri.GetPathId(), remPath)
+			if oldPath != nil && !oldPath.IsStale() {
+				l.dampenPath(dest, peerIP, nlri.GetPathId(), oldPath, DampeningPenaltyWithdraw, true)
+			}
 			if oldPath != nil && !oldPath.IsReachable(dest.protoFamily) {
 				nextHop := oldPath.GetNextHop(dest.protoFamily)
 				if nextHop != nil {
@@ -239,7 +250,7 @@ func (l *LocRib) ProcessRoutes(peerIP string, add, rem []packet.NLRI, addPath, r
 			updated, withdrawn, updatedAddPaths = l.updateRibOutInfo(action, addPathsMod, addRoutes, updRoutes,
 				delRoutes, dest, updated, withdrawn, updatedAddPaths)
 
-			if oldPath != nil && remPath != nil {
+			if oldPath != nil && !oldPath.IsStale() && remPath != nil && !remPath.IsVPNPath() {
 				if neighborConf := remPath.GetNeighborConf(); neighborConf != nil {
 					l.logger.Infof("Decrement prefix count for destination %s from Peer %s",
 						nlri.GetCIDR(), peerIP)
@@ -274,7 +285,9 @@ func (l *LocRib) ProcessRoutes(peerIP string, add, rem []packet.NLRI, addPath, r
 		if !alreadyCreated {
 			op = l.stateDBMgr.AddObject
 		}
-		if oldPath := dest.getPathForIP(peerIP, nlri.GetPathId()); oldPath == nil && addPath.NeighborConf != nil {
+		// Stale paths retained during a graceful restart are not counted in the prefix count of the peer
+		oldPath := dest.getPathForIP(peerIP, nlri.GetPathId())
+		if (oldPath == nil || oldPath.IsStale()) && addPath.NeighborConf != nil && !addPath.IsVPNPath() {
 			if !addPath.NeighborConf.CanAcceptNewPrefix() {
 				l.logger.Infof("Max prefixes limit reached for peer %s, can't process %s", peerIP,
 					nlri.GetCIDR())
@@ -285,6 +298,7 @@ func (l *LocRib) ProcessRoutes(peerIP string, add, rem []packet.NLRI, addPath, r
 			addPath.NeighborConf.IncrPrefixCount()
 		}
 
+		l.updateDampening(dest, peerIP, nlri.GetPathId(), oldPath, addPath)
 		dest.AddOrUpdatePath(peerIP, nlri.GetPathId(), addPath)
 		if !addPath.IsReachable(protoFamily) {
 			if _, ok := l.unreachablePaths[nextHopStr][addPath][dest]; !ok {
@@ -475,6 +489,22 @@ func (l *LocRib) ProcessConnectedRoutes(src string, path *Path, add, remove map[
 	return updated, withdrawn, updatedAddPaths
 }
 
+// ProcessVPNRoutes adds the routes with the path from the source and removes the routes from the source. The
+// routes are exported from a VRF to the VPN families or imported from the VPN families to a VRF.
+func (l *LocRib) ProcessVPNRoutes(src string, add, remove []packet.NLRI, path *Path, protoFamily uint32,
+	addPathCount int) (map[uint32]map[*Path][]*Destination, []*Destination, []*Destination) {
+	updated := make(map[uint32]map[*Path][]*Destination)
+	withdrawn := make([]*Destination, 0)
+	updatedAddPaths := make([]*Destination, 0)
+
+	if path == nil {
+		path = NewVPNPath(l, nil, nil, nil, RouteTypeStatic, nil)
+	}
+	updated, withdrawn, updatedAddPaths, _ = l.ProcessRoutes(src, add, remove, path, path.Clone(), addPathCount,
+		protoFamily, updated, withdrawn, updatedAddPaths)
+	return updated, withdrawn, updatedAddPaths
+}
+
 func (l *LocRib) RemoveUpdatesFromNeighbor(peerIP string, neighborConf *base.NeighborConf, addPathCount int) (
 	map[uint32]map[*Path][]*Destination, []*Destination, []*Destination) {
 	remPath := NewPath(l, neighborConf, nil, nil, RouteTypeEGP)
@@ -509,6 +539,60 @@ func (l *LocRib) RemoveUpdatesFromNeighbor(peerIP string, neighborConf *base.Nei
 	return updated, withdrawn, updatedAddPaths
 }
 
+// MarkStaleUpdatesFromNeighbor marks the paths received from the peer for the protocol families as stale.
+// The stale paths stay in the RIB until they are refreshed by the peer or removed when the peer does not
+// complete the graceful restart.
+func (l *LocRib) MarkStaleUpdatesFromNeighbor(peerIP string, protoFamilies map[uint32]bool) {
+	for protoFamily, ipDestMap := range l.destPathMap {
+		if !protoFamilies[protoFamily] {
+			continue
+		}
+
+		for _, dest := range ipDestMap {
+			dest.MarkStalePaths(peerIP)
+		}
+	}
+}
+
+func (l *LocRib) RemoveStaleUpdatesFromNeighbor(peerIP string, neighborConf *base.NeighborConf,
+	protoFamilies map[uint32]bool, addPathCount int) (map[uint32]map[*Path][]*Destination, []*Destination,
+	[]*Destination) {
+	remPath := NewPath(l, neighborConf, nil, nil, RouteTypeEGP)
+	withdrawn := make([]*Destination, 0)
+	updated := make(map[uint32]map[*Path][]*Destination)
+	updatedAddPaths := make([]*Destination, 0)
+
+	for protoFamily, ipDestMap := range l.destPathMap {
+		if !protoFamilies[protoFamily] {
+			continue
+		}
+
+		for destIP, dest := range ipDestMap {
+			if !dest.RemoveStalePaths(peerIP, remPath) {
+				continue
+			}
+
+			op := l.stateDBMgr.UpdateObject
+			action, addPathsMod, addRoutes, updRoutes, delRoutes := dest.SelectRouteForLocRib(addPathCount)
+			l.logger.Info("RemoveStaleUpdatesFromNeighbor - dest", dest.NLRI.GetCIDR(),
+				"SelectRouteForLocRib returned action", action, "addRoutes", addRoutes, "updRoutes", updRoutes,
+				"delRoutes", delRoutes)
+			updated, withdrawn, updatedAddPaths = l.updateRibOutInfo(action, addPathsMod, addRoutes, updRoutes,
+				delRoutes, dest, updated, withdrawn, updatedAddPaths)
+			if action == RouteActionDelete && dest.IsEmpty() {
+				l.logger.Info("All routes removed for dest", dest.NLRI.GetCIDR())
+				l.removeRoutesFromRouteList(dest, protoFamily)
+				delete(l.destPathMap[protoFamily], destIP)
+				l.routesCount[protoFamily]--
+				op = l.stateDBMgr.DeleteObject
+			}
+			op(l.GetRouteStateConfigObj(dest.GetBGPRoute()))
+		}
+	}
+
+	return updated, withdrawn, updatedAddPaths
+}
+
 func (l *LocRib) RemoveUpdatesFromAllNeighbors(addPathCount int) {
 	withdrawn := make([]*Destination, 0)
 	updated := make(map[uint32]map[*Path][]*Destination)
@@ -818,3 +902,9 @@ func (l *LocRib) BulkGetBGPv6Routes(index int, count int) (int, int, []*bgpd.BGP
 	}
 	return i, n, thriftRoutes
 }
+
+func (l *LocRib) GetRouteListLen(protoFamily uint32) int {
+	defer l.routeMutex.RUnlock()
+	l.routeMutex.RLock()
+	return len(l.routeList[protoFamily])
+}
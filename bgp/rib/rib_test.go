@@ -137,6 +137,22 @@ func TestGetDest(t *testing.T) {
 	}
 }
 
+func TestGetDestFromIPAndLen(t *testing.T) {
+	logger := getLogger(t)
+	gConf, _ := getConfObjects("192.168.0.100", uint32(1234), uint32(4321))
+	locRib := constructRib(t, logger, gConf)
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+	dest, _ := locRib.GetDest(packet.NewIPPrefix(net.ParseIP("60.1.1.0"), 24), protoFamily, true)
+
+	if found := locRib.GetDestFromIPAndLen(protoFamily, "60.1.1.0", 24); found != dest {
+		t.Fatal("LocRib:GetDestFromIPAndLen did not return the destination of 60.1.1.0/24, got", found)
+	}
+
+	if found := locRib.GetDestFromIPAndLen(protoFamily, "60.1.1.0", 25); found != nil {
+		t.Fatal("LocRib:GetDestFromIPAndLen returned a destination for 60.1.1.0/25, got", found)
+	}
+}
+
 func constructIPPrefix(t *testing.T, ips ...string) []packet.NLRI {
 	nlri := make([]packet.NLRI, 0)
 	for _, cidrIP := range ips {
@@ -226,3 +242,60 @@ func TestProcessUpdate(t *testing.T) {
 		t.Fatal("LocRib:ProcessUpdate - Did not add all prefixes to RIB")
 	}
 }
+
+func TestProcessVPNRoutes(t *testing.T) {
+	logger := getLogger(t)
+	gConf, _ := getConfObjects("192.168.0.100", uint32(1234), uint32(4321))
+	locRib := constructRib(t, logger, gConf)
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiMPLSVPN)
+	src := "VRF-red"
+
+	rd, err := packet.ParseRouteDistinguisher("1234:100")
+	if err != nil {
+		t.Fatal("ParseRouteDistinguisher failed with error:", err)
+	}
+	nlri := make([]packet.NLRI, 0)
+	for _, prefix := range constructIPPrefix(t, "30.1.10.0/24", "40.1.0.0/16") {
+		nlri = append(nlri, packet.NewVPNPrefix(rd, []uint32{16}, prefix.(*packet.IPPrefix)))
+	}
+
+	updated, withdrawn, updatedAddPaths := locRib.ProcessVPNRoutes(src, nlri, nil, nil, protoFamily, 0)
+	if len(updated[protoFamily]) != 1 {
+		t.Fatal("LocRib:ProcessVPNRoutes - Did not find one path in protocol family", protoFamily)
+	}
+	for path, destinations := range updated[protoFamily] {
+		if !path.IsVPNPath() {
+			t.Fatalf("LocRib:ProcessVPNRoutes - Path %+v is not a VPN path", path)
+		}
+		if len(destinations) != 2 {
+			t.Fatalf("LocRib:ProcessVPNRoutes - Did not find 2 destinations %+v for path %+v", destinations, path)
+		}
+	}
+	if len(withdrawn) > 0 {
+		t.Fatal("LocRib:ProcessVPNRoutes - Found withdrawn paths, withdrawn=", withdrawn)
+	}
+	if len(updatedAddPaths) > 0 {
+		t.Fatal("LocRib:ProcessVPNRoutes - Found add paths, updatedAddPaths=", updatedAddPaths)
+	}
+
+	for _, prefix := range nlri {
+		dest, exists := locRib.GetDest(prefix, protoFamily, false)
+		if !exists {
+			t.Fatal("LocRib:ProcessVPNRoutes - Destination not found for", prefix.GetCIDR())
+		}
+		if dest.isRouteInstalled(dest.LocRibPath) {
+			t.Fatal("LocRib:ProcessVPNRoutes - VPN route is installed for", prefix.GetCIDR())
+		}
+	}
+
+	updated, withdrawn, updatedAddPaths = locRib.ProcessVPNRoutes(src, nil, nlri[:1], nil, protoFamily, 0)
+	if len(updated[protoFamily]) != 0 {
+		t.Fatal("LocRib:ProcessVPNRoutes - Found updated paths in protocol family", protoFamily)
+	}
+	if len(withdrawn) != 1 {
+		t.Fatal("LocRib:ProcessVPNRoutes - Did not find 1 withdrawn destination, withdrawn=", withdrawn)
+	}
+	if _, exists := locRib.GetDest(nlri[0], protoFamily, false); exists {
+		t.Fatal("LocRib:ProcessVPNRoutes - Destination not removed for", nlri[0].GetCIDR())
+	}
+}
@@ -54,24 +54,30 @@ type Route struct {
 func NewRoute(dest *Destination, path *Path, action RouteAction, inPathId, outPathId uint32) *Route {
 	currTime := time.Now()
 	pathInfo := &bgpd.PathInfo{
-		NextHop:        path.GetNextHop(dest.protoFamily).String(),
-		Metric:         int32(path.MED),
-		LocalPref:      int32(path.LocalPref),
-		Path:           path.GetAS4ByteList(),
-		PathId:         int32(inPathId),
-		UpdatedTime:    currTime.String(),
-		ValidPath:      path.IsReachable(dest.protoFamily),
-		BestPath:       false,
-		MultiPath:      false,
-		AdditionalPath: false,
-		Origin:         packet.GetOriginTypeStr(path.GetOrigin()),
-		PathType:       path.GetSourceStr(),
+		NextHop:          path.GetNextHop(dest.protoFamily).String(),
+		Metric:           int32(path.MED),
+		LocalPref:        int32(path.LocalPref),
+		Path:             path.GetAS4ByteList(),
+		PathId:           int32(inPathId),
+		UpdatedTime:      currTime.String(),
+		ValidPath:        path.IsReachable(dest.protoFamily),
+		BestPath:         false,
+		MultiPath:        false,
+		AdditionalPath:   false,
+		Origin:           packet.GetOriginTypeStr(path.GetOrigin()),
+		PathType:         path.GetSourceStr(),
+		Communities:      path.GetCommunities(),
+		ExtCommunities:   path.GetExtCommunities(),
+		LargeCommunities: path.GetLargeCommunities(),
+		ValidationState:  path.ValidationState.String(),
+		Labels:           path.GetLabels(),
 	}
 	return &Route{
 		PathInfo:         pathInfo,
 		Dest:             dest,
 		path:             path,
 		routeListIdx:     -1,
+		time:             currTime,
 		action:           action,
 		OutPathId:        outPathId,
 		PolicyList:       make([]string, 0),
@@ -79,6 +85,11 @@ func NewRoute(dest *Destination, path *Path, action RouteAction, inPathId, outPa
 	}
 }
 
+// GetTime returns the time the route was created.
+func (r *Route) GetTime() time.Time {
+	return r.time
+}
+
 func (r *Route) setAction(action RouteAction) {
 	r.action = action
 }
@@ -110,3 +121,22 @@ func (r *Route) SetAdditionalPath() {
 func (r *Route) ResetAdditionalPath() {
 	r.PathInfo.AdditionalPath = false
 }
+
+// setDampeningState sets the route flap dampening state of the path in the route state object.
+func (r *Route) setDampeningState(info *DampeningInfo, now time.Time) {
+	if info == nil {
+		r.PathInfo.Dampened = false
+		r.PathInfo.FlapCount = 0
+		r.PathInfo.Penalty = 0
+		r.PathInfo.ReuseTime = ""
+		return
+	}
+
+	r.PathInfo.Dampened = info.suppressed
+	r.PathInfo.FlapCount = int32(info.flapCount)
+	r.PathInfo.Penalty = int32(info.penalty)
+	r.PathInfo.ReuseTime = ""
+	if dampConf := info.neighborConf.GetDampeningConfig(); dampConf != nil && info.suppressed {
+		r.PathInfo.ReuseTime = info.getReuseTime(dampConf, now).String()
+	}
+}
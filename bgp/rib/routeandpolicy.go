@@ -44,6 +44,7 @@ type AdjRIBRoute struct {
 	PolicyList       []string
 	PolicyHitCounter int
 	Accept           bool
+	StalePathIds     map[uint32]bool
 }
 
 func NewAdjRIBRoute(neighbor net.IP, protoFamily uint32, nlri packet.NLRI) *AdjRIBRoute {
@@ -55,15 +56,33 @@ func NewAdjRIBRoute(neighbor net.IP, protoFamily uint32, nlri packet.NLRI) *AdjR
 		PolicyList:       make([]string, 0),
 		PolicyHitCounter: 0,
 		Accept:           false,
+		StalePathIds:     make(map[uint32]bool),
 	}
 }
 
 func (a *AdjRIBRoute) AddPath(pathId uint32, path *Path) {
 	a.PathMap[pathId] = path
+	delete(a.StalePathIds, pathId)
 }
 
 func (a *AdjRIBRoute) RemovePath(pathId uint32) {
 	delete(a.PathMap, pathId)
+	delete(a.StalePathIds, pathId)
+}
+
+// MarkPathsStale marks all the paths of the route as stale, the paths that are added again are no longer stale.
+func (a *AdjRIBRoute) MarkPathsStale() {
+	for pathId, _ := range a.PathMap {
+		a.StalePathIds[pathId] = true
+	}
+}
+
+func (a *AdjRIBRoute) GetStalePathIds() []uint32 {
+	pathIds := make([]uint32, 0, len(a.StalePathIds))
+	for pathId, _ := range a.StalePathIds {
+		pathIds = append(pathIds, pathId)
+	}
+	return pathIds
 }
 
 func (a *AdjRIBRoute) GetPath(pathId uint32) *Path {
@@ -81,6 +100,7 @@ func (a *AdjRIBRoute) GetPathMap() map[uint32]*Path {
 func (a *AdjRIBRoute) RemoveAllPaths() {
 	a.PathMap = nil
 	a.PathMap = make(map[uint32]*Path)
+	a.StalePathIds = make(map[uint32]bool)
 }
 
 type FilteredRoutes struct {
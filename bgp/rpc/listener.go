@@ -38,6 +38,7 @@ import (
 	"math"
 	"models/objects"
 	"net"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"time"
@@ -91,9 +92,24 @@ func (h *BGPHandler) convertModelToBGPGlobalConfig(obj objects.BGPGlobal) (gConf
 			EBGPMaxPaths:        obj.EBGPMaxPaths,
 			EBGPAllowMultipleAS: obj.EBGPAllowMultipleAS,
 			IBGPMaxPaths:        obj.IBGPMaxPaths,
+			GracefulRestart:     obj.GracefulRestart,
+			RestartTime:         uint16(obj.RestartTime),
+			StalePathTime:       uint16(obj.StalePathTime),
+			Dampening: h.convertToDampeningConfig(obj.Dampening, obj.DampeningHalfLife,
+				obj.DampeningReuseLimit, obj.DampeningSuppressLimit, obj.DampeningMaxSuppressTime),
+			RouteDistinguisher: obj.RouteDistinguisher,
+			ImportRouteTargets: obj.ImportRouteTargets,
+			ExportRouteTargets: obj.ExportRouteTargets,
+			OriginValidation:   obj.OriginValidation,
 		},
 	}
 
+	if gConf.ConfederationId, gConf.ConfederationPeers, err = h.convertToConfedConfig(obj.ConfederationId,
+		obj.ConfederationPeers); err != nil {
+		h.logger.Err("Invalid confederation config")
+		return gConf, err
+	}
+
 	if obj.Redistribution != nil {
 		gConf.Redistribution = make([]config.SourcePolicyMap, 0)
 		for i := 0; i < len(obj.Redistribution); i++ {
@@ -145,6 +161,7 @@ func (h *BGPHandler) convertModelToBGPv4PeerGroup(obj objects.BGPv4PeerGroup) (g
 		return group, err
 	}
 	group = config.PeerGroupConfig{
+		Vrf: obj.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -168,6 +185,19 @@ func (h *BGPHandler) convertModelToBGPv4PeerGroup(obj objects.BGPv4PeerGroup) (g
 			MaxPrefixesRestartTimer: uint8(obj.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          obj.AdjRIBInFilter,
 			AdjRIBOutFilter:         obj.AdjRIBOutFilter,
+			AllowAsIn:               uint8(obj.AllowAsIn),
+			RemovePrivateAS:         obj.RemovePrivateAS,
+			LocalASNoPrepend:        obj.LocalASNoPrepend,
+			LocalASReplaceAS:        obj.LocalASReplaceAS,
+			AsOverride:              obj.AsOverride,
+			DefaultOriginate:        obj.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(obj.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(obj.AdvertisePrefixes, obj.AdvertiseConditionPrefix,
+				obj.AdvertiseNonExist),
+			LabeledUnicast: obj.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(obj.AddPaths),
+			Dampening: h.convertToDampeningConfig(obj.Dampening, obj.DampeningHalfLife,
+				obj.DampeningReuseLimit, obj.DampeningSuppressLimit, obj.DampeningMaxSuppressTime),
 		},
 		Name: obj.Name,
 	}
@@ -210,6 +240,7 @@ func (h *BGPHandler) convertModelToBGPv6PeerGroup(obj objects.BGPv6PeerGroup) (g
 		return group, err
 	}
 	group = config.PeerGroupConfig{
+		Vrf: obj.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -232,6 +263,19 @@ func (h *BGPHandler) convertModelToBGPv6PeerGroup(obj objects.BGPv6PeerGroup) (g
 			MaxPrefixesRestartTimer: uint8(obj.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          obj.AdjRIBInFilter,
 			AdjRIBOutFilter:         obj.AdjRIBOutFilter,
+			AllowAsIn:               uint8(obj.AllowAsIn),
+			RemovePrivateAS:         obj.RemovePrivateAS,
+			LocalASNoPrepend:        obj.LocalASNoPrepend,
+			LocalASReplaceAS:        obj.LocalASReplaceAS,
+			AsOverride:              obj.AsOverride,
+			DefaultOriginate:        obj.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(obj.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(obj.AdvertisePrefixes, obj.AdvertiseConditionPrefix,
+				obj.AdvertiseNonExist),
+			LabeledUnicast: obj.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(obj.AddPaths),
+			Dampening: h.convertToDampeningConfig(obj.Dampening, obj.DampeningHalfLife,
+				obj.DampeningReuseLimit, obj.DampeningSuppressLimit, obj.DampeningMaxSuppressTime),
 		},
 		Name: obj.Name,
 	}
@@ -284,6 +328,7 @@ func (h *BGPHandler) convertModelToBGPv4Neighbor(obj objects.BGPv4Neighbor) (nei
 	}
 
 	neighbor = config.NeighborConfig{
+		Vrf: obj.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -309,6 +354,19 @@ func (h *BGPHandler) convertModelToBGPv4Neighbor(obj objects.BGPv4Neighbor) (nei
 			MaxPrefixesRestartTimer: uint8(obj.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          obj.AdjRIBInFilter,
 			AdjRIBOutFilter:         obj.AdjRIBOutFilter,
+			AllowAsIn:               uint8(obj.AllowAsIn),
+			RemovePrivateAS:         obj.RemovePrivateAS,
+			LocalASNoPrepend:        obj.LocalASNoPrepend,
+			LocalASReplaceAS:        obj.LocalASReplaceAS,
+			AsOverride:              obj.AsOverride,
+			DefaultOriginate:        obj.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(obj.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(obj.AdvertisePrefixes, obj.AdvertiseConditionPrefix,
+				obj.AdvertiseNonExist),
+			LabeledUnicast: obj.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(obj.AddPaths),
+			Dampening: h.convertToDampeningConfig(obj.Dampening, obj.DampeningHalfLife,
+				obj.DampeningReuseLimit, obj.DampeningSuppressLimit, obj.DampeningMaxSuppressTime),
 		},
 		NeighborAddress: ip,
 		IfIndex:         ifIndex,
@@ -365,6 +423,7 @@ func (h *BGPHandler) convertModelToBGPv6Neighbor(obj objects.BGPv6Neighbor) (nei
 	}
 
 	neighbor = config.NeighborConfig{
+		Vrf: obj.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -389,6 +448,19 @@ func (h *BGPHandler) convertModelToBGPv6Neighbor(obj objects.BGPv6Neighbor) (nei
 			MaxPrefixesRestartTimer: uint8(obj.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          obj.AdjRIBInFilter,
 			AdjRIBOutFilter:         obj.AdjRIBOutFilter,
+			AllowAsIn:               uint8(obj.AllowAsIn),
+			RemovePrivateAS:         obj.RemovePrivateAS,
+			LocalASNoPrepend:        obj.LocalASNoPrepend,
+			LocalASReplaceAS:        obj.LocalASReplaceAS,
+			AsOverride:              obj.AsOverride,
+			DefaultOriginate:        obj.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(obj.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(obj.AdvertisePrefixes, obj.AdvertiseConditionPrefix,
+				obj.AdvertiseNonExist),
+			LabeledUnicast: obj.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(obj.AddPaths),
+			Dampening: h.convertToDampeningConfig(obj.Dampening, obj.DampeningHalfLife,
+				obj.DampeningReuseLimit, obj.DampeningSuppressLimit, obj.DampeningMaxSuppressTime),
 		},
 		NeighborAddress: ip,
 		IfIndex:         ifIndex,
@@ -424,6 +496,7 @@ func (h *BGPHandler) handleV6NeighborConfig() error {
 
 func (h *BGPHandler) convertModelToBGPv4Aggregate(obj objects.BGPv4Aggregate) (config.BGPAggregate, error) {
 	aggConf := config.BGPAggregate{
+		Vrf:             obj.Vrf,
 		IPPrefix:        obj.IpPrefix,
 		GenerateASSet:   obj.GenerateASSet,
 		SendSummaryOnly: obj.SendSummaryOnly,
@@ -456,6 +529,7 @@ func (h *BGPHandler) handleBGPv4Aggregate() error {
 
 func (h *BGPHandler) convertModelToBGPv6Aggregate(obj objects.BGPv6Aggregate) (config.BGPAggregate, error) {
 	aggConf := config.BGPAggregate{
+		Vrf:             obj.Vrf,
 		IPPrefix:        obj.IpPrefix,
 		GenerateASSet:   obj.GenerateASSet,
 		SendSummaryOnly: obj.SendSummaryOnly,
@@ -486,12 +560,165 @@ func (h *BGPHandler) handleBGPv6Aggregate() error {
 	return nil
 }
 
+func (h *BGPHandler) convertModelToBGPBmpCollector(obj objects.BGPBmpCollector) (config.BMPCollector, error) {
+	colConf := config.BMPCollector{
+		Name:                  obj.Name,
+		Address:               net.ParseIP(obj.Address),
+		Port:                  uint16(obj.Port),
+		RouteMonitoringPolicy: obj.RouteMonitoringPolicy,
+		StatsInterval:         uint32(obj.StatsInterval),
+	}
+
+	return colConf, nil
+}
+
+func (h *BGPHandler) handleBGPBmpCollector() error {
+	var obj objects.BGPBmpCollector
+	objList, err := h.dbUtil.GetAllObjFromDb(obj)
+	if err != nil {
+		h.logger.Errf("GetAllObjFromDb failed for BGPBmpCollector with error %s", err)
+		return err
+	}
+
+	for _, confObj := range objList {
+		obj = confObj.(objects.BGPBmpCollector)
+
+		colConf, err := h.convertModelToBGPBmpCollector(obj)
+		if err != nil {
+			h.logger.Err("handleBGPBmpCollector - Failed to convert Model object BGPBmpCollector, error:", err)
+			return err
+		}
+		h.server.AddBMPColCh <- server.BMPCollectorUpdate{config.BMPCollector{}, colConf, make([]bool, 0)}
+	}
+	return nil
+}
+
+func (h *BGPHandler) convertModelToBGPMrt(obj objects.BGPMrt) (config.MRTConfig, error) {
+	mrtConf := config.MRTConfig{
+		Directory:       obj.Directory,
+		RIBDumpInterval: uint32(obj.RibDumpInterval),
+		RecordUpdates:   obj.RecordUpdates,
+		RotateInterval:  uint32(obj.RotateInterval),
+	}
+
+	return mrtConf, nil
+}
+
+func (h *BGPHandler) handleBGPMrt() error {
+	var obj objects.BGPMrt
+	objList, err := h.dbUtil.GetAllObjFromDb(obj)
+	if err != nil {
+		h.logger.Errf("GetAllObjFromDb failed for BGPMrt with error %s", err)
+		return err
+	}
+
+	for _, confObj := range objList {
+		obj = confObj.(objects.BGPMrt)
+
+		mrtConf, err := h.convertModelToBGPMrt(obj)
+		if err != nil {
+			h.logger.Err("handleBGPMrt - Failed to convert Model object BGPMrt, error:", err)
+			return err
+		}
+		h.server.MRTConfigCh <- mrtConf
+	}
+	return nil
+}
+
+func (h *BGPHandler) convertModelToBGPRpki(obj objects.BGPRpki) (config.RPKICacheServer, error) {
+	cacheConf := config.RPKICacheServer{
+		Address:         h.convertStrIPToNetIP(obj.CacheAddress),
+		Port:            uint16(obj.CachePort),
+		RefreshInterval: uint32(obj.RefreshInterval),
+		RetryInterval:   uint32(obj.RetryInterval),
+		ExpireInterval:  uint32(obj.ExpireInterval),
+	}
+
+	if cacheConf.Address == nil {
+		return cacheConf, config.IPError{obj.CacheAddress}
+	}
+	return cacheConf, nil
+}
+
+func (h *BGPHandler) handleBGPRpki() error {
+	var obj objects.BGPRpki
+	objList, err := h.dbUtil.GetAllObjFromDb(obj)
+	if err != nil {
+		h.logger.Errf("GetAllObjFromDb failed for BGPRpki with error %s", err)
+		return err
+	}
+
+	for _, confObj := range objList {
+		obj = confObj.(objects.BGPRpki)
+
+		cacheConf, err := h.convertModelToBGPRpki(obj)
+		if err != nil {
+			h.logger.Err("handleBGPRpki - Failed to convert Model object BGPRpki, error:", err)
+			return err
+		}
+		h.server.RPKICacheCh <- cacheConf
+	}
+	return nil
+}
+
+func (h *BGPHandler) convertModelToBGPListenRange(obj objects.BGPListenRange) (config.ListenRange, error) {
+	_, prefix, err := net.ParseCIDR(obj.Prefix)
+	if err != nil {
+		return config.ListenRange{}, err
+	}
+
+	rangeConf := config.ListenRange{
+		Vrf:       obj.Vrf,
+		Prefix:    prefix,
+		PeerGroup: obj.PeerGroup,
+		AllowedAS: make([]uint32, 0, len(obj.AllowedAS)),
+		MaxPeers:  uint32(obj.MaxPeers),
+	}
+	for _, as := range obj.AllowedAS {
+		rangeConf.AllowedAS = append(rangeConf.AllowedAS, uint32(as))
+	}
+	return rangeConf, nil
+}
+
+func (h *BGPHandler) handleBGPListenRange() error {
+	var obj objects.BGPListenRange
+	objList, err := h.dbUtil.GetAllObjFromDb(obj)
+	if err != nil {
+		h.logger.Errf("GetAllObjFromDb failed for BGPListenRange with error %s", err)
+		return err
+	}
+
+	for _, confObj := range objList {
+		obj = confObj.(objects.BGPListenRange)
+
+		rangeConf, err := h.convertModelToBGPListenRange(obj)
+		if err != nil {
+			h.logger.Err("handleBGPListenRange - Failed to convert Model object BGPListenRange, error:", err)
+			return err
+		}
+		h.server.AddRangeCh <- rangeConf
+	}
+	return nil
+}
+
 func (h *BGPHandler) ReadBGPConfigFromDB() error {
 	var err error
 	if err = h.handleGlobalConfig(); err != nil {
 		return err
 	}
 
+	if err = h.handleBGPBmpCollector(); err != nil {
+		return err
+	}
+
+	if err = h.handleBGPMrt(); err != nil {
+		return err
+	}
+
+	if err = h.handleBGPRpki(); err != nil {
+		return err
+	}
+
 	if err = h.handleBGPv4Aggregate(); err != nil {
 		return err
 	}
@@ -515,6 +742,10 @@ func (h *BGPHandler) ReadBGPConfigFromDB() error {
 		return err
 	}
 
+	if err = h.handleBGPListenRange(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -527,6 +758,265 @@ func (h *BGPHandler) convertStrIPToNetIP(ip string) net.IP {
 	return netIP
 }
 
+func (h *BGPHandler) convertToDampeningConfig(enabled bool, halfLife, reuseLimit, suppressLimit,
+	maxSuppressTime int32) config.DampeningConfig {
+	return config.DampeningConfig{
+		Enabled:         enabled,
+		HalfLife:        uint16(halfLife),
+		ReuseLimit:      uint32(reuseLimit),
+		SuppressLimit:   uint32(suppressLimit),
+		MaxSuppressTime: uint16(maxSuppressTime),
+	}
+}
+
+func (h *BGPHandler) validateDampeningConfig(dampConf config.DampeningConfig) error {
+	if !dampConf.Enabled {
+		return nil
+	}
+
+	halfLife := dampConf.HalfLife
+	if halfLife == 0 {
+		halfLife = config.BGPDampeningHalfLifeDefault
+	}
+	reuseLimit := dampConf.ReuseLimit
+	if reuseLimit == 0 {
+		reuseLimit = config.BGPDampeningReuseLimitDefault
+	}
+	suppressLimit := dampConf.SuppressLimit
+	if suppressLimit == 0 {
+		suppressLimit = config.BGPDampeningSuppressLimitDefault
+	}
+	maxSuppressTime := dampConf.MaxSuppressTime
+	if maxSuppressTime == 0 {
+		maxSuppressTime = config.BGPDampeningMaxSuppressTimeDefault
+	}
+
+	if reuseLimit >= suppressLimit {
+		h.logger.Info("Dampening reuse limit", reuseLimit, "is not less than the suppress limit", suppressLimit)
+		return errors.New(fmt.Sprintf("Dampening reuse limit %d should be less than the suppress limit %d",
+			reuseLimit, suppressLimit))
+	}
+
+	if maxSuppressTime < halfLife {
+		h.logger.Info("Dampening max suppress time", maxSuppressTime, "is less than the half life", halfLife)
+		return errors.New(fmt.Sprintf("Dampening max suppress time %d should not be less than the half life %d",
+			maxSuppressTime, halfLife))
+	}
+	return nil
+}
+
+func (h *BGPHandler) validateVPNConfig(gConf config.GlobalBase) error {
+	if gConf.RouteDistinguisher != "" {
+		if gConf.Vrf == "" || gConf.Vrf == config.BGPDefaultVrf {
+			h.logger.Info("Route distinguisher", gConf.RouteDistinguisher, "is configured for the default VRF")
+			return errors.New("Route distinguisher can only be configured for a non-default VRF")
+		}
+		if _, err := packet.ParseRouteDistinguisher(gConf.RouteDistinguisher); err != nil {
+			h.logger.Info("Route distinguisher", gConf.RouteDistinguisher, "is not valid, error:", err)
+			return err
+		}
+	}
+
+	for _, routeTargets := range [][]string{gConf.ImportRouteTargets, gConf.ExportRouteTargets} {
+		for _, routeTarget := range routeTargets {
+			if _, err := packet.ParseRouteTarget(routeTarget); err != nil {
+				h.logger.Info("Route target", routeTarget, "is not valid, error:", err)
+				return errors.New(fmt.Sprintf("Route target %s is not valid", routeTarget))
+			}
+		}
+	}
+	return nil
+}
+
+func (h *BGPHandler) validateOriginValidation(originValidation string) error {
+	if originValidation != "" && originValidation != config.OriginValidationPreferValid &&
+		originValidation != config.OriginValidationExcludeInvalid {
+		h.logger.Info("Origin validation", originValidation, "is not valid")
+		return errors.New(fmt.Sprintf("Origin validation %s is not valid, valid values are %s and %s",
+			originValidation, config.OriginValidationPreferValid, config.OriginValidationExcludeInvalid))
+	}
+	return nil
+}
+
+// validateASPathConfig validates the AS path options of the neighbor or the peer group.
+func (h *BGPHandler) validateASPathConfig(baseConf config.BaseConfig) error {
+	if baseConf.AllowAsIn > config.BGPAllowAsInMax {
+		return errors.New(fmt.Sprintf("AllowAsIn %d is more than the max value %d", baseConf.AllowAsIn,
+			config.BGPAllowAsInMax))
+	}
+
+	if baseConf.RemovePrivateAS != "" && baseConf.RemovePrivateAS != config.RemovePrivateASAll &&
+		baseConf.RemovePrivateAS != config.RemovePrivateASReplace {
+		return errors.New(fmt.Sprintf("RemovePrivateAS %s is not valid, valid values are %s and %s",
+			baseConf.RemovePrivateAS, config.RemovePrivateASAll, config.RemovePrivateASReplace))
+	}
+	return nil
+}
+
+// normalizePrefix returns the prefix in the CIDR notation with the host bits cleared. The prefix is returned as
+// is if it's not valid, it's then rejected by the validation of the config.
+func (h *BGPHandler) normalizePrefix(prefix string) string {
+	if prefix == "" {
+		return prefix
+	}
+
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(prefix))
+	if err != nil {
+		return prefix
+	}
+	return ipNet.String()
+}
+
+func (h *BGPHandler) convertToConditionalAdvConfig(prefixes []string, condPrefix string,
+	nonExist bool) config.ConditionalAdvConfig {
+	condAdv := config.ConditionalAdvConfig{
+		Prefixes:        make([]string, 0, len(prefixes)),
+		ConditionPrefix: h.normalizePrefix(condPrefix),
+		NonExist:        nonExist,
+	}
+	for _, prefix := range prefixes {
+		condAdv.Prefixes = append(condAdv.Prefixes, h.normalizePrefix(prefix))
+	}
+	return condAdv
+}
+
+// convertToAddPathsConfig converts the per AFI-SAFI add path config of the neighbor or the peer group.
+func (h *BGPHandler) convertToAddPathsConfig(addPaths []*bgpd.BGPAddPathsAfiSafi) []config.AddPathsConfig {
+	addPathsConf := make([]config.AddPathsConfig, 0, len(addPaths))
+	for _, addPath := range addPaths {
+		if addPath == nil {
+			continue
+		}
+		addPathsConf = append(addPathsConf, config.AddPathsConfig{
+			AfiSafiName: strings.ToLower(addPath.AfiSafiName),
+			Rx:          addPath.Rx,
+			TxMode:      strings.ToLower(addPath.TxMode),
+			MaxTx:       uint8(addPath.MaxTx),
+		})
+	}
+	return addPathsConf
+}
+
+func (h *BGPHandler) convertToThriftAddPaths(addPathsConf []config.AddPathsConfig) []*bgpd.BGPAddPathsAfiSafi {
+	addPaths := make([]*bgpd.BGPAddPathsAfiSafi, 0, len(addPathsConf))
+	for _, addPath := range addPathsConf {
+		addPaths = append(addPaths, &bgpd.BGPAddPathsAfiSafi{
+			AfiSafiName: addPath.AfiSafiName,
+			Rx:          addPath.Rx,
+			TxMode:      addPath.TxMode,
+			MaxTx:       int8(addPath.MaxTx),
+		})
+	}
+	return addPaths
+}
+
+// validateAddPathsConfig validates the per AFI-SAFI add path config of the neighbor or the peer group. Add path
+// is only supported for the unicast families.
+func (h *BGPHandler) validateAddPathsConfig(baseConf config.BaseConfig) error {
+	families := make(map[uint32]bool)
+	for _, addPath := range baseConf.AddPaths {
+		protoFamily, ok := packet.ProtocolFamilyMap[addPath.AfiSafiName]
+		if !ok || !packet.IsUnicastFamily(protoFamily) {
+			return errors.New(fmt.Sprintf("Add paths is not supported for AFI-SAFI %s", addPath.AfiSafiName))
+		}
+		if families[protoFamily] {
+			return errors.New(fmt.Sprintf("Add paths is configured more than once for AFI-SAFI %s",
+				addPath.AfiSafiName))
+		}
+		families[protoFamily] = true
+
+		switch addPath.TxMode {
+		case "", config.AddPathsTxModeAll, config.AddPathsTxModeECMP, config.AddPathsTxModeBackup:
+			if addPath.MaxTx != 0 {
+				return errors.New(fmt.Sprintf("Add paths max tx is only valid with the TX mode %s, AFI-SAFI %s",
+					config.AddPathsTxModeBestN, addPath.AfiSafiName))
+			}
+		case config.AddPathsTxModeBestN:
+			if addPath.MaxTx < 2 {
+				return errors.New(fmt.Sprintf("Add paths max tx %d for AFI-SAFI %s should be at least 2",
+					addPath.MaxTx, addPath.AfiSafiName))
+			}
+		default:
+			return errors.New(fmt.Sprintf("Add paths TX mode %s is not valid for AFI-SAFI %s, valid modes are "+
+				"%s, %s, %s, %s", addPath.TxMode, addPath.AfiSafiName, config.AddPathsTxModeAll,
+				config.AddPathsTxModeBestN, config.AddPathsTxModeECMP, config.AddPathsTxModeBackup))
+		}
+	}
+	return nil
+}
+
+// validateAdvConditionConfig validates the default route origination and the conditional advertisement config
+// of the neighbor or the peer group.
+func (h *BGPHandler) validateAdvConditionConfig(baseConf config.BaseConfig) error {
+	if baseConf.DefaultOriginateCond != "" {
+		if _, _, err := net.ParseCIDR(baseConf.DefaultOriginateCond); err != nil {
+			return errors.New(fmt.Sprintf("Default originate condition %s is not a valid prefix",
+				baseConf.DefaultOriginateCond))
+		}
+	}
+
+	condAdv := baseConf.ConditionalAdv
+	if condAdv.ConditionPrefix == "" {
+		if len(condAdv.Prefixes) > 0 {
+			return errors.New("Advertise condition prefix is not set for the advertise prefixes")
+		}
+		return nil
+	}
+
+	if len(condAdv.Prefixes) == 0 {
+		return errors.New(fmt.Sprintf("Advertise prefixes are not set for the advertise condition prefix %s",
+			condAdv.ConditionPrefix))
+	}
+
+	for _, prefix := range append([]string{condAdv.ConditionPrefix}, condAdv.Prefixes...) {
+		if _, _, err := net.ParseCIDR(prefix); err != nil {
+			return errors.New(fmt.Sprintf("Conditional advertisement prefix %s is not valid", prefix))
+		}
+	}
+	return nil
+}
+
+// convertToConfedConfig converts the confederation identifier and the member AS numbers of the other
+// sub-ASes, in asplain or asdot notation, to the AS numbers.
+func (h *BGPHandler) convertToConfedConfig(confedId string, confedPeers []string) (uint32, []uint32, error) {
+	id, err := bgputils.GetAsNum(confedId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	peers := make([]uint32, 0, len(confedPeers))
+	for _, peer := range confedPeers {
+		as, err := bgputils.GetAsNum(peer)
+		if err != nil {
+			return 0, nil, err
+		}
+		peers = append(peers, uint32(as))
+	}
+	return uint32(id), peers, nil
+}
+
+func (h *BGPHandler) validateConfedConfig(gConf config.GlobalBase) error {
+	if gConf.ConfederationId == 0 {
+		if len(gConf.ConfederationPeers) > 0 {
+			return errors.New(fmt.Sprintf("BGPGlobal: Confederation peers %v are set without the confederation "+
+				"identifier", gConf.ConfederationPeers))
+		}
+		return nil
+	}
+
+	if gConf.ConfederationId == gConf.AS || gConf.ConfederationId == uint32(packet.BGPASTrans) {
+		return errors.New(fmt.Sprintf("BGPGlobal: Confederation identifier %d is not valid",
+			gConf.ConfederationId))
+	}
+
+	for _, as := range gConf.ConfederationPeers {
+		if as == 0 || as == gConf.AS || as == gConf.ConfederationId {
+			return errors.New(fmt.Sprintf("BGPGlobal: Confederation peer AS %d is not valid", as))
+		}
+	}
+	return nil
+}
+
 func (h *BGPHandler) validateBGPGlobal(bgpGlobal *bgpd.BGPGlobal) (gConf config.GlobalConfig, err error) {
 	if bgpGlobal == nil {
 		return gConf, err
@@ -550,6 +1040,13 @@ func (h *BGPHandler) validateBGPGlobal(bgpGlobal *bgpd.BGPGlobal) (gConf config.
 		return gConf, err
 	}
 
+	if bgpGlobal.RestartTime < 0 || bgpGlobal.RestartTime > int32(packet.BGPCapGRRestartTimeMax) {
+		err = errors.New(fmt.Sprintf("BGPGlobal: Restart time %d is not valid", bgpGlobal.RestartTime))
+		h.logger.Info("SendBGPGlobal: Restart time", bgpGlobal.RestartTime, "is not in the range 0 -",
+			packet.BGPCapGRRestartTimeMax)
+		return gConf, err
+	}
+
 	gConf = config.GlobalConfig{
 		GlobalBase: config.GlobalBase{
 			Vrf:                 bgpGlobal.Vrf,
@@ -560,9 +1057,39 @@ func (h *BGPHandler) validateBGPGlobal(bgpGlobal *bgpd.BGPGlobal) (gConf config.
 			EBGPMaxPaths:        uint32(bgpGlobal.EBGPMaxPaths),
 			EBGPAllowMultipleAS: bgpGlobal.EBGPAllowMultipleAS,
 			IBGPMaxPaths:        uint32(bgpGlobal.IBGPMaxPaths),
+			GracefulRestart:     bgpGlobal.GracefulRestart,
+			RestartTime:         uint16(bgpGlobal.RestartTime),
+			StalePathTime:       uint16(bgpGlobal.StalePathTime),
+			Dampening: h.convertToDampeningConfig(bgpGlobal.Dampening, bgpGlobal.DampeningHalfLife,
+				bgpGlobal.DampeningReuseLimit, bgpGlobal.DampeningSuppressLimit, bgpGlobal.DampeningMaxSuppressTime),
+			RouteDistinguisher: bgpGlobal.RouteDistinguisher,
+			ImportRouteTargets: bgpGlobal.ImportRouteTargets,
+			ExportRouteTargets: bgpGlobal.ExportRouteTargets,
+			OriginValidation:   bgpGlobal.OriginValidation,
 		},
 	}
 
+	if gConf.ConfederationId, gConf.ConfederationPeers, err = h.convertToConfedConfig(bgpGlobal.ConfederationId,
+		bgpGlobal.ConfederationPeers); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateDampeningConfig(gConf.Dampening); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateVPNConfig(gConf.GlobalBase); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateOriginValidation(gConf.OriginValidation); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateConfedConfig(gConf.GlobalBase); err != nil {
+		return gConf, err
+	}
+
 	if bgpGlobal.Redistribution != nil {
 		gConf.Redistribution = make([]config.SourcePolicyMap, 0)
 		for i := 0; i < len(bgpGlobal.Redistribution); i++ {
@@ -603,9 +1130,23 @@ func (h *BGPHandler) validateBGPGlobalForPatchUpdate(oldConfig *bgpd.BGPGlobal,
 			EBGPMaxPaths:        uint32(oldConfig.EBGPMaxPaths),
 			EBGPAllowMultipleAS: oldConfig.EBGPAllowMultipleAS,
 			IBGPMaxPaths:        uint32(oldConfig.IBGPMaxPaths),
+			GracefulRestart:     oldConfig.GracefulRestart,
+			RestartTime:         uint16(oldConfig.RestartTime),
+			StalePathTime:       uint16(oldConfig.StalePathTime),
+			Dampening: h.convertToDampeningConfig(oldConfig.Dampening, oldConfig.DampeningHalfLife,
+				oldConfig.DampeningReuseLimit, oldConfig.DampeningSuppressLimit, oldConfig.DampeningMaxSuppressTime),
+			RouteDistinguisher: oldConfig.RouteDistinguisher,
+			ImportRouteTargets: oldConfig.ImportRouteTargets,
+			ExportRouteTargets: oldConfig.ExportRouteTargets,
+			OriginValidation:   oldConfig.OriginValidation,
 		},
 	}
 
+	if gConf.ConfederationId, gConf.ConfederationPeers, err = h.convertToConfedConfig(oldConfig.ConfederationId,
+		oldConfig.ConfederationPeers); err != nil {
+		return gConf, err
+	}
+
 	for idx := 0; idx < len(op); idx++ {
 		h.logger.Debug("patch update")
 		switch op[idx].Path {
@@ -674,9 +1215,39 @@ func (h *BGPHandler) validateBGPGlobalForUpdate(oldConfig *bgpd.BGPGlobal, newCo
 			EBGPMaxPaths:        uint32(newConfig.EBGPMaxPaths),
 			EBGPAllowMultipleAS: newConfig.EBGPAllowMultipleAS,
 			IBGPMaxPaths:        uint32(newConfig.IBGPMaxPaths),
+			GracefulRestart:     newConfig.GracefulRestart,
+			RestartTime:         uint16(newConfig.RestartTime),
+			StalePathTime:       uint16(newConfig.StalePathTime),
+			Dampening: h.convertToDampeningConfig(newConfig.Dampening, newConfig.DampeningHalfLife,
+				newConfig.DampeningReuseLimit, newConfig.DampeningSuppressLimit, newConfig.DampeningMaxSuppressTime),
+			RouteDistinguisher: newConfig.RouteDistinguisher,
+			ImportRouteTargets: newConfig.ImportRouteTargets,
+			ExportRouteTargets: newConfig.ExportRouteTargets,
+			OriginValidation:   newConfig.OriginValidation,
 		},
 	}
 
+	if gConf.ConfederationId, gConf.ConfederationPeers, err = h.convertToConfedConfig(newConfig.ConfederationId,
+		newConfig.ConfederationPeers); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateDampeningConfig(gConf.Dampening); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateVPNConfig(gConf.GlobalBase); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateOriginValidation(gConf.OriginValidation); err != nil {
+		return gConf, err
+	}
+
+	if err = h.validateConfedConfig(gConf.GlobalBase); err != nil {
+		return gConf, err
+	}
+
 	if newConfig.Redistribution != nil {
 		gConf.Redistribution = make([]config.SourcePolicyMap, 0)
 		for i := 0; i < len(newConfig.Redistribution); i++ {
@@ -744,7 +1315,14 @@ func (h *BGPHandler) CreateBGPGlobal(bgpGlobal *bgpd.BGPGlobal) (bool, error) {
 }
 
 func (h *BGPHandler) GetBGPGlobalState(vrfId string) (*bgpd.BGPGlobalState, error) {
-	bgpGlobal := h.server.GetBGPGlobalState()
+	bgpGlobal, ok := h.server.GetBGPGlobalState(vrfId)
+	if !ok {
+		return bgpd.NewBGPGlobalState(), errors.New(fmt.Sprintf("BGP global not configured for VRF %s", vrfId))
+	}
+	return h.convertToThriftGlobalState(bgpGlobal), nil
+}
+
+func (h *BGPHandler) convertToThriftGlobalState(bgpGlobal config.GlobalState) *bgpd.BGPGlobalState {
 	bgpGlobalResponse := bgpd.NewBGPGlobalState()
 	bgpGlobalResponse.Vrf = bgpGlobal.Vrf
 	bgpGlobalResponse.AS, _ = bgputils.GetAsDot(int(bgpGlobal.AS)) //int32(bgpGlobal.AS)
@@ -754,20 +1332,46 @@ func (h *BGPHandler) GetBGPGlobalState(vrfId string) (*bgpd.BGPGlobalState, erro
 	bgpGlobalResponse.EBGPMaxPaths = int32(bgpGlobal.EBGPMaxPaths)
 	bgpGlobalResponse.EBGPAllowMultipleAS = bgpGlobal.EBGPAllowMultipleAS
 	bgpGlobalResponse.IBGPMaxPaths = int32(bgpGlobal.IBGPMaxPaths)
+	bgpGlobalResponse.GracefulRestart = bgpGlobal.GracefulRestart
+	bgpGlobalResponse.RestartTime = int32(bgpGlobal.RestartTime)
+	bgpGlobalResponse.StalePathTime = int32(bgpGlobal.StalePathTime)
+	bgpGlobalResponse.Dampening = bgpGlobal.Dampening.Enabled
+	bgpGlobalResponse.DampeningHalfLife = int32(bgpGlobal.Dampening.HalfLife)
+	bgpGlobalResponse.DampeningReuseLimit = int32(bgpGlobal.Dampening.ReuseLimit)
+	bgpGlobalResponse.DampeningSuppressLimit = int32(bgpGlobal.Dampening.SuppressLimit)
+	bgpGlobalResponse.DampeningMaxSuppressTime = int32(bgpGlobal.Dampening.MaxSuppressTime)
+	bgpGlobalResponse.RouteDistinguisher = bgpGlobal.RouteDistinguisher
+	bgpGlobalResponse.ImportRouteTargets = bgpGlobal.ImportRouteTargets
+	bgpGlobalResponse.ExportRouteTargets = bgpGlobal.ExportRouteTargets
+	bgpGlobalResponse.OriginValidation = bgpGlobal.OriginValidation
+	bgpGlobalResponse.ConfederationId, _ = bgputils.GetAsDot(int(bgpGlobal.ConfederationId))
+	bgpGlobalResponse.ConfederationPeers = make([]string, 0, len(bgpGlobal.ConfederationPeers))
+	for _, as := range bgpGlobal.ConfederationPeers {
+		asDot, _ := bgputils.GetAsDot(int(as))
+		bgpGlobalResponse.ConfederationPeers = append(bgpGlobalResponse.ConfederationPeers, asDot)
+	}
 	bgpGlobalResponse.TotalPaths = int32(bgpGlobal.TotalPaths)
 	bgpGlobalResponse.Totalv4Prefixes = int32(bgpGlobal.Totalv4Prefixes)
 	bgpGlobalResponse.Totalv6Prefixes = int32(bgpGlobal.Totalv6Prefixes)
-	return bgpGlobalResponse, nil
+	return bgpGlobalResponse
 }
 
 func (h *BGPHandler) GetBulkBGPGlobalState(index bgpd.Int,
 	count bgpd.Int) (*bgpd.BGPGlobalStateGetInfo, error) {
+	globalStates := h.server.GetBGPGlobalStates()
 	bgpGlobalStateBulk := bgpd.NewBGPGlobalStateGetInfo()
-	bgpGlobalStateBulk.EndIdx = bgpd.Int(0)
-	bgpGlobalStateBulk.Count = bgpd.Int(1)
-	bgpGlobalStateBulk.More = false
-	bgpGlobalStateBulk.BGPGlobalStateList = make([]*bgpd.BGPGlobalState, 1)
-	bgpGlobalStateBulk.BGPGlobalStateList[0], _ = h.GetBGPGlobalState("default")
+	bgpGlobalStateBulk.BGPGlobalStateList = make([]*bgpd.BGPGlobalState, 0)
+	idx := int(index)
+	for ; idx < len(globalStates) && len(bgpGlobalStateBulk.BGPGlobalStateList) < int(count); idx++ {
+		bgpGlobalStateBulk.BGPGlobalStateList = append(bgpGlobalStateBulk.BGPGlobalStateList,
+			h.convertToThriftGlobalState(globalStates[idx]))
+	}
+	if idx >= len(globalStates) {
+		idx = 0
+	}
+	bgpGlobalStateBulk.EndIdx = bgpd.Int(idx)
+	bgpGlobalStateBulk.Count = bgpd.Int(len(bgpGlobalStateBulk.BGPGlobalStateList))
+	bgpGlobalStateBulk.More = (idx != 0)
 
 	return bgpGlobalStateBulk, nil
 }
@@ -780,14 +1384,27 @@ func (h *BGPHandler) UpdateBGPGlobal(origG *bgpd.BGPGlobal, updatedG *bgpd.BGPGl
 
 func (h *BGPHandler) DeleteBGPGlobal(bgpGlobal *bgpd.BGPGlobal) (bool, error) {
 	h.logger.Info("Delete global config attrs:", bgpGlobal)
-	return false, errors.New(fmt.Sprintf("Can't delete BGP global object"))
+	if bgpGlobal.Vrf == "" || bgpGlobal.Vrf == config.BGPDefaultVrf {
+		return false, errors.New(fmt.Sprintf("Can't delete BGP global object of the default VRF"))
+	}
+
+	if err := h.checkBGPGlobal(bgpGlobal.Vrf); err != nil {
+		return false, err
+	}
+
+	gConf := config.GlobalConfig{Vrf: bgpGlobal.Vrf}
+	delete(h.globalASMap, bgpGlobal.Vrf)
+	h.server.GlobalConfigCh <- server.GlobalUpdate{bgpGlobal, gConf, gConf, make([]bool, 0), nil, "delete"}
+	return true, nil
 }
 
-func (h *BGPHandler) checkBGPGlobal() error {
-	vrf := "default"
+func (h *BGPHandler) checkBGPGlobal(vrf string) error {
+	if vrf == "" {
+		vrf = config.BGPDefaultVrf
+	}
 
 	if as, ok := h.globalASMap[vrf]; !ok || as == 0 {
-		return errors.New(fmt.Sprintf("The default BGP AS number 0 is not updated yet."))
+		return errors.New(fmt.Sprintf("The BGP AS number of VRF %s is not configured yet.", vrf))
 	}
 
 	return nil
@@ -858,6 +1475,7 @@ func (h *BGPHandler) ConvertV4NeighborFromThrift(bgpNeighbor *bgpd.BGPv4Neighbor
 		return pConf, err
 	}
 	pConf = config.NeighborConfig{
+		Vrf: bgpNeighbor.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -883,6 +1501,19 @@ func (h *BGPHandler) ConvertV4NeighborFromThrift(bgpNeighbor *bgpd.BGPv4Neighbor
 			MaxPrefixesRestartTimer: uint8(bgpNeighbor.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          bgpNeighbor.AdjRIBInFilter,
 			AdjRIBOutFilter:         bgpNeighbor.AdjRIBOutFilter,
+			AllowAsIn:               uint8(bgpNeighbor.AllowAsIn),
+			RemovePrivateAS:         bgpNeighbor.RemovePrivateAS,
+			LocalASNoPrepend:        bgpNeighbor.LocalASNoPrepend,
+			LocalASReplaceAS:        bgpNeighbor.LocalASReplaceAS,
+			AsOverride:              bgpNeighbor.AsOverride,
+			DefaultOriginate:        bgpNeighbor.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(bgpNeighbor.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(bgpNeighbor.AdvertisePrefixes, bgpNeighbor.AdvertiseConditionPrefix,
+				bgpNeighbor.AdvertiseNonExist),
+			LabeledUnicast: bgpNeighbor.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(bgpNeighbor.AddPaths),
+			Dampening: h.convertToDampeningConfig(bgpNeighbor.Dampening, bgpNeighbor.DampeningHalfLife,
+				bgpNeighbor.DampeningReuseLimit, bgpNeighbor.DampeningSuppressLimit, bgpNeighbor.DampeningMaxSuppressTime),
 		},
 		NeighborAddress: ip,
 		IfIndex:         ifIndex,
@@ -912,6 +1543,16 @@ func (h *BGPHandler) ValidateV4Neighbor(bgpNeighbor *bgpd.BGPv4Neighbor) (pConf
 		return pConf, err
 	}
 	pConf, _ = h.ConvertV4NeighborFromThrift(bgpNeighbor, ip, ifIndex)
+	if err = h.validateASPathConfig(pConf.BaseConfig); err != nil {
+		return pConf, err
+	}
+	if err = h.validateAdvConditionConfig(pConf.BaseConfig); err != nil {
+		return pConf, err
+	}
+	if err = h.validateAddPathsConfig(pConf.BaseConfig); err != nil {
+		return pConf, err
+	}
+	err = h.validateDampeningConfig(pConf.Dampening)
 	return pConf, err
 }
 
@@ -941,7 +1582,7 @@ func (h *BGPHandler) SendBGPv4Neighbor(oldNeigh *bgpd.BGPv4Neighbor, newNeigh *b
 	patchOp []*bgpd.PatchOpInfo, op string) (
 	bool, error) {
 	h.logger.Info("SendBGPv4Neighbor, op:", op)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(newNeigh.Vrf); err != nil {
 		h.logger.Err("checkBGPGlobal failed with err:", err)
 		return false, err
 	}
@@ -982,6 +1623,7 @@ func (h *BGPHandler) CreateBGPv4Neighbor(bgpNeighbor *bgpd.BGPv4Neighbor) (bool,
 
 func (h *BGPHandler) convertToThriftV4Neighbor(neighborState *config.NeighborState) *bgpd.BGPv4NeighborState {
 	bgpNeighborResponse := bgpd.NewBGPv4NeighborState()
+	bgpNeighborResponse.Vrf = neighborState.Vrf
 	bgpNeighborResponse.NeighborAddress = neighborState.NeighborAddress.String()
 	//bgpNeighborResponse.IfIndex = neighborState.IfIndex
 	bgpNeighborResponse.IntfRef = "" //strconv.Itoa(int(neighborState.IfIndex))
@@ -1029,6 +1671,23 @@ func (h *BGPHandler) convertToThriftV4Neighbor(neighborState *config.NeighborSta
 	bgpNeighborResponse.TotalPrefixes = int32(neighborState.TotalPrefixes)
 	bgpNeighborResponse.AdjRIBInFilter = neighborState.AdjRIBInFilter
 	bgpNeighborResponse.AdjRIBOutFilter = neighborState.AdjRIBOutFilter
+	bgpNeighborResponse.AllowAsIn = int8(neighborState.AllowAsIn)
+	bgpNeighborResponse.RemovePrivateAS = neighborState.RemovePrivateAS
+	bgpNeighborResponse.LocalASNoPrepend = neighborState.LocalASNoPrepend
+	bgpNeighborResponse.LocalASReplaceAS = neighborState.LocalASReplaceAS
+	bgpNeighborResponse.AsOverride = neighborState.AsOverride
+	bgpNeighborResponse.DefaultOriginate = neighborState.DefaultOriginate
+	bgpNeighborResponse.DefaultOriginateCondition = neighborState.DefaultOriginateCond
+	bgpNeighborResponse.AdvertisePrefixes = neighborState.ConditionalAdv.Prefixes
+	bgpNeighborResponse.AdvertiseConditionPrefix = neighborState.ConditionalAdv.ConditionPrefix
+	bgpNeighborResponse.AdvertiseNonExist = neighborState.ConditionalAdv.NonExist
+	bgpNeighborResponse.LabeledUnicast = neighborState.LabeledUnicast
+	bgpNeighborResponse.AddPaths = h.convertToThriftAddPaths(neighborState.AddPaths)
+	bgpNeighborResponse.Dampening = neighborState.Dampening.Enabled
+	bgpNeighborResponse.DampeningHalfLife = int32(neighborState.Dampening.HalfLife)
+	bgpNeighborResponse.DampeningReuseLimit = int32(neighborState.Dampening.ReuseLimit)
+	bgpNeighborResponse.DampeningSuppressLimit = int32(neighborState.Dampening.SuppressLimit)
+	bgpNeighborResponse.DampeningMaxSuppressTime = int32(neighborState.Dampening.MaxSuppressTime)
 
 	received := bgpd.NewBGPCounters()
 	received.Notification = int64(neighborState.Messages.Received.Notification)
@@ -1049,7 +1708,7 @@ func (h *BGPHandler) convertToThriftV4Neighbor(neighborState *config.NeighborSta
 	return bgpNeighborResponse
 }
 
-func (h *BGPHandler) GetBGPv4NeighborState(neighborAddr string, intfref string) (*bgpd.BGPv4NeighborState, error) {
+func (h *BGPHandler) GetBGPv4NeighborState(vrf string, neighborAddr string, intfref string) (*bgpd.BGPv4NeighborState, error) {
 	ip, _, _, err := h.getIPAndIfIndexForV4Neighbor(neighborAddr, intfref)
 	if err != nil {
 		h.logger.Info("GetBGPv4NeighborState: getIPAndIfIndexForV4Neighbor failed for neighbor address", neighborAddr,
@@ -1057,7 +1716,7 @@ func (h *BGPHandler) GetBGPv4NeighborState(neighborAddr string, intfref string)
 		return bgpd.NewBGPv4NeighborState(), err
 	}
 
-	bgpNeighborState := h.server.GetBGPNeighborState(ip.String())
+	bgpNeighborState := h.server.GetBGPNeighborState(vrf, ip.String())
 	if bgpNeighborState == nil {
 		return bgpd.NewBGPv4NeighborState(), errors.New(fmt.Sprintf("GetBGPNeighborState: Neighbor %s not configured", ip))
 	}
@@ -1089,7 +1748,7 @@ func (h *BGPHandler) UpdateBGPv4Neighbor(origN *bgpd.BGPv4Neighbor, updatedN *bg
 
 func (h *BGPHandler) DeleteBGPv4Neighbor(bgpNeighbor *bgpd.BGPv4Neighbor) (bool, error) {
 	h.logger.Info("Delete BGPv4 neighbor:", bgpNeighbor.NeighborAddress)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(bgpNeighbor.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1146,6 +1805,7 @@ func (h *BGPHandler) ConvertV6NeighborFromThrift(bgpNeighbor *bgpd.BGPv6Neighbor
 		return pConf, err
 	}
 	pConf = config.NeighborConfig{
+		Vrf: bgpNeighbor.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -1170,6 +1830,19 @@ func (h *BGPHandler) ConvertV6NeighborFromThrift(bgpNeighbor *bgpd.BGPv6Neighbor
 			MaxPrefixesRestartTimer: uint8(bgpNeighbor.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          bgpNeighbor.AdjRIBInFilter,
 			AdjRIBOutFilter:         bgpNeighbor.AdjRIBOutFilter,
+			AllowAsIn:               uint8(bgpNeighbor.AllowAsIn),
+			RemovePrivateAS:         bgpNeighbor.RemovePrivateAS,
+			LocalASNoPrepend:        bgpNeighbor.LocalASNoPrepend,
+			LocalASReplaceAS:        bgpNeighbor.LocalASReplaceAS,
+			AsOverride:              bgpNeighbor.AsOverride,
+			DefaultOriginate:        bgpNeighbor.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(bgpNeighbor.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(bgpNeighbor.AdvertisePrefixes, bgpNeighbor.AdvertiseConditionPrefix,
+				bgpNeighbor.AdvertiseNonExist),
+			LabeledUnicast: bgpNeighbor.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(bgpNeighbor.AddPaths),
+			Dampening: h.convertToDampeningConfig(bgpNeighbor.Dampening, bgpNeighbor.DampeningHalfLife,
+				bgpNeighbor.DampeningReuseLimit, bgpNeighbor.DampeningSuppressLimit, bgpNeighbor.DampeningMaxSuppressTime),
 		},
 		NeighborAddress: ip,
 		IfIndex:         ifIndex,
@@ -1203,6 +1876,16 @@ func (h *BGPHandler) ValidateV6Neighbor(bgpNeighbor *bgpd.BGPv6Neighbor) (pConf
 	}
 
 	pConf, _ = h.ConvertV6NeighborFromThrift(bgpNeighbor, ip, ifIndex, ifName)
+	if err = h.validateASPathConfig(pConf.BaseConfig); err != nil {
+		return pConf, err
+	}
+	if err = h.validateAdvConditionConfig(pConf.BaseConfig); err != nil {
+		return pConf, err
+	}
+	if err = h.validateAddPathsConfig(pConf.BaseConfig); err != nil {
+		return pConf, err
+	}
+	err = h.validateDampeningConfig(pConf.Dampening)
 	return pConf, err
 }
 
@@ -1230,7 +1913,7 @@ func (h *BGPHandler) ValidateV6NeighborForUpdate(oldNeigh *bgpd.BGPv6Neighbor, o
 
 func (h *BGPHandler) SendBGPv6Neighbor(oldNeigh *bgpd.BGPv6Neighbor, newNeigh *bgpd.BGPv6Neighbor, attrSet []bool, patchOp []*bgpd.PatchOpInfo, op string) (
 	bool, error) {
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(newNeigh.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1263,6 +1946,7 @@ func (h *BGPHandler) CreateBGPv6Neighbor(bgpNeighbor *bgpd.BGPv6Neighbor) (bool,
 
 func (h *BGPHandler) convertToThriftV6Neighbor(neighborState *config.NeighborState) *bgpd.BGPv6NeighborState {
 	bgpNeighborResponse := bgpd.NewBGPv6NeighborState()
+	bgpNeighborResponse.Vrf = neighborState.Vrf
 	bgpNeighborResponse.NeighborAddress = neighborState.NeighborAddress.String()
 	//bgpNeighborResponse.IfIndex = neighborState.IfIndex
 	bgpNeighborResponse.IntfRef = "" //strconv.Itoa(int(neighborState.IfIndex))
@@ -1309,6 +1993,23 @@ func (h *BGPHandler) convertToThriftV6Neighbor(neighborState *config.NeighborSta
 	bgpNeighborResponse.TotalPrefixes = int32(neighborState.TotalPrefixes)
 	bgpNeighborResponse.AdjRIBInFilter = neighborState.AdjRIBInFilter
 	bgpNeighborResponse.AdjRIBOutFilter = neighborState.AdjRIBOutFilter
+	bgpNeighborResponse.AllowAsIn = int8(neighborState.AllowAsIn)
+	bgpNeighborResponse.RemovePrivateAS = neighborState.RemovePrivateAS
+	bgpNeighborResponse.LocalASNoPrepend = neighborState.LocalASNoPrepend
+	bgpNeighborResponse.LocalASReplaceAS = neighborState.LocalASReplaceAS
+	bgpNeighborResponse.AsOverride = neighborState.AsOverride
+	bgpNeighborResponse.DefaultOriginate = neighborState.DefaultOriginate
+	bgpNeighborResponse.DefaultOriginateCondition = neighborState.DefaultOriginateCond
+	bgpNeighborResponse.AdvertisePrefixes = neighborState.ConditionalAdv.Prefixes
+	bgpNeighborResponse.AdvertiseConditionPrefix = neighborState.ConditionalAdv.ConditionPrefix
+	bgpNeighborResponse.AdvertiseNonExist = neighborState.ConditionalAdv.NonExist
+	bgpNeighborResponse.LabeledUnicast = neighborState.LabeledUnicast
+	bgpNeighborResponse.AddPaths = h.convertToThriftAddPaths(neighborState.AddPaths)
+	bgpNeighborResponse.Dampening = neighborState.Dampening.Enabled
+	bgpNeighborResponse.DampeningHalfLife = int32(neighborState.Dampening.HalfLife)
+	bgpNeighborResponse.DampeningReuseLimit = int32(neighborState.Dampening.ReuseLimit)
+	bgpNeighborResponse.DampeningSuppressLimit = int32(neighborState.Dampening.SuppressLimit)
+	bgpNeighborResponse.DampeningMaxSuppressTime = int32(neighborState.Dampening.MaxSuppressTime)
 
 	received := bgpd.NewBGPCounters()
 	received.Notification = int64(neighborState.Messages.Received.Notification)
@@ -1329,7 +2030,7 @@ func (h *BGPHandler) convertToThriftV6Neighbor(neighborState *config.NeighborSta
 	return bgpNeighborResponse
 }
 
-func (h *BGPHandler) GetBGPv6NeighborState(neighborAddr string, intfref string) (*bgpd.BGPv6NeighborState, error) {
+func (h *BGPHandler) GetBGPv6NeighborState(vrf string, neighborAddr string, intfref string) (*bgpd.BGPv6NeighborState, error) {
 	ip, _, _, err := h.getIPAndIfIndexForV6Neighbor(neighborAddr, intfref)
 	if err != nil {
 		h.logger.Info("GetBGPv4NeighborState: getIPAndIfIndexForV4Neighbor failed for neighbor address", neighborAddr,
@@ -1337,7 +2038,7 @@ func (h *BGPHandler) GetBGPv6NeighborState(neighborAddr string, intfref string)
 		return bgpd.NewBGPv6NeighborState(), err
 	}
 
-	bgpNeighborState := h.server.GetBGPNeighborState(ip.String())
+	bgpNeighborState := h.server.GetBGPNeighborState(vrf, ip.String())
 	if bgpNeighborState == nil {
 		return bgpd.NewBGPv6NeighborState(), errors.New(fmt.Sprintf("GetBGPNeighborState: Neighbor %s not configured", ip))
 	}
@@ -1370,7 +2071,7 @@ func (h *BGPHandler) UpdateBGPv6Neighbor(origN *bgpd.BGPv6Neighbor, updatedN *bg
 
 func (h *BGPHandler) DeleteBGPv6Neighbor(bgpNeighbor *bgpd.BGPv6Neighbor) (bool, error) {
 	h.logger.Info("Delete BGPv6 neighbor:", bgpNeighbor.NeighborAddress)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(bgpNeighbor.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1412,6 +2113,7 @@ func (h *BGPHandler) ValidateBGPv4PeerGroup(peerGroup *bgpd.BGPv4PeerGroup) (gro
 	}
 
 	group = config.PeerGroupConfig{
+		Vrf: peerGroup.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -1435,16 +2137,39 @@ func (h *BGPHandler) ValidateBGPv4PeerGroup(peerGroup *bgpd.BGPv4PeerGroup) (gro
 			MaxPrefixesRestartTimer: uint8(peerGroup.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          peerGroup.AdjRIBInFilter,
 			AdjRIBOutFilter:         peerGroup.AdjRIBOutFilter,
+			AllowAsIn:               uint8(peerGroup.AllowAsIn),
+			RemovePrivateAS:         peerGroup.RemovePrivateAS,
+			LocalASNoPrepend:        peerGroup.LocalASNoPrepend,
+			LocalASReplaceAS:        peerGroup.LocalASReplaceAS,
+			AsOverride:              peerGroup.AsOverride,
+			DefaultOriginate:        peerGroup.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(peerGroup.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(peerGroup.AdvertisePrefixes, peerGroup.AdvertiseConditionPrefix,
+				peerGroup.AdvertiseNonExist),
+			LabeledUnicast: peerGroup.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(peerGroup.AddPaths),
+			Dampening: h.convertToDampeningConfig(peerGroup.Dampening, peerGroup.DampeningHalfLife,
+				peerGroup.DampeningReuseLimit, peerGroup.DampeningSuppressLimit, peerGroup.DampeningMaxSuppressTime),
 		},
 		Name: peerGroup.Name,
 	}
 
+	if err = h.validateASPathConfig(group.BaseConfig); err != nil {
+		return group, err
+	}
+	if err = h.validateAdvConditionConfig(group.BaseConfig); err != nil {
+		return group, err
+	}
+	if err = h.validateAddPathsConfig(group.BaseConfig); err != nil {
+		return group, err
+	}
+	err = h.validateDampeningConfig(group.Dampening)
 	return group, err
 }
 
 func (h *BGPHandler) SendBGPv4PeerGroup(oldGroup *bgpd.BGPv4PeerGroup, newGroup *bgpd.BGPv4PeerGroup, attrSet []bool) (
 	bool, error) {
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(newGroup.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1475,7 +2200,7 @@ func (h *BGPHandler) UpdateBGPv4PeerGroup(origG *bgpd.BGPv4PeerGroup, updatedG *
 
 func (h *BGPHandler) DeleteBGPv4PeerGroup(peerGroup *bgpd.BGPv4PeerGroup) (bool, error) {
 	h.logger.Info("Delete BGP v4 peer group:%+v", peerGroup.Name)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(peerGroup.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1509,6 +2234,7 @@ func (h *BGPHandler) ValidateBGPv6PeerGroup(peerGroup *bgpd.BGPv6PeerGroup) (gro
 	}
 
 	group = config.PeerGroupConfig{
+		Vrf: peerGroup.Vrf,
 		BaseConfig: config.BaseConfig{
 			PeerAS:                  uint32(peerAS),
 			LocalAS:                 uint32(localAS),
@@ -1531,16 +2257,39 @@ func (h *BGPHandler) ValidateBGPv6PeerGroup(peerGroup *bgpd.BGPv6PeerGroup) (gro
 			MaxPrefixesRestartTimer: uint8(peerGroup.MaxPrefixesRestartTimer),
 			AdjRIBInFilter:          peerGroup.AdjRIBInFilter,
 			AdjRIBOutFilter:         peerGroup.AdjRIBOutFilter,
+			AllowAsIn:               uint8(peerGroup.AllowAsIn),
+			RemovePrivateAS:         peerGroup.RemovePrivateAS,
+			LocalASNoPrepend:        peerGroup.LocalASNoPrepend,
+			LocalASReplaceAS:        peerGroup.LocalASReplaceAS,
+			AsOverride:              peerGroup.AsOverride,
+			DefaultOriginate:        peerGroup.DefaultOriginate,
+			DefaultOriginateCond:    h.normalizePrefix(peerGroup.DefaultOriginateCondition),
+			ConditionalAdv: h.convertToConditionalAdvConfig(peerGroup.AdvertisePrefixes, peerGroup.AdvertiseConditionPrefix,
+				peerGroup.AdvertiseNonExist),
+			LabeledUnicast: peerGroup.LabeledUnicast,
+			AddPaths:       h.convertToAddPathsConfig(peerGroup.AddPaths),
+			Dampening: h.convertToDampeningConfig(peerGroup.Dampening, peerGroup.DampeningHalfLife,
+				peerGroup.DampeningReuseLimit, peerGroup.DampeningSuppressLimit, peerGroup.DampeningMaxSuppressTime),
 		},
 		Name: peerGroup.Name,
 	}
 
+	if err = h.validateASPathConfig(group.BaseConfig); err != nil {
+		return group, err
+	}
+	if err = h.validateAdvConditionConfig(group.BaseConfig); err != nil {
+		return group, err
+	}
+	if err = h.validateAddPathsConfig(group.BaseConfig); err != nil {
+		return group, err
+	}
+	err = h.validateDampeningConfig(group.Dampening)
 	return group, err
 }
 
 func (h *BGPHandler) SendBGPv6PeerGroup(oldGroup *bgpd.BGPv6PeerGroup, newGroup *bgpd.BGPv6PeerGroup, attrSet []bool) (
 	bool, error) {
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(newGroup.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1571,7 +2320,7 @@ func (h *BGPHandler) UpdateBGPv6PeerGroup(origG *bgpd.BGPv6PeerGroup, updatedG *
 
 func (h *BGPHandler) DeleteBGPv6PeerGroup(peerGroup *bgpd.BGPv6PeerGroup) (bool, error) {
 	h.logger.Info("Delete BGP v6 peer group:", peerGroup.Name)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(peerGroup.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1583,17 +2332,18 @@ func (h *BGPHandler) DeleteBGPv6PeerGroup(peerGroup *bgpd.BGPv6PeerGroup) (bool,
 	return true, nil
 }
 
-func (h *BGPHandler) GetBGPv4RouteState(network string, cidrLen int16) (*bgpd.BGPv4RouteState, error) {
-	bgpRoute := h.server.LocRib.GetBGPv4Route(network)
+func (h *BGPHandler) GetBGPv4RouteState(vrf string, network string, cidrLen int16) (*bgpd.BGPv4RouteState,
+	error) {
+	bgpRoute := h.server.GetBGPv4Route(vrf, network)
 	var err error = nil
 	if bgpRoute == nil {
-		err = errors.New(fmt.Sprintf("Route not found for destination %s", network))
+		err = errors.New(fmt.Sprintf("Route not found for destination %s in VRF %s", network, vrf))
 	}
 	return bgpRoute, err
 }
 
 func (h *BGPHandler) GetBulkBGPv4RouteState(index bgpd.Int, count bgpd.Int) (*bgpd.BGPv4RouteStateGetInfo, error) {
-	nextIdx, currCount, bgpRoutes := h.server.LocRib.BulkGetBGPv4Routes(int(index), int(count))
+	nextIdx, currCount, bgpRoutes := h.server.BulkGetBGPv4Routes(int(index), int(count))
 
 	bgpRoutesBulk := bgpd.NewBGPv4RouteStateGetInfo()
 	bgpRoutesBulk.EndIdx = bgpd.Int(nextIdx)
@@ -1604,17 +2354,18 @@ func (h *BGPHandler) GetBulkBGPv4RouteState(index bgpd.Int, count bgpd.Int) (*bg
 	return bgpRoutesBulk, nil
 }
 
-func (h *BGPHandler) GetBGPv6RouteState(network string, cidrLen int16) (*bgpd.BGPv6RouteState, error) {
-	bgpRoute := h.server.LocRib.GetBGPv6Route(network)
+func (h *BGPHandler) GetBGPv6RouteState(vrf string, network string, cidrLen int16) (*bgpd.BGPv6RouteState,
+	error) {
+	bgpRoute := h.server.GetBGPv6Route(vrf, network)
 	var err error = nil
 	if bgpRoute == nil {
-		err = errors.New(fmt.Sprintf("Route not found for destination %s", network))
+		err = errors.New(fmt.Sprintf("Route not found for destination %s in VRF %s", network, vrf))
 	}
 	return bgpRoute, err
 }
 
 func (h *BGPHandler) GetBulkBGPv6RouteState(index bgpd.Int, count bgpd.Int) (*bgpd.BGPv6RouteStateGetInfo, error) {
-	nextIdx, currCount, bgpRoutes := h.server.LocRib.BulkGetBGPv6Routes(int(index), int(count))
+	nextIdx, currCount, bgpRoutes := h.server.BulkGetBGPv6Routes(int(index), int(count))
 
 	bgpRoutesBulk := bgpd.NewBGPv6RouteStateGetInfo()
 	bgpRoutesBulk.EndIdx = bgpd.Int(nextIdx)
@@ -1648,6 +2399,28 @@ func (h *BGPHandler) CreateBGPPolicyCondition(cfg *bgpd.BGPPolicyCondition) (val
 		val = true
 		h.bgpPolicyMgr.ConditionCfgCh <- *policyCfg
 		break
+	case bgppolicy.ConditionTypeMatchCommunity:
+		commCond, err := bgppolicy.NewCommunityCondition(cfg.Name, cfg.CommunityMatchType, cfg.Communities)
+		if err != nil {
+			h.logger.Err("Create community condition", cfg.Name, "failed with error", err)
+			return false, err
+		}
+		h.bgpPolicyMgr.CommunityDB.AddCondition(commCond)
+		policyCfg := convertThriftToPolicyConditionConfig(cfg)
+		val = true
+		h.bgpPolicyMgr.ConditionCfgCh <- *policyCfg
+		break
+	case bgppolicy.ConditionTypeMatchValidationState:
+		validationCond, err := bgppolicy.NewValidationStateCondition(cfg.Name, cfg.ValidationState)
+		if err != nil {
+			h.logger.Err("Create validation state condition", cfg.Name, "failed with error", err)
+			return false, err
+		}
+		h.bgpPolicyMgr.ValidationDB.AddCondition(validationCond)
+		policyCfg := convertThriftToPolicyConditionConfig(cfg)
+		val = true
+		h.bgpPolicyMgr.ConditionCfgCh <- *policyCfg
+		break
 	default:
 		h.logger.Info("Unknown condition type ", cfg.ConditionType)
 		err = errors.New(fmt.Sprintf("Unknown condition type %s", cfg.ConditionType))
@@ -1694,6 +2467,17 @@ func (h *BGPHandler) CreateBGPPolicyAction(cfg *bgpd.BGPPolicyAction) (val bool,
 		val = true
 		h.bgpPolicyMgr.ActionCfgCh <- *actionCfg
 		break
+	case bgppolicy.ActionTypeSetCommunity, bgppolicy.ActionTypeAddCommunity, bgppolicy.ActionTypeDeleteCommunity:
+		commAction, err := bgppolicy.NewCommunityAction(cfg.Name, cfg.ActionType, cfg.Communities)
+		if err != nil {
+			h.logger.Err("Create community action", cfg.Name, "failed with error", err)
+			return false, err
+		}
+		h.bgpPolicyMgr.CommunityDB.AddAction(commAction)
+		actionCfg := convertThriftToPolicyActionConfig(cfg)
+		val = true
+		h.bgpPolicyMgr.ActionCfgCh <- *actionCfg
+		break
 	default:
 		h.logger.Info("Unknown action type ", cfg.ActionType)
 		err = errors.New(fmt.Sprintf("Unknown action type %s", cfg.ActionType))
@@ -1831,6 +2615,7 @@ func (h *BGPHandler) validateBGPAggregate(bgpAgg *bgpd.BGPv4Aggregate) (aggConf
 	}
 
 	aggConf = config.BGPAggregate{
+		Vrf:             bgpAgg.Vrf,
 		IPPrefix:        bgpAgg.IpPrefix,
 		GenerateASSet:   bgpAgg.GenerateASSet,
 		SendSummaryOnly: bgpAgg.SendSummaryOnly,
@@ -1841,7 +2626,7 @@ func (h *BGPHandler) validateBGPAggregate(bgpAgg *bgpd.BGPv4Aggregate) (aggConf
 
 func (h *BGPHandler) SendBGPAggregate(oldConfig *bgpd.BGPv4Aggregate, newConfig *bgpd.BGPv4Aggregate, attrSet []bool) (
 	bool, error) {
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(newConfig.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1872,7 +2657,7 @@ func (h *BGPHandler) UpdateBGPv4Aggregate(origA *bgpd.BGPv4Aggregate, updatedA *
 
 func (h *BGPHandler) DeleteBGPv4Aggregate(bgpAgg *bgpd.BGPv4Aggregate) (bool, error) {
 	h.logger.Info("Delete BGP v4 aggregate:", bgpAgg)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(bgpAgg.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1901,6 +2686,7 @@ func (h *BGPHandler) validateBGPv6Aggregate(bgpAgg *bgpd.BGPv6Aggregate) (aggCon
 	}
 
 	aggConf = config.BGPAggregate{
+		Vrf:             bgpAgg.Vrf,
 		IPPrefix:        bgpAgg.IpPrefix,
 		GenerateASSet:   bgpAgg.GenerateASSet,
 		SendSummaryOnly: bgpAgg.SendSummaryOnly,
@@ -1911,7 +2697,7 @@ func (h *BGPHandler) validateBGPv6Aggregate(bgpAgg *bgpd.BGPv6Aggregate) (aggCon
 
 func (h *BGPHandler) SendBGPv6Aggregate(oldConfig *bgpd.BGPv6Aggregate, newConfig *bgpd.BGPv6Aggregate,
 	attrSet []bool) (bool, error) {
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(newConfig.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1942,7 +2728,7 @@ func (h *BGPHandler) UpdateBGPv6Aggregate(origA *bgpd.BGPv6Aggregate, updatedA *
 
 func (h *BGPHandler) DeleteBGPv6Aggregate(bgpAgg *bgpd.BGPv6Aggregate) (bool, error) {
 	h.logger.Info("Delete BGP IPv6 aggregate:", bgpAgg)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(bgpAgg.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1951,9 +2737,276 @@ func (h *BGPHandler) DeleteBGPv6Aggregate(bgpAgg *bgpd.BGPv6Aggregate) (bool, er
 	return true, nil
 }
 
+func (h *BGPHandler) validateBGPBmpCollector(bmpCol *bgpd.BGPBmpCollector) (colConf config.BMPCollector, err error) {
+	if bmpCol == nil {
+		return colConf, err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(bmpCol.Address))
+	if ip == nil {
+		err = errors.New(fmt.Sprintf("BGPBmpCollector: Address %s is not a valid IP", bmpCol.Address))
+		h.logger.Info("SendBGPBmpCollector: Address", bmpCol.Address, "is not a valid IP")
+		return colConf, err
+	}
+
+	if bmpCol.Port <= 0 || bmpCol.Port > 65535 {
+		err = errors.New(fmt.Sprintf("BGPBmpCollector: Port %d is not valid", bmpCol.Port))
+		h.logger.Info("SendBGPBmpCollector: Port", bmpCol.Port, "is not valid")
+		return colConf, err
+	}
+
+	policy := bmpCol.RouteMonitoringPolicy
+	if policy == "" {
+		policy = config.BMPRouteMonitoringPrePolicy
+	}
+	if policy != config.BMPRouteMonitoringPrePolicy && policy != config.BMPRouteMonitoringPostPolicy &&
+		policy != config.BMPRouteMonitoringAll {
+		err = errors.New(fmt.Sprintf("BGPBmpCollector: Route monitoring policy %s is not valid, expected %s, %s or %s",
+			policy, config.BMPRouteMonitoringPrePolicy, config.BMPRouteMonitoringPostPolicy,
+			config.BMPRouteMonitoringAll))
+		h.logger.Info("SendBGPBmpCollector: Route monitoring policy", policy, "is not valid")
+		return colConf, err
+	}
+
+	if bmpCol.StatsInterval < 0 {
+		err = errors.New(fmt.Sprintf("BGPBmpCollector: Stats interval %d is not valid", bmpCol.StatsInterval))
+		h.logger.Info("SendBGPBmpCollector: Stats interval", bmpCol.StatsInterval, "is not valid")
+		return colConf, err
+	}
+
+	colConf = config.BMPCollector{
+		Name:                  bmpCol.Name,
+		Address:               ip,
+		Port:                  uint16(bmpCol.Port),
+		RouteMonitoringPolicy: policy,
+		StatsInterval:         uint32(bmpCol.StatsInterval),
+	}
+	return colConf, nil
+}
+
+func (h *BGPHandler) SendBGPBmpCollector(oldConfig *bgpd.BGPBmpCollector, newConfig *bgpd.BGPBmpCollector,
+	attrSet []bool) (bool, error) {
+	oldCol, err := h.validateBGPBmpCollector(oldConfig)
+	if err != nil {
+		return false, err
+	}
+
+	newCol, err := h.validateBGPBmpCollector(newConfig)
+	if err != nil {
+		return false, err
+	}
+
+	h.server.AddBMPColCh <- server.BMPCollectorUpdate{oldCol, newCol, attrSet}
+	return true, err
+}
+
+func (h *BGPHandler) CreateBGPBmpCollector(bmpCol *bgpd.BGPBmpCollector) (bool, error) {
+	h.logger.Info("Create BGP BMP collector:", bmpCol)
+	return h.SendBGPBmpCollector(nil, bmpCol, make([]bool, 0))
+}
+
+func (h *BGPHandler) UpdateBGPBmpCollector(origC *bgpd.BGPBmpCollector, updatedC *bgpd.BGPBmpCollector,
+	attrSet []bool, op []*bgpd.PatchOpInfo) (bool, error) {
+	h.logger.Info("Update BGP BMP collector:", updatedC, "old:", origC)
+	return h.SendBGPBmpCollector(origC, updatedC, attrSet)
+}
+
+func (h *BGPHandler) DeleteBGPBmpCollector(bmpCol *bgpd.BGPBmpCollector) (bool, error) {
+	h.logger.Info("Delete BGP BMP collector:", bmpCol)
+	h.server.RemBMPColCh <- config.BMPCollector{Name: bmpCol.Name}
+	return true, nil
+}
+
+func (h *BGPHandler) validateBGPMrt(bgpMrt *bgpd.BGPMrt) (mrtConf config.MRTConfig, err error) {
+	if bgpMrt.Directory != "" && !filepath.IsAbs(bgpMrt.Directory) {
+		err = errors.New(fmt.Sprintf("BGPMrt: Directory %s is not an absolute path", bgpMrt.Directory))
+		h.logger.Info("SendBGPMrt: Directory", bgpMrt.Directory, "is not an absolute path")
+		return mrtConf, err
+	}
+
+	if bgpMrt.RibDumpInterval < 0 {
+		err = errors.New(fmt.Sprintf("BGPMrt: RIB dump interval %d is not valid", bgpMrt.RibDumpInterval))
+		h.logger.Info("SendBGPMrt: RIB dump interval", bgpMrt.RibDumpInterval, "is not valid")
+		return mrtConf, err
+	}
+
+	if bgpMrt.RotateInterval < 0 {
+		err = errors.New(fmt.Sprintf("BGPMrt: Rotate interval %d is not valid", bgpMrt.RotateInterval))
+		h.logger.Info("SendBGPMrt: Rotate interval", bgpMrt.RotateInterval, "is not valid")
+		return mrtConf, err
+	}
+
+	mrtConf = config.MRTConfig{
+		Directory:       bgpMrt.Directory,
+		RIBDumpInterval: uint32(bgpMrt.RibDumpInterval),
+		RecordUpdates:   bgpMrt.RecordUpdates,
+		RotateInterval:  uint32(bgpMrt.RotateInterval),
+	}
+	return mrtConf, nil
+}
+
+func (h *BGPHandler) SendBGPMrt(bgpMrt *bgpd.BGPMrt) (bool, error) {
+	mrtConf, err := h.validateBGPMrt(bgpMrt)
+	if err != nil {
+		return false, err
+	}
+
+	h.server.MRTConfigCh <- mrtConf
+	return true, nil
+}
+
+func (h *BGPHandler) CreateBGPMrt(bgpMrt *bgpd.BGPMrt) (bool, error) {
+	h.logger.Info("Create BGP MRT:", bgpMrt)
+	return h.SendBGPMrt(bgpMrt)
+}
+
+func (h *BGPHandler) UpdateBGPMrt(origM *bgpd.BGPMrt, updatedM *bgpd.BGPMrt, attrSet []bool,
+	op []*bgpd.PatchOpInfo) (bool, error) {
+	h.logger.Info("Update BGP MRT:", updatedM, "old:", origM)
+	return h.SendBGPMrt(updatedM)
+}
+
+func (h *BGPHandler) DeleteBGPMrt(bgpMrt *bgpd.BGPMrt) (bool, error) {
+	h.logger.Info("Delete BGP MRT:", bgpMrt)
+	h.server.MRTConfigCh <- config.MRTConfig{}
+	return true, nil
+}
+
+func (h *BGPHandler) validateBGPRpki(bgpRpki *bgpd.BGPRpki) (cacheConf config.RPKICacheServer, err error) {
+	ip := h.convertStrIPToNetIP(bgpRpki.CacheAddress)
+	if ip == nil {
+		err = errors.New(fmt.Sprintf("BGPRpki: Cache address %s is not valid", bgpRpki.CacheAddress))
+		h.logger.Info("SendBGPRpki: Cache address", bgpRpki.CacheAddress, "is not valid")
+		return cacheConf, err
+	}
+
+	if bgpRpki.CachePort < 0 || bgpRpki.CachePort > math.MaxUint16 {
+		err = errors.New(fmt.Sprintf("BGPRpki: Cache port %d is not valid", bgpRpki.CachePort))
+		h.logger.Info("SendBGPRpki: Cache port", bgpRpki.CachePort, "is not valid")
+		return cacheConf, err
+	}
+
+	if bgpRpki.RefreshInterval < 0 || bgpRpki.RetryInterval < 0 || bgpRpki.ExpireInterval < 0 {
+		err = errors.New(fmt.Sprintf("BGPRpki: Refresh interval %d, retry interval %d or expire interval %d "+
+			"is not valid", bgpRpki.RefreshInterval, bgpRpki.RetryInterval, bgpRpki.ExpireInterval))
+		h.logger.Info("SendBGPRpki: Refresh interval", bgpRpki.RefreshInterval, "retry interval",
+			bgpRpki.RetryInterval, "or expire interval", bgpRpki.ExpireInterval, "is not valid")
+		return cacheConf, err
+	}
+
+	cacheConf = config.RPKICacheServer{
+		Address:         ip,
+		Port:            uint16(bgpRpki.CachePort),
+		RefreshInterval: uint32(bgpRpki.RefreshInterval),
+		RetryInterval:   uint32(bgpRpki.RetryInterval),
+		ExpireInterval:  uint32(bgpRpki.ExpireInterval),
+	}
+	return cacheConf, nil
+}
+
+func (h *BGPHandler) SendBGPRpki(bgpRpki *bgpd.BGPRpki) (bool, error) {
+	cacheConf, err := h.validateBGPRpki(bgpRpki)
+	if err != nil {
+		return false, err
+	}
+
+	h.server.RPKICacheCh <- cacheConf
+	return true, nil
+}
+
+func (h *BGPHandler) CreateBGPRpki(bgpRpki *bgpd.BGPRpki) (bool, error) {
+	h.logger.Info("Create BGP RPKI:", bgpRpki)
+	return h.SendBGPRpki(bgpRpki)
+}
+
+func (h *BGPHandler) UpdateBGPRpki(origR *bgpd.BGPRpki, updatedR *bgpd.BGPRpki, attrSet []bool,
+	op []*bgpd.PatchOpInfo) (bool, error) {
+	h.logger.Info("Update BGP RPKI:", updatedR, "old:", origR)
+	return h.SendBGPRpki(updatedR)
+}
+
+func (h *BGPHandler) DeleteBGPRpki(bgpRpki *bgpd.BGPRpki) (bool, error) {
+	h.logger.Info("Delete BGP RPKI:", bgpRpki)
+	h.server.RPKICacheCh <- config.RPKICacheServer{}
+	return true, nil
+}
+
+func (h *BGPHandler) validateBGPListenRange(listenRange *bgpd.BGPListenRange) (rangeConf config.ListenRange,
+	err error) {
+	_, prefix, err := net.ParseCIDR(strings.TrimSpace(listenRange.Prefix))
+	if err != nil {
+		err = errors.New(fmt.Sprintf("BGPListenRange: Prefix %s is not valid", listenRange.Prefix))
+		h.logger.Info("SendBGPListenRange: Prefix", listenRange.Prefix, "is not valid")
+		return rangeConf, err
+	}
+
+	if listenRange.MaxPeers < 0 {
+		err = errors.New(fmt.Sprintf("BGPListenRange: Max peers %d is not valid", listenRange.MaxPeers))
+		h.logger.Info("SendBGPListenRange: Max peers", listenRange.MaxPeers, "is not valid")
+		return rangeConf, err
+	}
+
+	rangeConf = config.ListenRange{
+		Vrf:       listenRange.Vrf,
+		Prefix:    prefix,
+		PeerGroup: listenRange.PeerGroup,
+		AllowedAS: make([]uint32, 0, len(listenRange.AllowedAS)),
+		MaxPeers:  uint32(listenRange.MaxPeers),
+	}
+	for _, as := range listenRange.AllowedAS {
+		if as <= 0 {
+			err = errors.New(fmt.Sprintf("BGPListenRange: Allowed AS %d is not valid", as))
+			h.logger.Info("SendBGPListenRange: Allowed AS", as, "is not valid")
+			return rangeConf, err
+		}
+		rangeConf.AllowedAS = append(rangeConf.AllowedAS, uint32(as))
+	}
+	return rangeConf, nil
+}
+
+func (h *BGPHandler) SendBGPListenRange(listenRange *bgpd.BGPListenRange) (bool, error) {
+	if err := h.checkBGPGlobal(listenRange.Vrf); err != nil {
+		return false, err
+	}
+
+	rangeConf, err := h.validateBGPListenRange(listenRange)
+	if err != nil {
+		return false, err
+	}
+
+	h.server.AddRangeCh <- rangeConf
+	return true, nil
+}
+
+func (h *BGPHandler) CreateBGPListenRange(listenRange *bgpd.BGPListenRange) (bool, error) {
+	h.logger.Info("Create BGP listen range:", listenRange)
+	return h.SendBGPListenRange(listenRange)
+}
+
+func (h *BGPHandler) UpdateBGPListenRange(origR *bgpd.BGPListenRange, updatedR *bgpd.BGPListenRange,
+	attrSet []bool, op []*bgpd.PatchOpInfo) (bool, error) {
+	h.logger.Info("Update BGP listen range:", updatedR, "old:", origR)
+	return h.SendBGPListenRange(updatedR)
+}
+
+func (h *BGPHandler) DeleteBGPListenRange(listenRange *bgpd.BGPListenRange) (bool, error) {
+	h.logger.Info("Delete BGP listen range:", listenRange)
+	if err := h.checkBGPGlobal(listenRange.Vrf); err != nil {
+		return false, err
+	}
+
+	rangeConf, err := h.validateBGPListenRange(listenRange)
+	if err != nil {
+		return false, err
+	}
+
+	h.server.RemRangeCh <- rangeConf
+	return true, nil
+}
+
 func (h *BGPHandler) ExecuteActionResetBGPv4NeighborByIPAddr(resetIP *bgpd.ResetBGPv4NeighborByIPAddr) (bool, error) {
 	h.logger.Info("Reset BGP v4 neighbor by IP address", resetIP.IPAddr)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(resetIP.Vrf); err != nil {
 		return false, err
 	}
 
@@ -1961,14 +3014,53 @@ func (h *BGPHandler) ExecuteActionResetBGPv4NeighborByIPAddr(resetIP *bgpd.Reset
 	if ip == nil {
 		return false, errors.New(fmt.Sprintf("IPv4 Neighbor address %s is not a valid IP", resetIP.IPAddr))
 	}
-	h.server.PeerCommandCh <- config.PeerCommand{IP: ip, Command: int(fsm.BGPEventManualStop)}
+	h.server.PeerCommandCh <- config.PeerCommand{Vrf: resetIP.Vrf, IP: ip, Command: int(fsm.BGPEventManualStop)}
+	return true, nil
+}
+
+func (h *BGPHandler) ExecuteActionDumpBGPRib(dumpRib *bgpd.DumpBGPRib) (bool, error) {
+	h.logger.Info("Dump BGP RIB for VRF", dumpRib.Vrf)
+	if dumpRib.Vrf != "" {
+		if err := h.checkBGPGlobal(dumpRib.Vrf); err != nil {
+			return false, err
+		}
+	}
+
+	h.server.MRTDumpCh <- config.MRTDumpCommand{Vrf: dumpRib.Vrf}
+	return true, nil
+}
+
+func (h *BGPHandler) ExecuteActionSoftResetBGPNeighbor(softReset *bgpd.SoftResetBGPNeighbor) (bool, error) {
+	h.logger.Info("Soft reset BGP neighbor", softReset.IPAddr, "direction", softReset.Direction)
+	if err := h.checkBGPGlobal(softReset.Vrf); err != nil {
+		return false, err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(softReset.IPAddr))
+	if ip == nil {
+		return false, errors.New(fmt.Sprintf("Neighbor address %s is not a valid IP", softReset.IPAddr))
+	}
+
+	var dir config.SoftResetDir
+	switch strings.ToLower(strings.TrimSpace(softReset.Direction)) {
+	case "in":
+		dir = config.SoftResetDirIn
+	case "out":
+		dir = config.SoftResetDirOut
+	case "", "both":
+		dir = config.SoftResetDirBoth
+	default:
+		return false, errors.New(fmt.Sprintf("Soft reset direction %s is not valid, must be in, out or both",
+			softReset.Direction))
+	}
+	h.server.SoftResetCh <- config.SoftResetCommand{Vrf: softReset.Vrf, IP: ip, Direction: dir}
 	return true, nil
 }
 
 func (h *BGPHandler) ExecuteActionResetBGPv4NeighborByInterface(resetIf *bgpd.ResetBGPv4NeighborByInterface) (bool,
 	error) {
 	h.logger.Info("Reset BGP v4 neighbor by interface", resetIf.IntfRef)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(resetIf.Vrf); err != nil {
 		return false, err
 	}
 
@@ -2002,13 +3094,13 @@ func (h *BGPHandler) ExecuteActionResetBGPv4NeighborByInterface(resetIf *bgpd.Re
 	h.logger.Info("IPv4Addr of the v4Neighbor remote interface is", ifIP)
 	ip := ifIP
 
-	h.server.PeerCommandCh <- config.PeerCommand{IP: ip, Command: int(fsm.BGPEventManualStop)}
+	h.server.PeerCommandCh <- config.PeerCommand{Vrf: resetIf.Vrf, IP: ip, Command: int(fsm.BGPEventManualStop)}
 	return true, nil
 }
 
 func (h *BGPHandler) ExecuteActionResetBGPv6NeighborByIPAddr(resetIP *bgpd.ResetBGPv6NeighborByIPAddr) (bool, error) {
 	h.logger.Info("Reset BGP v6 neighbor by IP address", resetIP.IPAddr)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(resetIP.Vrf); err != nil {
 		return false, err
 	}
 
@@ -2016,14 +3108,14 @@ func (h *BGPHandler) ExecuteActionResetBGPv6NeighborByIPAddr(resetIP *bgpd.Reset
 	if ip == nil {
 		return false, errors.New(fmt.Sprintf("IPv6 Neighbor address %s is not a valid IP", resetIP.IPAddr))
 	}
-	h.server.PeerCommandCh <- config.PeerCommand{IP: ip, Command: int(fsm.BGPEventManualStop)}
+	h.server.PeerCommandCh <- config.PeerCommand{Vrf: resetIP.Vrf, IP: ip, Command: int(fsm.BGPEventManualStop)}
 	return true, nil
 }
 
 func (h *BGPHandler) ExecuteActionResetBGPv6NeighborByInterface(resetIf *bgpd.ResetBGPv6NeighborByInterface) (bool,
 	error) {
 	h.logger.Info("Reset BGP v6 neighbor by interface", resetIf.IntfRef)
-	if err := h.checkBGPGlobal(); err != nil {
+	if err := h.checkBGPGlobal(resetIf.Vrf); err != nil {
 		return false, err
 	}
 
@@ -2049,6 +3141,6 @@ func (h *BGPHandler) ExecuteActionResetBGPv6NeighborByInterface(resetIf *bgpd.Re
 		return false, errors.New(fmt.Sprintf("IPv6 Neighbor address %s for interface %s is not a valid IP",
 			ipInfo.LinklocalIpAddr, resetIf.IntfRef))
 	}
-	h.server.PeerCommandCh <- config.PeerCommand{IP: ip, Command: int(fsm.BGPEventManualStop)}
+	h.server.PeerCommandCh <- config.PeerCommand{Vrf: resetIf.Vrf, IP: ip, Command: int(fsm.BGPEventManualStop)}
 	return true, nil
 }
@@ -0,0 +1,381 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// client.go
+package rpki
+
+import (
+	"l3/bgp/config"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"utils/logging"
+)
+
+const (
+	RTRConnectTimeout = 10 * time.Second
+)
+
+type cacheSession struct {
+	config          config.RPKICacheServer
+	version         uint8
+	sessionId       uint16
+	serial          uint32
+	hasData         bool
+	refreshInterval time.Duration
+	retryInterval   time.Duration
+	expireTimer     *time.Timer
+	stopCh          chan bool
+}
+
+type cacheResponse struct {
+	reset     bool
+	started   bool
+	announced []ROA
+	withdrawn []ROA
+}
+
+// Client maintains the ROA table with the ROAs fetched from a RPKI cache using the RPKI-Router protocol,
+// RFC 8210. The client falls back to version 0 of the protocol, RFC 6810, if the cache does not support
+// version 1. A message is sent on UpdateCh every time the ROA table changes.
+type Client struct {
+	logger   *logging.Writer
+	Table    *ROATable
+	UpdateCh chan bool
+	mutex    sync.Mutex
+	session  *cacheSession
+}
+
+func NewClient(logger *logging.Writer) *Client {
+	return &Client{
+		logger:   logger,
+		Table:    NewROATable(),
+		UpdateCh: make(chan bool, 1),
+	}
+}
+
+func (c *Client) IsRunning() bool {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	return c.session != nil
+}
+
+// Start connects to the cache in conf, the client is restarted if it is already connected to a cache.
+func (c *Client) Start(conf config.RPKICacheServer) {
+	c.Stop()
+	if conf.RefreshInterval == 0 {
+		conf.RefreshInterval = config.RPKIRefreshIntervalDefault
+	}
+	if conf.RetryInterval == 0 {
+		conf.RetryInterval = config.RPKIRetryIntervalDefault
+	}
+	if conf.ExpireInterval == 0 {
+		conf.ExpireInterval = config.RPKIExpireIntervalDefault
+	}
+
+	session := &cacheSession{
+		config:          conf,
+		version:         RTRVersion1,
+		refreshInterval: time.Duration(conf.RefreshInterval) * time.Second,
+		retryInterval:   time.Duration(conf.RetryInterval) * time.Second,
+		expireTimer:     time.NewTimer(time.Duration(conf.ExpireInterval) * time.Second),
+		stopCh:          make(chan bool),
+	}
+	session.expireTimer.Stop()
+
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	c.session = session
+	go c.runSession(session)
+}
+
+// Stop disconnects from the cache and removes all the ROAs.
+func (c *Client) Stop() {
+	c.mutex.Lock()
+	session := c.session
+	c.session = nil
+	if session != nil {
+		close(session.stopCh)
+		session.expireTimer.Stop()
+	}
+	c.mutex.Unlock()
+
+	if c.Table.Clear() {
+		c.notify()
+	}
+}
+
+func (c *Client) Validate(prefix net.IP, prefixLen uint8, originAS uint32) ValidationState {
+	return c.Table.Validate(prefix, prefixLen, originAS)
+}
+
+func (c *Client) notify() {
+	select {
+	case c.UpdateCh <- true:
+	default:
+	}
+}
+
+// applyResponse updates the ROA table with the response of the cache. The response is dropped if the
+// session is stopped so that the ROAs are not added back after the client is stopped.
+func (c *Client) applyResponse(session *cacheSession, resp *cacheResponse) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	if c.session != session {
+		return
+	}
+
+	changed, errs := c.Table.Update(resp.announced, resp.withdrawn, resp.reset)
+	for _, err := range errs {
+		c.logger.Err("RPKI cache", session.config.Address, "update error:", err)
+	}
+	c.logger.Info("RPKI cache", session.config.Address, "serial", session.serial, "announced",
+		len(resp.announced), "withdrawn", len(resp.withdrawn), "ROAs, total ROAs", c.Table.Len())
+	if changed {
+		c.notify()
+	}
+}
+
+func (c *Client) expireData(session *cacheSession) {
+	c.logger.Err("RPKI cache", session.config.Address, "data expired, removing all the ROAs")
+	session.hasData = false
+	c.applyResponse(session, &cacheResponse{reset: true})
+}
+
+func (c *Client) runSession(session *cacheSession) {
+	addr := net.JoinHostPort(session.config.Address.String(), strconv.Itoa(int(session.config.Port)))
+	for {
+		version := session.version
+		c.logger.Info("RPKI cache connecting to", addr, "version", version)
+		conn, err := net.DialTimeout("tcp", addr, RTRConnectTimeout)
+		if err != nil {
+			c.logger.Err("RPKI cache failed to connect to", addr, "with error", err)
+		} else {
+			c.logger.Info("RPKI cache connected to", addr)
+			stopped := c.serveSession(session, conn)
+			conn.Close()
+			if stopped {
+				return
+			}
+			if session.version < version {
+				continue
+			}
+		}
+
+		retryTimer := time.NewTimer(session.retryInterval)
+		for waiting := true; waiting; {
+			select {
+			case <-session.stopCh:
+				retryTimer.Stop()
+				return
+
+			case <-session.expireTimer.C:
+				c.expireData(session)
+
+			case <-retryTimer.C:
+				waiting = false
+			}
+		}
+	}
+}
+
+func (c *Client) sendPDU(session *cacheSession, conn net.Conn, pdu *PDU) bool {
+	pkt, err := pdu.Encode()
+	if err == nil {
+		_, err = conn.Write(pkt)
+	}
+	if err != nil {
+		c.logger.Err("RPKI cache", session.config.Address, "failed to send", pdu, "with error", err)
+		return false
+	}
+	return true
+}
+
+// sendQuery sends a Serial Query to get the changes since the last response of the cache, or a Reset Query
+// to get all the ROAs if the client does not have the data of the session.
+func (c *Client) sendQuery(session *cacheSession, conn net.Conn, resp *cacheResponse) bool {
+	resp.reset = !session.hasData
+	if resp.reset {
+		return c.sendPDU(session, conn, NewResetQuery(session.version))
+	}
+	return c.sendPDU(session, conn, NewSerialQuery(session.version, session.sessionId, session.serial))
+}
+
+// serveSession processes the PDUs of the cache until the connection fails or the client is stopped. It
+// returns true if the client is stopped.
+func (c *Client) serveSession(session *cacheSession, conn net.Conn) bool {
+	type readResult struct {
+		pdu *PDU
+		pkt []byte
+		err error
+	}
+
+	readCh := make(chan readResult)
+	closedCh := make(chan bool)
+	defer close(closedCh)
+	go func() {
+		for {
+			pdu, pkt, err := ReadPDU(conn)
+			select {
+			case readCh <- readResult{pdu, pkt, err}:
+			case <-closedCh:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	resp := &cacheResponse{}
+	if !c.sendQuery(session, conn, resp) {
+		return false
+	}
+
+	refreshTimer := time.NewTimer(session.refreshInterval)
+	defer refreshTimer.Stop()
+	for {
+		select {
+		case result := <-readCh:
+			if result.err != nil {
+				c.logger.Err("RPKI cache", session.config.Address, "read failed with error", result.err)
+				if result.pkt != nil {
+					c.sendPDU(session, conn, NewErrorReport(session.version, RTRErrCorruptData, result.pkt,
+						result.err.Error()))
+				}
+				return false
+			}
+
+			if !c.processPDU(session, conn, result.pdu, result.pkt, resp) {
+				return false
+			}
+			if result.pdu.Type == PDUTypeEndOfData {
+				refreshTimer.Reset(session.refreshInterval)
+			}
+
+		case <-refreshTimer.C:
+			if !resp.started && !c.sendQuery(session, conn, resp) {
+				return false
+			}
+			refreshTimer.Reset(session.refreshInterval)
+
+		case <-session.expireTimer.C:
+			c.expireData(session)
+
+		case <-session.stopCh:
+			c.logger.Info("RPKI cache", session.config.Address, "client stopped, closing the connection")
+			return true
+		}
+	}
+}
+
+// processPDU processes a PDU received from the cache. It returns false if the connection should be closed.
+func (c *Client) processPDU(session *cacheSession, conn net.Conn, pdu *PDU, pkt []byte,
+	resp *cacheResponse) bool {
+	if pdu.Type == PDUTypeErrorReport {
+		c.logger.Err("RPKI cache", session.config.Address, "sent error report", pdu)
+		if pdu.SessionId == RTRErrUnsupportedVersion && pdu.Version < session.version {
+			c.logger.Info("RPKI cache", session.config.Address, "does not support version", session.version,
+				"falling back to version", pdu.Version)
+			session.version = pdu.Version
+		}
+		return false
+	}
+
+	if pdu.Version != session.version {
+		c.logger.Err("RPKI cache", session.config.Address, "sent", pdu, "expected version", session.version)
+		c.sendPDU(session, conn, NewErrorReport(session.version, RTRErrUnexpectedVersion, pkt,
+			"Unexpected protocol version"))
+		return false
+	}
+
+	switch pdu.Type {
+	case PDUTypeSerialNotify:
+		if !resp.started && (!session.hasData || pdu.Serial != session.serial) {
+			return c.sendQuery(session, conn, resp)
+		}
+
+	case PDUTypeCacheResponse:
+		if session.hasData && !resp.reset && pdu.SessionId != session.sessionId {
+			c.logger.Err("RPKI cache", session.config.Address, "session id changed from", session.sessionId,
+				"to", pdu.SessionId)
+			c.sendPDU(session, conn, NewErrorReport(session.version, RTRErrCorruptData, pkt,
+				"Session id changed"))
+			session.hasData = false
+			return false
+		}
+		session.sessionId = pdu.SessionId
+		resp.started = true
+		resp.announced = make([]ROA, 0)
+		resp.withdrawn = make([]ROA, 0)
+
+	case PDUTypeIPv4Prefix, PDUTypeIPv6Prefix:
+		if !resp.started {
+			c.sendPDU(session, conn, NewErrorReport(session.version, RTRErrCorruptData, pkt,
+				"Prefix PDU without Cache Response"))
+			return false
+		}
+		if pdu.Announce {
+			resp.announced = append(resp.announced, pdu.ROA)
+		} else {
+			resp.withdrawn = append(resp.withdrawn, pdu.ROA)
+		}
+
+	case PDUTypeEndOfData:
+		if !resp.started || pdu.SessionId != session.sessionId {
+			c.sendPDU(session, conn, NewErrorReport(session.version, RTRErrCorruptData, pkt,
+				"Unexpected End of Data"))
+			return false
+		}
+
+		session.serial = pdu.Serial
+		session.hasData = true
+		if pdu.Version >= RTRVersion1 {
+			if pdu.RefreshInterval > 0 {
+				session.refreshInterval = time.Duration(pdu.RefreshInterval) * time.Second
+			}
+			if pdu.RetryInterval > 0 {
+				session.retryInterval = time.Duration(pdu.RetryInterval) * time.Second
+			}
+			if pdu.ExpireInterval > 0 {
+				session.config.ExpireInterval = pdu.ExpireInterval
+			}
+		}
+		session.expireTimer.Reset(time.Duration(session.config.ExpireInterval) * time.Second)
+		c.applyResponse(session, resp)
+		*resp = cacheResponse{}
+
+	case PDUTypeCacheReset:
+		session.hasData = false
+		return c.sendQuery(session, conn, resp)
+
+	case PDUTypeRouterKey:
+
+	default:
+		c.sendPDU(session, conn, NewErrorReport(session.version, RTRErrUnsupportedPDUType, pkt,
+			"Unsupported PDU type"))
+		return false
+	}
+	return true
+}
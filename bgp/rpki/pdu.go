@@ -0,0 +1,320 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// pdu.go
+package rpki
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// RPKI-Router protocol versions, RFC 6810 and RFC 8210.
+const (
+	RTRVersion0 uint8 = 0
+	RTRVersion1 uint8 = 1
+)
+
+type PDUType uint8
+
+const (
+	PDUTypeSerialNotify  PDUType = 0
+	PDUTypeSerialQuery   PDUType = 1
+	PDUTypeResetQuery    PDUType = 2
+	PDUTypeCacheResponse PDUType = 3
+	PDUTypeIPv4Prefix    PDUType = 4
+	PDUTypeIPv6Prefix    PDUType = 6
+	PDUTypeEndOfData     PDUType = 7
+	PDUTypeCacheReset    PDUType = 8
+	PDUTypeRouterKey     PDUType = 9
+	PDUTypeErrorReport   PDUType = 10
+)
+
+const (
+	RTRHeaderLen        = 8
+	RTRSerialPDULen     = 12
+	RTRIPv4PrefixLen    = 20
+	RTRIPv6PrefixLen    = 32
+	RTREndOfDataV0Len   = 12
+	RTREndOfDataV1Len   = 24
+	RTRMaxPDULen        = 65536
+	RTRPrefixAnnounce   = 0x01
+	RTRMaxIPv4PrefixLen = 32
+	RTRMaxIPv6PrefixLen = 128
+)
+
+// Error codes of the Error Report PDU.
+const (
+	RTRErrCorruptData               uint16 = 0
+	RTRErrInternalError             uint16 = 1
+	RTRErrNoDataAvailable           uint16 = 2
+	RTRErrInvalidRequest            uint16 = 3
+	RTRErrUnsupportedVersion        uint16 = 4
+	RTRErrUnsupportedPDUType        uint16 = 5
+	RTRErrWithdrawalOfUnknownRecord uint16 = 6
+	RTRErrDuplicateAnnouncement     uint16 = 7
+	RTRErrUnexpectedVersion         uint16 = 8
+)
+
+// ROA is a Route Origin Authorization, the AS is authorized to originate the prefix and the more specific
+// prefixes up to MaxLen.
+type ROA struct {
+	Prefix    net.IP
+	PrefixLen uint8
+	MaxLen    uint8
+	AS        uint32
+}
+
+func (r ROA) String() string {
+	return fmt.Sprintf("%s/%d-%d AS%d", r.Prefix, r.PrefixLen, r.MaxLen, r.AS)
+}
+
+// PDU is a RPKI-Router protocol PDU. SessionId carries the error code in the Error Report PDU, Serial is used
+// by the Serial Notify, Serial Query and End of Data PDUs and ROA by the prefix PDUs.
+type PDU struct {
+	Version         uint8
+	Type            PDUType
+	SessionId       uint16
+	Serial          uint32
+	Announce        bool
+	ROA             ROA
+	RefreshInterval uint32
+	RetryInterval   uint32
+	ExpireInterval  uint32
+	ErrorPDU        []byte
+	ErrorText       string
+}
+
+func (p *PDU) String() string {
+	switch p.Type {
+	case PDUTypeIPv4Prefix, PDUTypeIPv6Prefix:
+		return fmt.Sprintf("{RTR v%d type %d announce %t %s}", p.Version, p.Type, p.Announce, p.ROA)
+	case PDUTypeErrorReport:
+		return fmt.Sprintf("{RTR v%d type %d error %d %s}", p.Version, p.Type, p.SessionId, p.ErrorText)
+	default:
+		return fmt.Sprintf("{RTR v%d type %d session %d serial %d}", p.Version, p.Type, p.SessionId, p.Serial)
+	}
+}
+
+func encodeHeader(pkt []byte, version uint8, pduType PDUType, sessionId uint16) {
+	pkt[0] = version
+	pkt[1] = uint8(pduType)
+	binary.BigEndian.PutUint16(pkt[2:4], sessionId)
+	binary.BigEndian.PutUint32(pkt[4:8], uint32(len(pkt)))
+}
+
+func (p *PDU) Encode() ([]byte, error) {
+	var pkt []byte
+	switch p.Type {
+	case PDUTypeSerialNotify, PDUTypeSerialQuery:
+		pkt = make([]byte, RTRSerialPDULen)
+		binary.BigEndian.PutUint32(pkt[8:12], p.Serial)
+
+	case PDUTypeResetQuery, PDUTypeCacheResponse, PDUTypeCacheReset:
+		pkt = make([]byte, RTRHeaderLen)
+
+	case PDUTypeIPv4Prefix, PDUTypeIPv6Prefix:
+		var prefix net.IP
+		if p.Type == PDUTypeIPv4Prefix {
+			pkt = make([]byte, RTRIPv4PrefixLen)
+			prefix = p.ROA.Prefix.To4()
+		} else {
+			pkt = make([]byte, RTRIPv6PrefixLen)
+			prefix = p.ROA.Prefix.To16()
+		}
+		if prefix == nil {
+			return nil, errors.New(fmt.Sprintf("RTR prefix PDU type %d, prefix %s is not valid", p.Type,
+				p.ROA.Prefix))
+		}
+		if p.Announce {
+			pkt[8] = RTRPrefixAnnounce
+		}
+		pkt[9] = p.ROA.PrefixLen
+		pkt[10] = p.ROA.MaxLen
+		copy(pkt[12:], prefix)
+		binary.BigEndian.PutUint32(pkt[12+len(prefix):], p.ROA.AS)
+
+	case PDUTypeEndOfData:
+		if p.Version == RTRVersion0 {
+			pkt = make([]byte, RTREndOfDataV0Len)
+		} else {
+			pkt = make([]byte, RTREndOfDataV1Len)
+			binary.BigEndian.PutUint32(pkt[12:16], p.RefreshInterval)
+			binary.BigEndian.PutUint32(pkt[16:20], p.RetryInterval)
+			binary.BigEndian.PutUint32(pkt[20:24], p.ExpireInterval)
+		}
+		binary.BigEndian.PutUint32(pkt[8:12], p.Serial)
+
+	case PDUTypeErrorReport:
+		pkt = make([]byte, RTRHeaderLen+8+len(p.ErrorPDU)+len(p.ErrorText))
+		binary.BigEndian.PutUint32(pkt[8:12], uint32(len(p.ErrorPDU)))
+		copy(pkt[12:], p.ErrorPDU)
+		idx := 12 + len(p.ErrorPDU)
+		binary.BigEndian.PutUint32(pkt[idx:idx+4], uint32(len(p.ErrorText)))
+		copy(pkt[idx+4:], p.ErrorText)
+
+	default:
+		return nil, errors.New(fmt.Sprintf("RTR PDU type %d is not supported", p.Type))
+	}
+
+	sessionId := p.SessionId
+	if p.Type == PDUTypeResetQuery || p.Type == PDUTypeCacheReset || p.Type == PDUTypeIPv4Prefix ||
+		p.Type == PDUTypeIPv6Prefix {
+		sessionId = 0
+	}
+	encodeHeader(pkt, p.Version, p.Type, sessionId)
+	return pkt, nil
+}
+
+func checkPDULen(pduType PDUType, length int, expected int) error {
+	if length != expected {
+		return errors.New(fmt.Sprintf("RTR PDU type %d has length %d, expected %d", pduType, length, expected))
+	}
+	return nil
+}
+
+// Decode decodes the PDU in pkt, pkt should have the complete PDU.
+func (p *PDU) Decode(pkt []byte) error {
+	if len(pkt) < RTRHeaderLen {
+		return errors.New(fmt.Sprintf("RTR PDU length %d is less than the header length", len(pkt)))
+	}
+
+	p.Version = pkt[0]
+	p.Type = PDUType(pkt[1])
+	p.SessionId = binary.BigEndian.Uint16(pkt[2:4])
+	length := int(binary.BigEndian.Uint32(pkt[4:8]))
+	if length != len(pkt) {
+		return errors.New(fmt.Sprintf("RTR PDU length %d does not match the data length %d", length, len(pkt)))
+	}
+
+	switch p.Type {
+	case PDUTypeSerialNotify, PDUTypeSerialQuery:
+		if err := checkPDULen(p.Type, length, RTRSerialPDULen); err != nil {
+			return err
+		}
+		p.Serial = binary.BigEndian.Uint32(pkt[8:12])
+
+	case PDUTypeResetQuery, PDUTypeCacheResponse, PDUTypeCacheReset:
+		if err := checkPDULen(p.Type, length, RTRHeaderLen); err != nil {
+			return err
+		}
+
+	case PDUTypeIPv4Prefix, PDUTypeIPv6Prefix:
+		addrLen, maxPrefixLen, pduLen := net.IPv4len, uint8(RTRMaxIPv4PrefixLen), RTRIPv4PrefixLen
+		if p.Type == PDUTypeIPv6Prefix {
+			addrLen, maxPrefixLen, pduLen = net.IPv6len, RTRMaxIPv6PrefixLen, RTRIPv6PrefixLen
+		}
+		if err := checkPDULen(p.Type, length, pduLen); err != nil {
+			return err
+		}
+		p.Announce = pkt[8]&RTRPrefixAnnounce != 0
+		p.ROA.PrefixLen = pkt[9]
+		p.ROA.MaxLen = pkt[10]
+		if p.ROA.PrefixLen > p.ROA.MaxLen || p.ROA.MaxLen > maxPrefixLen {
+			return errors.New(fmt.Sprintf("RTR prefix PDU has invalid prefix length %d max length %d",
+				p.ROA.PrefixLen, p.ROA.MaxLen))
+		}
+		p.ROA.Prefix = make(net.IP, addrLen)
+		copy(p.ROA.Prefix, pkt[12:12+addrLen])
+		p.ROA.AS = binary.BigEndian.Uint32(pkt[12+addrLen : 16+addrLen])
+
+	case PDUTypeEndOfData:
+		if p.Version == RTRVersion0 {
+			if err := checkPDULen(p.Type, length, RTREndOfDataV0Len); err != nil {
+				return err
+			}
+		} else {
+			if err := checkPDULen(p.Type, length, RTREndOfDataV1Len); err != nil {
+				return err
+			}
+			p.RefreshInterval = binary.BigEndian.Uint32(pkt[12:16])
+			p.RetryInterval = binary.BigEndian.Uint32(pkt[16:20])
+			p.ExpireInterval = binary.BigEndian.Uint32(pkt[20:24])
+		}
+		p.Serial = binary.BigEndian.Uint32(pkt[8:12])
+
+	case PDUTypeRouterKey:
+		// Router keys are used by BGPsec, they are not stored.
+
+	case PDUTypeErrorReport:
+		if length < RTRHeaderLen+8 {
+			return errors.New(fmt.Sprintf("RTR Error Report PDU length %d is too short", length))
+		}
+		pduLen := int(binary.BigEndian.Uint32(pkt[8:12]))
+		if pduLen > length-RTRHeaderLen-8 {
+			return errors.New(fmt.Sprintf("RTR Error Report PDU encapsulated PDU length %d is not valid", pduLen))
+		}
+		p.ErrorPDU = pkt[12 : 12+pduLen]
+		idx := 12 + pduLen
+		textLen := int(binary.BigEndian.Uint32(pkt[idx : idx+4]))
+		if textLen != length-idx-4 {
+			return errors.New(fmt.Sprintf("RTR Error Report PDU text length %d is not valid", textLen))
+		}
+		p.ErrorText = string(pkt[idx+4:])
+
+	default:
+		return errors.New(fmt.Sprintf("RTR PDU type %d is not supported", p.Type))
+	}
+	return nil
+}
+
+// ReadPDU reads the next PDU from the reader. The data of the PDU is returned with the error so that it can
+// be sent back in an Error Report PDU.
+func ReadPDU(reader io.Reader) (*PDU, []byte, error) {
+	header := make([]byte, RTRHeaderLen)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, nil, err
+	}
+
+	length := int(binary.BigEndian.Uint32(header[4:8]))
+	if length < RTRHeaderLen || length > RTRMaxPDULen {
+		return nil, header, errors.New(fmt.Sprintf("RTR PDU length %d is not valid", length))
+	}
+
+	pkt := make([]byte, length)
+	copy(pkt, header)
+	if _, err := io.ReadFull(reader, pkt[RTRHeaderLen:]); err != nil {
+		return nil, nil, err
+	}
+
+	pdu := &PDU{}
+	if err := pdu.Decode(pkt); err != nil {
+		return nil, pkt, err
+	}
+	return pdu, pkt, nil
+}
+
+func NewSerialQuery(version uint8, sessionId uint16, serial uint32) *PDU {
+	return &PDU{Version: version, Type: PDUTypeSerialQuery, SessionId: sessionId, Serial: serial}
+}
+
+func NewResetQuery(version uint8) *PDU {
+	return &PDU{Version: version, Type: PDUTypeResetQuery}
+}
+
+func NewErrorReport(version uint8, code uint16, errPDU []byte, text string) *PDU {
+	return &PDU{Version: version, Type: PDUTypeErrorReport, SessionId: code, ErrorPDU: errPDU, ErrorText: text}
+}
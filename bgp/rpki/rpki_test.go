@@ -0,0 +1,324 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// rpki_test.go
+package rpki
+
+import (
+	"l3/bgp/config"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+	"utils/logging"
+)
+
+func TestRTRPDUEncodeDecode(t *testing.T) {
+	pdus := []*PDU{
+		&PDU{Version: RTRVersion1, Type: PDUTypeSerialNotify, SessionId: 10, Serial: 20},
+		&PDU{Version: RTRVersion1, Type: PDUTypeSerialQuery, SessionId: 10, Serial: 20},
+		&PDU{Version: RTRVersion1, Type: PDUTypeResetQuery},
+		&PDU{Version: RTRVersion1, Type: PDUTypeCacheResponse, SessionId: 10},
+		&PDU{Version: RTRVersion1, Type: PDUTypeIPv4Prefix, Announce: true,
+			ROA: ROA{net.ParseIP("10.1.0.0").To4(), 16, 24, 65001}},
+		&PDU{Version: RTRVersion1, Type: PDUTypeIPv6Prefix,
+			ROA: ROA{net.ParseIP("2001:db8::"), 32, 48, 4200000000}},
+		&PDU{Version: RTRVersion0, Type: PDUTypeEndOfData, SessionId: 10, Serial: 21},
+		&PDU{Version: RTRVersion1, Type: PDUTypeEndOfData, SessionId: 10, Serial: 21, RefreshInterval: 100,
+			RetryInterval: 50, ExpireInterval: 1000},
+		&PDU{Version: RTRVersion1, Type: PDUTypeCacheReset},
+		&PDU{Version: RTRVersion1, Type: PDUTypeErrorReport, SessionId: RTRErrInvalidRequest,
+			ErrorPDU: []byte{1, 2, 0, 0, 0, 0, 0, 8}, ErrorText: "bad request"},
+	}
+
+	for _, pdu := range pdus {
+		pkt, err := pdu.Encode()
+		if err != nil {
+			t.Fatal("Failed to encode", pdu, "with error", err)
+		}
+
+		decoded := &PDU{}
+		if err = decoded.Decode(pkt); err != nil {
+			t.Fatal("Failed to decode", pdu, "with error", err)
+		}
+		if !reflect.DeepEqual(pdu, decoded) {
+			t.Fatal("Decoded PDU", decoded, "does not match", pdu)
+		}
+	}
+
+	bad := &PDU{Version: RTRVersion1, Type: PDUTypeIPv4Prefix, Announce: true,
+		ROA: ROA{net.ParseIP("10.1.0.0"), 24, 16, 65001}}
+	pkt, _ := bad.Encode()
+	if err := (&PDU{}).Decode(pkt); err == nil {
+		t.Fatal("Decoded prefix PDU with max length less than the prefix length")
+	}
+	if err := (&PDU{}).Decode(pkt[:RTRIPv4PrefixLen-1]); err == nil {
+		t.Fatal("Decoded truncated prefix PDU")
+	}
+}
+
+func TestROATableValidate(t *testing.T) {
+	table := NewROATable()
+	table.Add(ROA{net.ParseIP("10.0.0.0"), 8, 16, 65001})
+	table.Add(ROA{net.ParseIP("10.1.0.0"), 16, 24, 65002})
+	table.Add(ROA{net.ParseIP("2001:db8::"), 32, 32, 65003})
+	if err := table.Add(ROA{net.ParseIP("10.0.0.0"), 8, 16, 65001}); err == nil {
+		t.Fatal("Duplicate ROA added to the table")
+	}
+
+	tests := []struct {
+		prefix string
+		length uint8
+		as     uint32
+		state  ValidationState
+	}{
+		{"10.0.0.0", 8, 65001, ValidationStateValid},
+		{"10.2.0.0", 16, 65001, ValidationStateValid},
+		{"10.2.1.0", 24, 65001, ValidationStateInvalid},
+		{"10.1.1.0", 24, 65002, ValidationStateValid},
+		{"10.1.1.0", 24, 65001, ValidationStateInvalid},
+		{"10.1.1.0", 25, 65002, ValidationStateInvalid},
+		{"10.1.1.0", 24, 0, ValidationStateInvalid},
+		{"11.0.0.0", 8, 65001, ValidationStateNotFound},
+		{"0.0.0.0", 0, 65001, ValidationStateNotFound},
+		{"2001:db8::", 32, 65003, ValidationStateValid},
+		{"2001:db8:1::", 48, 65003, ValidationStateInvalid},
+		{"2001:db9::", 32, 65003, ValidationStateNotFound},
+	}
+
+	for _, test := range tests {
+		state := table.Validate(net.ParseIP(test.prefix), test.length, test.as)
+		if state != test.state {
+			t.Fatalf("Route %s/%d AS %d validation state %s, expected %s", test.prefix, test.length, test.as,
+				state, test.state)
+		}
+	}
+
+	changed, errs := table.Update(nil, []ROA{ROA{net.ParseIP("10.0.0.0"), 8, 16, 65001},
+		ROA{net.ParseIP("12.0.0.0"), 8, 8, 65001}}, false)
+	if !changed || len(errs) != 1 || table.Len() != 2 {
+		t.Fatal("ROA table update changed", changed, "errors", errs, "length", table.Len())
+	}
+	if state := table.Validate(net.ParseIP("10.2.0.0"), 16, 65001); state != ValidationStateNotFound {
+		t.Fatal("Route validation state", state, "after withdrawing the ROA, expected not-found")
+	}
+}
+
+// testCache is a minimal in-process RPKI cache. The ROAs are announced in the deltas of the serial numbers
+// so that the Serial Queries get the incremental updates.
+type testCache struct {
+	t         *testing.T
+	version   uint8
+	sessionId uint16
+	listener  net.Listener
+	mutex     sync.Mutex
+	deltas    [][]*PDU
+	conns     []net.Conn
+}
+
+func newTestCache(t *testing.T, version uint8) *testCache {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen with error", err)
+	}
+
+	cache := &testCache{
+		t:         t,
+		version:   version,
+		sessionId: 100,
+		listener:  listener,
+	}
+	go cache.serve()
+	return cache
+}
+
+func (c *testCache) getConfig() config.RPKICacheServer {
+	addr := c.listener.Addr().(*net.TCPAddr)
+	return config.RPKICacheServer{Address: addr.IP, Port: uint16(addr.Port), RetryInterval: 1}
+}
+
+func (c *testCache) close() {
+	c.listener.Close()
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+// update adds a new serial with the ROA changes and notifies the connected routers.
+func (c *testCache) update(announced []ROA, withdrawn []ROA) {
+	delta := make([]*PDU, 0)
+	for _, roa := range announced {
+		delta = append(delta, c.prefixPDU(roa, true))
+	}
+	for _, roa := range withdrawn {
+		delta = append(delta, c.prefixPDU(roa, false))
+	}
+
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	c.deltas = append(c.deltas, delta)
+	notify := &PDU{Version: c.version, Type: PDUTypeSerialNotify, SessionId: c.sessionId,
+		Serial: uint32(len(c.deltas))}
+	for _, conn := range c.conns {
+		c.send(conn, notify)
+	}
+}
+
+func (c *testCache) prefixPDU(roa ROA, announce bool) *PDU {
+	pduType := PDUTypeIPv4Prefix
+	if roa.Prefix.To4() == nil {
+		pduType = PDUTypeIPv6Prefix
+	}
+	return &PDU{Version: c.version, Type: pduType, Announce: announce, ROA: roa}
+}
+
+func (c *testCache) send(conn net.Conn, pdus ...*PDU) {
+	for _, pdu := range pdus {
+		pkt, err := pdu.Encode()
+		if err != nil {
+			c.t.Error("Test cache failed to encode", pdu, "with error", err)
+			return
+		}
+		conn.Write(pkt)
+	}
+}
+
+func (c *testCache) serve() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		c.mutex.Lock()
+		c.conns = append(c.conns, conn)
+		c.mutex.Unlock()
+		go c.serveConn(conn)
+	}
+}
+
+func (c *testCache) serveConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		query, pkt, err := ReadPDU(conn)
+		if err != nil {
+			return
+		}
+
+		if query.Version > c.version {
+			c.send(conn, NewErrorReport(c.version, RTRErrUnsupportedVersion, pkt, "Unsupported version"))
+			return
+		}
+
+		c.mutex.Lock()
+		serial := uint32(0)
+		if query.Type == PDUTypeSerialQuery {
+			serial = query.Serial
+		}
+		roas := make(map[string]*PDU)
+		keys := make([]string, 0)
+		for _, delta := range c.deltas[serial:] {
+			for _, pdu := range delta {
+				key := pdu.ROA.String()
+				if _, ok := roas[key]; !ok {
+					keys = append(keys, key)
+				}
+				roas[key] = pdu
+			}
+		}
+
+		c.send(conn, &PDU{Version: c.version, Type: PDUTypeCacheResponse, SessionId: c.sessionId})
+		for _, key := range keys {
+			if query.Type == PDUTypeSerialQuery || roas[key].Announce {
+				c.send(conn, roas[key])
+			}
+		}
+		c.send(conn, &PDU{Version: c.version, Type: PDUTypeEndOfData, SessionId: c.sessionId,
+			Serial: uint32(len(c.deltas)), RefreshInterval: 3600, RetryInterval: 1, ExpireInterval: 7200})
+		c.mutex.Unlock()
+	}
+}
+
+func waitForUpdate(t *testing.T, client *Client) {
+	select {
+	case <-client.UpdateCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the ROA table update")
+	}
+}
+
+func TestRTRClient(t *testing.T) {
+	logger, _ := logging.NewLogger("bgpd", "BGP", false)
+	cache := newTestCache(t, RTRVersion1)
+	defer cache.close()
+	roa1 := ROA{net.ParseIP("10.1.0.0").To4(), 16, 24, 65001}
+	roa2 := ROA{net.ParseIP("2001:db8::"), 32, 48, 65002}
+	cache.update([]ROA{roa1, roa2}, nil)
+
+	client := NewClient(logger)
+	client.Start(cache.getConfig())
+	defer client.Stop()
+	waitForUpdate(t, client)
+	if client.Table.Len() != 2 {
+		t.Fatal("ROA table has", client.Table.Len(), "ROAs, expected 2")
+	}
+	if state := client.Validate(net.ParseIP("10.1.2.0"), 24, 65001); state != ValidationStateValid {
+		t.Fatal("Route 10.1.2.0/24 validation state", state, "expected valid")
+	}
+
+	roa3 := ROA{net.ParseIP("10.1.2.0").To4(), 24, 24, 65003}
+	cache.update([]ROA{roa3}, []ROA{roa1})
+	waitForUpdate(t, client)
+	if client.Table.Len() != 2 {
+		t.Fatal("ROA table has", client.Table.Len(), "ROAs after the serial update, expected 2")
+	}
+	if state := client.Validate(net.ParseIP("10.1.2.0"), 24, 65001); state != ValidationStateInvalid {
+		t.Fatal("Route 10.1.2.0/24 validation state", state, "expected invalid")
+	}
+	if state := client.Validate(net.ParseIP("10.1.3.0"), 24, 65001); state != ValidationStateNotFound {
+		t.Fatal("Route 10.1.3.0/24 validation state", state, "expected not-found")
+	}
+
+	client.Stop()
+	waitForUpdate(t, client)
+	if client.IsRunning() || client.Table.Len() != 0 {
+		t.Fatal("ROA table has", client.Table.Len(), "ROAs after the client is stopped")
+	}
+}
+
+func TestRTRClientVersionFallback(t *testing.T) {
+	logger, _ := logging.NewLogger("bgpd", "BGP", false)
+	cache := newTestCache(t, RTRVersion0)
+	defer cache.close()
+	cache.update([]ROA{ROA{net.ParseIP("10.1.0.0").To4(), 16, 16, 65001}}, nil)
+
+	client := NewClient(logger)
+	client.Start(cache.getConfig())
+	defer client.Stop()
+	waitForUpdate(t, client)
+	if state := client.Validate(net.ParseIP("10.1.0.0"), 16, 65001); state != ValidationStateValid {
+		t.Fatal("Route 10.1.0.0/16 validation state", state, "expected valid")
+	}
+}
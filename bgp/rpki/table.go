@@ -0,0 +1,224 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// table.go
+package rpki
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ValidationState is the origin validation state of a route, RFC 6811.
+type ValidationState uint8
+
+const (
+	ValidationStateNotFound ValidationState = iota
+	ValidationStateValid
+	ValidationStateInvalid
+)
+
+var validationStateToStr = map[ValidationState]string{
+	ValidationStateNotFound: "not-found",
+	ValidationStateValid:    "valid",
+	ValidationStateInvalid:  "invalid",
+}
+
+func (v ValidationState) String() string {
+	if str, ok := validationStateToStr[v]; ok {
+		return str
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(v))
+}
+
+func ParseValidationState(str string) (ValidationState, error) {
+	for state, stateStr := range validationStateToStr {
+		if stateStr == str {
+			return state, nil
+		}
+	}
+	return ValidationStateNotFound, errors.New(fmt.Sprintf("Invalid origin validation state %s", str))
+}
+
+type roaKey struct {
+	prefix string
+	length uint8
+}
+
+func normalizePrefix(prefix net.IP) net.IP {
+	if ip := prefix.To4(); ip != nil {
+		return ip
+	}
+	return prefix.To16()
+}
+
+func getROAKey(prefix net.IP, length uint8) roaKey {
+	ip := normalizePrefix(prefix)
+	return roaKey{
+		prefix: string(ip.Mask(net.CIDRMask(int(length), len(ip)*8))),
+		length: length,
+	}
+}
+
+// ROATable stores the ROAs by the prefix, the ROAs that cover a route are found by looking up the prefixes
+// of all the lengths up to the length of the route.
+type ROATable struct {
+	sync.RWMutex
+	roas  map[roaKey][]ROA
+	count int
+}
+
+func NewROATable() *ROATable {
+	return &ROATable{
+		roas: make(map[roaKey][]ROA),
+	}
+}
+
+func (t *ROATable) Len() int {
+	defer t.RUnlock()
+	t.RLock()
+	return t.count
+}
+
+func (t *ROATable) add(roa ROA) error {
+	key := getROAKey(roa.Prefix, roa.PrefixLen)
+	for _, item := range t.roas[key] {
+		if item.MaxLen == roa.MaxLen && item.AS == roa.AS {
+			return errors.New(fmt.Sprintf("ROA %s already exists", roa))
+		}
+	}
+
+	roa.Prefix = net.IP(key.prefix)
+	t.roas[key] = append(t.roas[key], roa)
+	t.count++
+	return nil
+}
+
+func (t *ROATable) remove(roa ROA) error {
+	key := getROAKey(roa.Prefix, roa.PrefixLen)
+	roas := t.roas[key]
+	for idx, item := range roas {
+		if item.MaxLen == roa.MaxLen && item.AS == roa.AS {
+			roas[idx] = roas[len(roas)-1]
+			roas = roas[:len(roas)-1]
+			if len(roas) == 0 {
+				delete(t.roas, key)
+			} else {
+				t.roas[key] = roas
+			}
+			t.count--
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("ROA %s not found", roa))
+}
+
+func (t *ROATable) Add(roa ROA) error {
+	defer t.Unlock()
+	t.Lock()
+	return t.add(roa)
+}
+
+func (t *ROATable) Remove(roa ROA) error {
+	defer t.Unlock()
+	t.Lock()
+	return t.remove(roa)
+}
+
+// Update applies the ROAs announced and withdrawn by the cache. All the ROAs are replaced by the announced
+// ROAs if reset is true. The errors of the duplicate announcements and the withdrawals of unknown ROAs are
+// returned after the other ROAs are applied.
+func (t *ROATable) Update(announced []ROA, withdrawn []ROA, reset bool) (bool, []error) {
+	errs := make([]error, 0)
+	defer t.Unlock()
+	t.Lock()
+	changed := false
+	if reset && t.count > 0 {
+		t.roas = make(map[roaKey][]ROA)
+		t.count = 0
+		changed = true
+	}
+
+	for _, roa := range withdrawn {
+		if err := t.remove(roa); err != nil {
+			errs = append(errs, err)
+		} else {
+			changed = true
+		}
+	}
+
+	for _, roa := range announced {
+		if err := t.add(roa); err != nil {
+			errs = append(errs, err)
+		} else {
+			changed = true
+		}
+	}
+	return changed, errs
+}
+
+func (t *ROATable) Clear() bool {
+	defer t.Unlock()
+	t.Lock()
+	if t.count == 0 {
+		return false
+	}
+
+	t.roas = make(map[roaKey][]ROA)
+	t.count = 0
+	return true
+}
+
+// Validate returns the origin validation state of the route, RFC 6811. The route is valid if a covering ROA
+// authorizes the origin AS, invalid if it is covered only by the ROAs that don't authorize the origin AS and
+// not found if no ROA covers it. An origin AS of 0 never matches a ROA.
+func (t *ROATable) Validate(prefix net.IP, prefixLen uint8, originAS uint32) ValidationState {
+	ip := normalizePrefix(prefix)
+	if ip == nil || int(prefixLen) > len(ip)*8 {
+		return ValidationStateNotFound
+	}
+
+	defer t.RUnlock()
+	t.RLock()
+	if t.count == 0 {
+		return ValidationStateNotFound
+	}
+
+	state := ValidationStateNotFound
+	for length := 0; length <= int(prefixLen); length++ {
+		roas, ok := t.roas[getROAKey(ip, uint8(length))]
+		if !ok {
+			continue
+		}
+
+		state = ValidationStateInvalid
+		for _, roa := range roas {
+			if prefixLen <= roa.MaxLen && originAS == roa.AS && originAS != 0 {
+				return ValidationStateValid
+			}
+		}
+	}
+	return state
+}
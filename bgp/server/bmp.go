@@ -0,0 +1,193 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// bmp.go
+package server
+
+import (
+	"l3/bgp/bmp"
+	"l3/bgp/config"
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const bmpSysDescr = "SnapRoute FlexSwitch bgpd"
+
+func getSysName() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "bgpd"
+	}
+	return name
+}
+
+// processBMPCollectorUpdate adds the collector station. An updated collector is reconnected with the new
+// config.
+func (s *BGPServer) processBMPCollectorUpdate(colUpdate BMPCollectorUpdate) {
+	if colUpdate.OldCollector.Name != "" {
+		if err := s.bmpClient.RemoveCollector(colUpdate.OldCollector.Name); err != nil {
+			s.logger.Err("Remove BMP collector", colUpdate.OldCollector.Name, "failed with error", err)
+		}
+	}
+
+	s.logger.Infof("Add BMP collector %+v", colUpdate.NewCollector)
+	if err := s.bmpClient.AddCollector(colUpdate.NewCollector); err != nil {
+		s.logger.Err("Add BMP collector", colUpdate.NewCollector.Name, "failed with error", err)
+	}
+}
+
+func (s *BGPServer) processBMPCollectorDelete(colConf config.BMPCollector) {
+	s.logger.Infof("Remove BMP collector %s", colConf.Name)
+	if err := s.bmpClient.RemoveCollector(colConf.Name); err != nil {
+		s.logger.Err("Remove BMP collector", colConf.Name, "failed with error", err)
+	}
+}
+
+// bmpKey returns the key of the peer in the BMP client, the same peer address can be used in multiple VRFs.
+func (p *Peer) bmpKey() string {
+	return p.server.getVrf() + ":" + p.NeighborConf.Neighbor.NeighborAddress.String()
+}
+
+func (p *Peer) getBMPPeerHeader() *bmp.PeerHeader {
+	header := &bmp.PeerHeader{
+		PeerType:  bmp.BMPPeerTypeGlobal,
+		Address:   p.NeighborConf.Neighbor.NeighborAddress,
+		AS:        p.NeighborConf.RunningConf.PeerAS,
+		BGPId:     p.NeighborConf.BGPId,
+		Timestamp: time.Now(),
+	}
+	if p.NeighborConf.ASSize == 2 {
+		header.Flags |= bmp.BMPPeerFlagAS2Byte
+	}
+	if !p.server.isDefaultVrf() && p.server.vpn != nil {
+		header.PeerType = bmp.BMPPeerTypeRD
+		header.Distinguisher = p.server.vpn.rd
+	}
+	return header
+}
+
+func encodeBMPMessage(msg *packet.BGPMessage) []byte {
+	if msg == nil {
+		return nil
+	}
+
+	pkt, err := msg.Encode()
+	if err != nil {
+		return nil
+	}
+	return pkt
+}
+
+func splitHostPort(addr net.Addr) (net.IP, uint16) {
+	host, portStr, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return net.ParseIP(host), uint16(port)
+}
+
+func (p *Peer) bmpPeerUp(conn *net.Conn, sentOpen *packet.BGPMessage, recvOpen *packet.BGPMessage) {
+	localAddr, localPort := splitHostPort((*conn).LocalAddr())
+	_, remotePort := splitHostPort((*conn).RemoteAddr())
+	p.server.bmpClient.PeerUp(p.bmpKey(), p.getBMPPeerHeader(), localAddr, localPort, remotePort,
+		encodeBMPMessage(sentOpen), encodeBMPMessage(recvOpen))
+}
+
+// bmpPeerDown reports the peer down with the NOTIFICATION message that closed the session, if any.
+func (p *Peer) bmpPeerDown(notifMsg *packet.BGPMessage, notifMsgSent bool) {
+	reason := bmp.BMPPeerDownRemoteNoNotification
+	if notifMsg != nil {
+		reason = bmp.BMPPeerDownRemoteNotification
+		if notifMsgSent {
+			reason = bmp.BMPPeerDownLocalNotification
+		}
+	}
+	p.server.bmpClient.PeerDown(p.bmpKey(), reason, encodeBMPMessage(notifMsg))
+}
+
+func (p *Peer) bmpPeerDeconfigured() {
+	p.server.bmpClient.PeerDown(p.bmpKey(), bmp.BMPPeerDownDeconfigured, nil)
+}
+
+func (p *Peer) bmpRouteMonitoring(updateMsg *packet.BGPMessage, postPolicy bool) {
+	if pkt := encodeBMPMessage(updateMsg); pkt != nil {
+		p.server.bmpClient.RouteMonitoring(p.bmpKey(), pkt, postPolicy)
+	}
+}
+
+// bmpPostPolicyUpdates reports the routes of the UPDATE message accepted by the import policy, with the path
+// attributes modified by the policy.
+func (p *Peer) bmpPostPolicyUpdates(path *bgprib.Path, updateMsg *packet.BGPUpdate,
+	modifiedPaths map[*bgprib.Path][]packet.NLRI, mpReach *packet.BGPPathAttrMPReachNLRI,
+	mpUnreach *packet.BGPPathAttrMPUnreachNLRI, mpModifiedPaths map[*bgprib.Path][]packet.NLRI) {
+	if len(updateMsg.NLRI) > 0 {
+		p.bmpRouteMonitoring(packet.NewBGPUpdateMessage(updateMsg.WithdrawnRoutes, path.PathAttrs,
+			updateMsg.NLRI), true)
+	} else if len(updateMsg.WithdrawnRoutes) > 0 {
+		p.bmpRouteMonitoring(packet.NewBGPUpdateMessage(updateMsg.WithdrawnRoutes, nil, nil), true)
+	}
+
+	for modifiedPath, nlris := range modifiedPaths {
+		p.bmpRouteMonitoring(packet.NewBGPUpdateMessage(nil, modifiedPath.PathAttrs, nlris), true)
+	}
+
+	if mpUnreach != nil && len(mpUnreach.NLRI) > 0 {
+		mpUnreachAttr := packet.ConstructMPUnreachNLRI(mpUnreach.AFI, mpUnreach.SAFI, mpUnreach.NLRI)
+		p.bmpRouteMonitoring(packet.NewBGPUpdateMessage(nil, []packet.BGPPathAttr{mpUnreachAttr}, nil), true)
+	}
+
+	if mpReach == nil {
+		return
+	}
+
+	if len(mpReach.NLRI) > 0 {
+		pathAttrs := packet.AddMPReachNLRIToPathAttrs(packet.CopyPathAttrs(path.PathAttrs),
+			packet.CloneMPReachNLRIWithNewNLRI(mpReach, mpReach.NLRI))
+		p.bmpRouteMonitoring(packet.NewBGPUpdateMessage(nil, pathAttrs, nil), true)
+	}
+
+	for modifiedPath, nlris := range mpModifiedPaths {
+		pathAttrs := packet.AddMPReachNLRIToPathAttrs(packet.CopyPathAttrs(modifiedPath.PathAttrs),
+			packet.CloneMPReachNLRIWithNewNLRI(mpReach, nlris))
+		p.bmpRouteMonitoring(packet.NewBGPUpdateMessage(nil, pathAttrs, nil), true)
+	}
+}
+
+func (p *Peer) bmpIncrementStat(statType uint16, delta uint64) {
+	if delta > 0 {
+		p.server.bmpClient.IncrementStat(p.bmpKey(), statType, delta)
+	}
+}
+
+func (p *Peer) bmpUpdateAdjRIBInRoutes() {
+	var routes uint64
+	for _, ribIn := range p.ribIn {
+		routes += uint64(len(ribIn))
+	}
+	p.server.bmpClient.SetStat(p.bmpKey(), bmp.BMPStatAdjRIBInRoutes, routes)
+}
@@ -0,0 +1,224 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// condadv.go
+package server
+
+import (
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"net"
+)
+
+// The default route is sent with this path id to the peers that negotiated add-path. The Loc-RIB default route
+// is withdrawn from the peer while the default route is originated, so the path id can't be in use.
+const defaultRoutePathId uint32 = 1
+
+// hasAdvConditions returns true if the routes that are advertised to the peer depend on the default route
+// origination or the conditional advertisement config. The peer is not added to an update group then.
+func (p *Peer) hasAdvConditions() bool {
+	if p.NeighborConf.RunningConf.ConditionalAdv.ConditionPrefix != "" {
+		return true
+	}
+
+	for protoFamily, ok := range p.NeighborConf.AfiSafiMap {
+		if ok && p.NeighborConf.IsDefaultOriginate(protoFamily) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrefixInLocRib returns true if the prefix, in the CIDR notation, has a best path in the Loc-RIB.
+func (p *Peer) isPrefixInLocRib(prefix string) bool {
+	ip, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		p.logger.Errf("Neighbor %s: Tracked prefix %s is not valid", p.NeighborConf.Neighbor.NeighborAddress,
+			prefix)
+		return false
+	}
+
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+	if ip.To4() == nil {
+		protoFamily = packet.GetProtocolFamily(packet.AfiIP6, packet.SafiUnicast)
+	}
+	cidrLen, _ := ipNet.Mask.Size()
+	dest := p.locRib.GetDestFromIPAndLen(protoFamily, ipNet.IP.String(), uint32(cidrLen))
+	return dest != nil && dest.LocRibPath != nil
+}
+
+// isAdvConditionMet returns true if the routes of the conditional advertisement can be advertised to the peer.
+func (p *Peer) isAdvConditionMet() bool {
+	condAdv := &p.NeighborConf.RunningConf.ConditionalAdv
+	if condAdv.ConditionPrefix == "" {
+		return true
+	}
+
+	return p.isPrefixInLocRib(condAdv.ConditionPrefix) != condAdv.NonExist
+}
+
+// isAdvSuppressed returns true if the Loc-RIB route is not advertised to the peer because the default route is
+// originated to the peer or the condition of the conditional advertisement of the route is not met.
+func (p *Peer) isAdvSuppressed(protoFamily uint32, nlri packet.NLRI) bool {
+	if p.defaultRouteSent[protoFamily] && nlri.GetLength() == 0 {
+		return true
+	}
+
+	condAdv := &p.NeighborConf.RunningConf.ConditionalAdv
+	if condAdv.ConditionPrefix == "" || p.condAdvMet || !packet.IsUnicastFamily(protoFamily) {
+		return false
+	}
+
+	cidr := nlri.GetCIDR()
+	for _, prefix := range condAdv.Prefixes {
+		if prefix == cidr {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAdvConditions evaluates the conditions of the default route origination and the conditional
+// advertisement after the Loc-RIB is updated. The default route and the routes of the conditional
+// advertisement are advertised or withdrawn when the state of their condition changes.
+func (p *Peer) checkAdvConditions() {
+	updated := make(map[uint32]map[*bgprib.Path][]*bgprib.Destination)
+	for protoFamily, ok := range p.NeighborConf.AfiSafiMap {
+		if !ok || !p.NeighborConf.IsDefaultOriginate(protoFamily) {
+			continue
+		}
+
+		condPrefix := p.NeighborConf.RunningConf.DefaultOriginateCond
+		originate := condPrefix == "" || p.isPrefixInLocRib(condPrefix)
+		if originate == p.defaultRouteSent[protoFamily] {
+			continue
+		}
+
+		p.logger.Infof("Neighbor %s: Default route origination for protocol family %d changed to %t",
+			p.NeighborConf.Neighbor.NeighborAddress, protoFamily, originate)
+		if !originate {
+			p.sendDefaultRoute(protoFamily, false)
+		}
+		p.defaultRouteSent[protoFamily] = originate
+		// The default route in the Loc-RIB is withdrawn from the peer while the default route is originated
+		if dest := p.locRib.GetDestFromIPAndLen(protoFamily, p.getDefaultPrefix(protoFamily).String(),
+			0); dest != nil && dest.LocRibPath != nil {
+			p.addDestToUpdated(updated, protoFamily, dest)
+			p.SendUpdate(updated, make([]*bgprib.Destination, 0), make([]*bgprib.Destination, 0))
+			delete(updated, protoFamily)
+		}
+		if originate {
+			p.sendDefaultRoute(protoFamily, true)
+		}
+	}
+
+	condAdvMet := p.isAdvConditionMet()
+	if condAdvMet == p.condAdvMet {
+		return
+	}
+
+	p.logger.Infof("Neighbor %s: Conditional advertisement condition changed to %t",
+		p.NeighborConf.Neighbor.NeighborAddress, condAdvMet)
+	p.condAdvMet = condAdvMet
+	for _, prefix := range p.NeighborConf.RunningConf.ConditionalAdv.Prefixes {
+		ip, ipNet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			continue
+		}
+
+		protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+		if ip.To4() == nil {
+			protoFamily = packet.GetProtocolFamily(packet.AfiIP6, packet.SafiUnicast)
+		}
+		cidrLen, _ := ipNet.Mask.Size()
+		dest := p.locRib.GetDestFromIPAndLen(protoFamily, ipNet.IP.String(), uint32(cidrLen))
+		if dest != nil && dest.LocRibPath != nil {
+			p.addDestToUpdated(updated, protoFamily, dest)
+		}
+	}
+
+	if len(updated) > 0 {
+		// The routes are withdrawn by SendUpdate if the condition is not met
+		p.SendUpdate(updated, make([]*bgprib.Destination, 0), make([]*bgprib.Destination, 0))
+	}
+}
+
+func (p *Peer) addDestToUpdated(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination, protoFamily uint32,
+	dest *bgprib.Destination) {
+	if _, ok := updated[protoFamily]; !ok {
+		updated[protoFamily] = make(map[*bgprib.Path][]*bgprib.Destination)
+	}
+	updated[protoFamily][dest.LocRibPath] = append(updated[protoFamily][dest.LocRibPath], dest)
+}
+
+func (p *Peer) getDefaultPrefix(protoFamily uint32) net.IP {
+	afi, _ := packet.GetAfiSafi(protoFamily)
+	if afi == packet.AfiIP6 {
+		return net.IPv6zero
+	}
+	return net.IPv4zero
+}
+
+// sendDefaultRoute advertises or withdraws the default route of the unicast protocol family to the peer. The
+// default route is originated with an empty AS path and the local address as the next hop.
+func (p *Peer) sendDefaultRoute(protoFamily uint32, advertise bool) {
+	localAddress := p.NeighborConf.Neighbor.Transport.Config.LocalAddress
+	if localAddress == nil {
+		return
+	}
+
+	var nlri packet.NLRI = packet.NewIPPrefix(p.getDefaultPrefix(protoFamily), 0)
+	if p.isAddPathsTxEnabled(protoFamily) {
+		nlri = packet.NewExtNLRI(defaultRoutePathId, nlri.(*packet.IPPrefix))
+	}
+	afi, _ := packet.GetAfiSafi(protoFamily)
+
+	if !advertise {
+		var updateMsg *packet.BGPMessage
+		if afi == packet.AfiIP {
+			updateMsg = packet.NewBGPUpdateMessage([]packet.NLRI{nlri}, nil, nil)
+		} else {
+			mpUnreachNLRI := packet.ConstructMPUnreachNLRIFromProtoFamily(protoFamily, []packet.NLRI{nlri})
+			updateMsg = packet.NewBGPUpdateMessage(nil, []packet.BGPPathAttr{mpUnreachNLRI}, nil)
+		}
+		p.sendUpdateMsg(updateMsg, nil)
+		return
+	}
+
+	pathAttrs := make([]packet.BGPPathAttr, 0)
+	pathAttrs = append(pathAttrs, packet.NewBGPPathAttrOrigin(packet.BGPPathAttrOriginIGP))
+	pathAttrs = append(pathAttrs, packet.NewBGPPathAttrASPath())
+	var updateMsg *packet.BGPMessage
+	if afi == packet.AfiIP {
+		nextHop := packet.NewBGPPathAttrNextHop()
+		nextHop.Value = localAddress
+		pathAttrs = append(pathAttrs, nextHop)
+		updateMsg = packet.NewBGPUpdateMessage(make([]packet.NLRI, 0), pathAttrs, []packet.NLRI{nlri})
+	} else {
+		mpReachNLRI := packet.ConstructIPv6MPReachNLRI(protoFamily, localAddress, nil, []packet.NLRI{nlri})
+		pathAttrs = packet.AddMPReachNLRIToPathAttrs(pathAttrs, mpReachNLRI)
+		updateMsg = packet.NewBGPUpdateMessage(nil, pathAttrs, nil)
+	}
+	path := bgprib.NewPath(p.locRib, nil, pathAttrs, nil, bgprib.RouteTypeStatic)
+	p.sendUpdateMsg(updateMsg.Clone(), path)
+}
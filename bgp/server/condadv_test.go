@@ -0,0 +1,55 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// condadv_test.go
+package server
+
+import (
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"testing"
+)
+
+func TestSoftResetOutDefaultOriginate(t *testing.T) {
+	_, peers := getTestPeers(t, "192.168.0.1")
+	peer := peers[0]
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+	peer.NeighborConf.RunningConf.DefaultOriginate = true
+	peer.NeighborConf.Neighbor.State.EnhancedRouteRefresh = true
+	peer.defaultRouteSent[protoFamily] = true
+
+	peer.SoftResetOut(protoFamily, make(map[uint32]map[*bgprib.Path][]*bgprib.Destination))
+	if !peer.defaultRouteSent[protoFamily] {
+		t.Fatal("Default route origination stopped after the route refresh")
+	}
+	if output := peer.NeighborConf.Neighbor.State.Queues.Output; output != 1 {
+		t.Fatal("Originated default route not sent again before the EoRR, updates sent:", output)
+	}
+
+	peer.defaultRouteSent[protoFamily] = false
+	peer.NeighborConf.RunningConf.DefaultOriginate = false
+	peer.SoftResetOut(protoFamily, make(map[uint32]map[*bgprib.Path][]*bgprib.Destination))
+	if output := peer.NeighborConf.Neighbor.State.Queues.Output; output != 1 {
+		t.Fatal("Default route sent without default origination, updates sent:", output)
+	}
+}
@@ -0,0 +1,385 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// evpn.go
+package server
+
+import (
+	"fmt"
+	"l3/bgp/config"
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"net"
+)
+
+const (
+	// Source of the EVPN routes originated for the local VTEPs and MACs of the VXLAN daemon
+	evpnLocalSrc = "EVPN-Local"
+)
+
+// evpnRoute is a VTEP or a MAC of a VNI. The local routes are originated as the EVPN routes, the remote routes
+// are learned from the best paths of the EVPN routes and sent to the VXLAN daemon.
+type evpnRoute struct {
+	routeType uint8
+	vni       uint32
+	mac       net.HardwareAddr
+	ip        net.IP
+	vtepIp    net.IP
+	nlri      *packet.EVPNNLRI
+}
+
+func (r *evpnRoute) equal(route *evpnRoute) bool {
+	return r.routeType == route.routeType && r.vni == route.vni && r.mac.String() == route.mac.String() &&
+		r.ip.Equal(route.ip) && r.vtepIp.Equal(route.vtepIp)
+}
+
+func (r *evpnRoute) ipStr() string {
+	if r.ip == nil {
+		return ""
+	}
+	return r.ip.String()
+}
+
+// evpnState is the EVPN state of the default VRF with the inclusive multicast routes of the local VTEPs, the
+// MAC/IP advertisement routes of the local MACs, and the remote VTEPs and MACs sent to the VXLAN daemon.
+type evpnState struct {
+	localVteps map[uint32]*evpnRoute
+	localMacs  map[uint32]map[string]*evpnRoute
+	remote     map[string]*evpnRoute
+}
+
+func newEVPNState() *evpnState {
+	return &evpnState{
+		localVteps: make(map[uint32]*evpnRoute),
+		localMacs:  make(map[uint32]map[string]*evpnRoute),
+		remote:     make(map[string]*evpnRoute),
+	}
+}
+
+func getEVPNProtoFamily() uint32 {
+	return packet.GetProtocolFamily(packet.AfiL2VPN, packet.SafiEVPN)
+}
+
+// getEVPNRouteDistinguisher returns the auto derived route distinguisher RouterId:VNI of a VNI, RFC 8365
+// section 5.1.2.1. The type 1 route distinguisher has a 2 byte value and uses the lower 16 bits of the VNI.
+func (s *BGPInstance) getEVPNRouteDistinguisher(vni uint32) (packet.RouteDistinguisher, error) {
+	return packet.ParseRouteDistinguisher(fmt.Sprintf("%s:%d", s.BgpConfig.Global.Config.RouterId, vni&0xffff))
+}
+
+// getEVPNRouteTarget returns the auto derived route target AS:VNI of a VNI, RFC 8365 section 5.1.2.1.
+func (s *BGPInstance) getEVPNRouteTarget(vni uint32) (uint64, error) {
+	return packet.ParseRouteTarget(fmt.Sprintf("%d:%d", s.BgpConfig.Global.Config.AS, vni))
+}
+
+// constructEVPNPath constructs the path of a local EVPN route with the VXLAN encapsulation, the route target
+// of the VNI and the VTEP as the next hop. The inclusive multicast routes have the PMSI tunnel attribute for
+// the ingress replication with the VNI as the label.
+func (s *BGPInstance) constructEVPNPath(route *evpnRoute) (*bgprib.Path, error) {
+	routeTarget, err := s.getEVPNRouteTarget(route.vni)
+	if err != nil {
+		return nil, err
+	}
+
+	pathAttrs := packet.ConstructPathAttrForConnRoutes(s.BgpConfig.Global.Config.AS)
+	packet.RemoveNextHop(&pathAttrs)
+	pathAttrs = packet.SetExtCommunities(pathAttrs, []uint64{routeTarget,
+		packet.NewEncapExtCommunity(packet.BGPEncapTunnelTypeVXLAN)})
+	if route.routeType == packet.BGPEVPNRouteTypeInclusiveMulticast {
+		pmsi := packet.NewBGPPathAttrPMSITunnel(packet.BGPPMSITunnelTypeIngressReplication, route.vni,
+			route.vtepIp)
+		pathAttrs = packet.AddPathAttrToPathAttrsByCode(pathAttrs, packet.BGPPathAttrTypePMSITunnel, pmsi)
+	}
+
+	protoFamily := getEVPNProtoFamily()
+	mpReach := packet.ConstructIPv6MPReachNLRI(protoFamily, route.vtepIp, nil, nil)
+	return bgprib.NewVPNPath(s.LocRib, nil, pathAttrs, mpReach, bgprib.RouteTypeStatic, []uint32{route.vni}), nil
+}
+
+// originateEVPNRoute adds the EVPN route of a local VTEP or MAC to the Loc-RIB.
+func (s *BGPInstance) originateEVPNRoute(route *evpnRoute) {
+	rd, err := s.getEVPNRouteDistinguisher(route.vni)
+	if err != nil {
+		s.logger.Err("EVPN route for VNI", route.vni, "not originated, error:", err)
+		return
+	}
+
+	if route.routeType == packet.BGPEVPNRouteTypeInclusiveMulticast {
+		route.nlri = packet.NewEVPNInclusiveMulticastRoute(rd, 0, route.vtepIp)
+	} else {
+		route.nlri = packet.NewEVPNMACIPAdvertisementRoute(rd, 0, route.mac, route.ip, route.vni)
+	}
+
+	path, err := s.constructEVPNPath(route)
+	if err != nil {
+		s.logger.Err("EVPN route", route.nlri.GetCIDR(), "not originated, error:", err)
+		route.nlri = nil
+		return
+	}
+
+	s.logger.Infof("Originate EVPN route %s", route.nlri.GetCIDR())
+	updated, withdrawn, updatedAddPaths := s.LocRib.ProcessVPNRoutes(evpnLocalSrc, []packet.NLRI{route.nlri}, nil,
+		path, getEVPNProtoFamily(), s.AddPathCount)
+	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+}
+
+// withdrawEVPNRoute removes the EVPN route of a local VTEP or MAC from the Loc-RIB.
+func (s *BGPInstance) withdrawEVPNRoute(route *evpnRoute) {
+	if route.nlri == nil {
+		return
+	}
+
+	s.logger.Infof("Withdraw EVPN route %s", route.nlri.GetCIDR())
+	updated, withdrawn, updatedAddPaths := s.LocRib.ProcessVPNRoutes(evpnLocalSrc, nil,
+		[]packet.NLRI{route.nlri}, nil, getEVPNProtoFamily(), s.AddPathCount)
+	route.nlri = nil
+	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+}
+
+// setupEVPN sets up the EVPN state of the default VRF. The local routes are originated again with the route
+// distinguisher and the route targets of the current global config.
+func (s *BGPInstance) setupEVPN() {
+	if !s.isDefaultVrf() {
+		return
+	}
+
+	if s.evpn == nil {
+		s.evpn = newEVPNState()
+		return
+	}
+
+	for _, route := range s.evpn.localVteps {
+		s.withdrawEVPNRoute(route)
+		s.originateEVPNRoute(route)
+	}
+	for _, macs := range s.evpn.localMacs {
+		for _, route := range macs {
+			s.withdrawEVPNRoute(route)
+			s.originateEVPNRoute(route)
+		}
+	}
+}
+
+func (s *BGPInstance) removeEVPNLocalMacs(vni uint32) {
+	for _, route := range s.evpn.localMacs[vni] {
+		s.withdrawEVPNRoute(route)
+	}
+	delete(s.evpn.localMacs, vni)
+}
+
+// processEVPNVtep originates the inclusive multicast route of a local VTEP. The routes of the VNI are
+// withdrawn when the VTEP is deleted.
+func (s *BGPInstance) processEVPNVtep(vtepInfo config.EVPNVtepInfo) {
+	if s.evpn == nil {
+		return
+	}
+
+	s.logger.Infof("EVPN local VTEP %+v", vtepInfo)
+	route, ok := s.evpn.localVteps[vtepInfo.Vni]
+	switch vtepInfo.Oper {
+	case config.EVPN_VTEP_CREATED:
+		vtepIp := net.ParseIP(vtepInfo.VtepIp)
+		if vtepIp == nil {
+			s.logger.Err("EVPN local VTEP for VNI", vtepInfo.Vni, "has invalid IP", vtepInfo.VtepIp)
+			return
+		}
+		if ok {
+			if route.vtepIp.Equal(vtepIp) {
+				return
+			}
+			s.withdrawEVPNRoute(route)
+		}
+
+		route = &evpnRoute{
+			routeType: packet.BGPEVPNRouteTypeInclusiveMulticast,
+			vni:       vtepInfo.Vni,
+			vtepIp:    vtepIp,
+		}
+		s.evpn.localVteps[vtepInfo.Vni] = route
+		s.originateEVPNRoute(route)
+
+	case config.EVPN_VTEP_DELETED:
+		if !ok {
+			return
+		}
+		delete(s.evpn.localVteps, vtepInfo.Vni)
+		s.withdrawEVPNRoute(route)
+		s.removeEVPNLocalMacs(vtepInfo.Vni)
+	}
+}
+
+// processEVPNMac originates or withdraws the MAC/IP advertisement route of a local MAC.
+func (s *BGPInstance) processEVPNMac(macInfo config.EVPNMacInfo) {
+	if s.evpn == nil {
+		return
+	}
+
+	s.logger.Infof("EVPN local MAC %+v", macInfo)
+	mac, err := net.ParseMAC(macInfo.Mac)
+	if err != nil {
+		s.logger.Err("EVPN local MAC for VNI", macInfo.Vni, "is invalid, error:", err)
+		return
+	}
+
+	route, ok := s.evpn.localMacs[macInfo.Vni][mac.String()]
+	switch macInfo.Oper {
+	case config.EVPN_MAC_CREATED:
+		newRoute := &evpnRoute{
+			routeType: packet.BGPEVPNRouteTypeMACIPAdvertisement,
+			vni:       macInfo.Vni,
+			mac:       mac,
+			ip:        net.ParseIP(macInfo.Ip),
+			vtepIp:    net.ParseIP(macInfo.VtepIp),
+		}
+		if newRoute.vtepIp == nil {
+			s.logger.Err("EVPN local MAC", macInfo.Mac, "has invalid VTEP IP", macInfo.VtepIp)
+			return
+		}
+		if ok {
+			if route.equal(newRoute) {
+				return
+			}
+			s.withdrawEVPNRoute(route)
+		}
+
+		if _, ok := s.evpn.localMacs[macInfo.Vni]; !ok {
+			s.evpn.localMacs[macInfo.Vni] = make(map[string]*evpnRoute)
+		}
+		s.evpn.localMacs[macInfo.Vni][mac.String()] = newRoute
+		s.originateEVPNRoute(newRoute)
+
+	case config.EVPN_MAC_DELETED:
+		if !ok {
+			return
+		}
+		delete(s.evpn.localMacs[macInfo.Vni], mac.String())
+		if len(s.evpn.localMacs[macInfo.Vni]) == 0 {
+			delete(s.evpn.localMacs, macInfo.Vni)
+		}
+		s.withdrawEVPNRoute(route)
+	}
+}
+
+// getEVPNRemoteRoute returns the remote VTEP or MAC of the best path of an EVPN route. Only the paths received
+// from the neighbors with the VXLAN encapsulation, RFC 8365 section 5.1.3, are sent to the VXLAN daemon.
+func getEVPNRemoteRoute(path *bgprib.Path, nlri *packet.EVPNNLRI, protoFamily uint32) *evpnRoute {
+	if path == nil || path.NeighborConf == nil ||
+		!packet.HasEncapTunnelType(path.PathAttrs, packet.BGPEncapTunnelTypeVXLAN) {
+		return nil
+	}
+
+	route := &evpnRoute{routeType: nlri.RouteType, nlri: nlri}
+	switch nlri.RouteType {
+	case packet.BGPEVPNRouteTypeInclusiveMulticast:
+		pmsi := packet.GetPMSITunnel(path.PathAttrs)
+		if pmsi == nil || pmsi.TunnelType != packet.BGPPMSITunnelTypeIngressReplication {
+			return nil
+		}
+		route.vni = pmsi.Label
+		route.vtepIp = pmsi.TunnelId
+		if route.vtepIp == nil || route.vtepIp.IsUnspecified() {
+			route.vtepIp = nlri.IP
+		}
+
+	case packet.BGPEVPNRouteTypeMACIPAdvertisement:
+		route.vni = nlri.GetVNI()
+		route.mac = nlri.MAC
+		route.ip = nlri.IP
+		route.vtepIp = path.GetNextHop(protoFamily)
+
+	default:
+		return nil
+	}
+
+	if route.vtepIp == nil {
+		return nil
+	}
+	return route
+}
+
+func (s *BGPInstance) addEVPNRemoteRoute(route *evpnRoute) {
+	s.logger.Infof("EVPN remote route %s VNI %d VTEP %s", route.nlri.GetCIDR(), route.vni, route.vtepIp)
+	s.evpn.remote[route.nlri.GetCIDR()] = route
+	if route.routeType == packet.BGPEVPNRouteTypeInclusiveMulticast {
+		s.evpnMgr.CreateRemoteVtep(route.vni, route.vtepIp.String())
+	} else {
+		s.evpnMgr.CreateRemoteMac(route.vni, route.mac.String(), route.ipStr(), route.vtepIp.String())
+	}
+}
+
+func (s *BGPInstance) removeEVPNRemoteRoute(cidr string) {
+	route, ok := s.evpn.remote[cidr]
+	if !ok {
+		return
+	}
+
+	s.logger.Infof("EVPN remove remote route %s VNI %d VTEP %s", cidr, route.vni, route.vtepIp)
+	delete(s.evpn.remote, cidr)
+	if route.routeType == packet.BGPEVPNRouteTypeInclusiveMulticast {
+		s.evpnMgr.DeleteRemoteVtep(route.vni, route.vtepIp.String())
+	} else {
+		s.evpnMgr.DeleteRemoteMac(route.vni, route.mac.String(), route.ipStr(), route.vtepIp.String())
+	}
+}
+
+// notifyEVPNRoutes sends the remote VTEPs and MACs of the updated and the withdrawn EVPN routes to the VXLAN
+// daemon. The remote route is removed when the best path is a local route.
+func (s *BGPInstance) notifyEVPNRoutes(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination,
+	withdrawn []*bgprib.Destination) {
+	if s.evpn == nil {
+		return
+	}
+
+	for protoFamily, pathDestMap := range updated {
+		if !packet.IsEVPNFamily(protoFamily) {
+			continue
+		}
+		for path, destinations := range pathDestMap {
+			for _, dest := range destinations {
+				if dest == nil {
+					continue
+				}
+				nlri, ok := dest.NLRI.(*packet.EVPNNLRI)
+				if !ok {
+					continue
+				}
+
+				cidr := nlri.GetCIDR()
+				route := getEVPNRemoteRoute(path, nlri, protoFamily)
+				if oldRoute, ok := s.evpn.remote[cidr]; ok && route != nil && oldRoute.equal(route) {
+					continue
+				}
+				s.removeEVPNRemoteRoute(cidr)
+				if route != nil {
+					s.addEVPNRemoteRoute(route)
+				}
+			}
+		}
+	}
+
+	for _, dest := range withdrawn {
+		if dest == nil || !packet.IsEVPNFamily(dest.GetProtocolFamily()) {
+			continue
+		}
+		s.removeEVPNRemoteRoute(dest.NLRI.GetCIDR())
+	}
+}
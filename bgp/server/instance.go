@@ -0,0 +1,210 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// instance.go
+package server
+
+import (
+	"l3/bgp/config"
+	"l3/bgp/fsm"
+	"l3/bgp/packet"
+	bgppolicy "l3/bgp/policy"
+	bgprib "l3/bgp/rib"
+	"net"
+	"sync"
+	"time"
+)
+
+// BGPInstance is the BGP instance of a VRF. Every instance has its own global config, neighbors, peer groups,
+// aggregates, Loc-RIB and redistribution, and processes its events in its own go routine.
+type BGPInstance struct {
+	*BGPServer
+	locRibPE         map[uint32]*bgppolicy.LocRibPolicyEngine
+	ribInPE          *bgppolicy.AdjRibPPolicyEngine
+	ribOutPE         *bgppolicy.AdjRibPPolicyEngine
+	listener         *net.TCPListener
+	listenerIPv6     *net.TCPListener
+	BgpConfig        config.Bgp
+	GlobalConfigCh   chan GlobalUpdate
+	AddPeerCh        chan PeerUpdate
+	RemPeerCh        chan config.NeighborConfig
+	AddPeerGroupCh   chan PeerGroupUpdate
+	RemPeerGroupCh   chan config.PeerGroupConfig
+	AddAggCh         chan AggUpdate
+	RemAggCh         chan config.BGPAggregate
+	AddRangeCh       chan config.ListenRange
+	RemRangeCh       chan config.ListenRange
+	PeerFSMConnCh    chan fsm.PeerFSMConn
+	PeerGRTimerCh    chan fsm.PeerGRTimerExp
+	PeerConnEstCh    chan string
+	PeerConnBrokenCh chan string
+	PeerCommandCh    chan config.PeerCommand
+	SoftResetCh      chan config.SoftResetCommand
+	ReachabilityCh   chan config.ReachabilityInfo
+	BGPPktSrcCh      chan *packet.BGPPktSrc
+	BfdCh            chan config.BfdInfo
+	IntfCh           chan config.IntfStateInfo
+	RoutesCh         chan *config.RouteCh
+	EVPNVtepCh       chan config.EVPNVtepInfo
+	EVPNMacCh        chan config.EVPNMacInfo
+	MRTDumpCh        chan config.MRTDumpCommand
+	RPKIUpdateCh     chan bool
+	acceptCh         chan *net.TCPConn
+	doneCh           chan bool
+	GlobalCfgDone    bool
+
+	NeighborMutex     sync.RWMutex
+	PeerMap           map[string]*Peer
+	ifaceNeighbors    map[config.PeerAddressType]map[int32]*Peer
+	Neighbors         []*Peer
+	LocRib            *bgprib.LocRib
+	ConnRoutesPath    *bgprib.Path
+	IfIndexPeerMap    map[int32][]string
+	RedistributionMap map[string]string
+	ifaceIP           net.IP
+	AddPathCount      int
+	grRestarting      bool
+	grRestartTimer    *time.Timer
+	dampeningTimer    *time.Timer
+	vpnQueue          *vpnQueue
+	vpn               *vpnState
+	evpn              *evpnState
+	updateGroups      map[string]*UpdateGroup
+	listenRanges      map[string]*listenRange
+	localLabels       map[uint32]map[string]*localLabel
+}
+
+func NewBGPInstance(server *BGPServer, vrf string) *BGPInstance {
+	instance := &BGPInstance{BGPServer: server}
+	instance.GlobalCfgDone = false
+	instance.GlobalConfigCh = make(chan GlobalUpdate)
+	instance.AddPeerCh = make(chan PeerUpdate)
+	instance.RemPeerCh = make(chan config.NeighborConfig)
+	instance.AddPeerGroupCh = make(chan PeerGroupUpdate)
+	instance.RemPeerGroupCh = make(chan config.PeerGroupConfig)
+	instance.AddAggCh = make(chan AggUpdate)
+	instance.RemAggCh = make(chan config.BGPAggregate)
+	instance.AddRangeCh = make(chan config.ListenRange)
+	instance.RemRangeCh = make(chan config.ListenRange)
+	instance.PeerFSMConnCh = make(chan fsm.PeerFSMConn, 50)
+	instance.PeerGRTimerCh = make(chan fsm.PeerGRTimerExp, 50)
+	instance.PeerConnEstCh = make(chan string)
+	instance.PeerConnBrokenCh = make(chan string)
+	instance.PeerCommandCh = make(chan config.PeerCommand)
+	instance.SoftResetCh = make(chan config.SoftResetCommand)
+	instance.ReachabilityCh = make(chan config.ReachabilityInfo)
+	instance.BGPPktSrcCh = make(chan *packet.BGPPktSrc)
+	instance.BfdCh = make(chan config.BfdInfo)
+	instance.IntfCh = make(chan config.IntfStateInfo)
+	instance.RoutesCh = make(chan *config.RouteCh)
+	instance.EVPNVtepCh = make(chan config.EVPNVtepInfo)
+	instance.EVPNMacCh = make(chan config.EVPNMacInfo)
+	instance.MRTDumpCh = make(chan config.MRTDumpCommand)
+	instance.RPKIUpdateCh = make(chan bool)
+	instance.acceptCh = make(chan *net.TCPConn)
+	instance.doneCh = make(chan bool)
+	instance.vpnQueue = newVPNQueue()
+
+	instance.NeighborMutex = sync.RWMutex{}
+	instance.PeerMap = make(map[string]*Peer)
+	instance.ifaceNeighbors = make(map[config.PeerAddressType]map[int32]*Peer)
+	instance.ifaceNeighbors[config.PeerAddressV4] = make(map[int32]*Peer)
+	instance.ifaceNeighbors[config.PeerAddressV6] = make(map[int32]*Peer)
+
+	instance.Neighbors = make([]*Peer, 0)
+	instance.updateGroups = make(map[string]*UpdateGroup)
+	instance.listenRanges = make(map[string]*listenRange)
+	instance.localLabels = make(map[uint32]map[string]*localLabel)
+	instance.initGlobalConfig()
+	instance.BgpConfig.Global.Config.Vrf = vrf
+	instance.LocRib = bgprib.NewLocRib(server.logger, server.routeMgr, server.stateDBMgr,
+		&instance.BgpConfig.Global.Config)
+	instance.IfIndexPeerMap = make(map[int32][]string)
+	instance.RedistributionMap = make(map[string]string)
+	instance.ifaceIP = nil
+	instance.AddPathCount = 0
+	instance.grRestarting = false
+	instance.grRestartTimer = time.NewTimer(time.Duration(config.BGPGRRestartTimeDefault) * time.Second)
+	instance.grRestartTimer.Stop()
+	instance.dampeningTimer = time.NewTimer(bgprib.DampeningReuseInterval)
+	instance.initPolicyEngines()
+	return instance
+}
+
+func (s *BGPInstance) getVrf() string {
+	return s.BgpConfig.Global.Config.Vrf
+}
+
+func (s *BGPInstance) isShutdown() bool {
+	select {
+	case <-s.doneCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// getConnectedRoutes returns the routes redistributed by RIBd in the VRF of the instance.
+func (s *BGPInstance) getConnectedRoutes() ([]*config.RouteInfo, []*config.RouteInfo) {
+	add, remove := s.routeMgr.GetRoutes()
+	if add == nil || remove == nil {
+		return add, remove
+	}
+
+	return filterRoutesByVrf(add, s.getVrf()), filterRoutesByVrf(remove, s.getVrf())
+}
+
+// Shutdown removes all the neighbors, aggregates and connected routes of the instance and stops listening for
+// the peer connections. The instance can't be used after it is shut down.
+func (s *BGPInstance) Shutdown() {
+	s.logger.Info("Shutdown BGP instance for VRF", s.getVrf())
+	close(s.doneCh)
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.listenerIPv6 != nil {
+		s.listenerIPv6.Close()
+	}
+
+	neighbors := make([]*Peer, len(s.Neighbors))
+	copy(neighbors, s.Neighbors)
+	for _, peer := range neighbors {
+		s.removePeer(peer.NeighborConf.Neighbor.Config)
+	}
+
+	for _, af := range s.BgpConfig.Afs {
+		for _, aggConf := range af.BgpAggs {
+			s.DeleteAgg(*aggConf)
+		}
+	}
+
+	if add, _ := s.getConnectedRoutes(); len(add) > 0 {
+		s.ProcessConnectedRoutes(make([]*config.RouteInfo, 0), add)
+	}
+
+	s.teardownVPN()
+	s.policyManager.RemovePolicyEngine(s.ribInPE)
+	s.policyManager.RemovePolicyEngine(s.ribOutPE)
+	s.grRestartTimer.Stop()
+	s.dampeningTimer.Stop()
+}
@@ -0,0 +1,159 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// labeled.go
+package server
+
+import (
+	"fmt"
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+)
+
+const (
+	labelsKeyPrefix = "labels:"
+)
+
+// localLabel is the local label bound to a labeled unicast route. The label is advertised to the peers with the
+// local address as the next hop and is swapped with the labels of the best path, or popped if the best path
+// has no labels.
+type localLabel struct {
+	label uint32
+	path  *bgprib.Path
+}
+
+// getLabeledPath returns the path with the label stack of the labeled unicast NLRI. The paths are cached by the
+// label stack in pathCache so that the NLRIs with the same labels share the same path.
+func (p *Peer) getLabeledPath(path *bgprib.Path, nlri packet.NLRI,
+	pathCache map[*bgprib.Path]map[string]*bgprib.Path) *bgprib.Path {
+	labeledPrefix, ok := nlri.(*packet.LabeledPrefix)
+	if path == nil || !ok {
+		return path
+	}
+
+	key := labelsKeyPrefix + fmt.Sprint(labeledPrefix.Labels)
+	if _, ok := pathCache[path]; !ok {
+		pathCache[path] = make(map[string]*bgprib.Path)
+	}
+	if newPath, ok := pathCache[path][key]; ok {
+		return newPath
+	}
+
+	newPath := path.Clone()
+	newPath.Labels = labeledPrefix.Labels
+	pathCache[path][key] = newPath
+	return newPath
+}
+
+// addLabeledUnicastRoutes adds the routes of the unicast families to the labeled unicast families, so the
+// locally originated routes are also advertised to the labeled unicast peers.
+func (s *BGPInstance) addLabeledUnicastRoutes(pfNLRI map[uint32][]packet.NLRI) {
+	for protoFamily, labeledFamily := range packet.UnicastFamilyToLabeledFamilyMap {
+		for _, nlri := range pfNLRI[protoFamily] {
+			pfNLRI[labeledFamily] = append(pfNLRI[labeledFamily], packet.NewLabeledPrefix(nil, nlri.GetIPPrefix()))
+		}
+	}
+}
+
+// updateLocalLabels binds the local labels to the best paths of the updated labeled unicast routes and
+// releases the local labels of the withdrawn routes.
+func (s *BGPInstance) updateLocalLabels(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination,
+	withdrawn []*bgprib.Destination) {
+	for protoFamily, pathDestMap := range updated {
+		if !packet.IsLabeledUnicastFamily(protoFamily) {
+			continue
+		}
+		for path, destinations := range pathDestMap {
+			for _, dest := range destinations {
+				if dest == nil {
+					continue
+				}
+				s.bindLocalLabel(protoFamily, dest, path)
+			}
+		}
+	}
+
+	for _, dest := range withdrawn {
+		if dest == nil || !packet.IsLabeledUnicastFamily(dest.GetProtocolFamily()) {
+			continue
+		}
+		s.releaseLocalLabel(dest.GetProtocolFamily(), dest.NLRI.GetCIDR())
+	}
+}
+
+// bindLocalLabel binds the local label of the route to the path. The label is allocated when the route does
+// not have one.
+func (s *BGPInstance) bindLocalLabel(protoFamily uint32, dest *bgprib.Destination, path *bgprib.Path) *localLabel {
+	cidr := dest.NLRI.GetCIDR()
+	if _, ok := s.localLabels[protoFamily]; !ok {
+		s.localLabels[protoFamily] = make(map[string]*localLabel)
+	}
+
+	binding, ok := s.localLabels[protoFamily][cidr]
+	if !ok {
+		label, err := s.labels.allocate()
+		if err != nil {
+			s.logger.Err("VRF", s.getVrf(), "local label not allocated for", cidr, "error:", err)
+			return nil
+		}
+		binding = &localLabel{label: label}
+		s.localLabels[protoFamily][cidr] = binding
+	}
+
+	if binding.path == path || path == nil {
+		return binding
+	}
+
+	binding.path = path
+	reachInfo := path.GetReachability(protoFamily)
+	if reachInfo == nil {
+		reachInfo = bgprib.NewReachabilityInfo("", 0, 0, 0)
+	}
+	cfg := dest.ConstructRouteConfig(path, reachInfo, packet.GetAddressLengthForFamily(protoFamily))
+	s.logger.Infof("VRF %s: bind local label %d to %s, labels %v", s.getVrf(), binding.label, cidr, path.Labels)
+	s.routeMgr.CreateLocalLabel(binding.label, cfg)
+	return binding
+}
+
+func (s *BGPInstance) releaseLocalLabel(protoFamily uint32, cidr string) {
+	binding, ok := s.localLabels[protoFamily][cidr]
+	if !ok {
+		return
+	}
+
+	s.logger.Infof("VRF %s: release local label %d of %s", s.getVrf(), binding.label, cidr)
+	s.routeMgr.DeleteLocalLabel(binding.label)
+	s.labels.release(binding.label)
+	delete(s.localLabels[protoFamily], cidr)
+}
+
+// getLabeledNLRI returns the labeled unicast NLRI of the route with the local label. The next hop of the labeled
+// unicast routes is always set to the local address, so the local label is advertised instead of the labels
+// of the path.
+func (s *BGPInstance) getLabeledNLRI(protoFamily uint32, dest *bgprib.Destination) packet.NLRI {
+	var labels []uint32
+	if binding := s.bindLocalLabel(protoFamily, dest, dest.LocRibPath); binding != nil {
+		labels = []uint32{binding.label}
+	}
+	return packet.NewLabeledPrefix(labels, dest.NLRI.GetIPPrefix())
+}
@@ -0,0 +1,176 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// listenrange.go
+package server
+
+import (
+	"l3/bgp/config"
+	"l3/bgp/packet"
+	"net"
+)
+
+// listenRange is a prefix that the dynamic neighbors are accepted from. The dynamic neighbors created from the
+// range are kept by their IP address.
+type listenRange struct {
+	config.ListenRange
+	peers map[string]*Peer
+}
+
+func newListenRange(rangeConf config.ListenRange) *listenRange {
+	return &listenRange{
+		ListenRange: rangeConf,
+		peers:       make(map[string]*Peer),
+	}
+}
+
+// addListenRange adds a listen range or updates an existing one. The dynamic neighbors of the old range are
+// removed, they are created again with the new config when they reconnect.
+func (s *BGPInstance) addListenRange(rangeConf config.ListenRange) {
+	if rangeConf.Prefix == nil {
+		s.logger.Err("Failed to add listen range, prefix is not set")
+		return
+	}
+
+	key := rangeConf.Prefix.String()
+	s.logger.Info("Add listen range", key, "peer group", rangeConf.PeerGroup, "max peers", rangeConf.MaxPeers)
+	if lRange, ok := s.listenRanges[key]; ok {
+		s.removeRangePeers(lRange)
+	}
+	s.listenRanges[key] = newListenRange(rangeConf)
+}
+
+func (s *BGPInstance) removeListenRange(rangeConf config.ListenRange) {
+	if rangeConf.Prefix == nil {
+		s.logger.Err("Failed to remove listen range, prefix is not set")
+		return
+	}
+
+	key := rangeConf.Prefix.String()
+	lRange, ok := s.listenRanges[key]
+	if !ok {
+		s.logger.Err("Listen range", key, "not found")
+		return
+	}
+
+	s.logger.Info("Remove listen range", key)
+	s.removeRangePeers(lRange)
+	delete(s.listenRanges, key)
+}
+
+func (s *BGPInstance) removeRangePeers(lRange *listenRange) {
+	for peerIP, peer := range lRange.peers {
+		s.removeDynamicPeer(peerIP, peer)
+	}
+}
+
+// getListenRange returns the longest listen range that the IP address belongs to.
+func (s *BGPInstance) getListenRange(ip net.IP) *listenRange {
+	var match *listenRange
+	matchLen := -1
+	for _, lRange := range s.listenRanges {
+		if !lRange.Prefix.Contains(ip) {
+			continue
+		}
+
+		if ones, _ := lRange.Prefix.Mask.Size(); ones > matchLen {
+			match = lRange
+			matchLen = ones
+		}
+	}
+
+	return match
+}
+
+// createDynamicPeer creates a neighbor for a connection from an IP address that is not configured as a
+// neighbor. The neighbor inherits the config of the peer group of the listen range that the address belongs
+// to. It returns nil if the address is not in a listen range or the range reached its max number of peers.
+func (s *BGPInstance) createDynamicPeer(ip net.IP) *Peer {
+	lRange := s.getListenRange(ip)
+	if lRange == nil {
+		s.logger.Info("Address", ip, "is not in a listen range")
+		return nil
+	}
+
+	if lRange.MaxPeers != 0 && uint32(len(lRange.peers)) >= lRange.MaxPeers {
+		s.logger.Info("Listen range", lRange.Prefix, "reached max peers", lRange.MaxPeers, ", can't accept",
+			"connection from", ip)
+		return nil
+	}
+
+	if s.isBGPGlobalDisabled() {
+		s.logger.Info("BGP global", s.getVrf(), "is disabled, not creating dynamic neighbor", ip)
+		return nil
+	}
+
+	peerAddrType := config.PeerAddressV4
+	if ip.To4() == nil {
+		peerAddrType = config.PeerAddressV6
+	}
+	protoFamily, _ := packet.GetProtocolFamilyFromPeerAddrType(peerAddrType)
+	group, ok := s.BgpConfig.PeerGroups[protoFamily][lRange.PeerGroup]
+	if !ok {
+		s.logger.Info("Peer group", lRange.PeerGroup, "of listen range", lRange.Prefix, "not created yet, can't",
+			"accept connection from", ip)
+		return nil
+	}
+
+	neighConf := config.NeighborConfig{
+		Vrf:             s.getVrf(),
+		NeighborAddress: ip,
+		IfIndex:         -1,
+		PeerGroup:       lRange.PeerGroup,
+	}
+	neighConf.PeerAddressType = peerAddrType
+
+	s.logger.Info("Add dynamic neighbor, ip:", ip, "listen range:", lRange.Prefix)
+	peer := NewPeer(s, s.LocRib, &s.BgpConfig.Global.Config, &group.Config, neighConf)
+	peer.NeighborConf.LocalRestarting = s.grRestarting
+	peer.NeighborConf.ListenRange = lRange.Prefix.String()
+	peer.NeighborConf.AllowedPeerAS = lRange.AllowedAS
+	s.PeerMap[ip.String()] = peer
+	lRange.peers[ip.String()] = peer
+
+	s.NeighborMutex.Lock()
+	s.addPeerToList(peer)
+	s.NeighborMutex.Unlock()
+	peer.Init()
+	return peer
+}
+
+// removeDynamicPeer removes a dynamic neighbor and the paths received from it. It's called when the session
+// of the neighbor goes down or the listen range is removed.
+func (s *BGPInstance) removeDynamicPeer(peerIP string, peer *Peer) {
+	s.logger.Info("Remove dynamic neighbor, ip:", peerIP, "listen range:", peer.NeighborConf.ListenRange)
+	s.NeighborMutex.Lock()
+	s.removePeerFromList(peer)
+	s.NeighborMutex.Unlock()
+	delete(s.PeerMap, peerIP)
+	peer.Cleanup()
+	s.ProcessRemoveNeighbor(peerIP, peer)
+	s.LocRib.RemoveDampeningInfoForNeighbor(peerIP)
+
+	if lRange, ok := s.listenRanges[peer.NeighborConf.ListenRange]; ok && lRange.peers[peerIP] == peer {
+		delete(lRange.peers, peerIP)
+	}
+}
@@ -0,0 +1,194 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// mrt.go
+package server
+
+import (
+	"bytes"
+	"l3/bgp/baseobjects"
+	"l3/bgp/config"
+	"l3/bgp/mrt"
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"sort"
+	"time"
+)
+
+func getMRTDir(mrtConf config.MRTConfig) string {
+	if mrtConf.Directory == "" {
+		return config.MRTDumpDirDefault
+	}
+	return mrtConf.Directory
+}
+
+// processMRTConfig starts or stops the recording of the received UPDATE messages and the periodic RIB dumps.
+func (s *BGPServer) processMRTConfig(mrtConf config.MRTConfig) {
+	s.logger.Infof("MRT config %+v", mrtConf)
+	s.mrtConfig = mrtConf
+	if mrtConf.RecordUpdates {
+		rotateInterval := mrtConf.RotateInterval
+		if rotateInterval == 0 {
+			rotateInterval = config.MRTRotateIntervalDefault
+		}
+		s.mrtRecorder.Start(getMRTDir(mrtConf), time.Duration(rotateInterval)*time.Second)
+	} else {
+		s.mrtRecorder.Stop()
+	}
+
+	s.mrtDumpTimer.Stop()
+	if mrtConf.RIBDumpInterval > 0 {
+		s.mrtDumpTimer.Reset(time.Duration(mrtConf.RIBDumpInterval) * time.Second)
+	}
+}
+
+// processMRTDump requests the instances to dump their Loc-RIB. The RIBs are dumped in the instance goroutines
+// that own them.
+func (s *BGPServer) processMRTDump(dumpCmd config.MRTDumpCommand) {
+	dumpCmd.Directory = getMRTDir(s.mrtConfig)
+	if dumpCmd.Vrf == "" {
+		for _, inst := range s.getActiveInstances() {
+			inst.MRTDumpCh <- dumpCmd
+		}
+		return
+	}
+
+	if inst := s.getActiveInstance(getVrfName(dumpCmd.Vrf)); inst != nil {
+		inst.MRTDumpCh <- dumpCmd
+	} else {
+		s.logger.Err("MRT dump failed, BGP instance for VRF", dumpCmd.Vrf, "is not active")
+	}
+}
+
+type mrtPeerIndex struct {
+	peers   []mrt.PeerEntry
+	indexes map[*base.NeighborConf]uint16
+}
+
+// getIndex returns the index of the peer of the path in the PEER_INDEX_TABLE. The locally originated paths use
+// the index 0, which is the local router.
+func (m *mrtPeerIndex) getIndex(path *bgprib.Path) uint16 {
+	neighborConf := path.NeighborConf
+	if path.IsLocal() || neighborConf == nil {
+		return 0
+	}
+
+	if idx, ok := m.indexes[neighborConf]; ok {
+		return idx
+	}
+
+	idx := uint16(len(m.peers))
+	m.indexes[neighborConf] = idx
+	m.peers = append(m.peers, mrt.PeerEntry{
+		BGPId:   neighborConf.BGPId,
+		Address: neighborConf.Neighbor.NeighborAddress,
+		AS:      neighborConf.RunningConf.PeerAS,
+	})
+	return idx
+}
+
+func encodeMRTPathAttrs(path *bgprib.Path, protoFamily uint32) []byte {
+	var buf bytes.Buffer
+	for _, pa := range path.PathAttrs {
+		if pa.GetCode() == packet.BGPPathAttrTypeMPReachNLRI || pa.GetCode() == packet.BGPPathAttrTypeMPUnreachNLRI {
+			continue
+		}
+		if pkt, err := pa.Encode(); err == nil {
+			buf.Write(pkt)
+		}
+	}
+
+	afi, _ := packet.GetAfiSafi(protoFamily)
+	if afi == packet.AfiIP6 {
+		buf.Write(mrt.EncodeMPReachNextHop(path.GetNextHop(protoFamily)))
+	}
+	return buf.Bytes()
+}
+
+type mrtDestSlice []*bgprib.Destination
+
+func (d mrtDestSlice) Len() int {
+	return len(d)
+}
+
+func (d mrtDestSlice) Less(i, j int) bool {
+	if cmp := bytes.Compare(d[i].NLRI.GetPrefix().To16(), d[j].NLRI.GetPrefix().To16()); cmp != 0 {
+		return cmp < 0
+	}
+	return d[i].NLRI.GetLength() < d[j].NLRI.GetLength()
+}
+
+func (d mrtDestSlice) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+}
+
+// dumpRIB writes the IPv4 and IPv6 unicast routes of the Loc-RIB to a TABLE_DUMP_V2 file. The view name of
+// the dump is the VRF.
+func (s *BGPInstance) dumpRIB(dir string) {
+	now := time.Now()
+	vrf := s.getVrf()
+	peerIndex := &mrtPeerIndex{
+		peers: []mrt.PeerEntry{mrt.PeerEntry{
+			BGPId: s.BgpConfig.Global.Config.RouterId,
+			AS:    s.BgpConfig.Global.Config.AS,
+		}},
+		indexes: make(map[*base.NeighborConf]uint16),
+	}
+
+	locRib := s.LocRib.GetLocRib()
+	records := make([][]byte, 1)
+	seqNum := uint32(0)
+	for _, afi := range []packet.AFI{packet.AfiIP, packet.AfiIP6} {
+		protoFamily := packet.GetProtocolFamily(afi, packet.SafiUnicast)
+		dests := make(mrtDestSlice, 0)
+		for _, destList := range locRib[protoFamily] {
+			dests = append(dests, destList...)
+		}
+		sort.Sort(dests)
+
+		for _, dest := range dests {
+			path := dest.LocRibPath
+			originated := now
+			if route := dest.GetLocRibPathRoute(); route != nil {
+				originated = route.GetTime()
+			}
+			entry := mrt.RIBEntry{
+				PeerIndex:      peerIndex.getIndex(path),
+				OriginatedTime: originated,
+				PathAttrs:      encodeMRTPathAttrs(path, protoFamily),
+			}
+			records = append(records, mrt.NewRIBRecord(now, seqNum, dest.NLRI.GetPrefix(), dest.NLRI.GetLength(),
+				[]mrt.RIBEntry{entry}))
+			seqNum++
+		}
+	}
+	records[0] = mrt.NewPeerIndexTable(now, s.BgpConfig.Global.Config.RouterId, vrf, peerIndex.peers)
+
+	writer := mrt.NewFileWriter(dir, mrt.MRTRIBPrefix+"."+vrf, 0)
+	defer writer.Close()
+	if err := writer.Write(records...); err != nil {
+		s.logger.Err("MRT dump of VRF", vrf, "failed with error", err)
+		return
+	}
+	s.logger.Infof("MRT dump of VRF %s wrote %d routes to %s", vrf, seqNum, dir)
+}
@@ -27,6 +27,7 @@ package server
 import (
 	_ "fmt"
 	"l3/bgp/baseobjects"
+	"l3/bgp/bmp"
 	"l3/bgp/config"
 	"l3/bgp/fsm"
 	"l3/bgp/packet"
@@ -48,19 +49,21 @@ const (
 )
 
 type AdjRIBPolicyParams struct {
-	CreateType      int
-	DeleteType      int
-	Route           *bgprib.AdjRIBRoute
-	Peer            *Peer
-	Accept          int
-	PolicyEngine    *bgppolicy.AdjRibPPolicyEngine
-	updated         *(map[uint32]map[*bgprib.Path][]*bgprib.Destination)
-	withdrawn       *([]*bgprib.Destination)
-	updatedAddPaths *([]*bgprib.Destination)
+	CreateType       int
+	DeleteType       int
+	Route            *bgprib.AdjRIBRoute
+	Path             *bgprib.Path
+	Peer             *Peer
+	Accept           int
+	CommunityActions []*bgppolicy.CommunityAction
+	PolicyEngine     *bgppolicy.AdjRibPPolicyEngine
+	updated          *(map[uint32]map[*bgprib.Path][]*bgprib.Destination)
+	withdrawn        *([]*bgprib.Destination)
+	updatedAddPaths  *([]*bgprib.Destination)
 }
 
 type Peer struct {
-	server       *BGPServer
+	server       *BGPInstance
 	logger       *logging.Writer
 	locRib       *bgprib.LocRib
 	NeighborConf *base.NeighborConf
@@ -69,9 +72,14 @@ type Peer struct {
 	ifIdx        int32
 	ribIn        map[uint32]map[string]*bgprib.AdjRIBRoute
 	ribOut       map[uint32]map[string]*bgprib.AdjRIBRoute
+	updateGroup  *UpdateGroup
+
+	// State of the default route origination and the conditional advertisement
+	defaultRouteSent map[uint32]bool
+	condAdvMet       bool
 }
 
-func NewPeer(server *BGPServer, locRib *bgprib.LocRib, globalConf *config.GlobalConfig,
+func NewPeer(server *BGPInstance, locRib *bgprib.LocRib, globalConf *config.GlobalConfig,
 	peerGroup *config.PeerGroupConfig, peerConf config.NeighborConfig) *Peer {
 	server.logger.Info("NewPeer - ip:", peerConf.NeighborAddress, "ifIndex:", peerConf.IfIndex)
 
@@ -83,6 +91,8 @@ func NewPeer(server *BGPServer, locRib *bgprib.LocRib, globalConf *config.Global
 		ifIdx:  -1,
 		ribIn:  make(map[uint32]map[string]*bgprib.AdjRIBRoute),
 		ribOut: make(map[uint32]map[string]*bgprib.AdjRIBRoute),
+
+		defaultRouteSent: make(map[uint32]bool),
 	}
 
 	peer.NeighborConf = base.NewNeighborConf(peer.logger, globalConf, peerGroup, peerConf)
@@ -100,7 +110,7 @@ func NewPeer(server *BGPServer, locRib *bgprib.LocRib, globalConf *config.Global
 	}
 
 	peer.fsmManager = fsm.NewFSMManager(peer.logger, peer.NeighborConf, server.BGPPktSrcCh,
-		server.PeerFSMConnCh, server.ReachabilityCh)
+		server.PeerFSMConnCh, server.PeerGRTimerCh, server.ReachabilityCh)
 	return &peer
 }
 
@@ -300,11 +310,12 @@ func (p *Peer) Init() {
 	if p.fsmManager == nil {
 		p.logger.Infof("Init - Instantiating new FSM Manager for neighbor %s", p.NeighborConf.Neighbor.NeighborAddress)
 		fsmMgr = fsm.NewFSMManager(p.logger, p.NeighborConf, p.server.BGPPktSrcCh,
-			p.server.PeerFSMConnCh, p.server.ReachabilityCh)
+			p.server.PeerFSMConnCh, p.server.PeerGRTimerCh, p.server.ReachabilityCh)
 	} else {
 		fsmMgr = p.fsmManager
 	}
 
+	fsmMgr.SetMsgRecorder(p.server.mrtRecorder)
 	p.clearRibOut()
 	go fsmMgr.Init()
 	runtime.Gosched()
@@ -334,6 +345,8 @@ func (p *Peer) Cleanup() {
 	}
 
 	p.active = false
+	p.bmpPeerDeconfigured()
+	p.server.leaveUpdateGroup(p)
 
 	if p.NeighborConf.RunningConf.AdjRIBInFilter != "" {
 		p.RemoveAdjRIBFilter(p.server.ribInPE, p.NeighborConf.RunningConf.AdjRIBInFilter, bgprib.AdjRIBDirIn)
@@ -409,15 +422,22 @@ func (p *Peer) BfdFaultCleared() {
 	p.fsmManager.BfdStatusCh <- true
 }
 
-func (p *Peer) getAddPathsMaxTx() int {
-	return int(p.NeighborConf.Neighbor.State.AddPathsMaxTx)
+func (p *Peer) getAddPathCount() int {
+	return p.NeighborConf.GetAddPathCount()
+}
+
+func (p *Peer) isAddPathsTxEnabled(protoFamily uint32) bool {
+	return p.NeighborConf.GetAddPathsTx(protoFamily).TxMode != ""
 }
 
 func (p *Peer) clearRibOut() {
+	p.server.leaveUpdateGroup(p)
 	p.ribIn = nil
 	p.ribOut = nil
 	p.ribIn = make(map[uint32]map[string]*bgprib.AdjRIBRoute)
 	p.ribOut = make(map[uint32]map[string]*bgprib.AdjRIBRoute)
+	p.defaultRouteSent = make(map[uint32]bool)
+	p.condAdvMet = false
 	p.initAdjRIBTables()
 }
 
@@ -449,7 +469,7 @@ func (p *Peer) ProcessBfd(add bool) {
 
 }
 
-func (p *Peer) PeerConnEstablished(conn *net.Conn) {
+func (p *Peer) PeerConnEstablished(conn *net.Conn, sentOpenMsg *packet.BGPMessage, recvOpenMsg *packet.BGPMessage) {
 	host, _, err := net.SplitHostPort((*conn).LocalAddr().String())
 	if err != nil {
 		p.logger.Errf("Neighbor %s: Can't find local address from the peer connection: %s",
@@ -460,12 +480,18 @@ func (p *Peer) PeerConnEstablished(conn *net.Conn) {
 	hostSplit := strings.Split(host, "%")
 	host = hostSplit[0]
 	p.NeighborConf.Neighbor.Transport.Config.LocalAddress = net.ParseIP(host)
+	if p.NeighborConf.IsDynamic() && recvOpenMsg != nil {
+		// The peer AS of a dynamic neighbor is learned from the OPEN message that the FSM already validated
+		p.NeighborConf.SetDynamicPeerAS(packet.GetPeerAS(recvOpenMsg.Body.(*packet.BGPOpen)))
+	}
 	p.NeighborConf.PeerConnEstablished()
 	p.clearRibOut()
+	p.bmpPeerUp(conn, sentOpenMsg, recvOpenMsg)
 	//p.Server.PeerConnEstCh <- p.Neighbor.NeighborAddress.String()
 }
 
-func (p *Peer) PeerConnBroken(fsmCleanup bool) {
+func (p *Peer) PeerConnBroken(fsmCleanup bool, notifMsg *packet.BGPMessage, notifMsgSent bool) {
+	p.bmpPeerDown(notifMsg, notifMsgSent)
 	if p.NeighborConf.Neighbor.Transport.Config.LocalAddress != nil {
 		p.NeighborConf.Neighbor.Transport.Config.LocalAddress = nil
 		//p.Server.PeerConnBrokenCh <- p.Neighbor.NeighborAddress.String()
@@ -530,7 +556,7 @@ func (p *Peer) processWithdraws(protoFamily uint32, nlris *[]packet.NLRI) {
 		if !route.DoesPathsExist() {
 			p.logger.Infof("Neighbor %s: remove nlri %s protocol family %s from RIB-In",
 				p.NeighborConf.RunningConf.NeighborAddress, ip, protoFamily)
-			p.checkRIBInFilter(nlri, route, false)
+			p.checkRIBInFilter(nlri, route, nil, false)
 			delete(p.ribIn[protoFamily], ip)
 		}
 
@@ -548,11 +574,16 @@ func (p *Peer) processWithdraws(protoFamily uint32, nlris *[]packet.NLRI) {
 	(*nlris) = (*nlris)[:idx]
 }
 
-func (p *Peer) checkAdjRIBFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, pe *bgppolicy.AdjRibPPolicyEngine,
-	policyDir int, create bool) bool {
+func (p *Peer) checkAdjRIBFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, path *bgprib.Path,
+	pe *bgppolicy.AdjRibPPolicyEngine, policyDir int, create bool) (bool, []*bgppolicy.CommunityAction) {
 	if route != nil {
 		if len(route.PolicyList) > 0 {
-			return true
+			if !create {
+				return true, nil
+			}
+			// The route is advertised again, run the policy again so that the community conditions are matched
+			// against the new path and its community actions are returned
+			p.resetAdjRIBRoutePolicyState(route, pe)
 		}
 
 		peEntity := utilspolicy.PolicyEngineFilterEntityParams{
@@ -563,6 +594,7 @@ func (p *Peer) checkAdjRIBFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, pe
 		callbackInfo := &AdjRIBPolicyParams{
 			Peer:  p,
 			Route: route,
+			Path:  path,
 		}
 
 		if create {
@@ -578,32 +610,64 @@ func (p *Peer) checkAdjRIBFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, pe
 		pe.PolicyEngine.PolicyEngineFilter(peEntity, policyDir, callbackInfo)
 		p.logger.Infof("checkAdjRIBFilter - NLRI %s policylist %v hit %v after applying create policy, callbackInfo=%+v",
 			nlri.GetCIDR(), route.PolicyList, route.PolicyHitCounter, callbackInfo)
-		return callbackInfo.Accept == Accept
+		return callbackInfo.Accept == Accept, callbackInfo.CommunityActions
 	}
-	return false
+	return false, nil
 }
 
-func (p *Peer) checkRIBInFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, create bool) bool {
+func (p *Peer) checkRIBInFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, path *bgprib.Path, create bool) (
+	bool, []*bgppolicy.CommunityAction) {
 	if p.NeighborConf.Neighbor.Config.AdjRIBInFilter == "" {
 		p.logger.Debugf("Peer %s - RIB In filter is not set", p.NeighborConf.Neighbor.NeighborAddress)
-		return true
+		return true, nil
 	}
 
-	return p.checkAdjRIBFilter(nlri, route, p.server.ribInPE, policyCommonDefs.PolicyPath_Import, create)
+	return p.checkAdjRIBFilter(nlri, route, path, p.server.ribInPE, policyCommonDefs.PolicyPath_Import, create)
 }
 
-func (p *Peer) checkRIBOutFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, create bool) bool {
+func (p *Peer) checkRIBOutFilter(nlri packet.NLRI, route *bgprib.AdjRIBRoute, path *bgprib.Path, create bool) (
+	bool, []*bgppolicy.CommunityAction) {
 	if p.NeighborConf.Neighbor.Config.AdjRIBOutFilter == "" {
 		p.logger.Debugf("Peer %s - RIB Out filter is not set", p.NeighborConf.Neighbor.NeighborAddress)
-		return true
+		return true, nil
+	}
+
+	return p.checkAdjRIBFilter(nlri, route, path, p.server.ribOutPE, policyCommonDefs.PolicyPath_Export, create)
+}
+
+// getCommunityActionsPath returns the path with the community actions applied. The paths are cached by the
+// actions key in pathCache so that the NLRIs modified by the same actions share the same path.
+func (p *Peer) getCommunityActionsPath(path *bgprib.Path, actions []*bgppolicy.CommunityAction,
+	pathCache map[*bgprib.Path]map[string]*bgprib.Path) *bgprib.Path {
+	if path == nil || len(actions) == 0 {
+		return path
+	}
+
+	key := bgppolicy.GetCommunityActionsKey(actions)
+	if _, ok := pathCache[path]; !ok {
+		pathCache[path] = make(map[string]*bgprib.Path)
+	}
+	if newPath, ok := pathCache[path][key]; ok {
+		return newPath
 	}
 
-	return p.checkAdjRIBFilter(nlri, route, p.server.ribOutPE, policyCommonDefs.PolicyPath_Export, create)
+	pathAttrs := path.PathAttrs
+	for _, action := range actions {
+		pathAttrs = action.Apply(pathAttrs)
+	}
+	newPath := path.Clone()
+	newPath.PathAttrs = pathAttrs
+	pathCache[path][key] = newPath
+	return newPath
 }
 
-func (p *Peer) processUpdates(protoFamily uint32, nlris *[]packet.NLRI, path *bgprib.Path) {
+func (p *Peer) processUpdates(protoFamily uint32, nlris *[]packet.NLRI,
+	path *bgprib.Path) map[*bgprib.Path][]packet.NLRI {
 	var ok bool
 	var route *bgprib.AdjRIBRoute
+	modifiedPaths := make(map[*bgprib.Path][]packet.NLRI)
+	pathCache := make(map[*bgprib.Path]map[string]*bgprib.Path)
+	rejected := uint64(0)
 	total := len(*nlris)
 	last := total - 1
 	idx := 0
@@ -626,22 +690,39 @@ func (p *Peer) processUpdates(protoFamily uint32, nlris *[]packet.NLRI, path *bg
 			p.logger.Infof("Neighbor %s: add nlri %s protocol family %d",
 				p.NeighborConf.RunningConf.NeighborAddress, ip, protoFamily)
 		}
-		route.AddPath(nlri.GetPathId(), path)
+		nlriPath := p.getLabeledPath(path, nlri, pathCache)
+		route.AddPath(nlri.GetPathId(), nlriPath)
 		p.logger.Infof("Neighbor %s: add path id %d for nlri %s protocol family %d to RIB-In %+v",
 			p.NeighborConf.RunningConf.NeighborAddress, nlri.GetPathId(), ip, protoFamily, p.ribIn[protoFamily])
 
 		if ok && !route.Accept {
 			p.logger.Infof("Neighbor %s: nlri %s is already filtered", p.NeighborConf.RunningConf.NeighborAddress, ip)
+			rejected++
 			(*nlris)[idx] = (*nlris)[last]
 			(*nlris)[last] = nil
 			last--
 			continue
 		}
 
-		accept := p.checkRIBInFilter(nlri, route, true)
+		accept, actions := p.checkRIBInFilter(nlri, route, nlriPath, true)
 		route.Accept = accept
 		if !accept {
 			p.logger.Infof("Neighbor %s: filter nlri %s", p.NeighborConf.RunningConf.NeighborAddress, ip)
+			rejected++
+			(*nlris)[idx] = (*nlris)[last]
+			(*nlris)[last] = nil
+			last--
+			continue
+		}
+
+		if len(actions) > 0 {
+			p.logger.Infof("Neighbor %s: apply community actions %s to nlri %s",
+				p.NeighborConf.RunningConf.NeighborAddress, bgppolicy.GetCommunityActionsKey(actions), ip)
+		}
+		newPath := p.getValidatedPath(p.getCommunityActionsPath(nlriPath, actions, pathCache),
+			p.server.getValidationState(protoFamily, nlri, path), pathCache)
+		if newPath != path {
+			modifiedPaths[newPath] = append(modifiedPaths[newPath], nlri)
 			(*nlris)[idx] = (*nlris)[last]
 			(*nlris)[last] = nil
 			last--
@@ -650,6 +731,8 @@ func (p *Peer) processUpdates(protoFamily uint32, nlris *[]packet.NLRI, path *bg
 		idx++
 	}
 	(*nlris) = (*nlris)[:idx]
+	p.bmpIncrementStat(bmp.BMPStatPrefixesRejected, rejected)
+	return modifiedPaths
 }
 
 func (p *Peer) AddRouteNLRIs(route *bgprib.AdjRIBRoute, pathNLRIs map[*bgprib.Path]map[uint32]*bgprib.FilteredRoutes,
@@ -721,6 +804,214 @@ func (p *Peer) AdjRIBInPolicyUpdated(adjRibDir bgprib.AdjRIBDir, data interface{
 	return updated, withdrawn, updatedAddPaths
 }
 
+// resetAdjRIBRoutePolicyState clears the policies applied to an Adj-RIB route so that the next call to
+// checkAdjRIBFilter runs the policy engine again.
+func (p *Peer) resetAdjRIBRoutePolicyState(route *bgprib.AdjRIBRoute, pe *bgppolicy.AdjRibPPolicyEngine) {
+	for _, policy := range route.PolicyList {
+		pe.UpdateAdjRIBPolicyRouteMap(route, policy, bgppolicy.Del)
+	}
+	bgppolicy.UpdateAdjRIBRoutePolicyState(route, bgppolicy.DelAll, "", "")
+}
+
+// SoftResetIn re-applies the RIB-In policy and the origin validation to all the routes stored in the RIB-In
+// of the peer.
+func (p *Peer) SoftResetIn() (map[uint32]map[*bgprib.Path][]*bgprib.Destination, []*bgprib.Destination,
+	[]*bgprib.Destination) {
+	p.logger.Infof("Neighbor %s: soft reset in", p.NeighborConf.Neighbor.NeighborAddress)
+	if p.fsmManager != nil && p.NeighborConf.Neighbor.State.RouteRefresh {
+		// Ask the peer to send the routes again, the routes stored in the RIB-In are re-applied below
+		for protoFamily, ok := range p.NeighborConf.AfiSafiMap {
+			if ok {
+				afi, safi := packet.GetAfiSafi(protoFamily)
+				p.fsmManager.SendRouteRefreshMsg(afi, safi, packet.BGPRouteRefreshNormal)
+			}
+		}
+	}
+
+	filteredRoutes := make(map[*bgprib.Path]map[uint32]*bgprib.FilteredRoutes)
+	pathCache := make(map[*bgprib.Path]map[string]*bgprib.Path)
+	for _, prefixRouteMap := range p.ribIn {
+		for _, route := range prefixRouteMap {
+			if route == nil {
+				continue
+			}
+
+			p.resetAdjRIBRoutePolicyState(route, p.server.ribInPE)
+			accept, actions := p.checkRIBInFilter(route.NLRI, route, nil, true)
+			if accept {
+				for pathId, path := range route.PathMap {
+					newPath := p.getValidatedPath(p.getCommunityActionsPath(path, actions, pathCache),
+						p.server.getValidationState(route.ProtocolFamily, route.NLRI, path), pathCache)
+					if _, ok := filteredRoutes[newPath]; !ok {
+						filteredRoutes[newPath] = make(map[uint32]*bgprib.FilteredRoutes)
+					}
+					if _, ok := filteredRoutes[newPath][route.ProtocolFamily]; !ok {
+						filteredRoutes[newPath][route.ProtocolFamily] = bgprib.NewFilteredRoutes()
+					}
+					nlris := filteredRoutes[newPath][route.ProtocolFamily]
+					nlris.Add = append(nlris.Add, packet.ConstructNLRIFromPathIdAndNLRI(route.NLRI, pathId))
+				}
+			} else if route.Accept {
+				filteredRoutes = p.AddRouteNLRIs(route, filteredRoutes, false)
+			}
+			route.Accept = accept
+		}
+	}
+
+	updated, withdrawn, updatedAddPaths, addedAllPrefixes := p.locRib.ProcessFilteredRoutes(p.NeighborConf,
+		filteredRoutes, p.server.AddPathCount)
+	if !addedAllPrefixes {
+		p.MaxPrefixesExceeded()
+	}
+
+	return updated, withdrawn, updatedAddPaths
+}
+
+// ProcessEnhancedRouteRefresh handles the BoRR and EoRR markers of an enhanced route refresh (RFC 7313). The
+// paths of the protocol family in the RIB-In are marked stale on BoRR, and the paths that were not advertised
+// again by the peer are removed on EoRR.
+func (p *Peer) ProcessEnhancedRouteRefresh(protoFamily uint32, subType uint8) (
+	map[uint32]map[*bgprib.Path][]*bgprib.Destination, []*bgprib.Destination, []*bgprib.Destination) {
+	filteredRoutes := make(map[*bgprib.Path]map[uint32]*bgprib.FilteredRoutes)
+	switch subType {
+	case packet.BGPRouteRefreshBoRR:
+		p.logger.Infof("Neighbor %s: Received BoRR for protocol family %d, mark RIB-In routes stale",
+			p.NeighborConf.Neighbor.NeighborAddress, protoFamily)
+		for _, route := range p.ribIn[protoFamily] {
+			route.MarkPathsStale()
+		}
+
+	case packet.BGPRouteRefreshEoRR:
+		p.logger.Infof("Neighbor %s: Received EoRR for protocol family %d, remove stale RIB-In routes",
+			p.NeighborConf.Neighbor.NeighborAddress, protoFamily)
+		for ip, route := range p.ribIn[protoFamily] {
+			for _, pathId := range route.GetStalePathIds() {
+				if route.Accept {
+					path := route.GetPath(pathId)
+					if _, ok := filteredRoutes[path]; !ok {
+						filteredRoutes[path] = make(map[uint32]*bgprib.FilteredRoutes)
+					}
+					if _, ok := filteredRoutes[path][protoFamily]; !ok {
+						filteredRoutes[path][protoFamily] = bgprib.NewFilteredRoutes()
+					}
+					nlris := filteredRoutes[path][protoFamily]
+					nlris.Remove = append(nlris.Remove, packet.ConstructNLRIFromPathIdAndNLRI(route.NLRI, pathId))
+				}
+				route.RemovePath(pathId)
+			}
+
+			if !route.DoesPathsExist() {
+				p.logger.Infof("Neighbor %s: remove stale nlri %s protocol family %d from RIB-In",
+					p.NeighborConf.Neighbor.NeighborAddress, ip, protoFamily)
+				p.checkRIBInFilter(route.NLRI, route, nil, false)
+				delete(p.ribIn[protoFamily], ip)
+			}
+		}
+	}
+
+	updated, withdrawn, updatedAddPaths, _ := p.locRib.ProcessFilteredRoutes(p.NeighborConf, filteredRoutes,
+		p.server.AddPathCount)
+	return updated, withdrawn, updatedAddPaths
+}
+
+// SoftResetOut re-applies the RIB-Out policy and re-advertises the routes of the protocol family to the peer.
+// The routes that are rejected by the policy are withdrawn. If the peer supports enhanced route refresh, the
+// updates are sent between the BoRR and EoRR markers.
+func (p *Peer) SoftResetOut(protoFamily uint32, locRib map[uint32]map[*bgprib.Path][]*bgprib.Destination) {
+	p.logger.Infof("Neighbor %s: soft reset out, protocol family %d", p.NeighborConf.Neighbor.NeighborAddress,
+		protoFamily)
+	if p.NeighborConf.Neighbor.Transport.Config.LocalAddress == nil || p.fsmManager == nil {
+		p.logger.Errf("Neighbor %s: Can't soft reset out, FSM is not in Established state",
+			p.NeighborConf.Neighbor.NeighborAddress)
+		return
+	}
+
+	if !p.NeighborConf.AfiSafiMap[protoFamily] {
+		p.logger.Errf("Neighbor %s: Can't soft reset out, protocol family %d is not negotiated",
+			p.NeighborConf.Neighbor.NeighborAddress, protoFamily)
+		return
+	}
+
+	if p.updateGroup != nil && len(p.updateGroup.members) > 1 {
+		// Send the routes only to this peer, it gets a copy of the Adj-RIB-Out of the group. The peer joins
+		// the update group again only if its new Adj-RIB-Out is the same as the one of the group.
+		group := p.updateGroup
+		p.server.leaveUpdateGroup(p)
+		defer p.rejoinUpdateGroup(group)
+	}
+
+	afi, safi := packet.GetAfiSafi(protoFamily)
+	enhancedRouteRefresh := p.NeighborConf.Neighbor.State.EnhancedRouteRefresh
+	if enhancedRouteRefresh {
+		p.fsmManager.SendRouteRefreshMsg(afi, safi, packet.BGPRouteRefreshBoRR)
+	}
+
+	addPathsTx := p.isAddPathsTxEnabled(protoFamily)
+	withdrawList := make([]packet.NLRI, 0)
+	for ip, route := range p.ribOut[protoFamily] {
+		p.resetAdjRIBRoutePolicyState(route, p.server.ribOutPE)
+		if accept, _ := p.checkRIBOutFilter(route.NLRI, route, nil, true); !accept {
+			if addPathsTx {
+				for pathId, _ := range route.GetPathMap() {
+					withdrawList = append(withdrawList, packet.NewExtNLRI(pathId, route.NLRI.GetIPPrefix()))
+				}
+			} else {
+				withdrawList = append(withdrawList, route.NLRI)
+			}
+		}
+		p.resetAdjRIBRoutePolicyState(route, p.server.ribOutPE)
+		route.RemoveAllPaths()
+		delete(p.ribOut[protoFamily], ip)
+	}
+
+	if len(withdrawList) > 0 {
+		p.logger.Infof("Neighbor %s: soft reset out, withdraw routes:%+v", p.NeighborConf.Neighbor.NeighborAddress,
+			withdrawList)
+		var updateMsg *packet.BGPMessage
+		if protoFamily == packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast) {
+			updateMsg = packet.NewBGPUpdateMessage(withdrawList, nil, nil)
+		} else {
+			mpUnreachNLRI := packet.ConstructMPUnreachNLRIFromProtoFamily(protoFamily, withdrawList)
+			updateMsg = packet.NewBGPUpdateMessage(nil, []packet.BGPPathAttr{mpUnreachNLRI}, nil)
+		}
+		p.sendUpdateMsg(updateMsg, nil)
+	}
+
+	updated := make(map[uint32]map[*bgprib.Path][]*bgprib.Destination)
+	if pathDestMap, ok := locRib[protoFamily]; ok {
+		updated[protoFamily] = pathDestMap
+	}
+	p.SendUpdate(updated, make([]*bgprib.Destination, 0), make([]*bgprib.Destination, 0))
+
+	// The originated default route is not in the Loc-RIB, it is sent again so that the peer doesn't purge it
+	// as stale after the EoRR
+	if p.defaultRouteSent[protoFamily] {
+		p.sendDefaultRoute(protoFamily, true)
+	}
+
+	if enhancedRouteRefresh {
+		p.fsmManager.SendRouteRefreshMsg(afi, safi, packet.BGPRouteRefreshEoRR)
+	}
+}
+
+// rejoinUpdateGroup joins the update group that the peer left if the Adj-RIB-Out of the peer is the same as the
+// one of the group.
+func (p *Peer) rejoinUpdateGroup(group *UpdateGroup) {
+	if p.server.updateGroups[group.key] != group || p.getUpdateGroupKey() != group.key ||
+		!group.isRibOutEqual(p.ribOut) {
+		p.logger.Infof("Neighbor %s: Adj-RIB-Out differs from update group %s, not joining the group",
+			p.NeighborConf.Neighbor.NeighborAddress, group.key)
+		return
+	}
+
+	for _, routeMap := range p.ribOut {
+		for _, route := range routeMap {
+			p.resetAdjRIBRoutePolicyState(route, p.server.ribOutPE)
+		}
+	}
+	p.server.joinUpdateGroup(p)
+}
+
 func (p *Peer) ReceiveUpdate(pktInfo *packet.BGPPktSrc) (map[uint32]map[*bgprib.Path][]*bgprib.Destination,
 	[]*bgprib.Destination, []*bgprib.Destination) {
 	var mpReachProtoFamily, mpUnreachProtoFamily uint32 = 0, 0
@@ -733,11 +1024,28 @@ func (p *Peer) ReceiveUpdate(pktInfo *packet.BGPPktSrc) (map[uint32]map[*bgprib.
 	atomic.AddUint32(&p.NeighborConf.Neighbor.State.Queues.Input, ^uint32(0))
 	p.NeighborConf.Neighbor.State.Messages.Received.Update++
 
+	// The pre-policy update is reported before the message is modified by the processing below
+	bmpEnabled := p.server.bmpClient.HasCollectors()
+	if bmpEnabled {
+		p.bmpRouteMonitoring(pktInfo.Msg, false)
+	}
+
+	if eorProtoFamily, ok := packet.GetEndOfRIBProtoFamily(pktInfo.Msg); ok {
+		return p.ProcessEndOfRIB(eorProtoFamily)
+	}
+
 	asLoop := false
 	updateMsg := pktInfo.Msg.Body.(*packet.BGPUpdate)
-	if packet.HasASLoop(updateMsg.PathAttributes, p.NeighborConf.RunningConf.LocalAS) {
+	if p.NeighborConf.IsLocalASConfigured() && p.NeighborConf.IsExternal() &&
+		!p.NeighborConf.RunningConf.LocalASNoPrepend {
+		// The routes look like they were received in the local AS of the peer
+		packet.PrependAS(pktInfo.Msg, p.NeighborConf.RunningConf.LocalAS, 4)
+	}
+
+	if p.NeighborConf.HasASLoop(updateMsg.PathAttributes) {
 		p.logger.Infof("Neighbor %s: Recived Update message has AS loop", p.NeighborConf.Neighbor.NeighborAddress)
 		asLoop = true
+		p.bmpIncrementStat(bmp.BMPStatASPathLoop, 1)
 	}
 
 	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
@@ -748,11 +1056,12 @@ func (p *Peer) ReceiveUpdate(pktInfo *packet.BGPPktSrc) (map[uint32]map[*bgprib.
 	//remPath := bgprib.NewPath(p.locRib, p.neighborConf, updateMsg.PathAttributes, mpReach, RouteTypeEGP)
 	path := bgprib.NewPath(p.locRib, p.NeighborConf, updateMsg.PathAttributes, mpReach, bgprib.RouteTypeEGP)
 
+	var modifiedPaths, mpModifiedPaths map[*bgprib.Path][]packet.NLRI
 	p.processWithdraws(protoFamily, &updateMsg.WithdrawnRoutes)
 	if asLoop {
 		updateMsg.NLRI = make([]packet.NLRI, 0)
 	} else {
-		p.processUpdates(protoFamily, &updateMsg.NLRI, path)
+		modifiedPaths = p.processUpdates(protoFamily, &updateMsg.NLRI, path)
 	}
 
 	if len(updateMsg.WithdrawnRoutes) > 0 || len(updateMsg.NLRI) > 0 {
@@ -764,6 +1073,15 @@ func (p *Peer) ReceiveUpdate(pktInfo *packet.BGPPktSrc) (map[uint32]map[*bgprib.
 		}
 	}
 
+	for modifiedPath, nlris := range modifiedPaths {
+		updated, withdrawn, updatedAddPaths, addedAllPrefixes = p.locRib.ProcessUpdate(p.NeighborConf,
+			modifiedPath, nlris, make([]packet.NLRI, 0), protoFamily, p.server.AddPathCount, updated, withdrawn,
+			updatedAddPaths)
+		if !addedAllPrefixes {
+			p.MaxPrefixesExceeded()
+		}
+	}
+
 	if mpUnreach != nil {
 		mpUnreachProtoFamily := packet.GetProtocolFamily(mpUnreach.AFI, mpUnreach.SAFI)
 		p.processWithdraws(mpUnreachProtoFamily, &(mpUnreach.NLRI))
@@ -775,7 +1093,7 @@ func (p *Peer) ReceiveUpdate(pktInfo *packet.BGPPktSrc) (map[uint32]map[*bgprib.
 			mpReach.NLRI = make([]packet.NLRI, 0)
 		} else {
 			mpReachProtoFamily = packet.GetProtocolFamily(mpReach.AFI, mpReach.SAFI)
-			p.processUpdates(mpReachProtoFamily, &(mpReach.NLRI), path)
+			mpModifiedPaths = p.processUpdates(mpReachProtoFamily, &(mpReach.NLRI), path)
 			if mpReachProtoFamily == mpUnreachProtoFamily {
 				mpProtoFamilySame = true
 				mpReachNLRI = mpReach.NLRI
@@ -801,9 +1119,52 @@ func (p *Peer) ReceiveUpdate(pktInfo *packet.BGPPktSrc) (map[uint32]map[*bgprib.
 		}
 	}
 
+	for modifiedPath, nlris := range mpModifiedPaths {
+		updated, withdrawn, updatedAddPaths, addedAllPrefixes = p.locRib.ProcessUpdate(p.NeighborConf,
+			modifiedPath, nlris, make([]packet.NLRI, 0), mpReachProtoFamily, p.server.AddPathCount, updated,
+			withdrawn, updatedAddPaths)
+		if !addedAllPrefixes {
+			p.MaxPrefixesExceeded()
+		}
+	}
+
+	if bmpEnabled {
+		p.bmpPostPolicyUpdates(path, updateMsg, modifiedPaths, mpReach, mpUnreach, mpModifiedPaths)
+		p.bmpUpdateAdjRIBInRoutes()
+	}
+
 	return updated, withdrawn, updatedAddPaths
 }
 
+// ProcessEndOfRIB removes the stale paths of the protocol family that were not refreshed by the peer
+// after a graceful restart.
+func (p *Peer) ProcessEndOfRIB(protoFamily uint32) (map[uint32]map[*bgprib.Path][]*bgprib.Destination,
+	[]*bgprib.Destination, []*bgprib.Destination) {
+	p.logger.Infof("Neighbor %s: Received End-of-RIB for protocol family %d", p.NeighborConf.Neighbor.NeighborAddress,
+		protoFamily)
+	delete(p.NeighborConf.EORPending, protoFamily)
+	if !p.NeighborConf.StaleFamilies[protoFamily] {
+		return make(map[uint32]map[*bgprib.Path][]*bgprib.Destination), make([]*bgprib.Destination, 0),
+			make([]*bgprib.Destination, 0)
+	}
+
+	delete(p.NeighborConf.StaleFamilies, protoFamily)
+	return p.locRib.RemoveStaleUpdatesFromNeighbor(p.NeighborConf.Neighbor.NeighborAddress.String(),
+		p.NeighborConf, map[uint32]bool{protoFamily: true}, p.server.AddPathCount)
+}
+
+func (p *Peer) SendEndOfRIB() {
+	if p.fsmManager == nil || !p.NeighborConf.Neighbor.State.GracefulRestart {
+		return
+	}
+
+	for protoFamily, ok := range p.NeighborConf.PeerAfiSafiMap {
+		if ok {
+			p.fsmManager.SendEndOfRIBMsg(protoFamily)
+		}
+	}
+}
+
 func (p *Peer) updatePathAttrs(bgpMsg *packet.BGPMessage, path *bgprib.Path) bool {
 	if p.NeighborConf.Neighbor.Transport.Config.LocalAddress == nil {
 		p.logger.Errf("Neighbor %s: Can't send Update message, FSM is not in Established state",
@@ -822,6 +1183,13 @@ func (p *Peer) updatePathAttrs(bgpMsg *packet.BGPMessage, path *bgprib.Path) boo
 		return true
 	}
 
+	confedExternal := p.NeighborConf.IsConfedExternal()
+	if p.NeighborConf.IsExternal() && !confedExternal {
+		// The confederation segments are not sent outside the confederation, RFC 5065
+		packet.RemoveConfedSegments(bgpMsg)
+		p.rewriteASPath(bgpMsg)
+	}
+
 	if p.NeighborConf.ASSize == 2 {
 		packet.Convert4ByteTo2ByteASPath(bgpMsg)
 	}
@@ -839,12 +1207,22 @@ func (p *Peer) updatePathAttrs(bgpMsg *packet.BGPMessage, path *bgprib.Path) boo
 		if p.NeighborConf.RunningConf.NextHopSelf {
 			packet.SetNextHop(bgpMsg, p.NeighborConf.Neighbor.Transport.Config.LocalAddress)
 		}
+	} else if confedExternal {
+		// NEXT_HOP, MED and LOCAL_PREF are kept in the confederation
+		packet.SetLocalPref(bgpMsg, path.GetPreference())
+		packet.PrependConfedAS(bgpMsg, p.NeighborConf.RunningConf.LocalAS, p.NeighborConf.ASSize)
+		if p.NeighborConf.RunningConf.NextHopSelf {
+			packet.SetNextHop(bgpMsg, p.NeighborConf.Neighbor.Transport.Config.LocalAddress)
+		}
 	} else {
 		// Do change these path attrs for local routes
 		if path.NeighborConf != nil {
 			packet.RemoveMultiExitDisc(bgpMsg)
 		}
-		packet.PrependAS(bgpMsg, p.NeighborConf.RunningConf.LocalAS, p.NeighborConf.ASSize)
+		if p.NeighborConf.IsLocalASConfigured() && !p.NeighborConf.RunningConf.LocalASReplaceAS {
+			packet.PrependAS(bgpMsg, p.NeighborConf.GetRouterAS(), p.NeighborConf.ASSize)
+		}
+		packet.PrependAS(bgpMsg, p.NeighborConf.GetAdvertisedAS(), p.NeighborConf.ASSize)
 		if updateMsg.NLRI != nil && len(updateMsg.NLRI) > 0 {
 			packet.SetNextHop(bgpMsg, p.NeighborConf.Neighbor.Transport.Config.LocalAddress)
 		} else if len(updateMsg.PathAttributes) > 0 {
@@ -861,7 +1239,27 @@ func (p *Peer) updatePathAttrs(bgpMsg *packet.BGPMessage, path *bgprib.Path) boo
 	return true
 }
 
+// rewriteASPath removes or replaces the private ASes and overrides the AS of the peer in the AS path of the
+// update that is sent to the eBGP peer.
+func (p *Peer) rewriteASPath(bgpMsg *packet.BGPMessage) {
+	switch p.NeighborConf.RunningConf.RemovePrivateAS {
+	case config.RemovePrivateASAll:
+		packet.RemovePrivateAS(bgpMsg)
+	case config.RemovePrivateASReplace:
+		packet.ReplacePrivateAS(bgpMsg, p.NeighborConf.GetRouterAS())
+	}
+
+	if p.NeighborConf.RunningConf.AsOverride {
+		packet.ReplaceAS(bgpMsg, p.NeighborConf.RunningConf.PeerAS, p.NeighborConf.GetRouterAS())
+	}
+}
+
 func (p *Peer) sendUpdateMsg(msg *packet.BGPMessage, path *bgprib.Path) {
+	if p.updateGroup != nil {
+		p.updateGroup.sendUpdateMsg(msg, path)
+		return
+	}
+
 	if p.fsmManager == nil {
 		p.logger.Errf("Can't send update, FSM Manager is not instantiated for neighbor %s",
 			p.NeighborConf.Neighbor.NeighborAddress)
@@ -900,37 +1298,57 @@ func (p *Peer) isAdvertisable(path *bgprib.Path) bool {
 			}
 		}
 
-		// Don't send the update to the peer that sent the update.
-		if p.NeighborConf.RunningConf.NeighborAddress.String() ==
+		// Don't send the update to the peer that sent the update. The update group checks it for every
+		// member when the message is sent.
+		if p.updateGroup == nil && p.NeighborConf.RunningConf.NeighborAddress.String() ==
 			path.NeighborConf.RunningConf.NeighborAddress.String() {
 			return false
 		}
 
-		if packet.HasASLoop(path.PathAttrs, p.NeighborConf.RunningConf.PeerAS) {
+		// The AS of the peer is replaced in the AS path with as-override
+		if !p.NeighborConf.RunningConf.AsOverride &&
+			packet.HasASLoop(path.PathAttrs, p.NeighborConf.RunningConf.PeerAS) {
 			return false
 		}
 
 	}
 
+	if path != nil {
+		// RFC 1997 well-known communities
+		if path.HasCommunity(packet.BGPCommunityNoAdvertise) {
+			return false
+		}
+
+		if p.NeighborConf.IsExternal() && path.HasCommunity(packet.BGPCommunityNoExportSubconfed) {
+			return false
+		}
+
+		// NO_EXPORT routes are advertised to the other member ASes of the confederation
+		if p.NeighborConf.IsExternal() && !p.NeighborConf.IsConfedExternal() &&
+			path.HasCommunity(packet.BGPCommunityNoExport) {
+			return false
+		}
+	}
+
 	return true
 }
 
-func (p *Peer) addPathFamilyToUpdated(pathAdded, protoFamilyAdded bool, path *bgprib.Path, protoFamily uint32,
-	updated map[*bgprib.Path]map[uint32][]packet.NLRI) (bool, bool, map[*bgprib.Path]map[uint32][]packet.NLRI) {
-	if !pathAdded {
+func (p *Peer) addNLRIToUpdated(path *bgprib.Path, protoFamily uint32, nlri packet.NLRI,
+	updated map[*bgprib.Path]map[uint32][]packet.NLRI) map[*bgprib.Path]map[uint32][]packet.NLRI {
+	if _, ok := updated[path]; !ok {
 		updated[path] = make(map[uint32][]packet.NLRI)
-		pathAdded = true
 	}
-	if !protoFamilyAdded {
+	if _, ok := updated[path][protoFamily]; !ok {
 		updated[path][protoFamily] = make([]packet.NLRI, 0)
-		protoFamilyAdded = true
 	}
-	return pathAdded, protoFamilyAdded, updated
+	updated[path][protoFamily] = append(updated[path][protoFamily], nlri)
+	return updated
 }
 
 func (p *Peer) calculateAddPathsAdvertisements(dest *bgprib.Destination, path *bgprib.Path,
-	newUpdated map[*bgprib.Path]map[uint32][]packet.NLRI, withdrawList map[uint32][]packet.NLRI, addPathsTx int) (
-	map[*bgprib.Path]map[uint32][]packet.NLRI, map[uint32][]packet.NLRI) {
+	newUpdated map[*bgprib.Path]map[uint32][]packet.NLRI, withdrawList map[uint32][]packet.NLRI,
+	addPathsTx config.AddPathsConfig, pathCache map[*bgprib.Path]map[string]*bgprib.Path) (map[*bgprib.Path]map[uint32][]packet.NLRI,
+	map[uint32][]packet.NLRI) {
 	pathIdMap := make(map[uint32]*bgprib.Path)
 	ip := dest.NLRI.GetCIDR()
 	protoFamily := dest.GetProtocolFamily()
@@ -943,26 +1361,17 @@ func (p *Peer) calculateAddPathsAdvertisements(dest *bgprib.Destination, path *b
 	}
 
 	ribOutRoute := p.ribOut[protoFamily][ip]
-	canAdvertise := p.checkRIBOutFilter(dest.NLRI, ribOutRoute, true)
+	canAdvertise, actions := p.checkRIBOutFilter(dest.NLRI, ribOutRoute, path, true)
 	canWithdraw := p.checkRIBOutWithdraw(ribOutRoute)
 
-	pathAdded := false
-	protoFamilyAdded := false
-	if _, ok := newUpdated[path]; ok {
-		pathAdded = true
-		if _, ok := newUpdated[path][protoFamily]; ok {
-			protoFamilyAdded = true
-		}
-	}
-
-	if p.isAdvertisable(path) {
+	suppressed := p.isAdvSuppressed(protoFamily, dest.NLRI)
+	if !suppressed && p.isAdvertisable(path) {
 		route := dest.LocRibPathRoute
 		if path != nil { // Loc-RIB path changed
 			if canAdvertise {
-				pathAdded, protoFamilyAdded, newUpdated = p.addPathFamilyToUpdated(pathAdded, protoFamilyAdded, path,
-					protoFamily, newUpdated)
 				nlri := packet.NewExtNLRI(route.OutPathId, dest.NLRI.GetIPPrefix())
-				newUpdated[path][protoFamily] = append(newUpdated[path][protoFamily], nlri)
+				newUpdated = p.addNLRIToUpdated(p.getCommunityActionsPath(path, actions, pathCache), protoFamily,
+					nlri, newUpdated)
 			}
 		} else {
 			path = dest.LocRibPath
@@ -970,10 +1379,10 @@ func (p *Peer) calculateAddPathsAdvertisements(dest *bgprib.Destination, path *b
 		pathIdMap[route.OutPathId] = path
 	}
 
-	for i := 0; i < len(dest.AddPaths) && len(pathIdMap) < (addPathsTx-1); i++ {
-		route := dest.GetPathRoute(dest.AddPaths[i])
-		if route != nil && p.isAdvertisable(dest.AddPaths[i]) {
-			pathIdMap[route.OutPathId] = dest.AddPaths[i]
+	for _, addPath := range dest.GetAdditionalPaths(addPathsTx.TxMode, int(addPathsTx.MaxTx)) {
+		route := dest.GetPathRoute(addPath)
+		if route != nil && !suppressed && p.isAdvertisable(addPath) {
+			pathIdMap[route.OutPathId] = addPath
 		}
 	}
 
@@ -988,10 +1397,12 @@ func (p *Peer) calculateAddPathsAdvertisements(dest *bgprib.Destination, path *b
 			delete(pathIdMap, ribOutPathId)
 		} else if ribOutPath != path {
 			if canAdvertise {
-				pathAdded, protoFamilyAdded, newUpdated = p.addPathFamilyToUpdated(pathAdded, protoFamilyAdded, path,
-					protoFamily, newUpdated)
 				nlri := packet.NewExtNLRI(ribOutPathId, dest.NLRI.GetIPPrefix())
-				newUpdated[path][protoFamily] = append(newUpdated[path][protoFamily], nlri)
+				newUpdated = p.addNLRIToUpdated(p.getCommunityActionsPath(path, actions, pathCache), protoFamily,
+					nlri, newUpdated)
+				if p.updateGroup != nil {
+					p.updateGroup.addSourceWithdraw(path, ribOutPath, protoFamily, nlri)
+				}
 			}
 			ribOutRoute.AddPath(ribOutPathId, path)
 			delete(pathIdMap, ribOutPathId)
@@ -1000,10 +1411,9 @@ func (p *Peer) calculateAddPathsAdvertisements(dest *bgprib.Destination, path *b
 
 	for pathId, path := range pathIdMap {
 		if canAdvertise {
-			pathAdded, protoFamilyAdded, newUpdated = p.addPathFamilyToUpdated(pathAdded, protoFamilyAdded, path,
-				protoFamily, newUpdated)
 			nlri := packet.NewExtNLRI(pathId, dest.NLRI.GetIPPrefix())
-			newUpdated[path][protoFamily] = append(newUpdated[path][protoFamily], nlri)
+			newUpdated = p.addNLRIToUpdated(p.getCommunityActionsPath(path, actions, pathCache), protoFamily, nlri,
+				newUpdated)
 		}
 		ribOutRoute.AddPath(pathId, path)
 		delete(pathIdMap, pathId)
@@ -1037,9 +1447,9 @@ func (p *Peer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destinat
 		return
 	}
 
-	addPathsTx := p.getAddPathsMaxTx()
 	withdrawList := make(map[uint32][]packet.NLRI)
 	newUpdated := make(map[*bgprib.Path]map[uint32][]packet.NLRI)
+	pathCache := make(map[*bgprib.Path]map[string]*bgprib.Path)
 	if len(withdrawn) > 0 {
 		for _, dest := range withdrawn {
 			if dest != nil {
@@ -1064,7 +1474,7 @@ func (p *Peer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destinat
 						continue
 					}
 
-					if addPathsTx > 0 {
+					if p.isAddPathsTxEnabled(protoFamily) {
 						for pathId, _ := range route.GetPathMap() {
 							nlri := packet.NewExtNLRI(pathId, dest.NLRI.GetIPPrefix())
 							withdrawList[protoFamily] = append(withdrawList[protoFamily], nlri)
@@ -1088,17 +1498,19 @@ func (p *Peer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destinat
 		if _, ok := withdrawList[protoFamily]; !ok {
 			withdrawList[protoFamily] = make([]packet.NLRI, 0)
 		}
+		addPathsTx := p.NeighborConf.GetAddPathsTx(protoFamily)
 		for path, destinations := range pathDestMap {
 			for _, dest := range destinations {
 				if dest == nil {
 					continue
 				}
 				ip := dest.NLRI.GetCIDR()
-				if addPathsTx > 0 {
+				// Add-path is only negotiated for the unicast families
+				if addPathsTx.TxMode != "" {
 					newUpdated, withdrawList = p.calculateAddPathsAdvertisements(dest, path, newUpdated,
-						withdrawList, addPathsTx)
+						withdrawList, addPathsTx, pathCache)
 				} else {
-					if !p.isAdvertisable(path) {
+					if !p.isAdvertisable(path) || p.isAdvSuppressed(protoFamily, dest.NLRI) {
 						if ribOutRoute := p.ribOut[protoFamily][ip]; ribOutRoute != nil &&
 							p.checkRIBOutWithdraw(ribOutRoute) {
 							withdrawList[protoFamily] = append(withdrawList[protoFamily], dest.NLRI)
@@ -1113,21 +1525,26 @@ func (p *Peer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destinat
 								protoFamily, dest.NLRI)
 						}
 						ribOutRoute := p.ribOut[protoFamily][ip]
-						for ribPathId, _ := range ribOutRoute.GetPathMap() {
+						var oldPath *bgprib.Path
+						for ribPathId, ribPath := range ribOutRoute.GetPathMap() {
+							oldPath = ribPath
 							if pathId != ribPathId {
 								ribOutRoute.RemovePath(ribPathId)
 							}
 						}
 						if ribOutPath := ribOutRoute.GetPath(pathId); ribOutPath == nil || ribOutPath != path {
-							if p.checkRIBOutFilter(dest.NLRI, ribOutRoute, true) {
-								if _, ok := newUpdated[path]; !ok {
-									newUpdated[path] = make(map[uint32][]packet.NLRI)
+							if accept, actions := p.checkRIBOutFilter(dest.NLRI, ribOutRoute, path, true); accept {
+								var nlri packet.NLRI = dest.NLRI.GetIPPrefix()
+								if packet.IsLabeledUnicastFamily(protoFamily) {
+									nlri = p.server.getLabeledNLRI(protoFamily, dest)
+								} else if !packet.IsUnicastFamily(protoFamily) {
+									nlri = dest.NLRI
 								}
-								if _, ok := newUpdated[path][protoFamily]; !ok {
-									newUpdated[path][protoFamily] = make([]packet.NLRI, 0)
+								newUpdated = p.addNLRIToUpdated(p.getCommunityActionsPath(path, actions, pathCache),
+									protoFamily, nlri, newUpdated)
+								if p.updateGroup != nil {
+									p.updateGroup.addSourceWithdraw(path, oldPath, protoFamily, nlri)
 								}
-								newUpdated[path][protoFamily] = append(newUpdated[path][protoFamily],
-									dest.NLRI.GetIPPrefix())
 							}
 						}
 						ribOutRoute.AddPath(pathId, path)
@@ -1137,11 +1554,13 @@ func (p *Peer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destinat
 		}
 	}
 
-	if addPathsTx > 0 {
-		for _, dest := range updatedAddPaths {
-			newUpdated, withdrawList = p.calculateAddPathsAdvertisements(dest, nil, newUpdated, withdrawList,
-				addPathsTx)
+	for _, dest := range updatedAddPaths {
+		addPathsTx := p.NeighborConf.GetAddPathsTx(dest.GetProtocolFamily())
+		if addPathsTx.TxMode == "" {
+			continue
 		}
+		newUpdated, withdrawList = p.calculateAddPathsAdvertisements(dest, nil, newUpdated, withdrawList,
+			addPathsTx, pathCache)
 	}
 
 	if withdrawList != nil {
@@ -1185,7 +1604,8 @@ func (p *Peer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destinat
 
 		for protoFamily, nlriList := range pfNLRIMap {
 			if len(nlriList) > 0 {
-				mpReachNLRI := packet.ConstructIPv6MPReachNLRI(protoFamily, localAddress, nil, nlriList)
+				mpReachNLRI := packet.ConstructIPv6MPReachNLRI(protoFamily, p.getMPNextHop(path, protoFamily,
+					localAddress), nil, nlriList)
 				pa := packet.CopyPathAttrs(path.PathAttrs)
 				pa = packet.AddMPReachNLRIToPathAttrs(pa, mpReachNLRI)
 				updateMsg = packet.NewBGPUpdateMessage(nil, pa, ipv4List)
@@ -1203,6 +1623,25 @@ func (p *Peer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destinat
 			p.sendUpdateMsg(updateMsg.Clone(), path)
 		}
 	}
+
+	if p.updateGroup != nil {
+		p.updateGroup.sendSourceWithdraws()
+	}
+
+	if p.hasAdvConditions() {
+		p.checkAdvConditions()
+	}
+}
+
+// getMPNextHop returns the next hop of the multiprotocol routes. The next hop of the EVPN routes is the
+// VTEP that originated the route and is not changed, RFC 8365 section 5.1.3.
+func (p *Peer) getMPNextHop(path *bgprib.Path, protoFamily uint32, localAddress net.IP) net.IP {
+	if packet.IsEVPNFamily(protoFamily) {
+		if nextHop := path.GetNextHop(protoFamily); nextHop != nil && !nextHop.IsUnspecified() {
+			return nextHop
+		}
+	}
+	return localAddress
 }
 
 func (p *Peer) AdjRIBOutPolicyUpdated(data interface{}, updateFunc utilspolicy.PolicyApplyfunc) {
@@ -1259,7 +1698,8 @@ func (p *Peer) AdjRIBOutPolicyUpdated(data interface{}, updateFunc utilspolicy.P
 				var pa []packet.BGPPathAttr
 				if len(routesMap.Add) > 0 {
 					pa = packet.CopyPathAttrs(path.PathAttrs)
-					mpReachNLRI := packet.ConstructIPv6MPReachNLRI(protoFamily, localAddress, nil, routesMap.Add)
+					mpReachNLRI := packet.ConstructIPv6MPReachNLRI(protoFamily, p.getMPNextHop(path, protoFamily,
+						localAddress), nil, routesMap.Add)
 					pa = packet.AddMPReachNLRIToPathAttrs(pa, mpReachNLRI)
 				}
 
@@ -0,0 +1,69 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// routerefresh_test.go
+package server
+
+import (
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"net"
+	"testing"
+)
+
+func TestEnhancedRouteRefreshStaleRoutes(t *testing.T) {
+	_, peers := getTestPeers(t, "192.168.0.1")
+	peer := peers[0]
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+	path := bgprib.NewPath(peer.locRib, peer.NeighborConf, nil, nil, bgprib.RouteTypeEGP)
+	prefixes := []string{"20.1.1.0", "20.1.2.0"}
+	for _, prefix := range prefixes {
+		nlri := packet.NewIPPrefix(net.ParseIP(prefix), 24)
+		route := bgprib.NewAdjRIBRoute(peer.NeighborConf.Neighbor.NeighborAddress, protoFamily, nlri)
+		route.AddPath(0, path)
+		peer.ribIn[protoFamily][nlri.GetCIDR()] = route
+	}
+
+	peer.ProcessEnhancedRouteRefresh(protoFamily, packet.BGPRouteRefreshBoRR)
+	for ip, route := range peer.ribIn[protoFamily] {
+		if !route.StalePathIds[0] {
+			t.Fatal("Route", ip, "not marked stale after BoRR")
+		}
+	}
+
+	// The first route is advertised again by the peer
+	refreshed := packet.NewIPPrefix(net.ParseIP(prefixes[0]), 24).GetCIDR()
+	peer.ribIn[protoFamily][refreshed].AddPath(0, path)
+
+	peer.ProcessEnhancedRouteRefresh(protoFamily, packet.BGPRouteRefreshEoRR)
+	if len(peer.ribIn[protoFamily]) != 1 {
+		t.Fatal("Expected only the refreshed route in RIB-In after EoRR, found", len(peer.ribIn[protoFamily]))
+	}
+	route, ok := peer.ribIn[protoFamily][refreshed]
+	if !ok {
+		t.Fatal("Refreshed route", refreshed, "removed after EoRR")
+	}
+	if len(route.StalePathIds) != 0 {
+		t.Fatal("Refreshed route", refreshed, "is still stale after EoRR")
+	}
+}
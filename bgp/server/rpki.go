@@ -0,0 +1,126 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// rpki.go
+package server
+
+import (
+	"l3/bgp/config"
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"l3/bgp/rpki"
+	utilspolicy "utils/policy"
+)
+
+const (
+	validationStateKeyPrefix = "validation-state:"
+)
+
+func (s *BGPServer) processRPKICacheConfig(cacheConf config.RPKICacheServer) {
+	if cacheConf.Address == nil {
+		s.logger.Info("Stop RPKI client")
+		s.rpkiClient.Stop()
+		return
+	}
+
+	if cacheConf.Port == 0 {
+		cacheConf.Port = config.RPKICachePortDefault
+	}
+	s.logger.Info("Start RPKI client with cache", cacheConf.Address, "port", cacheConf.Port)
+	s.rpkiClient.Start(cacheConf)
+}
+
+// getValidationState returns the origin validation state of an IPv4 or IPv6 unicast route received from a
+// peer. The local routes and the routes of the other families are not validated.
+func (s *BGPInstance) getValidationState(protoFamily uint32, nlri packet.NLRI,
+	path *bgprib.Path) rpki.ValidationState {
+	if path == nil || path.NeighborConf == nil || path.IsVPNPath() {
+		return rpki.ValidationStateNotFound
+	}
+
+	if protoFamily != packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast) &&
+		protoFamily != packet.GetProtocolFamily(packet.AfiIP6, packet.SafiUnicast) {
+		return rpki.ValidationStateNotFound
+	}
+
+	return s.rpkiClient.Validate(nlri.GetPrefix(), nlri.GetLength(), path.GetOriginAS())
+}
+
+// matchValidationStateConditions checks the origin validation state conditions of the policy statement
+// against the route.
+func (s *BGPInstance) matchValidationStateConditions(policyParams *AdjRIBPolicyParams,
+	policyStmt utilspolicy.PolicyStmt) bool {
+	var path *bgprib.Path
+	for _, conditionName := range policyStmt.Conditions {
+		condition := s.policyManager.ValidationDB.GetCondition(conditionName)
+		if condition == nil {
+			continue
+		}
+
+		if path == nil {
+			if path = s.getAdjRIBPolicyPath(policyParams); path == nil {
+				return false
+			}
+		}
+
+		state := s.getValidationState(policyParams.Route.ProtocolFamily, policyParams.Route.NLRI, path)
+		if !condition.Match(state) {
+			s.logger.Infof("BGPServer:matchValidationStateConditions - validation state condition %s did not "+
+				"match route %s state %s", conditionName, policyParams.Route.NLRI.GetCIDR(), state)
+			return false
+		}
+	}
+	return true
+}
+
+// processROATableUpdate validates the routes of all the peers again after the ROA table is updated.
+func (s *BGPInstance) processROATableUpdate() {
+	s.logger.Info("ROA table updated, validating the routes of the VRF", s.getVrf())
+	for _, peer := range s.PeerMap {
+		updated, withdrawn, updatedAddPaths := peer.SoftResetIn()
+		updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+		s.SendUpdate(updated, withdrawn, updatedAddPaths)
+	}
+}
+
+// getValidatedPath returns the path with the origin validation state. The paths are cached by the state in
+// pathCache so that the NLRIs with the same state share the same path.
+func (p *Peer) getValidatedPath(path *bgprib.Path, state rpki.ValidationState,
+	pathCache map[*bgprib.Path]map[string]*bgprib.Path) *bgprib.Path {
+	if path == nil || path.ValidationState == state {
+		return path
+	}
+
+	key := validationStateKeyPrefix + state.String()
+	if _, ok := pathCache[path]; !ok {
+		pathCache[path] = make(map[string]*bgprib.Path)
+	}
+	if newPath, ok := pathCache[path][key]; ok {
+		return newPath
+	}
+
+	newPath := path.Clone()
+	newPath.ValidationState = state
+	pathCache[path][key] = newPath
+	return newPath
+}
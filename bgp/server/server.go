@@ -29,11 +29,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"l3/bgp/bmp"
 	"l3/bgp/config"
 	"l3/bgp/fsm"
+	"l3/bgp/mrt"
 	"l3/bgp/packet"
 	bgppolicy "l3/bgp/policy"
 	bgprib "l3/bgp/rib"
+	"l3/bgp/rpki"
 	"l3/bgp/utils"
 	"net"
 	"reflect"
@@ -83,6 +86,12 @@ type AggUpdate struct {
 	AttrSet []bool
 }
 
+type BMPCollectorUpdate struct {
+	OldCollector config.BMPCollector
+	NewCollector config.BMPCollector
+	AttrSet      []bool
+}
+
 type PolicyParams struct {
 	CreateType      int
 	DeleteType      int
@@ -97,64 +106,63 @@ type IntfEntry struct {
 	Name string
 }
 
+// BGPServer hosts the BGP instances, one per VRF. It owns the managers that are shared by all the instances
+// and dispatches the config and the notifications to the instance of the VRF.
 type BGPServer struct {
-	logger           *logging.Writer
-	policyManager    *bgppolicy.BGPPolicyManager
-	locRibPE         map[uint32]*bgppolicy.LocRibPolicyEngine
-	ribInPE          *bgppolicy.AdjRibPPolicyEngine
-	ribOutPE         *bgppolicy.AdjRibPPolicyEngine
-	listener         *net.TCPListener
-	listenerIPv6     *net.TCPListener
-	ifaceMgr         *utils.InterfaceMgr
-	BgpConfig        config.Bgp
-	GlobalConfigCh   chan GlobalUpdate
-	AddPeerCh        chan PeerUpdate
-	RemPeerCh        chan config.NeighborConfig
-	AddPeerGroupCh   chan PeerGroupUpdate
-	RemPeerGroupCh   chan config.PeerGroupConfig
-	AddAggCh         chan AggUpdate
-	RemAggCh         chan config.BGPAggregate
-	PeerFSMConnCh    chan fsm.PeerFSMConn
-	PeerConnEstCh    chan string
-	PeerConnBrokenCh chan string
-	PeerCommandCh    chan config.PeerCommand
-	ReachabilityCh   chan config.ReachabilityInfo
-	BGPPktSrcCh      chan *packet.BGPPktSrc
-	BfdCh            chan config.BfdInfo
-	IntfCh           chan config.IntfStateInfo
-	IntfMapCh        chan config.IntfMapInfo
-	RoutesCh         chan *config.RouteCh
-	acceptCh         chan *net.TCPConn
-	ServerUpCh       chan bool
-	GlobalCfgDone    bool
-
-	NeighborMutex     sync.RWMutex
-	PeerMap           map[string]*Peer
-	ifaceNeighbors    map[config.PeerAddressType]map[int32]*Peer
-	Neighbors         []*Peer
-	LocRib            *bgprib.LocRib
-	ConnRoutesPath    *bgprib.Path
-	IfIndexPeerMap    map[int32][]string
-	IntfIdNameMap     map[int32]IntfEntry
-	IfNameToIfIndex   map[string]int32
-	RedistributionMap map[string]string
-	ifaceIP           net.IP
-	AddPathCount      int
-	// all managers
+	logger         *logging.Writer
+	policyManager  *bgppolicy.BGPPolicyManager
+	ifaceMgr       *utils.InterfaceMgr
+	GlobalConfigCh chan GlobalUpdate
+	AddPeerCh      chan PeerUpdate
+	RemPeerCh      chan config.NeighborConfig
+	AddPeerGroupCh chan PeerGroupUpdate
+	RemPeerGroupCh chan config.PeerGroupConfig
+	AddAggCh       chan AggUpdate
+	RemAggCh       chan config.BGPAggregate
+	AddRangeCh     chan config.ListenRange
+	RemRangeCh     chan config.ListenRange
+	PeerCommandCh  chan config.PeerCommand
+	SoftResetCh    chan config.SoftResetCommand
+	BfdCh          chan config.BfdInfo
+	IntfCh         chan config.IntfStateInfo
+	IntfMapCh      chan config.IntfMapInfo
+	RoutesCh       chan *config.RouteCh
+	EVPNVtepCh     chan config.EVPNVtepInfo
+	EVPNMacCh      chan config.EVPNMacInfo
+	AddBMPColCh    chan BMPCollectorUpdate
+	RemBMPColCh    chan config.BMPCollector
+	MRTConfigCh    chan config.MRTConfig
+	MRTDumpCh      chan config.MRTDumpCommand
+	RPKICacheCh    chan config.RPKICacheServer
+	ServerUpCh     chan bool
+
+	vrfMutex        sync.RWMutex
+	instances       map[string]*BGPInstance
+	labels          *labelAllocator
+	IntfIdNameMap   map[int32]IntfEntry
+	IfNameToIfIndex map[string]int32
+
 	IntfMgr    config.IntfStateMgrIntf
 	routeMgr   config.RouteMgrIntf
 	bfdMgr     config.BfdMgrIntf
+	evpnMgr    config.EVPNMgrIntf
+	bmpClient  *bmp.Client
+	rpkiClient *rpki.Client
 	stateDBMgr statedbclient.StateDBClient
 	eventDbHdl *dbutils.DBUtil
+
+	mrtConfig    config.MRTConfig
+	mrtRecorder  *mrt.UpdateRecorder
+	mrtDumpTimer *time.Timer
 }
 
 func NewBGPServer(logger *logging.Writer, policyManager *bgppolicy.BGPPolicyManager, iMgr config.IntfStateMgrIntf,
-	rMgr config.RouteMgrIntf, bMgr config.BfdMgrIntf, sDBMgr statedbclient.StateDBClient) *BGPServer {
+	rMgr config.RouteMgrIntf, bMgr config.BfdMgrIntf, eMgr config.EVPNMgrIntf,
+	sDBMgr statedbclient.StateDBClient) *BGPServer {
 	bgpServer := &BGPServer{}
 	bgpServer.logger = logger
 	bgpServer.policyManager = policyManager
 	bgpServer.ifaceMgr = utils.NewInterfaceMgr(logger)
-	bgpServer.GlobalCfgDone = false
 	bgpServer.GlobalConfigCh = make(chan GlobalUpdate)
 	bgpServer.AddPeerCh = make(chan PeerUpdate)
 	bgpServer.RemPeerCh = make(chan config.NeighborConfig)
@@ -162,42 +170,43 @@ func NewBGPServer(logger *logging.Writer, policyManager *bgppolicy.BGPPolicyMana
 	bgpServer.RemPeerGroupCh = make(chan config.PeerGroupConfig)
 	bgpServer.AddAggCh = make(chan AggUpdate)
 	bgpServer.RemAggCh = make(chan config.BGPAggregate)
-	bgpServer.PeerFSMConnCh = make(chan fsm.PeerFSMConn, 50)
-	bgpServer.PeerConnEstCh = make(chan string)
-	bgpServer.PeerConnBrokenCh = make(chan string)
+	bgpServer.AddRangeCh = make(chan config.ListenRange)
+	bgpServer.RemRangeCh = make(chan config.ListenRange)
 	bgpServer.PeerCommandCh = make(chan config.PeerCommand)
-	bgpServer.ReachabilityCh = make(chan config.ReachabilityInfo)
-	bgpServer.BGPPktSrcCh = make(chan *packet.BGPPktSrc)
+	bgpServer.SoftResetCh = make(chan config.SoftResetCommand)
 	bgpServer.BfdCh = make(chan config.BfdInfo)
 	bgpServer.IntfCh = make(chan config.IntfStateInfo)
 	bgpServer.IntfMapCh = make(chan config.IntfMapInfo)
 	bgpServer.RoutesCh = make(chan *config.RouteCh)
+	bgpServer.EVPNVtepCh = make(chan config.EVPNVtepInfo)
+	bgpServer.EVPNMacCh = make(chan config.EVPNMacInfo)
+	bgpServer.AddBMPColCh = make(chan BMPCollectorUpdate)
+	bgpServer.RemBMPColCh = make(chan config.BMPCollector)
+	bgpServer.MRTConfigCh = make(chan config.MRTConfig)
+	bgpServer.MRTDumpCh = make(chan config.MRTDumpCommand)
+	bgpServer.RPKICacheCh = make(chan config.RPKICacheServer)
 	bgpServer.ServerUpCh = make(chan bool)
 
-	bgpServer.NeighborMutex = sync.RWMutex{}
-	bgpServer.PeerMap = make(map[string]*Peer)
-	bgpServer.ifaceNeighbors = make(map[config.PeerAddressType]map[int32]*Peer)
-	bgpServer.ifaceNeighbors[config.PeerAddressV4] = make(map[int32]*Peer)
-	bgpServer.ifaceNeighbors[config.PeerAddressV6] = make(map[int32]*Peer)
-
-	bgpServer.Neighbors = make([]*Peer, 0)
+	bgpServer.vrfMutex = sync.RWMutex{}
+	bgpServer.instances = make(map[string]*BGPInstance)
+	bgpServer.labels = newLabelAllocator()
 	bgpServer.IntfMgr = iMgr
-	bgpServer.routeMgr = rMgr
-	bgpServer.bfdMgr = bMgr
-	bgpServer.stateDBMgr = sDBMgr
-	bgpServer.LocRib = bgprib.NewLocRib(logger, rMgr, sDBMgr, &bgpServer.BgpConfig.Global.Config)
+	bgpServer.routeMgr = &serialRouteMgr{RouteMgrIntf: rMgr}
+	bgpServer.bfdMgr = &serialBfdMgr{BfdMgrIntf: bMgr}
+	bgpServer.evpnMgr = eMgr
+	bgpServer.bmpClient = bmp.NewClient(logger, getSysName(), bmpSysDescr)
+	bgpServer.mrtRecorder = mrt.NewUpdateRecorder(logger)
+	bgpServer.mrtDumpTimer = time.NewTimer(time.Second)
+	bgpServer.mrtDumpTimer.Stop()
+	bgpServer.rpkiClient = rpki.NewClient(logger)
+	bgpServer.stateDBMgr = &serialStateDBClient{StateDBClient: sDBMgr}
 	bgpServer.IfNameToIfIndex = make(map[string]int32)
 	bgpServer.IntfIdNameMap = make(map[int32]IntfEntry)
-	bgpServer.IfIndexPeerMap = make(map[int32][]string)
-	bgpServer.RedistributionMap = make(map[string]string)
-	bgpServer.ifaceIP = nil
-	bgpServer.AddPathCount = 0
-	bgpServer.initGlobalConfig()
-	bgpServer.initPolicyEngines()
+	bgpServer.instances[config.BGPDefaultVrf] = NewBGPInstance(bgpServer, config.BGPDefaultVrf)
 	return bgpServer
 }
 
-func (s *BGPServer) initGlobalConfig() {
+func (s *BGPInstance) initGlobalConfig() {
 	s.BgpConfig = config.Bgp{}
 	s.BgpConfig.Afs = make(map[uint32]*config.AddressFamily)
 	for _, pfNumber := range packet.ProtocolFamilyMap {
@@ -205,7 +214,7 @@ func (s *BGPServer) initGlobalConfig() {
 	}
 }
 
-func (s *BGPServer) initPolicyEngines() {
+func (s *BGPInstance) initPolicyEngines() {
 	type TraverseFuncMap struct {
 		ApplyFunc   utilspolicy.EntityTraverseAndApplyPolicyfunc
 		ReverseFunc utilspolicy.EntityTraverseAndReversePolicyfunc
@@ -261,7 +270,18 @@ func (s *BGPServer) initPolicyEngines() {
 	s.policyManager.AddPolicyEngine(s.ribOutPE)
 }
 
-func (s *BGPServer) createListener(proto string) (*net.TCPListener, error) {
+func (s *BGPInstance) createListener(proto string) (*net.TCPListener, error) {
+	if vrf := s.BgpConfig.Global.Config.Vrf; vrf != "" && vrf != config.BGPDefaultVrf {
+		s.logger.Infof("Listening for incomig connections on port %s in VRF %s", config.BGPPort, vrf)
+		port, _ := strconv.Atoi(config.BGPPort)
+		listener, err := utils.ListenTCPInVrf(proto, port, vrf)
+		if err != nil {
+			s.logger.Info("ListenTCPInVrf failed with", err)
+			return nil, err
+		}
+		return listener, nil
+	}
+
 	addr := ":" + config.BGPPort
 	s.logger.Infof("Listening for incomig connections on %s", addr)
 	tcpAddr, err := net.ResolveTCPAddr(proto, addr)
@@ -279,7 +299,7 @@ func (s *BGPServer) createListener(proto string) (*net.TCPListener, error) {
 	return listener, nil
 }
 
-func (s *BGPServer) setListener(listener *net.TCPListener, proto string) {
+func (s *BGPInstance) setListener(listener *net.TCPListener, proto string) {
 	switch proto {
 	case "tcp4":
 		s.listener = listener
@@ -290,17 +310,25 @@ func (s *BGPServer) setListener(listener *net.TCPListener, proto string) {
 	}
 }
 
-func (s *BGPServer) listenForPeers(listener *net.TCPListener, proto string, acceptCh chan *net.TCPConn) {
+func (s *BGPInstance) listenForPeers(listener *net.TCPListener, proto string, acceptCh chan *net.TCPConn) {
 	for {
 		s.logger.Info("Waiting for peer connections...")
 		tcpConn, err := listener.AcceptTCP()
 		if err != nil {
 			s.logger.Info("AcceptTCP failed with", err)
+			if s.isShutdown() {
+				s.logger.Info("BGP instance is shut down, stop listening for", proto)
+				return
+			}
 			if strings.Contains(err.Error(), "use of closed network connection") {
 				newListener, err2 := s.createListener(proto)
 				if err2 != nil {
 					ticker := time.NewTicker(time.Duration(5) * time.Second)
 					for range ticker.C {
+						if s.isShutdown() {
+							ticker.Stop()
+							return
+						}
 						newListener, err2 = s.createListener(proto)
 						if err2 == nil {
 							ticker.Stop()
@@ -316,18 +344,40 @@ func (s *BGPServer) listenForPeers(listener *net.TCPListener, proto string, acce
 			continue
 		}
 		s.logger.Info("Got a peer connection from %s", tcpConn.RemoteAddr())
-		acceptCh <- tcpConn
+		select {
+		case acceptCh <- tcpConn:
+		case <-s.doneCh:
+			tcpConn.Close()
+			return
+		}
 	}
 }
 
-func (s *BGPServer) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination, withdrawn,
+func (s *BGPInstance) SendUpdate(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination, withdrawn,
 	updatedAddPaths []*bgprib.Destination) {
+	s.exportVPNRoutes(updated, withdrawn)
+	s.distributeVPNRoutes(updated, withdrawn)
+	s.notifyEVPNRoutes(updated, withdrawn)
+	s.updateLocalLabels(updated, withdrawn)
+
+	if s.grRestarting {
+		// Advertisements are deferred till the graceful restart is complete
+		return
+	}
+
+	// The peers in an update group are sent the updates computed by the leader of the group
 	for _, peer := range s.PeerMap {
-		peer.SendUpdate(updated, withdrawn, updatedAddPaths)
+		if peer.updateGroup == nil {
+			peer.SendUpdate(updated, withdrawn, updatedAddPaths)
+		}
+	}
+
+	for _, group := range s.updateGroups {
+		group.getLeader().SendUpdate(updated, withdrawn, updatedAddPaths)
 	}
 }
 
-func (s *BGPServer) DoesRouteExist(params interface{}) bool {
+func (s *BGPInstance) DoesRouteExist(params interface{}) bool {
 	policyParams := params.(PolicyParams)
 	dest := policyParams.dest
 	if dest == nil {
@@ -345,7 +395,7 @@ func (s *BGPServer) DoesRouteExist(params interface{}) bool {
 	return false
 }
 
-func (s *BGPServer) getAggPrefix(conditionsList []interface{}) *packet.IPPrefix {
+func (s *BGPInstance) getAggPrefix(conditionsList []interface{}) *packet.IPPrefix {
 	s.logger.Info("BGPServer:getAggPrefix")
 	var ipPrefix *packet.IPPrefix
 	var err error
@@ -369,7 +419,7 @@ func (s *BGPServer) getAggPrefix(conditionsList []interface{}) *packet.IPPrefix
 	return ipPrefix
 }
 
-func (s *BGPServer) setUpdatedAddPaths(policyParams *PolicyParams,
+func (s *BGPInstance) setUpdatedAddPaths(policyParams *PolicyParams,
 	updatedAddPaths []*bgprib.Destination) {
 	if len(updatedAddPaths) > 0 {
 		addPathsMap := make(map[*bgprib.Destination]bool)
@@ -386,7 +436,7 @@ func (s *BGPServer) setUpdatedAddPaths(policyParams *PolicyParams,
 	}
 }
 
-func (s *BGPServer) setWithdrawnWithAggPaths(policyParams *PolicyParams, withdrawn []*bgprib.Destination,
+func (s *BGPInstance) setWithdrawnWithAggPaths(policyParams *PolicyParams, withdrawn []*bgprib.Destination,
 	sendSummaryOnly bool, updatedAddPaths []*bgprib.Destination) {
 	destMap := make(map[*bgprib.Destination]bool)
 	for _, dest := range *policyParams.withdrawn {
@@ -453,7 +503,7 @@ func (s *BGPServer) setWithdrawnWithAggPaths(policyParams *PolicyParams, withdra
 	s.setUpdatedAddPaths(policyParams, updatedAddPaths)
 }
 
-func (s *BGPServer) setUpdatedWithAggPaths(policyParams *PolicyParams,
+func (s *BGPInstance) setUpdatedWithAggPaths(policyParams *PolicyParams,
 	updated map[uint32]map[*bgprib.Path][]*bgprib.Destination, sendSummaryOnly bool, ipPrefix *packet.IPPrefix,
 	protoFamily uint32, updatedAddPaths []*bgprib.Destination) {
 	var routeDest *bgprib.Destination
@@ -523,7 +573,7 @@ func (s *BGPServer) setUpdatedWithAggPaths(policyParams *PolicyParams,
 	s.setUpdatedAddPaths(policyParams, updatedAddPaths)
 }
 
-func (s *BGPServer) UndoAggregateAction(actionInfo interface{},
+func (s *BGPInstance) UndoAggregateAction(actionInfo interface{},
 	conditionList []interface{}, params interface{}, policyStmt utilspolicy.PolicyStmt) {
 	policyParams := params.(PolicyParams)
 	ipPrefix := packet.NewIPPrefix(net.ParseIP(policyParams.route.Dest.BGPRouteState.GetNetwork()),
@@ -555,7 +605,7 @@ func (s *BGPServer) UndoAggregateAction(actionInfo interface{},
 	return
 }
 
-func (s *BGPServer) ApplyAggregateAction(actionInfo interface{}, conditionInfo []interface{}, params interface{},
+func (s *BGPInstance) ApplyAggregateAction(actionInfo interface{}, conditionInfo []interface{}, params interface{},
 	policyStmt utilspolicy.PolicyStmt) {
 	policyParams := params.(PolicyParams)
 	ipPrefix := packet.NewIPPrefix(net.ParseIP(policyParams.route.Dest.BGPRouteState.GetNetwork()),
@@ -593,7 +643,7 @@ func (s *BGPServer) ApplyAggregateAction(actionInfo interface{}, conditionInfo [
 	return
 }
 
-func (s *BGPServer) CheckForAggregation(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination, withdrawn,
+func (s *BGPInstance) CheckForAggregation(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination, withdrawn,
 	updatedAddPaths []*bgprib.Destination) (map[uint32]map[*bgprib.Path][]*bgprib.Destination, []*bgprib.Destination,
 	[]*bgprib.Destination) {
 	s.logger.Infof("BGPServer:checkForAggregate - start, updated %v withdrawn %v", updated, withdrawn)
@@ -689,7 +739,7 @@ func (s *BGPServer) CheckForAggregation(updated map[uint32]map[*bgprib.Path][]*b
 	return updated, withdrawn, updatedAddPaths
 }
 
-func (s *BGPServer) UpdateRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{}) {
+func (s *BGPInstance) UpdateRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{}) {
 	var op int
 	policyParams := params.(PolicyParams)
 	dest := policyParams.dest
@@ -713,7 +763,7 @@ func (s *BGPServer) UpdateRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetai
 	pe.UpdatePolicyRouteMap(policyParams.route, policyDetails.Policy, op)
 }
 
-func (s *BGPServer) TraverseAndApplyBGPRib(data interface{}, updateFunc utilspolicy.PolicyApplyfunc) {
+func (s *BGPInstance) TraverseAndApplyBGPRib(data interface{}, updateFunc utilspolicy.PolicyApplyfunc) {
 	s.logger.Infof("BGPServer:TraverseAndApplyBGPRib - start")
 	updated := make(map[uint32]map[*bgprib.Path][]*bgprib.Destination, 10)
 	withdrawn := make([]*bgprib.Destination, 0, 10)
@@ -756,7 +806,7 @@ func (s *BGPServer) TraverseAndApplyBGPRib(data interface{}, updateFunc utilspol
 	s.SendUpdate(updated, withdrawn, updatedAddPaths)
 }
 
-func (s *BGPServer) TrAndRevAggForIPv4(policyData interface{}) {
+func (s *BGPInstance) TrAndRevAggForIPv4(policyData interface{}) {
 	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
 	pe, ok := s.locRibPE[protoFamily]
 	if !ok {
@@ -766,7 +816,7 @@ func (s *BGPServer) TrAndRevAggForIPv4(policyData interface{}) {
 	s.TraverseAndReverseBGPRib(policyData, pe)
 }
 
-func (s *BGPServer) TrAndRevAggForIPv6(policyData interface{}) {
+func (s *BGPInstance) TrAndRevAggForIPv6(policyData interface{}) {
 	protoFamily := packet.GetProtocolFamily(packet.AfiIP6, packet.SafiUnicast)
 	pe, ok := s.locRibPE[protoFamily]
 	if !ok {
@@ -776,7 +826,7 @@ func (s *BGPServer) TrAndRevAggForIPv6(policyData interface{}) {
 	s.TraverseAndReverseBGPRib(policyData, pe)
 }
 
-func (s *BGPServer) TraverseAndReverseBGPRib(policyData interface{}, pe *bgppolicy.LocRibPolicyEngine) {
+func (s *BGPInstance) TraverseAndReverseBGPRib(policyData interface{}, pe *bgppolicy.LocRibPolicyEngine) {
 	updateInfo := policyData.(utilspolicy.PolicyEngineApplyInfo)
 	applyPolicyInfo := updateInfo.ApplyPolicy
 	policy := applyPolicyInfo.ApplyPolicy
@@ -823,7 +873,7 @@ func (s *BGPServer) TraverseAndReverseBGPRib(policyData interface{}, pe *bgppoli
 	}
 }
 
-func (s *BGPServer) DoesAdjRIBRouteExist(params interface{}, adjRIBDir bgprib.AdjRIBDir) bool {
+func (s *BGPInstance) DoesAdjRIBRouteExist(params interface{}, adjRIBDir bgprib.AdjRIBDir) bool {
 	policyParams := params.(*AdjRIBPolicyParams)
 	peer := policyParams.Peer
 	if peer == nil {
@@ -842,18 +892,68 @@ func (s *BGPServer) DoesAdjRIBRouteExist(params interface{}, adjRIBDir bgprib.Ad
 	return false
 }
 
-func (s *BGPServer) DoesAdjRIBInRouteExist(params interface{}) bool {
+func (s *BGPInstance) DoesAdjRIBInRouteExist(params interface{}) bool {
 	return s.DoesAdjRIBRouteExist(params, bgprib.AdjRIBDirIn)
 }
 
-func (s *BGPServer) DoesAdjRIBOutRouteExist(params interface{}) bool {
+func (s *BGPInstance) DoesAdjRIBOutRouteExist(params interface{}) bool {
 	return s.DoesAdjRIBRouteExist(params, bgprib.AdjRIBDirOut)
 }
 
-func (s *BGPServer) ApplyAdjRIBAction(actionInfo interface{}, conditionInfo []interface{}, params interface{},
+func (s *BGPInstance) getAdjRIBPolicyPath(policyParams *AdjRIBPolicyParams) *bgprib.Path {
+	if policyParams.Path != nil {
+		return policyParams.Path
+	}
+
+	for _, path := range policyParams.Route.PathMap {
+		return path
+	}
+	return nil
+}
+
+// matchCommunityConditions checks the community conditions of the policy statement against the path. The policy
+// engine only evaluates the prefix and neighbor conditions, the community conditions are evaluated here.
+func (s *BGPInstance) matchCommunityConditions(policyParams *AdjRIBPolicyParams,
+	policyStmt utilspolicy.PolicyStmt) bool {
+	var path *bgprib.Path
+	for _, conditionName := range policyStmt.Conditions {
+		condition := s.policyManager.CommunityDB.GetCondition(conditionName)
+		if condition == nil {
+			continue
+		}
+
+		if path == nil {
+			if path = s.getAdjRIBPolicyPath(policyParams); path == nil {
+				return false
+			}
+		}
+
+		if !condition.Match(path.PathAttrs) {
+			s.logger.Infof("BGPServer:matchCommunityConditions - community condition %s did not match path %+v",
+				conditionName, path.PathAttrs)
+			return false
+		}
+	}
+	return true
+}
+
+func (s *BGPInstance) ApplyAdjRIBAction(actionInfo interface{}, conditionInfo []interface{}, params interface{},
 	policyStmt utilspolicy.PolicyStmt) {
 	policyParams := params.(*AdjRIBPolicyParams)
 	s.logger.Infof("BGPServer:ApplyAdjRIBAction - policyParams=%+v, policyStmt=%+v\n", policyParams, policyStmt)
+	if !s.matchCommunityConditions(policyParams, policyStmt) ||
+		!s.matchValidationStateConditions(policyParams, policyStmt) {
+		return
+	}
+
+	for _, action := range policyStmt.Actions {
+		if commAction := s.policyManager.CommunityDB.GetAction(action); commAction != nil {
+			s.logger.Infof("BGPServer:ApplyAdjRIBAction - policyParams=%+v, policyStmt=%+v, community action %s\n",
+				policyParams, policyStmt, action)
+			policyParams.CommunityActions = append(policyParams.CommunityActions, commAction)
+		}
+	}
+
 	if len(policyStmt.Actions) > 0 {
 		for _, action := range policyStmt.Actions {
 			if action == "permit" {
@@ -865,6 +965,8 @@ func (s *BGPServer) ApplyAdjRIBAction(actionInfo interface{}, conditionInfo []in
 				s.logger.Info("BGPServer:ApplyAdjRIBAction - policyParams=%+v, policyStmt=%+v, action deny\n",
 					policyParams, policyStmt, action)
 				policyParams.Accept = Reject
+			} else if s.policyManager.CommunityDB.GetAction(action) != nil {
+				continue
 			} else {
 				s.logger.Err("BGPServer:ApplyAdjRIBAction - policyParams=%+v, policyStmt=%+v, unknown action=%s\n",
 					policyParams, policyStmt, action)
@@ -873,7 +975,7 @@ func (s *BGPServer) ApplyAdjRIBAction(actionInfo interface{}, conditionInfo []in
 	}
 }
 
-func (s *BGPServer) UndoAdjRIBAction(actionInfo interface{}, conditionInfo []interface{}, params interface{},
+func (s *BGPInstance) UndoAdjRIBAction(actionInfo interface{}, conditionInfo []interface{}, params interface{},
 	policyStmt utilspolicy.PolicyStmt) {
 	policyParams := params.(*AdjRIBPolicyParams)
 	s.logger.Info("BGPServer:UndoAdjRIBAction - policyParams=%+v policyStmt=%+v\n", policyParams, policyStmt)
@@ -896,7 +998,7 @@ func (s *BGPServer) UndoAdjRIBAction(actionInfo interface{}, conditionInfo []int
 	}
 }
 
-func (s *BGPServer) UpdateAdjRIBRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{},
+func (s *BGPInstance) UpdateAdjRIBRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{},
 	pe *bgppolicy.AdjRibPPolicyEngine) {
 	var op int
 	policyParams := params.(*AdjRIBPolicyParams)
@@ -917,15 +1019,15 @@ func (s *BGPServer) UpdateAdjRIBRouteAndPolicyDB(policyDetails utilspolicy.Polic
 	pe.UpdateAdjRIBPolicyRouteMap(policyParams.Route, policyDetails.Policy, op)
 }
 
-func (s *BGPServer) UpdateAdjRIBInRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{}) {
+func (s *BGPInstance) UpdateAdjRIBInRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{}) {
 	s.UpdateAdjRIBRouteAndPolicyDB(policyDetails, params, s.ribInPE)
 }
 
-func (s *BGPServer) UpdateAdjRIBOutRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{}) {
+func (s *BGPInstance) UpdateAdjRIBOutRouteAndPolicyDB(policyDetails utilspolicy.PolicyDetails, params interface{}) {
 	s.UpdateAdjRIBRouteAndPolicyDB(policyDetails, params, s.ribOutPE)
 }
 
-func (s *BGPServer) getPeerForPolicy(data interface{}, updateFunc utilspolicy.PolicyApplyfunc,
+func (s *BGPInstance) getPeerForPolicy(data interface{}, updateFunc utilspolicy.PolicyApplyfunc,
 	pe *bgppolicy.AdjRibPPolicyEngine) *Peer {
 	s.logger.Infof("BGPServer:TraverseAndApplyAdjRib - start")
 	policyInfo := data.(utilspolicy.PolicyEngineApplyInfo)
@@ -981,7 +1083,7 @@ func (s *BGPServer) getPeerForPolicy(data interface{}, updateFunc utilspolicy.Po
 	*/
 }
 
-func (s *BGPServer) TraverseAndApplyAdjRibIn(data interface{}, updateFunc utilspolicy.PolicyApplyfunc) {
+func (s *BGPInstance) TraverseAndApplyAdjRibIn(data interface{}, updateFunc utilspolicy.PolicyApplyfunc) {
 	peer := s.getPeerForPolicy(data, updateFunc, s.ribInPE)
 	if peer == nil {
 		s.logger.Infof("BGPServer:TraverseAndApplyAdjRibIn - peer not found")
@@ -992,7 +1094,7 @@ func (s *BGPServer) TraverseAndApplyAdjRibIn(data interface{}, updateFunc utilsp
 	s.SendUpdate(updated, withdrawn, updatedAddPaths)
 }
 
-func (s *BGPServer) TraverseAndApplyAdjRibOut(data interface{}, updateFunc utilspolicy.PolicyApplyfunc) {
+func (s *BGPInstance) TraverseAndApplyAdjRibOut(data interface{}, updateFunc utilspolicy.PolicyApplyfunc) {
 	peer := s.getPeerForPolicy(data, updateFunc, s.ribOutPE)
 	if peer == nil {
 		s.logger.Infof("BGPServer:TraverseAndApplyAdjRibOut - peer not found")
@@ -1002,7 +1104,7 @@ func (s *BGPServer) TraverseAndApplyAdjRibOut(data interface{}, updateFunc utils
 	peer.AdjRIBOutPolicyUpdated(data, updateFunc)
 }
 
-func (s *BGPServer) TraverseAndReverseAdjRIB(policyData interface{}, pe *bgppolicy.AdjRibPPolicyEngine) {
+func (s *BGPInstance) TraverseAndReverseAdjRIB(policyData interface{}, pe *bgppolicy.AdjRibPPolicyEngine) {
 	updateInfo := policyData.(utilspolicy.PolicyEngineApplyInfo)
 	applyPolicyInfo := updateInfo.ApplyPolicy //policyData.(utilspolicy.ApplyPolicyInfo)
 	policy := applyPolicyInfo.ApplyPolicy     //policyItem.(policy.Policy)
@@ -1046,15 +1148,15 @@ func (s *BGPServer) TraverseAndReverseAdjRIB(policyData interface{}, pe *bgppoli
 	}
 }
 
-func (s *BGPServer) TraverseAndReverseAdjRIBIn(policyData interface{}) {
+func (s *BGPInstance) TraverseAndReverseAdjRIBIn(policyData interface{}) {
 	s.TraverseAndReverseAdjRIB(policyData, s.ribInPE)
 }
 
-func (s *BGPServer) TraverseAndReverseAdjRIBOut(policyData interface{}) {
+func (s *BGPInstance) TraverseAndReverseAdjRIBOut(policyData interface{}) {
 	s.TraverseAndReverseAdjRIB(policyData, s.ribOutPE)
 }
 
-func (s *BGPServer) ProcessUpdate(pktInfo *packet.BGPPktSrc) {
+func (s *BGPInstance) ProcessUpdate(pktInfo *packet.BGPPktSrc) {
 	peer, ok := s.PeerMap[pktInfo.Src]
 	if !ok {
 		s.logger.Err("BgpServer:ProcessUpdate - Peer not found, address:", pktInfo.Src)
@@ -1064,9 +1166,64 @@ func (s *BGPServer) ProcessUpdate(pktInfo *packet.BGPPktSrc) {
 	updated, withdrawn, updatedAddPaths := peer.ReceiveUpdate(pktInfo)
 	updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
 	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+	if s.grRestarting {
+		s.checkGracefulRestartComplete()
+	}
 }
 
-func (s *BGPServer) convertDestIPToIPPrefix(routes []*config.RouteInfo) map[uint32][]packet.NLRI {
+func (s *BGPInstance) ProcessRouteRefresh(pktInfo *packet.BGPPktSrc) {
+	peer, ok := s.PeerMap[pktInfo.Src]
+	if !ok {
+		s.logger.Err("BgpServer:ProcessRouteRefresh - Peer not found, address:", pktInfo.Src)
+		return
+	}
+
+	routeRefresh := pktInfo.Msg.Body.(*packet.BGPRouteRefresh)
+	protoFamily := packet.GetProtocolFamily(routeRefresh.AFI, routeRefresh.SAFI)
+	switch routeRefresh.SubType {
+	case packet.BGPRouteRefreshNormal:
+		peer.SoftResetOut(protoFamily, s.LocRib.GetLocRib())
+
+	case packet.BGPRouteRefreshBoRR, packet.BGPRouteRefreshEoRR:
+		if !peer.NeighborConf.Neighbor.State.EnhancedRouteRefresh {
+			s.logger.Infof("BgpServer:ProcessRouteRefresh - Peer %s, enhanced route refresh is not negotiated, "+
+				"ignore route refresh subtype %d", pktInfo.Src, routeRefresh.SubType)
+			return
+		}
+		updated, withdrawn, updatedAddPaths := peer.ProcessEnhancedRouteRefresh(protoFamily, routeRefresh.SubType)
+		updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+		s.SendUpdate(updated, withdrawn, updatedAddPaths)
+
+	default:
+		s.logger.Infof("BgpServer:ProcessRouteRefresh - Peer %s, ignore route refresh subtype %d", pktInfo.Src,
+			routeRefresh.SubType)
+	}
+}
+
+func (s *BGPInstance) ProcessSoftReset(softReset config.SoftResetCommand) {
+	peer, ok := s.PeerMap[softReset.IP.String()]
+	if !ok {
+		s.logger.Infof("Failed to soft reset, Peer at address %s does not exist", softReset.IP)
+		return
+	}
+
+	if softReset.Direction&config.SoftResetDirIn != 0 {
+		updated, withdrawn, updatedAddPaths := peer.SoftResetIn()
+		updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+		s.SendUpdate(updated, withdrawn, updatedAddPaths)
+	}
+
+	if softReset.Direction&config.SoftResetDirOut != 0 {
+		locRib := s.LocRib.GetLocRib()
+		for protoFamily, ok := range peer.NeighborConf.AfiSafiMap {
+			if ok {
+				peer.SoftResetOut(protoFamily, locRib)
+			}
+		}
+	}
+}
+
+func (s *BGPInstance) convertDestIPToIPPrefix(routes []*config.RouteInfo) map[uint32][]packet.NLRI {
 	pfNLRI := make(map[uint32][]packet.NLRI)
 	var protoFamily uint32
 	for _, r := range routes {
@@ -1095,10 +1252,12 @@ func (s *BGPServer) convertDestIPToIPPrefix(routes []*config.RouteInfo) map[uint
 	return pfNLRI
 }
 
-func (s *BGPServer) ProcessConnectedRoutes(installedRoutes, withdrawnRoutes []*config.RouteInfo) {
+func (s *BGPInstance) ProcessConnectedRoutes(installedRoutes, withdrawnRoutes []*config.RouteInfo) {
 	s.logger.Info("valid routes:", installedRoutes, "invalid routes:", withdrawnRoutes)
 	valid := s.convertDestIPToIPPrefix(installedRoutes)
 	invalid := s.convertDestIPToIPPrefix(withdrawnRoutes)
+	s.addLabeledUnicastRoutes(valid)
+	s.addLabeledUnicastRoutes(invalid)
 	s.logger.Info("pfNLRI valid:", valid, "invalid:", invalid)
 	routerId := s.BgpConfig.Global.Config.RouterId.String()
 	updated, withdrawn, updatedAddPaths := s.LocRib.ProcessConnectedRoutes(routerId, s.ConnRoutesPath, valid,
@@ -1130,7 +1289,8 @@ func (s *BGPServer) GetIfaceIP(ifIndex int32) (*utils.IPInfo, error) {
 	return ipInfo, err
 }
 
-func (s *BGPServer) ProcessRemoveNeighbor(peerIp string, peer *Peer) {
+func (s *BGPInstance) ProcessRemoveNeighbor(peerIp string, peer *Peer) {
+	peer.NeighborConf.StaleFamilies = make(map[uint32]bool)
 	updated, withdrawn, updatedAddPaths := s.LocRib.RemoveUpdatesFromNeighbor(peerIp, peer.NeighborConf,
 		s.AddPathCount)
 	s.logger.Infof("ProcessRemoveNeighbor - Neighbor %s, send updated paths %v, withdrawn paths %v",
@@ -1139,22 +1299,147 @@ func (s *BGPServer) ProcessRemoveNeighbor(peerIp string, peer *Peer) {
 	s.SendUpdate(updated, withdrawn, updatedAddPaths)
 }
 
-func (s *BGPServer) SendAllRoutesToPeer(peer *Peer) {
+// ProcessDampenedPaths advertises the suppressed paths that can be reused after their penalty decayed.
+func (s *BGPInstance) ProcessDampenedPaths() {
+	updated, withdrawn, updatedAddPaths := s.LocRib.ProcessDampenedPaths(s.AddPathCount)
+	if len(updated) == 0 && len(withdrawn) == 0 && len(updatedAddPaths) == 0 {
+		return
+	}
+
+	s.logger.Infof("ProcessDampenedPaths - send updated paths %v, withdrawn paths %v", updated, withdrawn)
+	updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+}
+
+// ProcessGracefulRestartNeighbor retains the paths received from the peer as stale paths for the protocol
+// families in the graceful restart capability of the peer. The paths of the other families are removed.
+func (s *BGPInstance) ProcessGracefulRestartNeighbor(peerIp string, peer *Peer) {
+	s.logger.Infof("ProcessGracefulRestartNeighbor - Neighbor %s, retain paths for families %v", peerIp,
+		peer.NeighborConf.GRFamilies)
+	s.LocRib.MarkStaleUpdatesFromNeighbor(peerIp, peer.NeighborConf.AfiSafiMap)
+	peer.NeighborConf.StaleFamilies = make(map[uint32]bool)
+	removeFamilies := make(map[uint32]bool)
+	for protoFamily, ok := range peer.NeighborConf.AfiSafiMap {
+		if !ok {
+			continue
+		}
+		if _, ok = peer.NeighborConf.GRFamilies[protoFamily]; ok {
+			peer.NeighborConf.StaleFamilies[protoFamily] = true
+		} else {
+			removeFamilies[protoFamily] = true
+		}
+	}
+
+	if len(removeFamilies) > 0 {
+		s.ProcessRemoveStaleNeighbor(peerIp, peer, removeFamilies)
+	}
+}
+
+func (s *BGPInstance) ProcessRemoveStaleNeighbor(peerIp string, peer *Peer, protoFamilies map[uint32]bool) {
+	updated, withdrawn, updatedAddPaths := s.LocRib.RemoveStaleUpdatesFromNeighbor(peerIp, peer.NeighborConf,
+		protoFamilies, s.AddPathCount)
+	for protoFamily, _ := range protoFamilies {
+		delete(peer.NeighborConf.StaleFamilies, protoFamily)
+	}
+	s.logger.Infof("ProcessRemoveStaleNeighbor - Neighbor %s, send updated paths %v, withdrawn paths %v",
+		peerIp, updated, withdrawn)
+	updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+}
+
+// processStaleFamilies removes the stale paths of the families for which the peer did not preserve the
+// forwarding state when the session is established again after a graceful restart.
+func (s *BGPInstance) processStaleFamilies(peerIp string, peer *Peer) {
+	removeFamilies := make(map[uint32]bool)
+	for protoFamily, _ := range peer.NeighborConf.StaleFamilies {
+		if !peer.NeighborConf.CanRetainStaleFamily(protoFamily) {
+			removeFamilies[protoFamily] = true
+		}
+	}
+
+	if len(removeFamilies) > 0 {
+		s.ProcessRemoveStaleNeighbor(peerIp, peer, removeFamilies)
+	}
+}
+
+func (s *BGPInstance) processGRTimerExpired(grTimerExp fsm.PeerGRTimerExp) {
+	peer, ok := s.PeerMap[grTimerExp.PeerIP]
+	if !ok {
+		s.logger.Infof("Failed to process graceful restart timer, Peer %s does not exist", grTimerExp.PeerIP)
+		return
+	}
+
+	if grTimerExp.Timer == fsm.GRTimerRestart && peer.NeighborConf.Neighbor.Transport.Config.LocalAddress != nil {
+		s.logger.Infof("Peer %s is established, ignore graceful restart timer", grTimerExp.PeerIP)
+		return
+	}
+
+	if peer.NeighborConf.HasStalePaths() {
+		s.logger.Infof("Peer %s did not complete graceful restart, remove stale paths", grTimerExp.PeerIP)
+		s.ProcessRemoveStaleNeighbor(grTimerExp.PeerIP, peer, peer.NeighborConf.StaleFamilies)
+	}
+}
+
+func (s *BGPInstance) startGracefulRestart(gConf *config.GlobalConfig) {
+	restartTime := gConf.RestartTime
+	if restartTime == 0 {
+		restartTime = config.BGPGRRestartTimeDefault
+	}
+	s.logger.Info("Start graceful restart, defer the advertisements for", restartTime, "seconds")
+	s.grRestarting = true
+	s.grRestartTimer.Reset(time.Duration(restartTime) * time.Second)
+}
+
+// checkGracefulRestartComplete completes the graceful restart when all the peers are established and End-of-RIB
+// is received from all the peers that support graceful restart.
+func (s *BGPInstance) checkGracefulRestartComplete() {
+	for _, peer := range s.PeerMap {
+		if !peer.IsActive() {
+			continue
+		}
+
+		if peer.NeighborConf.Neighbor.Transport.Config.LocalAddress == nil ||
+			len(peer.NeighborConf.EORPending) > 0 {
+			return
+		}
+	}
+
+	s.completeGracefulRestart()
+}
+
+func (s *BGPInstance) completeGracefulRestart() {
+	s.logger.Info("Graceful restart complete, advertise the routes to the peers")
+	s.grRestarting = false
+	s.grRestartTimer.Stop()
+	for _, peer := range s.PeerMap {
+		peer.NeighborConf.LocalRestarting = false
+	}
+
+	updated := s.LocRib.GetLocRib()
+	s.SendUpdate(updated, make([]*bgprib.Destination, 0), make([]*bgprib.Destination, 0))
+	for _, peer := range s.PeerMap {
+		s.joinUpdateGroup(peer)
+		peer.SendEndOfRIB()
+	}
+}
+
+func (s *BGPInstance) SendAllRoutesToPeer(peer *Peer) {
 	withdrawn := make([]*bgprib.Destination, 0)
 	updatedAddPaths := make([]*bgprib.Destination, 0)
 	updated := s.LocRib.GetLocRib()
 	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+	s.joinUpdateGroup(peer)
 }
 
-func (s *BGPServer) RemoveRoutesFromAllNeighbor() {
+func (s *BGPInstance) RemoveRoutesFromAllNeighbor() {
 	s.LocRib.RemoveUpdatesFromAllNeighbors(s.AddPathCount)
 }
 
-func (s *BGPServer) addPeerToList(peer *Peer) {
+func (s *BGPInstance) addPeerToList(peer *Peer) {
 	s.Neighbors = append(s.Neighbors, peer)
 }
 
-func (s *BGPServer) removePeerFromList(peer *Peer) {
+func (s *BGPInstance) removePeerFromList(peer *Peer) {
 	for idx, item := range s.Neighbors {
 		if item == peer {
 			s.Neighbors[idx] = s.Neighbors[len(s.Neighbors)-1]
@@ -1165,7 +1450,7 @@ func (s *BGPServer) removePeerFromList(peer *Peer) {
 	}
 }
 
-func (s *BGPServer) StopPeersByGroup(groupName string, peerAddrType config.PeerAddressType) []*Peer {
+func (s *BGPInstance) StopPeersByGroup(groupName string, peerAddrType config.PeerAddressType) []*Peer {
 	peers := make([]*Peer, 0)
 	for peerIP, peer := range s.PeerMap {
 		if peer.NeighborConf.Group != nil && peer.NeighborConf.RunningConf.PeerAddressType == peerAddrType &&
@@ -1182,16 +1467,20 @@ func (s *BGPServer) StopPeersByGroup(groupName string, peerAddrType config.PeerA
 	return peers
 }
 
-func (s *BGPServer) UpdatePeerGroupInPeers(groupName string, peerAddrType config.PeerAddressType,
+func (s *BGPInstance) UpdatePeerGroupInPeers(groupName string, peerAddrType config.PeerAddressType,
 	peerGroup *config.PeerGroupConfig) {
 	peers := s.StopPeersByGroup(groupName, peerAddrType)
 	for _, peer := range peers {
+		if peer.NeighborConf.IsDynamic() {
+			s.removeDynamicPeer(peer.NeighborConf.Neighbor.NeighborAddress.String(), peer)
+			continue
+		}
 		peer.UpdatePeerGroup(peerGroup)
 		peer.Init()
 	}
 }
 
-func (s *BGPServer) DeleteAgg(aggConf config.BGPAggregate) error {
+func (s *BGPInstance) DeleteAgg(aggConf config.BGPAggregate) error {
 	pe, ok := s.locRibPE[aggConf.AddressFamily]
 	if ok {
 		policyEngine := pe.GetPolicyEngine()
@@ -1215,7 +1504,7 @@ func (s *BGPServer) DeleteAgg(aggConf config.BGPAggregate) error {
 	return nil
 }
 
-func (s *BGPServer) AddOrUpdateAgg(oldConf config.BGPAggregate, newConf config.BGPAggregate, attrSet []bool) error {
+func (s *BGPInstance) AddOrUpdateAgg(oldConf config.BGPAggregate, newConf config.BGPAggregate, attrSet []bool) error {
 	s.logger.Info("AddOrUpdateAgg")
 	var err error
 
@@ -1310,7 +1599,7 @@ func (s *BGPServer) AddOrUpdateAgg(oldConf config.BGPAggregate, newConf config.B
 	return err
 }
 
-func (s *BGPServer) UpdateAggPolicy(policyName string, pe *bgppolicy.LocRibPolicyEngine,
+func (s *BGPInstance) UpdateAggPolicy(policyName string, pe *bgppolicy.LocRibPolicyEngine,
 	aggConf config.BGPAggregate) error {
 	s.logger.Debug("UpdateApplyPolicy")
 	var err error
@@ -1339,7 +1628,7 @@ func (s *BGPServer) UpdateAggPolicy(policyName string, pe *bgppolicy.LocRibPolic
 	return err
 }
 
-func (s *BGPServer) copyGlobalConf(gConf config.GlobalConfig) {
+func (s *BGPInstance) copyGlobalConf(gConf config.GlobalConfig) {
 	// Don't create a new Global object. Peers have reference to the global object.
 	s.BgpConfig.Global.Config.Vrf = gConf.Vrf
 	s.BgpConfig.Global.Config.AS = gConf.AS
@@ -1349,9 +1638,19 @@ func (s *BGPServer) copyGlobalConf(gConf config.GlobalConfig) {
 	s.BgpConfig.Global.Config.EBGPMaxPaths = gConf.EBGPMaxPaths
 	s.BgpConfig.Global.Config.EBGPAllowMultipleAS = gConf.EBGPAllowMultipleAS
 	s.BgpConfig.Global.Config.IBGPMaxPaths = gConf.IBGPMaxPaths
-}
-
-func (s *BGPServer) handleBfdNotifications(oper config.Operation, DestIp string,
+	s.BgpConfig.Global.Config.GracefulRestart = gConf.GracefulRestart
+	s.BgpConfig.Global.Config.RestartTime = gConf.RestartTime
+	s.BgpConfig.Global.Config.StalePathTime = gConf.StalePathTime
+	s.BgpConfig.Global.Config.Dampening = gConf.Dampening
+	s.BgpConfig.Global.Config.RouteDistinguisher = gConf.RouteDistinguisher
+	s.BgpConfig.Global.Config.ImportRouteTargets = gConf.ImportRouteTargets
+	s.BgpConfig.Global.Config.ExportRouteTargets = gConf.ExportRouteTargets
+	s.BgpConfig.Global.Config.OriginValidation = gConf.OriginValidation
+	s.BgpConfig.Global.Config.ConfederationId = gConf.ConfederationId
+	s.BgpConfig.Global.Config.ConfederationPeers = gConf.ConfederationPeers
+}
+
+func (s *BGPInstance) handleBfdNotifications(oper config.Operation, DestIp string,
 	State bool) {
 	if peer, ok := s.PeerMap[DestIp]; ok {
 		if !State && peer.NeighborConf.Neighbor.State.BfdNeighborState == "up" {
@@ -1365,7 +1664,7 @@ func (s *BGPServer) handleBfdNotifications(oper config.Operation, DestIp string,
 	}
 }
 
-func (s *BGPServer) setInterfaceMapForPeer(peerIP string, peer *Peer) {
+func (s *BGPInstance) setInterfaceMapForPeer(peerIP string, peer *Peer) {
 	s.logger.Info("Server: setInterfaceMapForPeer Peer", peer, "calling GetRouteReachabilityInfo")
 	reachInfo, err := s.routeMgr.GetNextHopInfo(peerIP, -1)
 	s.logger.Info("Server: setInterfaceMapForPeer Peer", peer, "GetRouteReachabilityInfo returned", reachInfo)
@@ -1385,7 +1684,7 @@ func (s *BGPServer) setInterfaceMapForPeer(peerIP string, peer *Peer) {
 	}
 }
 
-func (s *BGPServer) clearInterfaceMapForPeer(peerIP string, peer *Peer) {
+func (s *BGPInstance) clearInterfaceMapForPeer(peerIP string, peer *Peer) {
 	ifIdx := peer.getIfIdx()
 	s.logger.Infof("Server: Peer %s FSM connection broken ifIdx %v", peerIP, ifIdx)
 	if peerList, ok := s.IfIndexPeerMap[ifIdx]; ok {
@@ -1403,7 +1702,7 @@ func (s *BGPServer) clearInterfaceMapForPeer(peerIP string, peer *Peer) {
 	peer.setIfIdx(-1)
 }
 
-func (s *BGPServer) constructBGPGlobalState(gConf *config.GlobalConfig) {
+func (s *BGPInstance) constructBGPGlobalState(gConf *config.GlobalConfig) {
 	s.BgpConfig.Global.State.Vrf = gConf.Vrf
 	s.BgpConfig.Global.State.AS = gConf.AS
 	s.BgpConfig.Global.State.RouterId = gConf.RouterId
@@ -1412,9 +1711,19 @@ func (s *BGPServer) constructBGPGlobalState(gConf *config.GlobalConfig) {
 	s.BgpConfig.Global.State.EBGPMaxPaths = gConf.EBGPMaxPaths
 	s.BgpConfig.Global.State.EBGPAllowMultipleAS = gConf.EBGPAllowMultipleAS
 	s.BgpConfig.Global.State.IBGPMaxPaths = gConf.IBGPMaxPaths
-}
-
-func (s *BGPServer) SetupRedistribution(gConf config.GlobalConfig) {
+	s.BgpConfig.Global.State.GracefulRestart = gConf.GracefulRestart
+	s.BgpConfig.Global.State.RestartTime = gConf.RestartTime
+	s.BgpConfig.Global.State.StalePathTime = gConf.StalePathTime
+	s.BgpConfig.Global.State.Dampening = gConf.Dampening
+	s.BgpConfig.Global.State.RouteDistinguisher = gConf.RouteDistinguisher
+	s.BgpConfig.Global.State.ImportRouteTargets = gConf.ImportRouteTargets
+	s.BgpConfig.Global.State.ExportRouteTargets = gConf.ExportRouteTargets
+	s.BgpConfig.Global.State.OriginValidation = gConf.OriginValidation
+	s.BgpConfig.Global.State.ConfederationId = gConf.ConfederationId
+	s.BgpConfig.Global.State.ConfederationPeers = gConf.ConfederationPeers
+}
+
+func (s *BGPInstance) SetupRedistribution(gConf config.GlobalConfig) {
 	s.logger.Info("SetUpRedistribution")
 	if gConf.Redistribution == nil || len(gConf.Redistribution) == 0 {
 		s.logger.Info("No redistribution policies configured")
@@ -1495,7 +1804,7 @@ func (s *BGPServer) SetupRedistribution(gConf config.GlobalConfig) {
 	}
 }
 
-func (s *BGPServer) UpdateGlobalForPatchUpdate(oldConfig, newConfig config.GlobalConfig, op []*bgpd.PatchOpInfo) {
+func (s *BGPInstance) UpdateGlobalForPatchUpdate(oldConfig, newConfig config.GlobalConfig, op []*bgpd.PatchOpInfo) {
 	s.logger.Info("UpdateGlobalForPatchUpdate")
 	for idx := 0; idx < len(op); idx++ {
 		s.logger.Debug("patch update")
@@ -1597,7 +1906,7 @@ func (s *BGPServer) UpdateGlobalForPatchUpdate(oldConfig, newConfig config.Globa
 	}
 }
 
-func (s *BGPServer) UpdateGlobal(bgpGlobal *bgpd.BGPGlobal, oldConfig, newConfig config.GlobalConfig, attrSet []bool) {
+func (s *BGPInstance) UpdateGlobal(bgpGlobal *bgpd.BGPGlobal, oldConfig, newConfig config.GlobalConfig, attrSet []bool) {
 	s.logger.Info("UpdateGlobal")
 	if bgpGlobal == nil {
 		s.logger.Err("bgpglobal nil in update")
@@ -1629,12 +1938,15 @@ func (s *BGPServer) UpdateGlobal(bgpGlobal *bgpd.BGPGlobal, oldConfig, newConfig
 	}
 }
 
-func (s *BGPServer) isBGPGlobalDisabled() bool {
+func (s *BGPInstance) isBGPGlobalDisabled() bool {
 	return s.BgpConfig.Global.Config.Disabled
 }
 
-func (s *BGPServer) Restart(cfg config.GlobalConfig) {
+func (s *BGPInstance) Restart(cfg config.GlobalConfig) {
 	s.logger.Info("Restart BGP")
+	for _, lRange := range s.listenRanges {
+		s.removeRangePeers(lRange)
+	}
 	for peerIP, peer := range s.PeerMap {
 		s.logger.Infof("Cleanup peer %s", peerIP)
 		peer.Cleanup()
@@ -1655,10 +1967,14 @@ func (s *BGPServer) Restart(cfg config.GlobalConfig) {
 
 	if s.isBGPGlobalDisabled() {
 		s.logger.Info("BGP global for Vrf", gConf.Vrf, "is disabled, not bringing the neighbors up.")
+		s.teardownVPN()
 		return
 	}
 
-	add, remove := s.routeMgr.GetRoutes()
+	s.setupVPN(&gConf)
+	s.setupEVPN()
+
+	add, remove := s.getConnectedRoutes()
 	if add != nil && remove != nil {
 		s.ProcessConnectedRoutes(add, remove)
 	}
@@ -1670,7 +1986,7 @@ func (s *BGPServer) Restart(cfg config.GlobalConfig) {
 	// Get routes from the route manager
 }
 
-func (s *BGPServer) updateGlobalConfig(bgpGlobal *bgpd.BGPGlobal, oldConfig, newConfig config.GlobalConfig,
+func (s *BGPInstance) updateGlobalConfig(bgpGlobal *bgpd.BGPGlobal, oldConfig, newConfig config.GlobalConfig,
 	attrSet []bool, op []*bgpd.PatchOpInfo) {
 	s.logger.Info("updateGlobalConfig")
 	if op == nil || len(op) == 0 {
@@ -1680,7 +1996,7 @@ func (s *BGPServer) updateGlobalConfig(bgpGlobal *bgpd.BGPGlobal, oldConfig, new
 	}
 }
 
-func (s *BGPServer) getIfaceIP(ifIndex int32, peerAddrType config.PeerAddressType) net.IP {
+func (s *BGPInstance) getIfaceIP(ifIndex int32, peerAddrType config.PeerAddressType) net.IP {
 	ipInfo, err := s.GetIfaceIP(ifIndex)
 	s.logger.Info("ipInfo:", ipInfo, " err:", err)
 	if err != nil {
@@ -1709,7 +2025,7 @@ func (s *BGPServer) getIfaceIP(ifIndex int32, peerAddrType config.PeerAddressTyp
 	return nil
 }
 
-func (s *BGPServer) handleIntfCreate(ifIndex int32, peerAddrType config.PeerAddressType) {
+func (s *BGPInstance) handleIntfCreate(ifIndex int32, peerAddrType config.PeerAddressType) {
 	s.logger.Infof("handleIntfCreate - ifIndex:%d, peerAddrType:%d", ifIndex, peerAddrType)
 	ip := s.getIfaceIP(ifIndex, peerAddrType)
 	if ip != nil {
@@ -1729,7 +2045,7 @@ func (s *BGPServer) handleIntfCreate(ifIndex int32, peerAddrType config.PeerAddr
 	}
 }
 
-func (s *BGPServer) handleIntfDelete(ifIndex int32, peerAddrType config.PeerAddressType) {
+func (s *BGPInstance) handleIntfDelete(ifIndex int32, peerAddrType config.PeerAddressType) {
 	s.logger.Infof("handleIntfDelete - ifIndex:%d, peerAddrType:%d", ifIndex, peerAddrType)
 	if _, ok := s.ifaceNeighbors[peerAddrType]; ok {
 		if peer, ok := s.ifaceNeighbors[peerAddrType][ifIndex]; ok {
@@ -1750,7 +2066,7 @@ func (s *BGPServer) handleIntfDelete(ifIndex int32, peerAddrType config.PeerAddr
 	}
 }
 
-func (s *BGPServer) CreatePeer(newPeer config.NeighborConfig) {
+func (s *BGPInstance) CreatePeer(newPeer config.NeighborConfig) {
 	s.logger.Infof("CreatePeer %+v", newPeer)
 	var ok bool
 	var peer *Peer
@@ -1795,6 +2111,7 @@ func (s *BGPServer) CreatePeer(newPeer config.NeighborConfig) {
 
 	s.logger.Info("Add neighbor, ip:", newPeer.NeighborAddress.String(), "ifIndex:", newPeer.IfIndex)
 	peer = NewPeer(s, s.LocRib, &s.BgpConfig.Global.Config, groupConfig, newPeer)
+	peer.NeighborConf.LocalRestarting = s.grRestarting
 	if peer.NeighborConf.RunningConf.NeighborAddress.To4() != nil &&
 		peer.NeighborConf.RunningConf.AuthPassword != "" {
 		err := netUtils.SetTCPListenerMD5(s.listener, newPeer.NeighborAddress.String(),
@@ -1824,7 +2141,7 @@ func (s *BGPServer) CreatePeer(newPeer config.NeighborConfig) {
 	peer.Init()
 }
 
-func (s *BGPServer) getPeer(neighbor config.NeighborConfig) *Peer {
+func (s *BGPInstance) getPeer(neighbor config.NeighborConfig) *Peer {
 	var peer *Peer
 	var ok bool
 	if neighbor.NeighborAddress != nil {
@@ -1842,7 +2159,7 @@ func (s *BGPServer) getPeer(neighbor config.NeighborConfig) *Peer {
 	return peer
 }
 
-func (s *BGPServer) updatePeerConf(oldPeer, newPeer config.NeighborConfig, peer *Peer) {
+func (s *BGPInstance) updatePeerConf(oldPeer, newPeer config.NeighborConfig, peer *Peer) {
 	s.logger.Info("Clean up peer, ip:", oldPeer.NeighborAddress.String(), "ifIndex:", oldPeer.IfIndex)
 	peer.Cleanup()
 	if peer.NeighborConf.RunningConf.NeighborAddress != nil {
@@ -1893,7 +2210,7 @@ func (s *BGPServer) updatePeerConf(oldPeer, newPeer config.NeighborConfig, peer
 	peer.Init()
 }
 
-func (s *BGPServer) Updatev4Peer(bgpPeer *bgpd.BGPv4Neighbor, oldPeer, newPeer config.NeighborConfig, attrSet []bool) {
+func (s *BGPInstance) Updatev4Peer(bgpPeer *bgpd.BGPv4Neighbor, oldPeer, newPeer config.NeighborConfig, attrSet []bool) {
 	s.logger.Info("Updatev4Peer")
 	var peer *Peer
 
@@ -1938,7 +2255,7 @@ func (s *BGPServer) Updatev4Peer(bgpPeer *bgpd.BGPv4Neighbor, oldPeer, newPeer c
 	}
 }
 
-func (s *BGPServer) Updatev6Peer(bgpPeer *bgpd.BGPv6Neighbor, oldPeer, newPeer config.NeighborConfig, attrSet []bool) {
+func (s *BGPInstance) Updatev6Peer(bgpPeer *bgpd.BGPv6Neighbor, oldPeer, newPeer config.NeighborConfig, attrSet []bool) {
 	s.logger.Info("Updatev4Peer")
 	var peer *Peer
 
@@ -1983,7 +2300,7 @@ func (s *BGPServer) Updatev6Peer(bgpPeer *bgpd.BGPv6Neighbor, oldPeer, newPeer c
 	}
 }
 
-func (s *BGPServer) removePeer(neighbor config.NeighborConfig) {
+func (s *BGPInstance) removePeer(neighbor config.NeighborConfig) {
 	s.logger.Info("Remove Peer, ip:", neighbor.NeighborAddress, "ifIndex:", neighbor.IfIndex)
 	var peerIP string
 	var ifacePeer *Peer
@@ -2016,6 +2333,7 @@ func (s *BGPServer) removePeer(neighbor config.NeighborConfig) {
 		delete(s.PeerMap, peerIP)
 		peer.Cleanup()
 		s.ProcessRemoveNeighbor(peerIP, peer)
+		s.LocRib.RemoveDampeningInfoForNeighbor(peerIP)
 	} else if ifacePeer != nil {
 		s.NeighborMutex.Lock()
 		s.removePeerFromList(ifacePeer)
@@ -2024,7 +2342,7 @@ func (s *BGPServer) removePeer(neighbor config.NeighborConfig) {
 	}
 }
 
-func (s *BGPServer) listenChannelUpdates() {
+func (s *BGPInstance) listenChannelUpdates() {
 	for {
 		select {
 		case globalUpdate := <-s.GlobalConfigCh:
@@ -2033,6 +2351,9 @@ func (s *BGPServer) listenChannelUpdates() {
 			} else if globalUpdate.Op == "update" {
 				s.updateGlobalConfig(globalUpdate.BGPConfig, globalUpdate.OldConfig, globalUpdate.NewConfig,
 					globalUpdate.AttrSet, globalUpdate.PatchOp)
+			} else if globalUpdate.Op == "delete" {
+				s.Shutdown()
+				return
 			}
 
 		case peerUpdate := <-s.AddPeerCh:
@@ -2174,6 +2495,12 @@ func (s *BGPServer) listenChannelUpdates() {
 		case aggConf := <-s.RemAggCh:
 			s.DeleteAgg(aggConf)
 
+		case rangeConf := <-s.AddRangeCh:
+			s.addListenRange(rangeConf)
+
+		case rangeConf := <-s.RemRangeCh:
+			s.removeListenRange(rangeConf)
+
 		case tcpConn := <-s.acceptCh:
 			s.logger.Info("Connected to", tcpConn.RemoteAddr().String())
 			host, _, _ := net.SplitHostPort(tcpConn.RemoteAddr().String())
@@ -2181,6 +2508,9 @@ func (s *BGPServer) listenChannelUpdates() {
 			host = hostSplit[0]
 			peer, ok := s.PeerMap[host]
 			if !ok {
+				peer = s.createDynamicPeer(net.ParseIP(host))
+			}
+			if peer == nil {
 				s.logger.Info("Can't accept connection. Peer is not configured yet", host)
 				tcpConn.Close()
 				s.logger.Info("Closed connection from", host)
@@ -2197,6 +2527,10 @@ func (s *BGPServer) listenChannelUpdates() {
 			}
 			peer.Command(peerCommand.Command, fsm.BGPCmdReasonNone)
 
+		case softReset := <-s.SoftResetCh:
+			s.logger.Info("Soft reset received", softReset)
+			s.ProcessSoftReset(softReset)
+
 		case peerFSMConn := <-s.PeerFSMConnCh:
 			s.logger.Infof("Server: Peer %s FSM established/broken channel", peerFSMConn.PeerIP)
 			peer, ok := s.PeerMap[peerFSMConn.PeerIP]
@@ -2207,29 +2541,59 @@ func (s *BGPServer) listenChannelUpdates() {
 			}
 
 			if peerFSMConn.Established {
-				peer.PeerConnEstablished(peerFSMConn.Conn)
-				addPathsMaxTx := peer.getAddPathsMaxTx()
-				if addPathsMaxTx > s.AddPathCount {
-					s.AddPathCount = addPathsMaxTx
+				peer.PeerConnEstablished(peerFSMConn.Conn, peerFSMConn.SentOpenMsg, peerFSMConn.RecvOpenMsg)
+				addPathCount := peer.getAddPathCount()
+				if addPathCount > s.AddPathCount {
+					s.AddPathCount = addPathCount
 				}
 				s.setInterfaceMapForPeer(peerFSMConn.PeerIP, peer)
-				s.SendAllRoutesToPeer(peer)
+				s.processStaleFamilies(peerFSMConn.PeerIP, peer)
+				if s.grRestarting {
+					s.checkGracefulRestartComplete()
+				} else {
+					s.SendAllRoutesToPeer(peer)
+					peer.SendEndOfRIB()
+				}
 			} else {
-				peer.PeerConnBroken(true)
-				addPathsMaxTx := peer.getAddPathsMaxTx()
-				if addPathsMaxTx < s.AddPathCount {
+				peer.PeerConnBroken(true, peerFSMConn.NotifMsg, peerFSMConn.NotifMsgSent)
+				addPathCount := peer.getAddPathCount()
+				if addPathCount < s.AddPathCount {
 					s.AddPathCount = 0
 					for _, otherPeer := range s.PeerMap {
-						addPathsMaxTx = otherPeer.getAddPathsMaxTx()
-						if addPathsMaxTx > s.AddPathCount {
-							s.AddPathCount = addPathsMaxTx
+						addPathCount = otherPeer.getAddPathCount()
+						if addPathCount > s.AddPathCount {
+							s.AddPathCount = addPathCount
 						}
 					}
 				}
 				s.clearInterfaceMapForPeer(peerFSMConn.PeerIP, peer)
-				s.ProcessRemoveNeighbor(peerFSMConn.PeerIP, peer)
+				if peer.NeighborConf.IsDynamic() {
+					s.removeDynamicPeer(peerFSMConn.PeerIP, peer)
+				} else if peerFSMConn.GracefulRestart {
+					s.ProcessGracefulRestartNeighbor(peerFSMConn.PeerIP, peer)
+				} else {
+					s.ProcessRemoveNeighbor(peerFSMConn.PeerIP, peer)
+				}
 			}
 
+		case grTimerExp := <-s.PeerGRTimerCh:
+			s.logger.Infof("Server: Peer %s graceful restart timer %d expired", grTimerExp.PeerIP, grTimerExp.Timer)
+			s.processGRTimerExpired(grTimerExp)
+
+		case <-s.grRestartTimer.C:
+			s.logger.Info("Server: Graceful restart timer expired")
+			s.completeGracefulRestart()
+
+		case <-s.dampeningTimer.C:
+			s.ProcessDampenedPaths()
+			s.dampeningTimer.Reset(bgprib.DampeningReuseInterval)
+
+		case dumpCmd := <-s.MRTDumpCh:
+			s.dumpRIB(dumpCmd.Directory)
+
+		case <-s.RPKIUpdateCh:
+			s.processROATableUpdate()
+
 		case peerIP := <-s.PeerConnEstCh:
 			s.logger.Infof("Server: Peer %s FSM connection established", peerIP)
 			peer, ok := s.PeerMap[peerIP]
@@ -2284,7 +2648,11 @@ func (s *BGPServer) listenChannelUpdates() {
 
 		case pktInfo := <-s.BGPPktSrcCh:
 			s.logger.Info("Received BGP message from peer %s", pktInfo.Src)
-			s.ProcessUpdate(pktInfo)
+			if pktInfo.Msg.Header.Type == packet.BGPMsgTypeRouteRefresh {
+				s.ProcessRouteRefresh(pktInfo)
+			} else {
+				s.ProcessUpdate(pktInfo)
+			}
 
 		case reachabilityInfo := <-s.ReachabilityCh:
 			s.logger.Info("Server: Get reachability info for ip", reachabilityInfo.IP)
@@ -2307,32 +2675,31 @@ func (s *BGPServer) listenChannelUpdates() {
 					}
 				}
 			} else if ifState.State == config.INTF_CREATED {
-				s.ifaceMgr.AddIface(ifState.Idx, ifState.IPAddr)
 				s.handleIntfCreate(ifState.Idx, config.PeerAddressV4)
 			} else if ifState.State == config.INTF_DELETED {
-				s.ifaceMgr.RemoveIface(ifState.Idx, ifState.IPAddr)
 				s.handleIntfDelete(ifState.Idx, config.PeerAddressV4)
 			} else if ifState.State == config.INTFV6_CREATED {
-				s.ifaceMgr.AddV6Iface(ifState.Idx, ifState.IPAddr)
 				s.handleIntfCreate(ifState.Idx, config.PeerAddressV6)
 			} else if ifState.State == config.INTFV6_DELETED {
-				s.ifaceMgr.RemoveV6Iface(ifState.Idx, ifState.IPAddr)
 				s.handleIntfDelete(ifState.Idx, config.PeerAddressV6)
 			} else if ifState.State == config.IPV6_NEIGHBOR_CREATED {
 				s.logger.Info("IPV6_NEIGHBOR_CREATED message")
-				s.ifaceMgr.AddLinkLocalIface(ifState.Idx, ifState.LinkLocalIP)
 				s.handleIntfCreate(ifState.Idx, config.PeerAddressV6)
 			} else if ifState.State == config.IPV6_NEIGHBOR_DELETED {
-				s.ifaceMgr.RemoveLinkLocalIface(ifState.Idx, ifState.LinkLocalIP)
 				s.handleIntfDelete(ifState.Idx, config.PeerAddressV6)
 			}
 
-		case ifMap := <-s.IntfMapCh:
-			s.logger.Info("Received interface map")
-			s.ProcessIntfMapUpdates([]config.IntfMapInfo{config.IntfMapInfo{Idx: ifMap.Idx, IfName: ifMap.IfName}})
-
 		case routeInfo := <-s.RoutesCh:
 			s.ProcessConnectedRoutes(routeInfo.Add, routeInfo.Remove)
+
+		case <-s.vpnQueue.notifyCh:
+			s.processVPNUpdates()
+
+		case vtepInfo := <-s.EVPNVtepCh:
+			s.processEVPNVtep(vtepInfo)
+
+		case macInfo := <-s.EVPNMacCh:
+			s.processEVPNMac(macInfo)
 		}
 	}
 
@@ -2402,48 +2769,58 @@ func (s *BGPServer) StartServer() {
 	s.logger.Info("Setting serverup to true")
 
 	globalUpdate := <-s.GlobalConfigCh
-	gConf := globalUpdate.NewConfig
-	s.GlobalCfgDone = true
+	s.logger.Info("Start all managers and initialize API Layer")
+	s.IntfMgr.Start()
+	s.routeMgr.Start()
+	s.bfdMgr.Start()
+	s.evpnMgr.Start()
+
+	/*  ALERT: Every BGP instance runs in its own go routine. FlexSwitch uses thrift for rpc and hence
+	 *	   on return it will not know which go routine initiated the thrift call. The calls to the
+	 *	   clients are serialized by the managers that are shared by the instances.
+	 */
+	s.processGlobalUpdate(globalUpdate)
+	s.GetIntfObjects()
+	s.dispatchChannelUpdates()
+}
+
+func (s *BGPInstance) StartInstance(gConf config.GlobalConfig) {
 	s.logger.Info("Recieved global conf:", gConf)
 	s.BgpConfig.Global.Config = gConf
 	s.constructBGPGlobalState(&gConf)
 	s.BgpConfig.PeerGroups = make(map[uint32]map[string]*config.PeerGroup)
+	if gConf.GracefulRestart {
+		s.startGracefulRestart(&gConf)
+	}
 
 	pathAttrs := packet.ConstructPathAttrForConnRoutes(gConf.AS)
 	protoFamily := packet.GetProtocolFamily(packet.AfiIP6, packet.SafiUnicast)
 	ipv6MPReach := packet.ConstructIPv6MPReachNLRIForConnRoutes(protoFamily)
 	s.ConnRoutesPath = bgprib.NewPath(s.LocRib, nil, pathAttrs, ipv6MPReach, bgprib.RouteTypeConnected)
 
-	s.logger.Info("Setting up Peer connections")
-	// channel for accepting connections
-	s.acceptCh = make(chan *net.TCPConn)
-
-	s.listener, _ = s.createListener("tcp4")
-	go s.listenForPeers(s.listener, "tcp4", s.acceptCh)
+	s.logger.Info("Setting up Peer connections for VRF", gConf.Vrf)
+	var err error
+	if s.listener, err = s.createListener("tcp4"); err == nil {
+		go s.listenForPeers(s.listener, "tcp4", s.acceptCh)
+	}
 
-	s.listenerIPv6, _ = s.createListener("tcp6")
-	go s.listenForPeers(s.listenerIPv6, "tcp6", s.acceptCh)
+	if s.listenerIPv6, err = s.createListener("tcp6"); err == nil {
+		go s.listenForPeers(s.listenerIPv6, "tcp6", s.acceptCh)
+	}
 
-	s.logger.Info("Start all managers and initialize API Layer")
-	s.IntfMgr.Start()
-	s.routeMgr.Start()
-	s.bfdMgr.Start()
 	s.SetupRedistribution(gConf)
+	s.setupVPN(&gConf)
+	s.setupEVPN()
 
-	/*  ALERT: StartServer is a go routine and hence do not have any other go routine where
-	 *	   you are making calls to other client. FlexSwitch uses thrift for rpc and hence
-	 *	   on return it will not know which go routine initiated the thrift call.
-	 */
 	// Get routes from the route manager
-	add, remove := s.routeMgr.GetRoutes()
+	add, remove := s.getConnectedRoutes()
 	if add != nil && remove != nil {
 		s.ProcessConnectedRoutes(add, remove)
 	}
-	s.GetIntfObjects()
 	s.listenChannelUpdates()
 }
 
-func (s *BGPServer) GetBGPGlobalState() config.GlobalState {
+func (s *BGPInstance) GetBGPGlobalState() config.GlobalState {
 	routesCount := s.LocRib.GetRoutesCount()
 	s.BgpConfig.Global.State.Totalv4Prefixes = 0
 	s.BgpConfig.Global.State.Totalv6Prefixes = 0
@@ -2462,7 +2839,7 @@ func (s *BGPServer) GetBGPGlobalState() config.GlobalState {
 	return s.BgpConfig.Global.State
 }
 
-func (s *BGPServer) GetBGPNeighborState(neighborIP string) *config.NeighborState {
+func (s *BGPInstance) GetBGPNeighborState(neighborIP string) *config.NeighborState {
 	peer, ok := s.PeerMap[neighborIP]
 	if !ok {
 		s.logger.Errf("GetBGPNeighborState - Neighbor not found for address:%s", neighborIP)
@@ -2471,7 +2848,7 @@ func (s *BGPServer) GetBGPNeighborState(neighborIP string) *config.NeighborState
 	return &peer.NeighborConf.Neighbor.State
 }
 
-func (s *BGPServer) bulkGetBGPNeighbors(index int, count int, addrType config.PeerAddressType) (int, int,
+func (s *BGPInstance) bulkGetBGPNeighbors(index int, count int, addrType config.PeerAddressType) (int, int,
 	[]*config.NeighborState) {
 	defer s.NeighborMutex.RUnlock()
 
@@ -2499,15 +2876,15 @@ func (s *BGPServer) bulkGetBGPNeighbors(index int, count int, addrType config.Pe
 	return index, count, result
 }
 
-func (s *BGPServer) BulkGetBGPv4Neighbors(index int, count int) (int, int, []*config.NeighborState) {
+func (s *BGPInstance) BulkGetBGPv4Neighbors(index int, count int) (int, int, []*config.NeighborState) {
 	return s.bulkGetBGPNeighbors(index, count, config.PeerAddressV4)
 }
 
-func (s *BGPServer) BulkGetBGPv6Neighbors(index int, count int) (int, int, []*config.NeighborState) {
+func (s *BGPInstance) BulkGetBGPv6Neighbors(index int, count int) (int, int, []*config.NeighborState) {
 	return s.bulkGetBGPNeighbors(index, count, config.PeerAddressV6)
 }
 
-func (s *BGPServer) VerifyBgpGlobalConfig() bool {
+func (s *BGPInstance) VerifyBgpGlobalConfig() bool {
 	return s.GlobalCfgDone
 }
 
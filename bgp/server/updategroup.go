@@ -0,0 +1,285 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// updategroup.go
+package server
+
+import (
+	"fmt"
+	"l3/bgp/packet"
+	bgppolicy "l3/bgp/policy"
+	bgprib "l3/bgp/rib"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// UpdateGroup is a set of established peers with the same outbound attributes, the protocol families, peer
+// type, next hop self, route reflector client, ADD-PATH TX count and Adj-RIB-Out filter. The members share the
+// Adj-RIB-Out of the group. The updates are computed by the leader of the group, the first member, and the
+// messages are encoded once and sent to all the members.
+type UpdateGroup struct {
+	key      string
+	members  []*Peer
+	ribOut   map[uint32]map[string]*bgprib.AdjRIBRoute
+	withdraw map[*Peer]map[uint32][]packet.NLRI
+}
+
+func newUpdateGroup(key string, leader *Peer) *UpdateGroup {
+	return &UpdateGroup{
+		key:      key,
+		members:  []*Peer{leader},
+		ribOut:   leader.ribOut,
+		withdraw: make(map[*Peer]map[uint32][]packet.NLRI),
+	}
+}
+
+func (g *UpdateGroup) getLeader() *Peer {
+	return g.members[0]
+}
+
+func (g *UpdateGroup) addMember(peer *Peer) {
+	g.members = append(g.members, peer)
+	peer.ribOut = g.ribOut
+}
+
+// copyRibOut returns a deep copy of the Adj-RIB-Out of the group for the peer. The copied routes are added to
+// the RIB-Out policies of the group routes.
+func (g *UpdateGroup) copyRibOut(peer *Peer) map[uint32]map[string]*bgprib.AdjRIBRoute {
+	ribOut := make(map[uint32]map[string]*bgprib.AdjRIBRoute)
+	for protoFamily, routeMap := range g.ribOut {
+		ribOut[protoFamily] = make(map[string]*bgprib.AdjRIBRoute, len(routeMap))
+		for ip, route := range routeMap {
+			newRoute := bgprib.NewAdjRIBRoute(peer.NeighborConf.Neighbor.NeighborAddress, protoFamily, route.NLRI)
+			for pathId, path := range route.GetPathMap() {
+				newRoute.AddPath(pathId, path)
+			}
+			newRoute.PolicyHitCounter = route.PolicyHitCounter
+			newRoute.Accept = route.Accept
+			for _, policy := range route.PolicyList {
+				newRoute.PolicyList = append(newRoute.PolicyList, policy)
+				peer.server.ribOutPE.UpdateAdjRIBPolicyRouteMap(newRoute, policy, bgppolicy.Add)
+			}
+			ribOut[protoFamily][ip] = newRoute
+		}
+	}
+	return ribOut
+}
+
+// isRibOutEqual returns true if the peer Adj-RIB-Out has the same paths as the Adj-RIB-Out of the group.
+func (g *UpdateGroup) isRibOutEqual(ribOut map[uint32]map[string]*bgprib.AdjRIBRoute) bool {
+	for protoFamily, routeMap := range ribOut {
+		if len(routeMap) != len(g.ribOut[protoFamily]) {
+			return false
+		}
+	}
+	for protoFamily, routeMap := range g.ribOut {
+		if len(routeMap) != len(ribOut[protoFamily]) {
+			return false
+		}
+		for ip, route := range routeMap {
+			peerRoute, ok := ribOut[protoFamily][ip]
+			if !ok || len(peerRoute.GetPathMap()) != len(route.GetPathMap()) {
+				return false
+			}
+			for pathId, path := range route.GetPathMap() {
+				if peerRoute.GetPath(pathId) != path {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (g *UpdateGroup) removeMember(peer *Peer) {
+	for idx, member := range g.members {
+		if member == peer {
+			g.members = append(g.members[:idx], g.members[idx+1:]...)
+			break
+		}
+	}
+	delete(g.withdraw, peer)
+}
+
+// getSourceMember returns the member that sent the path. The path is not sent back to the member.
+func (g *UpdateGroup) getSourceMember(path *bgprib.Path) *Peer {
+	if path == nil || path.NeighborConf == nil {
+		return nil
+	}
+
+	for _, member := range g.members {
+		if member.NeighborConf.RunningConf.NeighborAddress.String() ==
+			path.NeighborConf.RunningConf.NeighborAddress.String() {
+			return member
+		}
+	}
+	return nil
+}
+
+// addSourceWithdraw withdraws the NLRI from the member that sent the new path when the group advertised a path
+// from another peer for the NLRI before. The new path is not sent to the member and doesn't replace the old one.
+func (g *UpdateGroup) addSourceWithdraw(path, oldPath *bgprib.Path, protoFamily uint32, nlri packet.NLRI) {
+	member := g.getSourceMember(path)
+	if member == nil || oldPath == nil || g.getSourceMember(oldPath) == member {
+		return
+	}
+
+	if _, ok := g.withdraw[member]; !ok {
+		g.withdraw[member] = make(map[uint32][]packet.NLRI)
+	}
+	g.withdraw[member][protoFamily] = append(g.withdraw[member][protoFamily], nlri)
+}
+
+func (g *UpdateGroup) sendSourceWithdraws() {
+	for member, withdrawList := range g.withdraw {
+		if member.fsmManager == nil {
+			continue
+		}
+
+		for protoFamily, nlriList := range withdrawList {
+			var updateMsg *packet.BGPMessage
+			if protoFamily == packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast) {
+				updateMsg = packet.NewBGPUpdateMessage(nlriList, nil, nil)
+			} else {
+				mpUnreachNLRI := packet.ConstructMPUnreachNLRIFromProtoFamily(protoFamily, nlriList)
+				updateMsg = packet.NewBGPUpdateMessage(nil, []packet.BGPPathAttr{mpUnreachNLRI}, nil)
+			}
+			member.logger.Infof("Neighbor %s: Send update message withdraw routes:%+v of the update group",
+				member.NeighborConf.Neighbor.NeighborAddress, nlriList)
+			atomic.AddUint32(&member.NeighborConf.Neighbor.State.Queues.Output, 1)
+			member.fsmManager.SendUpdateMsg(updateMsg)
+		}
+	}
+	g.withdraw = make(map[*Peer]map[uint32][]packet.NLRI)
+}
+
+// sendUpdateMsg updates the path attributes of the message for the group, encodes it and sends the encoded
+// packets to all the members except the one that sent the path.
+func (g *UpdateGroup) sendUpdateMsg(msg *packet.BGPMessage, path *bgprib.Path) {
+	leader := g.getLeader()
+	if path != nil && path.NeighborConf != nil && path.NeighborConf.IsInternal() &&
+		leader.NeighborConf.IsInternal() && !path.NeighborConf.IsRouteReflectorClient() &&
+		!leader.NeighborConf.IsRouteReflectorClient() {
+		return
+	}
+
+	if !leader.updatePathAttrs(msg, path) {
+		return
+	}
+
+	pkts, err := packet.EncodeMaxSizedUpdatePackets(msg)
+	if err != nil {
+		leader.logger.Errf("Update group %s: Failed to encode update message with error %s", g.key, err)
+		return
+	}
+
+	for _, member := range g.getTargetMembers(path) {
+		atomic.AddUint32(&member.NeighborConf.Neighbor.State.Queues.Output, 1)
+		member.fsmManager.SendEncodedUpdateMsg(pkts)
+	}
+}
+
+// getTargetMembers returns the members that are sent the update for the path, all the members with an FSM except
+// the one that sent the path.
+func (g *UpdateGroup) getTargetMembers(path *bgprib.Path) []*Peer {
+	source := g.getSourceMember(path)
+	members := make([]*Peer, 0, len(g.members))
+	for _, member := range g.members {
+		if member == source || member.fsmManager == nil {
+			continue
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// getUpdateGroupKey returns the outbound attributes of the peer that decide the update group. The peers with the
+// same key are sent the same update messages.
+func (p *Peer) getUpdateGroupKey() string {
+	protoFamilies := make([]string, 0)
+	for protoFamily, ok := range p.NeighborConf.AfiSafiMap {
+		if ok {
+			addPathsTx := p.NeighborConf.GetAddPathsTx(protoFamily)
+			protoFamilies = append(protoFamilies, fmt.Sprintf("%d:%s:%d", protoFamily, addPathsTx.TxMode,
+				addPathsTx.MaxTx))
+		}
+	}
+	sort.Strings(protoFamilies)
+
+	return fmt.Sprintf("%s|%t|%d|%d|%d|%t|%t|%s|%s|%s|%t|%t", strings.Join(protoFamilies, ","),
+		p.NeighborConf.IsInternal(), p.NeighborConf.RunningConf.PeerAS, p.NeighborConf.RunningConf.LocalAS,
+		p.NeighborConf.ASSize, p.NeighborConf.RunningConf.NextHopSelf, p.NeighborConf.IsRouteReflectorClient(),
+		p.NeighborConf.Neighbor.Config.AdjRIBOutFilter,
+		p.NeighborConf.Neighbor.Transport.Config.LocalAddress, p.NeighborConf.RunningConf.RemovePrivateAS,
+		p.NeighborConf.RunningConf.LocalASReplaceAS, p.NeighborConf.RunningConf.AsOverride)
+}
+
+// joinUpdateGroup adds the established peer to the update group of its outbound attributes. The peer must be
+// sent all the routes before joining, its Adj-RIB-Out is then the same as the one of the group.
+func (s *BGPInstance) joinUpdateGroup(peer *Peer) {
+	if peer.updateGroup != nil || peer.fsmManager == nil ||
+		peer.NeighborConf.Neighbor.Transport.Config.LocalAddress == nil {
+		return
+	}
+
+	// The routes advertised to the peer depend on the conditions of the peer
+	if peer.hasAdvConditions() {
+		return
+	}
+
+	key := peer.getUpdateGroupKey()
+	group, ok := s.updateGroups[key]
+	if ok {
+		group.addMember(peer)
+	} else {
+		group = newUpdateGroup(key, peer)
+		s.updateGroups[key] = group
+	}
+	peer.updateGroup = group
+	s.logger.Infof("Neighbor %s joined update group %s with %d members", peer.NeighborConf.Neighbor.NeighborAddress,
+		key, len(group.members))
+}
+
+// leaveUpdateGroup removes the peer from its update group. The peer gets a copy of the Adj-RIB-Out of the group,
+// the routes that were sent to the peer.
+func (s *BGPInstance) leaveUpdateGroup(peer *Peer) {
+	group := peer.updateGroup
+	if group == nil {
+		return
+	}
+
+	peer.updateGroup = nil
+	peer.ribOut = group.copyRibOut(peer)
+	for protoFamily, ok := range peer.NeighborConf.AfiSafiMap {
+		if _, exists := peer.ribOut[protoFamily]; ok && !exists {
+			peer.ribOut[protoFamily] = make(map[string]*bgprib.AdjRIBRoute)
+		}
+	}
+
+	group.removeMember(peer)
+	if len(group.members) == 0 {
+		delete(s.updateGroups, group.key)
+	}
+	s.logger.Infof("Neighbor %s left update group %s", peer.NeighborConf.Neighbor.NeighborAddress, group.key)
+}
@@ -0,0 +1,175 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// updategroup_test.go
+package server
+
+import (
+	"l3/bgp/baseobjects"
+	"l3/bgp/config"
+	"l3/bgp/fsm"
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"l3/bgp/utils"
+	"net"
+	"testing"
+	"utils/logging"
+)
+
+func getTestPeers(t *testing.T, neighbors ...string) (*BGPInstance, []*Peer) {
+	logger, err := logging.NewLogger("bgpd", "BGP", true)
+	if err != nil {
+		t.Fatal("Failed to start the logger. Exiting!!")
+	}
+	utils.SetLogger(logger)
+
+	s := &BGPInstance{BGPServer: &BGPServer{logger: logger}}
+	s.updateGroups = make(map[string]*UpdateGroup)
+	gConf := &config.GlobalConfig{}
+	gConf.AS = 1234
+	gConf.RouterId = net.ParseIP("10.1.10.100")
+	peers := make([]*Peer, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		pConf := config.NeighborConfig{}
+		pConf.NeighborAddress = net.ParseIP(neighbor)
+		pConf.PeerAS = 4321
+		nConf := base.NewNeighborConf(logger, gConf, nil, pConf)
+		nConf.Neighbor.Transport.Config.LocalAddress = net.ParseIP("10.1.10.1")
+		peer := &Peer{
+			server:           s,
+			logger:           logger,
+			NeighborConf:     nConf,
+			ribIn:            make(map[uint32]map[string]*bgprib.AdjRIBRoute),
+			ribOut:           make(map[uint32]map[string]*bgprib.AdjRIBRoute),
+			defaultRouteSent: make(map[uint32]bool),
+		}
+		peer.locRib = bgprib.NewLocRib(logger, nil, nil, gConf)
+		peer.initAdjRIBTables()
+		peer.fsmManager = fsm.NewFSMManager(logger, nConf, nil, nil, nil, nil)
+		peers = append(peers, peer)
+	}
+	return s, peers
+}
+
+func addUpdateGroupTestRoute(peer *Peer, path *bgprib.Path) {
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+	nlri := packet.NewIPPrefix(net.ParseIP("20.1.1.0"), 24)
+	route := bgprib.NewAdjRIBRoute(peer.NeighborConf.Neighbor.NeighborAddress, protoFamily, nlri)
+	route.AddPath(0, path)
+	peer.ribOut[protoFamily][nlri.GetCIDR()] = route
+}
+
+func TestUpdateGroupJoinLeave(t *testing.T) {
+	s, peers := getTestPeers(t, "192.168.0.1", "192.168.0.2")
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+	path := &bgprib.Path{}
+	addUpdateGroupTestRoute(peers[0], path)
+	for _, peer := range peers {
+		s.joinUpdateGroup(peer)
+	}
+
+	group := peers[0].updateGroup
+	if group == nil || peers[1].updateGroup != group || len(s.updateGroups) != 1 || len(group.members) != 2 {
+		t.Fatal("Peers with the same outbound attributes are not in one update group", s.updateGroups)
+	}
+	if group.getLeader() != peers[0] || len(peers[1].ribOut[protoFamily]) != 1 {
+		t.Fatal("Member does not share the Adj-RIB-Out of the leader", peers[1].ribOut)
+	}
+
+	s.leaveUpdateGroup(peers[1])
+	if peers[1].updateGroup != nil || len(group.members) != 1 {
+		t.Fatal("Peer did not leave the update group", group.members)
+	}
+	if !group.isRibOutEqual(peers[1].ribOut) {
+		t.Fatal("Peer that left the update group lost its Adj-RIB-Out", peers[1].ribOut)
+	}
+	for ip, route := range peers[1].ribOut[protoFamily] {
+		if route == group.ribOut[protoFamily][ip] {
+			t.Fatal("Adj-RIB-Out of the peer that left the update group is shared with the group")
+		}
+		route.RemoveAllPaths()
+	}
+	if group.isRibOutEqual(peers[1].ribOut) || len(group.ribOut[protoFamily]) != 1 ||
+		group.ribOut[protoFamily]["20.1.1.0/24"].GetPath(0) != path {
+		t.Fatal("Update of the Adj-RIB-Out of the peer that left changed the update group")
+	}
+
+	s.leaveUpdateGroup(peers[0])
+	if len(s.updateGroups) != 0 || !peers[0].ribOut[protoFamily]["20.1.1.0/24"].DoesPathsExist() {
+		t.Fatal("Update group without members not deleted or leader lost its Adj-RIB-Out", s.updateGroups)
+	}
+}
+
+func TestUpdateGroupFanOut(t *testing.T) {
+	s, peers := getTestPeers(t, "192.168.0.1", "192.168.0.2", "192.168.0.3")
+	for _, peer := range peers {
+		s.joinUpdateGroup(peer)
+	}
+	group := peers[0].updateGroup
+	if group.getLeader() != peers[0] {
+		t.Fatal("First member is not the leader of the update group")
+	}
+
+	if members := group.getTargetMembers(nil); len(members) != 3 {
+		t.Fatal("Locally originated update is not sent to all the members", members)
+	}
+	path := &bgprib.Path{NeighborConf: peers[1].NeighborConf}
+	members := group.getTargetMembers(path)
+	if len(members) != 2 || members[0] != peers[0] || members[1] != peers[2] {
+		t.Fatal("Update is sent back to the member that sent the path", members)
+	}
+
+	s.leaveUpdateGroup(peers[0])
+	if group.getLeader() != peers[1] || len(group.getTargetMembers(nil)) != 2 {
+		t.Fatal("Update group leader not changed after the leader left", group.members)
+	}
+}
+
+func TestUpdateGroupSourceWithdraw(t *testing.T) {
+	s, peers := getTestPeers(t, "192.168.0.1", "192.168.0.2")
+	for _, peer := range peers {
+		s.joinUpdateGroup(peer)
+	}
+	group := peers[0].updateGroup
+	protoFamily := packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast)
+	nlri := packet.NewIPPrefix(net.ParseIP("20.1.1.0"), 24)
+	oldPath := &bgprib.Path{NeighborConf: peers[0].NeighborConf}
+	path := &bgprib.Path{NeighborConf: peers[1].NeighborConf}
+
+	group.addSourceWithdraw(path, nil, protoFamily, nlri)
+	group.addSourceWithdraw(path, path, protoFamily, nlri)
+	group.addSourceWithdraw(&bgprib.Path{}, oldPath, protoFamily, nlri)
+	if len(group.withdraw) != 0 {
+		t.Fatal("Withdraw added without a path from another member", group.withdraw)
+	}
+
+	group.addSourceWithdraw(path, oldPath, protoFamily, nlri)
+	if len(group.withdraw) != 1 || len(group.withdraw[peers[1]][protoFamily]) != 1 {
+		t.Fatal("Old path not withdrawn from the member that sent the new path", group.withdraw)
+	}
+
+	group.sendSourceWithdraws()
+	if len(group.withdraw) != 0 {
+		t.Fatal("Withdraws not cleared after sending", group.withdraw)
+	}
+}
@@ -0,0 +1,505 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// vpn.go
+package server
+
+import (
+	"errors"
+	"fmt"
+	"l3/bgp/baseobjects"
+	"l3/bgp/config"
+	"l3/bgp/packet"
+	bgprib "l3/bgp/rib"
+	"net"
+	"sync"
+)
+
+const (
+	// Labels 0-15 are reserved, RFC 3032
+	labelMin uint32 = 16
+
+	// Sources of the paths exported from a VRF to the VPN table and imported from the VPN table to a VRF
+	vpnExportSrcPrefix = "VRF-"
+	vpnImportSrcPrefix = "VPN-"
+)
+
+// labelAllocator allocates the per-VRF labels of the routes exported to the VPN table and the local labels of
+// the labeled unicast routes. The labels are shared by all the instances.
+type labelAllocator struct {
+	mutex sync.Mutex
+	next  uint32
+	free  []uint32
+}
+
+func newLabelAllocator() *labelAllocator {
+	return &labelAllocator{
+		next: labelMin,
+		free: make([]uint32, 0),
+	}
+}
+
+func (a *labelAllocator) allocate() (uint32, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+
+	if len(a.free) > 0 {
+		label := a.free[len(a.free)-1]
+		a.free = a.free[:len(a.free)-1]
+		return label, nil
+	}
+
+	if a.next > packet.BGPLabelMax {
+		return 0, errors.New(fmt.Sprintf("All the labels from %d to %d are allocated", labelMin,
+			packet.BGPLabelMax))
+	}
+
+	label := a.next
+	a.next++
+	return label, nil
+}
+
+func (a *labelAllocator) release(label uint32) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	a.free = append(a.free, label)
+}
+
+// vpnUpdate is a VPN route passed between the instance of the default VRF, that owns the VPN table, and the
+// instances of the other VRFs. Path attrs are nil when the route is withdrawn.
+type vpnUpdate struct {
+	vrf          string
+	protoFamily  uint32
+	nlri         *packet.VPNPrefix
+	pathAttrs    []packet.BGPPathAttr
+	path         *bgprib.Path
+	neighborConf *base.NeighborConf
+	nextHop      net.IP
+	reachability *bgprib.ReachabilityInfo
+	replay       bool
+}
+
+// vpnQueue queues the VPN updates for an instance. Pushing an update never blocks, so the instances can
+// send the updates to each other from their own go routines.
+type vpnQueue struct {
+	mutex    sync.Mutex
+	updates  []*vpnUpdate
+	notifyCh chan bool
+}
+
+func newVPNQueue() *vpnQueue {
+	return &vpnQueue{
+		updates:  make([]*vpnUpdate, 0),
+		notifyCh: make(chan bool, 1),
+	}
+}
+
+func (q *vpnQueue) push(updates ...*vpnUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+
+	q.mutex.Lock()
+	q.updates = append(q.updates, updates...)
+	q.mutex.Unlock()
+
+	select {
+	case q.notifyCh <- true:
+	default:
+	}
+}
+
+func (q *vpnQueue) pop() []*vpnUpdate {
+	defer q.mutex.Unlock()
+	q.mutex.Lock()
+	updates := q.updates
+	q.updates = make([]*vpnUpdate, 0)
+	return updates
+}
+
+// vpnImport is a VPN route imported to the Loc-RIB of a VRF.
+type vpnImport struct {
+	src    string
+	prefix *packet.IPPrefix
+	path   *bgprib.Path
+}
+
+// vpnState is the VPN config of a VRF with a route distinguisher, and the routes exported from and imported
+// to the VRF.
+type vpnState struct {
+	rd        packet.RouteDistinguisher
+	label     uint32
+	importRTs []uint64
+	exportRTs []uint64
+	exported  map[uint32]map[string]*bgprib.Path
+	imported  map[uint32]map[string]*vpnImport
+}
+
+func parseRouteTargets(rtList []string) ([]uint64, error) {
+	routeTargets := make([]uint64, 0, len(rtList))
+	for _, rtStr := range rtList {
+		rt, err := packet.ParseRouteTarget(rtStr)
+		if err != nil {
+			return nil, err
+		}
+		routeTargets = append(routeTargets, rt)
+	}
+	return routeTargets, nil
+}
+
+func (s *BGPInstance) isDefaultVrf() bool {
+	return s.getVrf() == config.BGPDefaultVrf
+}
+
+// setupVPN sets up the import and the export of the VPN routes for a VRF with a route distinguisher. The
+// routes of the old config are withdrawn first.
+func (s *BGPInstance) setupVPN(gConf *config.GlobalConfig) {
+	if s.isDefaultVrf() {
+		return
+	}
+
+	s.teardownVPN()
+	if gConf.RouteDistinguisher == "" {
+		return
+	}
+
+	rd, err := packet.ParseRouteDistinguisher(gConf.RouteDistinguisher)
+	if err != nil {
+		s.logger.Err("VRF", s.getVrf(), "VPN not set up, error:", err)
+		return
+	}
+	importRTs, err := parseRouteTargets(gConf.ImportRouteTargets)
+	if err != nil {
+		s.logger.Err("VRF", s.getVrf(), "VPN not set up, import route targets error:", err)
+		return
+	}
+	exportRTs, err := parseRouteTargets(gConf.ExportRouteTargets)
+	if err != nil {
+		s.logger.Err("VRF", s.getVrf(), "VPN not set up, export route targets error:", err)
+		return
+	}
+	label, err := s.labels.allocate()
+	if err != nil {
+		s.logger.Err("VRF", s.getVrf(), "VPN not set up, error:", err)
+		return
+	}
+
+	s.logger.Infof("VRF %s: VPN route distinguisher %s, label %d", s.getVrf(), rd, label)
+	s.routeMgr.CreateVrfLabel(s.getVrf(), label)
+	s.vpn = &vpnState{
+		rd:        rd,
+		label:     label,
+		importRTs: importRTs,
+		exportRTs: exportRTs,
+		exported:  make(map[uint32]map[string]*bgprib.Path),
+		imported:  make(map[uint32]map[string]*vpnImport),
+	}
+
+	s.exportVPNRoutes(s.LocRib.GetLocRib(), make([]*bgprib.Destination, 0))
+	if inst := s.getInstance(config.BGPDefaultVrf); inst != nil {
+		inst.vpnQueue.push(&vpnUpdate{vrf: s.getVrf(), replay: true})
+	}
+}
+
+// teardownVPN withdraws the routes exported from the VRF, removes the routes imported to the VRF and
+// releases the label of the VRF.
+func (s *BGPInstance) teardownVPN() {
+	if s.vpn == nil {
+		return
+	}
+
+	vpn := s.vpn
+	updates := make([]*vpnUpdate, 0)
+	for protoFamily, cidrPathMap := range vpn.exported {
+		vpnProtoFamily := packet.UnicastFamilyToVPNFamilyMap[protoFamily]
+		for cidr := range cidrPathMap {
+			prefix, err := packet.ConstructIPPrefixFromCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			updates = append(updates, &vpnUpdate{vrf: s.getVrf(), protoFamily: vpnProtoFamily,
+				nlri: packet.NewVPNPrefix(vpn.rd, nil, prefix)})
+		}
+	}
+	if inst := s.getInstance(config.BGPDefaultVrf); inst != nil {
+		inst.vpnQueue.push(updates...)
+	}
+
+	for vpnProtoFamily, importMap := range vpn.imported {
+		for cidr := range importMap {
+			s.removeVPNImport(vpnProtoFamily, cidr)
+		}
+	}
+
+	s.routeMgr.DeleteVrfLabel(s.getVrf(), vpn.label)
+	s.labels.release(vpn.label)
+	s.vpn = nil
+}
+
+// exportVPNRoutes exports the best paths of the VRF to the VPN table with the route distinguisher, the label
+// and the export route targets of the VRF. The paths imported from the VPN table are not exported.
+func (s *BGPInstance) exportVPNRoutes(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination,
+	withdrawn []*bgprib.Destination) {
+	if s.vpn == nil {
+		return
+	}
+
+	updates := make([]*vpnUpdate, 0)
+	for protoFamily, pathDestMap := range updated {
+		vpnProtoFamily, ok := packet.UnicastFamilyToVPNFamilyMap[protoFamily]
+		if !ok {
+			continue
+		}
+		if _, ok := s.vpn.exported[protoFamily]; !ok {
+			s.vpn.exported[protoFamily] = make(map[string]*bgprib.Path)
+		}
+
+		for path, destinations := range pathDestMap {
+			for _, dest := range destinations {
+				if dest == nil {
+					continue
+				}
+				prefix := dest.NLRI.GetIPPrefix()
+				cidr := prefix.GetCIDR()
+				if path.IsVPNPath() {
+					if _, ok := s.vpn.exported[protoFamily][cidr]; ok {
+						delete(s.vpn.exported[protoFamily], cidr)
+						updates = append(updates, &vpnUpdate{vrf: s.getVrf(), protoFamily: vpnProtoFamily,
+							nlri: packet.NewVPNPrefix(s.vpn.rd, nil, prefix)})
+					}
+					continue
+				}
+
+				if s.vpn.exported[protoFamily][cidr] == path {
+					continue
+				}
+				s.vpn.exported[protoFamily][cidr] = path
+				pathAttrs := packet.CopyPathAttrs(path.PathAttrs)
+				packet.RemoveNextHop(&pathAttrs)
+				pathAttrs = packet.SetRouteTargets(pathAttrs, s.vpn.exportRTs)
+				updates = append(updates, &vpnUpdate{vrf: s.getVrf(), protoFamily: vpnProtoFamily,
+					nlri: packet.NewVPNPrefix(s.vpn.rd, []uint32{s.vpn.label}, prefix), pathAttrs: pathAttrs})
+			}
+		}
+	}
+
+	for _, dest := range withdrawn {
+		if dest == nil {
+			continue
+		}
+		protoFamily := dest.GetProtocolFamily()
+		vpnProtoFamily, ok := packet.UnicastFamilyToVPNFamilyMap[protoFamily]
+		if !ok {
+			continue
+		}
+		prefix := dest.NLRI.GetIPPrefix()
+		if _, ok := s.vpn.exported[protoFamily][prefix.GetCIDR()]; ok {
+			delete(s.vpn.exported[protoFamily], prefix.GetCIDR())
+			updates = append(updates, &vpnUpdate{vrf: s.getVrf(), protoFamily: vpnProtoFamily,
+				nlri: packet.NewVPNPrefix(s.vpn.rd, nil, prefix)})
+		}
+	}
+
+	if inst := s.getInstance(config.BGPDefaultVrf); inst != nil {
+		inst.vpnQueue.push(updates...)
+	}
+}
+
+// getVPNImportUpdate returns the update to import the best path of a VPN route to the VRFs. Only the paths
+// received from the neighbors are imported, the paths exported from the VRFs of this router are withdrawn.
+func getVPNImportUpdate(vpnProtoFamily uint32, path *bgprib.Path, dest *bgprib.Destination) *vpnUpdate {
+	nlri, ok := dest.NLRI.(*packet.VPNPrefix)
+	if !ok {
+		return nil
+	}
+
+	update := &vpnUpdate{protoFamily: vpnProtoFamily, nlri: nlri}
+	if path != nil && path.NeighborConf != nil && !path.IsVPNPath() {
+		update.pathAttrs = path.PathAttrs
+		update.path = path
+		update.neighborConf = path.NeighborConf
+		update.nextHop = path.GetNextHop(vpnProtoFamily)
+		update.reachability = path.GetReachability(vpnProtoFamily)
+	}
+	return update
+}
+
+// distributeVPNRoutes sends the updated and the withdrawn VPN routes of the default VRF to the other VRFs.
+func (s *BGPInstance) distributeVPNRoutes(updated map[uint32]map[*bgprib.Path][]*bgprib.Destination,
+	withdrawn []*bgprib.Destination) {
+	if !s.isDefaultVrf() {
+		return
+	}
+
+	updates := make([]*vpnUpdate, 0)
+	for protoFamily, pathDestMap := range updated {
+		if !packet.IsVPNFamily(protoFamily) {
+			continue
+		}
+		for path, destinations := range pathDestMap {
+			for _, dest := range destinations {
+				if dest == nil {
+					continue
+				}
+				if update := getVPNImportUpdate(protoFamily, path, dest); update != nil {
+					updates = append(updates, update)
+				}
+			}
+		}
+	}
+
+	for _, dest := range withdrawn {
+		if dest == nil || !packet.IsVPNFamily(dest.GetProtocolFamily()) {
+			continue
+		}
+		if update := getVPNImportUpdate(dest.GetProtocolFamily(), nil, dest); update != nil {
+			updates = append(updates, update)
+		}
+	}
+
+	if len(updates) == 0 {
+		return
+	}
+	for _, inst := range s.getActiveInstances() {
+		if !inst.isDefaultVrf() {
+			inst.vpnQueue.push(updates...)
+		}
+	}
+}
+
+// replayVPNRoutes sends all the VPN routes of the default VRF to a VRF.
+func (s *BGPInstance) replayVPNRoutes(vrf string) {
+	inst := s.getInstance(vrf)
+	if inst == nil {
+		return
+	}
+
+	updates := make([]*vpnUpdate, 0)
+	for protoFamily, pathDestMap := range s.LocRib.GetLocRib() {
+		if !packet.IsVPNFamily(protoFamily) {
+			continue
+		}
+		for path, destinations := range pathDestMap {
+			for _, dest := range destinations {
+				if update := getVPNImportUpdate(protoFamily, path, dest); update != nil {
+					updates = append(updates, update)
+				}
+			}
+		}
+	}
+	s.logger.Infof("Replay %d VPN routes to VRF %s", len(updates), vrf)
+	inst.vpnQueue.push(updates...)
+}
+
+// processVPNExport adds or removes a route exported from a VRF in the VPN table of the default VRF.
+func (s *BGPInstance) processVPNExport(update *vpnUpdate) {
+	src := vpnExportSrcPrefix + update.vrf
+	var updated map[uint32]map[*bgprib.Path][]*bgprib.Destination
+	var withdrawn, updatedAddPaths []*bgprib.Destination
+	if update.pathAttrs != nil {
+		path := bgprib.NewVPNPath(s.LocRib, nil, update.pathAttrs, nil, bgprib.RouteTypeStatic, update.nlri.Labels)
+		updated, withdrawn, updatedAddPaths = s.LocRib.ProcessVPNRoutes(src, []packet.NLRI{update.nlri}, nil, path,
+			update.protoFamily, s.AddPathCount)
+	} else {
+		updated, withdrawn, updatedAddPaths = s.LocRib.ProcessVPNRoutes(src, nil, []packet.NLRI{update.nlri}, nil,
+			update.protoFamily, s.AddPathCount)
+	}
+	updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+}
+
+func (s *BGPInstance) removeVPNImport(vpnProtoFamily uint32, cidr string) {
+	imported, ok := s.vpn.imported[vpnProtoFamily][cidr]
+	if !ok {
+		return
+	}
+
+	delete(s.vpn.imported[vpnProtoFamily], cidr)
+	protoFamily := packet.VPNFamilyToUnicastFamilyMap[vpnProtoFamily]
+	updated, withdrawn, updatedAddPaths := s.LocRib.ProcessVPNRoutes(imported.src, nil,
+		[]packet.NLRI{imported.prefix}, nil, protoFamily, s.AddPathCount)
+	updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+}
+
+// processVPNImport imports a VPN route to the Loc-RIB of the VRF if the route has one of the import route
+// targets of the VRF. The route imported earlier is removed if the route is withdrawn or does not match the
+// import route targets anymore.
+func (s *BGPInstance) processVPNImport(update *vpnUpdate) {
+	if s.vpn == nil {
+		return
+	}
+
+	cidr := update.nlri.GetCIDR()
+	if update.pathAttrs == nil || update.reachability == nil ||
+		!packet.HasRouteTarget(update.pathAttrs, s.vpn.importRTs) {
+		s.removeVPNImport(update.protoFamily, cidr)
+		return
+	}
+
+	if imported, ok := s.vpn.imported[update.protoFamily][cidr]; ok && imported.path == update.path {
+		return
+	}
+
+	protoFamily := packet.VPNFamilyToUnicastFamilyMap[update.protoFamily]
+	pathAttrs := packet.CopyPathAttrs(update.pathAttrs)
+	var mpReach *packet.BGPPathAttrMPReachNLRI
+	if protoFamily == packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast) {
+		packet.RemoveNextHop(&pathAttrs)
+		nextHop := packet.NewBGPPathAttrNextHop()
+		nextHop.Value = update.nextHop
+		pathAttrs = packet.AddPathAttrToPathAttrsByCode(pathAttrs, packet.BGPPathAttrTypeNextHop, nextHop)
+	} else {
+		mpReach = packet.ConstructIPv6MPReachNLRI(protoFamily, update.nextHop.To16(), nil, nil)
+	}
+	path := bgprib.NewVPNPath(s.LocRib, update.neighborConf, pathAttrs, mpReach, bgprib.RouteTypeEGP,
+		update.nlri.Labels)
+	path.SetReachabilityForFamily(protoFamily, update.reachability)
+
+	if _, ok := s.vpn.imported[update.protoFamily]; !ok {
+		s.vpn.imported[update.protoFamily] = make(map[string]*vpnImport)
+	}
+	imported := &vpnImport{
+		src:    vpnImportSrcPrefix + update.nlri.RD.String(),
+		prefix: update.nlri.IPPrefix,
+		path:   update.path,
+	}
+	s.vpn.imported[update.protoFamily][cidr] = imported
+	s.logger.Infof("VRF %s: import VPN route %s", s.getVrf(), cidr)
+	updated, withdrawn, updatedAddPaths := s.LocRib.ProcessVPNRoutes(imported.src, []packet.NLRI{imported.prefix},
+		nil, path, protoFamily, s.AddPathCount)
+	updated, withdrawn, updatedAddPaths = s.CheckForAggregation(updated, withdrawn, updatedAddPaths)
+	s.SendUpdate(updated, withdrawn, updatedAddPaths)
+}
+
+func (s *BGPInstance) processVPNUpdates() {
+	for _, update := range s.vpnQueue.pop() {
+		if update.replay {
+			s.replayVPNRoutes(update.vrf)
+		} else if s.isDefaultVrf() {
+			s.processVPNExport(update)
+		} else {
+			s.processVPNImport(update)
+		}
+	}
+}
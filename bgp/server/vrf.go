@@ -0,0 +1,498 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// vrf.go
+package server
+
+import (
+	"l3/bgp/config"
+	"l3/bgp/packet"
+	"models/objects"
+	"sort"
+	"sync"
+	"time"
+	"utils/statedbclient"
+
+	"bgpd"
+)
+
+// getVrfName returns the name of the VRF used for the config. An empty VRF refers to the default VRF.
+func getVrfName(vrf string) string {
+	if vrf == "" {
+		return config.BGPDefaultVrf
+	}
+	return vrf
+}
+
+func filterRoutesByVrf(routes []*config.RouteInfo, vrf string) []*config.RouteInfo {
+	vrfRoutes := make([]*config.RouteInfo, 0)
+	for _, route := range routes {
+		if getVrfName(route.Vrf) == vrf {
+			vrfRoutes = append(vrfRoutes, route)
+		}
+	}
+	return vrfRoutes
+}
+
+func (s *BGPServer) getInstance(vrf string) *BGPInstance {
+	defer s.vrfMutex.RUnlock()
+	s.vrfMutex.RLock()
+	return s.instances[getVrfName(vrf)]
+}
+
+// getActiveInstance returns the instance of the VRF if the BGP global config was created for the VRF.
+func (s *BGPServer) getActiveInstance(vrf string) *BGPInstance {
+	inst := s.getInstance(vrf)
+	if inst == nil || !inst.GlobalCfgDone {
+		s.logger.Err("BGP instance not found for VRF", getVrfName(vrf))
+		return nil
+	}
+	return inst
+}
+
+// getActiveInstances returns the instances with the BGP global config, sorted by the VRF name.
+func (s *BGPServer) getActiveInstances() []*BGPInstance {
+	defer s.vrfMutex.RUnlock()
+	s.vrfMutex.RLock()
+
+	vrfs := make([]string, 0, len(s.instances))
+	for vrf, inst := range s.instances {
+		if inst.GlobalCfgDone {
+			vrfs = append(vrfs, vrf)
+		}
+	}
+	sort.Strings(vrfs)
+
+	instances := make([]*BGPInstance, 0, len(vrfs))
+	for _, vrf := range vrfs {
+		instances = append(instances, s.instances[vrf])
+	}
+	return instances
+}
+
+func (s *BGPServer) processGlobalUpdate(globalUpdate GlobalUpdate) {
+	vrf := getVrfName(globalUpdate.NewConfig.Vrf)
+	globalUpdate.NewConfig.Vrf = vrf
+	s.logger.Info("Global config", globalUpdate.Op, "for VRF", vrf)
+
+	switch globalUpdate.Op {
+	case "create":
+		inst := s.getInstance(vrf)
+		if inst == nil {
+			inst = NewBGPInstance(s, vrf)
+			s.vrfMutex.Lock()
+			s.instances[vrf] = inst
+			s.vrfMutex.Unlock()
+		}
+
+		if !inst.GlobalCfgDone {
+			inst.GlobalCfgDone = true
+			go inst.StartInstance(globalUpdate.NewConfig)
+		} else {
+			inst.GlobalConfigCh <- globalUpdate
+		}
+
+	case "update":
+		if inst := s.getActiveInstance(vrf); inst != nil {
+			inst.GlobalConfigCh <- globalUpdate
+		}
+
+	case "delete":
+		if vrf == config.BGPDefaultVrf {
+			s.logger.Err("Can't delete the BGP instance of the default VRF")
+			break
+		}
+
+		inst := s.getActiveInstance(vrf)
+		if inst == nil {
+			break
+		}
+		s.vrfMutex.Lock()
+		delete(s.instances, vrf)
+		s.vrfMutex.Unlock()
+		inst.GlobalConfigCh <- globalUpdate
+	}
+}
+
+func (s *BGPServer) dispatchRoutes(routeInfo *config.RouteCh) {
+	for _, inst := range s.getActiveInstances() {
+		add := filterRoutesByVrf(routeInfo.Add, inst.getVrf())
+		remove := filterRoutesByVrf(routeInfo.Remove, inst.getVrf())
+		if len(add) > 0 || len(remove) > 0 {
+			inst.RoutesCh <- &config.RouteCh{Add: add, Remove: remove}
+		}
+	}
+}
+
+func (s *BGPServer) dispatchChannelUpdates() {
+	for {
+		select {
+		case globalUpdate := <-s.GlobalConfigCh:
+			s.processGlobalUpdate(globalUpdate)
+
+		case peerUpdate := <-s.AddPeerCh:
+			peerUpdate.NewPeer.Vrf = getVrfName(peerUpdate.NewPeer.Vrf)
+			if inst := s.getActiveInstance(peerUpdate.NewPeer.Vrf); inst != nil {
+				inst.AddPeerCh <- peerUpdate
+			}
+
+		case remPeer := <-s.RemPeerCh:
+			remPeer.Vrf = getVrfName(remPeer.Vrf)
+			if inst := s.getActiveInstance(remPeer.Vrf); inst != nil {
+				inst.RemPeerCh <- remPeer
+			}
+
+		case groupUpdate := <-s.AddPeerGroupCh:
+			groupUpdate.NewGroup.Vrf = getVrfName(groupUpdate.NewGroup.Vrf)
+			if inst := s.getActiveInstance(groupUpdate.NewGroup.Vrf); inst != nil {
+				inst.AddPeerGroupCh <- groupUpdate
+			}
+
+		case group := <-s.RemPeerGroupCh:
+			group.Vrf = getVrfName(group.Vrf)
+			if inst := s.getActiveInstance(group.Vrf); inst != nil {
+				inst.RemPeerGroupCh <- group
+			}
+
+		case aggUpdate := <-s.AddAggCh:
+			aggUpdate.NewAgg.Vrf = getVrfName(aggUpdate.NewAgg.Vrf)
+			if inst := s.getActiveInstance(aggUpdate.NewAgg.Vrf); inst != nil {
+				inst.AddAggCh <- aggUpdate
+			}
+
+		case aggConf := <-s.RemAggCh:
+			aggConf.Vrf = getVrfName(aggConf.Vrf)
+			if inst := s.getActiveInstance(aggConf.Vrf); inst != nil {
+				inst.RemAggCh <- aggConf
+			}
+
+		case rangeConf := <-s.AddRangeCh:
+			rangeConf.Vrf = getVrfName(rangeConf.Vrf)
+			if inst := s.getActiveInstance(rangeConf.Vrf); inst != nil {
+				inst.AddRangeCh <- rangeConf
+			}
+
+		case rangeConf := <-s.RemRangeCh:
+			rangeConf.Vrf = getVrfName(rangeConf.Vrf)
+			if inst := s.getActiveInstance(rangeConf.Vrf); inst != nil {
+				inst.RemRangeCh <- rangeConf
+			}
+
+		case peerCommand := <-s.PeerCommandCh:
+			if inst := s.getActiveInstance(peerCommand.Vrf); inst != nil {
+				inst.PeerCommandCh <- peerCommand
+			}
+
+		case softReset := <-s.SoftResetCh:
+			if inst := s.getActiveInstance(softReset.Vrf); inst != nil {
+				inst.SoftResetCh <- softReset
+			}
+
+		case bfdNotify := <-s.BfdCh:
+			for _, inst := range s.getActiveInstances() {
+				inst.BfdCh <- bfdNotify
+			}
+
+		case ifState := <-s.IntfCh:
+			s.logger.Info("Received message on ItfCh")
+			s.ProcessIntfStates([]*config.IntfStateInfo{&ifState})
+			for _, inst := range s.getActiveInstances() {
+				inst.IntfCh <- ifState
+			}
+
+		case ifMap := <-s.IntfMapCh:
+			s.logger.Info("Received message on IntfMapCh")
+			s.ProcessIntfMapUpdates([]config.IntfMapInfo{ifMap})
+
+		case routeInfo := <-s.RoutesCh:
+			s.dispatchRoutes(routeInfo)
+
+		case vtepInfo := <-s.EVPNVtepCh:
+			if inst := s.getActiveInstance(config.BGPDefaultVrf); inst != nil {
+				inst.EVPNVtepCh <- vtepInfo
+			}
+
+		case macInfo := <-s.EVPNMacCh:
+			if inst := s.getActiveInstance(config.BGPDefaultVrf); inst != nil {
+				inst.EVPNMacCh <- macInfo
+			}
+
+		case colUpdate := <-s.AddBMPColCh:
+			s.processBMPCollectorUpdate(colUpdate)
+
+		case colConf := <-s.RemBMPColCh:
+			s.processBMPCollectorDelete(colConf)
+
+		case mrtConf := <-s.MRTConfigCh:
+			s.processMRTConfig(mrtConf)
+
+		case dumpCmd := <-s.MRTDumpCh:
+			s.processMRTDump(dumpCmd)
+
+		case <-s.mrtDumpTimer.C:
+			s.processMRTDump(config.MRTDumpCommand{})
+			if s.mrtConfig.RIBDumpInterval > 0 {
+				s.mrtDumpTimer.Reset(time.Duration(s.mrtConfig.RIBDumpInterval) * time.Second)
+			}
+
+		case cacheConf := <-s.RPKICacheCh:
+			s.processRPKICacheConfig(cacheConf)
+
+		case <-s.rpkiClient.UpdateCh:
+			for _, inst := range s.getActiveInstances() {
+				inst.RPKIUpdateCh <- true
+			}
+		}
+	}
+}
+
+func (s *BGPServer) GetBGPGlobalState(vrf string) (config.GlobalState, bool) {
+	inst := s.getInstance(vrf)
+	if inst == nil || !inst.GlobalCfgDone {
+		return config.GlobalState{}, false
+	}
+	return inst.GetBGPGlobalState(), true
+}
+
+func (s *BGPServer) GetBGPGlobalStates() []config.GlobalState {
+	instances := s.getActiveInstances()
+	states := make([]config.GlobalState, 0, len(instances))
+	for _, inst := range instances {
+		states = append(states, inst.GetBGPGlobalState())
+	}
+	return states
+}
+
+func (s *BGPServer) GetBGPNeighborState(vrf string, neighborIP string) *config.NeighborState {
+	inst := s.getInstance(vrf)
+	if inst == nil {
+		s.logger.Errf("GetBGPNeighborState - VRF %s not found", getVrfName(vrf))
+		return nil
+	}
+	return inst.GetBGPNeighborState(neighborIP)
+}
+
+func (s *BGPServer) bulkGetBGPNeighbors(index int, count int, addrType config.PeerAddressType) (int, int,
+	[]*config.NeighborState) {
+	neighbors := make([]*config.NeighborState, 0)
+	for _, inst := range s.getActiveInstances() {
+		_, num, result := inst.bulkGetBGPNeighbors(0, index+count+1, addrType)
+		if num > 0 {
+			neighbors = append(neighbors, result...)
+		}
+	}
+
+	if index >= len(neighbors) {
+		return 0, 0, make([]*config.NeighborState, 0)
+	}
+
+	if index+count < len(neighbors) {
+		return index + count, count, neighbors[index : index+count]
+	}
+	return 0, len(neighbors) - index, neighbors[index:]
+}
+
+func (s *BGPServer) BulkGetBGPv4Neighbors(index int, count int) (int, int, []*config.NeighborState) {
+	return s.bulkGetBGPNeighbors(index, count, config.PeerAddressV4)
+}
+
+func (s *BGPServer) BulkGetBGPv6Neighbors(index int, count int) (int, int, []*config.NeighborState) {
+	return s.bulkGetBGPNeighbors(index, count, config.PeerAddressV6)
+}
+
+func (s *BGPServer) getBGPRoute(vrf string, prefix string, protoFamily uint32) interface{} {
+	inst := s.getInstance(vrf)
+	if inst == nil {
+		return nil
+	}
+	return inst.LocRib.GetBGPRoute(prefix, protoFamily)
+}
+
+func (s *BGPServer) GetBGPv4Route(vrf string, prefix string) *bgpd.BGPv4RouteState {
+	route := s.getBGPRoute(vrf, prefix, packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast))
+	if route == nil {
+		return nil
+	}
+	return route.(*bgpd.BGPv4RouteState)
+}
+
+func (s *BGPServer) GetBGPv6Route(vrf string, prefix string) *bgpd.BGPv6RouteState {
+	route := s.getBGPRoute(vrf, prefix, packet.GetProtocolFamily(packet.AfiIP6, packet.SafiUnicast))
+	if route == nil {
+		return nil
+	}
+	return route.(*bgpd.BGPv6RouteState)
+}
+
+// bulkGetBGPRoutes walks the route lists of the instances in the order of the VRF names. The index is the offset
+// into the route lists of all the instances.
+func (s *BGPServer) bulkGetBGPRoutes(index int, count int, protoFamily uint32) (int, int, []interface{}) {
+	result := make([]interface{}, 0, count)
+	instances := s.getActiveInstances()
+	base := 0
+	for i, inst := range instances {
+		if listLen := inst.LocRib.GetRouteListLen(protoFamily); index >= base+listLen {
+			base += listLen
+			continue
+		}
+
+		nextIdx, _, routes := inst.LocRib.BulkGetBGPRoutes(index-base, count-len(result), protoFamily)
+		result = append(result, routes...)
+		if nextIdx != 0 {
+			return base + nextIdx, len(result), result
+		}
+
+		base += inst.LocRib.GetRouteListLen(protoFamily)
+		index = base
+		if len(result) >= count && i < len(instances)-1 {
+			return index, len(result), result
+		}
+	}
+	return 0, len(result), result
+}
+
+func (s *BGPServer) BulkGetBGPv4Routes(index int, count int) (int, int, []*bgpd.BGPv4RouteState) {
+	i, n, routes := s.bulkGetBGPRoutes(index, count, packet.GetProtocolFamily(packet.AfiIP, packet.SafiUnicast))
+	thriftRoutes := make([]*bgpd.BGPv4RouteState, len(routes))
+	for idx, route := range routes {
+		thriftRoutes[idx] = route.(*bgpd.BGPv4RouteState)
+	}
+	return i, n, thriftRoutes
+}
+
+func (s *BGPServer) BulkGetBGPv6Routes(index int, count int) (int, int, []*bgpd.BGPv6RouteState) {
+	i, n, routes := s.bulkGetBGPRoutes(index, count, packet.GetProtocolFamily(packet.AfiIP6, packet.SafiUnicast))
+	thriftRoutes := make([]*bgpd.BGPv6RouteState, len(routes))
+	for idx, route := range routes {
+		thriftRoutes[idx] = route.(*bgpd.BGPv6RouteState)
+	}
+	return i, n, thriftRoutes
+}
+
+// serialRouteMgr serializes the calls to the route manager from the instances.
+type serialRouteMgr struct {
+	config.RouteMgrIntf
+	mutex sync.Mutex
+}
+
+func (r *serialRouteMgr) GetNextHopInfo(ipAddr string, ifIndex int32) (*config.NextHopInfo, error) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	return r.RouteMgrIntf.GetNextHopInfo(ipAddr, ifIndex)
+}
+
+func (r *serialRouteMgr) CreateRoute(cfg *config.RouteConfig) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.CreateRoute(cfg)
+}
+
+func (r *serialRouteMgr) DeleteRoute(cfg *config.RouteConfig) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.DeleteRoute(cfg)
+}
+
+func (r *serialRouteMgr) UpdateRoute(cfg *config.RouteConfig, op string) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.UpdateRoute(cfg, op)
+}
+
+func (r *serialRouteMgr) ApplyPolicy(applyList []*config.ApplyPolicyInfo, undoList []*config.ApplyPolicyInfo) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.ApplyPolicy(applyList, undoList)
+}
+
+func (r *serialRouteMgr) GetRoutes() ([]*config.RouteInfo, []*config.RouteInfo) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	return r.RouteMgrIntf.GetRoutes()
+}
+
+func (r *serialRouteMgr) CreateVrfLabel(vrf string, label uint32) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.CreateVrfLabel(vrf, label)
+}
+
+func (r *serialRouteMgr) DeleteVrfLabel(vrf string, label uint32) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.DeleteVrfLabel(vrf, label)
+}
+
+func (r *serialRouteMgr) CreateLocalLabel(label uint32, cfg *config.RouteConfig) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.CreateLocalLabel(label, cfg)
+}
+
+func (r *serialRouteMgr) DeleteLocalLabel(label uint32) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	r.RouteMgrIntf.DeleteLocalLabel(label)
+}
+
+// serialBfdMgr serializes the calls to the BFD manager from the instances.
+type serialBfdMgr struct {
+	config.BfdMgrIntf
+	mutex sync.Mutex
+}
+
+func (b *serialBfdMgr) CreateBfdSession(ipAddr string, iface string, sessionParam string) (bool, error) {
+	defer b.mutex.Unlock()
+	b.mutex.Lock()
+	return b.BfdMgrIntf.CreateBfdSession(ipAddr, iface, sessionParam)
+}
+
+func (b *serialBfdMgr) DeleteBfdSession(ipAddr string, iface string) (bool, error) {
+	defer b.mutex.Unlock()
+	b.mutex.Lock()
+	return b.BfdMgrIntf.DeleteBfdSession(ipAddr, iface)
+}
+
+// serialStateDBClient serializes the updates to the state DB from the instances.
+type serialStateDBClient struct {
+	statedbclient.StateDBClient
+	mutex sync.Mutex
+}
+
+func (c *serialStateDBClient) AddObject(obj objects.ConfigObj) error {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	return c.StateDBClient.AddObject(obj)
+}
+
+func (c *serialStateDBClient) DeleteObject(obj objects.ConfigObj) error {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	return c.StateDBClient.DeleteObject(obj)
+}
+
+func (c *serialStateDBClient) UpdateObject(obj objects.ConfigObj) error {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	return c.StateDBClient.UpdateObject(obj)
+}
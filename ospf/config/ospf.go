@@ -23,7 +23,9 @@
 
 package config
 
-import ()
+import (
+	"time"
+)
 
 type AreaId string
 type RouterId string
@@ -69,10 +71,44 @@ type AuthType int
 const (
 	NoAuth         AuthType = 0
 	SimplePassword AuthType = 1
-	Md5            AuthType = 2
+	Md5            AuthType = 2 // Cryptographic authentication with MD5 or HMAC-SHA (RFC 5709) keys
 	Reserved       AuthType = 3
 )
 
+type AuthAlgorithm int
+
+const (
+	AuthMd5        AuthAlgorithm = 1
+	AuthHmacSha1   AuthAlgorithm = 2
+	AuthHmacSha256 AuthAlgorithm = 3
+	AuthHmacSha384 AuthAlgorithm = 4
+	AuthHmacSha512 AuthAlgorithm = 5
+)
+
+var AuthAlgorithmList = []string{
+	"Undefined",
+	"Md5",
+	"HmacSha1",
+	"HmacSha256",
+	"HmacSha384",
+	"HmacSha512"}
+
+/*
+AuthKey is a key of the interface key chain used for the cryptographic
+authentication. A zero start time means the key is valid right away and a
+zero end time that the key never expires. The send and accept lifetimes
+of the keys overlap during a key rollover.
+*/
+type AuthKey struct {
+	KeyId       uint8
+	Algorithm   AuthAlgorithm
+	Key         string
+	SendStart   time.Time
+	SendEnd     time.Time
+	AcceptStart time.Time
+	AcceptEnd   time.Time
+}
+
 type RestartSupport int
 
 const (
@@ -134,6 +170,7 @@ const (
 	UnnumberedP2P     IfType = 4
 	PointToMultipoint IfType = 5
 	Stub              IfType = 6
+	VirtualLink       IfType = 7
 )
 
 var IfTypeList = []string{
@@ -143,7 +180,8 @@ var IfTypeList = []string{
 	"NumberedP2P",
 	"UnnumberedP2P",
 	"PointToMultipoint",
-	"Stub"}
+	"Stub",
+	"VirtualLink"}
 
 type MulticastForwarding int
 
@@ -254,6 +292,9 @@ const (
 	Helping    NbrRestartHelperStatus = 2
 )
 
+// Throttle timers left unset (0) use the default value, ThrottleNoDelay removes the delay
+const ThrottleNoDelay int32 = -1
+
 type GlobalConf struct {
 	RouterId           RouterId
 	AdminStat          Status
@@ -262,6 +303,15 @@ type GlobalConf struct {
 	RestartSupport     RestartSupport
 	RestartInterval    int32
 	ReferenceBandwidth uint32
+	OpaqueLsaSupport   bool
+	TESupport          bool // RFC 3630 traffic engineering, requires opaque LSA support
+	SpfThrottleInitial int32 // msec, SPF delay after a quiet period
+	SpfThrottleHold    int32 // msec, minimum hold between SPF runs, doubled up to max wait
+	SpfThrottleMaxWait int32 // msec
+	LsaThrottleInitial int32 // msec, self originated LSA generation delay
+	LsaThrottleHold    int32 // msec
+	LsaThrottleMaxWait int32 // msec
+	LsaArrivalInterval int32 // msec, MinLSArrival
 }
 
 type GlobalState struct {
@@ -281,6 +331,21 @@ type GlobalState struct {
 	StubRouterSupport bool
 	//DiscontinuityTime        string
 	DiscontinuityTime int32 //This should be string
+	SpfRuns           int32
+	PartialSpfRuns    int32
+	SpfThrottled      int32 // triggers delayed or merged by the SPF throttle
+	LastSpfTrigger    string
+	LastSpfDuration   int32 // msec
+}
+
+// Indexed by SpfLogIdx, most recent run first
+type SpfLogState struct {
+	SpfLogIdx   int32
+	SpfRunTime  string
+	SpfType     string
+	SpfDuration int32 // msec
+	SpfTriggers int32
+	SpfReasons  string
 }
 
 // Indexed By AreaId
@@ -344,6 +409,8 @@ type AreaRangeState struct {
 	AreaRangeNet    IpAddress
 	ArearangeMask   IpAddress
 	AreaRangeEffect AreaRangeEffect
+	AreaRangeActive bool
+	AreaRangeCost   int32
 }
 
 // Indexed By HostIpAddress, HostTOS
@@ -377,6 +444,11 @@ type InterfaceConf struct {
 	IfPollInterval    PositiveInteger
 	IfAuthKey         string
 	IfAuthType        AuthType
+	IfAuthKeyChain    []AuthKey
+	IfTEMetric        int32  // 0 uses the interface cost
+	IfMaxBandwidth    int32  // Mbps, 0 is derived from the reference bandwidth and interface cost
+	IfMaxRsvBandwidth int32  // Mbps, 0 uses the maximum bandwidth
+	IfAdminGroup      uint32 // TE administrative group (resource class/color) bit mask
 }
 
 type InterfaceState struct {
@@ -417,6 +489,7 @@ type VirtIfConf struct {
 	VirtIfRtrDeadInterval PositiveInteger
 	VirtIfAuthKey         string
 	VirtIfAuthType        AuthType
+	VirtIfAuthKeyChain    []AuthKey
 }
 
 type VirtIfState struct {
@@ -508,6 +581,24 @@ type AreaAggregateState struct {
 	AreaAggregateExtRouteTag uint32
 }
 
+// ASBR summary address for redistributed routes
+// Indexed By SummaryAddrNet, SummaryAddrMask
+type SummaryAddrConf struct {
+	SummaryAddrNet    IpAddress
+	SummaryAddrMask   IpAddress
+	SummaryAddrEffect AreaRangeEffect
+	SummaryAddrTag    uint32
+}
+
+type SummaryAddrState struct {
+	SummaryAddrNet    IpAddress
+	SummaryAddrMask   IpAddress
+	SummaryAddrEffect AreaRangeEffect
+	SummaryAddrTag    uint32
+	SummaryAddrActive bool
+	SummaryAddrMetric int32
+}
+
 // Link local link state database for non-virtual links
 // Indexed by LocalLsdbIpAddress, LocalLsdbAddressLessIf,
 // LocalLsdbType, LocalLsdbLsid, LocalLsdbRouterId
@@ -578,3 +669,39 @@ type OspfEventState struct {
 	EventInfo      string
 }
 	
+
+// OSPFv3 interface, indexed by Ospfv3IfIndex
+type Ospfv3IntfConf struct {
+	IfIndex           int32
+	IfAreaId          AreaId
+	IfInstanceId      uint8
+	IfAdminStat       Status
+	IfType            IfType
+	IfRtrPriority     DesignatedRouterPriority
+	IfTransitDelay    UpToMaxAge
+	IfRetransInterval UpToMaxAge
+	IfHelloInterval   HelloRange
+	IfRtrDeadInterval PositiveInteger
+	IfCost            int32 // 0 is derived from the interface speed
+}
+
+type Ospfv3NbrState struct {
+	NbrIfIndex       int32
+	NbrRtrId         RouterId
+	NbrIpAddress     IpAddress // link-local address
+	NbrIntfId        uint32
+	NbrOptions       int
+	NbrPriority      uint8
+	NbrState         string
+	NbrEvents        int
+	NbrLsRetransQLen int
+}
+
+type Ospfv3IPv6Route struct {
+	DestPrefix string
+	AreaId     string
+	PathType   string
+	Cost       int32
+	NumOfPaths int32
+	NextHops   string
+}
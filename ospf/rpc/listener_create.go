@@ -27,6 +27,7 @@ import (
 	"errors"
 	"fmt"
 	"l3/ospf/config"
+	"l3/ospf/server"
 	"ospfd"
 	"strings"
 )
@@ -40,6 +41,39 @@ func (h *OSPFHandler) SendOspfGlobal(ospfGlobalConf *ospfd.OspfGlobal) error {
 		RestartSupport:     config.RestartSupport(ospfGlobalConf.RestartSupport),
 		RestartInterval:    ospfGlobalConf.RestartInterval,
 		ReferenceBandwidth: uint32(ospfGlobalConf.ReferenceBandwidth),
+		OpaqueLsaSupport:   ospfGlobalConf.OpaqueLsaSupport,
+		TESupport:          ospfGlobalConf.TESupport,
+		SpfThrottleInitial: ospfGlobalConf.SpfThrottleInitial,
+		SpfThrottleHold:    ospfGlobalConf.SpfThrottleHold,
+		SpfThrottleMaxWait: ospfGlobalConf.SpfThrottleMaxWait,
+		LsaThrottleInitial: ospfGlobalConf.LsaThrottleInitial,
+		LsaThrottleHold:    ospfGlobalConf.LsaThrottleHold,
+		LsaThrottleMaxWait: ospfGlobalConf.LsaThrottleMaxWait,
+		LsaArrivalInterval: ospfGlobalConf.LsaArrivalInterval,
+	}
+	if gConf.TESupport && !gConf.OpaqueLsaSupport {
+		err := errors.New("Traffic engineering requires opaque LSA support")
+		return err
+	}
+	if (gConf.RestartSupport == config.PlannedOnly ||
+		gConf.RestartSupport == config.PlannedAndUnplanned) && !gConf.OpaqueLsaSupport {
+		err := errors.New("Graceful restart requires opaque LSA support")
+		return err
+	}
+	if gConf.SpfThrottleInitial < config.ThrottleNoDelay || gConf.SpfThrottleHold < config.ThrottleNoDelay ||
+		gConf.SpfThrottleMaxWait < config.ThrottleNoDelay || gConf.LsaThrottleInitial < config.ThrottleNoDelay ||
+		gConf.LsaThrottleHold < config.ThrottleNoDelay || gConf.LsaThrottleMaxWait < config.ThrottleNoDelay ||
+		gConf.LsaArrivalInterval < config.ThrottleNoDelay {
+		err := errors.New("Throttle timers can not be less than -1 (no delay)")
+		return err
+	}
+	if gConf.SpfThrottleMaxWait != 0 && gConf.SpfThrottleMaxWait < gConf.SpfThrottleHold {
+		err := errors.New("SPF throttle max wait is less than hold time")
+		return err
+	}
+	if gConf.LsaThrottleMaxWait != 0 && gConf.LsaThrottleMaxWait < gConf.LsaThrottleHold {
+		err := errors.New("LSA throttle max wait is less than hold time")
+		return err
 	}
 	h.server.GlobalConfigCh <- gConf
 	//	retMsg := <-h.server.GlobalConfigRetCh
@@ -47,7 +81,7 @@ func (h *OSPFHandler) SendOspfGlobal(ospfGlobalConf *ospfd.OspfGlobal) error {
 	return nil
 }
 
-func (h *OSPFHandler) SendOspfIfConf(ospfIfConf *ospfd.OspfIfEntry) error {
+func (h *OSPFHandler) convertToIfConf(ospfIfConf *ospfd.OspfIfEntry) (config.InterfaceConf, error) {
 	ifConf := config.InterfaceConf{
 		IfIpAddress:       config.IpAddress(ospfIfConf.IfIpAddress),
 		AddressLessIf:     config.InterfaceIndexOrZero(ospfIfConf.AddressLessIf),
@@ -61,6 +95,14 @@ func (h *OSPFHandler) SendOspfIfConf(ospfIfConf *ospfd.OspfIfEntry) error {
 		IfPollInterval:    config.PositiveInteger(ospfIfConf.IfPollInterval),
 		IfAuthKey:         ospfIfConf.IfAuthKey,
 		IfAuthType:        config.AuthType(ospfIfConf.IfAuthType),
+		IfTEMetric:        ospfIfConf.IfTEMetric,
+		IfMaxBandwidth:    ospfIfConf.IfMaxBandwidth,
+		IfMaxRsvBandwidth: ospfIfConf.IfMaxRsvBandwidth,
+		IfAdminGroup:      uint32(ospfIfConf.IfAdminGroup),
+	}
+	if ifConf.IfTEMetric < 0 || ifConf.IfMaxBandwidth < 0 || ifConf.IfMaxRsvBandwidth < 0 {
+		err := errors.New("Invalid traffic engineering parameters")
+		return ifConf, err
 	}
 
 	for index, ifName := range config.IfTypeList {
@@ -69,6 +111,24 @@ func (h *OSPFHandler) SendOspfIfConf(ospfIfConf *ospfd.OspfIfEntry) error {
 			break
 		}
 	}
+
+	keyChain, err := server.ConvertAuthKeyChain(ospfIfConf.IfAuthKeyChain)
+	if err != nil {
+		return ifConf, err
+	}
+	ifConf.IfAuthKeyChain = keyChain
+	if ifConf.IfAuthType == config.Md5 && len(keyChain) == 0 {
+		err := errors.New("Key chain is required for the cryptographic authentication")
+		return ifConf, err
+	}
+	return ifConf, nil
+}
+
+func (h *OSPFHandler) SendOspfIfConf(ospfIfConf *ospfd.OspfIfEntry) error {
+	ifConf, err := h.convertToIfConf(ospfIfConf)
+	if err != nil {
+		return err
+	}
 	h.server.IntfConfigCh <- ifConf
 
 	//retMsg := <-h.server.IntfConfigRetCh
@@ -158,7 +218,64 @@ func (h *OSPFHandler) CreateOspfIfMetricEntry(ospfIfMetricConf *ospfd.OspfIfMetr
 	return true, nil
 }
 
+func (h *OSPFHandler) CreateOspfv3IntfEntry(ospfv3IfConf *ospfd.Ospfv3IntfEntry) (bool, error) {
+	if ospfv3IfConf == nil {
+		err := errors.New("Invalid OSPFv3 Interface Configuration")
+		return false, err
+	}
+	h.logger.Info(fmt.Sprintln("Create OSPFv3 interface config attrs:", ospfv3IfConf))
+	ifConf, err := server.ConvertOspfv3IntfConf(ospfv3IfConf)
+	if err != nil {
+		return false, err
+	}
+	h.server.Ospfv3IntfConfigCh <- ifConf
+	return true, nil
+}
+
+func (h *OSPFHandler) CreateOspfAreaRangeEntry(ospfAreaRangeConf *ospfd.OspfAreaRangeEntry) (bool, error) {
+	if ospfAreaRangeConf == nil {
+		err := errors.New("Invalid Area Range Configuration")
+		return false, err
+	}
+	h.logger.Info(fmt.Sprintln("Create area range config attrs:", ospfAreaRangeConf))
+	rangeConf, err := server.ConvertAreaRangeConf(ospfAreaRangeConf)
+	if err != nil {
+		return false, err
+	}
+	h.server.AreaRangeConfigCh <- server.AreaRangeMsg{
+		Conf: rangeConf,
+	}
+	return true, nil
+}
+
+func (h *OSPFHandler) CreateOspfSummaryAddrEntry(ospfSummaryAddrConf *ospfd.OspfSummaryAddrEntry) (bool, error) {
+	if ospfSummaryAddrConf == nil {
+		err := errors.New("Invalid Summary Address Configuration")
+		return false, err
+	}
+	h.logger.Info(fmt.Sprintln("Create summary address config attrs:", ospfSummaryAddrConf))
+	sConf, err := server.ConvertSummaryAddrConf(ospfSummaryAddrConf)
+	if err != nil {
+		return false, err
+	}
+	h.server.SummaryAddrConfigCh <- server.SummaryAddrMsg{
+		Conf: sConf,
+	}
+	return true, nil
+}
+
 func (h *OSPFHandler) CreateOspfVirtIfEntry(ospfVirtIfConf *ospfd.OspfVirtIfEntry) (bool, error) {
+	if ospfVirtIfConf == nil {
+		err := errors.New("Invalid Virtual Interface Configuration")
+		return false, err
+	}
 	h.logger.Info(fmt.Sprintln("Create virtual interface config attrs:", ospfVirtIfConf))
+	vConf, err := server.ConvertVirtIfConf(ospfVirtIfConf)
+	if err != nil {
+		return false, err
+	}
+	h.server.VirtLinkConfigCh <- server.VirtLinkMsg{
+		Conf: vConf,
+	}
 	return true, nil
 }
@@ -24,10 +24,11 @@
 package rpc
 
 import (
+	"errors"
 	"fmt"
+	"l3/ospf/server"
 	"ospfd"
 	//    "l3/ospf/config"
-	//    "l3/ospf/server"
 	//    "utils/logging"
 	//    "net"
 )
@@ -48,6 +49,50 @@ func (h *OSPFHandler) DeleteOspfIfEntry(ospfIfConf *ospfd.OspfIfEntry) (bool, er
 	return true, nil
 }
 
+func (h *OSPFHandler) DeleteOspfv3IntfEntry(ospfv3IfConf *ospfd.Ospfv3IntfEntry) (bool, error) {
+	h.logger.Info(fmt.Sprintln("Delete OSPFv3 interface config attrs:", ospfv3IfConf))
+	if ospfv3IfConf == nil {
+		err := errors.New("Invalid OSPFv3 Interface Configuration")
+		return false, err
+	}
+	h.server.Ospfv3IntfDeleteCh <- ospfv3IfConf.IfIndex
+	return true, nil
+}
+
+func (h *OSPFHandler) DeleteOspfAreaRangeEntry(ospfAreaRangeConf *ospfd.OspfAreaRangeEntry) (bool, error) {
+	h.logger.Info(fmt.Sprintln("Delete area range config attrs:", ospfAreaRangeConf))
+	if ospfAreaRangeConf == nil {
+		err := errors.New("Invalid Area Range Configuration")
+		return false, err
+	}
+	rangeConf, err := server.ConvertAreaRangeConf(ospfAreaRangeConf)
+	if err != nil {
+		return false, err
+	}
+	h.server.AreaRangeConfigCh <- server.AreaRangeMsg{
+		Conf:  rangeConf,
+		IsDel: true,
+	}
+	return true, nil
+}
+
+func (h *OSPFHandler) DeleteOspfSummaryAddrEntry(ospfSummaryAddrConf *ospfd.OspfSummaryAddrEntry) (bool, error) {
+	h.logger.Info(fmt.Sprintln("Delete summary address config attrs:", ospfSummaryAddrConf))
+	if ospfSummaryAddrConf == nil {
+		err := errors.New("Invalid Summary Address Configuration")
+		return false, err
+	}
+	sConf, err := server.ConvertSummaryAddrConf(ospfSummaryAddrConf)
+	if err != nil {
+		return false, err
+	}
+	h.server.SummaryAddrConfigCh <- server.SummaryAddrMsg{
+		Conf:  sConf,
+		IsDel: true,
+	}
+	return true, nil
+}
+
 func (h *OSPFHandler) DeleteOspfIfMetricEntry(ospfIfMetricConf *ospfd.OspfIfMetricEntry) (bool, error) {
 	h.logger.Info(fmt.Sprintln("Delete interface metric config attrs:", ospfIfMetricConf))
 	return true, nil
@@ -55,5 +100,17 @@ func (h *OSPFHandler) DeleteOspfIfMetricEntry(ospfIfMetricConf *ospfd.OspfIfMetr
 
 func (h *OSPFHandler) DeleteOspfVirtIfEntry(ospfVirtIfConf *ospfd.OspfVirtIfEntry) (bool, error) {
 	h.logger.Info(fmt.Sprintln("Delete virtual interface config attrs:", ospfVirtIfConf))
+	if ospfVirtIfConf == nil {
+		err := errors.New("Invalid Virtual Interface Configuration")
+		return false, err
+	}
+	vConf, err := server.ConvertVirtIfConf(ospfVirtIfConf)
+	if err != nil {
+		return false, err
+	}
+	h.server.VirtLinkConfigCh <- server.VirtLinkMsg{
+		Conf:  vConf,
+		IsDel: true,
+	}
 	return true, nil
 }
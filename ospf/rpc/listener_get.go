@@ -101,3 +101,17 @@ func (h *OSPFHandler) GetOspfIPv4RouteState(destId string, addrMask string, dest
 func (h *OSPFHandler) GetOspfEventState(Index int32) (*ospfd.OspfEventState, error) {
 	return nil, nil
 }
+
+func (h *OSPFHandler) GetOspfSpfLogEntryState(spfLogIdx int32) (*ospfd.OspfSpfLogEntryState, error) {
+	return nil, nil
+}
+
+func (h *OSPFHandler) GetOspfv3NbrEntryState(ifIndex int32, nbrRtrId string) (*ospfd.Ospfv3NbrEntryState, error) {
+	h.logger.Info(fmt.Sprintln("Get OSPFv3 Neighbor attrs"))
+	ospfv3NbrResponse := ospfd.NewOspfv3NbrEntryState()
+	return ospfv3NbrResponse, nil
+}
+
+func (h *OSPFHandler) GetOspfv3IPv6RouteState(destPrefix string) (*ospfd.Ospfv3IPv6RouteState, error) {
+	return nil, nil
+}
@@ -81,6 +81,14 @@ func (h *OSPFHandler) convertGlobalStateToThrift(ent config.GlobalState) *ospfd.
 	gState.AreaBdrRtrStatus = ent.AreaBdrRtrStatus
 	gState.ExternLsaCount = ent.ExternLsaCount
 	gState.OpaqueLsaSupport = ent.OpaqueLsaSupport
+	gState.RestartStatus = int32(ent.RestartStatus)
+	gState.RestartAge = ent.RestartAge
+	gState.RestartExitReason = int32(ent.RestartExitReason)
+	gState.SpfRuns = ent.SpfRuns
+	gState.PartialSpfRuns = ent.PartialSpfRuns
+	gState.SpfThrottled = ent.SpfThrottled
+	gState.LastSpfTrigger = ent.LastSpfTrigger
+	gState.LastSpfDuration = ent.LastSpfDuration
 
 	return gState
 }
@@ -94,6 +102,9 @@ func (h *OSPFHandler) convertNbrEntryStateToThrift(nbr config.NeighborState) *os
 	nbrEntry.NbrState = string(nbr.NbrState)
 	nbrEntry.NbrEvents = int32(nbr.NbrEvents)
 	nbrEntry.NbrHelloSuppressed = bool(nbr.NbrHelloSuppressed)
+	nbrEntry.NbrRestartHelperStatus = int32(nbr.NbrRestartHelperStatus)
+	nbrEntry.NbrRestartHelperAge = int32(nbr.NbrRestartHelperAge)
+	nbrEntry.NbrRestartHelperExitReason = int32(nbr.NbrRestartHelperExitReason)
 
 	return nbrEntry
 
@@ -183,9 +194,104 @@ func (h *OSPFHandler) GetBulkOspfNbrEntryState(fromIdx ospfd.Int, count ospfd.In
 	return OspfNbrEntryStateGetInfo, nil
 }
 
+func (h *OSPFHandler) GetBulkOspfSpfLogEntryState(fromIdx ospfd.Int, count ospfd.Int) (*ospfd.OspfSpfLogEntryStateGetInfo, error) {
+	h.logger.Info(fmt.Sprintln("Get SPF log attrs"))
+	nextIdx, currCount, spfLogs := h.server.GetBulkOspfSpfLogEntryState(int(fromIdx), int(count))
+	spfLogResponse := make([]*ospfd.OspfSpfLogEntryState, len(spfLogs))
+	for idx, item := range spfLogs {
+		spfLogEntry := ospfd.NewOspfSpfLogEntryState()
+		spfLogEntry.SpfLogIdx = item.SpfLogIdx
+		spfLogEntry.SpfRunTime = item.SpfRunTime
+		spfLogEntry.SpfType = item.SpfType
+		spfLogEntry.SpfDuration = item.SpfDuration
+		spfLogEntry.SpfTriggers = item.SpfTriggers
+		spfLogEntry.SpfReasons = item.SpfReasons
+		spfLogResponse[idx] = spfLogEntry
+	}
+	spfLogGetInfo := ospfd.NewOspfSpfLogEntryStateGetInfo()
+	spfLogGetInfo.Count = ospfd.Int(currCount)
+	spfLogGetInfo.StartIdx = ospfd.Int(fromIdx)
+	spfLogGetInfo.EndIdx = ospfd.Int(nextIdx)
+	spfLogGetInfo.More = (nextIdx != 0)
+	spfLogGetInfo.OspfSpfLogEntryStateList = spfLogResponse
+	return spfLogGetInfo, nil
+}
+
+func (h *OSPFHandler) GetBulkOspfv3NbrEntryState(fromIdx ospfd.Int, count ospfd.Int) (*ospfd.Ospfv3NbrEntryStateGetInfo, error) {
+	h.logger.Info(fmt.Sprintln("Get OSPFv3 Neighbor attrs"))
+	nextIdx, currCount, nbrStates := h.server.GetBulkOspfv3NbrEntryState(int(fromIdx), int(count))
+	nbrResponse := make([]*ospfd.Ospfv3NbrEntryState, len(nbrStates))
+	for idx, item := range nbrStates {
+		nbrEntry := ospfd.NewOspfv3NbrEntryState()
+		nbrEntry.IfIndex = item.NbrIfIndex
+		nbrEntry.NbrRtrId = string(item.NbrRtrId)
+		nbrEntry.NbrIpAddr = string(item.NbrIpAddress)
+		nbrEntry.NbrIntfId = int32(item.NbrIntfId)
+		nbrEntry.NbrOptions = int32(item.NbrOptions)
+		nbrEntry.NbrPriority = int32(item.NbrPriority)
+		nbrEntry.NbrState = item.NbrState
+		nbrEntry.NbrEvents = int32(item.NbrEvents)
+		nbrEntry.NbrLsRetransQLen = int32(item.NbrLsRetransQLen)
+		nbrResponse[idx] = nbrEntry
+	}
+	nbrGetInfo := ospfd.NewOspfv3NbrEntryStateGetInfo()
+	nbrGetInfo.Count = ospfd.Int(currCount)
+	nbrGetInfo.StartIdx = ospfd.Int(fromIdx)
+	nbrGetInfo.EndIdx = ospfd.Int(nextIdx)
+	nbrGetInfo.More = (nextIdx != 0)
+	nbrGetInfo.Ospfv3NbrEntryStateList = nbrResponse
+	return nbrGetInfo, nil
+}
+
+func (h *OSPFHandler) GetBulkOspfv3IPv6RouteState(fromIdx ospfd.Int, count ospfd.Int) (*ospfd.Ospfv3IPv6RouteStateGetInfo, error) {
+	h.logger.Info(fmt.Sprintln("Get OSPFv3 IPv6 route attrs"))
+	nextIdx, currCount, routeStates := h.server.GetBulkOspfv3IPv6RouteState(int(fromIdx), int(count))
+	routeResponse := make([]*ospfd.Ospfv3IPv6RouteState, len(routeStates))
+	for idx, item := range routeStates {
+		routeEntry := ospfd.NewOspfv3IPv6RouteState()
+		routeEntry.DestPrefix = item.DestPrefix
+		routeEntry.AreaId = item.AreaId
+		routeEntry.PathType = item.PathType
+		routeEntry.Cost = item.Cost
+		routeEntry.NumOfPaths = item.NumOfPaths
+		routeEntry.NextHops = item.NextHops
+		routeResponse[idx] = routeEntry
+	}
+	routeGetInfo := ospfd.NewOspfv3IPv6RouteStateGetInfo()
+	routeGetInfo.Count = ospfd.Int(currCount)
+	routeGetInfo.StartIdx = ospfd.Int(fromIdx)
+	routeGetInfo.EndIdx = ospfd.Int(nextIdx)
+	routeGetInfo.More = (nextIdx != 0)
+	routeGetInfo.Ospfv3IPv6RouteStateList = routeResponse
+	return routeGetInfo, nil
+}
+
 func (h *OSPFHandler) GetBulkOspfVirtNbrEntryState(fromIdx ospfd.Int, count ospfd.Int) (*ospfd.OspfVirtNbrEntryStateGetInfo, error) {
 	h.logger.Info(fmt.Sprintln("Get Virtual Neighbor attrs"))
+	nextIdx, currCount, nbrStates := h.server.GetBulkOspfVirtNbrEntryState(int(fromIdx), int(count))
+	NbrStateLen := len(config.NbrStateList)
+	nbrResponse := make([]*ospfd.OspfVirtNbrEntryState, len(nbrStates))
+	for idx, item := range nbrStates {
+		nbrEntry := ospfd.NewOspfVirtNbrEntryState()
+		nbrEntry.VirtNbrArea = string(item.VirtNbrArea)
+		nbrEntry.VirtNbrRtrId = string(item.VirtNbrRtrId)
+		nbrEntry.VirtNbrIpAddress = string(item.VirtNbrIpAddress)
+		nbrEntry.VirtNbrOptions = int32(item.VirtNbrOptions)
+		nbrEntry.VirtNbrState = config.NbrStateList[int(item.VirtNbrState)%NbrStateLen]
+		nbrEntry.VirtNbrEvents = int32(item.VirtNbrEvents)
+		nbrEntry.VirtNbrLsRetransQLen = int32(item.VirtNbrLsRetransQLen)
+		nbrEntry.VirtNbrHelloSuppressed = item.VirtNbrHelloSuppressed
+		nbrEntry.VirtNbrRestartHelperStatus = int32(item.VirtNbrRestartHelperStatus)
+		nbrEntry.VirtNbrRestartHelperAge = int32(item.VirtNbrRestartHelperAge)
+		nbrEntry.VirtNbrRestartHelperExitReason = int32(item.VirtNbrRestartHelperExitReason)
+		nbrResponse[idx] = nbrEntry
+	}
 	ospfVirtNbrResponse := ospfd.NewOspfVirtNbrEntryStateGetInfo()
+	ospfVirtNbrResponse.Count = ospfd.Int(currCount)
+	ospfVirtNbrResponse.StartIdx = ospfd.Int(fromIdx)
+	ospfVirtNbrResponse.EndIdx = ospfd.Int(nextIdx)
+	ospfVirtNbrResponse.More = (nextIdx != 0)
+	ospfVirtNbrResponse.OspfVirtNbrEntryStateList = nbrResponse
 	return ospfVirtNbrResponse, nil
 }
 
@@ -24,8 +24,10 @@
 package rpc
 
 import (
+	"errors"
 	"fmt"
 	"ospfd"
+	"reflect"
 	//    "l3/ospf/config"
 	//    "l3/ospf/server"
 	//    "utils/logging"
@@ -47,9 +49,51 @@ func (h *OSPFHandler) UpdateOspfAreaEntry(origConf *ospfd.OspfAreaEntry, newConf
 func (h *OSPFHandler) UpdateOspfIfEntry(origConf *ospfd.OspfIfEntry, newConf *ospfd.OspfIfEntry, attrset []bool, op []*ospfd.PatchOpInfo) (bool, error) {
 	h.logger.Info(fmt.Sprintln("Original interface config attrs:", origConf))
 	h.logger.Info(fmt.Sprintln("New interface config attrs:", newConf))
+	if origConf == nil || newConf == nil {
+		err := errors.New("Invalid Interface Configuration")
+		return false, err
+	}
+	origIfConf, _ := h.convertToIfConf(origConf)
+	newIfConf, err := h.convertToIfConf(newConf)
+	if err != nil {
+		return false, err
+	}
+
+	/* Key chain only changes are applied without restarting the interface */
+	keyChain := newIfConf.IfAuthKeyChain
+	origIfConf.IfAuthKeyChain = nil
+	newIfConf.IfAuthKeyChain = nil
+	if reflect.DeepEqual(origIfConf, newIfConf) {
+		newIfConf.IfAuthKeyChain = keyChain
+		h.server.IntfAuthConfigCh <- newIfConf
+		return true, nil
+	}
+
+	err = h.SendOspfIfConf(newConf)
+	if err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
+func (h *OSPFHandler) UpdateOspfv3IntfEntry(origConf *ospfd.Ospfv3IntfEntry, newConf *ospfd.Ospfv3IntfEntry, attrset []bool, op []*ospfd.PatchOpInfo) (bool, error) {
+	h.logger.Info(fmt.Sprintln("Original OSPFv3 interface config attrs:", origConf))
+	h.logger.Info(fmt.Sprintln("New OSPFv3 interface config attrs:", newConf))
+	return h.CreateOspfv3IntfEntry(newConf)
+}
+
+func (h *OSPFHandler) UpdateOspfAreaRangeEntry(origConf *ospfd.OspfAreaRangeEntry, newConf *ospfd.OspfAreaRangeEntry, attrset []bool, op []*ospfd.PatchOpInfo) (bool, error) {
+	h.logger.Info(fmt.Sprintln("Original area range config attrs:", origConf))
+	h.logger.Info(fmt.Sprintln("New area range config attrs:", newConf))
+	return h.CreateOspfAreaRangeEntry(newConf)
+}
+
+func (h *OSPFHandler) UpdateOspfSummaryAddrEntry(origConf *ospfd.OspfSummaryAddrEntry, newConf *ospfd.OspfSummaryAddrEntry, attrset []bool, op []*ospfd.PatchOpInfo) (bool, error) {
+	h.logger.Info(fmt.Sprintln("Original summary address config attrs:", origConf))
+	h.logger.Info(fmt.Sprintln("New summary address config attrs:", newConf))
+	return h.CreateOspfSummaryAddrEntry(newConf)
+}
+
 func (h *OSPFHandler) UpdateOspfIfMetricEntry(origConf *ospfd.OspfIfMetricEntry, newConf *ospfd.OspfIfMetricEntry, attrset []bool, op []*ospfd.PatchOpInfo) (bool, error) {
 	h.logger.Info(fmt.Sprintln("Original interface metric config attrs:", origConf))
 	h.logger.Info(fmt.Sprintln("New interface metric config attrs:", newConf))
@@ -59,6 +103,6 @@ func (h *OSPFHandler) UpdateOspfIfMetricEntry(origConf *ospfd.OspfIfMetricEntry,
 func (h *OSPFHandler) UpdateOspfVirtIfEntry(origConf *ospfd.OspfVirtIfEntry, newConf *ospfd.OspfVirtIfEntry, attrset []bool, op []*ospfd.PatchOpInfo) (bool, error) {
 	h.logger.Info(fmt.Sprintln("Original virtual interface config attrs:", origConf))
 	h.logger.Info(fmt.Sprintln("New virtual interface config attrs:", newConf))
-	return true, nil
+	return h.CreateOspfVirtIfEntry(newConf)
 }
 
@@ -113,74 +113,57 @@ func (server *OSPFServer) HandleASExternalLsa(areaId uint32) {
 			continue
 		}
 
-		cost := rEnt.Cost + uint16(lsaEnt.Metric)
-		nextHopMap := rEnt.NextHops
-		numOfNextHops := rEnt.NumOfPaths
-		rKey = RoutingTblEntryKey{
-			DestId:   lsaKey.LSId & lsaEnt.Netmask,
-			AddrMask: lsaEnt.Netmask,
-			DestType: Network, // TODO: Need to be revisited
-		}
+		server.calcASExternalRoute(areaIdKey, lsaKey, lsaEnt, rEnt)
+	}
+}
 
-		tempAreaRoutingTbl = server.TempAreaRoutingTbl[areaIdKey]
-		rEnt, exist = tempAreaRoutingTbl.RoutingTblMap[rKey]
-		if exist {
-			if rEnt.PathType == IntraArea ||
-				rEnt.PathType == InterArea {
-				//IntraArea or InterArea Paths are always preferred
-				continue
-			}
-			if rEnt.PathType == Type1Ext &&
-				lsaEnt.BitE == true {
-				//Type1Ext path is always preferred over Type2Ext
-				continue
-			}
-			var pathType PathType
-			if lsaEnt.BitE == true {
-				pathType = Type2Ext
-			} else {
-				pathType = Type1Ext
-			}
-			if rEnt.Cost < cost &&
-				rEnt.PathType == pathType {
-				//Routing table entry cost is less and path type is same
-				server.logger.Info("Route already exists with lesser cost")
-				continue
-			} else if (rEnt.Cost > cost &&
-				rEnt.PathType == pathType) ||
-				(rEnt.Cost < cost &&
-					rEnt.PathType == Type2Ext) {
-				rEnt.OptCapabilities = 0 //TODO
-				//rEnt.PathType = InterArea
-				rEnt.PathType = pathType
-				rEnt.Cost = cost
-				rEnt.Type2Cost = uint16(lsaEnt.Metric)
-				//rEnt.LSOrigin = lsaKey
-				rEnt.NumOfPaths = numOfNextHops
-				rEnt.NextHops = make(map[NextHop]bool)
-				for key, _ := range nextHopMap {
-					key.AdvRtr = lsaKey.AdvRouter
-					rEnt.NextHops[key] = true
-				}
-			} else {
-				cnt := 0
-				for key, _ := range nextHopMap {
-					_, exist = rEnt.NextHops[key]
-					if !exist {
-						key.AdvRtr = lsaKey.AdvRouter
-						rEnt.NextHops[key] = true
-						cnt++
-					}
-				}
-				rEnt.NumOfPaths = numOfNextHops + cnt
-			}
+/*
+@fn calcASExternalRoute
+Install the external route described by lsaEnt using the
+route to the ASBR (or forwarding address) in rEnt.
+Shared by AS external and NSSA LSA processing.
+*/
+func (server *OSPFServer) calcASExternalRoute(areaIdKey AreaIdKey, lsaKey LsaKey, lsaEnt ASExternalLsa, rEnt RoutingTblEntry) {
+	cost := rEnt.Cost + uint16(lsaEnt.Metric)
+	nextHopMap := rEnt.NextHops
+	numOfNextHops := rEnt.NumOfPaths
+	rKey := RoutingTblEntryKey{
+		DestId:   lsaKey.LSId & lsaEnt.Netmask,
+		AddrMask: lsaEnt.Netmask,
+		DestType: Network, // TODO: Need to be revisited
+	}
+
+	tempAreaRoutingTbl := server.TempAreaRoutingTbl[areaIdKey]
+	rEnt, exist := tempAreaRoutingTbl.RoutingTblMap[rKey]
+	if exist {
+		if rEnt.PathType == IntraArea ||
+			rEnt.PathType == InterArea {
+			//IntraArea or InterArea Paths are always preferred
+			return
+		}
+		if rEnt.PathType == Type1Ext &&
+			lsaEnt.BitE == true {
+			//Type1Ext path is always preferred over Type2Ext
+			return
+		}
+		var pathType PathType
+		if lsaEnt.BitE == true {
+			pathType = Type2Ext
 		} else {
+			pathType = Type1Ext
+		}
+		if rEnt.Cost < cost &&
+			rEnt.PathType == pathType {
+			//Routing table entry cost is less and path type is same
+			server.logger.Info("Route already exists with lesser cost")
+			return
+		} else if (rEnt.Cost > cost &&
+			rEnt.PathType == pathType) ||
+			(rEnt.Cost < cost &&
+				rEnt.PathType == Type2Ext) {
 			rEnt.OptCapabilities = 0 //TODO
-			if lsaEnt.BitE == true {
-				rEnt.PathType = Type2Ext
-			} else {
-				rEnt.PathType = Type1Ext
-			}
+			//rEnt.PathType = InterArea
+			rEnt.PathType = pathType
 			rEnt.Cost = cost
 			rEnt.Type2Cost = uint16(lsaEnt.Metric)
 			//rEnt.LSOrigin = lsaKey
@@ -190,10 +173,37 @@ func (server *OSPFServer) HandleASExternalLsa(areaId uint32) {
 				key.AdvRtr = lsaKey.AdvRouter
 				rEnt.NextHops[key] = true
 			}
+		} else {
+			cnt := 0
+			for key, _ := range nextHopMap {
+				_, exist = rEnt.NextHops[key]
+				if !exist {
+					key.AdvRtr = lsaKey.AdvRouter
+					rEnt.NextHops[key] = true
+					cnt++
+				}
+			}
+			rEnt.NumOfPaths = numOfNextHops + cnt
+		}
+	} else {
+		rEnt.OptCapabilities = 0 //TODO
+		if lsaEnt.BitE == true {
+			rEnt.PathType = Type2Ext
+		} else {
+			rEnt.PathType = Type1Ext
+		}
+		rEnt.Cost = cost
+		rEnt.Type2Cost = uint16(lsaEnt.Metric)
+		//rEnt.LSOrigin = lsaKey
+		rEnt.NumOfPaths = numOfNextHops
+		rEnt.NextHops = make(map[NextHop]bool)
+		for key, _ := range nextHopMap {
+			key.AdvRtr = lsaKey.AdvRouter
+			rEnt.NextHops[key] = true
 		}
-		tempAreaRoutingTbl.RoutingTblMap[rKey] = rEnt
-		server.TempAreaRoutingTbl[areaIdKey] = tempAreaRoutingTbl
 	}
+	tempAreaRoutingTbl.RoutingTblMap[rKey] = rEnt
+	server.TempAreaRoutingTbl[areaIdKey] = tempAreaRoutingTbl
 }
 
 func (server *OSPFServer) CalcASBorderRoutes(areaId uint32) {
@@ -221,3 +221,19 @@ func (server *OSPFServer) isStubArea(areaid config.AreaId) bool {
 	}
 	return false
 }
+
+func (server *OSPFServer) isNssaArea(areaid config.AreaId) bool {
+
+	areaConfKey := AreaConfKey{
+		AreaId: areaid,
+	}
+
+	conf, exist := server.AreaConfMap[areaConfKey]
+	if !exist {
+		return false
+	}
+	if conf.ImportAsExtern == config.ImportNssa {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"net"
+	"ospfd"
+	"ribd"
+)
+
+/*
+	RFC 2328 3.5, 12.4.3
+	Area border routers collapse the intra-area routes falling in a
+	configured area range into a single Type-3 summary-LSA advertised
+	in the other attached areas. With DoNotAdvertiseMatching the
+	component routes are suppressed and no summary is originated.
+	The cost of the summary is the highest cost of the component routes,
+	or the lowest one when RFC1583Compatibility is set (RFC 2328 G.2).
+
+	A discard route is installed for each advertised summary that has
+	at least one component route, so that traffic to the unreachable
+	parts of the range is dropped instead of looping on a less specific
+	route.
+*/
+
+const (
+	DISCARD_NEXTHOP = "255.255.255.255"
+)
+
+type AreaRangeKey struct {
+	AreaId uint32
+	Net    uint32
+	Mask   uint32
+}
+
+type AreaRangeEnt struct {
+	Effect config.AreaRangeEffect
+	Active bool // range has component routes
+	Cost   uint16
+}
+
+type AreaRangeMsg struct {
+	Conf  config.AreaRangeConf
+	IsDel bool
+}
+
+func isValidNetMask(mask uint32) bool {
+	return mask == 0 || (^mask)&(^mask+1) == 0
+}
+
+func convertRangeNetMask(netStr string, maskStr string) (uint32, uint32, error) {
+	ip := net.ParseIP(netStr).To4()
+	mask := net.ParseIP(maskStr).To4()
+	if ip == nil || mask == nil {
+		return 0, 0, errors.New("Invalid network or mask")
+	}
+	netMask := convertIPv4ToUint32(mask)
+	if !isValidNetMask(netMask) {
+		return 0, 0, errors.New("Network mask is not contiguous")
+	}
+	return convertIPv4ToUint32(ip) & netMask, netMask, nil
+}
+
+func isValidRangeEffect(effect config.AreaRangeEffect) bool {
+	return effect == config.AdvertiseMatching ||
+		effect == config.DoNotAdvertiseMatching
+}
+
+/*
+@fn ConvertAreaRangeConf
+Converts and validates the thrift area range object.
+*/
+func ConvertAreaRangeConf(conf *ospfd.OspfAreaRangeEntry) (config.AreaRangeConf, error) {
+	rangeConf := config.AreaRangeConf{
+		RangeAreaId:     config.AreaId(conf.RangeAreaId),
+		AreaRangeNet:    config.IpAddress(conf.AreaRangeNet),
+		ArearangeMask:   config.IpAddress(conf.AreaRangeMask),
+		AreaRangeEffect: config.AreaRangeEffect(conf.AreaRangeEffect),
+	}
+	if rangeConf.AreaRangeEffect == 0 {
+		rangeConf.AreaRangeEffect = config.AdvertiseMatching
+	}
+	if !isValidRangeEffect(rangeConf.AreaRangeEffect) {
+		return rangeConf, errors.New("Invalid area range effect")
+	}
+	if convertAreaOrRouterId(conf.RangeAreaId) == nil {
+		return rangeConf, errors.New("Invalid area id")
+	}
+	_, _, err := convertRangeNetMask(conf.AreaRangeNet, conf.AreaRangeMask)
+	if err != nil {
+		return rangeConf, err
+	}
+	return rangeConf, nil
+}
+
+func getAreaRangeKey(conf config.AreaRangeConf) (AreaRangeKey, error) {
+	var key AreaRangeKey
+	areaId := convertAreaOrRouterId(string(conf.RangeAreaId))
+	if areaId == nil {
+		return key, errors.New("Invalid area id")
+	}
+	rangeNet, rangeMask, err := convertRangeNetMask(string(conf.AreaRangeNet),
+		string(conf.ArearangeMask))
+	if err != nil {
+		return key, err
+	}
+	key.AreaId = convertIPv4ToUint32(areaId)
+	key.Net = rangeNet
+	key.Mask = rangeMask
+	return key, nil
+}
+
+/*
+@fn processAreaRangeConfig
+Add, update or delete an area range. Summary LSAs are regenerated
+by the LSDB goroutine after the next SPF run.
+*/
+func (server *OSPFServer) processAreaRangeConfig(msg AreaRangeMsg) error {
+	key, err := getAreaRangeKey(msg.Conf)
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("AREARANGE: Invalid area range ", msg.Conf, err))
+		return err
+	}
+	server.areaRangeMutex.Lock()
+	ent, exist := server.AreaRangeMap[key]
+	if msg.IsDel {
+		if !exist {
+			server.areaRangeMutex.Unlock()
+			return errors.New("Area range does not exist")
+		}
+		delete(server.AreaRangeMap, key)
+		server.updateAreaRangeDiscard(key, ent, AreaRangeEnt{})
+	} else {
+		newEnt := ent
+		newEnt.Effect = msg.Conf.AreaRangeEffect
+		server.AreaRangeMap[key] = newEnt
+		server.updateAreaRangeDiscard(key, ent, newEnt)
+	}
+	server.areaRangeMutex.Unlock()
+	server.logger.Info(fmt.Sprintln("AREARANGE: Updated area range ", msg.Conf, " delete ", msg.IsDel))
+	notifyConfChange(server.areaRangeChangeCh)
+	return nil
+}
+
+/*
+@fn notifyConfChange
+Notify the LSDB goroutine without blocking. Changes made while a
+notification is pending are handled along with it.
+*/
+func notifyConfChange(ch chan bool) {
+	select {
+	case ch <- true:
+	default:
+	}
+}
+
+func isDiscardAreaRange(ent AreaRangeEnt) bool {
+	return ent.Active && ent.Effect == config.AdvertiseMatching
+}
+
+/*
+@fn updateAreaRangeDiscard
+Install or delete the discard route when the range becomes active
+or inactive. A cost change of an active range updates the route
+by deleting and installing it again.
+*/
+func (server *OSPFServer) updateAreaRangeDiscard(key AreaRangeKey, oldEnt AreaRangeEnt, newEnt AreaRangeEnt) {
+	oldDiscard := isDiscardAreaRange(oldEnt)
+	newDiscard := isDiscardAreaRange(newEnt)
+	if oldDiscard && newDiscard && oldEnt.Cost != newEnt.Cost {
+		server.updateDiscardRoute(key.Net, key.Mask, uint32(oldEnt.Cost), true)
+		server.updateDiscardRoute(key.Net, key.Mask, uint32(newEnt.Cost), false)
+		return
+	}
+	if oldDiscard == newDiscard {
+		return
+	}
+	server.updateDiscardRoute(key.Net, key.Mask, uint32(newEnt.Cost), !newDiscard)
+}
+
+/*
+@fn updateDiscardRoute
+Install or delete the null route of an active summary.
+*/
+func (server *OSPFServer) updateDiscardRoute(destNet uint32, mask uint32, cost uint32, isDel bool) {
+	server.logger.Info(fmt.Sprintln("DISCARD: Discard route ", convertUint32ToIPv4(destNet),
+		convertUint32ToIPv4(mask), " delete ", isDel))
+	if server.ribdClient.ClientHdl == nil {
+		server.logger.Err("Nil ribd handle. Can not update discard route.")
+		return
+	}
+	cfg := ribd.IPv4Route{
+		DestinationNw: convertUint32ToIPv4(destNet),
+		Protocol:      "OSPF",
+		Cost:          int32(cost),
+		NetworkMask:   convertUint32ToIPv4(mask),
+		NullRoute:     true,
+	}
+	nextHopInfo := ribd.NextHopInfo{
+		NextHopIp: DISCARD_NEXTHOP,
+	}
+	cfg.NextHop = make([]*ribd.NextHopInfo, 0)
+	cfg.NextHop = append(cfg.NextHop, &nextHopInfo)
+	var err error
+	if isDel {
+		_, err = server.ribdClient.ClientHdl.DeleteIPv4Route(&cfg)
+	} else {
+		_, err = server.ribdClient.ClientHdl.CreateIPv4Route(&cfg)
+	}
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("DISCARD: Failed to update discard route ", err))
+	}
+}
+
+/*
+@fn getSummaryCost
+Cost of a summary after adding a component route.
+*/
+func (server *OSPFServer) getSummaryCost(cost uint32, compCost uint32, first bool) uint32 {
+	if first {
+		return compCost
+	}
+	if server.ospfGlobalConf.RFC1583Compatibility {
+		if compCost < cost {
+			return compCost
+		}
+	} else if compCost > cost {
+		return compCost
+	}
+	return cost
+}
+
+func getCoveringAreaRange(ranges map[AreaRangeKey]AreaRangeEnt, areaId uint32,
+	rKey RoutingTblEntryKey) (AreaRangeKey, bool) {
+	var key AreaRangeKey
+	found := false
+	for rangeKey, _ := range ranges {
+		if rangeKey.AreaId != areaId ||
+			rKey.DestId&rangeKey.Mask != rangeKey.Net ||
+			rKey.AddrMask&rangeKey.Mask != rangeKey.Mask {
+			continue
+		}
+		if !found || rangeKey.Mask > key.Mask {
+			key = rangeKey
+			found = true
+		}
+	}
+	return key, found
+}
+
+/*
+@fn calcAreaRanges
+Aggregate the intra-area network routes of the global routing
+table in the configured area ranges. Returns the updated ranges.
+*/
+func (server *OSPFServer) calcAreaRanges() map[AreaRangeKey]AreaRangeEnt {
+	server.areaRangeMutex.Lock()
+	defer server.areaRangeMutex.Unlock()
+	ranges := make(map[AreaRangeKey]AreaRangeEnt)
+	for key, ent := range server.AreaRangeMap {
+		ent.Active = false
+		ent.Cost = 0
+		ranges[key] = ent
+	}
+	for rKey, rEnt := range server.GlobalRoutingTbl {
+		if rKey.DestType != Network ||
+			rEnt.RoutingTblEnt.PathType != IntraArea ||
+			uint32(rEnt.RoutingTblEnt.Cost) >= LSInfinity {
+			continue
+		}
+		key, exist := getCoveringAreaRange(ranges, rEnt.AreaId, rKey)
+		if !exist {
+			continue
+		}
+		ent := ranges[key]
+		ent.Cost = uint16(server.getSummaryCost(uint32(ent.Cost),
+			uint32(rEnt.RoutingTblEnt.Cost), !ent.Active))
+		ent.Active = true
+		ranges[key] = ent
+	}
+	for key, ent := range ranges {
+		server.updateAreaRangeDiscard(key, server.AreaRangeMap[key], ent)
+		server.AreaRangeMap[key] = ent
+	}
+	return ranges
+}
+
+/*
+@fn generateAreaRangeSummaryLSA
+Type-3 summary LSA of an active area range.
+*/
+func (server *OSPFServer) generateAreaRangeSummaryLSA(key AreaRangeKey, ent AreaRangeEnt, lsDbKey LsdbKey) (LsaKey, SummaryLsa) {
+	rKey := RoutingTblEntryKey{
+		DestId:   key.Net,
+		AddrMask: key.Mask,
+		DestType: Network,
+	}
+	var rEnt GlobalRoutingTblEntry
+	rEnt.AreaId = key.AreaId
+	rEnt.RoutingTblEnt.PathType = IntraArea
+	rEnt.RoutingTblEnt.Cost = ent.Cost
+	return server.GenerateType3SummaryLSA(rKey, rEnt, lsDbKey)
+}
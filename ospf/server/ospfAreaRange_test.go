@@ -0,0 +1,187 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"l3/ospf/config"
+	"testing"
+)
+
+func initAreaRangeTestServer() *OSPFServer {
+	server := getAreaTestServer("0.0.0.0", "0.0.0.1")
+	for _, aEnt := range server.AreaConfMap {
+		aEnt.IntfListMap[IntfConfKey{IntfIdx: 1}] = true
+	}
+	routes := []struct {
+		destId uint32
+		mask   uint32
+		cost   uint16
+	}{
+		{0x0a010100, 0xffffff00, 10},
+		{0x0a010200, 0xffffff00, 30},
+		{0x0a020000, 0xffffff00, 5},
+	}
+	for _, route := range routes {
+		rKey := RoutingTblEntryKey{
+			DestId:   route.destId,
+			AddrMask: route.mask,
+			DestType: Network,
+		}
+		var rEnt GlobalRoutingTblEntry
+		rEnt.AreaId = 1
+		rEnt.RoutingTblEnt.PathType = IntraArea
+		rEnt.RoutingTblEnt.Cost = route.cost
+		server.GlobalRoutingTbl[rKey] = rEnt
+	}
+	return server
+}
+
+func getTestSummary3Lsa(server *OSPFServer, areaId uint32, lsId uint32) (SummaryLsa, bool) {
+	lsaKey := LsaKey{
+		LSType:    Summary3LSA,
+		LSId:      lsId,
+		AdvRouter: 0x0a000005,
+	}
+	lsa, exist := server.SummaryLsDb[LsdbKey{AreaId: areaId}][lsaKey]
+	return lsa, exist
+}
+
+func TestAreaRangeSummary(t *testing.T) {
+	server := initAreaRangeTestServer()
+	err := server.processAreaRangeConfig(AreaRangeMsg{
+		Conf: config.AreaRangeConf{
+			RangeAreaId:     "0.0.0.1",
+			AreaRangeNet:    "10.1.0.0",
+			ArearangeMask:   "255.255.0.0",
+			AreaRangeEffect: config.AdvertiseMatching,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add area range %v", err)
+	}
+	server.GenerateSummaryLsa()
+	lsa, exist := getTestSummary3Lsa(server, 0, 0x0a010000)
+	if !exist || lsa.Metric != 30 || lsa.Netmask != 0xffff0000 {
+		t.Errorf("Invalid area range summary %+v exist %v", lsa, exist)
+	}
+	for _, lsId := range []uint32{0x0a010100, 0x0a010200} {
+		if _, exist = getTestSummary3Lsa(server, 0, lsId); exist {
+			t.Errorf("Component route %x advertised", lsId)
+		}
+	}
+	if _, exist = getTestSummary3Lsa(server, 0, 0x0a020000); !exist {
+		t.Errorf("Route outside area range not advertised")
+	}
+	if _, exist = getTestSummary3Lsa(server, 1, 0x0a010000); exist {
+		t.Errorf("Area range advertised in its own area")
+	}
+	rangeKey := AreaRangeKey{
+		AreaId: 1,
+		Net:    0x0a010000,
+		Mask:   0xffff0000,
+	}
+	if ent := server.AreaRangeMap[rangeKey]; !ent.Active || ent.Cost != 30 {
+		t.Errorf("Invalid area range state %+v", ent)
+	}
+
+	server.ospfGlobalConf.RFC1583Compatibility = true
+	server.GenerateSummaryLsa()
+	if lsa, _ = getTestSummary3Lsa(server, 0, 0x0a010000); lsa.Metric != 10 {
+		t.Errorf("Area range cost %d, expected lowest component cost", lsa.Metric)
+	}
+
+	server.processAreaRangeConfig(AreaRangeMsg{
+		Conf: config.AreaRangeConf{
+			RangeAreaId:     "0.0.0.1",
+			AreaRangeNet:    "10.1.0.0",
+			ArearangeMask:   "255.255.0.0",
+			AreaRangeEffect: config.DoNotAdvertiseMatching,
+		},
+	})
+	server.GenerateSummaryLsa()
+	for _, lsId := range []uint32{0x0a010000, 0x0a010100, 0x0a010200} {
+		if _, exist = getTestSummary3Lsa(server, 0, lsId); exist {
+			t.Errorf("Suppressed route %x advertised", lsId)
+		}
+	}
+}
+
+func TestSummaryAddrExtRoute(t *testing.T) {
+	server := getAreaTestServer()
+	go func() {
+		for {
+			<-server.ospfNbrLsaUpdSendCh
+		}
+	}()
+	sConf := config.SummaryAddrConf{
+		SummaryAddrNet:    "10.1.0.0",
+		SummaryAddrMask:   "255.255.0.0",
+		SummaryAddrEffect: config.AdvertiseMatching,
+		SummaryAddrTag:    100,
+	}
+	server.processSummaryAddrConfig(SummaryAddrMsg{Conf: sConf})
+	sKey := SummaryAddrKey{
+		Net:  0x0a010000,
+		Mask: 0xffff0000,
+	}
+	routes := []RouteMdata{
+		{metric: 20, ipaddr: 0x0a010100, mask: 0xffffff00},
+		{metric: 40, ipaddr: 0x0a010200, mask: 0xffffff00},
+		{metric: 5, ipaddr: 0x0a020000, mask: 0xffffff00},
+	}
+	for _, route := range routes {
+		server.processExtRouteUpd(route)
+	}
+	if len(server.extLsaMap) != 2 {
+		t.Errorf("Invalid external LSAs %+v", server.extLsaMap)
+	}
+	if route := server.extLsaMap[sKey]; route.metric != 40 || route.tag != 100 {
+		t.Errorf("Invalid summary address route %+v", route)
+	}
+	if !server.extDiscardMap[sKey] {
+		t.Errorf("Discard route not installed")
+	}
+
+	routes[1].isDel = true
+	server.processExtRouteUpd(routes[1])
+	if route := server.extLsaMap[sKey]; route.metric != 20 {
+		t.Errorf("Summary address metric %d, expected 20", route.metric)
+	}
+	routes[0].isDel = true
+	server.processExtRouteUpd(routes[0])
+	if _, exist := server.extLsaMap[sKey]; exist || server.extDiscardMap[sKey] {
+		t.Errorf("Summary address without component routes not withdrawn")
+	}
+
+	routes[0].isDel = false
+	server.processExtRouteUpd(routes[0])
+	server.processSummaryAddrConfig(SummaryAddrMsg{Conf: sConf, IsDel: true})
+	server.processSummaryAddrChange()
+	if _, exist := server.extLsaMap[sKey]; exist {
+		t.Errorf("Summary address advertised after delete")
+	}
+	if _, exist := server.extLsaMap[getExtRouteKey(routes[0])]; !exist {
+		t.Errorf("Component route not advertised after summary address delete")
+	}
+}
@@ -114,6 +114,15 @@ func (server *OSPFServer) processAsicdNotification(asicdrxBuf []byte) {
 			return
 		}
 		server.UpdateIPv4Infra(NewIpv4IntfMsg, msg.MsgType)
+	} else if msg.MsgType == asicdCommonDefs.NOTIFY_IPV6INTF_CREATE ||
+		msg.MsgType == asicdCommonDefs.NOTIFY_IPV6INTF_DELETE {
+		var ipv6IntfMsg asicdCommonDefs.IPv6IntfNotifyMsg
+		err = json.Unmarshal(msg.Msg, &ipv6IntfMsg)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("Unable to unmarshal msg:", msg.Msg))
+			return
+		}
+		server.UpdateIPv6Infra(ipv6IntfMsg, msg.MsgType)
 	} else if msg.MsgType == asicdCommonDefs.NOTIFY_VLAN_CREATE ||
 		msg.MsgType == asicdCommonDefs.NOTIFY_VLAN_DELETE {
 		var vlanNotifyMsg asicdCommonDefs.VlanNotifyMsg
@@ -153,6 +162,19 @@ func (server *OSPFServer) initAsicdForRxMulticastPkt() (err error) {
 		server.logger.Info(fmt.Sprintln("Adding reserved mac failed", ALLDROUTERMAC))
 		return err
 	}
+
+	// OSPFv3 AllSPFRouters and AllDRouters
+	for _, macAddr := range []string{ALLSPFROUTERV6MAC, ALLDROUTERV6MAC} {
+		macConf := asicdInt.RsvdProtocolMacConfig{
+			MacAddr:     macAddr,
+			MacAddrMask: MASKMAC,
+		}
+		ret, err = server.asicdClient.ClientHdl.EnablePacketReception(&macConf)
+		if !ret {
+			server.logger.Info(fmt.Sprintln("Adding reserved mac failed", macAddr))
+			return err
+		}
+	}
 	return nil
 }
 
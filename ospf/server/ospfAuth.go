@@ -0,0 +1,362 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"l3/ospf/config"
+	"ospfd"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+   Rfc 2328 D.3, Rfc 5709 3.1
+   Cryptographic authentication field of the OSPF header
+
+        0                   1                   2                   3
+        0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |              0                |    Key ID     | Auth Data Len |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+       |                 Cryptographic sequence number                 |
+       +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+   The message digest is appended to the OSPF packet and is not
+   included in the OSPF packet length.
+*/
+
+const (
+	OSPF_MD5_KEY_LEN = 16
+)
+
+/* Rfc 5709 3.3 Apad is the hexadecimal value 0x878FE1F3 repeated */
+var ospfHmacApad = []byte{0x87, 0x8F, 0xE1, 0xF3}
+
+type ospfAuthAlgorithm struct {
+	digestLen int
+	hashFn    func() hash.Hash
+}
+
+var ospfAuthAlgorithms = map[config.AuthAlgorithm]ospfAuthAlgorithm{
+	config.AuthMd5:        {md5.Size, md5.New},
+	config.AuthHmacSha1:   {sha1.Size, sha1.New},
+	config.AuthHmacSha256: {sha256.Size, sha256.New},
+	config.AuthHmacSha384: {sha512.Size384, sha512.New384},
+	config.AuthHmacSha512: {sha512.Size, sha512.New},
+}
+
+/*
+IntfCryptoAuth holds the key chain of the interface and the cryptographic
+sequence numbers. It is shared by all the copies of the interface
+config, so it is updated under its own lock.
+*/
+type IntfCryptoAuth struct {
+	authMutex sync.Mutex
+	keyChain  []config.AuthKey
+	txSeqNum  uint32
+	rxSeqNum  map[uint32]uint32 // Key: Neighbor Router Id, Value: Last cryptographic sequence number
+}
+
+func NewIntfCryptoAuth() *IntfCryptoAuth {
+	return &IntfCryptoAuth{
+		keyChain: make([]config.AuthKey, 0),
+		/* Rfc 2328 D.4.3 the sequence number must not decrease across restarts */
+		txSeqNum: uint32(time.Now().Unix()),
+		rxSeqNum: make(map[uint32]uint32),
+	}
+}
+
+func (auth *IntfCryptoAuth) setKeyChain(keyChain []config.AuthKey) {
+	auth.authMutex.Lock()
+	auth.keyChain = keyChain
+	auth.authMutex.Unlock()
+}
+
+func (auth *IntfCryptoAuth) resetRxSeqNum(nbrRtrId uint32) {
+	auth.authMutex.Lock()
+	delete(auth.rxSeqNum, nbrRtrId)
+	auth.authMutex.Unlock()
+}
+
+func isKeyLifetimeValid(start time.Time, end time.Time, now time.Time) bool {
+	if !start.IsZero() && now.Before(start) {
+		return false
+	}
+	if !end.IsZero() && !now.Before(end) {
+		return false
+	}
+	return true
+}
+
+/*
+Rfc 2328 D.3
+The key with the most recent send start time is used when the send
+lifetimes overlap. When the last key expires it is used until a new
+key is configured instead of reverting to unauthenticated packets.
+*/
+func (auth *IntfCryptoAuth) getSendKey(now time.Time) (key config.AuthKey, expired bool, err error) {
+	found := false
+	for _, authKey := range auth.keyChain {
+		if isKeyLifetimeValid(authKey.SendStart, authKey.SendEnd, now) {
+			if !found || authKey.SendStart.After(key.SendStart) {
+				key = authKey
+				found = true
+			}
+		}
+	}
+	if found {
+		return key, false, nil
+	}
+
+	for _, authKey := range auth.keyChain {
+		if !authKey.SendStart.IsZero() && now.Before(authKey.SendStart) {
+			continue
+		}
+		if !found || authKey.SendEnd.After(key.SendEnd) {
+			key = authKey
+			found = true
+		}
+	}
+	if found {
+		return key, true, nil
+	}
+	return key, false, errors.New("No valid send key in the key chain")
+}
+
+func (auth *IntfCryptoAuth) getAcceptKey(keyId uint8, now time.Time) (key config.AuthKey, err error) {
+	for _, authKey := range auth.keyChain {
+		if authKey.KeyId != keyId {
+			continue
+		}
+		if !isKeyLifetimeValid(authKey.AcceptStart, authKey.AcceptEnd, now) {
+			return key, errors.New(fmt.Sprintln("Key Id", keyId, "is not valid for accept"))
+		}
+		return authKey, nil
+	}
+	return key, errors.New(fmt.Sprintln("Key Id", keyId, "is not in the key chain"))
+}
+
+/*
+Rfc 2328 D.4.3 MD5 digest of the packet followed by the key padded
+to 16 bytes.
+Rfc 5709 3.3 HMAC digest of the packet followed by Apad with the key
+zero padded or hashed to the digest length.
+*/
+func computeOspfAuthDigest(key config.AuthKey, ospfPkt []byte) ([]byte, error) {
+	algo, exist := ospfAuthAlgorithms[key.Algorithm]
+	if !exist {
+		return nil, errors.New(fmt.Sprintln("Invalid authentication algorithm", key.Algorithm))
+	}
+
+	if key.Algorithm == config.AuthMd5 {
+		md5Key := make([]byte, OSPF_MD5_KEY_LEN)
+		copy(md5Key, []byte(key.Key))
+		h := md5.New()
+		h.Write(ospfPkt)
+		h.Write(md5Key)
+		return h.Sum(nil), nil
+	}
+
+	hmacKey := make([]byte, algo.digestLen)
+	if len(key.Key) > algo.digestLen {
+		h := algo.hashFn()
+		h.Write([]byte(key.Key))
+		hmacKey = h.Sum(nil)
+	} else {
+		copy(hmacKey, []byte(key.Key))
+	}
+	apad := make([]byte, algo.digestLen)
+	for i := 0; i < algo.digestLen; i += len(ospfHmacApad) {
+		copy(apad[i:], ospfHmacApad)
+	}
+	mac := hmac.New(algo.hashFn, hmacKey)
+	mac.Write(ospfPkt)
+	mac.Write(apad)
+	return mac.Sum(nil), nil
+}
+
+/*
+encodeOspfAuth fills the checksum and the authentication field of the
+encoded OSPF packet. With the cryptographic authentication the checksum
+is not computed and the message digest is appended to the packet.
+*/
+func (server *OSPFServer) encodeOspfAuth(ent IntfConf, ospf []byte) []byte {
+	if ent.IfAuthType != uint16(config.Md5) {
+		csum := computeCheckSum(ospf)
+		binary.BigEndian.PutUint16(ospf[12:14], csum)
+		copy(ospf[16:24], ent.IfAuthKey)
+		return ospf
+	}
+
+	auth := ent.IfCryptoAuth
+	if auth == nil {
+		server.logger.Err(fmt.Sprintln("AUTH: No key chain for the interface", ent.IfName))
+		return nil
+	}
+	auth.authMutex.Lock()
+	defer auth.authMutex.Unlock()
+
+	key, expired, err := auth.getSendKey(time.Now())
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("AUTH: Interface", ent.IfName, err))
+		return nil
+	}
+	if expired {
+		server.logger.Warning(fmt.Sprintln("AUTH: Last send key", key.KeyId, "of interface", ent.IfName,
+			"expired, it is used until a new key is configured"))
+	}
+
+	auth.txSeqNum++
+	binary.BigEndian.PutUint16(ospf[12:14], 0)
+	binary.BigEndian.PutUint16(ospf[16:18], 0)
+	ospf[18] = key.KeyId
+	ospf[19] = uint8(ospfAuthAlgorithms[key.Algorithm].digestLen)
+	binary.BigEndian.PutUint32(ospf[20:24], auth.txSeqNum)
+
+	digest, err := computeOspfAuthDigest(key, ospf)
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("AUTH: Interface", ent.IfName, err))
+		return nil
+	}
+	return append(ospf, digest...)
+}
+
+/*
+Rfc 2328 D.5.2
+The packet is accepted if the key id is valid for accept, the message
+digest matches and the cryptographic sequence number is not less than
+the one last received from the neighbor.
+*/
+func (server *OSPFServer) processOspfCryptoAuth(ent IntfConf, ospfPkt []byte, ospfHdr *OSPFHeader) error {
+	auth := ent.IfCryptoAuth
+	if auth == nil {
+		return errors.New("No key chain for the interface")
+	}
+
+	keyId := ospfPkt[18]
+	authLen := int(ospfPkt[19])
+	seqNum := binary.BigEndian.Uint32(ospfPkt[20:24])
+	nbrRtrId := binary.BigEndian.Uint32(ospfHdr.routerId)
+	pktlen := int(ospfHdr.pktlen)
+
+	auth.authMutex.Lock()
+	defer auth.authMutex.Unlock()
+
+	key, err := auth.getAcceptKey(keyId, time.Now())
+	if err != nil {
+		return err
+	}
+	algo := ospfAuthAlgorithms[key.Algorithm]
+	if authLen != algo.digestLen || len(ospfPkt) < pktlen+authLen {
+		return errors.New(fmt.Sprintln("Invalid authentication data length", authLen, "for key id", keyId))
+	}
+
+	if lastSeqNum, exist := auth.rxSeqNum[nbrRtrId]; exist && seqNum < lastSeqNum {
+		return errors.New(fmt.Sprintln("Replayed cryptographic sequence number", seqNum, "last received",
+			lastSeqNum))
+	}
+
+	digest, err := computeOspfAuthDigest(key, ospfPkt[:pktlen])
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(digest, ospfPkt[pktlen:pktlen+authLen]) {
+		return errors.New(fmt.Sprintln("Message digest mismatch for key id", keyId))
+	}
+
+	auth.rxSeqNum[nbrRtrId] = seqNum
+	return nil
+}
+
+func parseAuthKeyTime(t string) (time.Time, error) {
+	if t == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, t)
+}
+
+/*
+ConvertAuthKeyChain converts the key chain of the OspfIfEntry. The
+lifetimes are RFC 3339 times, an empty lifetime is not bounded.
+*/
+func ConvertAuthKeyChain(keys []*ospfd.OspfAuthKey) ([]config.AuthKey, error) {
+	keyChain := make([]config.AuthKey, 0, len(keys))
+	keyIds := make(map[uint8]bool)
+	for _, key := range keys {
+		if key == nil {
+			continue
+		}
+		if key.KeyId < 0 || key.KeyId > 255 {
+			return nil, errors.New(fmt.Sprintln("Invalid key id", key.KeyId))
+		}
+		authKey := config.AuthKey{
+			KeyId: uint8(key.KeyId),
+			Key:   key.Key,
+		}
+		if keyIds[authKey.KeyId] {
+			return nil, errors.New(fmt.Sprintln("Duplicate key id", key.KeyId))
+		}
+		keyIds[authKey.KeyId] = true
+
+		for index, algoName := range config.AuthAlgorithmList {
+			if strings.EqualFold(key.Algorithm, algoName) {
+				authKey.Algorithm = config.AuthAlgorithm(index)
+				break
+			}
+		}
+		if _, exist := ospfAuthAlgorithms[authKey.Algorithm]; !exist {
+			return nil, errors.New(fmt.Sprintln("Invalid authentication algorithm", key.Algorithm, "for key id",
+				key.KeyId))
+		}
+		if authKey.Key == "" || (authKey.Algorithm == config.AuthMd5 && len(authKey.Key) > OSPF_MD5_KEY_LEN) {
+			return nil, errors.New(fmt.Sprintln("Invalid key length", len(authKey.Key), "for key id", key.KeyId))
+		}
+
+		var err error
+		if authKey.SendStart, err = parseAuthKeyTime(key.SendStart); err != nil {
+			return nil, err
+		}
+		if authKey.SendEnd, err = parseAuthKeyTime(key.SendEnd); err != nil {
+			return nil, err
+		}
+		if authKey.AcceptStart, err = parseAuthKeyTime(key.AcceptStart); err != nil {
+			return nil, err
+		}
+		if authKey.AcceptEnd, err = parseAuthKeyTime(key.AcceptEnd); err != nil {
+			return nil, err
+		}
+		keyChain = append(keyChain, authKey)
+	}
+	return keyChain, nil
+}
@@ -0,0 +1,119 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"l3/ospf/config"
+	"testing"
+	"time"
+)
+
+func buildAuthTestPkt(t *testing.T, server *OSPFServer, ent IntfConf) []byte {
+	ospfHdr := OSPFHeader{
+		ver:      OSPF_VERSION_2,
+		pktType:  uint8(HelloType),
+		pktlen:   OSPF_HEADER_SIZE + 4,
+		routerId: []byte{10, 1, 1, 1},
+		areaId:   []byte{0, 0, 0, 0},
+		authType: uint16(config.Md5),
+	}
+	ospfPkt := append(encodeOspfHdr(ospfHdr), []byte{255, 255, 255, 0}...)
+	ospfPkt = server.encodeOspfAuth(ent, ospfPkt)
+	if ospfPkt == nil {
+		t.Fatal("Failed to encode the ospf authentication")
+	}
+	return ospfPkt
+}
+
+func TestOspfCryptoAuth(t *testing.T) {
+	server := getServerObject()
+	now := time.Now()
+	keyChain := []config.AuthKey{
+		{
+			KeyId:     1,
+			Algorithm: config.AuthMd5,
+			Key:       "oldkey",
+			SendEnd:   now.Add(-time.Minute),
+		},
+		{
+			KeyId:     2,
+			Algorithm: config.AuthHmacSha256,
+			Key:       "newkey",
+			SendStart: now.Add(-time.Minute),
+		},
+	}
+	txIntf := IntfConf{IfAuthType: uint16(config.Md5), IfCryptoAuth: NewIntfCryptoAuth()}
+	txIntf.IfCryptoAuth.setKeyChain(keyChain)
+	rxIntf := IntfConf{IfAuthType: uint16(config.Md5), IfCryptoAuth: NewIntfCryptoAuth()}
+	rxIntf.IfCryptoAuth.setKeyChain(keyChain)
+
+	first := buildAuthTestPkt(t, server, txIntf)
+	second := buildAuthTestPkt(t, server, txIntf)
+	if first[18] != 2 || len(first) != OSPF_HEADER_SIZE+4+32 {
+		t.Fatal("Expected the packet to be signed with key id 2 and a SHA-256 digest, got key id", first[18],
+			"length", len(first))
+	}
+
+	hdr := NewOSPFHeader()
+	decodeOspfHdr(second, hdr)
+	if err := server.processOspfCryptoAuth(rxIntf, second, hdr); err != nil {
+		t.Fatal("Failed to authenticate the packet:", err)
+	}
+
+	decodeOspfHdr(first, hdr)
+	if err := server.processOspfCryptoAuth(rxIntf, first, hdr); err == nil {
+		t.Fatal("Packet with a replayed sequence number is accepted")
+	}
+
+	rxIntf.IfCryptoAuth.resetRxSeqNum(binary.BigEndian.Uint32(hdr.routerId))
+	first[OSPF_HEADER_SIZE] ^= 0xff
+	if err := server.processOspfCryptoAuth(rxIntf, first, hdr); err == nil {
+		t.Fatal("Packet with a modified body is accepted")
+	}
+	first[OSPF_HEADER_SIZE] ^= 0xff
+	if err := server.processOspfCryptoAuth(rxIntf, first, hdr); err != nil {
+		t.Fatal("Failed to authenticate the packet after the neighbor reset:", err)
+	}
+
+	keyChain[1].AcceptEnd = now.Add(-time.Second)
+	rxIntf.IfCryptoAuth.setKeyChain(keyChain)
+	if err := server.processOspfCryptoAuth(rxIntf, second, hdr); err == nil {
+		t.Fatal("Packet signed with a key past its accept lifetime is accepted")
+	}
+}
+
+func TestOspfAuthLastKeyExpired(t *testing.T) {
+	now := time.Now()
+	auth := NewIntfCryptoAuth()
+	auth.setKeyChain([]config.AuthKey{
+		{KeyId: 1, Algorithm: config.AuthHmacSha1, Key: "key1", SendEnd: now.Add(-2 * time.Hour)},
+		{KeyId: 2, Algorithm: config.AuthHmacSha1, Key: "key2", SendEnd: now.Add(-time.Hour)},
+		{KeyId: 3, Algorithm: config.AuthHmacSha1, Key: "key3", SendStart: now.Add(time.Hour)},
+	})
+	key, expired, err := auth.getSendKey(now)
+	if err != nil || !expired || key.KeyId != 2 {
+		t.Fatal("Expected the last expired key 2 to be used, got", key.KeyId, expired, err)
+	}
+}
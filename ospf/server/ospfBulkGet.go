@@ -27,6 +27,9 @@ import (
 	"fmt"
 	"l3/ospf/config"
 	"net"
+	"sort"
+	"strings"
+	"time"
 )
 
 func (server *OSPFServer) GetBulkOspfAreaEntryState(idx int, cnt int) (int, int, []config.AreaState) {
@@ -144,6 +147,20 @@ func (server *OSPFServer) GetBulkOspfLsdbEntryState(idx int, cnt int) (int, int,
 			}
 			lsaEnc = encodeASExternalLsa(lsa, lsaKey)
 			lsaMd = lsa.LsaMd
+		} else if lsdbSliceEnt.LSType == NSSALSA {
+			lsa, exist := lsDbEnt.NssaLsaMap[lsaKey]
+			if !exist {
+				continue
+			}
+			lsaEnc = encodeASExternalLsa(lsa, lsaKey)
+			lsaMd = lsa.LsaMd
+		} else if isOpaqueLsa(lsdbSliceEnt.LSType) {
+			lsa, ret := server.getOpaqueLsaFromLsdb(lsdbKey.AreaId, lsdbSliceEnt.IntfKey, lsaKey)
+			if ret == LsdbEntryNotFound {
+				continue
+			}
+			lsaEnc = encodeOpaqueLsa(lsa, lsaKey)
+			lsaMd = lsa.LsaMd
 		}
 
 		server.logger.Info(fmt.Sprintln(lsaEnc))
@@ -236,12 +253,18 @@ func (server *OSPFServer) GetOspfGlobalState() *config.GlobalState {
 	result.RxNewLsas = ent.RxNewLsas
 	result.OpaqueLsaSupport = ent.OpaqueLsaSupport
 	result.RestartStatus = ent.RestartStatus
-	result.RestartAge = ent.RestartAge
+	result.RestartAge = int32(server.getGRRestartAge())
 	result.RestartExitReason = ent.RestartExitReason
 	result.AsLsaCount = ent.AsLsaCount
 	result.AsLsaCksumSum = ent.AsLsaCksumSum
 	result.StubRouterSupport = ent.StubRouterSupport
 	result.DiscontinuityTime = ent.DiscontinuityTime
+	spfStats := server.getSpfStats()
+	result.SpfRuns = spfStats.SpfRuns
+	result.PartialSpfRuns = spfStats.PartialSpfRuns
+	result.SpfThrottled = spfStats.SpfThrottled
+	result.LastSpfTrigger = spfStats.LastSpfTrigger
+	result.LastSpfDuration = int32(spfStats.LastSpfDuration / time.Millisecond)
 	server.logger.Info(fmt.Sprintln("Global State:", result))
 	return result
 }
@@ -283,9 +306,10 @@ func (server *OSPFServer) GetBulkOspfNbrEntryState(idx int, cnt int) (int, int,
 			result[i].NbrLsRetransQLen = 0
 			result[i].NbmaNbrPermanence = 0
 			result[i].NbrHelloSuppressed = false
-			result[i].NbrRestartHelperStatus = 0
-			result[i].NbrRestartHelperAge = 0
-			result[i].NbrRestartHelperExitReason = 0
+			helperStatus, helperAge, helperExitReason := server.getGRHelperState(key)
+			result[i].NbrRestartHelperStatus = int(helperStatus)
+			result[i].NbrRestartHelperAge = helperAge
+			result[i].NbrRestartHelperExitReason = int(helperExitReason)
 		}
 
 	}
@@ -294,3 +318,134 @@ func (server *OSPFServer) GetBulkOspfNbrEntryState(idx int, cnt int) (int, int,
 	server.logger.Info(fmt.Sprintln("length:", length, "count:", count, "nextIdx:", nextIdx, "result:", result))
 	return nextIdx, count, result
 }
+
+type V3NbrKeySlice []V3NbrKey
+
+func (s V3NbrKeySlice) Len() int      { return len(s) }
+func (s V3NbrKeySlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s V3NbrKeySlice) Less(i, j int) bool {
+	if s[i].IfIndex != s[j].IfIndex {
+		return s[i].IfIndex < s[j].IfIndex
+	}
+	return s[i].RouterId < s[j].RouterId
+}
+
+func getBulkRange(idx int, cnt int, length int) (int, int) {
+	if idx < 0 || idx >= length {
+		return 0, 0
+	}
+	if idx+cnt >= length {
+		return 0, length - idx
+	}
+	return idx + cnt, cnt
+}
+
+func (server *OSPFServer) GetBulkOspfSpfLogEntryState(idx int, cnt int) (int, int, []config.SpfLogState) {
+	spfStats := server.getSpfStats()
+	nextIdx, count := getBulkRange(idx, cnt, len(spfStats.SpfLog))
+	result := make([]config.SpfLogState, count)
+	for i := 0; i < count; i++ {
+		result[i] = convertSpfLogToState(idx+i, spfStats.SpfLog[idx+i])
+	}
+	return nextIdx, count, result
+}
+
+func (server *OSPFServer) GetBulkOspfv3NbrEntryState(idx int, cnt int) (int, int, []config.Ospfv3NbrState) {
+	server.v3Mutex.RLock()
+	defer server.v3Mutex.RUnlock()
+	keys := make([]V3NbrKey, 0, len(server.V3NbrMap))
+	for key, _ := range server.V3NbrMap {
+		keys = append(keys, key)
+	}
+	sort.Sort(V3NbrKeySlice(keys))
+	nextIdx, count := getBulkRange(idx, cnt, len(keys))
+	result := make([]config.Ospfv3NbrState, count)
+	for i := 0; i < count; i++ {
+		key := keys[idx+i]
+		ent := server.V3NbrMap[key]
+		result[i].NbrIfIndex = key.IfIndex
+		result[i].NbrRtrId = config.RouterId(convertUint32ToIPv4(ent.RouterId))
+		result[i].NbrIpAddress = config.IpAddress(ent.Addr.String())
+		result[i].NbrIntfId = ent.IntfId
+		result[i].NbrOptions = int(ent.Options)
+		result[i].NbrPriority = ent.Priority
+		result[i].NbrState = config.NbrStateList[int(ent.State)%len(config.NbrStateList)]
+		result[i].NbrEvents = ent.Events
+		result[i].NbrLsRetransQLen = len(ent.LsRetxList)
+	}
+	return nextIdx, count, result
+}
+
+func (server *OSPFServer) GetBulkOspfv3IPv6RouteState(idx int, cnt int) (int, int, []config.Ospfv3IPv6Route) {
+	server.v3Mutex.RLock()
+	defer server.v3Mutex.RUnlock()
+	prefixes := make([]string, 0, len(server.V3RoutingTbl))
+	for prefix, _ := range server.V3RoutingTbl {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	nextIdx, count := getBulkRange(idx, cnt, len(prefixes))
+	result := make([]config.Ospfv3IPv6Route, count)
+	for i := 0; i < count; i++ {
+		prefix := prefixes[idx+i]
+		ent := server.V3RoutingTbl[prefix]
+		nextHops := make([]string, 0, len(ent.NextHops))
+		for nextHop, _ := range ent.NextHops {
+			nextHops = append(nextHops, fmt.Sprintf("%s%%%d", nextHop.Addr, nextHop.IfIndex))
+		}
+		sort.Strings(nextHops)
+		result[i].DestPrefix = prefix
+		result[i].AreaId = convertUint32ToIPv4(ent.AreaId)
+		result[i].PathType = ent.PathType
+		result[i].Cost = int32(ent.Cost)
+		result[i].NumOfPaths = int32(len(ent.NextHops))
+		result[i].NextHops = strings.Join(nextHops, ",")
+	}
+	return nextIdx, count, result
+}
+
+type VirtLinkKeySlice []VirtLinkKey
+
+func (s VirtLinkKeySlice) Len() int      { return len(s) }
+func (s VirtLinkKeySlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s VirtLinkKeySlice) Less(i, j int) bool {
+	if s[i].TransitArea != s[j].TransitArea {
+		return s[i].TransitArea < s[j].TransitArea
+	}
+	return s[i].NbrRtrId < s[j].NbrRtrId
+}
+
+func (server *OSPFServer) GetBulkOspfVirtNbrEntryState(idx int, cnt int) (int, int, []config.VirtNbrState) {
+	server.virtLinkMutex.RLock()
+	defer server.virtLinkMutex.RUnlock()
+	keys := make([]VirtLinkKey, 0, len(server.VirtLinkMap))
+	for key, _ := range server.VirtLinkMap {
+		keys = append(keys, key)
+	}
+	sort.Sort(VirtLinkKeySlice(keys))
+	nextIdx, count := getBulkRange(idx, cnt, len(keys))
+	result := make([]config.VirtNbrState, count)
+	for i := 0; i < count; i++ {
+		key := keys[idx+i]
+		ent := server.VirtLinkMap[key]
+		result[i].VirtNbrArea = config.AreaId(convertUint32ToIPv4(key.TransitArea))
+		result[i].VirtNbrRtrId = config.RouterId(convertUint32ToIPv4(key.NbrRtrId))
+		result[i].VirtNbrIpAddress = config.IpAddress(convertUint32ToIPv4(ent.EndPoint.NbrIpAddr))
+		result[i].VirtNbrState = config.NbrDown
+		intf := server.IntfConfMap[ent.IntfKey]
+		for nbrKey, _ := range intf.NeighborMap {
+			nbr, exist := server.NeighborConfigMap[nbrKey]
+			if !exist {
+				continue
+			}
+			result[i].VirtNbrOptions = nbr.OspfNbrOptions
+			result[i].VirtNbrState = nbr.OspfNbrState
+			result[i].VirtNbrEvents = int(nbr.nbrEvent)
+			helperStatus, helperAge, helperExitReason := server.getGRHelperState(nbrKey)
+			result[i].VirtNbrRestartHelperStatus = helperStatus
+			result[i].VirtNbrRestartHelperAge = helperAge
+			result[i].VirtNbrRestartHelperExitReason = helperExitReason
+		}
+	}
+	return nextIdx, count, result
+}
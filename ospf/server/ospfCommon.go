@@ -131,6 +131,7 @@ const (
 	NPOption = 0x08
 	EAOption = 0x20
 	DCOption = 0x40
+	OOption  = 0x40 // RFC 5250 opaque capability
 )
 
 type IntfTxHandle struct {
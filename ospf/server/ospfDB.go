@@ -106,8 +106,13 @@ func (server *OSPFServer) StartDBListener() {
 
 func (server *OSPFServer) ReadOspfCfgFromDB() {
 	server.readGlobalConfFromDB()
+	server.initGracefulRestart()
 	server.readAreaConfFromDB()
 	server.readIntfConfFromDB()
+	server.readOspfv3IntfConfFromDB()
+	server.readAreaRangeConfFromDB()
+	server.readSummaryAddrConfFromDB()
+	server.readVirtIfConfFromDB()
 }
 
 func (server *OSPFServer) readGlobalConfFromDB() {
@@ -135,11 +140,18 @@ func (server *OSPFServer) readGlobalConfFromDB() {
 
 func (server *OSPFServer) applyOspfGlobalConf(conf *ospfd.OspfGlobal) error {
 	gConf := config.GlobalConf{
-		RouterId:        config.RouterId(conf.RouterId),
-		ASBdrRtrStatus:  conf.ASBdrRtrStatus,
-		TOSSupport:      conf.TOSSupport,
-		RestartSupport:  config.RestartSupport(conf.RestartSupport),
-		RestartInterval: conf.RestartInterval,
+		RouterId:           config.RouterId(conf.RouterId),
+		ASBdrRtrStatus:     conf.ASBdrRtrStatus,
+		TOSSupport:         conf.TOSSupport,
+		RestartSupport:     config.RestartSupport(conf.RestartSupport),
+		RestartInterval:    conf.RestartInterval,
+		SpfThrottleInitial: conf.SpfThrottleInitial,
+		SpfThrottleHold:    conf.SpfThrottleHold,
+		SpfThrottleMaxWait: conf.SpfThrottleMaxWait,
+		LsaThrottleInitial: conf.LsaThrottleInitial,
+		LsaThrottleHold:    conf.LsaThrottleHold,
+		LsaThrottleMaxWait: conf.LsaThrottleMaxWait,
+		LsaArrivalInterval: conf.LsaArrivalInterval,
 	}
 	err := server.processGlobalConfig(gConf)
 	if err != nil {
@@ -216,6 +228,116 @@ func (server *OSPFServer) readIntfConfFromDB() {
 	}
 }
 
+func (server *OSPFServer) readOspfv3IntfConfFromDB() {
+	server.logger.Info("Reading OSPFv3 interface object from DB")
+	var dbObj objects.Ospfv3IntfEntry
+	if server.dbHdl == nil {
+		server.logger.Err("Null db handle. No OSPFv3 Intf conf to be read from db.")
+		return
+	}
+
+	objList, err := server.dbHdl.GetAllObjFromDb(dbObj)
+	if err != nil {
+		server.logger.Err("DB query failed for Ospfv3IntfEntry")
+		return
+	}
+	for idx := 0; idx < len(objList); idx++ {
+		obj := ospfd.NewOspfv3IntfEntry()
+		dbObject := objList[idx].(objects.Ospfv3IntfEntry)
+		objects.ConvertospfdOspfv3IntfEntryObjToThrift(&dbObject, obj)
+		ifConf, err := ConvertOspfv3IntfConf(obj)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("Error applying OSPFv3 Interface Configuration", err))
+			continue
+		}
+		server.Ospfv3IntfConfigCh <- ifConf
+	}
+}
+
+func (server *OSPFServer) readAreaRangeConfFromDB() {
+	server.logger.Info("Reading area range object from DB")
+	var dbObj objects.OspfAreaRangeEntry
+	if server.dbHdl == nil {
+		server.logger.Err("Null db handle. No area range conf to be read from db.")
+		return
+	}
+
+	objList, err := server.dbHdl.GetAllObjFromDb(dbObj)
+	if err != nil {
+		server.logger.Err("DB query failed for OspfAreaRangeEntry")
+		return
+	}
+	for idx := 0; idx < len(objList); idx++ {
+		obj := ospfd.NewOspfAreaRangeEntry()
+		dbObject := objList[idx].(objects.OspfAreaRangeEntry)
+		objects.ConvertospfdOspfAreaRangeEntryObjToThrift(&dbObject, obj)
+		rangeConf, err := ConvertAreaRangeConf(obj)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("Error applying Area Range Configuration", err))
+			continue
+		}
+		server.AreaRangeConfigCh <- AreaRangeMsg{
+			Conf: rangeConf,
+		}
+	}
+}
+
+func (server *OSPFServer) readSummaryAddrConfFromDB() {
+	server.logger.Info("Reading summary address object from DB")
+	var dbObj objects.OspfSummaryAddrEntry
+	if server.dbHdl == nil {
+		server.logger.Err("Null db handle. No summary address conf to be read from db.")
+		return
+	}
+
+	objList, err := server.dbHdl.GetAllObjFromDb(dbObj)
+	if err != nil {
+		server.logger.Err("DB query failed for OspfSummaryAddrEntry")
+		return
+	}
+	for idx := 0; idx < len(objList); idx++ {
+		obj := ospfd.NewOspfSummaryAddrEntry()
+		dbObject := objList[idx].(objects.OspfSummaryAddrEntry)
+		objects.ConvertospfdOspfSummaryAddrEntryObjToThrift(&dbObject, obj)
+		sConf, err := ConvertSummaryAddrConf(obj)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("Error applying Summary Address Configuration", err))
+			continue
+		}
+		server.SummaryAddrConfigCh <- SummaryAddrMsg{
+			Conf: sConf,
+		}
+	}
+}
+
+func (server *OSPFServer) readVirtIfConfFromDB() {
+	server.logger.Info("Reading virtual link object from DB")
+	var dbObj objects.OspfVirtIfEntry
+	if server.dbHdl == nil {
+		server.logger.Err("Null db handle. No virtual link conf to be read from db.")
+		return
+	}
+
+	objList, err := server.dbHdl.GetAllObjFromDb(dbObj)
+	if err != nil {
+		server.logger.Err("DB query failed for OspfVirtIfEntry")
+		return
+	}
+	for idx := 0; idx < len(objList); idx++ {
+		obj := ospfd.NewOspfVirtIfEntry()
+		dbObject := objList[idx].(objects.OspfVirtIfEntry)
+		objects.ConvertospfdOspfVirtIfEntryObjToThrift(&dbObject, obj)
+		vConf, err := ConvertVirtIfConf(obj)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("Error applying Virtual Link Configuration", err))
+			continue
+		}
+		server.VirtLinkConfigCh <- VirtLinkMsg{
+			Conf: vConf,
+		}
+	}
+}
+
 func (server *OSPFServer) applyOspfIntfConf(conf *ospfd.OspfIfEntry) error {
 	ifConf := config.InterfaceConf{
 		IfIpAddress:       config.IpAddress(conf.IfIpAddress),
@@ -238,7 +360,14 @@ func (server *OSPFServer) applyOspfIntfConf(conf *ospfd.OspfIfEntry) error {
 		}
 	}
 
-	err := server.processIntfConfig(ifConf)
+	keyChain, err := ConvertAuthKeyChain(conf.IfAuthKeyChain)
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("Error Configuring Ospf Interface key chain", err))
+		return err
+	}
+	ifConf.IfAuthKeyChain = keyChain
+
+	err = server.processIntfConfig(ifConf)
 	if err != nil {
 		server.logger.Err("Error Configuring Ospf Area Configuration")
 		err := errors.New("Error Configuring Ospf Area Configuration")
@@ -387,6 +516,20 @@ func (server *OSPFServer) AddLsdbEntry(entry LsdbSliceEnt) error {
 		}
 		lsaEnc = encodeASExternalLsa(lsa, lsaKey)
 		lsaMd = lsa.LsaMd
+	} else if entry.LSType == NSSALSA {
+		lsa, exist := lsDbEnt.NssaLsaMap[lsaKey]
+		if !exist {
+			return nil
+		}
+		lsaEnc = encodeASExternalLsa(lsa, lsaKey)
+		lsaMd = lsa.LsaMd
+	} else if isOpaqueLsa(entry.LSType) {
+		lsa, ret := server.getOpaqueLsaFromLsdb(entry.AreaId, entry.IntfKey, lsaKey)
+		if ret == LsdbEntryNotFound {
+			return nil
+		}
+		lsaEnc = encodeOpaqueLsa(lsa, lsaKey)
+		lsaMd = lsa.LsaMd
 	}
 	adv := convertByteToOctetString(lsaEnc[OSPF_LSA_HEADER_SIZE:])
 
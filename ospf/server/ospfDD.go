@@ -281,24 +281,32 @@ func (server *OSPFServer) BuildDBDPkt(intfKey IntfConfKey, ent IntfConf,
 
 	ospfEncHdr := encodeOspfHdr(ospfHdr)
 	//server.logger.Info(fmt.Sprintln("ospfEncHdr:", ospfEncHdr))
+	if ent.IfType == config.VirtualLink {
+		/* Rfc 2328 10.8 Interface MTU is set to 0 on virtual links */
+		dbdData.interface_mtu = 0
+	}
 	dbdDataEnc := encodeDatabaseDescriptionData(dbdData)
 	//server.logger.Info(fmt.Sprintln("DBD Pkt:", dbdDataEnc))
 
 	ospf := append(ospfEncHdr, dbdDataEnc...)
 	//server.logger.Info(fmt.Sprintln("OSPF DBD:", ospf))
-	csum := computeCheckSum(ospf)
-	binary.BigEndian.PutUint16(ospf[12:14], csum)
-	copy(ospf[16:24], ent.IfAuthKey)
+	ospf = server.encodeOspfAuth(ent, ospf)
+	if ospf == nil {
+		return nil
+	}
 
 	var DstIP net.IP
 	var DstMAC net.HardwareAddr
 
-	ipPktlen := IP_HEADER_MIN_LEN + ospfHdr.pktlen
+	ipPktlen := IP_HEADER_MIN_LEN + len(ospf)
 	SrcIP := ent.IfIpAddr
 
 	if ent.IfType == config.NumberedP2P {
 		DstIP = net.ParseIP(config.AllSPFRouters)
 		DstMAC, _ = net.ParseMAC(config.McastMAC)
+	} else if ent.IfType == config.VirtualLink {
+		DstIP = ent.IfVirtNbrIpAddr
+		DstMAC = ent.IfVirtNextHopMac
 	} else {
 		DstIP = nbrConf.OspfNbrIPAddr
 		DstMAC = dstMAC
@@ -309,7 +317,7 @@ func (server *OSPFServer) BuildDBDPkt(intfKey IntfConfKey, ent IntfConf,
 		IHL:      uint8(IP_HEADER_MIN_LEN),
 		TOS:      uint8(0xc0),
 		Length:   uint16(ipPktlen),
-		TTL:      uint8(1),
+		TTL:      getOspfPktTTL(ent),
 		Protocol: layers.IPProtocol(OSPF_PROTO_ID),
 		SrcIP:    SrcIP,
 		DstIP:    DstIP,
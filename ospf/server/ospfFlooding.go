@@ -126,6 +126,23 @@ func (server *OSPFServer) SendSelfOrigLSA(areaId uint32, intfKey IntfConfKey) []
 				ospfLsaPkt.no_lsas++
 				total_len += pktLen
 
+			case NSSALSA:
+				entry, ret := server.getNssaLsaFromLsdb(areaId, key)
+				if ret == LsdbEntryNotFound {
+					continue
+				}
+				LsaEnc = encodeASExternalLsa(entry, key)
+				checksumOffset := uint16(14)
+				checkSum := computeFletcherChecksum(LsaEnc[2:], checksumOffset)
+				binary.BigEndian.PutUint16(LsaEnc[16:18], checkSum)
+				pktLen = len(LsaEnc)
+				binary.BigEndian.PutUint16(LsaEnc[18:20], uint16(pktLen))
+				lsaid := convertUint32ToIPv4(key.LSId)
+				server.logger.Info(fmt.Sprintln("Flood: NSSA  LSA = ", lsaid))
+				ospfLsaPkt.lsa = append(ospfLsaPkt.lsa, LsaEnc...)
+				ospfLsaPkt.no_lsas++
+				total_len += pktLen
+
 			} // end of case
 		}
 	}
@@ -195,6 +212,9 @@ func (server *OSPFServer) processFloodMsg(lsa_data ospfFloodMsg) {
 				continue // dont flood the LSA on the interface it is received.
 			}
 			send := server.nbrFloodCheck(lsa_data.nbrKey, key, intf, lsa_data.lsType)
+			if isOpaqueLsa(lsa_data.lsType) && !server.opaqueFloodCheck(key) {
+				continue // neighbors are not opaque capable
+			}
 			if send {
 				if lsa_data.pkt != nil {
 					server.logger.Info(fmt.Sprintln("LSASELFLOOD: Unicast LSA interface ", intf.IfIpAddr, " lsid ", lsid, " lstype ", lsa_data.lsType))
@@ -262,6 +282,14 @@ func (server *OSPFServer) processFloodMsg(lsa_data ospfFloodMsg) {
 		server.logger.Info(fmt.Sprintln("LSAEXTFLOOD: Flood external routes for lsa key ", lsa_data.lsaKey))
 		server.processAsExternalLSAFlood(lsa_data.lsaKey)
 
+	case LSANSSAFLOOD: //flood NSSA LSA
+		server.logger.Info(fmt.Sprintln("LSANSSAFLOOD: Flood NSSA LSA ", lsa_data.lsaKey, " area ", lsa_data.areaId))
+		server.processNssaLSAFlood(lsa_data.areaId, lsa_data.lsaKey)
+
+	case LSAOPAQUEFLOOD: //flood opaque LSA
+		server.logger.Info(fmt.Sprintln("LSAOPAQUEFLOOD: Flood opaque LSA ", lsa_data.lsaKey, " area ", lsa_data.areaId))
+		server.processOpaqueLSAFlood(lsa_data.areaId, lsa_data.intfKey, lsa_data.lsaKey)
+
 	case LSAAGE: // Flood aged LSAs
 		server.constructAndSendLsaAgeFlood()
 
@@ -365,6 +393,9 @@ func (server *OSPFServer) constructAndSendLsaAgeFlood() {
 Check if we need to flood the LSA on the interface
 */
 func (server *OSPFServer) nbrFloodCheck(nbrKey NeighborConfKey, key IntfConfKey, intf IntfConf, lsType uint8) bool {
+	if lsType == ASExternalLSA && intf.IfType == config.VirtualLink {
+		return false // AS external LSAs are not flooded over virtual links
+	}
 	/* Check neighbor state */
 	flood_check := true
 	nbrConf := server.NeighborConfigMap[nbrKey]
@@ -476,6 +507,9 @@ func (server *OSPFServer) floodSummaryLsa(pkt []byte, areaid uint32) {
 func (server *OSPFServer) processAsExternalLSAFlood(lsakey LsaKey) {
 	areaId := convertAreaOrRouterIdUint32("0.0.0.0")
 	for ent, _ := range server.AreaConfMap {
+		if server.isStubArea(ent.AreaId) || server.isNssaArea(ent.AreaId) {
+			continue
+		}
 		areaId = convertAreaOrRouterIdUint32(string(ent.AreaId))
 	}
 	var lsaEncPkt []byte
@@ -511,13 +545,13 @@ func (server *OSPFServer) floodASExternalLsa(pkt []byte) {
 	dstIp := net.IP{224, 0, 0, 5}
 	for key, _ := range server.IntfConfMap {
 		intf, ok := server.IntfConfMap[key]
-		if !ok {
-			continue
+		if !ok || intf.IfType == config.VirtualLink {
+			continue // AS external LSAs are not flooded over virtual links
 		}
 		areaId := config.AreaId(convertIPInByteToString(intf.IfAreaId))
 		isStub := server.isStubArea(areaId)
-		if isStub {
-			server.logger.Info(fmt.Sprintln("ASBR: Dont flood AS external as area is stub ", areaId))
+		if isStub || server.isNssaArea(areaId) {
+			server.logger.Info(fmt.Sprintln("ASBR: Dont flood AS external as area is stub/NSSA ", areaId))
 			continue
 		}
 		nbrMdata, ok := ospfIntfToNbrMap[key]
@@ -0,0 +1,851 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"l3/ospf/config"
+	"models/objects"
+	"net"
+	"ospfd"
+	"reflect"
+	"time"
+)
+
+/*
+	RFC 3623 Graceful OSPF Restart
+
+	Before a planned restart the router floods grace LSAs (link-local
+	opaque LSAs of type 3) on all the interfaces with full neighbors
+	and saves the restart state in the DB. After an unplanned restart
+	the grace LSAs are sent before the first hello.
+	The restarting router keeps the routes of the previous instance
+	till all of its adjacencies are full again or the grace period
+	expires, after which routes are reconciled with RIBd.
+
+	A helper keeps the restarting neighbor FULL, and its LSAs in use,
+	till the grace LSA is flushed, the grace period expires or the
+	topology changes.
+	Grace LSA TLVs have the same format as TE LSA TLVs.
+*/
+
+const (
+	GraceOpaqueType uint8  = 3
+	GraceOpaqueId   uint32 = 0
+)
+
+/* grace LSA TLVs */
+const (
+	GracePeriodTlv   uint16 = 1
+	GraceReasonTlv   uint16 = 2
+	GraceIntfAddrTlv uint16 = 3
+)
+
+/* restart reasons */
+const (
+	GRReasonUnknown         uint8 = 0
+	GRReasonSoftwareRestart uint8 = 1
+	GRReasonSoftwareUpgrade uint8 = 2
+	GRReasonSwitchover      uint8 = 3
+)
+
+const (
+	GR_DEFAULT_GRACE_PERIOD uint32 = 120 // seconds
+	GR_LSA_TX_WAIT                 = 1   // seconds
+	GR_DB_KEY                      = "OspfGracefulRestart"
+	GR_RUNNING_DB_KEY              = "OspfRunning"
+)
+
+type GraceLsaInfo struct {
+	GracePeriod uint32
+	Reason      uint8
+	IntfAddr    uint32
+}
+
+/* Restart state saved in the DB before a planned restart */
+type grDbEntry struct {
+	StartTime   int64
+	GracePeriod int
+	SeqNum      int
+	NbrCount    int
+}
+
+/* State of the restarting router */
+type GRRestartState struct {
+	startTime   time.Time
+	gracePeriod uint32
+	seqNum      int
+	nbrCount    int // full neighbors before the restart
+}
+
+/* Helper state of a restarting neighbor */
+type GRHelperEnt struct {
+	helping     bool
+	startTime   time.Time
+	gracePeriod uint32
+	exitReason  config.RestartExitReason
+	timer       *time.Timer
+}
+
+type grOpaqueApp struct {
+	server *OSPFServer
+}
+
+func encodeGraceLsa(grace GraceLsaInfo) []byte {
+	var info []byte
+	info = append(info, encodeTETlv(GracePeriodTlv, encodeTEUint32(grace.GracePeriod))...)
+	info = append(info, encodeTETlv(GraceReasonTlv, []byte{grace.Reason})...)
+	if grace.IntfAddr != 0 {
+		info = append(info, encodeTETlv(GraceIntfAddrTlv, encodeTEUint32(grace.IntfAddr))...)
+	}
+	return info
+}
+
+func decodeGraceLsa(info []byte, grace *GraceLsaInfo) {
+	getTETlvs(info, func(tlvType uint16, value []byte) {
+		switch tlvType {
+		case GracePeriodTlv:
+			if len(value) == 4 {
+				grace.GracePeriod = binary.BigEndian.Uint32(value)
+			}
+		case GraceReasonTlv:
+			if len(value) == 1 {
+				grace.Reason = value[0]
+			}
+		case GraceIntfAddrTlv:
+			if len(value) == 4 {
+				grace.IntfAddr = binary.BigEndian.Uint32(value)
+			}
+		}
+	})
+}
+
+func (server *OSPFServer) isGRSupported() bool {
+	return server.ospfGlobalConf.RestartSupport == config.PlannedOnly ||
+		server.ospfGlobalConf.RestartSupport == config.PlannedAndUnplanned
+}
+
+func (server *OSPFServer) isGRRestarting() bool {
+	return server.ospfGlobalConf.RestartStatus == config.PlannedRestart ||
+		server.ospfGlobalConf.RestartStatus == config.UnplannedRestart
+}
+
+func (server *OSPFServer) getGracePeriod() uint32 {
+	if server.ospfGlobalConf.RestartInterval > 0 {
+		return uint32(server.ospfGlobalConf.RestartInterval)
+	}
+	return GR_DEFAULT_GRACE_PERIOD
+}
+
+func (server *OSPFServer) getGraceLsaKey() LsaKey {
+	return LsaKey{
+		LSType:    LocalOpaqueLSA,
+		LSId:      buildOpaqueLsId(GraceOpaqueType, GraceOpaqueId),
+		AdvRouter: convertIPv4ToUint32(server.ospfGlobalConf.RouterId),
+	}
+}
+
+/*
+@fn OpaqueLsaUpdate
+Grace LSA received from a neighbor.
+*/
+func (app *grOpaqueApp) OpaqueLsaUpdate(areaId uint32, intfKey IntfConfKey, lsaKey LsaKey, info []byte, isDel bool) {
+	server := app.server
+	nbrKey, exist := server.getNbrKeyFromRtrId(intfKey, lsaKey.AdvRouter)
+	if !exist {
+		server.logger.Info(fmt.Sprintln("GR: Grace LSA from unknown neighbor ", convertUint32ToIPv4(lsaKey.AdvRouter)))
+		return
+	}
+	if isDel {
+		server.exitGRHelper(nbrKey, config.Completed)
+		return
+	}
+	var grace GraceLsaInfo
+	decodeGraceLsa(info, &grace)
+	server.enterGRHelper(areaId, intfKey, nbrKey, lsaKey, grace)
+}
+
+func (server *OSPFServer) getNbrKeyFromRtrId(intfKey IntfConfKey, rtrId uint32) (NeighborConfKey, bool) {
+	nbrMdata, exist := ospfIntfToNbrMap[intfKey]
+	if !exist {
+		return NeighborConfKey{}, false
+	}
+	for _, nbrKey := range nbrMdata.nbrList {
+		nbrConf, valid := server.NeighborConfigMap[nbrKey]
+		if valid && nbrConf.OspfNbrRtrId == rtrId {
+			return nbrKey, true
+		}
+	}
+	return NeighborConfKey{}, false
+}
+
+/*
+@fn enterGRHelper
+RFC 3623 3.1 Entering helper mode.
+*/
+func (server *OSPFServer) enterGRHelper(areaId uint32, intfKey IntfConfKey, nbrKey NeighborConfKey, lsaKey LsaKey, grace GraceLsaInfo) {
+	nbrConf := server.NeighborConfigMap[nbrKey]
+	if !server.isGRSupported() || server.isGRRestarting() {
+		server.logger.Info(fmt.Sprintln("GR: Helper mode is disabled. Nbr ", nbrConf.OspfNbrIPAddr))
+		return
+	}
+	if nbrConf.OspfNbrState != config.NbrFull {
+		server.logger.Info(fmt.Sprintln("GR: Nbr is not full ", nbrConf.OspfNbrIPAddr))
+		return
+	}
+	if server.ospfGlobalConf.RestartSupport == config.PlannedOnly &&
+		grace.Reason == GRReasonUnknown {
+		server.logger.Info(fmt.Sprintln("GR: Unplanned restart is not supported. Nbr ", nbrConf.OspfNbrIPAddr))
+		return
+	}
+	lsa, ret := server.getOpaqueLsaFromLsdb(areaId, intfKey, lsaKey)
+	if ret != LsdbEntryFound || uint32(lsa.LsaMd.LSAge) >= grace.GracePeriod {
+		server.logger.Info(fmt.Sprintln("GR: Grace period expired. Nbr ", nbrConf.OspfNbrIPAddr))
+		return
+	}
+	if server.isNbrRetxPending(nbrKey) {
+		server.logger.Info(fmt.Sprintln("GR: Retransmission list of nbr is not empty ", nbrConf.OspfNbrIPAddr))
+		return
+	}
+	startTime := time.Now().Add(-time.Duration(lsa.LsaMd.LSAge) * time.Second)
+	remaining := time.Duration(grace.GracePeriod-uint32(lsa.LsaMd.LSAge)) * time.Second
+
+	server.grHelperMutex.Lock()
+	ent := server.GRHelperMap[nbrKey]
+	if ent.timer != nil {
+		ent.timer.Stop()
+	}
+	ent.helping = true
+	ent.startTime = startTime
+	ent.gracePeriod = grace.GracePeriod
+	ent.exitReason = config.InProgress
+	ent.timer = time.AfterFunc(remaining, func() {
+		server.grHelperExpiryCh <- nbrKey
+	})
+	server.GRHelperMap[nbrKey] = ent
+	server.grHelperMutex.Unlock()
+
+	server.logger.Info(fmt.Sprintln("GR: Helping nbr ", nbrConf.OspfNbrIPAddr, " grace period ", grace.GracePeriod,
+		" reason ", grace.Reason))
+	server.DbEventOp <- DbEventMsg{
+		eventType: config.ADJACENCY,
+		eventInfo: "Graceful restart helper started for " + nbrConf.OspfNbrIPAddr.String(),
+	}
+}
+
+/*
+@fn exitGRHelper
+RFC 3623 3.2 Exiting helper mode. The adjacency is kept only if
+the dead interval has not expired since the last hello.
+*/
+func (server *OSPFServer) exitGRHelper(nbrKey NeighborConfKey, reason config.RestartExitReason) {
+	server.grHelperMutex.Lock()
+	ent, exist := server.GRHelperMap[nbrKey]
+	if !exist || !ent.helping {
+		server.grHelperMutex.Unlock()
+		return
+	}
+	ent.helping = false
+	ent.exitReason = reason
+	if ent.timer != nil {
+		ent.timer.Stop()
+		ent.timer = nil
+	}
+	server.GRHelperMap[nbrKey] = ent
+	server.grHelperMutex.Unlock()
+
+	nbrConf, valid := server.NeighborConfigMap[nbrKey]
+	if !valid {
+		return
+	}
+	if nbrConf.NbrDeadTimer != nil {
+		nbrConf.NbrDeadTimer.Reset(nbrConf.OspfNbrDeadTimer)
+	}
+	server.logger.Info(fmt.Sprintln("GR: Helper exit for nbr ", nbrConf.OspfNbrIPAddr, " reason ", reason))
+	server.DbEventOp <- DbEventMsg{
+		eventType: config.ADJACENCY,
+		eventInfo: fmt.Sprint("Graceful restart helper exit for ", nbrConf.OspfNbrIPAddr.String(), " reason ", reason),
+	}
+}
+
+/*
+@fn processGRHelperExpiry
+Grace period timer of a helped neighbor expired. The expiry of
+an earlier grace period that was already replaced is ignored.
+*/
+func (server *OSPFServer) processGRHelperExpiry(nbrKey NeighborConfKey) {
+	server.grHelperMutex.RLock()
+	ent, exist := server.GRHelperMap[nbrKey]
+	server.grHelperMutex.RUnlock()
+	if !exist || !ent.helping ||
+		time.Since(ent.startTime) < time.Duration(ent.gracePeriod)*time.Second {
+		return
+	}
+	server.exitGRHelper(nbrKey, config.TimeedOut)
+}
+
+func (server *OSPFServer) deleteGRHelper(nbrKey NeighborConfKey) {
+	server.grHelperMutex.Lock()
+	defer server.grHelperMutex.Unlock()
+	ent, exist := server.GRHelperMap[nbrKey]
+	if !exist {
+		return
+	}
+	if ent.timer != nil {
+		ent.timer.Stop()
+	}
+	delete(server.GRHelperMap, nbrKey)
+}
+
+func (server *OSPFServer) isGRHelping(nbrKey NeighborConfKey) bool {
+	server.grHelperMutex.RLock()
+	defer server.grHelperMutex.RUnlock()
+	ent, exist := server.GRHelperMap[nbrKey]
+	return exist && ent.helping
+}
+
+func (server *OSPFServer) isGRHelperActive() bool {
+	server.grHelperMutex.RLock()
+	defer server.grHelperMutex.RUnlock()
+	for _, ent := range server.GRHelperMap {
+		if ent.helping {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+@fn getGRHelperState
+Helper status, remaining grace period and exit reason of the neighbor.
+*/
+func (server *OSPFServer) getGRHelperState(nbrKey NeighborConfKey) (config.NbrRestartHelperStatus, uint32, config.RestartExitReason) {
+	server.grHelperMutex.RLock()
+	defer server.grHelperMutex.RUnlock()
+	ent, exist := server.GRHelperMap[nbrKey]
+	if !exist {
+		return config.NotHelping, 0, config.NoAttempt
+	}
+	if !ent.helping {
+		return config.NotHelping, 0, ent.exitReason
+	}
+	var age uint32
+	elapsed := uint32(time.Since(ent.startTime).Seconds())
+	if elapsed < ent.gracePeriod {
+		age = ent.gracePeriod - elapsed
+	}
+	return config.Helping, age, ent.exitReason
+}
+
+func (server *OSPFServer) isNbrRetxPending(nbrKey NeighborConfKey) bool {
+	for _, reTx := range ospfNeighborRetx_list[nbrKey] {
+		if reTx.valid {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+@fn exitGRHelperOnTopologyChange
+RFC 3623 3.2 Helper mode is exited for neighbors of the area
+when an LSA with changed contents is installed. Changes of
+AS scope LSAs end helping in all the areas.
+The restarting router itself is skipped.
+*/
+func (server *OSPFServer) exitGRHelperOnTopologyChange(areaId uint32, asScope bool, advRtr uint32) {
+	var nbrList []NeighborConfKey
+	server.grHelperMutex.RLock()
+	for nbrKey, ent := range server.GRHelperMap {
+		if ent.helping {
+			nbrList = append(nbrList, nbrKey)
+		}
+	}
+	server.grHelperMutex.RUnlock()
+	for _, nbrKey := range nbrList {
+		nbrConf, valid := server.NeighborConfigMap[nbrKey]
+		if !valid || nbrConf.OspfNbrRtrId == advRtr {
+			continue
+		}
+		intf, exist := server.IntfConfMap[nbrConf.intfConfKey]
+		if !asScope && (!exist || convertIPv4ToUint32(intf.IfAreaId) != areaId) {
+			continue
+		}
+		server.exitGRHelper(nbrKey, config.TopologyChanged)
+	}
+}
+
+/*
+@fn checkGRTopologyChange
+Called before a received LSA is installed.
+Refreshed LSAs are not a topology change.
+*/
+func (server *OSPFServer) checkGRTopologyChange(msg LsdbUpdateMsg) {
+	if msg.MsgType != LsdbAdd || !server.isGRHelperActive() {
+		return
+	}
+	lsaHdr := NewLsaHeader()
+	decodeLsaHeader(msg.Data, lsaHdr)
+	if !server.isLsaContentChanged(msg.AreaId, msg.Data) {
+		return
+	}
+	asScope := lsaHdr.LSType == ASExternalLSA
+	server.exitGRHelperOnTopologyChange(msg.AreaId, asScope, lsaHdr.Adv_router)
+}
+
+func lsaContentMd(lsaMd LsaMetadata) LsaMetadata {
+	return LsaMetadata{
+		Options: lsaMd.Options,
+		LSLen:   lsaMd.LSLen,
+	}
+}
+
+/*
+@fn isLsaContentChanged
+Compare the contents of the LSA with the LSDB copy ignoring
+age, sequence number and checksum.
+*/
+func (server *OSPFServer) isLsaContentChanged(areaId uint32, data []byte) bool {
+	lsaKey := NewLsaKey()
+	var newLsa, oldLsa interface{}
+	ret := LsdbEntryNotFound
+	switch data[3] {
+	case RouterLSA:
+		lsa := NewRouterLsa()
+		decodeRouterLsa(data, lsa, lsaKey)
+		old, retVal := server.getRouterLsaFromLsdb(areaId, *lsaKey)
+		lsa.LsaMd, old.LsaMd = lsaContentMd(lsa.LsaMd), lsaContentMd(old.LsaMd)
+		newLsa, oldLsa, ret = *lsa, old, retVal
+	case NetworkLSA:
+		lsa := NewNetworkLsa()
+		decodeNetworkLsa(data, lsa, lsaKey)
+		old, retVal := server.getNetworkLsaFromLsdb(areaId, *lsaKey)
+		lsa.LsaMd, old.LsaMd = lsaContentMd(lsa.LsaMd), lsaContentMd(old.LsaMd)
+		newLsa, oldLsa, ret = *lsa, old, retVal
+	case Summary3LSA, Summary4LSA:
+		lsa := NewSummaryLsa()
+		decodeSummaryLsa(data, lsa, lsaKey)
+		old, retVal := server.getSummaryLsaFromLsdb(areaId, *lsaKey)
+		lsa.LsaMd, old.LsaMd = lsaContentMd(lsa.LsaMd), lsaContentMd(old.LsaMd)
+		newLsa, oldLsa, ret = *lsa, old, retVal
+	case ASExternalLSA, NSSALSA:
+		lsa := NewASExternalLsa()
+		decodeASExternalLsa(data, lsa, lsaKey)
+		var old ASExternalLsa
+		var retVal int
+		if data[3] == NSSALSA {
+			old, retVal = server.getNssaLsaFromLsdb(areaId, *lsaKey)
+		} else {
+			old, retVal = server.getASExternalLsaFromLsdb(areaId, *lsaKey)
+		}
+		lsa.LsaMd, old.LsaMd = lsaContentMd(lsa.LsaMd), lsaContentMd(old.LsaMd)
+		newLsa, oldLsa, ret = *lsa, old, retVal
+	default:
+		return false
+	}
+	isMaxAge := binary.BigEndian.Uint16(data[0:2]) >= config.MaxAge
+	if ret != LsdbEntryFound {
+		return !isMaxAge
+	}
+	return isMaxAge || !reflect.DeepEqual(newLsa, oldLsa)
+}
+
+/*
+@fn startPlannedRestart
+RFC 3623 2.1 Flood grace LSAs on all the interfaces with full
+neighbors and save the restart state before exiting.
+The grace LSAs are originated by the LSDB goroutine, which owns
+the neighbor, interface and LSDB state.
+*/
+func (server *OSPFServer) startPlannedRestart() error {
+	if !server.ospfGlobalConf.OpaqueLsaSupport {
+		return errors.New("Graceful restart requires opaque LSA support")
+	}
+	var ent grDbEntry
+	select {
+	case server.grPlannedRestartCh <- true:
+		ent = <-server.grPlannedRestartRetCh
+	case <-time.After(time.Duration(GR_LSA_TX_WAIT) * time.Second):
+		return errors.New("LSDB is not running. Grace LSAs are not sent.")
+	}
+	/* wait for grace LSAs to be flooded */
+	time.Sleep(time.Duration(GR_LSA_TX_WAIT) * time.Second)
+
+	server.logger.Info(fmt.Sprintln("GR: Planned restart ", ent))
+	return server.storeGRStateInDB(ent)
+}
+
+/*
+@fn processPlannedRestart
+Called in the LSDB goroutine. Originate the grace LSAs and
+return the restart state to save.
+*/
+func (server *OSPFServer) processPlannedRestart() grDbEntry {
+	nbrCount := 0
+	intfList := make(map[IntfConfKey]bool)
+	for _, nbrConf := range server.NeighborConfigMap {
+		if nbrConf.OspfNbrState == config.NbrFull {
+			nbrCount++
+			intfList[nbrConf.intfConfKey] = true
+		}
+	}
+	gracePeriod := server.getGracePeriod()
+	for intfKey, _ := range intfList {
+		intf, exist := server.IntfConfMap[intfKey]
+		if !exist {
+			continue
+		}
+		grace := GraceLsaInfo{
+			GracePeriod: gracePeriod,
+			Reason:      GRReasonSoftwareRestart,
+			IntfAddr:    convertAreaOrRouterIdUint32(intf.IfIpAddr.String()),
+		}
+		msg := OpaqueLsaMsg{
+			LSType:     LocalOpaqueLSA,
+			OpaqueType: GraceOpaqueType,
+			OpaqueId:   GraceOpaqueId,
+			IntfKey:    intfKey,
+			Info:       encodeGraceLsa(grace),
+		}
+		server.processOpaqueLsaMsg(msg)
+	}
+
+	seqNum := InitialSequenceNumber
+	lsaKey := server.getGraceLsaKey()
+	for intfKey, _ := range intfList {
+		lsa, exist := server.IntfOpaqueLsdb[intfKey][lsaKey]
+		if exist && lsa.LsaMd.LSSequenceNum > seqNum {
+			seqNum = lsa.LsaMd.LSSequenceNum
+		}
+	}
+	return grDbEntry{
+		StartTime:   time.Now().Unix(),
+		GracePeriod: int(gracePeriod),
+		SeqNum:      seqNum,
+		NbrCount:    nbrCount,
+	}
+}
+
+func (server *OSPFServer) storeGRStateInDB(ent grDbEntry) error {
+	if server.dbHdl == nil {
+		return errors.New("Null db handle. Restart state is not saved.")
+	}
+	_, err := server.dbHdl.Do("HMSET", redis.Args{}.Add(GR_DB_KEY).AddFlat(&ent)...)
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("GR: Failed to store restart state in db ", err))
+	}
+	return err
+}
+
+/*
+@fn readGRStateFromDB
+Restart state is valid only for the next start and is deleted
+once read.
+*/
+func (server *OSPFServer) readGRStateFromDB() (ent grDbEntry, found bool) {
+	vals, err := redis.Values(server.dbHdl.Do("HGETALL", GR_DB_KEY))
+	if err != nil || len(vals) == 0 {
+		return ent, false
+	}
+	server.dbHdl.Do("DEL", GR_DB_KEY)
+	err = redis.ScanStruct(vals, &ent)
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("GR: Invalid restart state in db ", err))
+		return ent, false
+	}
+	return ent, true
+}
+
+/*
+@fn readRunningMarkerFromDB
+The running marker is set at start up and cleared when ospfd is
+stopped. Finding it at start up means that the previous instance
+exited unexpectedly.
+*/
+func (server *OSPFServer) readRunningMarkerFromDB() bool {
+	found, err := redis.Bool(server.dbHdl.Do("EXISTS", GR_RUNNING_DB_KEY))
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("GR: Failed to read running marker from db ", err))
+		return false
+	}
+	return found
+}
+
+func (server *OSPFServer) storeRunningMarkerInDB() {
+	_, err := server.dbHdl.Do("SET", GR_RUNNING_DB_KEY, time.Now().Unix())
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("GR: Failed to store running marker in db ", err))
+	}
+}
+
+func (server *OSPFServer) clearRunningMarkerInDB() {
+	if server.dbHdl == nil {
+		return
+	}
+	server.dbHdl.Do("DEL", GR_RUNNING_DB_KEY)
+}
+
+/*
+@fn readIPv4RoutesStateFromDB
+Routes installed by the previous instance.
+*/
+func (server *OSPFServer) readIPv4RoutesStateFromDB() map[RoutingTblEntryKey]GlobalRoutingTblEntry {
+	routes := make(map[RoutingTblEntryKey]GlobalRoutingTblEntry)
+	var dbObj objects.OspfIPv4RouteState
+	objList, err := server.dbHdl.GetAllObjFromDb(dbObj)
+	if err != nil {
+		server.logger.Err("DB query failed for OspfIPv4RouteState")
+		return routes
+	}
+	for idx := 0; idx < len(objList); idx++ {
+		obj := ospfd.NewOspfIPv4RouteState()
+		dbObject := objList[idx].(objects.OspfIPv4RouteState)
+		objects.ConvertospfdOspfIPv4RouteStateObjToThrift(&dbObject, obj)
+		if len(obj.DestType) == 0 {
+			continue
+		}
+		rKey := RoutingTblEntryKey{
+			DestId:   convertAreaOrRouterIdUint32(obj.DestId),
+			AddrMask: convertAreaOrRouterIdUint32(obj.AddrMask),
+			DestType: DestType(obj.DestType[0]),
+		}
+		var rEnt GlobalRoutingTblEntry
+		rEnt.AreaId = convertAreaOrRouterIdUint32(obj.AreaId)
+		rEnt.RoutingTblEnt.Cost = uint16(obj.Cost)
+		rEnt.RoutingTblEnt.Type2Cost = uint16(obj.Type2Cost)
+		rEnt.RoutingTblEnt.NumOfPaths = int(obj.NumOfPaths)
+		rEnt.RoutingTblEnt.NextHops = make(map[NextHop]bool)
+		for _, nh := range obj.NextHops {
+			nextHop := NextHop{
+				IfIPAddr:  convertAreaOrRouterIdUint32(nh.IfIPAddr),
+				IfIdx:     uint32(nh.IfIdx),
+				NextHopIP: convertAreaOrRouterIdUint32(nh.NextHopIP),
+				AdvRtr:    convertAreaOrRouterIdUint32(nh.AdvRtr),
+			}
+			rEnt.RoutingTblEnt.NextHops[nextHop] = true
+		}
+		routes[rKey] = rEnt
+	}
+	return routes
+}
+
+/*
+@fn initGracefulRestart
+Called at start up once the global config is read from the DB.
+Routes of the previous instance are the initial routing table so
+that they are kept during a graceful restart and reconciled with
+RIBd on the first routing table calculation after it. They are
+handed to the SPF goroutine which owns the routing table.
+An unplanned restart is detected from the running marker left
+by the previous instance.
+*/
+func (server *OSPFServer) initGracefulRestart() {
+	grEnt, found := server.readGRStateFromDB()
+	crashed := server.readRunningMarkerFromDB()
+	server.storeRunningMarkerInDB()
+	routes := server.readIPv4RoutesStateFromDB()
+	now := time.Now()
+	if found && server.isGRSupported() &&
+		now.Unix() < grEnt.StartTime+int64(grEnt.GracePeriod) {
+		server.ospfGlobalConf.RestartStatus = config.PlannedRestart
+		server.grRestart = GRRestartState{
+			startTime:   time.Unix(grEnt.StartTime, 0),
+			gracePeriod: uint32(grEnt.GracePeriod),
+			seqNum:      grEnt.SeqNum,
+			nbrCount:    grEnt.NbrCount,
+		}
+	} else if !found && crashed && server.ospfGlobalConf.RestartSupport == config.PlannedAndUnplanned &&
+		server.ospfGlobalConf.OpaqueLsaSupport && len(routes) > 0 {
+		server.ospfGlobalConf.RestartStatus = config.UnplannedRestart
+		server.grRestart = GRRestartState{
+			startTime:   now,
+			gracePeriod: server.getGracePeriod(),
+			seqNum:      InitialSequenceNumber,
+		}
+	}
+	server.grRoutesCh <- routes
+	if !server.isGRRestarting() {
+		return
+	}
+	server.ospfGlobalConf.RestartExitReason = config.InProgress
+	server.logger.Info(fmt.Sprintln("GR: Graceful restart ", server.ospfGlobalConf.RestartStatus,
+		" routes ", len(routes), " grace period ", server.grRestart.gracePeriod))
+}
+
+func (server *OSPFServer) getGRRestartAge() uint32 {
+	if !server.isGRRestarting() {
+		return 0
+	}
+	elapsed := uint32(time.Since(server.grRestart.startTime).Seconds())
+	if elapsed >= server.grRestart.gracePeriod {
+		return 0
+	}
+	return server.grRestart.gracePeriod - elapsed
+}
+
+func (server *OSPFServer) getFullNbrCount() int {
+	count := 0
+	for _, nbrConf := range server.NeighborConfigMap {
+		if nbrConf.OspfNbrState == config.NbrFull {
+			count++
+		}
+	}
+	return count
+}
+
+/*
+@fn checkGRRestartExit
+RFC 3623 2.3 Restart ends when the adjacencies of the previous
+instance are full again or the grace period expires.
+*/
+func (server *OSPFServer) checkGRRestartExit() {
+	if !server.isGRRestarting() {
+		return
+	}
+	if server.getGRRestartAge() == 0 {
+		server.exitGRRestart(config.TimeedOut)
+		return
+	}
+	if server.grRestart.nbrCount > 0 &&
+		server.getFullNbrCount() >= server.grRestart.nbrCount {
+		server.exitGRRestart(config.Completed)
+	}
+}
+
+/*
+@fn exitGRRestart
+RFC 3623 2.3 Originate the LSAs that were suppressed during the
+restart, reconcile the routes of the previous instance with the
+calculated routing table and flush the grace LSAs.
+*/
+func (server *OSPFServer) exitGRRestart(reason config.RestartExitReason) {
+	server.logger.Info(fmt.Sprintln("GR: Graceful restart exit ", reason))
+	server.ospfGlobalConf.RestartStatus = config.NotRestarting
+	server.ospfGlobalConf.RestartExitReason = reason
+	server.originateGRSuppressedLsas()
+	server.runSpfNow(SpfFull, "Graceful restart exit")
+	if server.ospfGlobalConf.OpaqueLsaSupport {
+		for intfKey, intf := range server.IntfConfMap {
+			if intf.IfAdminStat != config.Enabled {
+				continue
+			}
+			server.sendGraceLsa(intfKey, intf, server.grRestart.seqNum+1, config.MaxAge, GraceLsaInfo{})
+		}
+	}
+	server.DbEventOp <- DbEventMsg{
+		eventType: config.RIB,
+		eventInfo: fmt.Sprint("Graceful restart exit reason ", reason),
+	}
+}
+
+/*
+@fn originateGRSuppressedLsas
+RFC 3623 2.2 (1) Router, network and AS external LSAs are not
+originated during the restart. They are originated from the
+current state once it ends. Summary and NSSA LSAs are originated
+by the routing table calculation run after this.
+*/
+func (server *OSPFServer) originateGRSuppressedLsas() {
+	rtrId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+	for key, aEnt := range server.AreaConfMap {
+		if len(aEnt.IntfListMap) == 0 {
+			continue
+		}
+		server.lsaGenPending.areas[convertAreaOrRouterIdUint32(string(key.AreaId))] = true
+	}
+	for intfKey, intf := range server.IntfConfMap {
+		if intf.IfDRtrId == rtrId && intf.IfType == config.Broadcast {
+			server.lsaGenPending.networks[intfKey] = convertIPv4ToUint32(intf.IfAreaId)
+		}
+	}
+	server.generatePendingLsas()
+	for _, route := range server.extLsaMap {
+		server.originateExtRouteLsa(route)
+	}
+
+	lsaKey := LsaKey{}
+	nbr := NeighborConfKey{}
+	for intfKey, intf := range server.IntfConfMap {
+		if intf.IfAdminStat != config.Enabled {
+			continue
+		}
+		server.sendLsdbToNeighborEvent(intfKey, nbr, convertIPv4ToUint32(intf.IfAreaId), 0, 0, lsaKey, LSAFLOOD)
+	}
+}
+
+/*
+@fn sendRestartGraceLsa
+RFC 3623 2.1 After an unplanned restart grace LSAs are sent
+before the first hello on the interface.
+*/
+func (server *OSPFServer) sendRestartGraceLsa(intfKey IntfConfKey) {
+	if server.ospfGlobalConf.RestartStatus != config.UnplannedRestart {
+		return
+	}
+	intf, exist := server.IntfConfMap[intfKey]
+	if !exist {
+		return
+	}
+	elapsed := uint32(time.Since(server.grRestart.startTime).Seconds())
+	if elapsed >= server.grRestart.gracePeriod {
+		return
+	}
+	grace := GraceLsaInfo{
+		GracePeriod: server.grRestart.gracePeriod,
+		Reason:      GRReasonUnknown,
+		IntfAddr:    convertAreaOrRouterIdUint32(intf.IfIpAddr.String()),
+	}
+	server.sendGraceLsa(intfKey, intf, server.grRestart.seqNum, uint16(elapsed), grace)
+}
+
+/*
+@fn sendGraceLsa
+Grace LSAs of a restarting router are not installed in the LSDB
+and are sent directly on the link.
+*/
+func (server *OSPFServer) sendGraceLsa(intfKey IntfConfKey, intf IntfConf, seqNum int, age uint16, grace GraceLsaInfo) {
+	lsaKey := server.getGraceLsaKey()
+	lsa := OpaqueLsa{
+		Info: encodeGraceLsa(grace),
+	}
+	lsa.LsaMd.LSAge = age
+	lsa.LsaMd.Options = server.getOpaqueLsaOptions(convertIPv4ToUint32(intf.IfAreaId))
+	lsa.LsaMd.LSSequenceNum = seqNum
+	lsa.LsaMd.LSLen = uint16(OSPF_LSA_HEADER_SIZE + len(lsa.Info))
+	lsaEnc := encodeOpaqueLsa(lsa, lsaKey)
+	checksum := computeFletcherChecksum(lsaEnc[2:], 14)
+	binary.BigEndian.PutUint16(lsaEnc[16:18], checksum)
+
+	lsaEncPkt := make([]byte, 4)
+	binary.BigEndian.PutUint32(lsaEncPkt, 1)
+	lsaEncPkt = append(lsaEncPkt, lsaEnc...)
+	dstMac := net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x05}
+	dstIp := net.IP{224, 0, 0, 5}
+	pkt := server.BuildLsaUpdPkt(intfKey, intf, dstMac, dstIp, len(lsaEncPkt), lsaEncPkt)
+	server.SendOspfPkt(intfKey, pkt)
+	server.logger.Info(fmt.Sprintln("GR: Sent grace LSA on ", intf.IfIpAddr, " age ", age, " seq ", seqNum))
+}
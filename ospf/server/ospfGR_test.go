@@ -0,0 +1,145 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"l3/ospf/config"
+	"testing"
+	"time"
+)
+
+func TestGraceLsaEncodeDecode(t *testing.T) {
+	grace := GraceLsaInfo{
+		GracePeriod: 90,
+		Reason:      GRReasonSoftwareRestart,
+		IntfAddr:    0x0a000001,
+	}
+	var dGrace GraceLsaInfo
+	decodeGraceLsa(encodeGraceLsa(grace), &dGrace)
+	if dGrace != grace {
+		t.Errorf("Grace LSA not preserved %+v %+v", grace, dGrace)
+	}
+
+	/* truncated TLV */
+	dGrace = GraceLsaInfo{}
+	data := encodeGraceLsa(grace)
+	decodeGraceLsa(data[:len(data)-4], &dGrace)
+	if dGrace.IntfAddr != 0 || dGrace.GracePeriod != grace.GracePeriod {
+		t.Errorf("Truncated grace LSA decoded %+v", dGrace)
+	}
+}
+
+func TestGRHelperState(t *testing.T) {
+	server := getServerObject()
+	nbrKey := NeighborConfKey{
+		IPAddr:  config.IpAddress("10.0.0.2"),
+		IntfIdx: 1,
+	}
+	status, age, reason := server.getGRHelperState(nbrKey)
+	if status != config.NotHelping || age != 0 || reason != config.NoAttempt {
+		t.Errorf("Invalid helper state %v %v %v", status, age, reason)
+	}
+
+	/* helper mode is disabled by default */
+	server.NeighborConfigMap[nbrKey] = OspfNeighborEntry{
+		OspfNbrState: config.NbrFull,
+	}
+	grace := GraceLsaInfo{
+		GracePeriod: 60,
+		Reason:      GRReasonSoftwareRestart,
+	}
+	server.enterGRHelper(0, IntfConfKey{}, nbrKey, server.getGraceLsaKey(), grace)
+	if server.isGRHelping(nbrKey) {
+		t.Errorf("Helper mode entered with graceful restart disabled")
+	}
+
+	/* unplanned restart with planned only support */
+	server.ospfGlobalConf.RestartSupport = config.PlannedOnly
+	grace.Reason = GRReasonUnknown
+	server.enterGRHelper(0, IntfConfKey{}, nbrKey, server.getGraceLsaKey(), grace)
+	if server.isGRHelping(nbrKey) {
+		t.Errorf("Helper mode entered for unplanned restart")
+	}
+}
+
+func TestGRHelperExpiry(t *testing.T) {
+	server := getServerObject()
+	server.InitDBChannels()
+	go startDummyChannels(server)
+	nbrKey := NeighborConfKey{
+		IPAddr:  config.IpAddress("10.0.0.2"),
+		IntfIdx: 1,
+	}
+	server.NeighborConfigMap[nbrKey] = OspfNeighborEntry{
+		OspfNbrState: config.NbrFull,
+	}
+	server.GRHelperMap[nbrKey] = GRHelperEnt{
+		helping:     true,
+		startTime:   time.Now(),
+		gracePeriod: 60,
+		exitReason:  config.InProgress,
+	}
+
+	/* expiry of an earlier grace period */
+	server.processGRHelperExpiry(nbrKey)
+	if !server.isGRHelping(nbrKey) {
+		t.Errorf("Helper mode exited before the grace period expired")
+	}
+
+	ent := server.GRHelperMap[nbrKey]
+	ent.startTime = time.Now().Add(-61 * time.Second)
+	server.GRHelperMap[nbrKey] = ent
+	server.processGRHelperExpiry(nbrKey)
+	status, _, reason := server.getGRHelperState(nbrKey)
+	if status != config.NotHelping || reason != config.TimeedOut {
+		t.Errorf("Invalid helper state after grace period expiry %v %v", status, reason)
+	}
+}
+
+func TestGRRestartLsaSuppression(t *testing.T) {
+	server := initNssaTranslateTestServer()
+	server.initThrottleTimers()
+	server.ospfGlobalConf.RestartStatus = config.PlannedRestart
+	route := RouteMdata{
+		ipaddr: 0x0a030000,
+		mask:   0xffffff00,
+		metric: 20,
+	}
+	server.processExtRouteUpd(route)
+	if _, exist := getNssaTestExtLsa(server, route.ipaddr); exist {
+		t.Errorf("AS external LSA originated during graceful restart")
+	}
+	if _, exist := server.extLsaMap[getExtRouteKey(route)]; !exist {
+		t.Errorf("External route not tracked during graceful restart")
+	}
+
+	server.exitGRRestart(config.Completed)
+	if server.isGRRestarting() || server.ospfGlobalConf.RestartExitReason != config.Completed {
+		t.Errorf("Invalid restart state after exit %v %v", server.ospfGlobalConf.RestartStatus,
+			server.ospfGlobalConf.RestartExitReason)
+	}
+	if lsa, exist := getNssaTestExtLsa(server, route.ipaddr); !exist || lsa.Metric != route.metric {
+		t.Errorf("AS external LSA not originated after graceful restart %+v", lsa)
+	}
+}
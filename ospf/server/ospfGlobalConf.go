@@ -50,6 +50,14 @@ type GlobalConf struct {
 	OriginateNewLsas         int32
 	RxNewLsas                int32
 	OpaqueLsaSupport         bool
+	TESupport                bool
+	SpfThrottleInitial       int32
+	SpfThrottleHold          int32
+	SpfThrottleMaxWait       int32
+	LsaThrottleInitial       int32
+	LsaThrottleHold          int32
+	LsaThrottleMaxWait       int32
+	LsaArrivalInterval       int32
 	RestartStatus            config.RestartStatus
 	RestartAge               int32
 	RestartExitReason        config.RestartExitReason
@@ -74,6 +82,16 @@ func (server *OSPFServer) updateGlobalConf(gConf config.GlobalConf) {
 	server.ospfGlobalConf.RestartSupport = gConf.RestartSupport
 	server.ospfGlobalConf.RestartInterval = gConf.RestartInterval
 	server.ospfGlobalConf.ReferenceBandwidth = uint32(gConf.ReferenceBandwidth)
+	server.ospfGlobalConf.OpaqueLsaSupport = gConf.OpaqueLsaSupport
+	server.ospfGlobalConf.TESupport = gConf.TESupport && gConf.OpaqueLsaSupport
+	server.ospfGlobalConf.SpfThrottleInitial = gConf.SpfThrottleInitial
+	server.ospfGlobalConf.SpfThrottleHold = gConf.SpfThrottleHold
+	server.ospfGlobalConf.SpfThrottleMaxWait = gConf.SpfThrottleMaxWait
+	server.ospfGlobalConf.LsaThrottleInitial = gConf.LsaThrottleInitial
+	server.ospfGlobalConf.LsaThrottleHold = gConf.LsaThrottleHold
+	server.ospfGlobalConf.LsaThrottleMaxWait = gConf.LsaThrottleMaxWait
+	server.ospfGlobalConf.LsaArrivalInterval = gConf.LsaArrivalInterval
+	server.initThrottleConf()
 	server.logger.Err("Global configuration updated")
 }
 
@@ -103,6 +121,8 @@ func (server *OSPFServer) initOspfGlobalConfDefault() {
 	server.ospfGlobalConf.OriginateNewLsas = 0
 	server.ospfGlobalConf.RxNewLsas = 0
 	server.ospfGlobalConf.OpaqueLsaSupport = false
+	server.ospfGlobalConf.TESupport = false
+	server.initThrottleConfDefault()
 	server.ospfGlobalConf.RestartStatus = config.NotRestarting
 	server.ospfGlobalConf.RestartAge = 0
 	server.ospfGlobalConf.RestartExitReason = config.NoAttempt
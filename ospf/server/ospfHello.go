@@ -106,6 +106,9 @@ func (server *OSPFServer) BuildHelloPkt(ent IntfConf) []byte {
 	option := uint8(2)
 	if isStub {
 		option = uint8(0)
+	} else if server.isNssaArea(areaId) {
+		/* RFC 3101 2.2: N-bit set and E-bit clear in NSSA hellos */
+		option = uint8(NPOption)
 	}
 	helloData := OSPFHelloData{
 		netmask:             ent.IfNetmask,
@@ -144,25 +147,33 @@ func (server *OSPFServer) BuildHelloPkt(ent IntfConf) []byte {
 
 	ospf := append(ospfEncHdr, helloDataNbrEnc...)
 	//server.logger.Debug(fmt.Sprintln("ospf:", ospf))
-	csum := computeCheckSum(ospf)
-	binary.BigEndian.PutUint16(ospf[12:14], csum)
-	copy(ospf[16:24], ent.IfAuthKey)
+	ospf = server.encodeOspfAuth(ent, ospf)
+	if ospf == nil {
+		return nil
+	}
+
+	dstIp := net.IP{224, 0, 0, 5}
+	dstMAC := net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x05}
+	if ent.IfType == config.VirtualLink {
+		dstIp = ent.IfVirtNbrIpAddr
+		dstMAC = ent.IfVirtNextHopMac
+	}
 
-	ipPktlen := IP_HEADER_MIN_LEN + ospfHdr.pktlen
+	ipPktlen := IP_HEADER_MIN_LEN + len(ospf)
 	ipLayer := layers.IPv4{
 		Version:  uint8(4),
 		IHL:      uint8(IP_HEADER_MIN_LEN),
 		TOS:      uint8(0xc0),
 		Length:   uint16(ipPktlen),
-		TTL:      uint8(1),
+		TTL:      getOspfPktTTL(ent),
 		Protocol: layers.IPProtocol(OSPF_PROTO_ID),
 		SrcIP:    ent.IfIpAddr,
-		DstIP:    net.IP{224, 0, 0, 5},
+		DstIP:    dstIp,
 	}
 
 	ethLayer := layers.Ethernet{
 		SrcMAC:       ent.IfMacAddr,
-		DstMAC:       net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x05},
+		DstMAC:       dstMAC,
 		EthernetType: layers.EthernetTypeIPv4,
 	}
 
@@ -188,8 +199,9 @@ func (server *OSPFServer) processRxHelloPkt(data []byte, ospfHdrMd *OspfHdrMetad
 	}
 	decodeOspfHelloData(data, ospfHelloData)
 
-	//  Todo: Sec 10.5 RFC2328 Need to add check for Virtual links
-	if ent.IfType != config.NumberedP2P || ent.IfType != config.UnnumberedP2P {
+	// Sec 10.5 RFC2328 Network mask is not checked on virtual links
+	if ent.IfType != config.VirtualLink &&
+		(ent.IfType != config.NumberedP2P || ent.IfType != config.UnnumberedP2P) {
 		if bytesEqual(ent.IfNetmask, ospfHelloData.netmask) == false {
 			server.logger.Debug(fmt.Sprintln("HELLO: Netmask mismatch. Int mask", ent.IfNetmask, " Hello mask ", ospfHelloData.netmask, " ip ", ipHdrMd.srcIP))
 			err := errors.New("Netmask mismatch")
@@ -215,6 +227,19 @@ func (server *OSPFServer) processRxHelloPkt(data []byte, ospfHdrMd *OspfHdrMetad
 		}
 	}
 
+	if ent.IfAreaId != nil {
+		areaId := config.AreaId(convertIPInByteToString(ent.IfAreaId))
+		isNssa := server.isNssaArea(areaId)
+		if isNssa != ((ospfHelloData.options & NPOption) != 0) {
+			err := errors.New("NSSA capability mismatch")
+			return err
+		}
+		if isNssa && (ospfHelloData.options&EOption) != 0 {
+			err := errors.New("External Routing Capability mismatch")
+			return err
+		}
+	}
+
 	//Todo: Find whether one way or two way
 	TwoWayStatus := false
 	/*
@@ -349,10 +374,11 @@ func (server *OSPFServer) CreateAndSendHelloRecvdMsg(routerId uint32,
 	if ifType == config.Broadcast ||
 		ifType == config.Nbma ||
 		ifType == config.PointToMultipoint ||
-		ifType == config.NumberedP2P {
+		ifType == config.NumberedP2P ||
+		ifType == config.VirtualLink {
 		msg.NeighborIP = net.IPv4(ipHdrMd.srcIP[0], ipHdrMd.srcIP[1], ipHdrMd.srcIP[2], ipHdrMd.srcIP[3])
 		//copy(msg.NeighborIP, ipHdrMd.srcIP)
-	} else { //Check for Virtual Links and unnumbered p2p
+	} else { //Check for unnumbered p2p
 		msg.NeighborIP = net.IPv4(ospfHdrMd.routerId[0], ospfHdrMd.routerId[1], ospfHdrMd.routerId[2], ospfHdrMd.routerId[3])
 		//copy(msg.NeighborIP, ospfHdrMd.routerId)
 	}
@@ -134,6 +134,7 @@ func (server *OSPFServer) BuildOspfInfra() {
 	server.constructPortInfra()
 	server.constructVlanInfra()
 	server.constructL3Infra()
+	server.constructIPv6Infra()
 }
 
 func (server *OSPFServer) constructPortInfra() {
@@ -216,6 +217,36 @@ func (server *OSPFServer) constructL3Infra() {
 	}
 }
 
+func (server *OSPFServer) constructIPv6Infra() {
+	curMark := 0
+	server.logger.Info("Calling Asicd for getting IPv6 Interfaces")
+	count := 100
+	for {
+		if server.asicdClient.ClientHdl == nil {
+			server.logger.Err("Infra: Null asicd client handle")
+			return
+		}
+		bulkInfo, _ := server.asicdClient.ClientHdl.GetBulkIPv6IntfState(asicdServices.Int(curMark), asicdServices.Int(count))
+		if bulkInfo == nil {
+			break
+		}
+
+		objCnt := int(bulkInfo.Count)
+		more := bool(bulkInfo.More)
+		curMark = int(bulkInfo.EndIdx)
+		for i := 0; i < objCnt; i++ {
+			msg := V3IntfNotifyMsg{
+				IfIndex: bulkInfo.IPv6IntfStateList[i].IfIndex,
+				IpAddr:  bulkInfo.IPv6IntfStateList[i].IpAddr,
+			}
+			server.updateIPv6IntfAddrMap(msg)
+		}
+		if more == false {
+			break
+		}
+	}
+}
+
 func (server *OSPFServer) getBulkPortState() {
 	currMarker := asicdServices.Int(asicdCommonDefs.MIN_SYS_PORTS)
 	if server.asicdClient.IsConnected {
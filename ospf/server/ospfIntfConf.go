@@ -93,6 +93,7 @@ type IntfConf struct {
 	IfMulticastForwarding config.MulticastForwarding
 	IfDemand              bool
 	IfAuthType            uint16
+	IfCryptoAuth          *IntfCryptoAuth
 	FSMCtrlCh             chan bool
 	FSMCtrlStatusCh       chan bool
 	HelloIntervalTicker   *time.Ticker
@@ -120,6 +121,15 @@ type IntfConf struct {
 	IfMtu          int32
 	IfCost         uint32
 	IfMetricTOSMap map[uint8]uint32 // Key: TOS Value, Value: TOS Metric
+	/* RFC 3630 TE link parameters, bandwidth in Mbps */
+	IfTEMetric        uint32
+	IfMaxBandwidth    uint32
+	IfMaxRsvBandwidth uint32
+	IfAdminGroup      uint32
+	/* Virtual link, packets are unicast to the virtual neighbor
+	   through the next hop of the transit area */
+	IfVirtNbrIpAddr  net.IP
+	IfVirtNextHopMac net.HardwareAddr
 }
 
 func (server *OSPFServer) initDefaultIntfConf(key IntfConfKey, ipIntfProp IPIntfProperty, ifType int) {
@@ -168,6 +178,7 @@ func (server *OSPFServer) initDefaultIntfConf(key IntfConfKey, ipIntfProp IPIntf
 		ent.IfMulticastForwarding = config.Blocked
 		ent.IfDemand = false
 		ent.IfAuthType = uint16(config.NoAuth)
+		ent.IfCryptoAuth = NewIntfCryptoAuth()
 		ent.FSMCtrlCh = make(chan bool)
 		ent.FSMCtrlStatusCh = make(chan bool)
 		ent.BackupSeenCh = make(chan BackupSeenMsg)
@@ -400,6 +411,14 @@ func (server *OSPFServer) updateIPIntfConfMap(ifConf config.InterfaceConf) {
 		//}
 		//ent.IfAuthKey = authKey
 		ent.IfAuthType = uint16(ifConf.IfAuthType)
+		if ent.IfCryptoAuth == nil {
+			ent.IfCryptoAuth = NewIntfCryptoAuth()
+		}
+		ent.IfCryptoAuth.setKeyChain(ifConf.IfAuthKeyChain)
+		ent.IfTEMetric = uint32(ifConf.IfTEMetric)
+		ent.IfMaxBandwidth = uint32(ifConf.IfMaxBandwidth)
+		ent.IfMaxRsvBandwidth = uint32(ifConf.IfMaxRsvBandwidth)
+		ent.IfAdminGroup = ifConf.IfAdminGroup
 		/* Re initiate the Interface State */
 		ent.IfDRIp = []byte{0, 0, 0, 0}
 		ent.IfBDRIp = []byte{0, 0, 0, 0}
@@ -458,12 +477,40 @@ func (server *OSPFServer) processIntfConfig(ifConf config.InterfaceConf) error {
 	return nil
 }
 
+/*
+processIntfAuthConfig updates the key chain of the interface without
+restarting it, so that the keys can be rolled over without bringing
+down the adjacencies.
+*/
+func (server *OSPFServer) processIntfAuthConfig(ifConf config.InterfaceConf) error {
+	intfConfKey := IntfConfKey{
+		IPAddr:  ifConf.IfIpAddress,
+		IntfIdx: config.InterfaceIndexOrZero(ifConf.AddressLessIf),
+	}
+	ent, exist := server.IntfConfMap[intfConfKey]
+	if !exist {
+		server.logger.Err(fmt.Sprintln("No such L3 interface exists ", intfConfKey.IPAddr, intfConfKey.IntfIdx))
+		err := errors.New("No such L3 interface exists")
+		return err
+	}
+	if ent.IfCryptoAuth == nil {
+		ent.IfCryptoAuth = NewIntfCryptoAuth()
+		server.IntfConfMap[intfConfKey] = ent
+	}
+	ent.IfCryptoAuth.setKeyChain(ifConf.IfAuthKeyChain)
+	server.logger.Info(fmt.Sprintln("AUTH: Updated key chain of interface", intfConfKey))
+	return nil
+}
+
 func (server *OSPFServer) StopSendRecvPkts(intfConfKey IntfConfKey) {
 	server.logger.Info("Stop Sending Hello Pkt")
 	server.StopOspfIntfFSM(intfConfKey)
-	server.logger.Info("Stop Receiving Hello Pkt")
-	server.StopOspfRecvPkts(intfConfKey)
 	ent, _ := server.IntfConfMap[intfConfKey]
+	/* Packets of the virtual links are received by the transit area interface */
+	if ent.IfType != config.VirtualLink {
+		server.logger.Info("Stop Receiving Hello Pkt")
+		server.StopOspfRecvPkts(intfConfKey)
+	}
 	ent.NeighborMap = nil
 	ent.IfEvents = ent.IfEvents + 1
 	ent.IfFSMState = config.Down
@@ -483,14 +530,17 @@ func (server *OSPFServer) StartSendRecvPkts(intfConfKey IntfConfKey) {
 	ent.IfEvents = ent.IfEvents + 1
 	if ent.IfType == config.Broadcast {
 		ent.IfFSMState = config.Waiting
-	} else if ent.IfType == config.NumberedP2P || ent.IfType == config.UnnumberedP2P {
+	} else if ent.IfType == config.NumberedP2P || ent.IfType == config.UnnumberedP2P ||
+		ent.IfType == config.VirtualLink {
 		ent.IfFSMState = config.P2P
 	}
 	server.IntfConfMap[intfConfKey] = ent
 	server.logger.Info("Start Sending Hello Pkt")
 	go server.StartOspfIntfFSM(intfConfKey)
-	server.logger.Info("Start Receiving Hello Pkt")
-	go server.StartOspfRecvPkts(intfConfKey)
+	if ent.IfType != config.VirtualLink {
+		server.logger.Info("Start Receiving Hello Pkt")
+		go server.StartOspfRecvPkts(intfConfKey)
+	}
 }
 
 func (server *OSPFServer) initIntfStateSlice() {
@@ -510,7 +560,10 @@ func (server *OSPFServer) initIntfStateSlice() {
 }
 
 func (server *OSPFServer) refreshIntfKeySlice() {
-	for key, _ := range server.IntfConfMap {
+	for key, ent := range server.IntfConfMap {
+		if ent.IfType == config.VirtualLink {
+			continue
+		}
 		server.IntfKeySlice = append(server.IntfKeySlice, key)
 		server.IntfKeyToSliceIdxMap[key] = true
 	}
@@ -45,7 +45,10 @@ func (server *OSPFServer) StartOspfIntfFSM(key IntfConfKey) {
 	server.logger.Info("Sending msg for router LSA generation")
 	server.IntfStateChangeCh <- msg
 
-	if ent.IfType == config.NumberedP2P || ent.IfType == config.UnnumberedP2P {
+	/* grace LSA has to reach the neighbors before the first hello */
+	server.sendRestartGraceLsa(key)
+	if ent.IfType == config.NumberedP2P || ent.IfType == config.UnnumberedP2P ||
+		ent.IfType == config.VirtualLink {
 		server.StartOspfP2PIntfFSM(key)
 	} else if ent.IfType == config.Broadcast {
 		server.StartOspfBroadcastIntfFSM(key)
@@ -110,6 +113,9 @@ func (server *OSPFServer) StartOspfP2PIntfFSM(key IntfConfKey) {
 			// Only when Neighbor Went Down from TwoWayStatus
 			server.logger.Info(fmt.Sprintf("Recev Neighbor State Change message", nbrStateChangeMsg))
 			server.processNbrDownEvent(nbrStateChangeMsg, key, true)
+			if ent.IfType == config.VirtualLink {
+				notifyConfChange(server.virtLinkLsaCh)
+			}
 		case state := <-ent.FSMCtrlCh:
 			if state == false {
 				server.StopSendHelloPkt(key)
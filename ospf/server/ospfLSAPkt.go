@@ -188,15 +188,19 @@ func (server *OSPFServer) EncodeLSAReqPkt(intfKey IntfConfKey, ent IntfConf,
 
 	ospf := append(ospfEncHdr, lsaDataEnc...)
 	server.logger.Info(fmt.Sprintln("OSPF LSA REQ:", ospf))
-	csum := computeCheckSum(ospf)
-	binary.BigEndian.PutUint16(ospf[12:14], csum)
-	copy(ospf[16:24], ent.IfAuthKey)
+	ospf = server.encodeOspfAuth(ent, ospf)
+	if ospf == nil {
+		return nil
+	}
 
-	ipPktlen := IP_HEADER_MIN_LEN + ospfHdr.pktlen
+	ipPktlen := IP_HEADER_MIN_LEN + len(ospf)
 	var dstIp net.IP
 	if ent.IfType == config.NumberedP2P {
 		dstIp = net.ParseIP(config.AllSPFRouters)
 		dstMAC, _ = net.ParseMAC(config.McastMAC)
+	} else if ent.IfType == config.VirtualLink {
+		dstIp = ent.IfVirtNbrIpAddr
+		dstMAC = ent.IfVirtNextHopMac
 	} else {
 		dstIp = nbrConf.OspfNbrIPAddr
 	}
@@ -205,7 +209,7 @@ func (server *OSPFServer) EncodeLSAReqPkt(intfKey IntfConfKey, ent IntfConf,
 		IHL:      uint8(IP_HEADER_MIN_LEN),
 		TOS:      uint8(0xc0),
 		Length:   uint16(ipPktlen),
-		TTL:      uint8(1),
+		TTL:      getOspfPktTTL(ent),
 		Protocol: layers.IPProtocol(OSPF_PROTO_ID),
 		SrcIP:    ent.IfIpAddr,
 		DstIP:    dstIp,
@@ -340,22 +344,26 @@ func (server *OSPFServer) BuildLsaUpdPkt(intfKey IntfConfKey, ent IntfConf,
 
 	ospf := append(ospfEncHdr, lsaUpdEnc...)
 	//server.logger.Info(fmt.Sprintln("OSPF LSA UPD:", ospf))
-	csum := computeCheckSum(ospf)
-	binary.BigEndian.PutUint16(ospf[12:14], csum)
-	copy(ospf[16:24], ent.IfAuthKey)
+	ospf = server.encodeOspfAuth(ent, ospf)
+	if ospf == nil {
+		return nil
+	}
 
 	if ent.IfType == config.NumberedP2P {
 		dstIp = net.ParseIP(config.AllSPFRouters)
 		dstMAC, _ = net.ParseMAC(config.McastMAC)
+	} else if ent.IfType == config.VirtualLink {
+		dstIp = ent.IfVirtNbrIpAddr
+		dstMAC = ent.IfVirtNextHopMac
 	}
 
-	ipPktlen := IP_HEADER_MIN_LEN + ospfHdr.pktlen
+	ipPktlen := IP_HEADER_MIN_LEN + len(ospf)
 	ipLayer := layers.IPv4{
 		Version:  uint8(4),
 		IHL:      uint8(IP_HEADER_MIN_LEN),
 		TOS:      uint8(0xc0),
 		Length:   uint16(ipPktlen),
-		TTL:      uint8(1),
+		TTL:      getOspfPktTTL(ent),
 		Protocol: layers.IPProtocol(OSPF_PROTO_ID),
 		SrcIP:    ent.IfIpAddr,
 		DstIP:    dstIp,
@@ -457,6 +465,7 @@ func (server *OSPFServer) DecodeLSAUpd(msg ospfNeighborLSAUpdMsg) {
 		/* send message to lsdb */
 		lsdb_msg := NewLsdbUpdateMsg()
 		lsdb_msg.AreaId = msg.areaId
+		lsdb_msg.IntfKey = nbr.intfConfKey
 		lsdb_msg.Data = make([]byte, end_index-i)
 		copy(lsdb_msg.Data, msg.data[index:end_index])
 		valid := validateChecksum(lsdb_msg.Data)
@@ -495,6 +504,18 @@ func (server *OSPFServer) DecodeLSAUpd(msg ospfNeighborLSAUpdMsg) {
 			dalsa, ret := server.getASExternalLsaFromLsdb(msg.areaId, *lsa_key)
 			discard, op = server.sanityCheckASExternalLsa(*alsa, dalsa, nbr, intf, intf.IfAreaId, ret, lsa_max_age)
 
+		case NSSALSA:
+			nlsa := NewASExternalLsa()
+			decodeASExternalLsa(lsdb_msg.Data, nlsa, lsa_key)
+			dnlsa, ret := server.getNssaLsaFromLsdb(msg.areaId, *lsa_key)
+			discard, op = server.sanityCheckNssaLsa(*nlsa, dnlsa, nbr, intf, intf.IfAreaId, ret, lsa_max_age)
+
+		case LocalOpaqueLSA, AreaOpaqueLSA, ASOpaqueLSA:
+			olsa := NewOpaqueLsa()
+			decodeOpaqueLsa(lsdb_msg.Data, olsa, lsa_key)
+			dolsa, ret := server.getOpaqueLsaFromLsdb(msg.areaId, nbr.intfConfKey, *lsa_key)
+			discard, op = server.sanityCheckOpaqueLsa(*lsa_key, *olsa, dolsa, nbr, intf, ret, lsa_max_age)
+
 		}
 		lsid := convertUint32ToIPv4(lsa_header.LinkId)
 		router_id := convertUint32ToIPv4(lsa_header.Adv_router)
@@ -506,6 +527,13 @@ func (server *OSPFServer) DecodeLSAUpd(msg ospfNeighborLSAUpdMsg) {
 
 		}
 
+		if !discard && !self_gen && op == FloodLsa &&
+			server.lsaArrivalThrottled(msg.areaId, *lsa_key, lsa_max_age) {
+			server.logger.Info(fmt.Sprintln("LSAUPD: discard. Received within MinLSArrival ", lsa_key))
+			index = end_index
+			continue
+		}
+
 		if !discard && !self_gen && op == FloodLsa {
 			server.logger.Info(fmt.Sprintln("LSAUPD: add to lsdb lsid ", lsid, " router_id ", router_id, " lstype ", lsa_header.LSType))
 			lsdb_msg.MsgType = LsdbAdd
@@ -522,7 +550,8 @@ func (server *OSPFServer) DecodeLSAUpd(msg ospfNeighborLSAUpdMsg) {
 		}
 		flood_pkt.pkt = make([]byte, end_index-index)
 		copy(flood_pkt.pkt, lsdb_msg.Data)
-		if lsop != LSASUMMARYFLOOD && !self_gen { // for ABR summary lsa is flooded after LSDB/SPF changes are done.
+		// link-local opaque LSA is not flooded beyond the receiving link
+		if lsop != LSASUMMARYFLOOD && !self_gen && lsa_header.LSType != LocalOpaqueLSA { // for ABR summary lsa is flooded after LSDB/SPF changes are done.
 			server.ospfNbrLsaUpdSendCh <- flood_pkt
 		}
 
@@ -593,7 +622,7 @@ func (server *OSPFServer) sanityCheckRouterLsa(rlsa RouterLsa, drlsa RouterLsa,
 		return discard, op
 	} else {
 		isNew := server.validateLsaIsNew(rlsa.LsaMd, drlsa.LsaMd)
-		// TODO check if lsa is installed before MinLSArrival
+		// MinLSArrival is checked by lsaArrivalThrottled
 		if isNew {
 			op = FloodLsa
 			discard = false
@@ -665,7 +694,11 @@ func (server *OSPFServer) sanityCheckSummaryLsa(slsa SummaryLsa, dslsa SummaryLs
 func (server *OSPFServer) sanityCheckASExternalLsa(alsa ASExternalLsa, dalsa ASExternalLsa, nbr OspfNeighborEntry, intf IntfConf, areaid []byte, exist int, lsa_max_age bool) (discard bool, op uint8) {
 	discard = false
 	op = LsdbAdd
-	// TODO Reject this lsa if area is configured as stub area.
+	areaId := config.AreaId(convertIPInByteToString(areaid))
+	if server.isStubArea(areaId) || server.isNssaArea(areaId) {
+		server.logger.Info(fmt.Sprintln("LSAUPD: As external LSA Discard. Area is stub/NSSA ", areaId))
+		return true, LsdbNoAction
+	}
 	send_ack := server.lsAgeCheck(nbr.intfConfKey, lsa_max_age, exist)
 	if send_ack {
 		op = LsdbNoAction
@@ -685,6 +718,66 @@ func (server *OSPFServer) sanityCheckASExternalLsa(alsa ASExternalLsa, dalsa ASE
 	return discard, op
 }
 
+func (server *OSPFServer) sanityCheckNssaLsa(nlsa ASExternalLsa, dnlsa ASExternalLsa, nbr OspfNeighborEntry, intf IntfConf, areaid []byte, exist int, lsa_max_age bool) (discard bool, op uint8) {
+	discard = false
+	op = LsdbAdd
+	areaId := config.AreaId(convertIPInByteToString(areaid))
+	if !server.isNssaArea(areaId) {
+		server.logger.Info(fmt.Sprintln("LSAUPD: NSSA LSA Discard. Area is not NSSA ", areaId))
+		return true, LsdbNoAction
+	}
+	send_ack := server.lsAgeCheck(nbr.intfConfKey, lsa_max_age, exist)
+	if send_ack {
+		op = LsdbNoAction
+		discard = true
+		server.logger.Info(fmt.Sprintln("LSAUPD: NSSA LSA Discard.", " nbr ", nbr))
+		return discard, op
+	} else {
+		isNew := server.validateLsaIsNew(nlsa.LsaMd, dnlsa.LsaMd)
+		if isNew {
+			op = FloodLsa
+			discard = false
+		} else {
+			discard = true
+			op = LsdbNoAction
+		}
+	}
+	return discard, op
+}
+
+func (server *OSPFServer) sanityCheckOpaqueLsa(lsaKey LsaKey, olsa OpaqueLsa, dolsa OpaqueLsa, nbr OspfNeighborEntry, intf IntfConf, exist int, lsa_max_age bool) (discard bool, op uint8) {
+	discard = false
+	op = LsdbAdd
+	if !server.ospfGlobalConf.OpaqueLsaSupport {
+		server.logger.Info(fmt.Sprintln("LSAUPD: Opaque LSA Discard. Opaque LSA support is disabled ", lsaKey))
+		return true, LsdbNoAction
+	}
+	if lsaKey.LSType == ASOpaqueLSA {
+		areaId := config.AreaId(convertIPInByteToString(intf.IfAreaId))
+		if server.isStubArea(areaId) || server.isNssaArea(areaId) {
+			server.logger.Info(fmt.Sprintln("LSAUPD: AS opaque LSA Discard. Area is stub/NSSA ", areaId))
+			return true, LsdbNoAction
+		}
+	}
+	send_ack := server.lsAgeCheck(nbr.intfConfKey, lsa_max_age, exist)
+	if send_ack {
+		op = LsdbNoAction
+		discard = true
+		server.logger.Info(fmt.Sprintln("LSAUPD: Opaque LSA Discard.", " nbr ", nbr))
+		return discard, op
+	} else {
+		isNew := server.validateLsaIsNew(olsa.LsaMd, dolsa.LsaMd)
+		if isNew {
+			op = FloodLsa
+			discard = false
+		} else {
+			discard = true
+			op = LsdbNoAction
+		}
+	}
+	return discard, op
+}
+
 func validateChecksum(data []byte) bool {
 
 	csum := computeFletcherChecksum(data[2:], FLETCHER_CHECKSUM_VALIDATE)
@@ -770,21 +863,25 @@ func (server *OSPFServer) BuildLSAAckPkt(intfKey IntfConfKey, ent IntfConf,
 
 	ospf := append(ospfEncHdr, lsaAckEnc...)
 	//server.logger.Info(fmt.Sprintln("OSPF LSA ACK:", ospf))
-	csum := computeCheckSum(ospf)
-	binary.BigEndian.PutUint16(ospf[12:14], csum)
-	copy(ospf[16:24], ent.IfAuthKey)
+	ospf = server.encodeOspfAuth(ent, ospf)
+	if ospf == nil {
+		return nil
+	}
 
-	ipPktlen := IP_HEADER_MIN_LEN + ospfHdr.pktlen
+	ipPktlen := IP_HEADER_MIN_LEN + len(ospf)
 	if ent.IfType == config.NumberedP2P {
 		dstIp = net.ParseIP(config.AllSPFRouters)
 		dstMAC, _ = net.ParseMAC(config.McastMAC)
+	} else if ent.IfType == config.VirtualLink {
+		dstIp = ent.IfVirtNbrIpAddr
+		dstMAC = ent.IfVirtNextHopMac
 	}
 	ipLayer := layers.IPv4{
 		Version:  uint8(4),
 		IHL:      uint8(IP_HEADER_MIN_LEN),
 		TOS:      uint8(0xc0),
 		Length:   uint16(ipPktlen),
-		TTL:      uint8(1),
+		TTL:      getOspfPktTTL(ent),
 		Protocol: layers.IPProtocol(OSPF_PROTO_ID),
 		SrcIP:    ent.IfIpAddr,
 		DstIP:    dstIp,
@@ -999,6 +1096,24 @@ func (server *OSPFServer) generateLsaUpdUnicast(req ospfLSAReq, nbrKey NeighborC
 			server.logger.Info(fmt.Sprintln("LSAREQ: AS external lsa not fount. lsaid ",
 				req.link_state_id, " lstype ", lsa_key.LSType, " adv_router ", lsa_key.AdvRouter, " areaid ", areaid))
 		}
+	case NSSALSA:
+		dnlsa, ret := server.getNssaLsaFromLsdb(areaid, *lsa_key)
+		if ret == LsdbEntryFound {
+			lsa_pkt = encodeASExternalLsa(dnlsa, *lsa_key)
+			flood = true
+		} else {
+			server.logger.Info(fmt.Sprintln("LSAREQ: NSSA lsa not found. lsaid ",
+				req.link_state_id, " lstype ", lsa_key.LSType, " adv_router ", lsa_key.AdvRouter, " areaid ", areaid))
+		}
+	case LocalOpaqueLSA, AreaOpaqueLSA, ASOpaqueLSA:
+		dolsa, ret := server.getOpaqueLsaFromLsdb(areaid, nbrConf.intfConfKey, *lsa_key)
+		if ret == LsdbEntryFound {
+			lsa_pkt = encodeOpaqueLsa(dolsa, *lsa_key)
+			flood = true
+		} else {
+			server.logger.Info(fmt.Sprintln("LSAREQ: Opaque lsa not found. lsaid ",
+				req.link_state_id, " lstype ", lsa_key.LSType, " adv_router ", lsa_key.AdvRouter, " areaid ", areaid))
+		}
 	}
 	lsid := convertUint32ToIPv4(req.link_state_id)
 	router_id := convertUint32ToIPv4(req.adv_router_id)
@@ -1096,6 +1211,16 @@ func (server *OSPFServer) lsaAddCheck(lsaheader ospfLSAHeader,
 		dalsa, ret := server.getASExternalLsaFromLsdb(areaId, *lsa_key)
 		discard, op = server.sanityCheckASExternalLsa(*alsa, dalsa, nbr, intf, intf.IfAreaId, ret, lsa_max_age)
 
+	case NSSALSA:
+		nlsa := NewASExternalLsa()
+		dnlsa, ret := server.getNssaLsaFromLsdb(areaId, *lsa_key)
+		discard, op = server.sanityCheckNssaLsa(*nlsa, dnlsa, nbr, intf, intf.IfAreaId, ret, lsa_max_age)
+
+	case LocalOpaqueLSA, AreaOpaqueLSA, ASOpaqueLSA:
+		olsa := NewOpaqueLsa()
+		dolsa, ret := server.getOpaqueLsaFromLsdb(areaId, nbr.intfConfKey, *lsa_key)
+		discard, op = server.sanityCheckOpaqueLsa(*lsa_key, *olsa, dolsa, nbr, intf, ret, lsa_max_age)
+
 	}
 	if discard {
 		server.logger.Info(fmt.Sprintln("DBD: LSA is not added in the request list. Adv router ", adv_router,
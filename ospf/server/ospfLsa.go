@@ -30,11 +30,15 @@ import (
 )
 
 const (
-	RouterLSA     uint8 = 1
-	NetworkLSA    uint8 = 2
-	Summary3LSA   uint8 = 3
-	Summary4LSA   uint8 = 4
-	ASExternalLSA uint8 = 5
+	RouterLSA      uint8 = 1
+	NetworkLSA     uint8 = 2
+	Summary3LSA    uint8 = 3
+	Summary4LSA    uint8 = 4
+	ASExternalLSA  uint8 = 5
+	NSSALSA        uint8 = 7
+	LocalOpaqueLSA uint8 = 9
+	AreaOpaqueLSA  uint8 = 10
+	ASOpaqueLSA    uint8 = 11
 )
 
 type LsaKey struct {
@@ -87,6 +91,7 @@ func NewLsaHeader() *LsaHeader {
 /* LS Type 1 */
 type RouterLsa struct {
 	LsaMd       LsaMetadata
+	BitNt       bool         /* Nt Bit */
 	BitV        bool         /* V Bit */
 	BitE        bool         /* Bit E */
 	BitB        bool         /* Bit B */
@@ -149,14 +154,29 @@ func NewASExternalLsa() *ASExternalLsa {
 	return &ASExternalLsa{}
 }
 
+/* LS Type 9, 10 or 11 */
+type OpaqueLsa struct {
+	LsaMd LsaMetadata
+	Info  []byte /* Opaque Information */
+}
+
+func NewOpaqueLsa() *OpaqueLsa {
+	return &OpaqueLsa{}
+}
+
 type LSDatabase struct {
 	RouterLsaMap     map[LsaKey]RouterLsa
 	NetworkLsaMap    map[LsaKey]NetworkLsa
 	Summary3LsaMap   map[LsaKey]SummaryLsa
 	Summary4LsaMap   map[LsaKey]SummaryLsa
 	ASExternalLsaMap map[LsaKey]ASExternalLsa
+	NssaLsaMap       map[LsaKey]ASExternalLsa
+	OpaqueLsaMap     map[LsaKey]OpaqueLsa
 }
 
+/* Link-local opaque LSAs of an interface */
+type OpaqueLsdb map[LsaKey]OpaqueLsa
+
 type maxAgeLsaMsg struct {
 	lsaKey   LsaKey
 	msg_type uint8
@@ -260,6 +280,11 @@ func decodeRouterLsa(data []byte, lsa *RouterLsa, lsakey *LsaKey) {
 	lsa.LsaMd.LSSequenceNum = int(binary.BigEndian.Uint32(data[12:16]))
 	lsa.LsaMd.LSChecksum = binary.BigEndian.Uint16(data[16:18])
 	lsa.LsaMd.LSLen = binary.BigEndian.Uint16(data[18:20])
+	if data[20]&0x10 != 0 {
+		lsa.BitNt = true
+	} else {
+		lsa.BitNt = false
+	}
 	if data[20]&0x04 != 0 {
 		lsa.BitV = true
 	} else {
@@ -332,6 +357,9 @@ func encodeRouterLsa(lsa RouterLsa, lsakey LsaKey) []byte {
 	lsaHdr := encodeLsaHeader(lsa.LsaMd, lsakey)
 	copy(rtrLsa[0:20], lsaHdr)
 	var val uint8 = 0
+	if lsa.BitNt == true {
+		val = val | 1<<4
+	}
 	if lsa.BitV == true {
 		val = val | 1<<2
 	}
@@ -600,6 +628,55 @@ func decodeASExternalLsa(data []byte, lsa *ASExternalLsa, lsakey *LsaKey) {
 	}
 }
 
+/*
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |            LS age             |     Options   |   9, 10, or 11 |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |  Opaque Type  |               Opaque ID                       |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                      Advertising Router                       |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                      LS Sequence Number                       |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |         LS checksum           |           Length              |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                                                               |
+   +                                                               +
+   |                      Opaque Information                       |
+   +                                                               +
+   |                              ...                              |
+*/
+
+func encodeOpaqueLsa(lsa OpaqueLsa, lsakey LsaKey) []byte {
+	oLsa := make([]byte, lsa.LsaMd.LSLen)
+	lsaHdr := encodeLsaHeader(lsa.LsaMd, lsakey)
+	copy(oLsa[0:20], lsaHdr)
+	copy(oLsa[20:], lsa.Info)
+	return oLsa
+}
+
+func decodeOpaqueLsa(data []byte, lsa *OpaqueLsa, lsakey *LsaKey) {
+	lsa.LsaMd.LSAge = binary.BigEndian.Uint16(data[0:2])
+	lsa.LsaMd.Options = uint8(data[2])
+	lsakey.LSType = uint8(data[3])
+	lsakey.LSId = binary.BigEndian.Uint32(data[4:8])
+	lsakey.AdvRouter = binary.BigEndian.Uint32(data[8:12])
+	lsa.LsaMd.LSSequenceNum = int(binary.BigEndian.Uint32(data[12:16]))
+	lsa.LsaMd.LSChecksum = binary.BigEndian.Uint16(data[16:18])
+	lsa.LsaMd.LSLen = binary.BigEndian.Uint16(data[18:20])
+	end := int(lsa.LsaMd.LSLen)
+	if end > len(data) {
+		end = len(data)
+	}
+	if end < OSPF_LSA_HEADER_SIZE {
+		end = OSPF_LSA_HEADER_SIZE
+	}
+	lsa.Info = make([]byte, end-OSPF_LSA_HEADER_SIZE)
+	copy(lsa.Info, data[OSPF_LSA_HEADER_SIZE:end])
+}
+
 func (server *OSPFServer) getRouterLsaFromLsdb(areaId uint32, lsaKey LsaKey) (lsa RouterLsa, retVal int) {
 	//server.logger.Info(fmt.Sprintln("1. LS DB:", server.AreaLsdb))
 	//server.logger.Info(fmt.Sprintln("1. areaId:", areaId, "lsaKey:", lsaKey))
@@ -668,6 +745,18 @@ func (server *OSPFServer) getASExternalLsaFromLsdb(areaId uint32, lsaKey LsaKey)
 	return lsa, LsdbEntryFound
 }
 
+func (server *OSPFServer) getNssaLsaFromLsdb(areaId uint32, lsaKey LsaKey) (lsa ASExternalLsa, retVal int) {
+	lsdbKey := LsdbKey{
+		AreaId: areaId,
+	}
+	lsDbEnt, _ := server.AreaLsdb[lsdbKey]
+	lsa, exist := lsDbEnt.NssaLsaMap[lsaKey]
+	if !exist {
+		return lsa, LsdbEntryNotFound
+	}
+	return lsa, LsdbEntryFound
+}
+
 func (server *OSPFServer) processMaxAgeLSA(lsdbKey LsdbKey, lsdbEnt LSDatabase) {
 	flood_lsa := false
 	/* Router LSA */
@@ -727,6 +816,45 @@ func (server *OSPFServer) processMaxAgeLSA(lsdbKey LsdbKey, lsdbEnt LSDatabase)
 			lsdbEnt.ASExternalLsaMap[lsakey] = lsa_ex
 		}
 	}
+	/* NSSA LSA */
+	for lsakey, lsa_nssa := range lsdbEnt.NssaLsaMap {
+		if lsa_nssa.LsaMd.LSAge == config.MaxAge {
+			// add to flood list
+			lsa_pkt := encodeASExternalLsa(lsa_nssa, lsakey)
+			maxAgeLsaMap[lsakey] = lsa_pkt
+			// delete LSA
+			delete(lsdbEnt.NssaLsaMap, lsakey)
+			advRouter := convertUint32ToIPv4(lsakey.AdvRouter)
+			lsid := convertUint32ToIPv4(lsakey.LSId)
+			server.logger.Info(fmt.Sprintln("DELETE: Max age reached. adv_router ",
+				advRouter, " lstype ", lsakey.LSType, " lsid ", lsid))
+			flood_lsa = true
+
+		} else {
+			lsa_nssa.LsaMd.LSAge++
+			lsdbEnt.NssaLsaMap[lsakey] = lsa_nssa
+		}
+	}
+	/* Opaque LSA */
+	for lsakey, lsa_op := range lsdbEnt.OpaqueLsaMap {
+		if lsa_op.LsaMd.LSAge == config.MaxAge {
+			// add to flood list
+			lsa_pkt := encodeOpaqueLsa(lsa_op, lsakey)
+			maxAgeLsaMap[lsakey] = lsa_pkt
+			// delete LSA
+			delete(lsdbEnt.OpaqueLsaMap, lsakey)
+			advRouter := convertUint32ToIPv4(lsakey.AdvRouter)
+			lsid := convertUint32ToIPv4(lsakey.LSId)
+			server.logger.Info(fmt.Sprintln("DELETE: Max age reached. adv_router ",
+				advRouter, " lstype ", lsakey.LSType, " lsid ", lsid))
+			server.notifyOpaqueApp(lsdbKey.AreaId, IntfConfKey{}, lsakey, lsa_op.Info, true)
+			flood_lsa = true
+
+		} else {
+			lsa_op.LsaMd.LSAge++
+			lsdbEnt.OpaqueLsaMap[lsakey] = lsa_op
+		}
+	}
 	/* Summary 3 */
 	for lsakey, lsa_sum := range lsdbEnt.Summary3LsaMap {
 		if lsa_sum.LsaMd.LSAge == config.MaxAge {
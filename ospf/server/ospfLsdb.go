@@ -33,6 +33,7 @@ import (
 type LsdbUpdateMsg struct {
 	MsgType uint8
 	AreaId  uint32
+	IntfKey IntfConfKey // receiving interface of link-local opaque LSA
 	Data    []byte
 }
 
@@ -85,6 +86,8 @@ func (server *OSPFServer) initLSDatabase(areaId uint32) {
 		lsDbEnt.Summary3LsaMap = make(map[LsaKey]SummaryLsa)
 		lsDbEnt.Summary4LsaMap = make(map[LsaKey]SummaryLsa)
 		lsDbEnt.ASExternalLsaMap = make(map[LsaKey]ASExternalLsa)
+		lsDbEnt.NssaLsaMap = make(map[LsaKey]ASExternalLsa)
+		lsDbEnt.OpaqueLsaMap = make(map[LsaKey]OpaqueLsa)
 		server.AreaLsdb[lsdbKey] = lsDbEnt
 	}
 	selfOrigLsaEnt, exist := server.AreaSelfOrigLsa[lsdbKey]
@@ -107,6 +110,7 @@ func (server *OSPFServer) StartLSDatabase() {
 	// start LSDB aging ticker
 	lsdbTickerCh = time.NewTimer(time.Second * 1)
 	lsdbRefreshTickerCh = time.NewTimer(time.Second * time.Duration(config.LSRefreshTime))
+	server.initThrottleTimers()
 	go server.processLSDatabaseUpdates()
 	return
 }
@@ -114,6 +118,7 @@ func (server *OSPFServer) StartLSDatabase() {
 func (server *OSPFServer) StopLSDatabase() {
 	lsdbTickerCh.Stop()
 	lsdbRefreshTickerCh.Stop()
+	server.stopThrottleTimers()
 }
 
 func (server *OSPFServer) compareSummaryLsa(lsdbKey LsdbKey, lsaKey LsaKey, lsaEnt SummaryLsa) bool {
@@ -224,6 +229,12 @@ func (server *OSPFServer) flushSummaryLsa(lsdbKey LsdbKey, lsaKey LsaKey) {
 func (server *OSPFServer) installSummaryLsa() {
 	ifkey := IntfConfKey{}
 	nbr := NeighborConfKey{}
+	if server.isGRRestarting() {
+		/* RFC 3623 2.2 Summary and NSSA LSAs are originated
+		when the graceful restart ends */
+		server.SummaryLsDb = nil
+		return
+	}
 	server.logger.Info("Installing summary Lsa...")
 	for lsdbKey, sLsa := range server.SummaryLsDb {
 		selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
@@ -265,6 +276,8 @@ func (server *OSPFServer) installSummaryLsa() {
 		oldSelfOrigSummaryLsa = nil
 	}
 	server.SummaryLsDb = nil
+	server.generateNssaDefaultLsa()
+	server.translateNssaLsa()
 }
 
 func (server *OSPFServer) flushNetworkLSA(areaId uint32, key IntfConfKey) {
@@ -311,6 +324,10 @@ func (server *OSPFServer) flushNetworkLSA(areaId uint32, key IntfConfKey) {
 }
 
 func (server *OSPFServer) generateNetworkLSA(areaId uint32, key IntfConfKey, isDR bool) {
+	if server.isGRRestarting() {
+		server.logger.Info(fmt.Sprintln("GR: Graceful restart in progress. Network LSA is not generated ", key))
+		return
+	}
 
 	//routerId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
 	ent := server.IntfConfMap[key]
@@ -453,6 +470,10 @@ func (server *OSPFServer) constructStubLinkP2P(ent IntfConf, likType config.IfTy
 
 func (server *OSPFServer) generateRouterLSA(areaId uint32) {
 	var linkDetails []LinkDetail = nil
+	if server.isGRRestarting() {
+		server.logger.Info(fmt.Sprintln("GR: Graceful restart in progress. Router LSA is not generated ", areaId))
+		return
+	}
 	for key, ent := range server.IntfConfMap {
 		AreaId := convertIPv4ToUint32(ent.IfAreaId)
 		if areaId != AreaId {
@@ -553,6 +574,20 @@ func (server *OSPFServer) generateRouterLSA(areaId uint32) {
 			linkDetail.LinkType = P2PLink
 			linkDetail.NumOfTOS = 0
 			linkDetail.LinkMetric = uint16(ent.IfCost)
+
+		case config.VirtualLink:
+			/* Virtual links are added only when the virtual neighbor
+			   is fully adjacent. The cost is the intra area cost of
+			   the path through the transit area. */
+			nbr, full := server.getFullVirtNbr(ent)
+			if !full {
+				continue
+			}
+			linkDetail.LinkId = nbr.OspfNbrRtrId
+			linkDetail.LinkData = convertAreaOrRouterIdUint32(ent.IfIpAddr.String())
+			linkDetail.LinkType = VirtualLink
+			linkDetail.NumOfTOS = 0
+			linkDetail.LinkMetric = uint16(ent.IfCost)
 		}
 		linkDetails = append(linkDetails, linkDetail)
 	}
@@ -566,9 +601,23 @@ func (server *OSPFServer) generateRouterLSA(areaId uint32) {
 	AdvRouter := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
 	BitE := false //not an AS boundary router (Todo)
 	BitB := false
+	BitNt := false
+	BitV := server.isVirtLinkTransitArea(areaId)
 	if server.ospfGlobalConf.AreaBdrRtrStatus == true {
 		BitB = true
 	}
+	areaConfKey := AreaConfKey{
+		AreaId: config.AreaId(convertUint32ToIPv4(areaId)),
+	}
+	if server.isNssaArea(areaConfKey.AreaId) {
+		/* RFC 3101 2.4: NSSA ASBRs set the E-bit so that their
+		   Type-7 LSAs can be resolved, unconditional translators
+		   set the Nt-bit */
+		BitE = server.ospfGlobalConf.ASBdrRtrStatus
+		if BitB && server.AreaConfMap[areaConfKey].AreaNssaTranslatorRole == config.Always {
+			BitNt = true
+		}
+	}
 	lsaKey := LsaKey{
 		LSType:    LSType,
 		LSId:      LSId,
@@ -606,6 +655,8 @@ func (server *OSPFServer) generateRouterLSA(areaId uint32) {
 	ent.LsaMd.LSLen = uint16(OSPF_LSA_HEADER_SIZE + 4 + (12 * numOfLinks))
 	ent.BitE = BitE
 	ent.BitB = BitB
+	ent.BitNt = BitNt
+	ent.BitV = BitV
 	ent.NumOfLinks = uint16(numOfLinks)
 	ent.LinkDetails = make([]LinkDetail, numOfLinks)
 	copy(ent.LinkDetails, linkDetails[0:])
@@ -648,9 +699,22 @@ func (server *OSPFServer) generateASExternalLsa(route RouteMdata) LsaKey {
 		LSId:      LSId,
 		AdvRouter: AdvRouter,
 	}
+	if server.NssaTranslatedLsaMap[lsaKey] {
+		if route.isDel {
+			// The installed LSA is translated from a Type-7 LSA
+			return lsaKey
+		}
+		// The locally originated LSA replaces the translated one
+		delete(server.NssaTranslatedLsaMap, lsaKey)
+	}
 
 	BitE := true
 	for lsdbKey, _ := range server.AreaLsdb {
+		areaId := config.AreaId(convertUint32ToIPv4(lsdbKey.AreaId))
+		if server.isStubArea(areaId) || server.isNssaArea(areaId) {
+			// NSSA carries the route as Type-7 LSA
+			continue
+		}
 		lsDbEnt, _ := server.AreaLsdb[lsdbKey]
 		ent, exist := lsDbEnt.ASExternalLsaMap[lsaKey]
 		LSAge := 0
@@ -670,7 +734,7 @@ func (server *OSPFServer) generateASExternalLsa(route RouteMdata) LsaKey {
 		ent.FwdAddr = convertAreaOrRouterIdUint32("0.0.0.0")
 		ent.Metric = route.metric
 		ent.Netmask = route.mask
-		ent.ExtRouteTag = 0
+		ent.ExtRouteTag = route.tag
 
 		LsaEnc := encodeASExternalLsa(ent, lsaKey)
 		checksumOffset := uint16(14)
@@ -1027,6 +1091,80 @@ func (server *OSPFServer) processRecvdASExternalLsa(data []byte, areaId uint32)
 	return true
 }
 
+func (server *OSPFServer) processDeleteNssaLsa(data []byte, areaId uint32) bool {
+	lsakey := NewLsaKey()
+	var val LsdbSliceEnt
+	nssaLsa := NewASExternalLsa()
+	lsdbKey := LsdbKey{
+		AreaId: areaId,
+	}
+	decodeASExternalLsa(data, nssaLsa, lsakey)
+	lsDbEnt, _ := server.AreaLsdb[lsdbKey]
+	delete(lsDbEnt.NssaLsaMap, *lsakey)
+	server.AreaLsdb[lsdbKey] = lsDbEnt
+
+	val.AreaId = lsdbKey.AreaId
+	val.LSType = lsakey.LSType
+	val.LSId = lsakey.LSId
+	val.AdvRtr = lsakey.AdvRouter
+	err := server.DelLsdbEntry(val)
+	if err != nil {
+		server.logger.Info(fmt.Sprintln("DB: Failed to delete entry from db ", lsakey))
+	}
+	return true
+}
+
+func (server *OSPFServer) processRecvdNssaLsa(data []byte, areaId uint32) bool {
+	lsakey := NewLsaKey()
+	nssaLsa := NewASExternalLsa()
+	lsdbKey := LsdbKey{
+		AreaId: areaId,
+	}
+	if !server.isNssaArea(config.AreaId(convertUint32ToIPv4(areaId))) {
+		server.logger.Err(fmt.Sprintln("Recvd NSSA LSA for non NSSA area ", areaId))
+		return false
+	}
+	decodeASExternalLsa(data, nssaLsa, lsakey)
+	selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+	_, exist := selfOrigLsaEnt[*lsakey]
+	if exist {
+		server.logger.Info("Recvd a self generated NSSA LSA")
+		return false
+	}
+
+	//Check Checksum
+	csum := computeFletcherChecksum(data[2:], FLETCHER_CHECKSUM_VALIDATE)
+	if csum != 0 {
+		server.logger.Err("Invalid NSSA LSA Checksum")
+		return false
+	}
+	lsDbEnt, _ := server.AreaLsdb[lsdbKey]
+	ent, exist := lsDbEnt.NssaLsaMap[*lsakey]
+	if exist {
+		if ent.LsaMd.LSSequenceNum >= nssaLsa.LsaMd.LSSequenceNum {
+			server.logger.Err("Old instance of NSSA LSA Recvd")
+			return false
+		}
+	}
+	lsDbEnt.NssaLsaMap[*lsakey] = *nssaLsa
+	server.AreaLsdb[lsdbKey] = lsDbEnt
+	if !exist {
+		var val LsdbSliceEnt
+		val.AreaId = lsdbKey.AreaId
+		val.LSType = lsakey.LSType
+		val.LSId = lsakey.LSId
+		val.AdvRtr = lsakey.AdvRouter
+		server.LsdbSlice = append(server.LsdbSlice, val)
+		msg := DbLsdbMsg{
+			entry: val,
+			op:    true,
+		}
+		server.DbLsdbOp <- msg
+	}
+
+	return true
+}
+
 func (server *OSPFServer) processRecvdLsa(data []byte, areaId uint32) bool {
 	LSType := uint8(data[3])
 	if LSType == RouterLSA {
@@ -1043,6 +1181,9 @@ func (server *OSPFServer) processRecvdLsa(data []byte, areaId uint32) bool {
 		return server.processRecvdSummaryLsa(data, areaId, LSType)
 	} else if LSType == ASExternalLSA {
 		return server.processRecvdASExternalLsa(data, areaId)
+	} else if LSType == NSSALSA {
+		server.logger.Info("LSDB: Received NSSA lsa")
+		return server.processRecvdNssaLsa(data, areaId)
 	} else {
 		server.logger.Info("LSDB: Invalid LSA packet from nbr")
 		return false
@@ -1062,6 +1203,8 @@ func (server *OSPFServer) processDeleteLsa(data []byte, areaId uint32) bool {
 		return server.processDeleteSummaryLsa(data, areaId, LSType)
 	} else if LSType == ASExternalLSA {
 		return server.processDeleteASExternalLsa(data, areaId)
+	} else if LSType == NSSALSA {
+		return server.processDeleteNssaLsa(data, areaId)
 	} else {
 		return false
 	}
@@ -1071,63 +1214,50 @@ func (server *OSPFServer) processLSDatabaseUpdates() {
 	for {
 		select {
 		case msg := <-server.LsdbUpdateCh:
+			if isOpaqueLsa(msg.Data[3]) {
+				ret := server.processOpaqueLsdbUpdate(msg)
+				server.logger.Info(fmt.Sprintln("Return Code:", ret))
+				continue
+			}
+			server.checkGRTopologyChange(msg)
 			if msg.MsgType == LsdbAdd {
 				server.logger.Info("Adding LS in the Lsdb")
 				server.logger.Info("Received New LSA")
 				ret := server.processRecvdLsa(msg.Data, msg.AreaId)
 				server.logger.Info(fmt.Sprintln("Return Code:", ret))
 				//server.LsaUpdateRetCodeCh <- ret
-				server.StartCalcSPFCh <- true
-				spfStatus := <-server.DoneCalcSPFCh
-				server.logger.Info(fmt.Sprintln("SPF Calculation Return Status", spfStatus))
-				if server.ospfGlobalConf.AreaBdrRtrStatus == true {
-					server.installSummaryLsa()
-				}
+				server.scheduleSpf(getSpfTypeForLsa(msg.Data[3]),
+					getLsaTypeName(msg.Data[3])+" update area "+convertUint32ToIPv4(msg.AreaId))
 			} else if msg.MsgType == LsdbDel {
 				server.logger.Info("Deleting LS in the Lsdb")
 				ret := server.processDeleteLsa(msg.Data, msg.AreaId)
 				//server.LsaUpdateRetCodeCh <- ret
 				server.logger.Info(fmt.Sprintln("Return Code:", ret))
-				server.StartCalcSPFCh <- true
-				spfStatus := <-server.DoneCalcSPFCh
-				server.logger.Info(fmt.Sprintln("SPF Calculation Return Status", spfStatus))
-				if server.ospfGlobalConf.AreaBdrRtrStatus == true {
-					server.installSummaryLsa()
-				}
+				server.scheduleSpf(getSpfTypeForLsa(msg.Data[3]),
+					getLsaTypeName(msg.Data[3])+" update area "+convertUint32ToIPv4(msg.AreaId))
 			} else if msg.MsgType == LsdbUpdate {
 				server.logger.Info("Deleting LS in the Lsdb")
 				ret := server.processRecvdLsa(msg.Data, msg.AreaId)
 				//server.LsaUpdateRetCodeCh <- ret
 				server.logger.Info(fmt.Sprintln("Return Code:", ret))
-				server.StartCalcSPFCh <- true
-				spfStatus := <-server.DoneCalcSPFCh
-				server.logger.Info(fmt.Sprintln("SPF Calculation Return Status", spfStatus))
-				if server.ospfGlobalConf.AreaBdrRtrStatus == true {
-					server.installSummaryLsa()
-				}
+				server.scheduleSpf(getSpfTypeForLsa(msg.Data[3]),
+					getLsaTypeName(msg.Data[3])+" update area "+convertUint32ToIPv4(msg.AreaId))
 			}
 		case msg := <-server.IntfStateChangeCh:
 			server.logger.Info(fmt.Sprintf("Interface State change msg", msg))
-			server.generateRouterLSA(msg.areaId)
+			server.exitGRHelperOnTopologyChange(msg.areaId, false, 0)
+			server.generateThrottledLsa(msg.areaId, msg.intfKey, false)
 			//server.logger.Info(fmt.Sprintln("LS Database", server.AreaLsdb))
-			server.StartCalcSPFCh <- true
-			spfStatus := <-server.DoneCalcSPFCh
-			server.logger.Info(fmt.Sprintln("SPF Calculation Return Status", spfStatus))
+			server.scheduleSpf(SpfFull, "Interface state change area "+convertUint32ToIPv4(msg.areaId))
 			server.processInterfaceChangeMsg(msg)
-			if server.ospfGlobalConf.AreaBdrRtrStatus == true {
-				server.installSummaryLsa()
-			}
+			server.updateTELsas()
 		case msg := <-server.NetworkDRChangeCh:
 			server.logger.Info(fmt.Sprintf("Network DR change msg", msg))
 			// Create a new router LSA
 			//server.logger.Info(fmt.Sprintln("LS Database", server.AreaLsdb))
 			server.processDrBdrChangeMsg(msg)
-			server.StartCalcSPFCh <- true
-			spfStatus := <-server.DoneCalcSPFCh
-			server.logger.Info(fmt.Sprintln("SPF Calculation Return Status", spfStatus))
-			if server.ospfGlobalConf.AreaBdrRtrStatus == true {
-				server.installSummaryLsa()
-			}
+			server.scheduleSpf(SpfFull, "DR change area "+convertUint32ToIPv4(msg.areaId))
+			server.updateTELsas()
 		case msg := <-server.CreateNetworkLSACh:
 			server.logger.Info(fmt.Sprintf("Create Network LSA msg", msg))
 			server.processNeighborFullEvent(msg)
@@ -1137,43 +1267,103 @@ func (server *OSPFServer) processLSDatabaseUpdates() {
 			// If link is broadcast
 			// Create Network LSA
 			//server.logger.Info(fmt.Sprintln("LS Database", server.AreaLsdb))
-			server.StartCalcSPFCh <- true
-			spfStatus := <-server.DoneCalcSPFCh
-			server.logger.Info(fmt.Sprintln("SPF Calculation Return Status", spfStatus))
-			if server.ospfGlobalConf.AreaBdrRtrStatus == true {
-				server.installSummaryLsa()
-			}
+			server.scheduleSpf(SpfFull, "Neighbor full area "+convertUint32ToIPv4(msg.areaId))
+			server.updateTELsas()
 
 		case msg := <-server.ExternalRouteNotif: //Generate external LSA
 			server.processExtRouteUpd(msg)
 
+		case <-server.summaryAddrChangeCh: //Resync summarized external LSAs
+			server.processSummaryAddrChange()
+
+		case <-server.virtLinkLsaCh: //Virtual adjacency or cost changed
+			server.processVirtLinkLsaChange()
+
+		case <-server.areaRangeChangeCh: //Regenerate area range summary LSAs
+			if server.ospfGlobalConf.AreaBdrRtrStatus == true {
+				server.scheduleSpf(SpfPartial, "Area range change")
+			}
+
+		case msg := <-server.OpaqueLsaCh: //Generate opaque LSA
+			server.processOpaqueLsaMsg(msg)
+
 		case msg := <-server.maxAgeLsaCh: //Flood MaxAge LSA
 			server.processMaxAgeLsaMsg(msg)
 
+		case nbrKey := <-server.grHelperExpiryCh: //Grace period of helped neighbor expired
+			server.processGRHelperExpiry(nbrKey)
+
+		case <-server.grPlannedRestartCh: //Planned restart, originate grace LSAs
+			server.grPlannedRestartRetCh <- server.processPlannedRestart()
+
 		case <-lsdbTickerCh.C: //Increment LSA AGE
 			lsdbTickerCh.Stop()
 			server.processLSDatabaseTicker()
+			server.checkGRRestartExit()
 			lsdbTickerCh.Reset(time.Duration(1) * time.Second)
 
 		case <-lsdbRefreshTickerCh.C: //Regenerate LSA
 			lsdbRefreshTickerCh.Stop()
 			server.lsdbSelfLsaRefresh()
 			lsdbRefreshTickerCh.Reset(time.Duration(config.LSRefreshTime) * time.Second)
+
+		case <-spfThrottleTimer.C: //Run throttled SPF
+			server.processSpfThrottleTimer()
+
+		case <-lsaThrottleTimer.C: //Generate throttled LSAs
+			server.processLsaThrottleTimer()
 		}
 	}
 }
 
 /*@fn processExtRouteUpd
+Track the redistributed route and generate / delete the
+As external LSA of the route or of its summary address.
+*/
+func (server *OSPFServer) processExtRouteUpd(msg RouteMdata) {
+	key := getExtRouteKey(msg)
+	if msg.isDel {
+		delete(server.extRouteMap, key)
+	} else {
+		server.extRouteMap[key] = msg
+	}
+	sKey, covered := server.getCoveringSummaryAddr(key)
+	if !covered {
+		server.originateExtRouteLsa(msg)
+		if msg.isDel {
+			delete(server.extLsaMap, key)
+		} else {
+			server.extLsaMap[key] = msg
+		}
+		return
+	}
+	server.syncExtLsa(key)
+	server.syncExtLsa(sKey)
+}
+
+/*@fn originateExtRouteLsa
 Generate / delete As external LSA.
 Send flood message if new route is added.
 */
-func (server *OSPFServer) processExtRouteUpd(msg RouteMdata) {
+func (server *OSPFServer) originateExtRouteLsa(msg RouteMdata) {
 	ifkey := IntfConfKey{}
 	nbr := NeighborConfKey{}
+	if server.isGRRestarting() {
+		/* RFC 3623 2.2 Originated from extLsaMap when the
+		graceful restart ends */
+		return
+	}
 	lsaKey := server.generateASExternalLsa(msg)
 	if !msg.isDel {
 		server.sendLsdbToNeighborEvent(ifkey, nbr, 0, 0, 0, lsaKey, LSAEXTFLOOD)
 	}
+	nssaKey, areaList := server.generateNssaLsa(msg)
+	if msg.isDel {
+		server.sendLsdbToNeighborEvent(ifkey, nbr, 0, 0, 0, nssaKey, LSAAGE)
+	}
+	for _, areaId := range areaList {
+		server.sendLsdbToNeighborEvent(ifkey, nbr, areaId, 0, 0, nssaKey, LSANSSAFLOOD)
+	}
 }
 
 /*
@@ -1213,10 +1403,14 @@ func (server *OSPFServer) processNeighborFullEvent(msg ospfNbrMdata) {
 		msg.areaId, " intf ", intConf.IfIpAddr))
 	if intConf.IfDRtrId == rtr_id && intConf.IfType == config.Broadcast {
 		server.logger.Info(fmt.Sprintln("Generate network LSA ", msg.intf))
-		server.generateNetworkLSA(msg.areaId, msg.intf, true)
+		server.generateThrottledLsa(msg.areaId, msg.intf, true)
+	} else {
+		server.generateThrottledLsa(msg.areaId, msg.intf, false)
 	}
-	server.generateRouterLSA(msg.areaId)
 	server.sendLsdbToNeighborEvent(msg.intf, nbr, msg.areaId, 0, 0, lsaKey, LSAFLOOD)
+	if intConf.IfType == config.VirtualLink {
+		server.updateVirtLinkTransitAreas()
+	}
 }
 
 /* @fn processDrBdrChangeMsg
@@ -1238,14 +1432,15 @@ func (server *OSPFServer) processDrBdrChangeMsg(msg DrChangeMsg) {
 		nbrExists = true
 		break
 	}
+	isNetwork := false
 	if nbrExists {
 		rtr_id := binary.BigEndian.Uint32(server.ospfGlobalConf.RouterId)
 		if intf.IfDRtrId == rtr_id {
 			server.logger.Info(fmt.Sprintln("Generate network LSA ", intf.IfIpAddr))
-			server.generateNetworkLSA(msg.areaId, msg.intfKey, true)
+			isNetwork = true
 		}
 	}
-	server.generateRouterLSA(msg.areaId)
+	server.generateThrottledLsa(msg.areaId, msg.intfKey, isNetwork)
 	server.sendLsdbToNeighborEvent(msg.intfKey, nbr, msg.areaId, 0, 0, lsaKey, LSAFLOOD)
 
 }
@@ -81,6 +81,37 @@ func (server *OSPFServer) lsdbStateRefresh() {
 				val.AdvRtr = lsakey.AdvRouter
 				server.LsdbSlice = append(server.LsdbSlice, val)
 			}
+			for lsakey, _ := range lsdbEnt.NssaLsaMap {
+				var val LsdbSliceEnt
+				val.AreaId = lsdbkey.AreaId
+				val.LSType = lsakey.LSType
+				val.LSId = lsakey.LSId
+				val.AdvRtr = lsakey.AdvRouter
+				server.LsdbSlice = append(server.LsdbSlice, val)
+			}
+			for lsakey, _ := range lsdbEnt.OpaqueLsaMap {
+				var val LsdbSliceEnt
+				val.AreaId = lsdbkey.AreaId
+				val.LSType = lsakey.LSType
+				val.LSId = lsakey.LSId
+				val.AdvRtr = lsakey.AdvRouter
+				server.LsdbSlice = append(server.LsdbSlice, val)
+			}
+		}
+		for intfKey, opaqueLsdb := range server.IntfOpaqueLsdb {
+			intf, exist := server.IntfConfMap[intfKey]
+			if !exist {
+				continue
+			}
+			for lsakey, _ := range opaqueLsdb {
+				var val LsdbSliceEnt
+				val.AreaId = convertIPv4ToUint32(intf.IfAreaId)
+				val.LSType = lsakey.LSType
+				val.LSId = lsakey.LSId
+				val.AdvRtr = lsakey.AdvRouter
+				val.IntfKey = intfKey
+				server.LsdbSlice = append(server.LsdbSlice, val)
+			}
 		}
 		server.logger.Info(fmt.Sprintln("The new Lsdb Slice after refresh", server.LsdbSlice))
 		server.LsdbStateTimer.Reset(server.RefreshDuration)
@@ -102,6 +133,11 @@ From RFC 2328
 */
 func (server *OSPFServer) lsdbSelfLsaRefresh() {
 	server.logger.Info(fmt.Sprintln("REFRESH: LSDB refresh started..."))
+	if server.isGRRestarting() {
+		/* RFC 3623 2.2 Self originated LSAs are not refreshed
+		during a graceful restart */
+		return
+	}
 	floodAsExt := 0
 	ifkey := IntfConfKey{}
 	nbr := NeighborConfKey{}
@@ -113,6 +149,12 @@ func (server *OSPFServer) lsdbSelfLsaRefresh() {
 				if floodAsExt == 0 && lsaKey.LSType == ASExternalLSA {
 					server.sendLsdbToNeighborEvent(ifkey, nbr, 0, 0, 0, lsaKey, LSAEXTFLOOD)
 				}
+				if lsaKey.LSType == NSSALSA {
+					server.sendLsdbToNeighborEvent(ifkey, nbr, lsdbKey.AreaId, 0, 0, lsaKey, LSANSSAFLOOD)
+				}
+				if lsaKey.LSType == AreaOpaqueLSA || lsaKey.LSType == ASOpaqueLSA {
+					server.sendLsdbToNeighborEvent(ifkey, nbr, lsdbKey.AreaId, 0, 0, lsaKey, LSAOPAQUEFLOOD)
+				}
 				if err != nil {
 					server.logger.Warning(fmt.Sprintln("LSDB: Failed to regenerate LSA ", lsaKey, " Area ", lsdbKey))
 				}
@@ -158,6 +200,16 @@ func (server *OSPFServer) regenerateLsa(lsdbKey LsdbKey, lsaKey LsaKey) error {
 	case ASExternalLSA:
 		server.updateAsExternalLSA(lsdbKey, lsaKey)
 
+	case NSSALSA:
+		lsa, ret := server.getNssaLsaFromLsdb(lsdbKey.AreaId, lsaKey)
+		if ret == LsdbEntryNotFound {
+			return nil
+		}
+		server.installSelfNssaLsa(lsdbKey, lsaKey, lsa, false)
+
+	case LocalOpaqueLSA, AreaOpaqueLSA, ASOpaqueLSA:
+		server.regenerateOpaqueLsa(lsdbKey, lsaKey)
+
 	}
 	return nil
 }
@@ -174,6 +226,9 @@ func (server *OSPFServer) processLSDatabaseTicker() {
 		server.processMaxAgeLSA(lsdbKey, lsDbEnt)
 
 	}
+	for intfKey, opaqueLsdb := range server.IntfOpaqueLsdb {
+		server.processMaxAgeIntfOpaqueLSA(intfKey, opaqueLsdb)
+	}
 
 }
 
@@ -35,6 +35,7 @@ func (server *OSPFServer) HandleSummaryLsa(areaId uint32) {
 	server.HandleSummaryType3Lsa(areaId)
 	server.HandleSummaryType4Lsa(areaId)
 	server.HandleASExternalLsa(areaId)
+	server.HandleNssaLsa(areaId)
 }
 
 func (server *OSPFServer) HandleSummaryType3Lsa(areaId uint32) {
@@ -164,6 +165,7 @@ func (server *OSPFServer) CalcInterAreaRoutes(areaId uint32) {
 func (server *OSPFServer) GenerateSummaryLsa() {
 	server.logger.Info("Generating Summary LSA")
 	server.SummaryLsDb = make(map[LsdbKey]SummaryLsaMap)
+	areaRanges := server.calcAreaRanges()
 	for aKey, aEnt := range server.AreaConfMap {
 		if len(aEnt.IntfListMap) == 0 {
 			continue
@@ -174,6 +176,7 @@ func (server *OSPFServer) GenerateSummaryLsa() {
 			AreaId: areaId,
 		}
 		isStub := server.isStubArea(aKey.AreaId)
+		isNssa := server.isNssaArea(aKey.AreaId)
 		sEnt, _ := server.SummaryLsDb[lsDbKey]
 		sEnt = make(map[LsaKey]SummaryLsa)
 		for rKey, rEnt := range server.GlobalRoutingTbl {
@@ -210,7 +213,7 @@ func (server *OSPFServer) GenerateSummaryLsa() {
 			*/
 
 			if (rKey.DestType == ASAreaBdrRouter ||
-				rKey.DestType == ASBdrRouter) && !isStub {
+				rKey.DestType == ASBdrRouter) && !isStub && !isNssa {
 				lsaKey, summaryLsa := server.GenerateType4SummaryLSA(rKey, rEnt, lsDbKey)
 				sEnt[lsaKey] = summaryLsa
 			}
@@ -225,7 +228,11 @@ func (server *OSPFServer) GenerateSummaryLsa() {
 				sEnt[lsaKey] = summaryLsa
 			} else if rKey.DestType == Network &&
 				rEnt.RoutingTblEnt.PathType == IntraArea {
-				//TODO: Address Range
+				// Routes in an area range are advertised
+				// by the range summary LSA
+				if _, covered := getCoveringAreaRange(areaRanges, rEnt.AreaId, rKey); covered {
+					continue
+				}
 				// By default LSId = network's address
 				// Metric = Routing Table cost
 				lsaKey, summaryLsa := server.GenerateType3SummaryLSA(rKey, rEnt, lsDbKey)
@@ -233,6 +240,15 @@ func (server *OSPFServer) GenerateSummaryLsa() {
 			}
 		}
 
+		for rangeKey, rangeEnt := range areaRanges {
+			if rangeKey.AreaId == areaId || !rangeEnt.Active ||
+				rangeEnt.Effect != config.AdvertiseMatching {
+				continue
+			}
+			lsaKey, summaryLsa := server.generateAreaRangeSummaryLSA(rangeKey, rangeEnt, lsDbKey)
+			sEnt[lsaKey] = summaryLsa
+		}
+
 		server.SummaryLsDb[lsDbKey] = sEnt
 		if isStub {
 			lsaKey, defsummaryLsa := server.GenerateDefaultSummary3LSA(lsDbKey)
@@ -172,16 +172,19 @@ func (server *OSPFServer) processNeighborExstart(nbrKey NeighborConfKey,
 	}
 
 	var lsa_attach uint8
+	intfConf, _ := server.IntfConfMap[nbrConf.intfConfKey]
 	if negotiationDone {
 		//server.logger.Debug(fmt.Sprintln("DBD: (Exstart) lsa_headers = ", len(nbrDbPkt.lsa_headers)))
+		/* RFC 5250 opaque LSAs are described only to opaque capable neighbors */
+		server.updateNbrOptions(nbrKey, nbrDbPkt.options)
 		server.generateDbSummaryList(nbrKey)
 		if nbrConf.isMaster != true { // i am the master
 			dbd_mdata, last_exchange = server.ConstructAndSendDbdPacket(nbrKey, false, true, true,
-				nbrDbPkt.options, nbrDbPkt.dd_sequence_number+1, true, false, ifMtu)
+				server.getIntfOptions(intfConf), nbrDbPkt.dd_sequence_number+1, true, false, ifMtu)
 		} else {
 			// send acknowledgement DBD with I and MS bit false , mbit = 1
 			dbd_mdata, last_exchange = server.ConstructAndSendDbdPacket(nbrKey, false, true, false,
-				nbrDbPkt.options, nbrDbPkt.dd_sequence_number, true, false, ifMtu)
+				server.getIntfOptions(intfConf), nbrDbPkt.dd_sequence_number, true, false, ifMtu)
 			dbd_mdata.dd_sequence_number++
 		}
 
@@ -196,13 +199,13 @@ func (server *OSPFServer) processNeighborExstart(nbrKey NeighborConfKey,
 			nbrConf.OspfNbrRtrId > binary.BigEndian.Uint32(server.ospfGlobalConf.RouterId) {
 			dbd_mdata.dd_sequence_number = nbrDbPkt.dd_sequence_number
 			dbd_mdata, last_exchange = server.ConstructAndSendDbdPacket(nbrKey, true, true, true,
-				nbrDbPkt.options, nbrDbPkt.dd_sequence_number, false, false, ifMtu)
+				server.getIntfOptions(intfConf), nbrDbPkt.dd_sequence_number, false, false, ifMtu)
 			dbd_mdata.dd_sequence_number++
 		} else {
 			//start with new seq number
 			dbd_mdata.dd_sequence_number = uint32(time.Now().Nanosecond()) //nbrConf.ospfNbrSeqNum
 			dbd_mdata, last_exchange = server.ConstructAndSendDbdPacket(nbrKey, true, true, true,
-				nbrDbPkt.options, nbrDbPkt.dd_sequence_number, false, false, ifMtu)
+				server.getIntfOptions(intfConf), nbrDbPkt.dd_sequence_number, false, false, ifMtu)
 		}
 	}
 
@@ -273,7 +276,7 @@ func (server *OSPFServer) processDBDEvent(nbrKey NeighborConfKey, nbrDbPkt ospfD
 							nbrDbPkt.mbit) {
 						server.logger.Debug(fmt.Sprintln("DBD: (master/Exchange) Send next packet in the exchange  to nbr ", nbrKey.IPAddr))
 						dbd_mdata, last_exchange = server.ConstructAndSendDbdPacket(nbrKey, false, false, true,
-							nbrDbPkt.options, nbrDbPkt.dd_sequence_number+1, true, false, intfConf.IfMtu)
+							server.getIntfOptions(intfConf), nbrDbPkt.dd_sequence_number+1, true, false, intfConf.IfMtu)
 						OspfNeighborLastDbd[nbrKey] = dbd_mdata
 					}
 
@@ -289,7 +292,7 @@ func (server *OSPFServer) processDBDEvent(nbrKey NeighborConfKey, nbrDbPkt ospfD
 						server.logger.Debug(fmt.Sprintln("DBD: (slave/Exchange) Send next packet in the exchange  to nbr ", nbrKey.IPAddr))
 						server.generateRequestList(nbrKey, nbrConf, nbrDbPkt)
 						dbd_mdata, last_exchange = server.ConstructAndSendDbdPacket(nbrKey, false, nbrDbPkt.mbit, false,
-							nbrDbPkt.options, nbrDbPkt.dd_sequence_number, true, false, intfConf.IfMtu)
+							server.getIntfOptions(intfConf), nbrDbPkt.dd_sequence_number, true, false, intfConf.IfMtu)
 						OspfNeighborLastDbd[nbrKey] = dbd_mdata
 						dbd_mdata.dd_sequence_number++
 					} else {
@@ -366,7 +369,7 @@ func (server *OSPFServer) processDBDEvent(nbrKey NeighborConfKey, nbrDbPkt ospfD
 				nbrConf.nbrEvent = config.Nbr2WayReceived
 				nbrConf.isMaster = false
 				dbd_mdata, last_exchange = server.ConstructAndSendDbdPacket(nbrKey, true, true, true,
-					nbrDbPkt.options, nbrConf.ospfNbrSeqNum+1, false, false, intfConf.IfMtu)
+					server.getIntfOptions(intfConf), nbrConf.ospfNbrSeqNum+1, false, false, intfConf.IfMtu)
 				seq_num = dbd_mdata.dd_sequence_number
 			} else if !isDuplicate {
 				/*
@@ -375,7 +378,7 @@ func (server *OSPFServer) processDBDEvent(nbrKey NeighborConfKey, nbrDbPkt ospfD
 				*/
 				if nbrConf.isMaster {
 					dbd_mdata, _ := server.ConstructAndSendDbdPacket(nbrKey, false, nbrDbPkt.mbit, false,
-						nbrDbPkt.options, nbrDbPkt.dd_sequence_number, false, false, intfConf.IfMtu)
+						server.getIntfOptions(intfConf), nbrDbPkt.dd_sequence_number, false, false, intfConf.IfMtu)
 					seq_num = dbd_mdata.dd_sequence_number + 1
 				}
 				nbrConf.ospfNbrLsaReqIndex = server.BuildAndSendLSAReq(nbrKey, nbrConf)
@@ -479,7 +482,9 @@ func (server *OSPFServer) ProcessNbrStateMachine() {
 							isStateUpdate = true
 						}
 					}
-				} else {
+				} else if !server.isGRHelping(nbrKey) {
+					/* RFC 3623 3.1 restarting neighbor is kept FULL
+					till the helper mode ends */
 					nbrConf.OspfNbrState = config.NbrInit
 					isStateUpdate = true
 				}
@@ -508,7 +513,7 @@ func (server *OSPFServer) ProcessNbrStateMachine() {
 
 				if send_dbd {
 					server.ConstructAndSendDbdPacket(nbrConfMsg.ospfNbrConfKey, true, true, true,
-						INTF_OPTIONS, nbrConf.ospfNbrSeqNum, false, false, intfConf.IfMtu)
+						server.getIntfOptions(intfConf), nbrConf.ospfNbrSeqNum, false, false, intfConf.IfMtu)
 				}
 
 			} else { //neighbor doesnt exist
@@ -575,7 +580,7 @@ func (server *OSPFServer) ProcessNbrStateMachine() {
 					dbd_mdata.msbit = true
 
 					dbd_mdata.interface_mtu = uint16(intfConf.IfMtu)
-					dbd_mdata.options = INTF_OPTIONS
+					dbd_mdata.options = server.getIntfOptions(intfConf)
 				}
 				server.logger.Info(fmt.Sprintln("NBREVENT: ADD Nbr ", nbrData.RouterId, "state ", nbrState))
 			}
@@ -753,9 +758,25 @@ func (server *OSPFServer) generateDbSummaryList(nbrConfKey NeighborConfKey) {
 		db_list = append(db_list, summary4_list...)
 	}
 
-	asExternal_list := server.generateDbasExternalList(areaId)
-	if asExternal_list != nil {
-		db_list = append(db_list, asExternal_list...)
+	/* Rfc 2328 10.3 AS external LSAs are omitted on virtual links */
+	if intf.IfType != config.VirtualLink {
+		asExternal_list := server.generateDbasExternalList(areaId)
+		if asExternal_list != nil {
+			db_list = append(db_list, asExternal_list...)
+		}
+	}
+
+	nssa_list := server.generateDbNssaList(areaId)
+	if nssa_list != nil {
+		db_list = append(db_list, nssa_list...)
+	}
+
+	if server.ospfGlobalConf.OpaqueLsaSupport &&
+		uint8(nbrConf.OspfNbrOptions)&OOption != 0 {
+		opaque_list := server.generateDbOpaqueList(areaId, nbrConf.intfConfKey)
+		if opaque_list != nil {
+			db_list = append(db_list, opaque_list...)
+		}
 	}
 
 	for lsa := range db_list {
@@ -919,6 +940,10 @@ func (server *OSPFServer) neighborDeadTimerEvent(nbrConfKey NeighborConfKey) {
 
 	nbr_entry_dead_func = func() {
 		server.logger.Info(fmt.Sprintln("NBRSCAN: DEAD ", nbrConfKey.IPAddr))
+		if server.isGRHelping(nbrConfKey) {
+			server.logger.Info(fmt.Sprintln("NBRSCAN: GR helper. keep adjacency ", nbrConfKey.IPAddr))
+			return
+		}
 
 		_, exists := server.NeighborConfigMap[nbrConfKey]
 		if exists {
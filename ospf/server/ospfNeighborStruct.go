@@ -54,6 +54,8 @@ const (
 	LSASUMMARYFLOOD = 4 //flood summary LSAs in different areas.
 	LSAEXTFLOOD     = 5 //flood AS External summary LSA
 	LSAROUTERFLOOD  = 6 //flood only router LSA
+	LSANSSAFLOOD    = 7 //flood NSSA LSA within the area
+	LSAOPAQUEFLOOD  = 8 //flood opaque LSA within its scope
 )
 
 type NeighborConfKey struct {
@@ -197,6 +199,13 @@ func (server *OSPFServer) UpdateNeighborConf() {
 			intfConf, _ := server.IntfConfMap[nbrMsg.ospfNbrEntry.intfConfKey]
 			//server.logger.Info(fmt.Sprintln("Update neighbor conf.  received"))
 			if nbrMsg.nbrMsgType == NBRDEL {
+				/* Rfc 2328 D.5.2 the sequence number is reset with the neighbor */
+				if nbr, exist := server.NeighborConfigMap[nbrMsg.ospfNbrConfKey]; exist {
+					if ent, valid := server.IntfConfMap[nbr.intfConfKey]; valid && ent.IfCryptoAuth != nil {
+						ent.IfCryptoAuth.resetRxSeqNum(nbr.OspfNbrRtrId)
+					}
+				}
+				server.deleteGRHelper(nbrMsg.ospfNbrConfKey)
 				delete(server.NeighborConfigMap, nbrMsg.ospfNbrConfKey)
 				server.logger.Info(fmt.Sprintln("DELETE neighbor with nbr id - ",
 					nbrMsg.ospfNbrConfKey.IPAddr, nbrMsg.ospfNbrConfKey.IntfIdx))
@@ -238,7 +247,7 @@ func (server *OSPFServer) UpdateNeighborConf() {
 				if nbrMsg.ospfNbrEntry.OspfNbrState >= config.NbrTwoWay {
 					seq_num := uint32(time.Now().Nanosecond())
 					server.ConstructAndSendDbdPacket(nbrMsg.ospfNbrConfKey, true, true, true,
-						INTF_OPTIONS, seq_num, false, false, intfConf.IfMtu)
+						server.getIntfOptions(intfConf), seq_num, false, false, intfConf.IfMtu)
 					nbrConf.OspfNbrState = config.NbrExchangeStart
 					nbrConf.nbrEvent = config.Nbr2WayReceived
 					nbrConf.ospfNbrSeqNum = seq_num
@@ -0,0 +1,679 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"l3/ospf/config"
+)
+
+/*
+	RFC 3101 Not-So-Stubby Area (NSSA)
+
+	Type-7 LSAs share the AS-external-LSA format and are stored in
+	LSDatabase.NssaLsaMap of the NSSA area they were originated in.
+	The P-bit (propagate) of a Type-7 LSA is carried in the same
+	options bit as the N-bit of hello and database description packets.
+*/
+
+/*
+@fn getIntfOptions
+Options advertised in database description packets on the interface.
+NSSA interfaces set the N-bit and clear the E-bit.
+The O-bit is set only when opaque LSAs are supported.
+*/
+func (server *OSPFServer) getIntfOptions(intf IntfConf) uint8 {
+	options := uint8(INTF_OPTIONS)
+	if !server.ospfGlobalConf.OpaqueLsaSupport {
+		options = options &^ OOption
+	}
+	if intf.IfAreaId == nil {
+		return options
+	}
+	areaId := config.AreaId(convertIPInByteToString(intf.IfAreaId))
+	if server.isNssaArea(areaId) {
+		return (options &^ EOption) | NPOption
+	}
+	return options
+}
+
+/*
+@fn getNssaFwdAddr
+RFC 3101 2.3: Type-7 LSAs with the P-bit set must carry a non-zero
+forwarding address. Use the address of one of the router's
+interfaces attached to the NSSA.
+*/
+func (server *OSPFServer) getNssaFwdAddr(areaId uint32) uint32 {
+	for key, intf := range server.IntfConfMap {
+		if intf.IfAreaId == nil ||
+			convertIPv4ToUint32(intf.IfAreaId) != areaId {
+			continue
+		}
+		if intf.IfAdminStat != config.Enabled {
+			continue
+		}
+		return convertAreaOrRouterIdUint32(string(key.IPAddr))
+	}
+	return 0
+}
+
+/*
+@fn installSelfNssaLsa
+Add (or refresh) a self originated Type-7 LSA in the area LSDB.
+When isDel is set the LSA is flushed from the area.
+*/
+func (server *OSPFServer) installSelfNssaLsa(lsdbKey LsdbKey, lsaKey LsaKey, lsa ASExternalLsa, isDel bool) bool {
+	lsDbEnt, exist := server.AreaLsdb[lsdbKey]
+	if !exist {
+		server.logger.Warning(fmt.Sprintln("NSSA: Area LSDB doesnt exist ", lsdbKey))
+		return false
+	}
+	selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+	ent, exist := lsDbEnt.NssaLsaMap[lsaKey]
+	if isDel {
+		if !exist {
+			return false
+		}
+		server.flushNssaLsa(lsdbKey, lsaKey)
+		return true
+	}
+	if !exist {
+		ent.LsaMd.LSSequenceNum = InitialSequenceNumber
+	} else {
+		ent.LsaMd.LSSequenceNum = ent.LsaMd.LSSequenceNum + 1
+	}
+	ent.LsaMd.LSAge = 0
+	ent.LsaMd.Options = lsa.LsaMd.Options
+	ent.LsaMd.LSChecksum = 0
+	ent.LsaMd.LSLen = uint16(OSPF_LSA_HEADER_SIZE + 16)
+	ent.BitE = lsa.BitE
+	ent.Netmask = lsa.Netmask
+	ent.Metric = lsa.Metric
+	ent.FwdAddr = lsa.FwdAddr
+	ent.ExtRouteTag = lsa.ExtRouteTag
+
+	LsaEnc := encodeASExternalLsa(ent, lsaKey)
+	checksumOffset := uint16(14)
+	ent.LsaMd.LSChecksum = computeFletcherChecksum(LsaEnc[2:], checksumOffset)
+	lsDbEnt.NssaLsaMap[lsaKey] = ent
+	server.AreaLsdb[lsdbKey] = lsDbEnt
+	selfOrigLsaEnt[lsaKey] = true
+	server.AreaSelfOrigLsa[lsdbKey] = selfOrigLsaEnt
+	server.logger.Info(fmt.Sprintln("NSSA: Added LSA to area ", lsdbKey, " lsaKey ", lsaKey))
+	if !exist {
+		var val LsdbSliceEnt
+		val.AreaId = lsdbKey.AreaId
+		val.LSType = lsaKey.LSType
+		val.LSId = lsaKey.LSId
+		val.AdvRtr = lsaKey.AdvRouter
+		server.LsdbSlice = append(server.LsdbSlice, val)
+		msg := DbLsdbMsg{
+			entry: val,
+			op:    true,
+		}
+		server.DbLsdbOp <- msg
+	}
+	return true
+}
+
+/*
+@fn flushNssaLsa
+Premature aging of self originated Type-7 LSA.
+*/
+func (server *OSPFServer) flushNssaLsa(lsdbKey LsdbKey, lsaKey LsaKey) {
+	lsDbEnt, _ := server.AreaLsdb[lsdbKey]
+	selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+	lsa, exist := lsDbEnt.NssaLsaMap[lsaKey]
+	if !exist {
+		return
+	}
+	server.logger.Info(fmt.Sprintln("FLUSH: NSSA lsa lsid ",
+		convertUint32ToIPv4(lsaKey.LSId), " area ", lsdbKey.AreaId))
+	lsa.LsaMd.LSAge = config.MaxAge
+	maxAgeLsaMap[lsaKey] = encodeASExternalLsa(lsa, lsaKey)
+	delete(lsDbEnt.NssaLsaMap, lsaKey)
+	delete(selfOrigLsaEnt, lsaKey)
+	server.AreaSelfOrigLsa[lsdbKey] = selfOrigLsaEnt
+	server.AreaLsdb[lsdbKey] = lsDbEnt
+}
+
+/*
+@fn generateNssaLsa
+RFC 3101 2.3
+Originate Type-7 LSA for the redistributed route in all attached
+NSSAs. Returns list of areas in which the LSA was added.
+*/
+func (server *OSPFServer) generateNssaLsa(route RouteMdata) (LsaKey, []uint32) {
+	var areaList []uint32
+	lsaKey := LsaKey{
+		LSType:    NSSALSA,
+		LSId:      route.ipaddr & route.mask,
+		AdvRouter: convertIPv4ToUint32(server.ospfGlobalConf.RouterId),
+	}
+	for key, aEnt := range server.AreaConfMap {
+		if !server.isNssaArea(key.AreaId) || len(aEnt.IntfListMap) == 0 {
+			continue
+		}
+		areaId := convertAreaOrRouterIdUint32(string(key.AreaId))
+		lsdbKey := LsdbKey{
+			AreaId: areaId,
+		}
+		var lsa ASExternalLsa
+		lsa.BitE = true
+		lsa.Netmask = route.mask
+		lsa.Metric = route.metric
+		lsa.ExtRouteTag = route.tag
+		/* An NSSA ASBR which is also an ABR does not set the P-bit */
+		if !server.ospfGlobalConf.isABR {
+			lsa.FwdAddr = server.getNssaFwdAddr(areaId)
+			if lsa.FwdAddr != 0 {
+				lsa.LsaMd.Options = NPOption
+			}
+		}
+		if server.installSelfNssaLsa(lsdbKey, lsaKey, lsa, route.isDel) && !route.isDel {
+			areaList = append(areaList, areaId)
+		}
+	}
+	return lsaKey, areaList
+}
+
+/*
+@fn generateNssaDefaultLsa
+RFC 3101 2.7
+NSSA border routers inject a Type-7 default route into
+the attached NSSAs. The P-bit is clear so that it is never
+translated into the rest of the AS.
+*/
+func (server *OSPFServer) generateNssaDefaultLsa() {
+	ifkey := IntfConfKey{}
+	nbr := NeighborConfKey{}
+	lsaKey := LsaKey{
+		LSType:    NSSALSA,
+		LSId:      0,
+		AdvRouter: convertIPv4ToUint32(server.ospfGlobalConf.RouterId),
+	}
+	for key, aEnt := range server.AreaConfMap {
+		if !server.isNssaArea(key.AreaId) {
+			continue
+		}
+		areaId := convertAreaOrRouterIdUint32(string(key.AreaId))
+		lsdbKey := LsdbKey{
+			AreaId: areaId,
+		}
+		lsa, ret := server.getNssaLsaFromLsdb(areaId, lsaKey)
+		if !server.ospfGlobalConf.isABR || len(aEnt.IntfListMap) == 0 {
+			if ret == LsdbEntryFound {
+				server.flushNssaLsa(lsdbKey, lsaKey)
+				server.sendLsdbToNeighborEvent(ifkey, nbr, 0, 0, 0, lsaKey, LSAAGE)
+			}
+			continue
+		}
+		if ret == LsdbEntryFound &&
+			lsa.Metric == uint32(aEnt.StubDefaultCost) {
+			continue
+		}
+		lsa = ASExternalLsa{}
+		lsa.BitE = true
+		lsa.Netmask = 0
+		lsa.Metric = uint32(aEnt.StubDefaultCost)
+		if server.installSelfNssaLsa(lsdbKey, lsaKey, lsa, false) {
+			server.logger.Info(fmt.Sprintln("NSSA: Inject default route in area ", key.AreaId))
+			server.sendLsdbToNeighborEvent(ifkey, nbr, areaId, 0, 0, lsaKey, LSANSSAFLOOD)
+		}
+	}
+}
+
+/*
+@fn getNssaRouterRoute
+Intra area route to an ASBR / ABR within the NSSA.
+*/
+func (server *OSPFServer) getNssaRouterRoute(areaIdKey AreaIdKey, rtrId uint32) (RoutingTblEntry, bool) {
+	tempAreaRoutingTbl := server.TempAreaRoutingTbl[areaIdKey]
+	for _, destType := range []DestType{ASBdrRouter, ASAreaBdrRouter, AreaBdrRouter} {
+		rKey := RoutingTblEntryKey{
+			DestId:   rtrId,
+			AddrMask: 0,
+			DestType: destType,
+		}
+		rEnt, exist := tempAreaRoutingTbl.RoutingTblMap[rKey]
+		if exist && rEnt.NumOfPaths != 0 {
+			return rEnt, true
+		}
+	}
+	return RoutingTblEntry{}, false
+}
+
+/*
+@fn getNssaFwdAddrRoute
+RFC 3101 2.5 (3): the forwarding address must be reachable
+through an intra area path in the NSSA.
+*/
+func (server *OSPFServer) getNssaFwdAddrRoute(areaIdKey AreaIdKey, fwdAddr uint32) (RoutingTblEntry, bool) {
+	var bestEnt RoutingTblEntry
+	var bestMask uint32
+	found := false
+	tempAreaRoutingTbl := server.TempAreaRoutingTbl[areaIdKey]
+	for rKey, rEnt := range tempAreaRoutingTbl.RoutingTblMap {
+		if rKey.DestType != Network ||
+			rEnt.PathType != IntraArea ||
+			rEnt.NumOfPaths == 0 {
+			continue
+		}
+		if fwdAddr&rKey.AddrMask != rKey.DestId {
+			continue
+		}
+		if !found || rKey.AddrMask > bestMask {
+			bestEnt = rEnt
+			bestMask = rKey.AddrMask
+			found = true
+		}
+	}
+	return bestEnt, found
+}
+
+/*
+@fn HandleNssaLsa
+RFC 3101 2.5
+Calculate routes from Type-7 LSAs. Called for every area alongside
+HandleASExternalLsa; non NSSA areas are ignored.
+*/
+func (server *OSPFServer) HandleNssaLsa(areaId uint32) {
+	areaConfKey := AreaConfKey{
+		AreaId: config.AreaId(convertUint32ToIPv4(areaId)),
+	}
+	if !server.isNssaArea(areaConfKey.AreaId) {
+		return
+	}
+	lsdbKey := LsdbKey{
+		AreaId: areaId,
+	}
+	lsDbEnt, exist := server.AreaLsdb[lsdbKey]
+	if !exist {
+		server.logger.Err(fmt.Sprintln("Unable to find Area Lsdb entry"))
+		return
+	}
+	server.electNssaTranslator(areaId)
+
+	areaIdKey := AreaIdKey{
+		AreaId: areaId,
+	}
+	rtrId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+	for lsaKey, lsaEnt := range lsDbEnt.NssaLsaMap {
+		server.logger.Info(fmt.Sprintln("NSSA LSAKey:", lsaKey, "lsaENt:", lsaEnt))
+		if lsaEnt.Metric == LSInfinity ||
+			lsaEnt.LsaMd.LSAge == config.MaxAge {
+			server.logger.Info("Ignoring NSSA LSA...")
+			continue
+		}
+		if lsaKey.AdvRouter == rtrId {
+			continue
+		}
+		if lsaKey.LSId == 0 && lsaEnt.Netmask == 0 &&
+			server.ospfGlobalConf.isABR {
+			// NSSA border routers ignore Type-7 default routes
+			continue
+		}
+		var rEnt RoutingTblEntry
+		if lsaEnt.FwdAddr == 0 {
+			rEnt, exist = server.getNssaRouterRoute(areaIdKey, lsaKey.AdvRouter)
+		} else {
+			rEnt, exist = server.getNssaFwdAddrRoute(areaIdKey, lsaEnt.FwdAddr)
+		}
+		if !exist {
+			server.logger.Info(fmt.Sprintln("NSSA: No intra area route to ASBR/forwarding address for ", lsaKey))
+			continue
+		}
+		server.calcASExternalRoute(areaIdKey, lsaKey, lsaEnt, rEnt)
+	}
+}
+
+/*
+@fn electNssaTranslator
+RFC 3101 3.1
+An NSSA border router configured as Always translates
+unconditionally. Candidates translate only if no other reachable
+NSSA border router has the Nt-bit set and theirs is the highest
+router id among the reachable NSSA border routers.
+*/
+func (server *OSPFServer) electNssaTranslator(areaId uint32) {
+	areaConfKey := AreaConfKey{
+		AreaId: config.AreaId(convertUint32ToIPv4(areaId)),
+	}
+	conf, exist := server.AreaConfMap[areaConfKey]
+	if !exist {
+		return
+	}
+	state := config.NssaTranslatorDisabled
+	if server.ospfGlobalConf.isABR {
+		if conf.AreaNssaTranslatorRole == config.Always {
+			state = config.NssaTranslatorEnabled
+		} else {
+			state = config.NssaTranslatorElected
+			lsdbKey := LsdbKey{
+				AreaId: areaId,
+			}
+			areaIdKey := AreaIdKey{
+				AreaId: areaId,
+			}
+			rtrId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+			lsDbEnt, _ := server.AreaLsdb[lsdbKey]
+			for lsaKey, lsaEnt := range lsDbEnt.RouterLsaMap {
+				if lsaKey.AdvRouter == rtrId || !lsaEnt.BitB ||
+					lsaEnt.LsaMd.LSAge == config.MaxAge {
+					continue
+				}
+				if _, reachable := server.getNssaRouterRoute(areaIdKey, lsaKey.AdvRouter); !reachable {
+					continue
+				}
+				if lsaEnt.BitNt || lsaKey.AdvRouter > rtrId {
+					state = config.NssaTranslatorDisabled
+					break
+				}
+			}
+		}
+	}
+	server.updateNssaTranslatorState(areaConfKey, state)
+}
+
+func (server *OSPFServer) updateNssaTranslatorState(key AreaConfKey, state config.NssaTranslatorState) {
+	ent, exist := server.AreaStateMap[key]
+	if !exist || ent.AreaNssaTranslatorState == state {
+		return
+	}
+	server.logger.Info(fmt.Sprintln("NSSA: Translator state change area ", key.AreaId,
+		" old ", ent.AreaNssaTranslatorState, " new ", state))
+	ent.AreaNssaTranslatorState = state
+	ent.AreaNssaTranslatorEvents++
+	server.AreaStateMap[key] = ent
+}
+
+func (server *OSPFServer) isNssaTranslator(key AreaConfKey) bool {
+	ent, exist := server.AreaStateMap[key]
+	if !exist {
+		return false
+	}
+	return ent.AreaNssaTranslatorState == config.NssaTranslatorEnabled ||
+		ent.AreaNssaTranslatorState == config.NssaTranslatorElected
+}
+
+/*
+@fn translateNssaLsa
+RFC 3101 3.2
+Translate Type-7 LSAs with the P-bit set and non-zero forwarding
+address into Type-5 LSAs for the areas where this router is the
+translator. Translated LSAs that are no longer needed are flushed.
+*/
+func (server *OSPFServer) translateNssaLsa() {
+	ifkey := IntfConfKey{}
+	nbr := NeighborConfKey{}
+	rtrId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+	selected := make(map[LsaKey]LsaKey)
+	selectedLsa := make(map[LsaKey]ASExternalLsa)
+	for key, _ := range server.AreaConfMap {
+		if !server.isNssaArea(key.AreaId) || !server.isNssaTranslator(key) {
+			continue
+		}
+		lsdbKey := LsdbKey{
+			AreaId: convertAreaOrRouterIdUint32(string(key.AreaId)),
+		}
+		lsDbEnt, exist := server.AreaLsdb[lsdbKey]
+		if !exist {
+			continue
+		}
+		for lsaKey, lsaEnt := range lsDbEnt.NssaLsaMap {
+			if lsaKey.AdvRouter == rtrId ||
+				lsaEnt.LsaMd.Options&NPOption == 0 ||
+				lsaEnt.FwdAddr == 0 ||
+				lsaEnt.Metric == LSInfinity ||
+				lsaEnt.LsaMd.LSAge == config.MaxAge ||
+				(lsaKey.LSId == 0 && lsaEnt.Netmask == 0) {
+				continue
+			}
+			extKey := LsaKey{
+				LSType:    ASExternalLSA,
+				LSId:      lsaKey.LSId & lsaEnt.Netmask,
+				AdvRouter: rtrId,
+			}
+			if bestKey, exist := selected[extKey]; exist &&
+				!isNssaLsaPreferred(lsaKey, lsaEnt, bestKey, selectedLsa[extKey]) {
+				continue
+			}
+			selected[extKey] = lsaKey
+			selectedLsa[extKey] = lsaEnt
+		}
+	}
+
+	translated := make(map[LsaKey]bool)
+	for extKey, lsaEnt := range selectedLsa {
+		if server.isLocalASExternalLsa(extKey) {
+			server.logger.Info(fmt.Sprintln("NSSA: Locally originated LSA takes precedence over translation ",
+				extKey))
+			continue
+		}
+		installed, changed := server.generateTranslatedASExternalLsa(extKey, lsaEnt)
+		if changed {
+			server.sendLsdbToNeighborEvent(ifkey, nbr, 0, 0, 0, extKey, LSAEXTFLOOD)
+		}
+		if installed {
+			translated[extKey] = true
+		}
+	}
+	flush := false
+	for extKey, _ := range server.NssaTranslatedLsaMap {
+		if !translated[extKey] {
+			server.flushTranslatedASExternalLsa(extKey)
+			flush = true
+		}
+	}
+	server.NssaTranslatedLsaMap = translated
+	if flush {
+		server.sendLsdbToNeighborEvent(ifkey, nbr, 0, 0, 0, LsaKey{}, LSAAGE)
+	}
+}
+
+/*
+@fn isNssaLsaPreferred
+RFC 3101 3.2
+Among the Type-7 LSAs for the same destination the translator
+uses the preference of section 2.5 (6): a type 1 external metric
+is preferred over a type 2 one, then the smaller metric. Remaining
+ties go to the higher advertising router so that the selection
+does not depend on the LSDB order.
+*/
+func isNssaLsaPreferred(lsaKey LsaKey, lsa ASExternalLsa, bestKey LsaKey, best ASExternalLsa) bool {
+	if lsa.BitE != best.BitE {
+		return !lsa.BitE
+	}
+	if lsa.Metric != best.Metric {
+		return lsa.Metric < best.Metric
+	}
+	if lsaKey.AdvRouter != bestKey.AdvRouter {
+		return lsaKey.AdvRouter > bestKey.AdvRouter
+	}
+	return lsa.FwdAddr > best.FwdAddr
+}
+
+/*
+@fn isLocalASExternalLsa
+Returns true if a Type-5 LSA is originated for a locally
+redistributed route. It takes precedence over the translated one.
+*/
+func (server *OSPFServer) isLocalASExternalLsa(lsaKey LsaKey) bool {
+	if server.NssaTranslatedLsaMap[lsaKey] {
+		return false
+	}
+	for lsdbKey, lsDbEnt := range server.AreaLsdb {
+		if _, exist := lsDbEnt.ASExternalLsaMap[lsaKey]; !exist {
+			continue
+		}
+		if server.AreaSelfOrigLsa[lsdbKey][lsaKey] {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+@fn generateTranslatedASExternalLsa
+Install Type-5 LSA translated from the Type-7 LSA in all
+non stub, non NSSA areas. Returns true if the LSA is installed
+in any area and true if the LSA changed.
+*/
+func (server *OSPFServer) generateTranslatedASExternalLsa(lsaKey LsaKey, nssaLsa ASExternalLsa) (bool, bool) {
+	installed := false
+	changed := false
+	for lsdbKey, lsDbEnt := range server.AreaLsdb {
+		areaId := config.AreaId(convertUint32ToIPv4(lsdbKey.AreaId))
+		if server.isStubArea(areaId) || server.isNssaArea(areaId) {
+			continue
+		}
+		installed = true
+		selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+		ent, exist := lsDbEnt.ASExternalLsaMap[lsaKey]
+		if exist && ent.LsaMd.LSAge != config.MaxAge &&
+			ent.BitE == nssaLsa.BitE &&
+			ent.Netmask == nssaLsa.Netmask &&
+			ent.Metric == nssaLsa.Metric &&
+			ent.FwdAddr == nssaLsa.FwdAddr &&
+			ent.ExtRouteTag == nssaLsa.ExtRouteTag {
+			continue
+		}
+		if !exist {
+			ent.LsaMd.LSSequenceNum = InitialSequenceNumber
+		} else {
+			ent.LsaMd.LSSequenceNum = ent.LsaMd.LSSequenceNum + 1
+		}
+		ent.LsaMd.LSAge = 0
+		ent.LsaMd.Options = 0x20
+		ent.LsaMd.LSChecksum = 0
+		ent.LsaMd.LSLen = uint16(OSPF_LSA_HEADER_SIZE + 16)
+		ent.BitE = nssaLsa.BitE
+		ent.Netmask = nssaLsa.Netmask
+		ent.Metric = nssaLsa.Metric
+		ent.FwdAddr = nssaLsa.FwdAddr
+		ent.ExtRouteTag = nssaLsa.ExtRouteTag
+
+		LsaEnc := encodeASExternalLsa(ent, lsaKey)
+		checksumOffset := uint16(14)
+		ent.LsaMd.LSChecksum = computeFletcherChecksum(LsaEnc[2:], checksumOffset)
+		lsDbEnt.ASExternalLsaMap[lsaKey] = ent
+		server.AreaLsdb[lsdbKey] = lsDbEnt
+		selfOrigLsaEnt[lsaKey] = true
+		server.AreaSelfOrigLsa[lsdbKey] = selfOrigLsaEnt
+		changed = true
+		server.logger.Info(fmt.Sprintln("NSSA: Translated Type-7 LSA to area ", lsdbKey, " lsaKey ", lsaKey))
+		if !exist {
+			var val LsdbSliceEnt
+			val.AreaId = lsdbKey.AreaId
+			val.LSType = lsaKey.LSType
+			val.LSId = lsaKey.LSId
+			val.AdvRtr = lsaKey.AdvRouter
+			server.LsdbSlice = append(server.LsdbSlice, val)
+			msg := DbLsdbMsg{
+				entry: val,
+				op:    true,
+			}
+			server.DbLsdbOp <- msg
+		}
+	}
+	return installed, changed
+}
+
+/*
+@fn flushTranslatedASExternalLsa
+Premature aging of the Type-5 LSA translated from a Type-7 LSA.
+Locally originated Type-5 LSAs are never flushed here.
+*/
+func (server *OSPFServer) flushTranslatedASExternalLsa(lsaKey LsaKey) {
+	if !server.NssaTranslatedLsaMap[lsaKey] {
+		return
+	}
+	for lsdbKey, lsDbEnt := range server.AreaLsdb {
+		lsa, exist := lsDbEnt.ASExternalLsaMap[lsaKey]
+		if !exist {
+			continue
+		}
+		server.logger.Info(fmt.Sprintln("FLUSH: Translated AS external lsa lsid ",
+			convertUint32ToIPv4(lsaKey.LSId), " area ", lsdbKey.AreaId))
+		lsa.LsaMd.LSAge = config.MaxAge
+		maxAgeLsaMap[lsaKey] = encodeASExternalLsa(lsa, lsaKey)
+		delete(lsDbEnt.ASExternalLsaMap, lsaKey)
+		server.AreaLsdb[lsdbKey] = lsDbEnt
+		selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+		delete(selfOrigLsaEnt, lsaKey)
+		server.AreaSelfOrigLsa[lsdbKey] = selfOrigLsaEnt
+	}
+}
+
+/*
+@fn processNssaLSAFlood
+Flood self originated Type-7 LSA within the NSSA.
+*/
+func (server *OSPFServer) processNssaLSAFlood(areaId uint32, lsaKey LsaKey) {
+	var lsaEncPkt []byte
+	entry, ret := server.getNssaLsaFromLsdb(areaId, lsaKey)
+	if ret == LsdbEntryNotFound {
+		server.logger.Info(fmt.Sprintln("NSSA: Lsa not found . Area",
+			areaId, " LSA key ", lsaKey))
+		return
+	}
+	LsaEnc := encodeASExternalLsa(entry, lsaKey)
+	checksumOffset := uint16(14)
+	checkSum := computeFletcherChecksum(LsaEnc[2:], checksumOffset)
+	binary.BigEndian.PutUint16(LsaEnc[16:18], checkSum)
+	no_lsas := uint32(1)
+	lsas_enc := make([]byte, 4)
+	binary.BigEndian.PutUint32(lsas_enc, no_lsas)
+	lsaEncPkt = append(lsaEncPkt, lsas_enc...)
+	lsaEncPkt = append(lsaEncPkt, LsaEnc...)
+	server.logger.Info(fmt.Sprintln("NSSA: flood lsid ", convertUint32ToIPv4(lsaKey.LSId),
+		" area ", areaId))
+	server.floodSummaryLsa(lsaEncPkt, areaId)
+}
+
+/*
+@fn generateDbNssaList
+Type-7 LSA headers for the database summary list of NSSA neighbors.
+*/
+func (server *OSPFServer) generateDbNssaList(self_areaId uint32) []*ospfNeighborDBSummary {
+	lsdbKey := LsdbKey{
+		AreaId: self_areaId,
+	}
+	area_lsa, exist := server.AreaLsdb[lsdbKey]
+	if !exist {
+		return nil
+	}
+	db_list := []*ospfNeighborDBSummary{}
+	for lsaKey, lsa := range area_lsa.NssaLsaMap {
+		db_nssa := newospfNeighborDBSummary()
+		db_nssa.lsa_headers = getLsaHeaderFromLsa(lsa.LsaMd.LSAge, lsa.LsaMd.Options,
+			NSSALSA, lsaKey.LSId, lsaKey.AdvRouter,
+			uint32(lsa.LsaMd.LSSequenceNum), lsa.LsaMd.LSChecksum,
+			lsa.LsaMd.LSLen)
+		db_nssa.valid = true
+		db_list = append(db_list, db_nssa)
+	}
+	return db_list
+}
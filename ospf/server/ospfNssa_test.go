@@ -0,0 +1,284 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"l3/ospf/config"
+	"testing"
+)
+
+func initNssaTestServer(role config.NssaTranslatorRole) *OSPFServer {
+	server := getAreaTestServer("0.0.0.1")
+	server.ospfGlobalConf.isABR = true
+	server.ospfGlobalConf.AreaBdrRtrStatus = true
+	nssaKey := AreaConfKey{
+		AreaId: "0.0.0.1",
+	}
+	conf := server.AreaConfMap[nssaKey]
+	conf.ImportAsExtern = config.ImportNssa
+	conf.AreaNssaTranslatorRole = role
+	server.AreaConfMap[nssaKey] = conf
+	server.initAreaStateSlice(nssaKey)
+	server.initLSDatabase(1)
+	server.TempAreaRoutingTbl = make(map[AreaIdKey]AreaRoutingTbl)
+	server.TempAreaRoutingTbl[AreaIdKey{AreaId: 1}] = AreaRoutingTbl{
+		RoutingTblMap: make(map[RoutingTblEntryKey]RoutingTblEntry),
+	}
+	return server
+}
+
+func addNssaTestBdrRtr(server *OSPFServer, rtrId uint32, bitNt bool) {
+	lsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      rtrId,
+		AdvRouter: rtrId,
+	}
+	server.AreaLsdb[LsdbKey{AreaId: 1}].RouterLsaMap[lsaKey] = RouterLsa{
+		BitB:  true,
+		BitNt: bitNt,
+	}
+	rKey := RoutingTblEntryKey{
+		DestId:   rtrId,
+		AddrMask: 0,
+		DestType: AreaBdrRouter,
+	}
+	server.TempAreaRoutingTbl[AreaIdKey{AreaId: 1}].RoutingTblMap[rKey] = RoutingTblEntry{
+		NumOfPaths: 1,
+	}
+}
+
+func TestNssaTranslatorElection(t *testing.T) {
+	nssaKey := AreaConfKey{
+		AreaId: "0.0.0.1",
+	}
+	tests := []struct {
+		role  config.NssaTranslatorRole
+		rtrId uint32
+		bitNt bool
+		state config.NssaTranslatorState
+	}{
+		{config.Candidate, 0, false, config.NssaTranslatorElected},
+		{config.Candidate, 0x0a000001, false, config.NssaTranslatorElected},
+		{config.Candidate, 0x0a000009, false, config.NssaTranslatorDisabled},
+		{config.Candidate, 0x0a000001, true, config.NssaTranslatorDisabled},
+		{config.Always, 0x0a000009, true, config.NssaTranslatorEnabled},
+	}
+	for idx, test := range tests {
+		server := initNssaTestServer(test.role)
+		if test.rtrId != 0 {
+			addNssaTestBdrRtr(server, test.rtrId, test.bitNt)
+		}
+		server.electNssaTranslator(1)
+		state := server.AreaStateMap[nssaKey]
+		if state.AreaNssaTranslatorState != test.state {
+			t.Errorf("%d: translator state %d, expected %d", idx, state.AreaNssaTranslatorState, test.state)
+		}
+		if test.state != config.NssaTranslatorDisabled &&
+			state.AreaNssaTranslatorEvents != 1 {
+			t.Errorf("%d: translator events %d, expected 1", idx, state.AreaNssaTranslatorEvents)
+		}
+	}
+
+	server := initNssaTestServer(config.Candidate)
+	server.electNssaTranslator(1)
+	server.ospfGlobalConf.isABR = false
+	server.electNssaTranslator(1)
+	state := server.AreaStateMap[nssaKey]
+	if state.AreaNssaTranslatorState != config.NssaTranslatorDisabled ||
+		state.AreaNssaTranslatorEvents != 2 {
+		t.Errorf("Translator not disabled after losing ABR status %+v", state)
+	}
+}
+
+func TestNssaOptions(t *testing.T) {
+	server := initNssaTestServer(config.Candidate)
+	intf := IntfConf{
+		IfAreaId: []byte{0, 0, 0, 1},
+	}
+	options := server.getIntfOptions(intf)
+	if options&NPOption == 0 || options&EOption != 0 {
+		t.Errorf("Invalid NSSA options %x", options)
+	}
+	intf.IfAreaId = []byte{0, 0, 0, 0}
+	if server.getIntfOptions(intf) != INTF_OPTIONS&^OOption {
+		t.Errorf("Invalid options %x for non NSSA area", server.getIntfOptions(intf))
+	}
+
+	lsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      0x0a000005,
+		AdvRouter: 0x0a000005,
+	}
+	lsa := RouterLsa{
+		BitNt: true,
+		BitB:  true,
+	}
+	lsa.LsaMd.LSLen = uint16(OSPF_LSA_HEADER_SIZE + 4)
+	data := encodeRouterLsa(lsa, lsaKey)
+	dLsa := NewRouterLsa()
+	dLsaKey := NewLsaKey()
+	decodeRouterLsa(data, dLsa, dLsaKey)
+	if !dLsa.BitNt || !dLsa.BitB || dLsa.BitE {
+		t.Errorf("Router LSA Nt bit not preserved %+v", dLsa)
+	}
+}
+
+func initNssaTranslateTestServer() *OSPFServer {
+	server := initNssaTestServer(config.Always)
+	server.AreaConfMap[AreaConfKey{AreaId: "0.0.0.0"}] = AreaConf{
+		ImportAsExtern: config.ImportExternal,
+		IntfListMap:    make(map[IntfConfKey]bool),
+	}
+	server.initLSDatabase(0)
+	server.InitDBChannels()
+	maxAgeLsaMap = make(map[LsaKey][]byte)
+	go startDummyChannels(server)
+	server.electNssaTranslator(1)
+	return server
+}
+
+func addNssaTestLsa(server *OSPFServer, advRtr uint32, lsId uint32, bitE bool, metric uint32) LsaKey {
+	lsaKey := LsaKey{
+		LSType:    NSSALSA,
+		LSId:      lsId,
+		AdvRouter: advRtr,
+	}
+	var lsa ASExternalLsa
+	lsa.LsaMd.Options = NPOption
+	lsa.BitE = bitE
+	lsa.Netmask = 0xffffff00
+	lsa.Metric = metric
+	lsa.FwdAddr = advRtr
+	server.AreaLsdb[LsdbKey{AreaId: 1}].NssaLsaMap[lsaKey] = lsa
+	return lsaKey
+}
+
+func getNssaTestExtLsa(server *OSPFServer, lsId uint32) (ASExternalLsa, bool) {
+	lsaKey := LsaKey{
+		LSType:    ASExternalLSA,
+		LSId:      lsId,
+		AdvRouter: 0x0a000005,
+	}
+	lsa, exist := server.AreaLsdb[LsdbKey{AreaId: 0}].ASExternalLsaMap[lsaKey]
+	return lsa, exist
+}
+
+func TestNssaTranslatePreference(t *testing.T) {
+	server := initNssaTranslateTestServer()
+	addNssaTestLsa(server, 0x0a000008, 0x0a010100, true, 20)
+	addNssaTestLsa(server, 0x0a000009, 0x0a010100, true, 10)
+	addNssaTestLsa(server, 0x0a000007, 0x0a010200, true, 10)
+	addNssaTestLsa(server, 0x0a000008, 0x0a010200, false, 30)
+	addNssaTestLsa(server, 0x0a000007, 0x0a010300, true, 10)
+	addNssaTestLsa(server, 0x0a000009, 0x0a010300, true, 10)
+	server.translateNssaLsa()
+
+	tests := []struct {
+		lsId    uint32
+		fwdAddr uint32
+	}{
+		{0x0a010100, 0x0a000009},
+		{0x0a010200, 0x0a000008},
+		{0x0a010300, 0x0a000009},
+	}
+	for _, test := range tests {
+		lsa, exist := getNssaTestExtLsa(server, test.lsId)
+		if !exist {
+			t.Errorf("Type-7 LSA %x not translated", test.lsId)
+			continue
+		}
+		if lsa.FwdAddr != test.fwdAddr {
+			t.Errorf("Translated %x from %x, expected %x", test.lsId, lsa.FwdAddr, test.fwdAddr)
+		}
+	}
+}
+
+func TestNssaTranslateLocalPrecedence(t *testing.T) {
+	server := initNssaTranslateTestServer()
+	route := RouteMdata{
+		ipaddr: 0x0a010100,
+		mask:   0xffffff00,
+		metric: 50,
+	}
+	server.generateASExternalLsa(route)
+	addNssaTestLsa(server, 0x0a000009, 0x0a010100, true, 10)
+	server.translateNssaLsa()
+	lsa, exist := getNssaTestExtLsa(server, 0x0a010100)
+	if !exist || lsa.Metric != 50 || lsa.FwdAddr != 0 {
+		t.Errorf("Local Type-5 LSA overwritten by translation %+v", lsa)
+	}
+	if len(server.NssaTranslatedLsaMap) != 0 {
+		t.Errorf("Local Type-5 LSA marked as translated %v", server.NssaTranslatedLsaMap)
+	}
+
+	route.isDel = true
+	server.generateASExternalLsa(route)
+	server.translateNssaLsa()
+	lsa, exist = getNssaTestExtLsa(server, 0x0a010100)
+	if !exist || lsa.Metric != 10 || lsa.FwdAddr != 0x0a000009 {
+		t.Errorf("Type-7 LSA not translated after local route removal %+v", lsa)
+	}
+
+	route.isDel = false
+	server.generateASExternalLsa(route)
+	server.translateNssaLsa()
+	lsa, exist = getNssaTestExtLsa(server, 0x0a010100)
+	if !exist || lsa.Metric != 50 || lsa.FwdAddr != 0 {
+		t.Errorf("Local Type-5 LSA flushed as translated %+v", lsa)
+	}
+}
+
+func TestNssaTranslateFlush(t *testing.T) {
+	server := initNssaTranslateTestServer()
+	server.generateASExternalLsa(RouteMdata{
+		ipaddr: 0x0a020000,
+		mask:   0xffffff00,
+		metric: 50,
+	})
+	lsaKey := addNssaTestLsa(server, 0x0a000009, 0x0a010100, true, 10)
+	server.translateNssaLsa()
+	if _, exist := getNssaTestExtLsa(server, 0x0a010100); !exist {
+		t.Fatalf("Type-7 LSA not translated")
+	}
+
+	delete(server.AreaLsdb[LsdbKey{AreaId: 1}].NssaLsaMap, lsaKey)
+	server.translateNssaLsa()
+	if _, exist := getNssaTestExtLsa(server, 0x0a010100); exist {
+		t.Errorf("Translated LSA not flushed after Type-7 LSA withdrawal")
+	}
+	extKey := LsaKey{
+		LSType:    ASExternalLSA,
+		LSId:      0x0a010100,
+		AdvRouter: 0x0a000005,
+	}
+	if _, exist := maxAgeLsaMap[extKey]; !exist {
+		t.Errorf("Translated LSA not prematurely aged")
+	}
+	if len(server.NssaTranslatedLsaMap) != 0 {
+		t.Errorf("Translated LSA not removed %v", server.NssaTranslatedLsaMap)
+	}
+	if _, exist := getNssaTestExtLsa(server, 0x0a020000); !exist {
+		t.Errorf("Local Type-5 LSA flushed with the translated LSA")
+	}
+}
@@ -0,0 +1,618 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"net"
+)
+
+/*
+	RFC 5250 The OSPF Opaque LSA Option
+
+	The flooding scope of an opaque LSA is given by its LS type.
+	Type-9 LSAs are link-local and kept per interface in IntfOpaqueLsdb.
+	Type-10 LSAs are area-local and kept in LSDatabase.OpaqueLsaMap.
+	Type-11 LSAs are flooded like AS-external-LSAs and are not
+	accepted in stub areas and NSSAs.
+	Opaque LSAs are only exchanged with neighbors that set the O-bit
+	in their database description packets.
+
+	Applications register for an opaque type to originate LSAs and
+	to be notified of the opaque LSAs received from other routers.
+*/
+
+type OpaqueApp interface {
+	OpaqueLsaUpdate(areaId uint32, intfKey IntfConfKey, lsaKey LsaKey, info []byte, isDel bool)
+}
+
+/* Opaque LSA origination request from an application */
+type OpaqueLsaMsg struct {
+	LSType     uint8
+	OpaqueType uint8
+	OpaqueId   uint32
+	AreaId     uint32      // area of type-10 LSA
+	IntfKey    IntfConfKey // link of type-9 LSA
+	Info       []byte
+	IsDel      bool
+}
+
+const (
+	MaxOpaqueId uint32 = 0xffffff
+)
+
+func isOpaqueLsa(lsType uint8) bool {
+	return lsType == LocalOpaqueLSA || lsType == AreaOpaqueLSA ||
+		lsType == ASOpaqueLSA
+}
+
+func getOpaqueType(lsId uint32) uint8 {
+	return uint8(lsId >> 24)
+}
+
+func getOpaqueId(lsId uint32) uint32 {
+	return lsId & MaxOpaqueId
+}
+
+func buildOpaqueLsId(opaqueType uint8, opaqueId uint32) uint32 {
+	return uint32(opaqueType)<<24 | (opaqueId & MaxOpaqueId)
+}
+
+/*
+@fn RegisterOpaqueApp
+Register application for the opaque type.
+*/
+func (server *OSPFServer) RegisterOpaqueApp(opaqueType uint8, app OpaqueApp) error {
+	server.opaqueAppMutex.Lock()
+	defer server.opaqueAppMutex.Unlock()
+	if _, exist := server.opaqueAppMap[opaqueType]; exist {
+		return errors.New(fmt.Sprintln("Opaque type", opaqueType, "is already registered"))
+	}
+	server.opaqueAppMap[opaqueType] = app
+	return nil
+}
+
+/*
+@fn UnregisterOpaqueApp
+The application is expected to withdraw its LSAs before
+unregistering.
+*/
+func (server *OSPFServer) UnregisterOpaqueApp(opaqueType uint8) {
+	server.opaqueAppMutex.Lock()
+	delete(server.opaqueAppMap, opaqueType)
+	server.opaqueAppMutex.Unlock()
+}
+
+/*
+@fn notifyOpaqueApp
+Pass opaque LSA received from other routers to the registered application.
+intfKey is the link of type-9 LSAs.
+*/
+func (server *OSPFServer) notifyOpaqueApp(areaId uint32, intfKey IntfConfKey, lsaKey LsaKey, info []byte, isDel bool) {
+	if server.selfGenLsaCheck(lsaKey) {
+		return
+	}
+	server.opaqueAppMutex.RLock()
+	app, exist := server.opaqueAppMap[getOpaqueType(lsaKey.LSId)]
+	server.opaqueAppMutex.RUnlock()
+	if !exist {
+		return
+	}
+	app.OpaqueLsaUpdate(areaId, intfKey, lsaKey, info, isDel)
+}
+
+/*
+@fn OriginateOpaqueLsa
+Originate, refresh or withdraw opaque LSA of a registered application.
+*/
+func (server *OSPFServer) OriginateOpaqueLsa(msg OpaqueLsaMsg) error {
+	if !server.ospfGlobalConf.OpaqueLsaSupport {
+		return errors.New("Opaque LSA support is disabled")
+	}
+	if !isOpaqueLsa(msg.LSType) {
+		return errors.New(fmt.Sprintln("Invalid opaque LS type", msg.LSType))
+	}
+	if msg.OpaqueId > MaxOpaqueId {
+		return errors.New(fmt.Sprintln("Invalid opaque id", msg.OpaqueId))
+	}
+	server.opaqueAppMutex.RLock()
+	_, exist := server.opaqueAppMap[msg.OpaqueType]
+	server.opaqueAppMutex.RUnlock()
+	if !exist {
+		return errors.New(fmt.Sprintln("Opaque type", msg.OpaqueType, "is not registered"))
+	}
+	server.OpaqueLsaCh <- msg
+	return nil
+}
+
+/*
+@fn processOpaqueLsaMsg
+Install the opaque LSA in all the LSDBs of its flooding scope
+and flood it. Unchanged LSAs are not reoriginated.
+*/
+func (server *OSPFServer) processOpaqueLsaMsg(msg OpaqueLsaMsg) {
+	ifkey := IntfConfKey{}
+	nbr := NeighborConfKey{}
+	lsaKey := LsaKey{
+		LSType:    msg.LSType,
+		LSId:      buildOpaqueLsId(msg.OpaqueType, msg.OpaqueId),
+		AdvRouter: convertIPv4ToUint32(server.ospfGlobalConf.RouterId),
+	}
+	var areaList []uint32
+	switch msg.LSType {
+	case LocalOpaqueLSA:
+		intf, exist := server.IntfConfMap[msg.IntfKey]
+		if !exist {
+			server.logger.Info(fmt.Sprintln("OPAQUE: Interface doesnt exist ", msg.IntfKey))
+			return
+		}
+		areaList = append(areaList, convertIPv4ToUint32(intf.IfAreaId))
+	case AreaOpaqueLSA:
+		areaList = append(areaList, msg.AreaId)
+	case ASOpaqueLSA:
+		for lsdbKey, _ := range server.AreaLsdb {
+			areaId := config.AreaId(convertUint32ToIPv4(lsdbKey.AreaId))
+			if server.isStubArea(areaId) || server.isNssaArea(areaId) {
+				continue
+			}
+			areaList = append(areaList, lsdbKey.AreaId)
+		}
+	}
+	for _, areaId := range areaList {
+		lsdbKey := LsdbKey{
+			AreaId: areaId,
+		}
+		lsa, ret := server.getOpaqueLsaFromLsdb(areaId, msg.IntfKey, lsaKey)
+		if !msg.IsDel && ret == LsdbEntryFound && bytes.Equal(lsa.Info, msg.Info) {
+			continue
+		}
+		if !server.installSelfOpaqueLsa(lsdbKey, msg.IntfKey, lsaKey, msg.Info, msg.IsDel) {
+			continue
+		}
+		if msg.IsDel {
+			server.sendLsdbToNeighborEvent(ifkey, nbr, areaId, 0, 0, lsaKey, LSAAGE)
+		} else {
+			server.sendLsdbToNeighborEvent(msg.IntfKey, nbr, areaId, 0, 0, lsaKey, LSAOPAQUEFLOOD)
+		}
+	}
+}
+
+/*
+@fn getOpaqueLsdb
+Opaque LSAs of the scope of the LS type.
+*/
+func (server *OSPFServer) getOpaqueLsdb(lsdbKey LsdbKey, intfKey IntfConfKey, lsType uint8) OpaqueLsdb {
+	if lsType == LocalOpaqueLSA {
+		return server.IntfOpaqueLsdb[intfKey]
+	}
+	lsDbEnt, _ := server.AreaLsdb[lsdbKey]
+	return lsDbEnt.OpaqueLsaMap
+}
+
+func (server *OSPFServer) initIntfOpaqueLsdb(intfKey IntfConfKey) {
+	if _, exist := server.IntfOpaqueLsdb[intfKey]; !exist {
+		server.IntfOpaqueLsdb[intfKey] = make(OpaqueLsdb)
+	}
+}
+
+func (server *OSPFServer) getOpaqueLsaFromLsdb(areaId uint32, intfKey IntfConfKey, lsaKey LsaKey) (lsa OpaqueLsa, retVal int) {
+	lsdbKey := LsdbKey{
+		AreaId: areaId,
+	}
+	opaqueLsdb := server.getOpaqueLsdb(lsdbKey, intfKey, lsaKey.LSType)
+	lsa, exist := opaqueLsdb[lsaKey]
+	if !exist {
+		return lsa, LsdbEntryNotFound
+	}
+	return lsa, LsdbEntryFound
+}
+
+/*
+@fn getOpaqueLsaOptions
+Options of self originated opaque LSAs.
+*/
+func (server *OSPFServer) getOpaqueLsaOptions(areaId uint32) uint8 {
+	options := uint8(INTF_OPTIONS | OOption)
+	area := config.AreaId(convertUint32ToIPv4(areaId))
+	if server.isStubArea(area) || server.isNssaArea(area) {
+		options = options &^ EOption
+	}
+	return options
+}
+
+/*
+@fn installSelfOpaqueLsa
+Add (or refresh) a self originated opaque LSA.
+When isDel is set the LSA is flushed.
+*/
+func (server *OSPFServer) installSelfOpaqueLsa(lsdbKey LsdbKey, intfKey IntfConfKey, lsaKey LsaKey, info []byte, isDel bool) bool {
+	if _, exist := server.AreaLsdb[lsdbKey]; !exist {
+		server.logger.Warning(fmt.Sprintln("OPAQUE: Area LSDB doesnt exist ", lsdbKey))
+		return false
+	}
+	if lsaKey.LSType == LocalOpaqueLSA {
+		server.initIntfOpaqueLsdb(intfKey)
+	}
+	opaqueLsdb := server.getOpaqueLsdb(lsdbKey, intfKey, lsaKey.LSType)
+	selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+	ent, exist := opaqueLsdb[lsaKey]
+	if isDel {
+		if !exist {
+			return false
+		}
+		server.flushOpaqueLsa(lsdbKey, intfKey, lsaKey)
+		return true
+	}
+	if !exist {
+		ent.LsaMd.LSSequenceNum = InitialSequenceNumber
+	} else {
+		ent.LsaMd.LSSequenceNum = ent.LsaMd.LSSequenceNum + 1
+	}
+	ent.LsaMd.LSAge = 0
+	ent.LsaMd.Options = server.getOpaqueLsaOptions(lsdbKey.AreaId)
+	ent.LsaMd.LSChecksum = 0
+	ent.LsaMd.LSLen = uint16(OSPF_LSA_HEADER_SIZE + len(info))
+	ent.Info = make([]byte, len(info))
+	copy(ent.Info, info)
+
+	LsaEnc := encodeOpaqueLsa(ent, lsaKey)
+	checksumOffset := uint16(14)
+	ent.LsaMd.LSChecksum = computeFletcherChecksum(LsaEnc[2:], checksumOffset)
+	opaqueLsdb[lsaKey] = ent
+	selfOrigLsaEnt[lsaKey] = true
+	server.AreaSelfOrigLsa[lsdbKey] = selfOrigLsaEnt
+	server.logger.Info(fmt.Sprintln("OPAQUE: Added LSA to area ", lsdbKey, " lsaKey ", lsaKey))
+	if !exist {
+		var val LsdbSliceEnt
+		val.AreaId = lsdbKey.AreaId
+		val.LSType = lsaKey.LSType
+		val.LSId = lsaKey.LSId
+		val.AdvRtr = lsaKey.AdvRouter
+		if lsaKey.LSType == LocalOpaqueLSA {
+			val.IntfKey = intfKey
+		}
+		server.LsdbSlice = append(server.LsdbSlice, val)
+		msg := DbLsdbMsg{
+			entry: val,
+			op:    true,
+		}
+		server.DbLsdbOp <- msg
+	}
+	return true
+}
+
+/*
+@fn flushOpaqueLsa
+Premature aging of self originated opaque LSA.
+*/
+func (server *OSPFServer) flushOpaqueLsa(lsdbKey LsdbKey, intfKey IntfConfKey, lsaKey LsaKey) {
+	opaqueLsdb := server.getOpaqueLsdb(lsdbKey, intfKey, lsaKey.LSType)
+	selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+	lsa, exist := opaqueLsdb[lsaKey]
+	if !exist {
+		return
+	}
+	server.logger.Info(fmt.Sprintln("FLUSH: Opaque lsa lsid ",
+		convertUint32ToIPv4(lsaKey.LSId), " type ", lsaKey.LSType, " area ", lsdbKey.AreaId))
+	lsa.LsaMd.LSAge = config.MaxAge
+	maxAgeLsaMap[lsaKey] = encodeOpaqueLsa(lsa, lsaKey)
+	delete(opaqueLsdb, lsaKey)
+	if lsaKey.LSType == LocalOpaqueLSA &&
+		len(server.getLocalOpaqueLsaIntfs(lsdbKey.AreaId, lsaKey)) > 0 {
+		// still originated on other links of the area
+		return
+	}
+	delete(selfOrigLsaEnt, lsaKey)
+	server.AreaSelfOrigLsa[lsdbKey] = selfOrigLsaEnt
+}
+
+/*
+@fn getLocalOpaqueLsaIntfs
+Links of the area on which the type-9 LSA is present.
+*/
+func (server *OSPFServer) getLocalOpaqueLsaIntfs(areaId uint32, lsaKey LsaKey) []IntfConfKey {
+	var intfList []IntfConfKey
+	for intfKey, opaqueLsdb := range server.IntfOpaqueLsdb {
+		intf, exist := server.IntfConfMap[intfKey]
+		if !exist || convertIPv4ToUint32(intf.IfAreaId) != areaId {
+			continue
+		}
+		if _, exist := opaqueLsdb[lsaKey]; exist {
+			intfList = append(intfList, intfKey)
+		}
+	}
+	return intfList
+}
+
+/*
+@fn processRecvdOpaqueLsa
+Install opaque LSA received from the neighbor on the interface.
+*/
+func (server *OSPFServer) processRecvdOpaqueLsa(data []byte, areaId uint32, intfKey IntfConfKey) bool {
+	lsakey := NewLsaKey()
+	opaqueLsa := NewOpaqueLsa()
+	lsdbKey := LsdbKey{
+		AreaId: areaId,
+	}
+	if !server.ospfGlobalConf.OpaqueLsaSupport {
+		server.logger.Err("Recvd opaque LSA. Opaque LSA support is disabled")
+		return false
+	}
+	decodeOpaqueLsa(data, opaqueLsa, lsakey)
+	if lsakey.LSType == ASOpaqueLSA {
+		area := config.AreaId(convertUint32ToIPv4(areaId))
+		if server.isStubArea(area) || server.isNssaArea(area) {
+			server.logger.Err(fmt.Sprintln("Recvd AS opaque LSA for stub/NSSA area ", areaId))
+			return false
+		}
+	}
+	selfOrigLsaEnt, _ := server.AreaSelfOrigLsa[lsdbKey]
+	_, exist := selfOrigLsaEnt[*lsakey]
+	if exist {
+		server.logger.Info("Recvd a self generated opaque LSA")
+		return false
+	}
+
+	//Check Checksum
+	csum := computeFletcherChecksum(data[2:], FLETCHER_CHECKSUM_VALIDATE)
+	if csum != 0 {
+		server.logger.Err("Invalid opaque LSA Checksum")
+		return false
+	}
+	if _, exist := server.AreaLsdb[lsdbKey]; !exist {
+		return false
+	}
+	if lsakey.LSType == LocalOpaqueLSA {
+		server.initIntfOpaqueLsdb(intfKey)
+	}
+	opaqueLsdb := server.getOpaqueLsdb(lsdbKey, intfKey, lsakey.LSType)
+	ent, exist := opaqueLsdb[*lsakey]
+	if exist {
+		if ent.LsaMd.LSSequenceNum >= opaqueLsa.LsaMd.LSSequenceNum {
+			server.logger.Err("Old instance of opaque LSA Recvd")
+			return false
+		}
+	}
+	opaqueLsdb[*lsakey] = *opaqueLsa
+	if !exist {
+		var val LsdbSliceEnt
+		val.AreaId = lsdbKey.AreaId
+		val.LSType = lsakey.LSType
+		val.LSId = lsakey.LSId
+		val.AdvRtr = lsakey.AdvRouter
+		if lsakey.LSType == LocalOpaqueLSA {
+			val.IntfKey = intfKey
+		}
+		server.LsdbSlice = append(server.LsdbSlice, val)
+		msg := DbLsdbMsg{
+			entry: val,
+			op:    true,
+		}
+		server.DbLsdbOp <- msg
+	}
+	// premature aging withdraws the LSA
+	isDel := opaqueLsa.LsaMd.LSAge == config.MaxAge
+	server.notifyOpaqueApp(areaId, intfKey, *lsakey, opaqueLsa.Info, isDel)
+	return true
+}
+
+func (server *OSPFServer) processDeleteOpaqueLsa(data []byte, areaId uint32, intfKey IntfConfKey) bool {
+	lsakey := NewLsaKey()
+	var val LsdbSliceEnt
+	opaqueLsa := NewOpaqueLsa()
+	lsdbKey := LsdbKey{
+		AreaId: areaId,
+	}
+	decodeOpaqueLsa(data, opaqueLsa, lsakey)
+	opaqueLsdb := server.getOpaqueLsdb(lsdbKey, intfKey, lsakey.LSType)
+	if _, exist := opaqueLsdb[*lsakey]; !exist {
+		return false
+	}
+	delete(opaqueLsdb, *lsakey)
+	server.notifyOpaqueApp(areaId, intfKey, *lsakey, opaqueLsa.Info, true)
+
+	val.AreaId = lsdbKey.AreaId
+	val.LSType = lsakey.LSType
+	val.LSId = lsakey.LSId
+	val.AdvRtr = lsakey.AdvRouter
+	err := server.DelLsdbEntry(val)
+	if err != nil {
+		server.logger.Info(fmt.Sprintln("DB: Failed to delete entry from db ", lsakey))
+	}
+	return true
+}
+
+/*
+@fn processOpaqueLsdbUpdate
+Opaque LSAs dont change the topology so SPF is not run.
+*/
+func (server *OSPFServer) processOpaqueLsdbUpdate(msg LsdbUpdateMsg) bool {
+	if msg.MsgType == LsdbDel {
+		return server.processDeleteOpaqueLsa(msg.Data, msg.AreaId, msg.IntfKey)
+	}
+	return server.processRecvdOpaqueLsa(msg.Data, msg.AreaId, msg.IntfKey)
+}
+
+/*
+@fn processMaxAgeIntfOpaqueLSA
+Age the link-local opaque LSAs of the interface.
+*/
+func (server *OSPFServer) processMaxAgeIntfOpaqueLSA(intfKey IntfConfKey, opaqueLsdb OpaqueLsdb) {
+	flood_lsa := false
+	intf, exist := server.IntfConfMap[intfKey]
+	if !exist {
+		delete(server.IntfOpaqueLsdb, intfKey)
+		return
+	}
+	areaId := convertIPv4ToUint32(intf.IfAreaId)
+	for lsakey, lsa := range opaqueLsdb {
+		if lsa.LsaMd.LSAge == config.MaxAge {
+			lsa_pkt := encodeOpaqueLsa(lsa, lsakey)
+			maxAgeLsaMap[lsakey] = lsa_pkt
+			delete(opaqueLsdb, lsakey)
+			advRouter := convertUint32ToIPv4(lsakey.AdvRouter)
+			lsid := convertUint32ToIPv4(lsakey.LSId)
+			server.logger.Info(fmt.Sprintln("DELETE: Max age reached. adv_router ",
+				advRouter, " lstype ", lsakey.LSType, " lsid ", lsid, " intf ", intfKey))
+			server.notifyOpaqueApp(areaId, intfKey, lsakey, lsa.Info, true)
+			flood_lsa = true
+		} else {
+			lsa.LsaMd.LSAge++
+			opaqueLsdb[lsakey] = lsa
+		}
+	}
+	if flood_lsa {
+		flood_pkt := ospfFloodMsg{
+			lsOp: LSAAGE,
+		}
+		server.ospfNbrLsaUpdSendCh <- flood_pkt
+	}
+}
+
+/*
+@fn regenerateOpaqueLsa
+Refresh self originated opaque LSA on every LSRefreshTime.
+*/
+func (server *OSPFServer) regenerateOpaqueLsa(lsdbKey LsdbKey, lsaKey LsaKey) {
+	nbr := NeighborConfKey{}
+	if lsaKey.LSType == LocalOpaqueLSA {
+		for _, intfKey := range server.getLocalOpaqueLsaIntfs(lsdbKey.AreaId, lsaKey) {
+			lsa, _ := server.getOpaqueLsaFromLsdb(lsdbKey.AreaId, intfKey, lsaKey)
+			server.installSelfOpaqueLsa(lsdbKey, intfKey, lsaKey, lsa.Info, false)
+			server.sendLsdbToNeighborEvent(intfKey, nbr, lsdbKey.AreaId, 0, 0, lsaKey, LSAOPAQUEFLOOD)
+		}
+		return
+	}
+	lsa, ret := server.getOpaqueLsaFromLsdb(lsdbKey.AreaId, IntfConfKey{}, lsaKey)
+	if ret == LsdbEntryNotFound {
+		return
+	}
+	server.installSelfOpaqueLsa(lsdbKey, IntfConfKey{}, lsaKey, lsa.Info, false)
+}
+
+/*
+@fn opaqueFloodCheck
+Opaque LSAs are flooded on the interface only if a neighbor
+in exchange or higher state is opaque capable.
+*/
+func (server *OSPFServer) opaqueFloodCheck(key IntfConfKey) bool {
+	if !server.ospfGlobalConf.OpaqueLsaSupport {
+		return false
+	}
+	nbrData, exist := ospfIntfToNbrMap[key]
+	if !exist {
+		return false
+	}
+	for _, nbrKey := range nbrData.nbrList {
+		nbrConf := server.NeighborConfigMap[nbrKey]
+		if nbrConf.OspfNbrState >= config.NbrExchange &&
+			uint8(nbrConf.OspfNbrOptions)&OOption != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+@fn processOpaqueLSAFlood
+Flood self originated opaque LSA within its flooding scope.
+*/
+func (server *OSPFServer) processOpaqueLSAFlood(areaId uint32, intfKey IntfConfKey, lsaKey LsaKey) {
+	dstMac := net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x05}
+	dstIp := net.IP{224, 0, 0, 5}
+	var lsaEncPkt []byte
+	entry, ret := server.getOpaqueLsaFromLsdb(areaId, intfKey, lsaKey)
+	if ret == LsdbEntryNotFound {
+		server.logger.Info(fmt.Sprintln("OPAQUE: Lsa not found . Area",
+			areaId, " LSA key ", lsaKey))
+		return
+	}
+	LsaEnc := encodeOpaqueLsa(entry, lsaKey)
+	checksumOffset := uint16(14)
+	checkSum := computeFletcherChecksum(LsaEnc[2:], checksumOffset)
+	binary.BigEndian.PutUint16(LsaEnc[16:18], checkSum)
+	no_lsas := uint32(1)
+	lsas_enc := make([]byte, 4)
+	binary.BigEndian.PutUint32(lsas_enc, no_lsas)
+	lsaEncPkt = append(lsaEncPkt, lsas_enc...)
+	lsaEncPkt = append(lsaEncPkt, LsaEnc...)
+	for key, intf := range server.IntfConfMap {
+		if lsaKey.LSType == LocalOpaqueLSA && key != intfKey {
+			continue
+		}
+		if convertIPv4ToUint32(intf.IfAreaId) != areaId ||
+			!server.opaqueFloodCheck(key) {
+			continue
+		}
+		pkt := server.BuildLsaUpdPkt(key, intf, dstMac, dstIp, len(lsaEncPkt), lsaEncPkt)
+		server.logger.Info(fmt.Sprintln("OPAQUE: flood lsid ", convertUint32ToIPv4(lsaKey.LSId),
+			" type ", lsaKey.LSType, " intf ", intf.IfIpAddr))
+		server.SendOspfPkt(key, pkt)
+	}
+}
+
+/*
+@fn generateDbOpaqueList
+Opaque LSA headers for the database summary list of opaque capable
+neighbors.
+*/
+func (server *OSPFServer) generateDbOpaqueList(self_areaId uint32, intfKey IntfConfKey) []*ospfNeighborDBSummary {
+	lsdbKey := LsdbKey{
+		AreaId: self_areaId,
+	}
+	area_lsa, exist := server.AreaLsdb[lsdbKey]
+	if !exist {
+		return nil
+	}
+	db_list := []*ospfNeighborDBSummary{}
+	for _, opaqueLsdb := range []OpaqueLsdb{area_lsa.OpaqueLsaMap, server.IntfOpaqueLsdb[intfKey]} {
+		for lsaKey, lsa := range opaqueLsdb {
+			db_opaque := newospfNeighborDBSummary()
+			db_opaque.lsa_headers = getLsaHeaderFromLsa(lsa.LsaMd.LSAge, lsa.LsaMd.Options,
+				lsaKey.LSType, lsaKey.LSId, lsaKey.AdvRouter,
+				uint32(lsa.LsaMd.LSSequenceNum), lsa.LsaMd.LSChecksum,
+				lsa.LsaMd.LSLen)
+			db_opaque.valid = true
+			db_list = append(db_list, db_opaque)
+		}
+	}
+	return db_list
+}
+
+/*
+@fn updateNbrOptions
+Options of the neighbor learnt from its database description packets.
+*/
+func (server *OSPFServer) updateNbrOptions(nbrKey NeighborConfKey, options uint8) {
+	nbrConf, exist := server.NeighborConfigMap[nbrKey]
+	if !exist {
+		return
+	}
+	nbrConf.OspfNbrOptions = int(options)
+	server.NeighborConfigMap[nbrKey] = nbrConf
+}
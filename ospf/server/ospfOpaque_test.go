@@ -0,0 +1,101 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOpaqueLsaEncodeDecode(t *testing.T) {
+	lsaKey := LsaKey{
+		LSType:    AreaOpaqueLSA,
+		LSId:      buildOpaqueLsId(TEOpaqueType, 5),
+		AdvRouter: 0x0a000001,
+	}
+	lsa := OpaqueLsa{
+		Info: []byte{0, 1, 0, 4, 10, 0, 0, 1},
+	}
+	lsa.LsaMd.LSSequenceNum = InitialSequenceNumber
+	lsa.LsaMd.LSLen = uint16(OSPF_LSA_HEADER_SIZE + len(lsa.Info))
+	data := encodeOpaqueLsa(lsa, lsaKey)
+	dLsa := NewOpaqueLsa()
+	dLsaKey := NewLsaKey()
+	decodeOpaqueLsa(data, dLsa, dLsaKey)
+	if *dLsaKey != lsaKey || !bytes.Equal(dLsa.Info, lsa.Info) {
+		t.Errorf("Opaque LSA not preserved %+v %+v", dLsaKey, dLsa)
+	}
+	if getOpaqueType(dLsaKey.LSId) != TEOpaqueType || getOpaqueId(dLsaKey.LSId) != 5 {
+		t.Errorf("Invalid opaque type/id for lsid %x", dLsaKey.LSId)
+	}
+}
+
+func TestTELsaEncodeDecode(t *testing.T) {
+	link := TELinkInfo{
+		LinkType:   TELinkP2P,
+		LinkId:     0x0a000002,
+		LocalAddr:  0x0b000001,
+		RemoteAddr: 0x0b000002,
+		TEMetric:   20,
+		MaxBw:      1000 * TE_BYTES_PER_MBIT,
+		MaxRsvBw:   800 * TE_BYTES_PER_MBIT,
+		AdminGroup: 0x5,
+	}
+	for i := range link.UnrsvBw {
+		link.UnrsvBw[i] = link.MaxRsvBw
+	}
+	var teInfo TELsaInfo
+	decodeTELsa(encodeTELinkTlv(link), &teInfo)
+	if len(teInfo.Links) != 1 || teInfo.Links[0] != link {
+		t.Errorf("TE link not preserved %+v", teInfo)
+	}
+
+	teInfo = TELsaInfo{}
+	decodeTELsa(encodeTERouterAddrTlv(0x0a000001), &teInfo)
+	if teInfo.RouterAddr != 0x0a000001 || len(teInfo.Links) != 0 {
+		t.Errorf("TE router address not preserved %+v", teInfo)
+	}
+
+	/* truncated TLV */
+	teInfo = TELsaInfo{}
+	data := encodeTELinkTlv(link)
+	decodeTELsa(data[:len(data)-4], &teInfo)
+	if len(teInfo.Links) != 0 {
+		t.Errorf("Truncated TE link decoded %+v", teInfo)
+	}
+}
+
+func TestOpaqueOptions(t *testing.T) {
+	server := getServerObject()
+	intf := IntfConf{
+		IfAreaId: []byte{0, 0, 0, 0},
+	}
+	if server.getIntfOptions(intf)&OOption != 0 {
+		t.Errorf("O-bit set without opaque LSA support")
+	}
+	server.ospfGlobalConf.OpaqueLsaSupport = true
+	if server.getIntfOptions(intf)&OOption == 0 {
+		t.Errorf("O-bit not set with opaque LSA support")
+	}
+}
@@ -42,6 +42,7 @@ type RouteMdata struct {
 	metric uint32
 	ipaddr uint32
 	mask   uint32
+	tag    uint32
 	isDel  bool
 }
 
@@ -163,6 +163,11 @@ func (server *OSPFServer) dumpRoutingTbl() {
 */
 
 func (server *OSPFServer) findP2PNextHopIP(vFirst VertexKey, vSecond VertexKey, areaIdKey AreaIdKey) (ifIPAddr uint32, nextHopIP uint32, err error) {
+	// Virtual link, the next hop is in the transit area
+	ifIPAddr, nextHopIP, exist := server.getVirtLinkNextHop(vFirst, vSecond, areaIdKey.AreaId)
+	if exist {
+		return ifIPAddr, nextHopIP, nil
+	}
 	// Our link is P2P
 	lsDbKey := LsdbKey{
 		AreaId: areaIdKey.AreaId,
@@ -216,7 +221,7 @@ func (server *OSPFServer) findP2PNextHopIP(vFirst VertexKey, vSecond VertexKey,
 	}
 	for _, link := range secondLsa.LinkDetails {
 		if link.LinkId == vFirst.AdvRtr &&
-			link.LinkType == P2PLink {
+			(link.LinkType == P2PLink || link.LinkType == VirtualLink) {
 			secondLink = link
 			flag = true
 			break
@@ -103,6 +103,11 @@ func (server *OSPFServer) processOspfHeader(ospfPkt []byte, key IntfConfKey, md
 		return err
 	}
 
+	if int(ospfHdr.pktlen) < OSPF_HEADER_SIZE || int(ospfHdr.pktlen) > len(ospfPkt) {
+		err := errors.New("Dropped because of invalid Ospf packet length")
+		return err
+	}
+
 	if ent.IfType != config.NumberedP2P || ent.IfType != config.UnnumberedP2P {
 		if bytesEqual(ent.IfAreaId, ospfHdr.areaId) == false &&
 			isInSubnet(net.IP(ent.IfAreaId), net.IP(ospfHdr.areaId), net.IPMask(ent.IfNetmask)) == false {
@@ -120,24 +125,32 @@ func (server *OSPFServer) processOspfHeader(ospfPkt []byte, key IntfConfKey, md
 
 	//OSPF Auth Type
 	if ent.IfAuthType != ospfHdr.authType {
-		err := errors.New("Dropped because of Router Id not matching")
+		err := errors.New("Dropped because of Auth Type not matching")
 		return err
 	}
 
-	//OSPF Header CheckSum
-	binary.BigEndian.PutUint16(ospfPkt[12:14], 0)
-	copy(ospfPkt[16:OSPF_HEADER_SIZE], []byte{0, 0, 0, 0, 0, 0, 0, 0})
-	csum := computeCheckSum(ospfPkt)
-	if csum != ospfHdr.chksum {
-		err := errors.New("Dropped because of invalid checksum")
-		return err
+	if ospfHdr.authType == uint16(config.Md5) {
+		//OSPF Cryptographic Authentication, the checksum is not computed
+		err := server.processOspfCryptoAuth(ent, ospfPkt, ospfHdr)
+		if err != nil {
+			err = errors.New(fmt.Sprintln("Dropped because of authentication failure", err))
+			return err
+		}
+	} else {
+		//OSPF Header CheckSum
+		binary.BigEndian.PutUint16(ospfPkt[12:14], 0)
+		copy(ospfPkt[16:OSPF_HEADER_SIZE], []byte{0, 0, 0, 0, 0, 0, 0, 0})
+		csum := computeCheckSum(ospfPkt[:ospfHdr.pktlen])
+		if csum != ospfHdr.chksum {
+			err := errors.New("Dropped because of invalid checksum")
+			return err
+		}
 	}
 
 	/*
 	   ToDo:
 	   RFC 2328 Section 8.2
 	   1. Complete AreaID check
-	   2. Authentication
 	*/
 	md.pktType = OspfType(ospfHdr.pktType)
 	md.pktlen = ospfHdr.pktlen
@@ -182,6 +195,9 @@ func (server *OSPFServer) ProcessOspfRecvPkt(key IntfConfKey, pkt gopacket.Packe
 
 	ospfHdrMd := NewOspfHdrMetadata()
 	ospfPkt := ipLayer.LayerPayload()
+	if virtKey, isVirtLink := server.getVirtLinkRxIntf(key, ospfPkt); isVirtLink {
+		key = virtKey
+	}
 	err = server.processOspfHeader(ospfPkt, key, ospfHdrMd)
 	if err != nil {
 		server.logger.Err(fmt.Sprintln("Dropped because of Ospf Header processing", err))
@@ -190,7 +206,7 @@ func (server *OSPFServer) ProcessOspfRecvPkt(key IntfConfKey, pkt gopacket.Packe
 		//server.logger.Info("Ospfv2 Header is processed successfully")
 	}
 
-	ospfData := ospfPkt[OSPF_HEADER_SIZE:]
+	ospfData := ospfPkt[OSPF_HEADER_SIZE:ospfHdrMd.pktlen]
 	err = server.processOspfData(ospfData, ethHdrMd, ipHdrMd, ospfHdrMd, key)
 	if err != nil {
 		server.logger.Err(fmt.Sprintln("Dropped because of Ospf Header processing", err))
@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"l3/ospf/config"
 	"sort"
+	"time"
 )
 
 type VertexKey struct {
@@ -354,8 +355,9 @@ func (server *OSPFServer) UpdateAreaGraphRouterLsa(lsaEnt RouterLsa, lsaKey LsaK
 			sentry.LsaKey = lsaKey
 			sentry.LinkStateId = lsaKey.LSId
 			server.AreaStubs[vKey] = sentry
-		} else if linkDetail.LinkType == P2PLink {
-			server.logger.Info("===It is P2PLink===")
+		} else if linkDetail.LinkType == P2PLink ||
+			linkDetail.LinkType == VirtualLink {
+			server.logger.Info("===It is P2PLink or VirtualLink===")
 			vKey = VertexKey{
 				Type:   RouterVertex,
 				ID:     linkDetail.LinkId,
@@ -501,6 +503,15 @@ func (server *OSPFServer) CreateAreaGraph(areaId uint32) (VertexKey, error) {
 }
 
 func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
+	return server.runDijkstra(vKey, server.AreaGraph, server.SPFTree)
+}
+
+/*
+@fn runDijkstra
+Shortest path tree calculation over the given area graph. The graph is
+shared by the OSPFv2 and OSPFv3 instances.
+*/
+func (server *OSPFServer) runDijkstra(vKey VertexKey, areaGraph map[VertexKey]Vertex, spfTree map[VertexKey]TreeVertex) error {
 	//var treeVSlice []VertexKey = make([]VertexKey, 0)
 	var treeVSlice []VertexData = make([]VertexData, 0)
 
@@ -510,7 +521,7 @@ func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
 		distance: 0,
 	}
 	treeVSlice = append(treeVSlice, vData)
-	ent, exist := server.SPFTree[vKey]
+	ent, exist := spfTree[vKey]
 	if !exist {
 		ent.Distance = 0
 		ent.NumOfPaths = 1
@@ -518,15 +529,15 @@ func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
 		var path Path
 		path = make(Path, 0)
 		ent.Paths[0] = path
-		server.SPFTree[vKey] = ent
+		spfTree[vKey] = ent
 	}
 
 	for j := 0; j < len(treeVSlice); j++ {
 		verArr := make([]VertexData, 0)
 		server.logger.Debug(fmt.Sprintln("treeVSlice:", treeVSlice))
 		server.logger.Debug(fmt.Sprintln("The value of j:", j, "treeVSlice:", treeVSlice[j].vKey))
-		//ent, exist := server.AreaGraph[treeVSlice[j]]
-		ent, exist := server.AreaGraph[treeVSlice[j].vKey]
+		//ent, exist := areaGraph[treeVSlice[j]]
+		ent, exist := areaGraph[treeVSlice[j].vKey]
 		if !exist {
 			server.logger.Info(fmt.Sprintln("No entry found for:", treeVSlice[j].vKey))
 			err := errors.New(fmt.Sprintln("No entry found for:", treeVSlice[j].vKey))
@@ -537,14 +548,14 @@ func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
 		for i := 0; i < len(ent.NbrVertexKey); i++ {
 			verKey := ent.NbrVertexKey[i]
 			cost := ent.NbrVertexCost[i]
-			entry, exist := server.AreaGraph[verKey]
+			entry, exist := areaGraph[verKey]
 			server.logger.Debug(fmt.Sprintln("Neighboring Vertex Number :", i, "verKey", verKey, "cost:", cost, "entry:", entry))
 			if !exist {
 				server.logger.Err("Something is wrong in SPF Calculation: Entry should exist in Area Graph")
 				err := errors.New("Something is wrong in SPF Calculation: Entry should exist in Area Graph")
 				return err
 			}
-			tEnt, exist := server.SPFTree[verKey]
+			tEnt, exist := spfTree[verKey]
 			if !exist {
 				server.logger.Debug("Entry doesnot exist for the neighbor in SPF hence adding it")
 				tEnt.Paths = make([]Path, 1)
@@ -554,7 +565,7 @@ func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
 				tEnt.Distance = 0xff00 // LSInfinity
 				tEnt.NumOfPaths = 1
 			}
-			tEntry, exist := server.SPFTree[treeVSlice[j].vKey]
+			tEntry, exist := spfTree[treeVSlice[j].vKey]
 			if !exist {
 				server.logger.Err("Something is wrong is SPF Calculation")
 				err := errors.New("Something is wrong is SPF Calculation")
@@ -606,7 +617,7 @@ func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
 				tEnt.Paths = paths
 				tEnt.NumOfPaths = tEntry.NumOfPaths + tEnt.NumOfPaths
 			}
-			if _, ok := server.SPFTree[verKey]; !ok {
+			if _, ok := spfTree[verKey]; !ok {
 				server.logger.Debug(fmt.Sprintln("Adding verKey:", verKey, "to treeVSlice"))
 				vData := VertexData{
 					vKey:     verKey,
@@ -614,7 +625,7 @@ func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
 				}
 				verArr = append(verArr, vData)
 			}
-			server.SPFTree[verKey] = tEnt
+			spfTree[verKey] = tEnt
 		}
 		treeVSlice = append(treeVSlice, verArr...)
 		if len(treeVSlice[j+1:]) > 0 {
@@ -623,7 +634,7 @@ func (server *OSPFServer) ExecuteDijkstra(vKey VertexKey, areaId uint32) error {
 		verArr = verArr[:0]
 		verArr = nil
 		ent.Visited = true
-		server.AreaGraph[treeVSlice[j].vKey] = ent
+		areaGraph[treeVSlice[j].vKey] = ent
 	}
 
 	return nil
@@ -782,9 +793,23 @@ func (server *OSPFServer) initialiseSPFStructs() {
 
 func (server *OSPFServer) spfCalculation() {
 	for {
-		msg := <-server.StartCalcSPFCh
+		var msg SpfCalcMsg
+		select {
+		case routes := <-server.grRoutesCh:
+			/* Routes of the previous instance read at start up */
+			server.GlobalRoutingTbl = routes
+			continue
+		case msg = <-server.StartCalcSPFCh:
+		}
 		server.logger.Info(fmt.Sprintln("Recevd SPF Calculation Notification for:", msg))
 		server.logger.Info(fmt.Sprintln("Area LS Database:", server.AreaLsdb))
+		start := time.Now()
+		spfType := SpfFull
+		if msg.SpfType == SpfPartial && server.canRunPartialSpf() {
+			spfType = SpfPartial
+		} else {
+			server.IntraAreaRoutingTbl = make(map[AreaIdKey]AreaRoutingTbl)
+		}
 		// Create New Routing table
 		// Invalidate Old Routing table
 		// Backup Old Routing table
@@ -808,6 +833,13 @@ func (server *OSPFServer) spfCalculation() {
 				AreaId: areaId,
 			}
 
+			if spfType == SpfPartial {
+				/* Only summary and external LSAs changed, intra area
+				routes of the last full run are still valid */
+				server.TempAreaRoutingTbl[areaIdKey] = copyAreaRoutingTbl(server.IntraAreaRoutingTbl[areaIdKey])
+				server.HandleSummaryLsa(areaId)
+				continue
+			}
 			tempRoutingTbl := server.TempAreaRoutingTbl[areaIdKey]
 			tempRoutingTbl.RoutingTblMap = make(map[RoutingTblEntryKey]RoutingTblEntry)
 			server.TempAreaRoutingTbl[areaIdKey] = tempRoutingTbl
@@ -837,22 +869,36 @@ func (server *OSPFServer) spfCalculation() {
 			server.UpdateRoutingTbl(vKey, areaId)
 			server.logger.Info("==============Handling Stub links...====================")
 			server.HandleStubs(vKey, areaId)
+			server.IntraAreaRoutingTbl[areaIdKey] = copyAreaRoutingTbl(server.TempAreaRoutingTbl[areaIdKey])
+			if aState, exist := server.AreaStateMap[key]; exist {
+				aState.SpfRuns++
+				server.AreaStateMap[key] = aState
+			}
 			server.HandleSummaryLsa(areaId)
 			server.AreaGraph = nil
 			server.AreaStubs = nil
 			server.SPFTree = nil
 		}
+		if spfType == SpfFull {
+			server.updateVirtLinks()
+		}
 		/*
 			server.dumpRoutingTbl()
 		*/
 		server.TempGlobalRoutingTbl = nil
 		server.TempGlobalRoutingTbl = make(map[RoutingTblEntryKey]GlobalRoutingTblEntry)
-		/* Summarize and Install/Delete Routes In Routing Table */
-		server.InstallRoutingTbl()
-		// Copy the Summarize Routing Table in Global Routing Table
-		server.GlobalRoutingTbl = nil
-		server.GlobalRoutingTbl = make(map[RoutingTblEntryKey]GlobalRoutingTblEntry)
-		server.GlobalRoutingTbl = server.TempGlobalRoutingTbl
+		if server.isGRRestarting() {
+			/* RFC 3623 2.2 Routes of the previous instance are
+			kept till the graceful restart ends */
+			server.logger.Info("Graceful restart in progress. Routes are not installed")
+		} else {
+			/* Summarize and Install/Delete Routes In Routing Table */
+			server.InstallRoutingTbl()
+			// Copy the Summarize Routing Table in Global Routing Table
+			server.GlobalRoutingTbl = nil
+			server.GlobalRoutingTbl = make(map[RoutingTblEntryKey]GlobalRoutingTblEntry)
+			server.GlobalRoutingTbl = server.TempGlobalRoutingTbl
+		}
 		//server.dumpGlobalRoutingTbl()
 		for key, _ := range server.AreaConfMap {
 			areaId := convertAreaOrRouterIdUint32(string(key.AreaId))
@@ -874,6 +920,7 @@ func (server *OSPFServer) spfCalculation() {
 			server.GenerateSummaryLsa()
 			server.logger.Info(fmt.Sprintln("========", server.SummaryLsDb, "=========="))
 		}
+		server.recordSpfRun(msg, spfType, start)
 		server.DoneCalcSPFCh <- true
 	}
 }
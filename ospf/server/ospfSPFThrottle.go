@@ -0,0 +1,488 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"l3/ospf/config"
+	"strings"
+	"time"
+)
+
+/* Throttle defaults in msec */
+const (
+	SPF_THROTTLE_INITIAL = 50
+	SPF_THROTTLE_HOLD    = 200
+	SPF_THROTTLE_MAXWAIT = 5000
+	LSA_THROTTLE_INITIAL = 0
+	LSA_THROTTLE_HOLD    = 5000 // MinLSInterval
+	LSA_THROTTLE_MAXWAIT = 5000
+	LSA_ARRIVAL_INTERVAL = 1000 // MinLSArrival
+)
+
+const (
+	SPF_LOG_SIZE    = 16
+	SPF_MAX_REASONS = 8
+)
+
+const (
+	SpfFull    uint8 = 1
+	SpfPartial uint8 = 2
+)
+
+/*
+SPF calculation request. A partial calculation reuses the
+intra area routes of the last full run and only recomputes
+inter area and external routes.
+*/
+type SpfCalcMsg struct {
+	SpfType  uint8
+	Triggers int
+	Reasons  []string
+}
+
+type SpfLogEnt struct {
+	RunTime  time.Time
+	SpfType  uint8
+	Duration time.Duration
+	Triggers int
+	Reasons  []string
+}
+
+type SpfStats struct {
+	SpfRuns         int32
+	PartialSpfRuns  int32
+	SpfThrottled    int32
+	LastSpfTrigger  string
+	LastSpfDuration time.Duration
+	SpfLog          []SpfLogEnt // most recent run first
+}
+
+/*
+Exponential backoff shared by SPF and LSA generation.
+The first event after a quiet period of maxWait waits initial.
+Following events wait for the hold time since the last run,
+the hold time doubles on every such event up to maxWait.
+*/
+type ospfThrottle struct {
+	initial time.Duration
+	hold    time.Duration
+	maxWait time.Duration
+	curHold time.Duration
+	lastRun time.Time
+}
+
+type lsaArrivalKey struct {
+	AreaId uint32
+	LsaKey LsaKey
+}
+
+type lsaGenPendingEnt struct {
+	areas    map[uint32]bool
+	networks map[IntfConfKey]uint32
+	armed    bool
+}
+
+var spfThrottleTimer *time.Timer
+var lsaThrottleTimer *time.Timer
+
+/*
+@fn getThrottleDuration
+The default is used when the timer is not set,
+ThrottleNoDelay removes the delay.
+*/
+func getThrottleDuration(msec int32, defMsec int32) time.Duration {
+	if msec == config.ThrottleNoDelay {
+		return 0
+	}
+	if msec <= 0 {
+		msec = defMsec
+	}
+	return time.Duration(msec) * time.Millisecond
+}
+
+func (t *ospfThrottle) configure(initial time.Duration, hold time.Duration, maxWait time.Duration) {
+	if maxWait < hold {
+		maxWait = hold
+	}
+	t.initial = initial
+	t.hold = hold
+	t.maxWait = maxWait
+	t.curHold = hold
+}
+
+/*
+@fn nextDelay
+Returns how long an event received at now has to wait
+before the next run.
+*/
+func (t *ospfThrottle) nextDelay(now time.Time) time.Duration {
+	if t.lastRun.IsZero() || now.Sub(t.lastRun) >= t.maxWait {
+		t.curHold = t.hold
+		return t.initial
+	}
+	delay := t.lastRun.Add(t.curHold).Sub(now)
+	if delay < t.initial {
+		delay = t.initial
+	}
+	t.curHold = 2 * t.curHold
+	if t.curHold > t.maxWait {
+		t.curHold = t.maxWait
+	}
+	return delay
+}
+
+func (server *OSPFServer) initThrottleConfDefault() {
+	server.ospfGlobalConf.SpfThrottleInitial = SPF_THROTTLE_INITIAL
+	server.ospfGlobalConf.SpfThrottleHold = SPF_THROTTLE_HOLD
+	server.ospfGlobalConf.SpfThrottleMaxWait = SPF_THROTTLE_MAXWAIT
+	server.ospfGlobalConf.LsaThrottleInitial = LSA_THROTTLE_INITIAL
+	server.ospfGlobalConf.LsaThrottleHold = LSA_THROTTLE_HOLD
+	server.ospfGlobalConf.LsaThrottleMaxWait = LSA_THROTTLE_MAXWAIT
+	server.ospfGlobalConf.LsaArrivalInterval = LSA_ARRIVAL_INTERVAL
+	server.initThrottleConf()
+}
+
+func (server *OSPFServer) initThrottleConf() {
+	gConf := server.ospfGlobalConf
+	server.spfThrottle.configure(
+		getThrottleDuration(gConf.SpfThrottleInitial, SPF_THROTTLE_INITIAL),
+		getThrottleDuration(gConf.SpfThrottleHold, SPF_THROTTLE_HOLD),
+		getThrottleDuration(gConf.SpfThrottleMaxWait, SPF_THROTTLE_MAXWAIT))
+	server.lsaThrottle.configure(
+		getThrottleDuration(gConf.LsaThrottleInitial, LSA_THROTTLE_INITIAL),
+		getThrottleDuration(gConf.LsaThrottleHold, LSA_THROTTLE_HOLD),
+		getThrottleDuration(gConf.LsaThrottleMaxWait, LSA_THROTTLE_MAXWAIT))
+	server.lsaArrivalInterval = getThrottleDuration(gConf.LsaArrivalInterval, LSA_ARRIVAL_INTERVAL)
+}
+
+func (server *OSPFServer) initThrottleTimers() {
+	spfThrottleTimer = time.NewTimer(time.Hour)
+	spfThrottleTimer.Stop()
+	lsaThrottleTimer = time.NewTimer(time.Hour)
+	lsaThrottleTimer.Stop()
+	server.spfPending = nil
+	server.lsaGenPending = lsaGenPendingEnt{
+		areas:    make(map[uint32]bool),
+		networks: make(map[IntfConfKey]uint32),
+	}
+}
+
+func (server *OSPFServer) stopThrottleTimers() {
+	if spfThrottleTimer != nil {
+		spfThrottleTimer.Stop()
+	}
+	if lsaThrottleTimer != nil {
+		lsaThrottleTimer.Stop()
+	}
+}
+
+func getSpfTypeForLsa(lsType uint8) uint8 {
+	switch lsType {
+	case Summary3LSA, Summary4LSA, ASExternalLSA, NSSALSA:
+		return SpfPartial
+	}
+	return SpfFull
+}
+
+func getLsaTypeName(lsType uint8) string {
+	switch lsType {
+	case RouterLSA:
+		return "Router LSA"
+	case NetworkLSA:
+		return "Network LSA"
+	case Summary3LSA:
+		return "Summary LSA"
+	case Summary4LSA:
+		return "ASBR Summary LSA"
+	case ASExternalLSA:
+		return "AS External LSA"
+	case NSSALSA:
+		return "NSSA LSA"
+	}
+	return fmt.Sprint("LSA type ", lsType)
+}
+
+func getSpfTypeName(spfType uint8) string {
+	if spfType == SpfPartial {
+		return "Partial"
+	}
+	return "Full"
+}
+
+/*
+@fn addSpfTrigger
+Merge a trigger in the pending SPF request. Returns true
+if there was no pending request.
+*/
+func (server *OSPFServer) addSpfTrigger(spfType uint8, reason string) bool {
+	if server.spfPending == nil {
+		server.spfPending = &SpfCalcMsg{
+			SpfType:  spfType,
+			Triggers: 1,
+			Reasons:  []string{reason},
+		}
+		return true
+	}
+	if spfType == SpfFull {
+		server.spfPending.SpfType = SpfFull
+	}
+	server.spfPending.Triggers++
+	for _, r := range server.spfPending.Reasons {
+		if r == reason {
+			return false
+		}
+	}
+	if len(server.spfPending.Reasons) < SPF_MAX_REASONS {
+		server.spfPending.Reasons = append(server.spfPending.Reasons, reason)
+	}
+	return false
+}
+
+/*
+@fn scheduleSpf
+Request an SPF calculation. Triggers received while a
+calculation is pending are merged into it, a full calculation
+overrides a partial one.
+*/
+func (server *OSPFServer) scheduleSpf(spfType uint8, reason string) {
+	server.logger.Info(fmt.Sprintln("SPF: Schedule ", getSpfTypeName(spfType), " SPF, reason ", reason))
+	if !server.addSpfTrigger(spfType, reason) {
+		server.spfStatsMutex.Lock()
+		server.spfStats.SpfThrottled++
+		server.spfStatsMutex.Unlock()
+		return
+	}
+	delay := server.spfThrottle.nextDelay(time.Now())
+	server.logger.Info(fmt.Sprintln("SPF: Calculation in ", delay))
+	spfThrottleTimer.Reset(delay)
+}
+
+/*
+@fn runSpfNow
+Run the pending SPF calculation along with this trigger
+without waiting for the throttle timer.
+*/
+func (server *OSPFServer) runSpfNow(spfType uint8, reason string) {
+	server.addSpfTrigger(spfType, reason)
+	if spfThrottleTimer != nil {
+		spfThrottleTimer.Stop()
+	}
+	server.processSpfThrottleTimer()
+}
+
+func (server *OSPFServer) processSpfThrottleTimer() {
+	if server.spfPending == nil {
+		return
+	}
+	msg := *server.spfPending
+	server.spfPending = nil
+	server.spfThrottle.lastRun = time.Now()
+	server.StartCalcSPFCh <- msg
+	spfStatus := <-server.DoneCalcSPFCh
+	server.logger.Info(fmt.Sprintln("SPF Calculation Return Status", spfStatus))
+	if server.ospfGlobalConf.AreaBdrRtrStatus == true {
+		server.installSummaryLsa()
+	}
+}
+
+/*
+@fn recordSpfRun
+Update SPF statistics, called by spfCalculation after each run.
+*/
+func (server *OSPFServer) recordSpfRun(msg SpfCalcMsg, spfType uint8, start time.Time) {
+	logEnt := SpfLogEnt{
+		RunTime:  start,
+		SpfType:  spfType,
+		Duration: time.Since(start),
+		Triggers: msg.Triggers,
+		Reasons:  msg.Reasons,
+	}
+	server.spfStatsMutex.Lock()
+	if spfType == SpfPartial {
+		server.spfStats.PartialSpfRuns++
+	} else {
+		server.spfStats.SpfRuns++
+	}
+	server.spfStats.LastSpfTrigger = strings.Join(msg.Reasons, ", ")
+	server.spfStats.LastSpfDuration = logEnt.Duration
+	spfLog := append([]SpfLogEnt{logEnt}, server.spfStats.SpfLog...)
+	if len(spfLog) > SPF_LOG_SIZE {
+		spfLog = spfLog[:SPF_LOG_SIZE]
+	}
+	server.spfStats.SpfLog = spfLog
+	server.spfStatsMutex.Unlock()
+}
+
+/*
+@fn canRunPartialSpf
+Partial calculation needs the intra area routes of every
+active area from the last full run.
+*/
+func (server *OSPFServer) canRunPartialSpf() bool {
+	if server.IntraAreaRoutingTbl == nil {
+		return false
+	}
+	for key, aEnt := range server.AreaConfMap {
+		if len(aEnt.IntfListMap) == 0 {
+			continue
+		}
+		areaIdKey := AreaIdKey{
+			AreaId: convertAreaOrRouterIdUint32(string(key.AreaId)),
+		}
+		if _, exist := server.IntraAreaRoutingTbl[areaIdKey]; !exist {
+			return false
+		}
+	}
+	return true
+}
+
+func copyAreaRoutingTbl(tbl AreaRoutingTbl) AreaRoutingTbl {
+	var newTbl AreaRoutingTbl
+	newTbl.RoutingTblMap = make(map[RoutingTblEntryKey]RoutingTblEntry)
+	for key, ent := range tbl.RoutingTblMap {
+		nextHops := make(map[NextHop]bool)
+		for nextHop, valid := range ent.NextHops {
+			nextHops[nextHop] = valid
+		}
+		ent.NextHops = nextHops
+		newTbl.RoutingTblMap[key] = ent
+	}
+	return newTbl
+}
+
+/*
+@fn generateThrottledLsa
+Generate the router LSA of areaId, and the network LSA of
+intfKey if isNetwork is set. When the LSA throttle holds
+generation back the LSAs are generated and flooded once the
+throttle timer fires. Returns true if the LSAs were generated.
+*/
+func (server *OSPFServer) generateThrottledLsa(areaId uint32, intfKey IntfConfKey, isNetwork bool) bool {
+	if server.isGRRestarting() {
+		/* RFC 3623 2.2 Generated when the graceful restart ends */
+		return false
+	}
+	if isNetwork {
+		server.lsaGenPending.networks[intfKey] = areaId
+	}
+	server.lsaGenPending.areas[areaId] = true
+	if server.lsaGenPending.armed {
+		server.logger.Info(fmt.Sprintln("LSDB: LSA generation for area ", areaId, " throttled"))
+		return false
+	}
+	delay := server.lsaThrottle.nextDelay(time.Now())
+	if delay == 0 {
+		server.generatePendingLsas()
+		return true
+	}
+	server.logger.Info(fmt.Sprintln("LSDB: LSA generation for area ", areaId, " in ", delay))
+	server.lsaGenPending.armed = true
+	lsaThrottleTimer.Reset(delay)
+	return false
+}
+
+func (server *OSPFServer) generatePendingLsas() []uint32 {
+	rtrId := binary.BigEndian.Uint32(server.ospfGlobalConf.RouterId)
+	for intfKey, areaId := range server.lsaGenPending.networks {
+		intf, exist := server.IntfConfMap[intfKey]
+		if exist && intf.IfDRtrId == rtrId {
+			server.generateNetworkLSA(areaId, intfKey, true)
+		}
+	}
+	var areaList []uint32
+	for areaId, _ := range server.lsaGenPending.areas {
+		server.generateRouterLSA(areaId)
+		areaList = append(areaList, areaId)
+	}
+	server.lsaGenPending.areas = make(map[uint32]bool)
+	server.lsaGenPending.networks = make(map[IntfConfKey]uint32)
+	server.lsaThrottle.lastRun = time.Now()
+	return areaList
+}
+
+/*
+@fn processLsaThrottleTimer
+Generate the LSAs held back by the LSA throttle, flood them
+and schedule SPF for the new router LSAs.
+*/
+func (server *OSPFServer) processLsaThrottleTimer() {
+	server.lsaGenPending.armed = false
+	areaList := server.generatePendingLsas()
+	nbr := NeighborConfKey{}
+	lsaKey := LsaKey{}
+	for _, areaId := range areaList {
+		for intfKey, intf := range server.IntfConfMap {
+			if convertIPv4ToUint32(intf.IfAreaId) != areaId {
+				continue
+			}
+			server.sendLsdbToNeighborEvent(intfKey, nbr, areaId, 0, 0, lsaKey, LSAFLOOD)
+		}
+		server.scheduleSpf(SpfFull, "Router LSA generation area "+convertUint32ToIPv4(areaId))
+	}
+}
+
+/*
+@fn lsaArrivalThrottled
+RFC 2328 13 (5a). A new instance of an LSA received less
+than MinLSArrival after the previous one is discarded.
+*/
+func (server *OSPFServer) lsaArrivalThrottled(areaId uint32, lsaKey LsaKey, isMaxAge bool) bool {
+	key := lsaArrivalKey{
+		AreaId: areaId,
+		LsaKey: lsaKey,
+	}
+	now := time.Now()
+	lastArrival, exist := server.lsaArrivalMap[key]
+	if exist && now.Sub(lastArrival) < server.lsaArrivalInterval {
+		return true
+	}
+	if isMaxAge {
+		delete(server.lsaArrivalMap, key)
+	} else {
+		server.lsaArrivalMap[key] = now
+	}
+	return false
+}
+
+func (server *OSPFServer) getSpfStats() SpfStats {
+	server.spfStatsMutex.RLock()
+	defer server.spfStatsMutex.RUnlock()
+	stats := server.spfStats
+	stats.SpfLog = make([]SpfLogEnt, len(server.spfStats.SpfLog))
+	copy(stats.SpfLog, server.spfStats.SpfLog)
+	return stats
+}
+
+func convertSpfLogToState(idx int, ent SpfLogEnt) config.SpfLogState {
+	return config.SpfLogState{
+		SpfLogIdx:   int32(idx),
+		SpfRunTime:  ent.RunTime.String(),
+		SpfType:     getSpfTypeName(ent.SpfType),
+		SpfDuration: int32(ent.Duration / time.Millisecond),
+		SpfTriggers: int32(ent.Triggers),
+		SpfReasons:  strings.Join(ent.Reasons, ", "),
+	}
+}
@@ -0,0 +1,154 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"l3/ospf/config"
+	"testing"
+	"time"
+)
+
+func TestOspfThrottleBackoff(t *testing.T) {
+	var throttle ospfThrottle
+	throttle.configure(50*time.Millisecond, 200*time.Millisecond, time.Second)
+	now := time.Now()
+	if delay := throttle.nextDelay(now); delay != 50*time.Millisecond {
+		t.Errorf("First delay %v, expected initial", delay)
+	}
+	throttle.lastRun = now
+	expected := []time.Duration{200, 400, 800, 1000, 1000}
+	for idx, exp := range expected {
+		delay := throttle.nextDelay(now)
+		if delay != exp*time.Millisecond {
+			t.Errorf("%d: delay %v, expected %v", idx, delay, exp*time.Millisecond)
+		}
+	}
+	if delay := throttle.nextDelay(now.Add(time.Second)); delay != 50*time.Millisecond {
+		t.Errorf("Delay after quiet period %v, expected initial", delay)
+	}
+	if delay := throttle.nextDelay(now.Add(100 * time.Millisecond)); delay != 100*time.Millisecond {
+		t.Errorf("Delay %v, expected remaining hold time", delay)
+	}
+}
+
+func TestOspfThrottleConf(t *testing.T) {
+	server := getServerObject()
+	server.ospfGlobalConf.SpfThrottleInitial = 0
+	server.ospfGlobalConf.SpfThrottleHold = config.ThrottleNoDelay
+	server.ospfGlobalConf.SpfThrottleMaxWait = config.ThrottleNoDelay
+	server.ospfGlobalConf.LsaThrottleInitial = 0
+	server.ospfGlobalConf.LsaArrivalInterval = config.ThrottleNoDelay
+	server.initThrottleConf()
+	if server.spfThrottle.initial != SPF_THROTTLE_INITIAL*time.Millisecond {
+		t.Errorf("SPF initial delay %v, expected default", server.spfThrottle.initial)
+	}
+	if server.spfThrottle.hold != 0 || server.spfThrottle.maxWait != 0 {
+		t.Errorf("SPF hold %v max wait %v, expected 0", server.spfThrottle.hold, server.spfThrottle.maxWait)
+	}
+	if server.lsaThrottle.initial != LSA_THROTTLE_INITIAL*time.Millisecond {
+		t.Errorf("LSA initial delay %v, expected default", server.lsaThrottle.initial)
+	}
+	if server.lsaArrivalInterval != 0 {
+		t.Errorf("LSA arrival interval %v, expected 0", server.lsaArrivalInterval)
+	}
+}
+
+func TestSpfTriggerMerge(t *testing.T) {
+	server := getServerObject()
+	if !server.addSpfTrigger(SpfPartial, "Summary LSA update area 0.0.0.0") {
+		t.Errorf("First trigger not reported as new")
+	}
+	server.addSpfTrigger(SpfPartial, "Summary LSA update area 0.0.0.0")
+	if server.spfPending.SpfType != SpfPartial {
+		t.Errorf("Partial triggers escalated to full SPF")
+	}
+	if server.addSpfTrigger(SpfFull, "Router LSA update area 0.0.0.0") {
+		t.Errorf("Trigger not merged in pending SPF")
+	}
+	msg := server.spfPending
+	if msg.SpfType != SpfFull || msg.Triggers != 3 || len(msg.Reasons) != 2 {
+		t.Errorf("Invalid pending SPF %+v", msg)
+	}
+}
+
+func TestPartialSpfRoutingTbl(t *testing.T) {
+	server := getServerObject()
+	server.AreaConfMap[AreaConfKey{AreaId: "0.0.0.0"}] = AreaConf{
+		IntfListMap: map[IntfConfKey]bool{IntfConfKey{}: true},
+	}
+	server.IntraAreaRoutingTbl = make(map[AreaIdKey]AreaRoutingTbl)
+	if server.canRunPartialSpf() {
+		t.Errorf("Partial SPF allowed without a full run")
+	}
+	rKey := RoutingTblEntryKey{
+		DestId:   0x0a000000,
+		AddrMask: 0xffffff00,
+		DestType: Network,
+	}
+	nextHop := NextHop{
+		IfIPAddr:  0x0a000001,
+		NextHopIP: 0x0a000002,
+	}
+	tbl := AreaRoutingTbl{
+		RoutingTblMap: map[RoutingTblEntryKey]RoutingTblEntry{
+			rKey: {
+				PathType:   IntraArea,
+				Cost:       10,
+				NumOfPaths: 1,
+				NextHops:   map[NextHop]bool{nextHop: true},
+			},
+		},
+	}
+	newTbl := copyAreaRoutingTbl(tbl)
+	newTbl.RoutingTblMap[rKey].NextHops[NextHop{}] = true
+	if len(tbl.RoutingTblMap[rKey].NextHops) != 1 {
+		t.Errorf("Copied routing table shares next hops")
+	}
+	server.IntraAreaRoutingTbl[AreaIdKey{AreaId: 0}] = tbl
+	if !server.canRunPartialSpf() {
+		t.Errorf("Partial SPF not allowed after a full run")
+	}
+}
+
+func TestLsaArrivalThrottled(t *testing.T) {
+	server := getServerObject()
+	lsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      0x0a000002,
+		AdvRouter: 0x0a000002,
+	}
+	if server.lsaArrivalThrottled(0, lsaKey, false) {
+		t.Errorf("First instance throttled")
+	}
+	if !server.lsaArrivalThrottled(0, lsaKey, false) {
+		t.Errorf("Instance within MinLSArrival accepted")
+	}
+	if server.lsaArrivalThrottled(1, lsaKey, false) {
+		t.Errorf("Instance of another area throttled")
+	}
+	server.lsaArrivalInterval = 0
+	if server.lsaArrivalThrottled(0, lsaKey, true) {
+		t.Errorf("Instance after MinLSArrival throttled")
+	}
+}
@@ -0,0 +1,262 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"ospfd"
+)
+
+/*
+	ASBR summary addresses
+	Redistributed routes falling in a configured summary address are
+	advertised as a single AS-external-LSA (and Type-7 LSA in the
+	attached NSSAs) instead of one LSA per route. The metric of the
+	summary follows the area range cost rule. With DoNotAdvertiseMatching
+	the component routes are not advertised at all.
+
+	The redistributed routes and the external LSAs originated for them
+	are owned by the LSDB goroutine.
+*/
+
+type SummaryAddrKey struct {
+	Net  uint32
+	Mask uint32
+}
+
+type SummaryAddrEnt struct {
+	Effect config.AreaRangeEffect
+	Tag    uint32
+	Active bool // summary has component routes
+	Metric uint32
+}
+
+type SummaryAddrMsg struct {
+	Conf  config.SummaryAddrConf
+	IsDel bool
+}
+
+/*
+@fn ConvertSummaryAddrConf
+Converts and validates the thrift summary address object.
+*/
+func ConvertSummaryAddrConf(conf *ospfd.OspfSummaryAddrEntry) (config.SummaryAddrConf, error) {
+	sConf := config.SummaryAddrConf{
+		SummaryAddrNet:    config.IpAddress(conf.SummaryAddrNet),
+		SummaryAddrMask:   config.IpAddress(conf.SummaryAddrMask),
+		SummaryAddrEffect: config.AreaRangeEffect(conf.SummaryAddrEffect),
+		SummaryAddrTag:    uint32(conf.SummaryAddrTag),
+	}
+	if sConf.SummaryAddrEffect == 0 {
+		sConf.SummaryAddrEffect = config.AdvertiseMatching
+	}
+	if !isValidRangeEffect(sConf.SummaryAddrEffect) {
+		return sConf, errors.New("Invalid summary address effect")
+	}
+	_, _, err := convertRangeNetMask(conf.SummaryAddrNet, conf.SummaryAddrMask)
+	if err != nil {
+		return sConf, err
+	}
+	return sConf, nil
+}
+
+/*
+@fn processSummaryAddrConfig
+Add, update or delete a summary address. External LSAs are
+updated by the LSDB goroutine.
+*/
+func (server *OSPFServer) processSummaryAddrConfig(msg SummaryAddrMsg) error {
+	sNet, sMask, err := convertRangeNetMask(string(msg.Conf.SummaryAddrNet),
+		string(msg.Conf.SummaryAddrMask))
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("SUMMARYADDR: Invalid summary address ", msg.Conf, err))
+		return err
+	}
+	key := SummaryAddrKey{
+		Net:  sNet,
+		Mask: sMask,
+	}
+	server.summaryAddrMutex.Lock()
+	ent, exist := server.SummaryAddrMap[key]
+	if msg.IsDel {
+		if !exist {
+			server.summaryAddrMutex.Unlock()
+			return errors.New("Summary address does not exist")
+		}
+		delete(server.SummaryAddrMap, key)
+	} else {
+		ent.Effect = msg.Conf.SummaryAddrEffect
+		ent.Tag = msg.Conf.SummaryAddrTag
+		server.SummaryAddrMap[key] = ent
+	}
+	server.summaryAddrMutex.Unlock()
+	server.logger.Info(fmt.Sprintln("SUMMARYADDR: Updated summary address ", msg.Conf, " delete ", msg.IsDel))
+	notifyConfChange(server.summaryAddrChangeCh)
+	return nil
+}
+
+func getExtRouteKey(route RouteMdata) SummaryAddrKey {
+	return SummaryAddrKey{
+		Net:  route.ipaddr & route.mask,
+		Mask: route.mask,
+	}
+}
+
+/*
+@fn getCoveringSummaryAddr
+Most specific summary address covering the prefix.
+*/
+func (server *OSPFServer) getCoveringSummaryAddr(key SummaryAddrKey) (SummaryAddrKey, bool) {
+	var sKey SummaryAddrKey
+	found := false
+	server.summaryAddrMutex.RLock()
+	for k, _ := range server.SummaryAddrMap {
+		if key.Net&k.Mask != k.Net || key.Mask&k.Mask != k.Mask {
+			continue
+		}
+		if !found || k.Mask > sKey.Mask {
+			sKey = k
+			found = true
+		}
+	}
+	server.summaryAddrMutex.RUnlock()
+	return sKey, found
+}
+
+/*
+@fn calcSummaryAddr
+Aggregate the redistributed routes of the summary address.
+Returns false if the summary address is not configured.
+*/
+func (server *OSPFServer) calcSummaryAddr(sKey SummaryAddrKey) (SummaryAddrEnt, bool) {
+	server.summaryAddrMutex.RLock()
+	ent, exist := server.SummaryAddrMap[sKey]
+	server.summaryAddrMutex.RUnlock()
+	if !exist {
+		return ent, false
+	}
+	ent.Active = false
+	ent.Metric = 0
+	for key, route := range server.extRouteMap {
+		if cKey, covered := server.getCoveringSummaryAddr(key); !covered || cKey != sKey {
+			continue
+		}
+		ent.Metric = server.getSummaryCost(ent.Metric, route.metric, !ent.Active)
+		ent.Active = true
+	}
+	server.summaryAddrMutex.Lock()
+	if cur, exist := server.SummaryAddrMap[sKey]; exist {
+		cur.Active = ent.Active
+		cur.Metric = ent.Metric
+		server.SummaryAddrMap[sKey] = cur
+	}
+	server.summaryAddrMutex.Unlock()
+	return ent, true
+}
+
+/*
+@fn getExtLsaRoute
+External route to be advertised for the prefix, either a summary
+address or a redistributed route that is not summarized.
+*/
+func (server *OSPFServer) getExtLsaRoute(key SummaryAddrKey) (RouteMdata, bool) {
+	if ent, exist := server.calcSummaryAddr(key); exist {
+		isDiscard := ent.Active && ent.Effect == config.AdvertiseMatching
+		if isDiscard != server.extDiscardMap[key] {
+			server.updateDiscardRoute(key.Net, key.Mask, ent.Metric, !isDiscard)
+			if isDiscard {
+				server.extDiscardMap[key] = true
+			} else {
+				delete(server.extDiscardMap, key)
+			}
+		}
+		route := RouteMdata{
+			metric: ent.Metric,
+			ipaddr: key.Net,
+			mask:   key.Mask,
+			tag:    ent.Tag,
+		}
+		return route, isDiscard
+	}
+	if server.extDiscardMap[key] {
+		server.updateDiscardRoute(key.Net, key.Mask, 0, true)
+		delete(server.extDiscardMap, key)
+	}
+	route, exist := server.extRouteMap[key]
+	if !exist {
+		return route, false
+	}
+	if _, covered := server.getCoveringSummaryAddr(key); covered {
+		return route, false
+	}
+	return route, true
+}
+
+/*
+@fn syncExtLsa
+Originate, refresh or withdraw the external LSA of the prefix.
+*/
+func (server *OSPFServer) syncExtLsa(key SummaryAddrKey) {
+	route, advertise := server.getExtLsaRoute(key)
+	cur, exist := server.extLsaMap[key]
+	if advertise {
+		if exist && cur.metric == route.metric && cur.tag == route.tag {
+			return
+		}
+		route.isDel = false
+		server.originateExtRouteLsa(route)
+		server.extLsaMap[key] = route
+	} else if exist {
+		cur.isDel = true
+		server.originateExtRouteLsa(cur)
+		delete(server.extLsaMap, key)
+	}
+}
+
+/*
+@fn processSummaryAddrChange
+Resync the external LSAs after summary address configuration change.
+*/
+func (server *OSPFServer) processSummaryAddrChange() {
+	keys := make(map[SummaryAddrKey]bool)
+	for key, _ := range server.extRouteMap {
+		keys[key] = true
+	}
+	for key, _ := range server.extLsaMap {
+		keys[key] = true
+	}
+	for key, _ := range server.extDiscardMap {
+		keys[key] = true
+	}
+	server.summaryAddrMutex.RLock()
+	for key, _ := range server.SummaryAddrMap {
+		keys[key] = true
+	}
+	server.summaryAddrMutex.RUnlock()
+	for key, _ := range keys {
+		server.syncExtLsa(key)
+	}
+}
@@ -0,0 +1,363 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"l3/ospf/config"
+	"math"
+)
+
+/*
+	RFC 3630 Traffic Engineering (TE) Extensions to OSPF
+
+	TE LSAs are area scoped opaque LSAs of opaque type 1. The router
+	address LSA (opaque id 0) carries the router address TLV and every
+	TE link is advertised in its own LSA with a single link TLV.
+	Bandwidths are configured in Mbps and carried in bytes per second
+	as IEEE floating point numbers.
+*/
+
+const (
+	TEOpaqueType uint8 = 1
+)
+
+/* Top level TLVs */
+const (
+	TERouterAddrTlv uint16 = 1
+	TELinkTlv       uint16 = 2
+)
+
+/* Link TLV sub-TLVs */
+const (
+	TELinkTypeSubTlv   uint16 = 1
+	TELinkIdSubTlv     uint16 = 2
+	TELocalAddrSubTlv  uint16 = 3
+	TERemoteAddrSubTlv uint16 = 4
+	TEMetricSubTlv     uint16 = 5
+	TEMaxBwSubTlv      uint16 = 6
+	TEMaxRsvBwSubTlv   uint16 = 7
+	TEUnrsvBwSubTlv    uint16 = 8
+	TEAdminGroupSubTlv uint16 = 9
+)
+
+const (
+	TELinkP2P         uint8 = 1
+	TELinkMultiAccess uint8 = 2
+)
+
+const (
+	TE_TLV_HEADER_SIZE   = 4
+	TE_PRIORITY_COUNT    = 8
+	TE_BYTES_PER_MBIT    = 125000
+	TERouterAddrOpaqueId = 0
+)
+
+type TELinkInfo struct {
+	LinkType   uint8
+	LinkId     uint32
+	LocalAddr  uint32
+	RemoteAddr uint32
+	TEMetric   uint32
+	MaxBw      float32 // bytes per second
+	MaxRsvBw   float32
+	UnrsvBw    [TE_PRIORITY_COUNT]float32
+	AdminGroup uint32
+}
+
+/* TE information of the TE LSA */
+type TELsaInfo struct {
+	RouterAddr uint32
+	Links      []TELinkInfo
+}
+
+/* Self originated link LSA of the interface */
+type TELinkLsaEnt struct {
+	opaqueId uint32
+	areaId   uint32
+	valid    bool
+}
+
+func encodeTETlv(tlvType uint16, value []byte) []byte {
+	tlv := make([]byte, TE_TLV_HEADER_SIZE+(len(value)+3)/4*4)
+	binary.BigEndian.PutUint16(tlv[0:2], tlvType)
+	binary.BigEndian.PutUint16(tlv[2:4], uint16(len(value)))
+	copy(tlv[TE_TLV_HEADER_SIZE:], value)
+	return tlv
+}
+
+func encodeTEUint32(val uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, val)
+	return data
+}
+
+func encodeTEBandwidth(bw float32) []byte {
+	return encodeTEUint32(math.Float32bits(bw))
+}
+
+func encodeTERouterAddrTlv(rtrAddr uint32) []byte {
+	return encodeTETlv(TERouterAddrTlv, encodeTEUint32(rtrAddr))
+}
+
+func encodeTELinkTlv(link TELinkInfo) []byte {
+	var subTlvs []byte
+	subTlvs = append(subTlvs, encodeTETlv(TELinkTypeSubTlv, []byte{link.LinkType})...)
+	subTlvs = append(subTlvs, encodeTETlv(TELinkIdSubTlv, encodeTEUint32(link.LinkId))...)
+	if link.LocalAddr != 0 {
+		subTlvs = append(subTlvs, encodeTETlv(TELocalAddrSubTlv, encodeTEUint32(link.LocalAddr))...)
+	}
+	if link.RemoteAddr != 0 {
+		subTlvs = append(subTlvs, encodeTETlv(TERemoteAddrSubTlv, encodeTEUint32(link.RemoteAddr))...)
+	}
+	subTlvs = append(subTlvs, encodeTETlv(TEMetricSubTlv, encodeTEUint32(link.TEMetric))...)
+	subTlvs = append(subTlvs, encodeTETlv(TEMaxBwSubTlv, encodeTEBandwidth(link.MaxBw))...)
+	subTlvs = append(subTlvs, encodeTETlv(TEMaxRsvBwSubTlv, encodeTEBandwidth(link.MaxRsvBw))...)
+	var unrsvBw []byte
+	for _, bw := range link.UnrsvBw {
+		unrsvBw = append(unrsvBw, encodeTEBandwidth(bw)...)
+	}
+	subTlvs = append(subTlvs, encodeTETlv(TEUnrsvBwSubTlv, unrsvBw)...)
+	subTlvs = append(subTlvs, encodeTETlv(TEAdminGroupSubTlv, encodeTEUint32(link.AdminGroup))...)
+	return encodeTETlv(TELinkTlv, subTlvs)
+}
+
+/*
+@fn getTETlvs
+Split TLVs into type and value. Truncated TLVs are ignored.
+*/
+func getTETlvs(data []byte, fn func(tlvType uint16, value []byte)) {
+	index := 0
+	for index+TE_TLV_HEADER_SIZE <= len(data) {
+		tlvType := binary.BigEndian.Uint16(data[index : index+2])
+		tlvLen := int(binary.BigEndian.Uint16(data[index+2 : index+4]))
+		start := index + TE_TLV_HEADER_SIZE
+		if start+tlvLen > len(data) {
+			return
+		}
+		fn(tlvType, data[start:start+tlvLen])
+		index = start + (tlvLen+3)/4*4
+	}
+}
+
+func decodeTELinkTlv(data []byte, link *TELinkInfo) {
+	getTETlvs(data, func(tlvType uint16, value []byte) {
+		switch tlvType {
+		case TELinkTypeSubTlv:
+			if len(value) >= 1 {
+				link.LinkType = value[0]
+			}
+		case TELinkIdSubTlv, TELocalAddrSubTlv, TERemoteAddrSubTlv,
+			TEMetricSubTlv, TEMaxBwSubTlv, TEMaxRsvBwSubTlv, TEAdminGroupSubTlv:
+			if len(value) < 4 {
+				return
+			}
+			val := binary.BigEndian.Uint32(value[0:4])
+			switch tlvType {
+			case TELinkIdSubTlv:
+				link.LinkId = val
+			case TELocalAddrSubTlv:
+				link.LocalAddr = val
+			case TERemoteAddrSubTlv:
+				link.RemoteAddr = val
+			case TEMetricSubTlv:
+				link.TEMetric = val
+			case TEMaxBwSubTlv:
+				link.MaxBw = math.Float32frombits(val)
+			case TEMaxRsvBwSubTlv:
+				link.MaxRsvBw = math.Float32frombits(val)
+			case TEAdminGroupSubTlv:
+				link.AdminGroup = val
+			}
+		case TEUnrsvBwSubTlv:
+			for i := 0; i < TE_PRIORITY_COUNT && (i+1)*4 <= len(value); i++ {
+				link.UnrsvBw[i] = math.Float32frombits(binary.BigEndian.Uint32(value[i*4 : (i+1)*4]))
+			}
+		}
+	})
+}
+
+func decodeTELsa(info []byte, teInfo *TELsaInfo) {
+	getTETlvs(info, func(tlvType uint16, value []byte) {
+		switch tlvType {
+		case TERouterAddrTlv:
+			if len(value) >= 4 {
+				teInfo.RouterAddr = binary.BigEndian.Uint32(value[0:4])
+			}
+		case TELinkTlv:
+			var link TELinkInfo
+			decodeTELinkTlv(value, &link)
+			teInfo.Links = append(teInfo.Links, link)
+		}
+	})
+}
+
+/* TE database of the TE LSAs received from other routers */
+type teOpaqueApp struct {
+	server *OSPFServer
+}
+
+func (app *teOpaqueApp) OpaqueLsaUpdate(areaId uint32, intfKey IntfConfKey, lsaKey LsaKey, info []byte, isDel bool) {
+	if isDel {
+		delete(app.server.TEDatabase, lsaKey)
+		return
+	}
+	var teInfo TELsaInfo
+	decodeTELsa(info, &teInfo)
+	app.server.TEDatabase[lsaKey] = teInfo
+}
+
+/*
+@fn getTELinkInfo
+TE link of the interface. Link is advertised only once it has
+a fully adjacent neighbor.
+*/
+func (server *OSPFServer) getTELinkInfo(intfKey IntfConfKey, intf IntfConf) (link TELinkInfo, valid bool) {
+	if intf.IfAdminStat != config.Enabled || intf.IfFSMState < config.P2P ||
+		intf.IfType == config.VirtualLink {
+		return link, false
+	}
+	var fullNbr NeighborConfKey
+	nbrFound := false
+	if nbrData, exist := ospfIntfToNbrMap[intfKey]; exist {
+		for _, nbrKey := range nbrData.nbrList {
+			if server.NeighborConfigMap[nbrKey].OspfNbrState == config.NbrFull {
+				fullNbr = nbrKey
+				nbrFound = true
+				break
+			}
+		}
+	}
+	if !nbrFound {
+		return link, false
+	}
+	link.LocalAddr = convertAreaOrRouterIdUint32(string(intfKey.IPAddr))
+	if intf.IfType == config.NumberedP2P || intf.IfType == config.UnnumberedP2P {
+		link.LinkType = TELinkP2P
+		link.LinkId = server.NeighborConfigMap[fullNbr].OspfNbrRtrId
+		link.RemoteAddr = convertAreaOrRouterIdUint32(string(fullNbr.IPAddr))
+	} else {
+		if len(intf.IfDRIp) < 4 || convertIPv4ToUint32(intf.IfDRIp) == 0 {
+			return link, false
+		}
+		link.LinkType = TELinkMultiAccess
+		link.LinkId = convertIPv4ToUint32(intf.IfDRIp)
+	}
+	link.TEMetric = intf.IfTEMetric
+	if link.TEMetric == 0 {
+		link.TEMetric = intf.IfCost
+	}
+	maxBw := intf.IfMaxBandwidth
+	if maxBw == 0 && intf.IfCost != 0 {
+		maxBw = server.ospfGlobalConf.ReferenceBandwidth / intf.IfCost
+	}
+	maxRsvBw := intf.IfMaxRsvBandwidth
+	if maxRsvBw == 0 {
+		maxRsvBw = maxBw
+	}
+	link.MaxBw = float32(maxBw) * TE_BYTES_PER_MBIT
+	link.MaxRsvBw = float32(maxRsvBw) * TE_BYTES_PER_MBIT
+	for i := range link.UnrsvBw {
+		link.UnrsvBw[i] = link.MaxRsvBw
+	}
+	link.AdminGroup = intf.IfAdminGroup
+	return link, true
+}
+
+func (server *OSPFServer) allocTEOpaqueId() uint32 {
+	used := make(map[uint32]bool)
+	for _, ent := range server.TELinkLsaMap {
+		used[ent.opaqueId] = true
+	}
+	opaqueId := uint32(TERouterAddrOpaqueId + 1)
+	for used[opaqueId] {
+		opaqueId++
+	}
+	return opaqueId
+}
+
+func (server *OSPFServer) originateTELsa(areaId uint32, opaqueId uint32, info []byte, isDel bool) {
+	msg := OpaqueLsaMsg{
+		LSType:     AreaOpaqueLSA,
+		OpaqueType: TEOpaqueType,
+		OpaqueId:   opaqueId,
+		AreaId:     areaId,
+		Info:       info,
+		IsDel:      isDel,
+	}
+	server.processOpaqueLsaMsg(msg)
+}
+
+/*
+@fn updateTELsas
+Originate TE LSAs for the TE links and flush the LSAs of links
+which are down or deleted. Called from the LSDB routine on
+interface, DR and adjacency changes.
+*/
+func (server *OSPFServer) updateTELsas() {
+	teAreas := make(map[uint32]bool)
+	for intfKey, ent := range server.TELinkLsaMap {
+		if _, exist := server.IntfConfMap[intfKey]; !exist {
+			if ent.valid {
+				server.originateTELsa(ent.areaId, ent.opaqueId, nil, true)
+			}
+			delete(server.TELinkLsaMap, intfKey)
+		}
+	}
+	for intfKey, intf := range server.IntfConfMap {
+		areaId := convertIPv4ToUint32(intf.IfAreaId)
+		link, valid := server.getTELinkInfo(intfKey, intf)
+		ent, exist := server.TELinkLsaMap[intfKey]
+		if ent.valid && (!server.ospfGlobalConf.TESupport || !valid || ent.areaId != areaId) {
+			server.logger.Info(fmt.Sprintln("TE: Flush link LSA intf ", intfKey, " area ", ent.areaId))
+			server.originateTELsa(ent.areaId, ent.opaqueId, nil, true)
+			ent.valid = false
+			server.TELinkLsaMap[intfKey] = ent
+		}
+		if !server.ospfGlobalConf.TESupport || !valid {
+			continue
+		}
+		if !exist {
+			ent.opaqueId = server.allocTEOpaqueId()
+		}
+		ent.areaId = areaId
+		ent.valid = true
+		server.TELinkLsaMap[intfKey] = ent
+		server.originateTELsa(areaId, ent.opaqueId, encodeTELinkTlv(link), false)
+		teAreas[areaId] = true
+	}
+
+	rtrAddr := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+	for areaId, _ := range teAreas {
+		server.originateTELsa(areaId, TERouterAddrOpaqueId, encodeTERouterAddrTlv(rtrAddr), false)
+		server.TERtrAddrAreaMap[areaId] = true
+	}
+	for areaId, _ := range server.TERtrAddrAreaMap {
+		if !teAreas[areaId] {
+			server.originateTELsa(areaId, TERouterAddrOpaqueId, nil, true)
+			delete(server.TERtrAddrAreaMap, areaId)
+		}
+	}
+}
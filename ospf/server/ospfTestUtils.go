@@ -904,3 +904,20 @@ func getServerObject() *OSPFServer {
 	ospf = ospfServer
 	return ospfServer
 }
+
+/*
+@fn getAreaTestServer
+Server object with router id 10.0.0.5 and the given areas
+configured without interfaces.
+*/
+func getAreaTestServer(areaIds ...config.AreaId) *OSPFServer {
+	server := getServerObject()
+	server.ospfGlobalConf.RouterId = []byte{10, 0, 0, 5}
+	for _, areaId := range areaIds {
+		server.AreaConfMap[AreaConfKey{AreaId: areaId}] = AreaConf{
+			ImportAsExtern: config.ImportExternal,
+			IntfListMap:    make(map[IntfConfKey]bool),
+		}
+	}
+	return server
+}
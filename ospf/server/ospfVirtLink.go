@@ -0,0 +1,606 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"net"
+	"ospfd"
+)
+
+/*
+Virtual links (RFC 2328 section 15) connect an area border router that
+is not attached to the backbone through a non-backbone transit area.
+The virtual interface belongs to the backbone, it comes up once the
+intra-area SPF of the transit area finds the virtual neighbor
+(RFC 2328 section 16.1), and its packets are unicast to the virtual
+neighbor through the next hop of the transit area path.
+*/
+const (
+	OSPF_VIRT_LINK_TTL        uint8                       = 64
+	OSPF_VIRT_LINK_IFIDX_BASE config.InterfaceIndexOrZero = 0x7f000000
+)
+
+type VirtLinkKey struct {
+	TransitArea uint32
+	NbrRtrId    uint32
+}
+
+/* Transit area path to the virtual neighbor, updated by SPF */
+type VirtLinkEndPoint struct {
+	Reachable bool
+	Cost      uint16
+	IfIpAddr  uint32 // local address in the transit area
+	NbrIpAddr uint32 // virtual neighbor address in the transit area
+	NextHopIP uint32
+}
+
+type VirtLinkEnt struct {
+	Conf     config.VirtIfConf
+	IntfKey  IntfConfKey
+	EndPoint VirtLinkEndPoint
+}
+
+type VirtLinkMsg struct {
+	Conf  config.VirtIfConf
+	IsDel bool
+}
+
+func ConvertVirtIfConf(conf *ospfd.OspfVirtIfEntry) (config.VirtIfConf, error) {
+	vConf := config.VirtIfConf{
+		VirtIfAreaId:          config.AreaId(conf.VirtIfAreaId),
+		VirtIfNeighbor:        config.RouterId(conf.VirtIfNeighbor),
+		VirtIfTransitDelay:    config.UpToMaxAge(conf.VirtIfTransitDelay),
+		VirtIfRetransInterval: config.UpToMaxAge(conf.VirtIfRetransInterval),
+		VirtIfHelloInterval:   config.HelloRange(conf.VirtIfHelloInterval),
+		VirtIfRtrDeadInterval: config.PositiveInteger(conf.VirtIfRtrDeadInterval),
+		VirtIfAuthKey:         conf.VirtIfAuthKey,
+		VirtIfAuthType:        config.AuthType(conf.VirtIfAuthType),
+	}
+	areaId := convertAreaOrRouterId(conf.VirtIfAreaId)
+	if areaId == nil {
+		return vConf, errors.New("Invalid transit area id")
+	}
+	if convertIPv4ToUint32(areaId) == 0 {
+		return vConf, errors.New("Backbone can not be a transit area")
+	}
+	if convertAreaOrRouterId(conf.VirtIfNeighbor) == nil {
+		return vConf, errors.New("Invalid virtual neighbor router id")
+	}
+	if vConf.VirtIfTransitDelay == 0 {
+		vConf.VirtIfTransitDelay = config.UpToMaxAge(1)
+	}
+	if vConf.VirtIfRetransInterval == 0 {
+		vConf.VirtIfRetransInterval = config.UpToMaxAge(5)
+	}
+	if vConf.VirtIfHelloInterval == 0 {
+		vConf.VirtIfHelloInterval = config.HelloRange(10)
+	}
+	if vConf.VirtIfRtrDeadInterval == 0 {
+		vConf.VirtIfRtrDeadInterval = config.PositiveInteger(60)
+	}
+	if vConf.VirtIfAuthKey != "" && convertAuthKey(vConf.VirtIfAuthKey) == nil {
+		return vConf, errors.New("Invalid authentication key")
+	}
+	keyChain, err := ConvertAuthKeyChain(conf.VirtIfAuthKeyChain)
+	if err != nil {
+		return vConf, err
+	}
+	vConf.VirtIfAuthKeyChain = keyChain
+	if vConf.VirtIfAuthType == config.Md5 && len(keyChain) == 0 {
+		return vConf, errors.New("Key chain is required for the cryptographic authentication")
+	}
+	return vConf, nil
+}
+
+func getVirtLinkKey(conf config.VirtIfConf) (VirtLinkKey, error) {
+	var key VirtLinkKey
+	areaId := convertAreaOrRouterId(string(conf.VirtIfAreaId))
+	if areaId == nil {
+		return key, errors.New("Invalid transit area id")
+	}
+	nbrRtrId := convertAreaOrRouterId(string(conf.VirtIfNeighbor))
+	if nbrRtrId == nil {
+		return key, errors.New("Invalid virtual neighbor router id")
+	}
+	key.TransitArea = convertIPv4ToUint32(areaId)
+	key.NbrRtrId = convertIPv4ToUint32(nbrRtrId)
+	return key, nil
+}
+
+func (server *OSPFServer) checkVirtLinkTransitArea(areaId config.AreaId) error {
+	if _, exist := server.AreaConfMap[AreaConfKey{AreaId: areaId}]; !exist {
+		return errors.New("Transit area is not configured")
+	}
+	if server.isStubArea(areaId) || server.isNssaArea(areaId) {
+		return errors.New("Stub or NSSA area can not be a transit area")
+	}
+	if _, exist := server.AreaConfMap[AreaConfKey{AreaId: "0.0.0.0"}]; !exist {
+		return errors.New("Backbone area is not configured")
+	}
+	return nil
+}
+
+/*
+@fn processVirtLinkConfig
+Add, update or delete a virtual link. The virtual interface is
+restarted on update so that the new timers and authentication
+take effect.
+*/
+func (server *OSPFServer) processVirtLinkConfig(msg VirtLinkMsg) error {
+	key, err := getVirtLinkKey(msg.Conf)
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("VIRTLINK: Invalid virtual link ", msg.Conf, err))
+		return err
+	}
+	if !msg.IsDel {
+		areaId := config.AreaId(convertUint32ToIPv4(key.TransitArea))
+		if err := server.checkVirtLinkTransitArea(areaId); err != nil {
+			server.logger.Err(fmt.Sprintln("VIRTLINK: Invalid virtual link ", msg.Conf, err))
+			return err
+		}
+	}
+
+	server.virtLinkMutex.Lock()
+	ent, exist := server.VirtLinkMap[key]
+	if msg.IsDel {
+		if !exist {
+			server.virtLinkMutex.Unlock()
+			return errors.New("Virtual link does not exist")
+		}
+		delete(server.VirtLinkMap, key)
+		server.virtLinkMutex.Unlock()
+		server.stopVirtLink(ent.IntfKey)
+		delete(server.IntfConfMap, ent.IntfKey)
+		server.logger.Info(fmt.Sprintln("VIRTLINK: Deleted virtual link ", key))
+		return nil
+	}
+	if !exist {
+		ent.IntfKey = server.allocVirtIntfKey(key.NbrRtrId)
+	}
+	ent.Conf = msg.Conf
+	server.VirtLinkMap[key] = ent
+	server.virtLinkMutex.Unlock()
+
+	server.stopVirtLink(ent.IntfKey)
+	server.initVirtIntfConf(ent.IntfKey, msg.Conf)
+	server.logger.Info(fmt.Sprintln("VIRTLINK: Configured virtual link ", key, ent.IntfKey))
+	server.processVirtLinkChange()
+	return nil
+}
+
+/* Virtual interfaces are keyed by the virtual neighbor router id */
+func (server *OSPFServer) allocVirtIntfKey(nbrRtrId uint32) IntfConfKey {
+	idx := OSPF_VIRT_LINK_IFIDX_BASE
+	for {
+		inUse := false
+		for _, ent := range server.VirtLinkMap {
+			if ent.IntfKey.IntfIdx == idx {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			break
+		}
+		idx++
+	}
+	return IntfConfKey{
+		IPAddr:  config.IpAddress(convertUint32ToIPv4(nbrRtrId)),
+		IntfIdx: idx,
+	}
+}
+
+func (server *OSPFServer) initVirtIntfConf(key IntfConfKey, conf config.VirtIfConf) {
+	ent, exist := server.IntfConfMap[key]
+	if !exist {
+		ent.IfAreaId = convertAreaOrRouterId("0.0.0.0")
+		ent.IfType = config.VirtualLink
+		ent.IfAdminStat = config.Disabled
+		ent.IfFSMState = config.Down
+		ent.IfMulticastForwarding = config.Blocked
+		ent.IfCryptoAuth = NewIntfCryptoAuth()
+		ent.FSMCtrlCh = make(chan bool)
+		ent.FSMCtrlStatusCh = make(chan bool)
+		ent.BackupSeenCh = make(chan BackupSeenMsg)
+		ent.NeighCreateCh = make(chan NeighCreateMsg)
+		ent.NeighChangeCh = make(chan NeighChangeMsg)
+		ent.NbrStateChangeCh = make(chan NbrStateChangeMsg)
+		ent.NbrFullStateCh = make(chan NbrFullStateMsg)
+		ent.IfNetmask = []byte{0, 0, 0, 0}
+		ent.IfDRIp = []byte{0, 0, 0, 0}
+		ent.IfBDRIp = []byte{0, 0, 0, 0}
+		ent.IfMetricTOSMap = make(map[uint8]uint32)
+	}
+	ent.IfTransitDelay = conf.VirtIfTransitDelay
+	ent.IfRetransInterval = conf.VirtIfRetransInterval
+	ent.IfHelloInterval = uint16(conf.VirtIfHelloInterval)
+	ent.IfRtrDeadInterval = uint32(conf.VirtIfRtrDeadInterval)
+	authKey := convertAuthKey(conf.VirtIfAuthKey)
+	if authKey == nil {
+		authKey = convertAuthKey("0.0.0.0.0.0.0.0")
+	}
+	ent.IfAuthKey = authKey
+	ent.IfAuthType = uint16(conf.VirtIfAuthType)
+	ent.IfCryptoAuth.setKeyChain(conf.VirtIfAuthKeyChain)
+	server.IntfConfMap[key] = ent
+}
+
+/*
+@fn processVirtLinkChange
+Bring the virtual interfaces up or down following the transit
+area end points computed by SPF.
+*/
+func (server *OSPFServer) processVirtLinkChange() {
+	server.virtLinkMutex.RLock()
+	virtLinks := make(map[VirtLinkKey]VirtLinkEnt, len(server.VirtLinkMap))
+	for key, ent := range server.VirtLinkMap {
+		virtLinks[key] = ent
+	}
+	server.virtLinkMutex.RUnlock()
+
+	for key, ent := range virtLinks {
+		intf, exist := server.IntfConfMap[ent.IntfKey]
+		if !exist {
+			continue
+		}
+		ep := ent.EndPoint
+		isUp := intf.IfAdminStat == config.Enabled
+		if isUp && (!ep.Reachable ||
+			convertAreaOrRouterIdUint32(intf.IfIpAddr.String()) != ep.IfIpAddr ||
+			convertAreaOrRouterIdUint32(intf.IfVirtNbrIpAddr.String()) != ep.NbrIpAddr) {
+			server.stopVirtLink(ent.IntfKey)
+			isUp = false
+		}
+		if !ep.Reachable || server.ospfGlobalConf.AdminStat != config.Enabled {
+			continue
+		}
+		if !isUp {
+			server.startVirtLink(key, ent)
+			continue
+		}
+		/* Same end points, the cost or next hop of the transit path changed */
+		_, nextHopMac, exist := server.getVirtLinkNextHopMac(key.TransitArea, ep)
+		if exist {
+			intf.IfVirtNextHopMac = nextHopMac
+		}
+		costChanged := intf.IfCost != uint32(ep.Cost)
+		intf.IfCost = uint32(ep.Cost)
+		server.IntfConfMap[ent.IntfKey] = intf
+		if costChanged {
+			notifyConfChange(server.virtLinkLsaCh)
+		}
+	}
+}
+
+/* Transit area interface of the end point and the MAC of its next hop */
+func (server *OSPFServer) getVirtLinkNextHopMac(transitArea uint32, ep VirtLinkEndPoint) (IntfConfKey, net.HardwareAddr, bool) {
+	for key, intf := range server.IntfConfMap {
+		if intf.IfType == config.VirtualLink ||
+			intf.IfAreaId == nil || intf.IfIpAddr == nil ||
+			convertIPv4ToUint32(intf.IfAreaId) != transitArea ||
+			convertAreaOrRouterIdUint32(intf.IfIpAddr.String()) != ep.IfIpAddr {
+			continue
+		}
+		nbrKey := NeighborConfKey{
+			IPAddr:  config.IpAddress(convertUint32ToIPv4(ep.NextHopIP)),
+			IntfIdx: key.IntfIdx,
+		}
+		mac, exist := ospfNeighborIPToMAC[nbrKey]
+		return key, mac, exist
+	}
+	return IntfConfKey{}, nil, false
+}
+
+func (server *OSPFServer) startVirtLink(key VirtLinkKey, ent VirtLinkEnt) {
+	phyKey, nextHopMac, exist := server.getVirtLinkNextHopMac(key.TransitArea, ent.EndPoint)
+	if !exist {
+		server.logger.Info(fmt.Sprintln("VIRTLINK: Next hop not resolved for virtual link ", key))
+		return
+	}
+	phyIntf := server.IntfConfMap[phyKey]
+	intf := server.IntfConfMap[ent.IntfKey]
+	intf.IfIpAddr = phyIntf.IfIpAddr
+	intf.IfMacAddr = phyIntf.IfMacAddr
+	intf.IfName = phyIntf.IfName
+	intf.IfMtu = phyIntf.IfMtu
+	intf.IfCost = uint32(ent.EndPoint.Cost)
+	intf.IfVirtNbrIpAddr = net.ParseIP(convertUint32ToIPv4(ent.EndPoint.NbrIpAddr)).To4()
+	intf.IfVirtNextHopMac = nextHopMac
+	intf.IfAdminStat = config.Enabled
+	server.IntfConfMap[ent.IntfKey] = intf
+	server.IntfTxMap[ent.IntfKey] = server.IntfTxMap[phyKey]
+	server.updateIntfToAreaMap(ent.IntfKey, "none", "0.0.0.0")
+	server.logger.Info(fmt.Sprintln("VIRTLINK: Virtual link up ", key, " via ", phyKey))
+	server.StartSendRecvPkts(ent.IntfKey)
+}
+
+func (server *OSPFServer) stopVirtLink(intfKey IntfConfKey) {
+	intf, exist := server.IntfConfMap[intfKey]
+	if !exist || intf.IfAdminStat != config.Enabled {
+		return
+	}
+	if intf.IfFSMState != config.Down {
+		server.StopSendRecvPkts(intfKey)
+		intf = server.IntfConfMap[intfKey]
+	}
+	intf.IfAdminStat = config.Disabled
+	server.IntfConfMap[intfKey] = intf
+	delete(server.IntfTxMap, intfKey)
+	server.updateIntfToAreaMap(intfKey, "0.0.0.0", "none")
+	server.logger.Info(fmt.Sprintln("VIRTLINK: Virtual link down ", intfKey))
+	if server.ospfGlobalConf.AdminStat == config.Enabled {
+		server.IntfStateChangeCh <- NetworkLSAChangeMsg{
+			areaId:  0,
+			intfKey: intfKey,
+		}
+	}
+	notifyConfChange(server.virtLinkLsaCh)
+}
+
+/*
+@fn updateVirtLinks
+RFC 2328 16.1, after the intra-area routes of the transit area are
+calculated, the virtual link end points are taken from the routing
+table entry of the virtual neighbor.
+*/
+func (server *OSPFServer) updateVirtLinks() {
+	changed := false
+	server.virtLinkMutex.Lock()
+	for key, ent := range server.VirtLinkMap {
+		endPoint := server.getVirtLinkEndPoint(key)
+		if endPoint != ent.EndPoint {
+			ent.EndPoint = endPoint
+			server.VirtLinkMap[key] = ent
+			changed = true
+		}
+	}
+	server.virtLinkMutex.Unlock()
+	if changed {
+		server.logger.Info("VIRTLINK: Virtual link end points changed")
+		notifyConfChange(server.virtLinkChangeCh)
+	}
+}
+
+func (server *OSPFServer) getVirtLinkEndPoint(key VirtLinkKey) VirtLinkEndPoint {
+	var endPoint VirtLinkEndPoint
+	rTbl := server.IntraAreaRoutingTbl[AreaIdKey{AreaId: key.TransitArea}]
+	for _, destType := range []DestType{InternalRouter, ASBdrRouter, AreaBdrRouter, ASAreaBdrRouter} {
+		rKey := RoutingTblEntryKey{
+			DestId:   key.NbrRtrId,
+			AddrMask: 0,
+			DestType: destType,
+		}
+		rEnt, exist := rTbl.RoutingTblMap[rKey]
+		if !exist || len(rEnt.NextHops) == 0 {
+			continue
+		}
+		nbrIpAddr := server.getVirtNbrIpAddr(key)
+		if nbrIpAddr == 0 {
+			return endPoint
+		}
+		/* Lowest next hop so that equal cost paths do not
+		   flap the virtual link between SPF runs */
+		first := true
+		for nextHop, _ := range rEnt.NextHops {
+			if first || nextHop.NextHopIP < endPoint.NextHopIP {
+				endPoint.IfIpAddr = nextHop.IfIPAddr
+				endPoint.NextHopIP = nextHop.NextHopIP
+				first = false
+			}
+		}
+		endPoint.Reachable = true
+		endPoint.Cost = rEnt.Cost
+		endPoint.NbrIpAddr = nbrIpAddr
+		return endPoint
+	}
+	return endPoint
+}
+
+/* Virtual neighbor address is its interface address in the transit area */
+func (server *OSPFServer) getVirtNbrIpAddr(key VirtLinkKey) uint32 {
+	lsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      key.NbrRtrId,
+		AdvRouter: key.NbrRtrId,
+	}
+	lsa, exist := server.AreaLsdb[LsdbKey{AreaId: key.TransitArea}].RouterLsaMap[lsaKey]
+	if !exist {
+		return 0
+	}
+	for _, link := range lsa.LinkDetails {
+		if link.LinkType == TransitLink || link.LinkType == P2PLink {
+			return link.LinkData
+		}
+	}
+	return 0
+}
+
+/*
+@fn getVirtLinkNextHop
+Backbone destinations reached over a virtual link use the transit
+area next hop to the virtual neighbor.
+*/
+func (server *OSPFServer) getVirtLinkNextHop(vFirst VertexKey, vSecond VertexKey, areaId uint32) (uint32, uint32, bool) {
+	rtrId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+	if areaId != 0 || vFirst.ID != rtrId {
+		return 0, 0, false
+	}
+	lsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      rtrId,
+		AdvRouter: rtrId,
+	}
+	lsa := server.AreaLsdb[LsdbKey{AreaId: areaId}].RouterLsaMap[lsaKey]
+	isVirtLink := false
+	for _, link := range lsa.LinkDetails {
+		if link.LinkType == VirtualLink && link.LinkId == vSecond.ID {
+			isVirtLink = true
+			break
+		}
+	}
+	if !isVirtLink {
+		return 0, 0, false
+	}
+
+	server.virtLinkMutex.RLock()
+	defer server.virtLinkMutex.RUnlock()
+	var endPoint VirtLinkEndPoint
+	for key, ent := range server.VirtLinkMap {
+		if key.NbrRtrId != vSecond.ID || !ent.EndPoint.Reachable {
+			continue
+		}
+		if !endPoint.Reachable || ent.EndPoint.Cost < endPoint.Cost {
+			endPoint = ent.EndPoint
+		}
+	}
+	if !endPoint.Reachable {
+		return 0, 0, false
+	}
+	return endPoint.IfIpAddr, endPoint.NextHopIP, true
+}
+
+/*
+@fn getVirtLinkRxIntf
+Backbone packets from a virtual neighbor arrive on the transit area
+interface, they are handed to the virtual interface.
+*/
+func (server *OSPFServer) getVirtLinkRxIntf(key IntfConfKey, ospfPkt []byte) (IntfConfKey, bool) {
+	if len(ospfPkt) < OSPF_HEADER_SIZE ||
+		binary.BigEndian.Uint32(ospfPkt[8:12]) != 0 {
+		return key, false
+	}
+	intf, exist := server.IntfConfMap[key]
+	if !exist || intf.IfType == config.VirtualLink || intf.IfAreaId == nil {
+		return key, false
+	}
+	transitArea := convertIPv4ToUint32(intf.IfAreaId)
+	if transitArea == 0 {
+		return key, false
+	}
+	vKey := VirtLinkKey{
+		TransitArea: transitArea,
+		NbrRtrId:    binary.BigEndian.Uint32(ospfPkt[4:8]),
+	}
+	server.virtLinkMutex.RLock()
+	ent, exist := server.VirtLinkMap[vKey]
+	server.virtLinkMutex.RUnlock()
+	if !exist {
+		return key, false
+	}
+	virtIntf, exist := server.IntfConfMap[ent.IntfKey]
+	if !exist || virtIntf.IfFSMState != config.P2P {
+		return key, false
+	}
+	return ent.IntfKey, true
+}
+
+/* Full neighbor of the virtual interface */
+func (server *OSPFServer) getFullVirtNbr(intf IntfConf) (OspfNeighborEntry, bool) {
+	for nbrKey, _ := range intf.NeighborMap {
+		nbr, exist := server.NeighborConfigMap[nbrKey]
+		if exist && nbr.OspfNbrState == config.NbrFull {
+			return nbr, true
+		}
+	}
+	return OspfNeighborEntry{}, false
+}
+
+/*
+@fn isVirtLinkTransitArea
+RFC 2328 12.4.1, the V-bit is set in the router-LSA of a transit
+area with fully adjacent virtual links.
+*/
+func (server *OSPFServer) isVirtLinkTransitArea(areaId uint32) bool {
+	if areaId == 0 {
+		return false
+	}
+	server.virtLinkMutex.RLock()
+	defer server.virtLinkMutex.RUnlock()
+	for key, ent := range server.VirtLinkMap {
+		if key.TransitArea != areaId {
+			continue
+		}
+		intf, exist := server.IntfConfMap[ent.IntfKey]
+		if !exist || intf.IfFSMState != config.P2P {
+			continue
+		}
+		if _, full := server.getFullVirtNbr(intf); full {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+@fn updateVirtLinkTransitAreas
+Regenerate the router-LSAs whose V-bit no longer matches the
+state of the virtual adjacencies.
+*/
+func (server *OSPFServer) updateVirtLinkTransitAreas() {
+	rtrId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+	lsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      rtrId,
+		AdvRouter: rtrId,
+	}
+	for areaKey, _ := range server.AreaConfMap {
+		areaId := convertAreaOrRouterIdUint32(string(areaKey.AreaId))
+		lsa, exist := server.AreaLsdb[LsdbKey{AreaId: areaId}].RouterLsaMap[lsaKey]
+		if !exist || lsa.BitV == server.isVirtLinkTransitArea(areaId) {
+			continue
+		}
+		server.generateVirtLinkAreaLsa(areaId)
+	}
+}
+
+func (server *OSPFServer) processVirtLinkLsaChange() {
+	server.generateVirtLinkAreaLsa(0)
+	server.updateVirtLinkTransitAreas()
+}
+
+func (server *OSPFServer) generateVirtLinkAreaLsa(areaId uint32) {
+	if !server.generateThrottledLsa(areaId, IntfConfKey{}, false) {
+		return
+	}
+	rtrId := convertIPv4ToUint32(server.ospfGlobalConf.RouterId)
+	lsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      rtrId,
+		AdvRouter: rtrId,
+	}
+	server.sendLsdbToNeighborEvent(IntfConfKey{}, NeighborConfKey{}, areaId, 0, 0, lsaKey, LSAROUTERFLOOD)
+	server.scheduleSpf(SpfFull, "Virtual link change area "+convertUint32ToIPv4(areaId))
+}
+
+/* Virtual link packets are routed through the transit area */
+func getOspfPktTTL(ent IntfConf) uint8 {
+	if ent.IfType == config.VirtualLink {
+		return OSPF_VIRT_LINK_TTL
+	}
+	return uint8(1)
+}
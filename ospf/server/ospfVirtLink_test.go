@@ -0,0 +1,187 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"l3/ospf/config"
+	"ospfd"
+	"testing"
+)
+
+func TestConvertVirtIfConf(t *testing.T) {
+	conf := ospfd.NewOspfVirtIfEntry()
+	conf.VirtIfAreaId = "0.0.0.0"
+	conf.VirtIfNeighbor = "10.0.0.9"
+	if _, err := ConvertVirtIfConf(conf); err == nil {
+		t.Errorf("Backbone accepted as transit area")
+	}
+	conf.VirtIfAreaId = "0.0.0.1"
+	vConf, err := ConvertVirtIfConf(conf)
+	if err != nil {
+		t.Fatalf("Failed to convert virtual link %v", err)
+	}
+	if vConf.VirtIfHelloInterval != 10 || vConf.VirtIfRtrDeadInterval != 60 ||
+		vConf.VirtIfRetransInterval != 5 || vConf.VirtIfTransitDelay != 1 {
+		t.Errorf("Invalid virtual link defaults %+v", vConf)
+	}
+	conf.VirtIfAuthType = int32(config.Md5)
+	if _, err = ConvertVirtIfConf(conf); err == nil {
+		t.Errorf("Cryptographic authentication accepted without key chain")
+	}
+}
+
+func initVirtLinkTestServer() *OSPFServer {
+	server := getAreaTestServer("0.0.0.0", "0.0.0.1")
+	/* Virtual neighbor 10.0.0.9 is reached in area 1 through 10.1.1.2 */
+	rKey := RoutingTblEntryKey{
+		DestId:   0x0a000009,
+		AddrMask: 0,
+		DestType: AreaBdrRouter,
+	}
+	rEnt := RoutingTblEntry{
+		PathType:   IntraArea,
+		Cost:       20,
+		NumOfPaths: 1,
+		NextHops: map[NextHop]bool{
+			NextHop{IfIPAddr: 0x0a010101, NextHopIP: 0x0a010102}: true,
+		},
+	}
+	server.IntraAreaRoutingTbl = make(map[AreaIdKey]AreaRoutingTbl)
+	server.IntraAreaRoutingTbl[AreaIdKey{AreaId: 1}] = AreaRoutingTbl{
+		RoutingTblMap: map[RoutingTblEntryKey]RoutingTblEntry{rKey: rEnt},
+	}
+	nbrLsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      0x0a000009,
+		AdvRouter: 0x0a000009,
+	}
+	server.AreaLsdb[LsdbKey{AreaId: 1}] = LSDatabase{
+		RouterLsaMap: map[LsaKey]RouterLsa{
+			nbrLsaKey: RouterLsa{
+				LinkDetails: []LinkDetail{
+					{LinkId: 0x0a010209, LinkData: 0x0a010209, LinkType: TransitLink},
+				},
+			},
+		},
+	}
+	return server
+}
+
+func TestVirtLinkEndPoint(t *testing.T) {
+	server := initVirtLinkTestServer()
+	vConf := config.VirtIfConf{
+		VirtIfAreaId:          "0.0.0.1",
+		VirtIfNeighbor:        "10.0.0.9",
+		VirtIfTransitDelay:    1,
+		VirtIfRetransInterval: 5,
+		VirtIfHelloInterval:   10,
+		VirtIfRtrDeadInterval: 60,
+	}
+	if err := server.processVirtLinkConfig(VirtLinkMsg{Conf: vConf}); err != nil {
+		t.Fatalf("Failed to add virtual link %v", err)
+	}
+	key := VirtLinkKey{
+		TransitArea: 1,
+		NbrRtrId:    0x0a000009,
+	}
+	ent, exist := server.VirtLinkMap[key]
+	if !exist {
+		t.Fatalf("Virtual link not created")
+	}
+	if intf := server.IntfConfMap[ent.IntfKey]; intf.IfType != config.VirtualLink ||
+		intf.IfRtrDeadInterval != 60 || intf.IfFSMState != config.Down {
+		t.Errorf("Invalid virtual interface %+v", intf)
+	}
+
+	server.updateVirtLinks()
+	select {
+	case <-server.virtLinkChangeCh:
+	default:
+		t.Errorf("Virtual link change not notified")
+	}
+	expected := VirtLinkEndPoint{
+		Reachable: true,
+		Cost:      20,
+		IfIpAddr:  0x0a010101,
+		NbrIpAddr: 0x0a010209,
+		NextHopIP: 0x0a010102,
+	}
+	if ep := server.VirtLinkMap[key].EndPoint; ep != expected {
+		t.Errorf("Invalid virtual link end point %+v", ep)
+	}
+
+	/* Backbone routes via the virtual neighbor use the transit area next hop */
+	rtrLsaKey := LsaKey{
+		LSType:    RouterLSA,
+		LSId:      0x0a000005,
+		AdvRouter: 0x0a000005,
+	}
+	server.AreaLsdb[LsdbKey{AreaId: 0}] = LSDatabase{
+		RouterLsaMap: map[LsaKey]RouterLsa{
+			rtrLsaKey: RouterLsa{
+				LinkDetails: []LinkDetail{
+					{LinkId: 0x0a000009, LinkData: 0x0a010101, LinkType: VirtualLink},
+				},
+			},
+		},
+	}
+	vFirst := VertexKey{Type: RouterVertex, ID: 0x0a000005, AdvRtr: 0x0a000005}
+	vSecond := VertexKey{Type: RouterVertex, ID: 0x0a000009, AdvRtr: 0x0a000009}
+	ifIPAddr, nextHopIP, exist := server.getVirtLinkNextHop(vFirst, vSecond, 0)
+	if !exist || ifIPAddr != 0x0a010101 || nextHopIP != 0x0a010102 {
+		t.Errorf("Invalid virtual link next hop %x %x %v", ifIPAddr, nextHopIP, exist)
+	}
+	if _, _, exist = server.getVirtLinkNextHop(vFirst, vSecond, 1); exist {
+		t.Errorf("Virtual link next hop used outside the backbone")
+	}
+
+	delete(server.IntraAreaRoutingTbl, AreaIdKey{AreaId: 1})
+	server.updateVirtLinks()
+	if ep := server.VirtLinkMap[key].EndPoint; ep.Reachable {
+		t.Errorf("Unreachable virtual neighbor %+v", ep)
+	}
+
+	server.processVirtLinkConfig(VirtLinkMsg{Conf: vConf, IsDel: true})
+	if _, exist = server.IntfConfMap[ent.IntfKey]; exist {
+		t.Errorf("Virtual interface not deleted")
+	}
+}
+
+func TestVirtLinkStubTransitArea(t *testing.T) {
+	server := initVirtLinkTestServer()
+	server.AreaConfMap[AreaConfKey{AreaId: "0.0.0.1"}] = AreaConf{
+		ImportAsExtern: config.ImportNoExternal,
+	}
+	vConf := config.VirtIfConf{
+		VirtIfAreaId:   "0.0.0.1",
+		VirtIfNeighbor: "10.0.0.9",
+	}
+	if err := server.processVirtLinkConfig(VirtLinkMsg{Conf: vConf}); err == nil {
+		t.Errorf("Virtual link accepted through a stub area")
+	}
+	vConf.VirtIfAreaId = "0.0.0.2"
+	if err := server.processVirtLinkConfig(VirtLinkMsg{Conf: vConf}); err == nil {
+		t.Errorf("Virtual link accepted through an unknown area")
+	}
+}
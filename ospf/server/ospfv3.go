@@ -0,0 +1,531 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"asicd/asicdCommonDefs"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"net"
+	"ospfd"
+	"strings"
+	"time"
+)
+
+/*
+RFC 5340 OSPF for IPv6
+
+OSPFv3 runs per link instead of per subnet. Adjacencies are formed over
+IPv6 link-local addresses and neighbors are identified by their
+Router ID on every link type. Addressing semantics are removed from
+Router and Network LSAs and carried by the new LSAs
+	0x0008 Link LSA              - link-local scope
+	0x2009 Intra-Area-Prefix LSA - area scope
+	0x2003 Inter-Area-Prefix LSA - area scope (replaces Type 3)
+	0x2004 Inter-Area-Router LSA - area scope (replaces Type 4)
+
+The OSPFv3 instance is driven by a single event loop. Interface, neighbor
+and LSDB state is owned by that loop and guarded by v3Mutex for the
+read only getbulk calls. The shortest path tree is calculated with the
+same Dijkstra implementation as the OSPFv2 instance and the routes are
+installed in RIBd as IPv6 routes.
+
+Not supported: virtual links, stub/NSSA areas and AS-external routes.
+AS scope LSAs and Inter-Area-Router LSAs received from neighbors are
+flooded but not used for the route calculation. Inter-Area-Router LSAs
+only describe the paths to AS boundary routers, so they are not
+originated either.
+*/
+
+const (
+	OSPF_VERSION_3        = 3
+	OSPFV3_HEADER_SIZE    = 16
+	OSPFV3_HELLO_MIN_SIZE = 20
+	OSPFV3_DBD_MIN_SIZE   = 12
+	OSPFV3_LSA_REQ_SIZE   = 12
+	OSPFV3_NO_OF_LSA_SIZE = 4
+	IPV6_HEADER_SIZE      = 40
+)
+
+var ALLSPFROUTERV6 string = "ff02::5"
+var ALLDROUTERV6 string = "ff02::6"
+var ALLSPFROUTERV6MAC string = "33:33:00:00:00:05"
+var ALLDROUTERV6MAC string = "33:33:00:00:00:06"
+
+/* RFC 5340 A.2 Options field */
+const (
+	V6Option   = 0x01
+	V3EOption  = 0x02
+	V3NOption  = 0x08
+	ROption    = 0x10
+	V3DCOption = 0x20
+)
+
+const (
+	OSPFV3_DEFAULT_OPTIONS uint32 = V6Option | V3EOption | ROption
+	V3_RXMT_MAX_LSA               = 20
+)
+
+type V3IntfConfKey struct {
+	IfIndex int32
+}
+
+type V3NbrKey struct {
+	IfIndex  int32
+	RouterId uint32
+}
+
+type V3IntfConf struct {
+	IfIndex         int32
+	IfName          string
+	IfMacAddr       net.HardwareAddr
+	IfMtu           int32
+	AreaId          uint32
+	InstanceId      uint8
+	AdminStat       config.Status
+	IfType          config.IfType
+	RtrPriority     uint8
+	TransitDelay    uint16
+	RetransInterval uint16
+	HelloInterval   uint16
+	RtrDeadInterval uint32
+	CfgCost         int32
+	Cost            uint16
+	LinkLocalAddr   net.IP
+	Prefixes        map[string]V3Prefix
+	FSMState        config.IfState
+	DRtrId          uint32
+	BDRtrId         uint32
+	DRIntfId        uint32
+	HelloTimer      uint32
+	WaitTimer       uint32
+	Events          int
+	LinkLsdb        V3Lsdb
+}
+
+type V3NbrEnt struct {
+	RouterId      uint32
+	IntfId        uint32
+	Addr          net.IP
+	MacAddr       net.HardwareAddr
+	Priority      uint8
+	Options       uint32
+	DRtrId        uint32
+	BDRtrId       uint32
+	State         config.NbrState
+	Events        int
+	DeadTimer     uint32
+	IsMaster      bool
+	DDSeqNum      uint32
+	LastRxDbd     Ospfv3Dbd
+	LastTxDbd     []byte
+	DbdRxmtTimer  uint32
+	DbSummaryList []V3LsaHeader
+	LsReqList     []V3LsaHeader
+	LsReqRxmtTime uint32
+	LsRetxList    map[V3LsaKey]bool
+	LsRetxTimer   uint32
+}
+
+/* IPv6 addresses of an interface notified by asicd */
+type V3IntfAddr struct {
+	LinkLocalAddr net.IP
+	Prefixes      map[string]V3Prefix
+}
+
+type V3IntfNotifyMsg struct {
+	IfIndex int32
+	IpAddr  string // CIDR Notation
+	IsDel   bool
+}
+
+type V3RxPktMsg struct {
+	key    V3IntfConfKey
+	hdr    Ospfv3Header
+	srcIP  net.IP
+	dstIP  net.IP
+	srcMAC net.HardwareAddr
+	data   []byte
+}
+
+func (server *OSPFServer) initOspfv3Server() {
+	server.V3IntfConfMap = make(map[V3IntfConfKey]V3IntfConf)
+	server.V3IntfTxMap = make(map[V3IntfConfKey]IntfTxHandle)
+	server.V3IntfRxMap = make(map[V3IntfConfKey]IntfRxHandle)
+	server.V3IntfAddrMap = make(map[int32]V3IntfAddr)
+	server.V3NbrMap = make(map[V3NbrKey]V3NbrEnt)
+	server.V3AreaLsdb = make(map[uint32]V3Lsdb)
+	server.V3AsLsdb = make(V3Lsdb)
+	server.V3RoutingTbl = make(map[string]V3RouteEnt)
+	server.V3InterAreaLsIdMap = make(map[V3InterAreaKey]uint32)
+	server.v3OrigPending = make(map[uint32]bool)
+	server.v3ForceOrig = make(map[V3LsaKey]bool)
+	server.Ospfv3IntfConfigCh = make(chan config.Ospfv3IntfConf)
+	server.Ospfv3IntfDeleteCh = make(chan int32)
+	server.v3IntfNotifyCh = make(chan V3IntfNotifyMsg)
+	server.v3RxPktCh = make(chan V3RxPktMsg, 100)
+}
+
+func (server *OSPFServer) getV3RouterId() uint32 {
+	if len(server.ospfGlobalConf.RouterId) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(server.ospfGlobalConf.RouterId)
+}
+
+/*
+@fn StartOspfv3Server
+Event loop of the OSPFv3 instance. Every state change schedules the
+origination and SPF work which is run once the event is processed.
+*/
+func (server *OSPFServer) StartOspfv3Server() {
+	server.logger.Info("OSPFv3: Starting the event loop")
+	ticker := time.NewTicker(time.Second)
+	for {
+		select {
+		case ifConf := <-server.Ospfv3IntfConfigCh:
+			server.v3Mutex.Lock()
+			err := server.processOspfv3IntfConfig(ifConf)
+			if err != nil {
+				server.logger.Err(fmt.Sprintln("OSPFv3: Interface config failed ", ifConf.IfIndex, err))
+			}
+		case ifIndex := <-server.Ospfv3IntfDeleteCh:
+			server.v3Mutex.Lock()
+			server.deleteOspfv3IntfConfig(ifIndex)
+		case msg := <-server.v3IntfNotifyCh:
+			server.v3Mutex.Lock()
+			server.processOspfv3IntfNotify(msg)
+		case msg := <-server.v3RxPktCh:
+			server.v3Mutex.Lock()
+			err := server.processOspfv3Pkt(msg)
+			if err != nil {
+				server.logger.Err(fmt.Sprintln("OSPFv3: Dropped packet on ", msg.key.IfIndex, err))
+			}
+		case <-ticker.C:
+			server.v3Mutex.Lock()
+			server.processOspfv3IntfTimers()
+			server.processOspfv3NbrTimers()
+			server.processOspfv3LsdbAging()
+		}
+		server.processOspfv3PendingEvents()
+		server.v3Mutex.Unlock()
+	}
+}
+
+func (server *OSPFServer) scheduleOspfv3Origination(areaId uint32) {
+	server.v3OrigPending[areaId] = true
+}
+
+func (server *OSPFServer) scheduleOspfv3SPF() {
+	server.v3SpfPending = true
+}
+
+func (server *OSPFServer) processOspfv3PendingEvents() {
+	for areaId, _ := range server.v3OrigPending {
+		server.originateOspfv3AreaLsas(areaId)
+		delete(server.v3OrigPending, areaId)
+	}
+	if server.v3SpfPending {
+		server.v3SpfPending = false
+		server.ospfv3SpfCalculation()
+	}
+}
+
+/*
+@fn ConvertOspfv3IntfConf
+Converts and validates the thrift OSPFv3 interface object. Zero timers
+and cost are replaced by their defaults when the interface is applied.
+*/
+func ConvertOspfv3IntfConf(conf *ospfd.Ospfv3IntfEntry) (config.Ospfv3IntfConf, error) {
+	ifConf := config.Ospfv3IntfConf{
+		IfIndex:           conf.IfIndex,
+		IfAreaId:          config.AreaId(conf.IfAreaId),
+		IfInstanceId:      uint8(conf.IfInstanceId),
+		IfAdminStat:       config.Status(conf.IfAdminStat),
+		IfRtrPriority:     config.DesignatedRouterPriority(conf.IfRtrPriority),
+		IfTransitDelay:    config.UpToMaxAge(conf.IfTransitDelay),
+		IfRetransInterval: config.UpToMaxAge(conf.IfRetransInterval),
+		IfHelloInterval:   config.HelloRange(conf.IfHelloInterval),
+		IfRtrDeadInterval: config.PositiveInteger(conf.IfRtrDeadInterval),
+		IfCost:            conf.IfCost,
+	}
+	if conf.IfInstanceId < 0 || conf.IfInstanceId > 0xff {
+		return ifConf, errors.New("Invalid instance id")
+	}
+	if conf.IfRtrPriority < 0 || conf.IfRtrPriority > 0xff {
+		return ifConf, errors.New("Invalid router priority")
+	}
+	if conf.IfTransitDelay < 0 || conf.IfTransitDelay > int32(config.MaxAge) ||
+		conf.IfRetransInterval < 0 || conf.IfRetransInterval > int32(config.MaxAge) {
+		return ifConf, errors.New("Invalid transit delay or retransmit interval")
+	}
+	if conf.IfHelloInterval < 0 || conf.IfHelloInterval > 0xffff ||
+		conf.IfRtrDeadInterval < 0 || conf.IfRtrDeadInterval > 0xffff {
+		return ifConf, errors.New("Invalid hello or dead interval")
+	}
+	if conf.IfCost < 0 || conf.IfCost > 0xffff {
+		return ifConf, errors.New("Invalid interface cost")
+	}
+	if convertAreaOrRouterId(conf.IfAreaId) == nil {
+		return ifConf, errors.New("Invalid area id")
+	}
+	for index, ifName := range config.IfTypeList {
+		if strings.EqualFold(conf.IfType, ifName) {
+			ifConf.IfType = config.IfType(index)
+			break
+		}
+	}
+	return ifConf, nil
+}
+
+func (server *OSPFServer) processOspfv3IntfConfig(ifConf config.Ospfv3IntfConf) error {
+	if server.getV3RouterId() == 0 {
+		return errors.New("Router Id is not configured")
+	}
+	key := V3IntfConfKey{
+		IfIndex: ifConf.IfIndex,
+	}
+	ent, exist := server.V3IntfConfMap[key]
+	if exist && ent.FSMState != config.Down {
+		server.stopOspfv3Intf(key)
+		ent, _ = server.V3IntfConfMap[key]
+	}
+	if !exist {
+		ifType := uint8(asicdCommonDefs.GetIntfTypeFromIfIndex(ifConf.IfIndex))
+		ifId := asicdCommonDefs.GetIntfIdFromIfIndex(ifConf.IfIndex)
+		ifName, err := server.getLinuxIntfName(int32(ifId), ifType)
+		if err != nil {
+			return err
+		}
+		ent.IfIndex = ifConf.IfIndex
+		ent.IfName = ifName
+		ent.IfMacAddr, err = getMacAddrIntfName(ifName)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: Unable to get mac address of ", ifName, err))
+		}
+		ent.IfMtu = server.computeMinMTU(ifType, uint16(ifId))
+		ent.FSMState = config.Down
+		ent.LinkLsdb = make(V3Lsdb)
+	}
+	areaId := convertAreaOrRouterIdUint32(string(ifConf.IfAreaId))
+	if exist && ent.AreaId != areaId {
+		server.scheduleOspfv3Origination(ent.AreaId)
+	}
+	ent.AreaId = areaId
+	ent.InstanceId = ifConf.IfInstanceId
+	ent.AdminStat = ifConf.IfAdminStat
+	ent.IfType = ifConf.IfType
+	if ent.IfType != config.NumberedP2P && ent.IfType != config.UnnumberedP2P {
+		ent.IfType = config.Broadcast
+	}
+	ent.RtrPriority = uint8(ifConf.IfRtrPriority)
+	ent.TransitDelay = uint16(ifConf.IfTransitDelay)
+	ent.RetransInterval = uint16(ifConf.IfRetransInterval)
+	ent.HelloInterval = uint16(ifConf.IfHelloInterval)
+	ent.RtrDeadInterval = uint32(ifConf.IfRtrDeadInterval)
+	if ent.RetransInterval == 0 {
+		ent.RetransInterval = 5
+	}
+	if ent.HelloInterval == 0 {
+		ent.HelloInterval = 10
+	}
+	if ent.RtrDeadInterval == 0 {
+		ent.RtrDeadInterval = 4 * uint32(ent.HelloInterval)
+	}
+	ent.CfgCost = ifConf.IfCost
+	ent.Cost = server.getOspfv3IntfCost(ent)
+	server.V3IntfConfMap[key] = ent
+	if _, ok := server.V3AreaLsdb[areaId]; !ok {
+		server.V3AreaLsdb[areaId] = make(V3Lsdb)
+	}
+	server.updateOspfv3AreaBdrStatus()
+	if ent.AdminStat == config.Enabled {
+		return server.startOspfv3Intf(key)
+	}
+	return nil
+}
+
+func (server *OSPFServer) deleteOspfv3IntfConfig(ifIndex int32) {
+	key := V3IntfConfKey{
+		IfIndex: ifIndex,
+	}
+	ent, exist := server.V3IntfConfMap[key]
+	if !exist {
+		return
+	}
+	if ent.FSMState != config.Down {
+		server.stopOspfv3Intf(key)
+	}
+	delete(server.V3IntfConfMap, key)
+	server.updateOspfv3AreaBdrStatus()
+	server.scheduleOspfv3Origination(ent.AreaId)
+	server.logger.Info(fmt.Sprintln("OSPFv3: Deleted interface ", ifIndex))
+}
+
+func (server *OSPFServer) getOspfv3IntfCost(ent V3IntfConf) uint16 {
+	if ent.CfgCost > 0 && ent.CfgCost <= 0xffff {
+		return uint16(ent.CfgCost)
+	}
+	ifType := uint8(asicdCommonDefs.GetIntfTypeFromIfIndex(ent.IfIndex))
+	ifId := uint16(asicdCommonDefs.GetIntfIdFromIfIndex(ent.IfIndex))
+	cost, err := server.getIntfCost(ifId, ifType)
+	if err != nil || cost == 0 || cost > 0xffff {
+		return uint16(DEFAULT_VLAN_COST)
+	}
+	return uint16(cost)
+}
+
+/*
+@fn updateOspfv3AreaBdrStatus
+The router is an area border router when it has active OSPFv3
+interfaces in more than one area.
+*/
+func (server *OSPFServer) updateOspfv3AreaBdrStatus() {
+	areas := make(map[uint32]bool)
+	for _, ent := range server.V3IntfConfMap {
+		if ent.AdminStat == config.Enabled {
+			areas[ent.AreaId] = true
+		}
+	}
+	isABR := len(areas) > 1
+	if isABR != server.v3AreaBdrRtrStatus {
+		server.logger.Info(fmt.Sprintln("OSPFv3: Area border router status ", isABR))
+		server.v3AreaBdrRtrStatus = isABR
+		for areaId, _ := range areas {
+			server.scheduleOspfv3Origination(areaId)
+		}
+		server.scheduleOspfv3SPF()
+	}
+}
+
+/*
+@fn UpdateIPv6Infra
+IPv6 address notifications are handed over to the OSPFv3 event loop.
+*/
+func (server *OSPFServer) UpdateIPv6Infra(msg asicdCommonDefs.IPv6IntfNotifyMsg, msgType uint8) {
+	notifyMsg := V3IntfNotifyMsg{
+		IfIndex: msg.IfIndex,
+		IpAddr:  msg.IpAddr,
+		IsDel:   msgType == asicdCommonDefs.NOTIFY_IPV6INTF_DELETE,
+	}
+	server.v3IntfNotifyCh <- notifyMsg
+}
+
+func (server *OSPFServer) updateIPv6IntfAddrMap(msg V3IntfNotifyMsg) bool {
+	ip, ipNet, err := net.ParseCIDR(msg.IpAddr)
+	if err != nil || ip.To4() != nil {
+		server.logger.Err(fmt.Sprintln("OSPFv3: Invalid IPv6 address ", msg.IpAddr))
+		return false
+	}
+	ent, exist := server.V3IntfAddrMap[msg.IfIndex]
+	if !exist {
+		ent.Prefixes = make(map[string]V3Prefix)
+	}
+	if ip.IsLinkLocalUnicast() {
+		if msg.IsDel {
+			ent.LinkLocalAddr = nil
+		} else {
+			ent.LinkLocalAddr = ip
+		}
+	} else {
+		prefix := newV3Prefix(ipNet)
+		if msg.IsDel {
+			delete(ent.Prefixes, prefix.String())
+		} else {
+			ent.Prefixes[prefix.String()] = prefix
+		}
+	}
+	server.V3IntfAddrMap[msg.IfIndex] = ent
+	return true
+}
+
+func (server *OSPFServer) processOspfv3IntfNotify(msg V3IntfNotifyMsg) {
+	server.logger.Info(fmt.Sprintln("OSPFv3: IPv6 address notification ", msg))
+	if !server.updateIPv6IntfAddrMap(msg) {
+		return
+	}
+	key := V3IntfConfKey{
+		IfIndex: msg.IfIndex,
+	}
+	ent, exist := server.V3IntfConfMap[key]
+	if !exist || ent.AdminStat != config.Enabled {
+		return
+	}
+	addr := server.V3IntfAddrMap[msg.IfIndex]
+	if ent.FSMState == config.Down {
+		server.startOspfv3Intf(key)
+		return
+	}
+	if addr.LinkLocalAddr == nil {
+		server.stopOspfv3Intf(key)
+		return
+	}
+	ent.Prefixes = addr.Prefixes
+	server.V3IntfConfMap[key] = ent
+	server.originateOspfv3LinkLsa(key)
+	server.scheduleOspfv3Origination(ent.AreaId)
+}
+
+/*
+@fn getOspfv3LinkLocalAddr
+Link-local address is taken from the asicd notification and
+from the kernel interface otherwise.
+*/
+func (server *OSPFServer) getOspfv3LinkLocalAddr(ifIndex int32, ifName string) net.IP {
+	addr, _ := server.V3IntfAddrMap[ifIndex]
+	if addr.LinkLocalAddr != nil {
+		return addr.LinkLocalAddr
+	}
+	ifi, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return nil
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	for _, a := range addrs {
+		ip, _, err := net.ParseCIDR(a.String())
+		if err == nil && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			return ip
+		}
+	}
+	return nil
+}
+
+func (server *OSPFServer) dumpOspfv3Intf(key V3IntfConfKey) string {
+	ent, exist := server.V3IntfConfMap[key]
+	if !exist {
+		return ""
+	}
+	prefixes := make([]string, 0)
+	for p, _ := range ent.Prefixes {
+		prefixes = append(prefixes, p)
+	}
+	return fmt.Sprintln("IfIndex:", ent.IfIndex, "Name:", ent.IfName, "Area:", convertUint32ToIPv4(ent.AreaId),
+		"State:", ent.FSMState, "DR:", convertUint32ToIPv4(ent.DRtrId), "BDR:", convertUint32ToIPv4(ent.BDRtrId),
+		"LinkLocal:", ent.LinkLocalAddr, "Prefixes:", strings.Join(prefixes, ","))
+}
@@ -0,0 +1,365 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"net"
+)
+
+/* DR election candidate, RFC 2328 9.4 with Router IDs (RFC 5340 4.2.1) */
+type V3DRCand struct {
+	RouterId uint32
+	Priority uint8
+	DRtrId   uint32
+	BDRtrId  uint32
+}
+
+func (server *OSPFServer) startOspfv3Intf(key V3IntfConfKey) error {
+	ent, exist := server.V3IntfConfMap[key]
+	if !exist {
+		return errors.New("OSPFv3 interface does not exist")
+	}
+	ent.LinkLocalAddr = server.getOspfv3LinkLocalAddr(ent.IfIndex, ent.IfName)
+	if ent.LinkLocalAddr == nil {
+		server.logger.Info(fmt.Sprintln("OSPFv3: No link-local address on ", ent.IfName, " interface stays down"))
+		return nil
+	}
+	ent.Prefixes = make(map[string]V3Prefix)
+	for p, prefix := range server.V3IntfAddrMap[ent.IfIndex].Prefixes {
+		ent.Prefixes[p] = prefix
+	}
+	server.V3IntfConfMap[key] = ent
+	err := server.openOspfv3PcapHdls(key)
+	if err != nil {
+		return err
+	}
+	ent.DRtrId = 0
+	ent.BDRtrId = 0
+	ent.DRIntfId = 0
+	ent.Events++
+	ent.HelloTimer = uint32(ent.HelloInterval)
+	if ent.IfType != config.Broadcast {
+		ent.FSMState = config.P2P
+	} else if ent.RtrPriority == 0 {
+		ent.FSMState = config.OtherDesignatedRouter
+	} else {
+		ent.FSMState = config.Waiting
+		ent.WaitTimer = ent.RtrDeadInterval
+	}
+	server.V3IntfConfMap[key] = ent
+	server.logger.Info(fmt.Sprintln("OSPFv3: Interface up ", server.dumpOspfv3Intf(key)))
+	go server.startOspfv3RecvPkts(key, server.V3IntfRxMap[key])
+	server.sendOspfv3Hello(key)
+	server.originateOspfv3LinkLsa(key)
+	server.scheduleOspfv3Origination(ent.AreaId)
+	return nil
+}
+
+func (server *OSPFServer) stopOspfv3Intf(key V3IntfConfKey) {
+	for nbrKey, _ := range server.V3NbrMap {
+		if nbrKey.IfIndex == key.IfIndex {
+			server.deleteOspfv3Nbr(nbrKey)
+		}
+	}
+	server.closeOspfv3PcapHdls(key)
+	ent, _ := server.V3IntfConfMap[key]
+	ent.FSMState = config.Down
+	ent.DRtrId = 0
+	ent.BDRtrId = 0
+	ent.DRIntfId = 0
+	ent.Events++
+	ent.LinkLsdb = make(V3Lsdb)
+	server.V3IntfConfMap[key] = ent
+	server.logger.Info(fmt.Sprintln("OSPFv3: Interface down ", ent.IfIndex, ent.IfName))
+	server.scheduleOspfv3Origination(ent.AreaId)
+	server.scheduleOspfv3SPF()
+}
+
+func (server *OSPFServer) sendOspfv3Hello(key V3IntfConfKey) {
+	ent, _ := server.V3IntfConfMap[key]
+	hello := Ospfv3Hello{
+		IntfId:          uint32(ent.IfIndex),
+		RtrPriority:     ent.RtrPriority,
+		Options:         OSPFV3_DEFAULT_OPTIONS,
+		HelloInterval:   ent.HelloInterval,
+		RtrDeadInterval: uint16(ent.RtrDeadInterval),
+		DRtrId:          ent.DRtrId,
+		BDRtrId:         ent.BDRtrId,
+	}
+	for nbrKey, nbr := range server.V3NbrMap {
+		if nbrKey.IfIndex == key.IfIndex && nbr.State >= config.NbrInit {
+			hello.NbrList = append(hello.NbrList, nbr.RouterId)
+		}
+	}
+	err := server.sendOspfv3Pkt(key, net.ParseIP(ALLSPFROUTERV6), nil, HelloType, encodeOspfv3Hello(hello))
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("OSPFv3: Unable to send hello on ", ent.IfName, err))
+	}
+}
+
+/*
+@fn processOspfv3Hello
+RFC 5340 4.2.2.1 Receiving Hello packets. The neighbor is identified
+by its Router ID and the source address is its link-local address.
+*/
+func (server *OSPFServer) processOspfv3Hello(msg V3RxPktMsg) error {
+	var hello Ospfv3Hello
+	err := decodeOspfv3Hello(msg.data, &hello)
+	if err != nil {
+		return err
+	}
+	ent, _ := server.V3IntfConfMap[msg.key]
+	if hello.HelloInterval != ent.HelloInterval ||
+		uint32(hello.RtrDeadInterval) != ent.RtrDeadInterval {
+		return errors.New(fmt.Sprintln("Hello/Dead interval mismatch", hello.HelloInterval, hello.RtrDeadInterval))
+	}
+	if hello.Options&V3EOption == 0 {
+		return errors.New("E-bit mismatch")
+	}
+
+	nbrKey := V3NbrKey{
+		IfIndex:  msg.key.IfIndex,
+		RouterId: msg.hdr.RouterId,
+	}
+	nbr, exist := server.V3NbrMap[nbrKey]
+	if !exist {
+		nbr.RouterId = msg.hdr.RouterId
+		nbr.State = config.NbrDown
+		nbr.LsRetxList = make(map[V3LsaKey]bool)
+		server.logger.Info(fmt.Sprintln("OSPFv3: New neighbor ", convertUint32ToIPv4(nbr.RouterId), msg.srcIP, "on", ent.IfName))
+	}
+	prevPriority := nbr.Priority
+	prevDR := nbr.DRtrId == nbr.RouterId
+	prevBDR := nbr.BDRtrId == nbr.RouterId
+	nbr.IntfId = hello.IntfId
+	nbr.Addr = msg.srcIP
+	nbr.MacAddr = msg.srcMAC
+	nbr.Priority = hello.RtrPriority
+	nbr.Options = hello.Options
+	nbr.DRtrId = hello.DRtrId
+	nbr.BDRtrId = hello.BDRtrId
+	nbr.DeadTimer = ent.RtrDeadInterval
+	if nbr.State == config.NbrDown {
+		nbr.State = config.NbrInit
+		nbr.Events++
+	}
+	server.V3NbrMap[nbrKey] = nbr
+
+	twoWay := false
+	rtrId := server.getV3RouterId()
+	for _, nbrId := range hello.NbrList {
+		if nbrId == rtrId {
+			twoWay = true
+			break
+		}
+	}
+	if !twoWay {
+		if nbr.State >= config.NbrTwoWay {
+			server.ospfv3Nbr1WayReceived(nbrKey)
+		}
+		return nil
+	}
+	newTwoWay := false
+	if nbr.State == config.NbrInit {
+		server.ospfv3Nbr2WayReceived(nbrKey)
+		newTwoWay = true
+	}
+	if ent.IfType != config.Broadcast {
+		return nil
+	}
+	isDR := hello.DRtrId == nbr.RouterId
+	isBDR := hello.BDRtrId == nbr.RouterId
+	if ent.FSMState == config.Waiting {
+		/* BackupSeen */
+		if (isDR && hello.BDRtrId == 0) || isBDR {
+			server.ospfv3ElectDR(msg.key)
+		}
+	} else if newTwoWay || (exist && prevPriority != hello.RtrPriority) ||
+		prevDR != isDR || prevBDR != isBDR {
+		/* NeighborChange */
+		server.ospfv3ElectDR(msg.key)
+	}
+	return nil
+}
+
+/*
+@fn electOspfv3DR
+RFC 2328 9.4 steps 2 and 3. Candidates are the routers with a
+non zero priority which are in 2-Way or higher state.
+*/
+func electOspfv3DR(cands []V3DRCand) (dRtrId uint32, bdRtrId uint32) {
+	better := func(a V3DRCand, b V3DRCand) bool {
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.RouterId > b.RouterId
+	}
+	var bdr, bdrDeclared, dr V3DRCand
+	for _, cand := range cands {
+		if cand.DRtrId == cand.RouterId {
+			if dr.RouterId == 0 || better(cand, dr) {
+				dr = cand
+			}
+			continue
+		}
+		if cand.BDRtrId == cand.RouterId {
+			if bdrDeclared.RouterId == 0 || better(cand, bdrDeclared) {
+				bdrDeclared = cand
+			}
+		}
+		if bdr.RouterId == 0 || better(cand, bdr) {
+			bdr = cand
+		}
+	}
+	if bdrDeclared.RouterId != 0 {
+		bdr = bdrDeclared
+	}
+	if dr.RouterId == 0 {
+		dr = bdr
+	}
+	return dr.RouterId, bdr.RouterId
+}
+
+func (server *OSPFServer) getOspfv3DRCands(key V3IntfConfKey) []V3DRCand {
+	ent, _ := server.V3IntfConfMap[key]
+	cands := make([]V3DRCand, 0)
+	if ent.RtrPriority > 0 {
+		self := V3DRCand{
+			RouterId: server.getV3RouterId(),
+			Priority: ent.RtrPriority,
+			DRtrId:   ent.DRtrId,
+			BDRtrId:  ent.BDRtrId,
+		}
+		cands = append(cands, self)
+	}
+	for nbrKey, nbr := range server.V3NbrMap {
+		if nbrKey.IfIndex != key.IfIndex ||
+			nbr.State < config.NbrTwoWay ||
+			nbr.Priority == 0 {
+			continue
+		}
+		cand := V3DRCand{
+			RouterId: nbr.RouterId,
+			Priority: nbr.Priority,
+			DRtrId:   nbr.DRtrId,
+			BDRtrId:  nbr.BDRtrId,
+		}
+		cands = append(cands, cand)
+	}
+	return cands
+}
+
+/*
+@fn ospfv3ElectDR
+Runs the DR election of a broadcast interface and updates the
+adjacencies when the DR or BDR changes.
+*/
+func (server *OSPFServer) ospfv3ElectDR(key V3IntfConfKey) {
+	ent, _ := server.V3IntfConfMap[key]
+	if ent.IfType != config.Broadcast || ent.FSMState == config.Down {
+		return
+	}
+	rtrId := server.getV3RouterId()
+	prevDR := ent.DRtrId
+	prevBDR := ent.BDRtrId
+	dr, bdr := electOspfv3DR(server.getOspfv3DRCands(key))
+	/* RFC 2328 9.4 step 4 */
+	if (dr == rtrId) != (prevDR == rtrId) || (bdr == rtrId) != (prevBDR == rtrId) {
+		ent.DRtrId = dr
+		ent.BDRtrId = bdr
+		server.V3IntfConfMap[key] = ent
+		dr, bdr = electOspfv3DR(server.getOspfv3DRCands(key))
+	}
+	ent.DRtrId = dr
+	ent.BDRtrId = bdr
+	ent.DRIntfId = 0
+	if dr == rtrId {
+		ent.FSMState = config.DesignatedRouter
+		ent.DRIntfId = uint32(ent.IfIndex)
+	} else {
+		if dr != 0 {
+			nbr, _ := server.V3NbrMap[V3NbrKey{IfIndex: key.IfIndex, RouterId: dr}]
+			ent.DRIntfId = nbr.IntfId
+		}
+		if bdr == rtrId {
+			ent.FSMState = config.BackupDesignatedRouter
+		} else {
+			ent.FSMState = config.OtherDesignatedRouter
+		}
+	}
+	server.V3IntfConfMap[key] = ent
+	if dr == prevDR && bdr == prevBDR {
+		return
+	}
+	ent.Events++
+	server.V3IntfConfMap[key] = ent
+	server.logger.Info(fmt.Sprintln("OSPFv3: DR election ", server.dumpOspfv3Intf(key)))
+	for nbrKey, _ := range server.V3NbrMap {
+		if nbrKey.IfIndex == key.IfIndex {
+			server.ospfv3CheckAdjacency(nbrKey)
+		}
+	}
+	server.scheduleOspfv3Origination(ent.AreaId)
+}
+
+/* RFC 2328 10.4 Whether to become adjacent */
+func (server *OSPFServer) ospfv3AdjacencyRequired(nbrKey V3NbrKey) bool {
+	ent, _ := server.V3IntfConfMap[V3IntfConfKey{IfIndex: nbrKey.IfIndex}]
+	if ent.IfType != config.Broadcast {
+		return true
+	}
+	rtrId := server.getV3RouterId()
+	return ent.DRtrId == rtrId || ent.BDRtrId == rtrId ||
+		ent.DRtrId == nbrKey.RouterId || ent.BDRtrId == nbrKey.RouterId
+}
+
+func (server *OSPFServer) processOspfv3IntfTimers() {
+	for key, ent := range server.V3IntfConfMap {
+		if ent.FSMState == config.Down {
+			continue
+		}
+		if ent.HelloTimer <= 1 {
+			ent.HelloTimer = uint32(ent.HelloInterval)
+			server.V3IntfConfMap[key] = ent
+			server.sendOspfv3Hello(key)
+		} else {
+			ent.HelloTimer--
+			server.V3IntfConfMap[key] = ent
+		}
+		if ent.FSMState == config.Waiting {
+			if ent.WaitTimer <= 1 {
+				/* WaitTimer event */
+				ent.WaitTimer = 0
+				server.V3IntfConfMap[key] = ent
+				server.ospfv3ElectDR(key)
+			} else {
+				ent.WaitTimer--
+				server.V3IntfConfMap[key] = ent
+			}
+		}
+	}
+}
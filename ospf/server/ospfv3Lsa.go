@@ -0,0 +1,860 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"net"
+	"time"
+)
+
+/*
+RFC 5340 A.4.2 LSA header
+
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |           LS Age              |           LS Type             |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                       Link State ID                           |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                    Advertising Router                         |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                    LS Sequence Number                         |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |        LS Checksum            |             Length            |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+LS Type carries the U bit and the flooding scope (S2 S1) in the
+upper three bits.
+*/
+
+const (
+	V3RouterLsa          uint16 = 0x2001
+	V3NetworkLsa         uint16 = 0x2002
+	V3InterAreaPrefixLsa uint16 = 0x2003
+	V3InterAreaRouterLsa uint16 = 0x2004
+	V3ASExternalLsa      uint16 = 0x4005
+	V3NssaLsa            uint16 = 0x2007
+	V3LinkLsa            uint16 = 0x0008
+	V3IntraAreaPrefixLsa uint16 = 0x2009
+)
+
+const (
+	V3LsaUBit      uint16 = 0x8000
+	V3LsaScopeMask uint16 = 0x6000
+	V3LinkScope    uint16 = 0x0000
+	V3AreaScope    uint16 = 0x2000
+	V3ASScope      uint16 = 0x4000
+)
+
+/* Router LSA flags */
+const (
+	V3BitB uint8 = 0x01
+	V3BitE uint8 = 0x02
+	V3BitV uint8 = 0x04
+)
+
+/* Router LSA link types */
+const (
+	V3P2PLink     uint8 = 1
+	V3TransitLink uint8 = 2
+	V3VirtualLink uint8 = 4
+)
+
+/* RFC 5340 A.4.1.1 Prefix options */
+const (
+	V3PrefixNUBit uint8 = 0x01
+	V3PrefixLABit uint8 = 0x02
+	V3PrefixPBit  uint8 = 0x08
+	V3PrefixDNBit uint8 = 0x10
+)
+
+const (
+	V3MaxAgeDiff            uint16 = 900
+	V3InitialSequenceNumber uint32 = 0x80000001
+	V3RouterLsaLinkSize            = 16
+)
+
+type V3LsaKey struct {
+	LSType    uint16
+	LSId      uint32
+	AdvRouter uint32
+}
+
+type V3LsaHeader struct {
+	LSAge         uint16
+	LSType        uint16
+	LSId          uint32
+	AdvRouter     uint32
+	LSSequenceNum uint32
+	LSChecksum    uint16
+	LSLen         uint16
+}
+
+type V3Lsa struct {
+	Header      V3LsaHeader
+	Body        []byte
+	InstallTime time.Time
+}
+
+type V3Lsdb map[V3LsaKey]V3Lsa
+
+type V3Prefix struct {
+	PrefixLen     uint8
+	PrefixOptions uint8
+	Metric        uint16
+	Prefix        net.IP
+}
+
+type V3RouterLink struct {
+	Type      uint8
+	Metric    uint16
+	IntfId    uint32
+	NbrIntfId uint32
+	NbrRtrId  uint32
+}
+
+type V3RouterLsaBody struct {
+	Flags   uint8
+	Options uint32
+	Links   []V3RouterLink
+}
+
+type V3NetworkLsaBody struct {
+	Options     uint32
+	AttachedRtr []uint32
+}
+
+type V3InterAreaPrefixLsaBody struct {
+	Metric uint32
+	Prefix V3Prefix
+}
+
+type V3LinkLsaBody struct {
+	RtrPriority   uint8
+	Options       uint32
+	LinkLocalAddr net.IP
+	Prefixes      []V3Prefix
+}
+
+type V3IntraAreaPrefixLsaBody struct {
+	RefLSType    uint16
+	RefLSId      uint32
+	RefAdvRouter uint32
+	Prefixes     []V3Prefix
+}
+
+func getV3LsaKey(hdr V3LsaHeader) V3LsaKey {
+	return V3LsaKey{
+		LSType:    hdr.LSType,
+		LSId:      hdr.LSId,
+		AdvRouter: hdr.AdvRouter,
+	}
+}
+
+func isKnownV3LsaType(lsType uint16) bool {
+	switch lsType {
+	case V3RouterLsa, V3NetworkLsa, V3InterAreaPrefixLsa, V3InterAreaRouterLsa,
+		V3ASExternalLsa, V3NssaLsa, V3LinkLsa, V3IntraAreaPrefixLsa:
+		return true
+	}
+	return false
+}
+
+/*
+@fn getV3LsaScope
+RFC 5340 4.5.2 Unknown LSAs with the U bit clear are
+treated as having link-local scope.
+*/
+func getV3LsaScope(lsType uint16) uint16 {
+	if lsType&V3LsaUBit == 0 && !isKnownV3LsaType(lsType) {
+		return V3LinkScope
+	}
+	return lsType & V3LsaScopeMask
+}
+
+func encodeV3LsaHeader(hdr V3LsaHeader) []byte {
+	data := make([]byte, OSPF_LSA_HEADER_SIZE)
+	binary.BigEndian.PutUint16(data[0:2], hdr.LSAge)
+	binary.BigEndian.PutUint16(data[2:4], hdr.LSType)
+	binary.BigEndian.PutUint32(data[4:8], hdr.LSId)
+	binary.BigEndian.PutUint32(data[8:12], hdr.AdvRouter)
+	binary.BigEndian.PutUint32(data[12:16], hdr.LSSequenceNum)
+	binary.BigEndian.PutUint16(data[16:18], hdr.LSChecksum)
+	binary.BigEndian.PutUint16(data[18:20], hdr.LSLen)
+	return data
+}
+
+func decodeV3LsaHeader(data []byte) V3LsaHeader {
+	return V3LsaHeader{
+		LSAge:         binary.BigEndian.Uint16(data[0:2]),
+		LSType:        binary.BigEndian.Uint16(data[2:4]),
+		LSId:          binary.BigEndian.Uint32(data[4:8]),
+		AdvRouter:     binary.BigEndian.Uint32(data[8:12]),
+		LSSequenceNum: binary.BigEndian.Uint32(data[12:16]),
+		LSChecksum:    binary.BigEndian.Uint16(data[16:18]),
+		LSLen:         binary.BigEndian.Uint16(data[18:20]),
+	}
+}
+
+func encodeV3Lsa(lsa V3Lsa) []byte {
+	lsa.Header.LSLen = uint16(OSPF_LSA_HEADER_SIZE + len(lsa.Body))
+	return append(encodeV3LsaHeader(lsa.Header), lsa.Body...)
+}
+
+func decodeV3Lsa(data []byte) (lsa V3Lsa, err error) {
+	if len(data) < OSPF_LSA_HEADER_SIZE {
+		return lsa, errors.New("Invalid LSA length")
+	}
+	lsa.Header = decodeV3LsaHeader(data)
+	if int(lsa.Header.LSLen) != len(data) {
+		return lsa, errors.New("LSA length mismatch")
+	}
+	if !validateChecksum(data) {
+		return lsa, errors.New(fmt.Sprintln("Invalid LSA checksum", dumpV3LsaKey(getV3LsaKey(lsa.Header))))
+	}
+	lsa.Body = make([]byte, len(data)-OSPF_LSA_HEADER_SIZE)
+	copy(lsa.Body, data[OSPF_LSA_HEADER_SIZE:])
+	return lsa, nil
+}
+
+/* Sets the length and the Fletcher checksum of a new LSA instance */
+func finalizeV3Lsa(lsa V3Lsa) V3Lsa {
+	lsa.Header.LSLen = uint16(OSPF_LSA_HEADER_SIZE + len(lsa.Body))
+	lsaEnc := encodeV3Lsa(lsa)
+	lsa.Header.LSChecksum = computeFletcherChecksum(lsaEnc[2:], 14)
+	return lsa
+}
+
+/* Current age of the LSA in the database */
+func getV3LsaAge(lsa V3Lsa) uint16 {
+	age := uint32(lsa.Header.LSAge)
+	if !lsa.InstallTime.IsZero() {
+		age += uint32(time.Since(lsa.InstallTime) / time.Second)
+	}
+	if age > uint32(config.MaxAge) {
+		age = uint32(config.MaxAge)
+	}
+	return uint16(age)
+}
+
+func getV3LsaHeader(lsa V3Lsa) V3LsaHeader {
+	hdr := lsa.Header
+	hdr.LSAge = getV3LsaAge(lsa)
+	return hdr
+}
+
+/*
+@fn compareV3LsaInstance
+RFC 2328 13.1 Returns 1 when a is the newer instance, -1 when b
+is newer and 0 for the same instance.
+*/
+func compareV3LsaInstance(a V3LsaHeader, b V3LsaHeader) int {
+	if a.LSSequenceNum != b.LSSequenceNum {
+		if int32(a.LSSequenceNum) > int32(b.LSSequenceNum) {
+			return 1
+		}
+		return -1
+	}
+	if a.LSChecksum != b.LSChecksum {
+		if a.LSChecksum > b.LSChecksum {
+			return 1
+		}
+		return -1
+	}
+	if a.LSAge == config.MaxAge && b.LSAge != config.MaxAge {
+		return 1
+	}
+	if b.LSAge == config.MaxAge && a.LSAge != config.MaxAge {
+		return -1
+	}
+	if a.LSAge+V3MaxAgeDiff < b.LSAge {
+		return 1
+	}
+	if b.LSAge+V3MaxAgeDiff < a.LSAge {
+		return -1
+	}
+	return 0
+}
+
+func newV3Prefix(ipNet *net.IPNet) V3Prefix {
+	ones, _ := ipNet.Mask.Size()
+	return V3Prefix{
+		PrefixLen: uint8(ones),
+		Prefix:    ipNet.IP.Mask(ipNet.Mask).To16(),
+	}
+}
+
+func (p V3Prefix) String() string {
+	ipNet := net.IPNet{
+		IP:   p.Prefix,
+		Mask: net.CIDRMask(int(p.PrefixLen), 128),
+	}
+	return ipNet.String()
+}
+
+/* RFC 5340 A.4.1 IPv6 Prefix Representation */
+func encodeV3Prefix(prefix V3Prefix) []byte {
+	addrLen := ((int(prefix.PrefixLen) + 31) / 32) * 4
+	data := make([]byte, 4+addrLen)
+	data[0] = prefix.PrefixLen
+	data[1] = prefix.PrefixOptions
+	binary.BigEndian.PutUint16(data[2:4], prefix.Metric)
+	copy(data[4:], prefix.Prefix.To16()[:addrLen])
+	return data
+}
+
+func decodeV3Prefix(data []byte) (prefix V3Prefix, length int, err error) {
+	if len(data) < 4 {
+		return prefix, 0, errors.New("Invalid prefix length")
+	}
+	prefix.PrefixLen = data[0]
+	prefix.PrefixOptions = data[1]
+	prefix.Metric = binary.BigEndian.Uint16(data[2:4])
+	if prefix.PrefixLen > 128 {
+		return prefix, 0, errors.New("Invalid prefix length")
+	}
+	addrLen := ((int(prefix.PrefixLen) + 31) / 32) * 4
+	if len(data) < 4+addrLen {
+		return prefix, 0, errors.New("Truncated prefix")
+	}
+	prefix.Prefix = make(net.IP, net.IPv6len)
+	copy(prefix.Prefix, data[4:4+addrLen])
+	prefix.Prefix = prefix.Prefix.Mask(net.CIDRMask(int(prefix.PrefixLen), 128))
+	return prefix, 4 + addrLen, nil
+}
+
+func encodeV3RouterLsa(body V3RouterLsaBody) []byte {
+	data := make([]byte, 4, 4+V3RouterLsaLinkSize*len(body.Links))
+	data[0] = body.Flags
+	encodeOspfv3Options(data[1:4], body.Options)
+	for _, link := range body.Links {
+		linkEnc := make([]byte, V3RouterLsaLinkSize)
+		linkEnc[0] = link.Type
+		binary.BigEndian.PutUint16(linkEnc[2:4], link.Metric)
+		binary.BigEndian.PutUint32(linkEnc[4:8], link.IntfId)
+		binary.BigEndian.PutUint32(linkEnc[8:12], link.NbrIntfId)
+		binary.BigEndian.PutUint32(linkEnc[12:16], link.NbrRtrId)
+		data = append(data, linkEnc...)
+	}
+	return data
+}
+
+func decodeV3RouterLsa(data []byte) (body V3RouterLsaBody, err error) {
+	if len(data) < 4 || (len(data)-4)%V3RouterLsaLinkSize != 0 {
+		return body, errors.New("Invalid router LSA length")
+	}
+	body.Flags = data[0]
+	body.Options = decodeOspfv3Options(data[1:4])
+	for i := 4; i < len(data); i += V3RouterLsaLinkSize {
+		link := V3RouterLink{
+			Type:      data[i],
+			Metric:    binary.BigEndian.Uint16(data[i+2 : i+4]),
+			IntfId:    binary.BigEndian.Uint32(data[i+4 : i+8]),
+			NbrIntfId: binary.BigEndian.Uint32(data[i+8 : i+12]),
+			NbrRtrId:  binary.BigEndian.Uint32(data[i+12 : i+16]),
+		}
+		body.Links = append(body.Links, link)
+	}
+	return body, nil
+}
+
+func encodeV3NetworkLsa(body V3NetworkLsaBody) []byte {
+	data := make([]byte, 4+4*len(body.AttachedRtr))
+	encodeOspfv3Options(data[1:4], body.Options)
+	for i, rtrId := range body.AttachedRtr {
+		binary.BigEndian.PutUint32(data[4+4*i:], rtrId)
+	}
+	return data
+}
+
+func decodeV3NetworkLsa(data []byte) (body V3NetworkLsaBody, err error) {
+	if len(data) < 4 || len(data)%4 != 0 {
+		return body, errors.New("Invalid network LSA length")
+	}
+	body.Options = decodeOspfv3Options(data[1:4])
+	for i := 4; i < len(data); i += 4 {
+		body.AttachedRtr = append(body.AttachedRtr, binary.BigEndian.Uint32(data[i:i+4]))
+	}
+	return body, nil
+}
+
+func encodeV3InterAreaPrefixLsa(body V3InterAreaPrefixLsaBody) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, body.Metric&LSInfinity)
+	prefix := body.Prefix
+	prefix.Metric = 0
+	return append(data, encodeV3Prefix(prefix)...)
+}
+
+func decodeV3InterAreaPrefixLsa(data []byte) (body V3InterAreaPrefixLsaBody, err error) {
+	if len(data) < 8 {
+		return body, errors.New("Invalid inter-area-prefix LSA length")
+	}
+	body.Metric = binary.BigEndian.Uint32(data[0:4]) & LSInfinity
+	body.Prefix, _, err = decodeV3Prefix(data[4:])
+	return body, err
+}
+
+func encodeV3LinkLsa(body V3LinkLsaBody) []byte {
+	data := make([]byte, 24)
+	data[0] = body.RtrPriority
+	encodeOspfv3Options(data[1:4], body.Options)
+	copy(data[4:20], body.LinkLocalAddr.To16())
+	binary.BigEndian.PutUint32(data[20:24], uint32(len(body.Prefixes)))
+	for _, prefix := range body.Prefixes {
+		prefix.Metric = 0
+		data = append(data, encodeV3Prefix(prefix)...)
+	}
+	return data
+}
+
+func decodeV3LinkLsa(data []byte) (body V3LinkLsaBody, err error) {
+	if len(data) < 24 {
+		return body, errors.New("Invalid link LSA length")
+	}
+	body.RtrPriority = data[0]
+	body.Options = decodeOspfv3Options(data[1:4])
+	body.LinkLocalAddr = make(net.IP, net.IPv6len)
+	copy(body.LinkLocalAddr, data[4:20])
+	numOfPrefix := binary.BigEndian.Uint32(data[20:24])
+	index := 24
+	for i := uint32(0); i < numOfPrefix; i++ {
+		prefix, length, err := decodeV3Prefix(data[index:])
+		if err != nil {
+			return body, err
+		}
+		body.Prefixes = append(body.Prefixes, prefix)
+		index += length
+	}
+	return body, nil
+}
+
+func encodeV3IntraAreaPrefixLsa(body V3IntraAreaPrefixLsaBody) []byte {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint16(data[0:2], uint16(len(body.Prefixes)))
+	binary.BigEndian.PutUint16(data[2:4], body.RefLSType)
+	binary.BigEndian.PutUint32(data[4:8], body.RefLSId)
+	binary.BigEndian.PutUint32(data[8:12], body.RefAdvRouter)
+	for _, prefix := range body.Prefixes {
+		data = append(data, encodeV3Prefix(prefix)...)
+	}
+	return data
+}
+
+func decodeV3IntraAreaPrefixLsa(data []byte) (body V3IntraAreaPrefixLsaBody, err error) {
+	if len(data) < 12 {
+		return body, errors.New("Invalid intra-area-prefix LSA length")
+	}
+	numOfPrefix := binary.BigEndian.Uint16(data[0:2])
+	body.RefLSType = binary.BigEndian.Uint16(data[2:4])
+	body.RefLSId = binary.BigEndian.Uint32(data[4:8])
+	body.RefAdvRouter = binary.BigEndian.Uint32(data[8:12])
+	index := 12
+	for i := uint16(0); i < numOfPrefix; i++ {
+		prefix, length, err := decodeV3Prefix(data[index:])
+		if err != nil {
+			return body, err
+		}
+		body.Prefixes = append(body.Prefixes, prefix)
+		index += length
+	}
+	return body, nil
+}
+
+func dumpV3LsaKey(key V3LsaKey) string {
+	return fmt.Sprintf("LSType: 0x%04x LSId: %s AdvRouter: %s", key.LSType,
+		convertUint32ToIPv4(key.LSId), convertUint32ToIPv4(key.AdvRouter))
+}
+
+/*
+@fn getOspfv3Lsdb
+Returns the link, area or AS scope database of the LSA type.
+*/
+func (server *OSPFServer) getOspfv3Lsdb(lsType uint16, areaId uint32, key V3IntfConfKey) V3Lsdb {
+	switch getV3LsaScope(lsType) {
+	case V3LinkScope:
+		return server.V3IntfConfMap[key].LinkLsdb
+	case V3ASScope:
+		return server.V3AsLsdb
+	}
+	return server.V3AreaLsdb[areaId]
+}
+
+/*
+@fn installOspfv3Lsa
+RFC 2328 13.2 Installing the LSA in the database. The route
+calculation is scheduled when the contents of the LSA change.
+*/
+func (server *OSPFServer) installOspfv3Lsa(lsdb V3Lsdb, lsa V3Lsa, areaId uint32) {
+	if lsdb == nil {
+		return
+	}
+	lsaKey := getV3LsaKey(lsa.Header)
+	old, exist := lsdb[lsaKey]
+	lsa.InstallTime = time.Now()
+	lsdb[lsaKey] = lsa
+	if exist && bytes.Equal(old.Body, lsa.Body) &&
+		(old.Header.LSAge == config.MaxAge) == (lsa.Header.LSAge == config.MaxAge) {
+		return
+	}
+	switch lsa.Header.LSType {
+	case V3LinkLsa:
+		/* prefixes of the link are advertised by the DR */
+		server.scheduleOspfv3Origination(areaId)
+	case V3RouterLsa, V3NetworkLsa, V3InterAreaPrefixLsa,
+		V3InterAreaRouterLsa, V3IntraAreaPrefixLsa:
+		server.scheduleOspfv3SPF()
+	}
+}
+
+/*
+@fn originateOspfv3Lsa
+Originates a new instance of a self-originated LSA when its contents
+changed and floods it within its scope.
+*/
+func (server *OSPFServer) originateOspfv3Lsa(lsType uint16, lsId uint32, body []byte, areaId uint32, key V3IntfConfKey) {
+	lsdb := server.getOspfv3Lsdb(lsType, areaId, key)
+	if lsdb == nil {
+		return
+	}
+	lsaKey := V3LsaKey{
+		LSType:    lsType,
+		LSId:      lsId,
+		AdvRouter: server.getV3RouterId(),
+	}
+	old, exist := lsdb[lsaKey]
+	forced := server.v3ForceOrig[lsaKey]
+	delete(server.v3ForceOrig, lsaKey)
+	if exist && !forced && getV3LsaAge(old) < config.MaxAge && bytes.Equal(old.Body, body) {
+		return
+	}
+	lsa := V3Lsa{
+		Header: V3LsaHeader{
+			LSType:        lsType,
+			LSId:          lsId,
+			AdvRouter:     lsaKey.AdvRouter,
+			LSSequenceNum: V3InitialSequenceNumber,
+		},
+		Body: body,
+	}
+	if exist {
+		lsa.Header.LSSequenceNum = old.Header.LSSequenceNum + 1
+	}
+	lsa = finalizeV3Lsa(lsa)
+	server.logger.Info(fmt.Sprintln("OSPFv3: Originate LSA ", dumpV3LsaKey(lsaKey), "seq", lsa.Header.LSSequenceNum))
+	server.removeOspfv3LsaFromRetxLists(lsaKey)
+	server.installOspfv3Lsa(lsdb, lsa, areaId)
+	server.floodOspfv3Lsa(lsdb[lsaKey], areaId, key, V3NbrKey{})
+}
+
+/* RFC 2328 14.1 Premature aging of self-originated LSAs */
+func (server *OSPFServer) flushOspfv3Lsa(lsdb V3Lsdb, lsaKey V3LsaKey, areaId uint32, key V3IntfConfKey) {
+	lsa, exist := lsdb[lsaKey]
+	if !exist || lsa.Header.LSAge == config.MaxAge {
+		return
+	}
+	server.logger.Info(fmt.Sprintln("OSPFv3: Flush LSA ", dumpV3LsaKey(lsaKey)))
+	lsa.Header.LSAge = config.MaxAge
+	server.removeOspfv3LsaFromRetxLists(lsaKey)
+	server.installOspfv3Lsa(lsdb, lsa, areaId)
+	server.floodOspfv3Lsa(lsdb[lsaKey], areaId, key, V3NbrKey{})
+}
+
+/*
+@fn processOspfv3SelfOrigLsa
+RFC 2328 13.4 A newer instance of a self-originated LSA is installed
+and a new instance is originated with a higher sequence number. LSAs
+which are no longer originated are flushed by the origination.
+*/
+func (server *OSPFServer) processOspfv3SelfOrigLsa(lsdb V3Lsdb, lsa V3Lsa, areaId uint32) {
+	lsaKey := getV3LsaKey(lsa.Header)
+	server.logger.Info(fmt.Sprintln("OSPFv3: Received newer self-originated LSA ", dumpV3LsaKey(lsaKey)))
+	server.removeOspfv3LsaFromRetxLists(lsaKey)
+	server.installOspfv3Lsa(lsdb, lsa, areaId)
+	server.v3ForceOrig[lsaKey] = true
+	server.scheduleOspfv3Origination(areaId)
+	server.scheduleOspfv3SPF()
+}
+
+func (server *OSPFServer) getOspfv3IntfLinkType(key V3IntfConfKey) (link V3RouterLink, ok bool) {
+	ent, _ := server.V3IntfConfMap[key]
+	link.Metric = ent.Cost
+	link.IntfId = uint32(ent.IfIndex)
+	rtrId := server.getV3RouterId()
+	if ent.IfType == config.Broadcast {
+		if ent.DRtrId == 0 {
+			return link, false
+		}
+		fullNbr := false
+		for nbrKey, nbr := range server.V3NbrMap {
+			if nbrKey.IfIndex == key.IfIndex && nbr.State == config.NbrFull &&
+				(ent.DRtrId == rtrId || nbr.RouterId == ent.DRtrId) {
+				fullNbr = true
+				break
+			}
+		}
+		if !fullNbr {
+			return link, false
+		}
+		link.Type = V3TransitLink
+		link.NbrIntfId = ent.DRIntfId
+		link.NbrRtrId = ent.DRtrId
+		return link, true
+	}
+	return link, false
+}
+
+func (server *OSPFServer) getOspfv3IntfPrefixes(ent V3IntfConf, metric uint16) []V3Prefix {
+	prefixes := make([]V3Prefix, 0, len(ent.Prefixes))
+	for _, prefix := range ent.Prefixes {
+		prefix.Metric = metric
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+func (server *OSPFServer) originateOspfv3LinkLsa(key V3IntfConfKey) {
+	ent, exist := server.V3IntfConfMap[key]
+	if !exist || ent.FSMState == config.Down {
+		return
+	}
+	body := V3LinkLsaBody{
+		RtrPriority:   ent.RtrPriority,
+		Options:       OSPFV3_DEFAULT_OPTIONS,
+		LinkLocalAddr: ent.LinkLocalAddr,
+		Prefixes:      server.getOspfv3IntfPrefixes(ent, 0),
+	}
+	server.originateOspfv3Lsa(V3LinkLsa, uint32(ent.IfIndex), encodeV3LinkLsa(body), ent.AreaId, key)
+}
+
+/*
+@fn originateOspfv3AreaLsas
+Originates the Router, Network, Intra-Area-Prefix and Link LSAs of an
+area (RFC 5340 4.4.3) and flushes the ones which are not needed anymore.
+*/
+func (server *OSPFServer) originateOspfv3AreaLsas(areaId uint32) {
+	lsdb, exist := server.V3AreaLsdb[areaId]
+	if !exist {
+		return
+	}
+	rtrId := server.getV3RouterId()
+	selfOrig := make(map[V3LsaKey]bool)
+	rtrLsa := V3RouterLsaBody{
+		Options: OSPFV3_DEFAULT_OPTIONS,
+	}
+	if server.v3AreaBdrRtrStatus {
+		rtrLsa.Flags |= V3BitB
+	}
+	rtrPrefix := V3IntraAreaPrefixLsaBody{
+		RefLSType:    V3RouterLsa,
+		RefAdvRouter: rtrId,
+	}
+	numOfIntf := 0
+	for key, ent := range server.V3IntfConfMap {
+		if ent.AreaId != areaId || ent.FSMState == config.Down {
+			continue
+		}
+		numOfIntf++
+		server.originateOspfv3LinkLsa(key)
+		if ent.IfType != config.Broadcast {
+			for nbrKey, nbr := range server.V3NbrMap {
+				if nbrKey.IfIndex != key.IfIndex || nbr.State != config.NbrFull {
+					continue
+				}
+				link := V3RouterLink{
+					Type:      V3P2PLink,
+					Metric:    ent.Cost,
+					IntfId:    uint32(ent.IfIndex),
+					NbrIntfId: nbr.IntfId,
+					NbrRtrId:  nbr.RouterId,
+				}
+				rtrLsa.Links = append(rtrLsa.Links, link)
+			}
+			rtrPrefix.Prefixes = append(rtrPrefix.Prefixes, server.getOspfv3IntfPrefixes(ent, ent.Cost)...)
+			continue
+		}
+		link, transit := server.getOspfv3IntfLinkType(key)
+		if !transit {
+			rtrPrefix.Prefixes = append(rtrPrefix.Prefixes, server.getOspfv3IntfPrefixes(ent, ent.Cost)...)
+			continue
+		}
+		rtrLsa.Links = append(rtrLsa.Links, link)
+		if ent.FSMState != config.DesignatedRouter {
+			continue
+		}
+		/* RFC 5340 4.4.3.3 and 4.4.3.9 DR originates the network LSA of the link */
+		netLsa := V3NetworkLsaBody{
+			AttachedRtr: []uint32{rtrId},
+		}
+		netPrefix := V3IntraAreaPrefixLsaBody{
+			RefLSType:    V3NetworkLsa,
+			RefLSId:      uint32(ent.IfIndex),
+			RefAdvRouter: rtrId,
+			Prefixes:     server.getOspfv3IntfPrefixes(ent, 0),
+		}
+		netOptions := OSPFV3_DEFAULT_OPTIONS
+		seen := make(map[string]bool)
+		for _, prefix := range netPrefix.Prefixes {
+			seen[prefix.String()] = true
+		}
+		for nbrKey, nbr := range server.V3NbrMap {
+			if nbrKey.IfIndex != key.IfIndex || nbr.State != config.NbrFull {
+				continue
+			}
+			netLsa.AttachedRtr = append(netLsa.AttachedRtr, nbr.RouterId)
+			linkLsaKey := V3LsaKey{
+				LSType:    V3LinkLsa,
+				LSId:      nbr.IntfId,
+				AdvRouter: nbr.RouterId,
+			}
+			linkLsa, exist := ent.LinkLsdb[linkLsaKey]
+			if !exist || getV3LsaAge(linkLsa) >= config.MaxAge {
+				continue
+			}
+			linkBody, err := decodeV3LinkLsa(linkLsa.Body)
+			if err != nil {
+				continue
+			}
+			netOptions |= linkBody.Options
+			for _, prefix := range linkBody.Prefixes {
+				if prefix.PrefixOptions&(V3PrefixNUBit|V3PrefixLABit) != 0 || seen[prefix.String()] {
+					continue
+				}
+				seen[prefix.String()] = true
+				prefix.Metric = 0
+				netPrefix.Prefixes = append(netPrefix.Prefixes, prefix)
+			}
+		}
+		netLsa.Options = netOptions
+		netLsaKey := V3LsaKey{
+			LSType:    V3NetworkLsa,
+			LSId:      uint32(ent.IfIndex),
+			AdvRouter: rtrId,
+		}
+		selfOrig[netLsaKey] = true
+		server.originateOspfv3Lsa(V3NetworkLsa, netLsaKey.LSId, encodeV3NetworkLsa(netLsa), areaId, key)
+		if len(netPrefix.Prefixes) > 0 {
+			netPrefixKey := V3LsaKey{
+				LSType:    V3IntraAreaPrefixLsa,
+				LSId:      uint32(ent.IfIndex),
+				AdvRouter: rtrId,
+			}
+			selfOrig[netPrefixKey] = true
+			server.originateOspfv3Lsa(V3IntraAreaPrefixLsa, netPrefixKey.LSId, encodeV3IntraAreaPrefixLsa(netPrefix), areaId, key)
+		}
+	}
+	if numOfIntf > 0 {
+		rtrLsaKey := V3LsaKey{
+			LSType:    V3RouterLsa,
+			LSId:      0,
+			AdvRouter: rtrId,
+		}
+		selfOrig[rtrLsaKey] = true
+		server.originateOspfv3Lsa(V3RouterLsa, 0, encodeV3RouterLsa(rtrLsa), areaId, V3IntfConfKey{})
+		if len(rtrPrefix.Prefixes) > 0 {
+			rtrPrefixKey := V3LsaKey{
+				LSType:    V3IntraAreaPrefixLsa,
+				LSId:      0,
+				AdvRouter: rtrId,
+			}
+			selfOrig[rtrPrefixKey] = true
+			server.originateOspfv3Lsa(V3IntraAreaPrefixLsa, 0, encodeV3IntraAreaPrefixLsa(rtrPrefix), areaId, V3IntfConfKey{})
+		}
+	}
+	for lsaKey, _ := range lsdb {
+		if lsaKey.AdvRouter != rtrId || selfOrig[lsaKey] {
+			continue
+		}
+		switch lsaKey.LSType {
+		case V3RouterLsa, V3NetworkLsa, V3IntraAreaPrefixLsa:
+			server.flushOspfv3Lsa(lsdb, lsaKey, areaId, V3IntfConfKey{})
+		}
+	}
+}
+
+func (server *OSPFServer) isOspfv3LsaInRetxList(lsaKey V3LsaKey) bool {
+	for _, nbr := range server.V3NbrMap {
+		if nbr.LsRetxList[lsaKey] {
+			return true
+		}
+	}
+	return false
+}
+
+func (server *OSPFServer) isOspfv3NbrExchanging() bool {
+	for _, nbr := range server.V3NbrMap {
+		if nbr.State == config.NbrExchange || nbr.State == config.NbrLoading {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+@fn ageOspfv3Lsdb
+RFC 2328 14 Aging the database. Self-originated LSAs are refreshed
+every LSRefreshTime. LSAs reaching MaxAge are flooded and removed once
+they are acknowledged by all the neighbors.
+*/
+func (server *OSPFServer) ageOspfv3Lsdb(lsdb V3Lsdb, areaId uint32, key V3IntfConfKey) {
+	rtrId := server.getV3RouterId()
+	for lsaKey, lsa := range lsdb {
+		age := getV3LsaAge(lsa)
+		if lsa.Header.LSAge == config.MaxAge {
+			if !server.isOspfv3LsaInRetxList(lsaKey) && !server.isOspfv3NbrExchanging() {
+				delete(lsdb, lsaKey)
+			}
+			continue
+		}
+		if age >= config.MaxAge {
+			lsa.Header.LSAge = config.MaxAge
+			server.removeOspfv3LsaFromRetxLists(lsaKey)
+			server.installOspfv3Lsa(lsdb, lsa, areaId)
+			server.floodOspfv3Lsa(lsdb[lsaKey], areaId, key, V3NbrKey{})
+			continue
+		}
+		if lsaKey.AdvRouter == rtrId && uint32(age) >= config.LSRefreshTime {
+			server.v3ForceOrig[lsaKey] = true
+			server.originateOspfv3Lsa(lsaKey.LSType, lsaKey.LSId, lsa.Body, areaId, key)
+		}
+	}
+}
+
+func (server *OSPFServer) processOspfv3LsdbAging() {
+	for key, ent := range server.V3IntfConfMap {
+		if ent.FSMState != config.Down {
+			server.ageOspfv3Lsdb(ent.LinkLsdb, ent.AreaId, key)
+		}
+	}
+	for areaId, lsdb := range server.V3AreaLsdb {
+		server.ageOspfv3Lsdb(lsdb, areaId, V3IntfConfKey{})
+	}
+	server.ageOspfv3Lsdb(server.V3AsLsdb, 0, V3IntfConfKey{})
+}
@@ -0,0 +1,679 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"l3/ospf/config"
+	"net"
+	"time"
+)
+
+func (server *OSPFServer) setOspfv3NbrState(nbrKey V3NbrKey, nbr V3NbrEnt, state config.NbrState) V3NbrEnt {
+	prevState := nbr.State
+	nbr.State = state
+	nbr.Events++
+	server.V3NbrMap[nbrKey] = nbr
+	if prevState == state {
+		return nbr
+	}
+	server.logger.Info(fmt.Sprintln("OSPFv3: Neighbor ", convertUint32ToIPv4(nbrKey.RouterId), "on", nbrKey.IfIndex,
+		"state", config.NbrStateList[prevState], "->", config.NbrStateList[state]))
+	if prevState == config.NbrFull || state == config.NbrFull {
+		ent, _ := server.V3IntfConfMap[V3IntfConfKey{IfIndex: nbrKey.IfIndex}]
+		server.scheduleOspfv3Origination(ent.AreaId)
+		server.scheduleOspfv3SPF()
+	}
+	return nbr
+}
+
+func resetOspfv3NbrLists(nbr *V3NbrEnt) {
+	nbr.DbSummaryList = nil
+	nbr.LsReqList = nil
+	nbr.LsRetxList = make(map[V3LsaKey]bool)
+	nbr.LastRxDbd = Ospfv3Dbd{}
+	nbr.LastTxDbd = nil
+	nbr.DbdRxmtTimer = 0
+	nbr.LsReqRxmtTime = 0
+	nbr.LsRetxTimer = 0
+}
+
+/* RFC 2328 10.3 2-WayReceived event */
+func (server *OSPFServer) ospfv3Nbr2WayReceived(nbrKey V3NbrKey) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	server.setOspfv3NbrState(nbrKey, nbr, config.NbrTwoWay)
+	if server.ospfv3AdjacencyRequired(nbrKey) {
+		server.startOspfv3NbrExStart(nbrKey)
+	}
+}
+
+/* RFC 2328 10.3 1-WayReceived event */
+func (server *OSPFServer) ospfv3Nbr1WayReceived(nbrKey V3NbrKey) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	resetOspfv3NbrLists(&nbr)
+	server.setOspfv3NbrState(nbrKey, nbr, config.NbrInit)
+	key := V3IntfConfKey{
+		IfIndex: nbrKey.IfIndex,
+	}
+	if server.V3IntfConfMap[key].FSMState != config.Waiting {
+		server.ospfv3ElectDR(key)
+	}
+}
+
+func (server *OSPFServer) deleteOspfv3Nbr(nbrKey V3NbrKey) {
+	nbr, exist := server.V3NbrMap[nbrKey]
+	if !exist {
+		return
+	}
+	resetOspfv3NbrLists(&nbr)
+	server.setOspfv3NbrState(nbrKey, nbr, config.NbrDown)
+	delete(server.V3NbrMap, nbrKey)
+}
+
+/*
+@fn ospfv3CheckAdjacency
+RFC 2328 10.3 AdjOK? event. The adjacency is started or torn down
+after the DR or BDR of the link changed.
+*/
+func (server *OSPFServer) ospfv3CheckAdjacency(nbrKey V3NbrKey) {
+	nbr, exist := server.V3NbrMap[nbrKey]
+	if !exist {
+		return
+	}
+	required := server.ospfv3AdjacencyRequired(nbrKey)
+	if nbr.State == config.NbrTwoWay && required {
+		server.startOspfv3NbrExStart(nbrKey)
+	} else if nbr.State >= config.NbrExchangeStart && !required {
+		resetOspfv3NbrLists(&nbr)
+		server.setOspfv3NbrState(nbrKey, nbr, config.NbrTwoWay)
+	}
+}
+
+/*
+@fn startOspfv3NbrExStart
+RFC 2328 10.8 Starts the master/slave negotiation by sending empty
+database description packets with the I, M and MS bits set.
+*/
+func (server *OSPFServer) startOspfv3NbrExStart(nbrKey V3NbrKey) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	resetOspfv3NbrLists(&nbr)
+	nbr.IsMaster = false
+	if nbr.DDSeqNum == 0 {
+		nbr.DDSeqNum = uint32(time.Now().Unix())
+	} else {
+		nbr.DDSeqNum++
+	}
+	server.setOspfv3NbrState(nbrKey, nbr, config.NbrExchangeStart)
+	server.sendOspfv3Dbd(nbrKey, V3DbdIBit|V3DbdMBit|V3DbdMSBit)
+}
+
+/* RFC 2328 10.3 SeqNumberMismatch and BadLSReq events */
+func (server *OSPFServer) restartOspfv3NbrExStart(nbrKey V3NbrKey, reason string) {
+	server.logger.Info(fmt.Sprintln("OSPFv3: Restarting adjacency with ", convertUint32ToIPv4(nbrKey.RouterId), reason))
+	server.startOspfv3NbrExStart(nbrKey)
+}
+
+/*
+@fn sendOspfv3Dbd
+Sends the next database description packet to the neighbor. The LSA
+headers are taken from the database summary list as long as they fit
+in the interface MTU.
+*/
+func (server *OSPFServer) sendOspfv3Dbd(nbrKey V3NbrKey, flags uint8) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	ent, _ := server.V3IntfConfMap[V3IntfConfKey{IfIndex: nbrKey.IfIndex}]
+	dbd := Ospfv3Dbd{
+		Options:  OSPFV3_DEFAULT_OPTIONS,
+		IntfMtu:  uint16(ent.IfMtu),
+		Flags:    flags,
+		DDSeqNum: nbr.DDSeqNum,
+	}
+	if flags&V3DbdIBit == 0 {
+		maxHdrs := (int(ent.IfMtu) - IPV6_HEADER_SIZE - OSPFV3_HEADER_SIZE - OSPFV3_DBD_MIN_SIZE) / OSPF_LSA_HEADER_SIZE
+		numOfHdrs := min(maxHdrs, len(nbr.DbSummaryList))
+		dbd.LsaHeaders = nbr.DbSummaryList[:numOfHdrs]
+		nbr.DbSummaryList = nbr.DbSummaryList[numOfHdrs:]
+		if len(nbr.DbSummaryList) > 0 {
+			dbd.Flags |= V3DbdMBit
+		}
+	}
+	nbr.LastTxDbd = encodeOspfv3Dbd(dbd)
+	nbr.DbdRxmtTimer = 0
+	if flags&V3DbdMSBit != 0 {
+		/* only the master retransmits */
+		nbr.DbdRxmtTimer = uint32(ent.RetransInterval)
+	}
+	server.V3NbrMap[nbrKey] = nbr
+	err := server.sendOspfv3PktToNbr(nbrKey, DBDescriptionType, nbr.LastTxDbd)
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("OSPFv3: Unable to send DBD to ", convertUint32ToIPv4(nbrKey.RouterId), err))
+	}
+}
+
+/*
+@fn ospfv3NbrNegotiationDone
+RFC 2328 10.3 NegotiationDone event. The database summary list is built
+from the link, area and AS scope databases. MaxAge LSAs are put on the
+retransmission list instead.
+*/
+func (server *OSPFServer) ospfv3NbrNegotiationDone(nbrKey V3NbrKey) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	ent, _ := server.V3IntfConfMap[V3IntfConfKey{IfIndex: nbrKey.IfIndex}]
+	nbr.DbSummaryList = make([]V3LsaHeader, 0)
+	for _, lsdb := range []V3Lsdb{ent.LinkLsdb, server.V3AreaLsdb[ent.AreaId], server.V3AsLsdb} {
+		for lsaKey, lsa := range lsdb {
+			hdr := getV3LsaHeader(lsa)
+			if hdr.LSAge == config.MaxAge {
+				nbr.LsRetxList[lsaKey] = true
+				continue
+			}
+			nbr.DbSummaryList = append(nbr.DbSummaryList, hdr)
+		}
+	}
+	server.setOspfv3NbrState(nbrKey, nbr, config.NbrExchange)
+}
+
+/* RFC 2328 10.3 ExchangeDone and LoadingDone events */
+func (server *OSPFServer) ospfv3NbrExchangeDone(nbrKey V3NbrKey) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	nbr.DbdRxmtTimer = 0
+	if len(nbr.LsReqList) == 0 {
+		server.setOspfv3NbrState(nbrKey, nbr, config.NbrFull)
+		return
+	}
+	server.setOspfv3NbrState(nbrKey, nbr, config.NbrLoading)
+	server.sendOspfv3LsaReq(nbrKey)
+}
+
+func findV3LsaHeader(hdrs []V3LsaHeader, lsaKey V3LsaKey) int {
+	for idx, hdr := range hdrs {
+		if getV3LsaKey(hdr) == lsaKey {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (server *OSPFServer) getOspfv3NbrLsdb(nbrKey V3NbrKey, lsType uint16) V3Lsdb {
+	key := V3IntfConfKey{
+		IfIndex: nbrKey.IfIndex,
+	}
+	return server.getOspfv3Lsdb(lsType, server.V3IntfConfMap[key].AreaId, key)
+}
+
+func (server *OSPFServer) processOspfv3Dbd(nbrKey V3NbrKey, data []byte) error {
+	var dbd Ospfv3Dbd
+	err := decodeOspfv3Dbd(data, &dbd)
+	if err != nil {
+		return err
+	}
+	ent, _ := server.V3IntfConfMap[V3IntfConfKey{IfIndex: nbrKey.IfIndex}]
+	if int32(dbd.IntfMtu) > ent.IfMtu {
+		return errors.New(fmt.Sprintln("Neighbor MTU", dbd.IntfMtu, "is larger than interface MTU", ent.IfMtu))
+	}
+	nbr, _ := server.V3NbrMap[nbrKey]
+	dup := nbr.LastRxDbd.DDSeqNum == dbd.DDSeqNum &&
+		nbr.LastRxDbd.Flags == dbd.Flags &&
+		nbr.LastRxDbd.Options == dbd.Options
+	switch nbr.State {
+	case config.NbrInit:
+		server.ospfv3Nbr2WayReceived(nbrKey)
+		if server.V3NbrMap[nbrKey].State != config.NbrExchangeStart {
+			return nil
+		}
+		return server.processOspfv3DbdExStart(nbrKey, dbd)
+	case config.NbrExchangeStart:
+		return server.processOspfv3DbdExStart(nbrKey, dbd)
+	case config.NbrExchange:
+		if dup {
+			return server.processOspfv3DbdDuplicate(nbrKey)
+		}
+		return server.processOspfv3DbdExchange(nbrKey, dbd)
+	case config.NbrLoading, config.NbrFull:
+		if dup {
+			return server.processOspfv3DbdDuplicate(nbrKey)
+		}
+		server.restartOspfv3NbrExStart(nbrKey, "unexpected database description")
+		return nil
+	}
+	return errors.New(fmt.Sprintln("Database description in neighbor state", config.NbrStateList[nbr.State]))
+}
+
+/* RFC 2328 10.6 The slave answers duplicates with its last packet */
+func (server *OSPFServer) processOspfv3DbdDuplicate(nbrKey V3NbrKey) error {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	if !nbr.IsMaster || nbr.LastTxDbd == nil {
+		return nil
+	}
+	return server.sendOspfv3PktToNbr(nbrKey, DBDescriptionType, nbr.LastTxDbd)
+}
+
+func (server *OSPFServer) processOspfv3DbdExStart(nbrKey V3NbrKey, dbd Ospfv3Dbd) error {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	rtrId := server.getV3RouterId()
+	initFlags := V3DbdIBit | V3DbdMBit | V3DbdMSBit
+	if dbd.Flags&initFlags == initFlags && len(dbd.LsaHeaders) == 0 &&
+		nbrKey.RouterId > rtrId {
+		/* neighbor is the master */
+		nbr.IsMaster = true
+		nbr.DDSeqNum = dbd.DDSeqNum
+		server.V3NbrMap[nbrKey] = nbr
+		server.ospfv3NbrNegotiationDone(nbrKey)
+		nbr, _ = server.V3NbrMap[nbrKey]
+		nbr.LastRxDbd = dbd
+		server.V3NbrMap[nbrKey] = nbr
+		server.sendOspfv3Dbd(nbrKey, 0)
+		return nil
+	}
+	if dbd.Flags&(V3DbdIBit|V3DbdMSBit) == 0 && dbd.DDSeqNum == nbr.DDSeqNum &&
+		nbrKey.RouterId < rtrId {
+		nbr.IsMaster = false
+		server.V3NbrMap[nbrKey] = nbr
+		server.ospfv3NbrNegotiationDone(nbrKey)
+		return server.processOspfv3DbdExchange(nbrKey, dbd)
+	}
+	return nil
+}
+
+/*
+@fn processOspfv3DbdExchange
+RFC 2328 10.6 and 10.8 Database exchange. LSAs which are newer than
+the database copy are added to the link state request list.
+*/
+func (server *OSPFServer) processOspfv3DbdExchange(nbrKey V3NbrKey, dbd Ospfv3Dbd) error {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	if (dbd.Flags&V3DbdMSBit != 0) != nbr.IsMaster || dbd.Flags&V3DbdIBit != 0 ||
+		(nbr.LastRxDbd.Options != 0 && nbr.LastRxDbd.Options != dbd.Options) {
+		server.restartOspfv3NbrExStart(nbrKey, "database description flags mismatch")
+		return nil
+	}
+	expSeqNum := nbr.DDSeqNum
+	if nbr.IsMaster {
+		expSeqNum++
+	}
+	if dbd.DDSeqNum != expSeqNum {
+		server.restartOspfv3NbrExStart(nbrKey, "database description sequence number mismatch")
+		return nil
+	}
+	for _, hdr := range dbd.LsaHeaders {
+		lsaKey := getV3LsaKey(hdr)
+		lsa, exist := server.getOspfv3NbrLsdb(nbrKey, hdr.LSType)[lsaKey]
+		if exist && compareV3LsaInstance(hdr, getV3LsaHeader(lsa)) <= 0 {
+			continue
+		}
+		if findV3LsaHeader(nbr.LsReqList, lsaKey) < 0 {
+			nbr.LsReqList = append(nbr.LsReqList, hdr)
+		}
+	}
+	nbr.LastRxDbd = dbd
+	if nbr.IsMaster {
+		nbr.DDSeqNum = dbd.DDSeqNum
+		server.V3NbrMap[nbrKey] = nbr
+		server.sendOspfv3Dbd(nbrKey, 0)
+		if dbd.Flags&V3DbdMBit == 0 && len(server.V3NbrMap[nbrKey].DbSummaryList) == 0 {
+			server.ospfv3NbrExchangeDone(nbrKey)
+		}
+		return nil
+	}
+	/* the acknowledged packet was the last one of the master */
+	sentAll := len(nbr.LastTxDbd) >= OSPFV3_DBD_MIN_SIZE &&
+		nbr.LastTxDbd[7]&(V3DbdIBit|V3DbdMBit) == 0
+	nbr.DDSeqNum++
+	server.V3NbrMap[nbrKey] = nbr
+	if sentAll && dbd.Flags&V3DbdMBit == 0 {
+		server.ospfv3NbrExchangeDone(nbrKey)
+		return nil
+	}
+	server.sendOspfv3Dbd(nbrKey, V3DbdMSBit)
+	return nil
+}
+
+func (server *OSPFServer) sendOspfv3LsaReq(nbrKey V3NbrKey) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	ent, _ := server.V3IntfConfMap[V3IntfConfKey{IfIndex: nbrKey.IfIndex}]
+	if len(nbr.LsReqList) == 0 {
+		return
+	}
+	maxReq := (int(ent.IfMtu) - IPV6_HEADER_SIZE - OSPFV3_HEADER_SIZE) / OSPFV3_LSA_REQ_SIZE
+	reqList := make([]V3LsaKey, 0)
+	for idx := 0; idx < len(nbr.LsReqList) && idx < maxReq; idx++ {
+		reqList = append(reqList, getV3LsaKey(nbr.LsReqList[idx]))
+	}
+	nbr.LsReqRxmtTime = uint32(ent.RetransInterval)
+	server.V3NbrMap[nbrKey] = nbr
+	err := server.sendOspfv3PktToNbr(nbrKey, LSRequestType, encodeOspfv3LsaReq(reqList))
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("OSPFv3: Unable to send LSR to ", convertUint32ToIPv4(nbrKey.RouterId), err))
+	}
+}
+
+/* RFC 2328 10.7 Receiving link state request packets */
+func (server *OSPFServer) processOspfv3LsaReq(nbrKey V3NbrKey, data []byte) error {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	if nbr.State < config.NbrExchange {
+		return errors.New(fmt.Sprintln("Link state request in neighbor state", config.NbrStateList[nbr.State]))
+	}
+	reqList, err := decodeOspfv3LsaReq(data)
+	if err != nil {
+		return err
+	}
+	lsas := make([]V3Lsa, 0, len(reqList))
+	for _, lsaKey := range reqList {
+		lsa, exist := server.getOspfv3NbrLsdb(nbrKey, lsaKey.LSType)[lsaKey]
+		if !exist {
+			server.restartOspfv3NbrExStart(nbrKey, "bad link state request "+dumpV3LsaKey(lsaKey))
+			return nil
+		}
+		lsa.Header = getV3LsaHeader(lsa)
+		lsas = append(lsas, lsa)
+	}
+	server.sendOspfv3LsaUpdToNbr(nbrKey, lsas)
+	return nil
+}
+
+/* Splits the LSAs in link state update packets which fit in the MTU */
+func (server *OSPFServer) buildOspfv3LsaUpdPkts(key V3IntfConfKey, lsas []V3Lsa) [][]byte {
+	ent, _ := server.V3IntfConfMap[key]
+	maxLen := int(ent.IfMtu) - IPV6_HEADER_SIZE - OSPFV3_HEADER_SIZE - OSPFV3_NO_OF_LSA_SIZE
+	pkts := make([][]byte, 0)
+	start := 0
+	pktLen := 0
+	for idx, lsa := range lsas {
+		lsaLen := OSPF_LSA_HEADER_SIZE + len(lsa.Body)
+		if idx > start && pktLen+lsaLen > maxLen {
+			pkts = append(pkts, encodeOspfv3LsaUpd(lsas[start:idx], ent.TransitDelay))
+			start = idx
+			pktLen = 0
+		}
+		pktLen += lsaLen
+	}
+	if start < len(lsas) {
+		pkts = append(pkts, encodeOspfv3LsaUpd(lsas[start:], ent.TransitDelay))
+	}
+	return pkts
+}
+
+func (server *OSPFServer) sendOspfv3LsaUpdToNbr(nbrKey V3NbrKey, lsas []V3Lsa) {
+	for _, pkt := range server.buildOspfv3LsaUpdPkts(V3IntfConfKey{IfIndex: nbrKey.IfIndex}, lsas) {
+		err := server.sendOspfv3PktToNbr(nbrKey, LSUpdateType, pkt)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: Unable to send LSU to ", convertUint32ToIPv4(nbrKey.RouterId), err))
+		}
+	}
+}
+
+func (server *OSPFServer) removeOspfv3LsaFromRetxLists(lsaKey V3LsaKey) {
+	for _, nbr := range server.V3NbrMap {
+		delete(nbr.LsRetxList, lsaKey)
+	}
+}
+
+/*
+@fn floodOspfv3Lsa
+RFC 2328 13.3 and RFC 5340 4.5.2 Floods the LSA out of the interfaces
+of its flooding scope. Returns true when the LSA was flooded back out
+of the receiving interface.
+*/
+func (server *OSPFServer) floodOspfv3Lsa(lsa V3Lsa, areaId uint32, rxKey V3IntfConfKey, rxNbrKey V3NbrKey) bool {
+	lsaKey := getV3LsaKey(lsa.Header)
+	lsaHdr := getV3LsaHeader(lsa)
+	scope := getV3LsaScope(lsa.Header.LSType)
+	floodedBack := false
+	for key, ent := range server.V3IntfConfMap {
+		if ent.FSMState == config.Down ||
+			(scope == V3LinkScope && key != rxKey) ||
+			(scope == V3AreaScope && ent.AreaId != areaId) {
+			continue
+		}
+		added := false
+		for nbrKey, nbr := range server.V3NbrMap {
+			if nbrKey.IfIndex != key.IfIndex || nbr.State < config.NbrExchange {
+				continue
+			}
+			if nbr.State != config.NbrFull {
+				idx := findV3LsaHeader(nbr.LsReqList, lsaKey)
+				if idx >= 0 {
+					cmp := compareV3LsaInstance(lsaHdr, nbr.LsReqList[idx])
+					if cmp < 0 {
+						continue
+					}
+					nbr.LsReqList = append(nbr.LsReqList[:idx], nbr.LsReqList[idx+1:]...)
+					server.V3NbrMap[nbrKey] = nbr
+					if len(nbr.LsReqList) == 0 && nbr.State == config.NbrLoading {
+						server.setOspfv3NbrState(nbrKey, nbr, config.NbrFull)
+					}
+					if cmp == 0 {
+						continue
+					}
+				}
+			}
+			if nbrKey == rxNbrKey {
+				continue
+			}
+			nbr.LsRetxList[lsaKey] = true
+			added = true
+		}
+		if !added {
+			continue
+		}
+		if key == rxKey && rxNbrKey.RouterId != 0 {
+			if rxNbrKey.RouterId == ent.DRtrId || rxNbrKey.RouterId == ent.BDRtrId ||
+				ent.FSMState == config.BackupDesignatedRouter {
+				continue
+			}
+			floodedBack = true
+		}
+		dstIP := ALLSPFROUTERV6
+		if ent.IfType == config.Broadcast &&
+			ent.FSMState != config.DesignatedRouter &&
+			ent.FSMState != config.BackupDesignatedRouter {
+			dstIP = ALLDROUTERV6
+		}
+		flood := lsa
+		flood.Header = lsaHdr
+		err := server.sendOspfv3Pkt(key, net.ParseIP(dstIP), nil, LSUpdateType,
+			encodeOspfv3LsaUpd([]V3Lsa{flood}, ent.TransitDelay))
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: Unable to flood LSA on ", ent.IfName, err))
+		}
+	}
+	return floodedBack
+}
+
+/*
+@fn processOspfv3LsaUpd
+RFC 2328 13 The flooding procedure for received link state updates.
+*/
+func (server *OSPFServer) processOspfv3LsaUpd(nbrKey V3NbrKey, data []byte) error {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	if nbr.State < config.NbrExchange {
+		return errors.New(fmt.Sprintln("Link state update in neighbor state", config.NbrStateList[nbr.State]))
+	}
+	lsas, err := decodeOspfv3LsaUpd(data)
+	if err != nil {
+		return err
+	}
+	key := V3IntfConfKey{
+		IfIndex: nbrKey.IfIndex,
+	}
+	areaId := server.V3IntfConfMap[key].AreaId
+	rtrId := server.getV3RouterId()
+	acks := make([]V3LsaHeader, 0)
+	sendBack := make([]V3Lsa, 0)
+	for _, lsa := range lsas {
+		nbr, exist := server.V3NbrMap[nbrKey]
+		if !exist || nbr.State < config.NbrExchange {
+			break
+		}
+		lsaKey := getV3LsaKey(lsa.Header)
+		lsdb := server.getOspfv3Lsdb(lsa.Header.LSType, areaId, key)
+		old, exist := lsdb[lsaKey]
+		if lsa.Header.LSAge == config.MaxAge && !exist && !server.isOspfv3NbrExchanging() {
+			acks = append(acks, lsa.Header)
+			continue
+		}
+		cmp := 1
+		if exist {
+			cmp = compareV3LsaInstance(lsa.Header, getV3LsaHeader(old))
+		}
+		if cmp > 0 {
+			if lsaKey.AdvRouter == rtrId {
+				server.processOspfv3SelfOrigLsa(lsdb, lsa, areaId)
+				acks = append(acks, lsa.Header)
+				continue
+			}
+			server.removeOspfv3LsaFromRetxLists(lsaKey)
+			floodedBack := server.floodOspfv3Lsa(lsa, areaId, key, nbrKey)
+			server.installOspfv3Lsa(lsdb, lsa, areaId)
+			if !floodedBack {
+				acks = append(acks, lsa.Header)
+			}
+			continue
+		}
+		if findV3LsaHeader(nbr.LsReqList, lsaKey) >= 0 {
+			server.restartOspfv3NbrExStart(nbrKey, "bad link state request "+dumpV3LsaKey(lsaKey))
+			return nil
+		}
+		if cmp == 0 {
+			if nbr.LsRetxList[lsaKey] {
+				/* implied acknowledgement */
+				delete(nbr.LsRetxList, lsaKey)
+			} else {
+				acks = append(acks, lsa.Header)
+			}
+			continue
+		}
+		if getV3LsaAge(old) == config.MaxAge && old.Header.LSSequenceNum == uint32(MaxSequenceNumber) {
+			continue
+		}
+		old.Header = getV3LsaHeader(old)
+		sendBack = append(sendBack, old)
+	}
+	if len(acks) > 0 {
+		err = server.sendOspfv3PktToNbr(nbrKey, LSAckType, encodeOspfv3LsaAck(acks))
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: Unable to send LSAck to ", convertUint32ToIPv4(nbrKey.RouterId), err))
+		}
+	}
+	if len(sendBack) > 0 {
+		server.sendOspfv3LsaUpdToNbr(nbrKey, sendBack)
+	}
+	return nil
+}
+
+/* RFC 2328 13.7 Receiving link state acknowledgments */
+func (server *OSPFServer) processOspfv3LsaAck(nbrKey V3NbrKey, data []byte) error {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	if nbr.State < config.NbrExchange {
+		return errors.New(fmt.Sprintln("Link state ack in neighbor state", config.NbrStateList[nbr.State]))
+	}
+	lsaHdrs, err := decodeOspfv3LsaAck(data)
+	if err != nil {
+		return err
+	}
+	for _, hdr := range lsaHdrs {
+		lsaKey := getV3LsaKey(hdr)
+		if !nbr.LsRetxList[lsaKey] {
+			continue
+		}
+		lsa, exist := server.getOspfv3NbrLsdb(nbrKey, hdr.LSType)[lsaKey]
+		if !exist || compareV3LsaInstance(hdr, getV3LsaHeader(lsa)) == 0 {
+			delete(nbr.LsRetxList, lsaKey)
+		}
+	}
+	return nil
+}
+
+func (server *OSPFServer) retransmitOspfv3Lsas(nbrKey V3NbrKey) {
+	nbr, _ := server.V3NbrMap[nbrKey]
+	lsas := make([]V3Lsa, 0)
+	for lsaKey, _ := range nbr.LsRetxList {
+		lsa, exist := server.getOspfv3NbrLsdb(nbrKey, lsaKey.LSType)[lsaKey]
+		if !exist {
+			delete(nbr.LsRetxList, lsaKey)
+			continue
+		}
+		if len(lsas) < V3_RXMT_MAX_LSA {
+			lsa.Header = getV3LsaHeader(lsa)
+			lsas = append(lsas, lsa)
+		}
+	}
+	server.sendOspfv3LsaUpdToNbr(nbrKey, lsas)
+}
+
+/*
+@fn processOspfv3NbrTimers
+Runs every second. Handles the inactivity timer and the database
+description, link state request and link state update retransmissions.
+*/
+func (server *OSPFServer) processOspfv3NbrTimers() {
+	for nbrKey, nbr := range server.V3NbrMap {
+		key := V3IntfConfKey{
+			IfIndex: nbrKey.IfIndex,
+		}
+		ent, _ := server.V3IntfConfMap[key]
+		if nbr.DeadTimer <= 1 {
+			server.logger.Info(fmt.Sprintln("OSPFv3: Inactivity timer expired for ", convertUint32ToIPv4(nbrKey.RouterId)))
+			server.deleteOspfv3Nbr(nbrKey)
+			server.ospfv3ElectDR(key)
+			continue
+		}
+		nbr.DeadTimer--
+		dbdRxmt := false
+		if nbr.DbdRxmtTimer > 0 {
+			nbr.DbdRxmtTimer--
+			if nbr.DbdRxmtTimer == 0 && !nbr.IsMaster &&
+				(nbr.State == config.NbrExchangeStart || nbr.State == config.NbrExchange) {
+				nbr.DbdRxmtTimer = uint32(ent.RetransInterval)
+				dbdRxmt = true
+			}
+		}
+		lsReqRxmt := false
+		if nbr.LsReqRxmtTime > 0 {
+			nbr.LsReqRxmtTime--
+			lsReqRxmt = nbr.LsReqRxmtTime == 0 &&
+				(nbr.State == config.NbrExchange || nbr.State == config.NbrLoading)
+		}
+		lsRetx := false
+		if len(nbr.LsRetxList) > 0 {
+			if nbr.LsRetxTimer <= 1 {
+				nbr.LsRetxTimer = uint32(ent.RetransInterval)
+				lsRetx = true
+			} else {
+				nbr.LsRetxTimer--
+			}
+		}
+		server.V3NbrMap[nbrKey] = nbr
+		if dbdRxmt {
+			server.sendOspfv3PktToNbr(nbrKey, DBDescriptionType, nbr.LastTxDbd)
+		}
+		if lsReqRxmt {
+			server.sendOspfv3LsaReq(nbrKey)
+		}
+		if lsRetx {
+			server.retransmitOspfv3Lsas(nbrKey)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"l3/ospf/config"
+	"net"
+	"sync"
+)
+
+/*
+RFC 5340 A.3.1 The OSPF Packet Header
+
+	 0                   1                   2                   3
+	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|   Version #   |     Type      |         Packet length         |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|                         Router ID                             |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|                          Area ID                              |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|          Checksum             |  Instance ID  |      0        |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+Authentication is not part of the OSPFv3 header. The checksum is the
+standard IPv6 upper-layer checksum (RFC 2460 8.1).
+*/
+type Ospfv3Header struct {
+	Version    uint8
+	PktType    OspfType
+	PktLen     uint16
+	RouterId   uint32
+	AreaId     uint32
+	Checksum   uint16
+	InstanceId uint8
+}
+
+/* RFC 5340 A.3.2 */
+type Ospfv3Hello struct {
+	IntfId          uint32
+	RtrPriority     uint8
+	Options         uint32
+	HelloInterval   uint16
+	RtrDeadInterval uint16
+	DRtrId          uint32
+	BDRtrId         uint32
+	NbrList         []uint32
+}
+
+const (
+	V3DbdMSBit uint8 = 0x01
+	V3DbdMBit  uint8 = 0x02
+	V3DbdIBit  uint8 = 0x04
+)
+
+/* RFC 5340 A.3.3 */
+type Ospfv3Dbd struct {
+	Options    uint32
+	IntfMtu    uint16
+	Flags      uint8
+	DDSeqNum   uint32
+	LsaHeaders []V3LsaHeader
+}
+
+func encodeOspfv3Header(hdr Ospfv3Header) []byte {
+	pkt := make([]byte, OSPFV3_HEADER_SIZE)
+	pkt[0] = hdr.Version
+	pkt[1] = uint8(hdr.PktType)
+	binary.BigEndian.PutUint16(pkt[2:4], hdr.PktLen)
+	binary.BigEndian.PutUint32(pkt[4:8], hdr.RouterId)
+	binary.BigEndian.PutUint32(pkt[8:12], hdr.AreaId)
+	binary.BigEndian.PutUint16(pkt[12:14], hdr.Checksum)
+	pkt[14] = hdr.InstanceId
+	return pkt
+}
+
+func decodeOspfv3Header(data []byte, hdr *Ospfv3Header) error {
+	if len(data) < OSPFV3_HEADER_SIZE {
+		return errors.New("Invalid OSPFv3 header length")
+	}
+	hdr.Version = data[0]
+	hdr.PktType = OspfType(data[1])
+	hdr.PktLen = binary.BigEndian.Uint16(data[2:4])
+	hdr.RouterId = binary.BigEndian.Uint32(data[4:8])
+	hdr.AreaId = binary.BigEndian.Uint32(data[8:12])
+	hdr.Checksum = binary.BigEndian.Uint16(data[12:14])
+	hdr.InstanceId = data[14]
+	if hdr.Version != OSPF_VERSION_3 {
+		return errors.New(fmt.Sprintln("Unsupported OSPF version", hdr.Version))
+	}
+	if int(hdr.PktLen) < OSPFV3_HEADER_SIZE || int(hdr.PktLen) > len(data) {
+		return errors.New(fmt.Sprintln("Invalid OSPFv3 packet length", hdr.PktLen))
+	}
+	return nil
+}
+
+/*
+@fn computeOspfv3Checksum
+One's complement checksum over the IPv6 pseudo header and the OSPFv3
+packet. Returns 0 for a packet with a valid checksum.
+*/
+func computeOspfv3Checksum(srcIP net.IP, dstIP net.IP, pkt []byte) uint16 {
+	data := make([]byte, IPV6_HEADER_SIZE+len(pkt)+len(pkt)%2)
+	copy(data[0:16], srcIP.To16())
+	copy(data[16:32], dstIP.To16())
+	binary.BigEndian.PutUint32(data[32:36], uint32(len(pkt)))
+	data[39] = OSPF_PROTO_ID
+	copy(data[IPV6_HEADER_SIZE:], pkt)
+	return computeCheckSum(data)
+}
+
+func encodeOspfv3Options(data []byte, options uint32) {
+	data[0] = uint8(options >> 16)
+	data[1] = uint8(options >> 8)
+	data[2] = uint8(options)
+}
+
+func decodeOspfv3Options(data []byte) uint32 {
+	return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+}
+
+func encodeOspfv3Hello(hello Ospfv3Hello) []byte {
+	pkt := make([]byte, OSPFV3_HELLO_MIN_SIZE+4*len(hello.NbrList))
+	binary.BigEndian.PutUint32(pkt[0:4], hello.IntfId)
+	pkt[4] = hello.RtrPriority
+	encodeOspfv3Options(pkt[5:8], hello.Options)
+	binary.BigEndian.PutUint16(pkt[8:10], hello.HelloInterval)
+	binary.BigEndian.PutUint16(pkt[10:12], hello.RtrDeadInterval)
+	binary.BigEndian.PutUint32(pkt[12:16], hello.DRtrId)
+	binary.BigEndian.PutUint32(pkt[16:20], hello.BDRtrId)
+	for i, nbr := range hello.NbrList {
+		binary.BigEndian.PutUint32(pkt[OSPFV3_HELLO_MIN_SIZE+4*i:], nbr)
+	}
+	return pkt
+}
+
+func decodeOspfv3Hello(data []byte, hello *Ospfv3Hello) error {
+	if len(data) < OSPFV3_HELLO_MIN_SIZE || (len(data)-OSPFV3_HELLO_MIN_SIZE)%4 != 0 {
+		return errors.New("Invalid OSPFv3 hello length")
+	}
+	hello.IntfId = binary.BigEndian.Uint32(data[0:4])
+	hello.RtrPriority = data[4]
+	hello.Options = decodeOspfv3Options(data[5:8])
+	hello.HelloInterval = binary.BigEndian.Uint16(data[8:10])
+	hello.RtrDeadInterval = binary.BigEndian.Uint16(data[10:12])
+	hello.DRtrId = binary.BigEndian.Uint32(data[12:16])
+	hello.BDRtrId = binary.BigEndian.Uint32(data[16:20])
+	hello.NbrList = nil
+	for i := OSPFV3_HELLO_MIN_SIZE; i < len(data); i += 4 {
+		hello.NbrList = append(hello.NbrList, binary.BigEndian.Uint32(data[i:i+4]))
+	}
+	return nil
+}
+
+func encodeOspfv3Dbd(dbd Ospfv3Dbd) []byte {
+	pkt := make([]byte, OSPFV3_DBD_MIN_SIZE, OSPFV3_DBD_MIN_SIZE+OSPF_LSA_HEADER_SIZE*len(dbd.LsaHeaders))
+	encodeOspfv3Options(pkt[1:4], dbd.Options)
+	binary.BigEndian.PutUint16(pkt[4:6], dbd.IntfMtu)
+	pkt[7] = dbd.Flags
+	binary.BigEndian.PutUint32(pkt[8:12], dbd.DDSeqNum)
+	for _, lsaHdr := range dbd.LsaHeaders {
+		pkt = append(pkt, encodeV3LsaHeader(lsaHdr)...)
+	}
+	return pkt
+}
+
+func decodeOspfv3Dbd(data []byte, dbd *Ospfv3Dbd) error {
+	if len(data) < OSPFV3_DBD_MIN_SIZE || (len(data)-OSPFV3_DBD_MIN_SIZE)%OSPF_LSA_HEADER_SIZE != 0 {
+		return errors.New("Invalid OSPFv3 database description length")
+	}
+	dbd.Options = decodeOspfv3Options(data[1:4])
+	dbd.IntfMtu = binary.BigEndian.Uint16(data[4:6])
+	dbd.Flags = data[7]
+	dbd.DDSeqNum = binary.BigEndian.Uint32(data[8:12])
+	dbd.LsaHeaders = nil
+	for i := OSPFV3_DBD_MIN_SIZE; i < len(data); i += OSPF_LSA_HEADER_SIZE {
+		dbd.LsaHeaders = append(dbd.LsaHeaders, decodeV3LsaHeader(data[i:i+OSPF_LSA_HEADER_SIZE]))
+	}
+	return nil
+}
+
+/* RFC 5340 A.3.4 */
+func encodeOspfv3LsaReq(reqList []V3LsaKey) []byte {
+	pkt := make([]byte, OSPFV3_LSA_REQ_SIZE*len(reqList))
+	for i, lsaKey := range reqList {
+		binary.BigEndian.PutUint16(pkt[i*OSPFV3_LSA_REQ_SIZE+2:], lsaKey.LSType)
+		binary.BigEndian.PutUint32(pkt[i*OSPFV3_LSA_REQ_SIZE+4:], lsaKey.LSId)
+		binary.BigEndian.PutUint32(pkt[i*OSPFV3_LSA_REQ_SIZE+8:], lsaKey.AdvRouter)
+	}
+	return pkt
+}
+
+func decodeOspfv3LsaReq(data []byte) ([]V3LsaKey, error) {
+	if len(data)%OSPFV3_LSA_REQ_SIZE != 0 {
+		return nil, errors.New("Invalid OSPFv3 link state request length")
+	}
+	reqList := make([]V3LsaKey, 0, len(data)/OSPFV3_LSA_REQ_SIZE)
+	for i := 0; i < len(data); i += OSPFV3_LSA_REQ_SIZE {
+		lsaKey := V3LsaKey{
+			LSType:    binary.BigEndian.Uint16(data[i+2 : i+4]),
+			LSId:      binary.BigEndian.Uint32(data[i+4 : i+8]),
+			AdvRouter: binary.BigEndian.Uint32(data[i+8 : i+12]),
+		}
+		reqList = append(reqList, lsaKey)
+	}
+	return reqList, nil
+}
+
+/* RFC 5340 A.3.5 */
+func encodeOspfv3LsaUpd(lsas []V3Lsa, transitDelay uint16) []byte {
+	pkt := make([]byte, OSPFV3_NO_OF_LSA_SIZE)
+	binary.BigEndian.PutUint32(pkt, uint32(len(lsas)))
+	for _, lsa := range lsas {
+		lsaEnc := encodeV3Lsa(lsa)
+		age := binary.BigEndian.Uint16(lsaEnc[0:2]) + transitDelay
+		if age > config.MaxAge {
+			age = config.MaxAge
+		}
+		binary.BigEndian.PutUint16(lsaEnc[0:2], age)
+		pkt = append(pkt, lsaEnc...)
+	}
+	return pkt
+}
+
+func decodeOspfv3LsaUpd(data []byte) ([]V3Lsa, error) {
+	if len(data) < OSPFV3_NO_OF_LSA_SIZE {
+		return nil, errors.New("Invalid OSPFv3 link state update length")
+	}
+	numOfLsa := binary.BigEndian.Uint32(data[0:4])
+	lsas := make([]V3Lsa, 0)
+	index := OSPFV3_NO_OF_LSA_SIZE
+	for i := uint32(0); i < numOfLsa; i++ {
+		if index+OSPF_LSA_HEADER_SIZE > len(data) {
+			return lsas, errors.New("Truncated OSPFv3 link state update")
+		}
+		lsaLen := int(binary.BigEndian.Uint16(data[index+18 : index+20]))
+		if lsaLen < OSPF_LSA_HEADER_SIZE || index+lsaLen > len(data) {
+			return lsas, errors.New("Invalid LSA length in OSPFv3 link state update")
+		}
+		lsa, err := decodeV3Lsa(data[index : index+lsaLen])
+		if err != nil {
+			return lsas, err
+		}
+		lsas = append(lsas, lsa)
+		index += lsaLen
+	}
+	return lsas, nil
+}
+
+/* RFC 5340 A.3.6 */
+func encodeOspfv3LsaAck(lsaHdrs []V3LsaHeader) []byte {
+	pkt := make([]byte, 0, OSPF_LSA_HEADER_SIZE*len(lsaHdrs))
+	for _, lsaHdr := range lsaHdrs {
+		pkt = append(pkt, encodeV3LsaHeader(lsaHdr)...)
+	}
+	return pkt
+}
+
+func decodeOspfv3LsaAck(data []byte) ([]V3LsaHeader, error) {
+	if len(data)%OSPF_LSA_HEADER_SIZE != 0 {
+		return nil, errors.New("Invalid OSPFv3 link state ack length")
+	}
+	lsaHdrs := make([]V3LsaHeader, 0, len(data)/OSPF_LSA_HEADER_SIZE)
+	for i := 0; i < len(data); i += OSPF_LSA_HEADER_SIZE {
+		lsaHdrs = append(lsaHdrs, decodeV3LsaHeader(data[i:i+OSPF_LSA_HEADER_SIZE]))
+	}
+	return lsaHdrs, nil
+}
+
+/*
+@fn getOspfv3MulticastMac
+RFC 2464 7 Multicast MAC is 33:33 followed by the last 4 bytes of the
+IPv6 group address.
+*/
+func getOspfv3MulticastMac(dstIP net.IP) net.HardwareAddr {
+	ip := dstIP.To16()
+	return net.HardwareAddr{0x33, 0x33, ip[12], ip[13], ip[14], ip[15]}
+}
+
+/*
+@fn sendOspfv3Pkt
+Builds the OSPFv3 header and the IPv6 frame for the given packet body.
+Multicast destinations are mapped to their MAC, unicast packets are
+sent to the neighbor's MAC address.
+*/
+func (server *OSPFServer) sendOspfv3Pkt(key V3IntfConfKey, dstIP net.IP, dstMAC net.HardwareAddr, pktType OspfType, body []byte) error {
+	ent, exist := server.V3IntfConfMap[key]
+	if !exist || ent.LinkLocalAddr == nil {
+		return errors.New("OSPFv3 interface is not operational")
+	}
+	hdr := Ospfv3Header{
+		Version:    OSPF_VERSION_3,
+		PktType:    pktType,
+		PktLen:     uint16(OSPFV3_HEADER_SIZE + len(body)),
+		RouterId:   server.getV3RouterId(),
+		AreaId:     ent.AreaId,
+		InstanceId: ent.InstanceId,
+	}
+	ospf := append(encodeOspfv3Header(hdr), body...)
+	csum := computeOspfv3Checksum(ent.LinkLocalAddr, dstIP, ospf)
+	binary.BigEndian.PutUint16(ospf[12:14], csum)
+
+	if dstIP.IsMulticast() {
+		dstMAC = getOspfv3MulticastMac(dstIP)
+	}
+	ethLayer := layers.Ethernet{
+		SrcMAC:       ent.IfMacAddr,
+		DstMAC:       dstMAC,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ipLayer := layers.IPv6{
+		Version:      6,
+		TrafficClass: 0xc0,
+		NextHeader:   layers.IPProtocol(OSPF_PROTO_ID),
+		HopLimit:     1,
+		SrcIP:        ent.LinkLocalAddr,
+		DstIP:        dstIP,
+	}
+	buffer := gopacket.NewSerializeBuffer()
+	options := gopacket.SerializeOptions{
+		FixLengths: true,
+	}
+	err := gopacket.SerializeLayers(buffer, options, &ethLayer, &ipLayer, gopacket.Payload(ospf))
+	if err != nil {
+		return err
+	}
+
+	entry, _ := server.V3IntfTxMap[key]
+	handle := entry.SendPcapHdl
+	if handle == nil {
+		return errors.New("Invalid pcap handle")
+	}
+	entry.SendMutex.Lock()
+	err = handle.WritePacketData(buffer.Bytes())
+	entry.SendMutex.Unlock()
+	return err
+}
+
+func (server *OSPFServer) sendOspfv3PktToNbr(nbrKey V3NbrKey, pktType OspfType, body []byte) error {
+	nbr, exist := server.V3NbrMap[nbrKey]
+	if !exist {
+		return errors.New("OSPFv3 neighbor does not exist")
+	}
+	key := V3IntfConfKey{
+		IfIndex: nbrKey.IfIndex,
+	}
+	ent, _ := server.V3IntfConfMap[key]
+	if ent.IfType != config.Broadcast {
+		return server.sendOspfv3Pkt(key, net.ParseIP(ALLSPFROUTERV6), nil, pktType, body)
+	}
+	return server.sendOspfv3Pkt(key, nbr.Addr, nbr.MacAddr, pktType, body)
+}
+
+func (server *OSPFServer) openOspfv3PcapHdls(key V3IntfConfKey) error {
+	ent, _ := server.V3IntfConfMap[key]
+	txEntry, exist := server.V3IntfTxMap[key]
+	if !exist {
+		sendHdl, err := pcap.OpenLive(ent.IfName, snapshot_len, promiscuous, timeout_pcap)
+		if sendHdl == nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: SendHdl: No device found.", ent.IfName, err))
+			return errors.New("Unable to open the send handle")
+		}
+		txEntry.SendPcapHdl = sendHdl
+		txEntry.SendMutex = &sync.Mutex{}
+		server.V3IntfTxMap[key] = txEntry
+	}
+	rxEntry, exist := server.V3IntfRxMap[key]
+	if !exist {
+		recvHdl, err := pcap.OpenLive(ent.IfName, snapshot_len, promiscuous, timeout_pcap)
+		if recvHdl == nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: RecvHdl: No device found.", ent.IfName, err))
+			return errors.New("Unable to open the receive handle")
+		}
+		filter := fmt.Sprintln("ip6 proto", OSPF_PROTO_ID, "and not src host", ent.LinkLocalAddr.String())
+		server.logger.Info(fmt.Sprintln("OSPFv3: Filter is : ", filter))
+		err = recvHdl.SetBPFFilter(filter)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: Unable to set filter on", ent.IfName))
+			recvHdl.Close()
+			return err
+		}
+		rxEntry.RecvPcapHdl = recvHdl
+		rxEntry.PktRecvCh = make(chan bool)
+		server.V3IntfRxMap[key] = rxEntry
+	}
+	return nil
+}
+
+func (server *OSPFServer) closeOspfv3PcapHdls(key V3IntfConfKey) {
+	if rxEntry, exist := server.V3IntfRxMap[key]; exist {
+		/* receive thread closes the handle */
+		close(rxEntry.PktRecvCh)
+		delete(server.V3IntfRxMap, key)
+	}
+	if txEntry, exist := server.V3IntfTxMap[key]; exist {
+		txEntry.SendMutex.Lock()
+		txEntry.SendPcapHdl.Close()
+		txEntry.SendMutex.Unlock()
+		delete(server.V3IntfTxMap, key)
+	}
+}
+
+/*
+@fn startOspfv3RecvPkts
+Receive thread of an OSPFv3 interface. Packets are validated here and
+handed over to the event loop. Closing PktRecvCh stops the thread.
+*/
+func (server *OSPFServer) startOspfv3RecvPkts(key V3IntfConfKey, rxEntry IntfRxHandle) {
+	handle := rxEntry.RecvPcapHdl
+	recv := gopacket.NewPacketSource(handle, layers.LayerTypeEthernet)
+	in := recv.Packets()
+	defer handle.Close()
+	for {
+		select {
+		case packet, ok := <-in:
+			if !ok {
+				return
+			}
+			msg, err := processOspfv3RecvPkt(key, packet)
+			if err != nil {
+				server.logger.Debug(fmt.Sprintln("OSPFv3: Dropped packet", err))
+				continue
+			}
+			select {
+			case server.v3RxPktCh <- msg:
+			case <-rxEntry.PktRecvCh:
+				server.logger.Info(fmt.Sprintln("OSPFv3: Stopping the receive thread of", key.IfIndex))
+				return
+			}
+		case <-rxEntry.PktRecvCh:
+			server.logger.Info(fmt.Sprintln("OSPFv3: Stopping the receive thread of", key.IfIndex))
+			return
+		}
+	}
+}
+
+func processOspfv3RecvPkt(key V3IntfConfKey, pkt gopacket.Packet) (msg V3RxPktMsg, err error) {
+	ethLayer := pkt.Layer(layers.LayerTypeEthernet)
+	if ethLayer == nil {
+		return msg, errors.New("Not an Ethernet frame")
+	}
+	ipLayer := pkt.Layer(layers.LayerTypeIPv6)
+	if ipLayer == nil {
+		return msg, errors.New("Not an IPv6 packet")
+	}
+	ipPkt := ipLayer.(*layers.IPv6)
+	if ipPkt.NextHeader != layers.IPProtocol(OSPF_PROTO_ID) {
+		return msg, errors.New(fmt.Sprintln("Incorrect next header", ipPkt.NextHeader))
+	}
+	/* RFC 5340 4.2.2 packets are always sourced from a link-local address */
+	if !ipPkt.SrcIP.IsLinkLocalUnicast() {
+		return msg, errors.New(fmt.Sprintln("Source is not a link-local address", ipPkt.SrcIP))
+	}
+	msg.key = key
+	msg.srcIP = ipPkt.SrcIP
+	msg.dstIP = ipPkt.DstIP
+	msg.srcMAC = ethLayer.(*layers.Ethernet).SrcMAC
+	msg.data, err = validateOspfv3Pkt(ipPkt.SrcIP, ipPkt.DstIP, ipLayer.LayerPayload(), &msg.hdr)
+	return msg, err
+}
+
+func validateOspfv3Pkt(srcIP net.IP, dstIP net.IP, ospfPkt []byte, hdr *Ospfv3Header) ([]byte, error) {
+	err := decodeOspfv3Header(ospfPkt, hdr)
+	if err != nil {
+		return nil, err
+	}
+	ospfPkt = ospfPkt[:hdr.PktLen]
+	if computeOspfv3Checksum(srcIP, dstIP, ospfPkt) != 0 {
+		return nil, errors.New("Invalid OSPFv3 checksum")
+	}
+	return ospfPkt[OSPFV3_HEADER_SIZE:], nil
+}
+
+/*
+@fn processOspfv3Pkt
+RFC 5340 4.2.2 Receiving protocol packets. Packets of another instance
+or area are dropped. Only hellos are processed from unknown neighbors.
+*/
+func (server *OSPFServer) processOspfv3Pkt(msg V3RxPktMsg) error {
+	ent, exist := server.V3IntfConfMap[msg.key]
+	if !exist || ent.FSMState == config.Down {
+		return errors.New("Interface is not operational")
+	}
+	if msg.hdr.InstanceId != ent.InstanceId {
+		return errors.New(fmt.Sprintln("Instance id mismatch", msg.hdr.InstanceId))
+	}
+	if msg.hdr.AreaId != ent.AreaId {
+		return errors.New(fmt.Sprintln("Area id mismatch", convertUint32ToIPv4(msg.hdr.AreaId)))
+	}
+	if msg.hdr.RouterId == server.getV3RouterId() {
+		return errors.New("Packet from the same router id")
+	}
+	allDRouter := net.ParseIP(ALLDROUTERV6)
+	if allDRouter.Equal(msg.dstIP) &&
+		ent.FSMState != config.DesignatedRouter &&
+		ent.FSMState != config.BackupDesignatedRouter {
+		return errors.New("AllDRouters packet on a non DR/BDR interface")
+	}
+	if msg.hdr.PktType == HelloType {
+		return server.processOspfv3Hello(msg)
+	}
+	nbrKey := V3NbrKey{
+		IfIndex:  msg.key.IfIndex,
+		RouterId: msg.hdr.RouterId,
+	}
+	if _, exist := server.V3NbrMap[nbrKey]; !exist {
+		return errors.New(fmt.Sprintln("Neighbor does not exist", convertUint32ToIPv4(msg.hdr.RouterId)))
+	}
+	switch msg.hdr.PktType {
+	case DBDescriptionType:
+		return server.processOspfv3Dbd(nbrKey, msg.data)
+	case LSRequestType:
+		return server.processOspfv3LsaReq(nbrKey, msg.data)
+	case LSUpdateType:
+		return server.processOspfv3LsaUpd(nbrKey, msg.data)
+	case LSAckType:
+		return server.processOspfv3LsaAck(nbrKey, msg.data)
+	}
+	return errors.New("Invalid Ospf packet type")
+}
@@ -0,0 +1,542 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"fmt"
+	"l3/ospf/config"
+	"net"
+	"ribd"
+	"strconv"
+)
+
+/*
+RFC 5340 4.8 Calculating the routing table
+
+The shortest path tree of an area is built from the Router and Network
+LSAs only, vertices being identified by Router IDs and Interface IDs.
+Prefixes are attached to the tree through the Intra-Area-Prefix LSAs
+referencing a router or a transit network vertex. Next hops are the
+link-local addresses of the neighbors.
+*/
+
+const (
+	V3IntraAreaRoute string = "IntraArea"
+	V3InterAreaRoute string = "InterArea"
+)
+
+type V3NextHop struct {
+	IfIndex int32
+	Addr    string
+}
+
+type V3RouteEnt struct {
+	Prefix    V3Prefix
+	Cost      uint32
+	PathType  string
+	AreaId    uint32
+	Connected bool
+	NextHops  map[V3NextHop]bool
+}
+
+type V3InterAreaKey struct {
+	AreaId uint32
+	Prefix string
+}
+
+type V3AreaSpf struct {
+	AreaId   uint32
+	Root     VertexKey
+	SpfTree  map[VertexKey]TreeVertex
+	NextHops map[VertexKey]map[V3NextHop]bool
+}
+
+func getV3RouterVertexKey(rtrId uint32) VertexKey {
+	return VertexKey{
+		Type:   RouterVertex,
+		ID:     rtrId,
+		AdvRtr: rtrId,
+	}
+}
+
+func getV3NetworkVertexKey(intfId uint32, dRtrId uint32) VertexKey {
+	return VertexKey{
+		Type:   TNetworkVertex,
+		ID:     intfId,
+		AdvRtr: dRtrId,
+	}
+}
+
+func isV3LsaUsable(lsa V3Lsa) bool {
+	return getV3LsaAge(lsa) < config.MaxAge
+}
+
+/*
+@fn buildOspfv3AreaGraph
+RFC 5340 4.8.1 Builds the area graph from the Router and Network LSAs.
+Links are only added when they are advertised in both directions.
+*/
+func (server *OSPFServer) buildOspfv3AreaGraph(lsdb V3Lsdb, areaId uint32) map[VertexKey]Vertex {
+	rtrLinks := make(map[uint32][]V3RouterLink)
+	netAttached := make(map[VertexKey][]uint32)
+	for lsaKey, lsa := range lsdb {
+		if !isV3LsaUsable(lsa) {
+			continue
+		}
+		switch lsaKey.LSType {
+		case V3RouterLsa:
+			body, err := decodeV3RouterLsa(lsa.Body)
+			if err != nil {
+				continue
+			}
+			rtrLinks[lsaKey.AdvRouter] = append(rtrLinks[lsaKey.AdvRouter], body.Links...)
+		case V3NetworkLsa:
+			body, err := decodeV3NetworkLsa(lsa.Body)
+			if err != nil {
+				continue
+			}
+			netAttached[getV3NetworkVertexKey(lsaKey.LSId, lsaKey.AdvRouter)] = body.AttachedRtr
+		}
+	}
+	hasP2PLink := func(from uint32, to uint32) bool {
+		for _, link := range rtrLinks[from] {
+			if link.Type == V3P2PLink && link.NbrRtrId == to {
+				return true
+			}
+		}
+		return false
+	}
+	hasTransitLink := func(from uint32, vKey VertexKey) bool {
+		for _, link := range rtrLinks[from] {
+			if link.Type == V3TransitLink &&
+				getV3NetworkVertexKey(link.NbrIntfId, link.NbrRtrId) == vKey {
+				return true
+			}
+		}
+		return false
+	}
+	areaGraph := make(map[VertexKey]Vertex)
+	for rtrId, links := range rtrLinks {
+		vertex := Vertex{
+			AreaId: areaId,
+		}
+		for _, link := range links {
+			var nbrKey VertexKey
+			switch link.Type {
+			case V3P2PLink:
+				if !hasP2PLink(link.NbrRtrId, rtrId) {
+					continue
+				}
+				nbrKey = getV3RouterVertexKey(link.NbrRtrId)
+			case V3TransitLink:
+				nbrKey = getV3NetworkVertexKey(link.NbrIntfId, link.NbrRtrId)
+				if _, exist := netAttached[nbrKey]; !exist {
+					continue
+				}
+			default:
+				continue
+			}
+			vertex.NbrVertexKey = append(vertex.NbrVertexKey, nbrKey)
+			vertex.NbrVertexCost = append(vertex.NbrVertexCost, link.Metric)
+		}
+		areaGraph[getV3RouterVertexKey(rtrId)] = vertex
+	}
+	for vKey, attached := range netAttached {
+		vertex := Vertex{
+			AreaId: areaId,
+		}
+		for _, rtrId := range attached {
+			if _, exist := rtrLinks[rtrId]; !exist || !hasTransitLink(rtrId, vKey) {
+				continue
+			}
+			vertex.NbrVertexKey = append(vertex.NbrVertexKey, getV3RouterVertexKey(rtrId))
+			vertex.NbrVertexCost = append(vertex.NbrVertexCost, 0)
+		}
+		areaGraph[vKey] = vertex
+	}
+	return areaGraph
+}
+
+/* Full neighbor of the area with the given Router ID */
+func (server *OSPFServer) getOspfv3NextHopNbr(areaId uint32, ifIndex int32, rtrId uint32) (V3NextHop, bool) {
+	for nbrKey, nbr := range server.V3NbrMap {
+		if nbrKey.RouterId != rtrId || nbr.State != config.NbrFull ||
+			(ifIndex != 0 && nbrKey.IfIndex != ifIndex) {
+			continue
+		}
+		ent, _ := server.V3IntfConfMap[V3IntfConfKey{IfIndex: nbrKey.IfIndex}]
+		if ent.AreaId != areaId {
+			continue
+		}
+		nextHop := V3NextHop{
+			IfIndex: nbrKey.IfIndex,
+			Addr:    nbr.Addr.String(),
+		}
+		return nextHop, true
+	}
+	return V3NextHop{}, false
+}
+
+/*
+@fn computeOspfv3NextHops
+RFC 2328 16.1.1 The next hop of a vertex is given by the first hop of
+its paths. A directly attached network has no next hop address, a
+router behind it is reached through its link-local address.
+*/
+func (server *OSPFServer) computeOspfv3NextHops(spf V3AreaSpf, vKey VertexKey) map[V3NextHop]bool {
+	nextHops := make(map[V3NextHop]bool)
+	tEnt, _ := spf.SpfTree[vKey]
+	for _, path := range tEnt.Paths {
+		full := append(append(Path{}, path...), vKey)
+		if len(full) < 2 {
+			continue
+		}
+		first := full[1]
+		if first.Type == RouterVertex {
+			nextHop, exist := server.getOspfv3NextHopNbr(spf.AreaId, 0, first.ID)
+			if exist {
+				nextHops[nextHop] = true
+			}
+			continue
+		}
+		for key, ent := range server.V3IntfConfMap {
+			if ent.AreaId != spf.AreaId || ent.FSMState == config.Down ||
+				getV3NetworkVertexKey(ent.DRIntfId, ent.DRtrId) != first {
+				continue
+			}
+			if len(full) == 2 {
+				nextHops[V3NextHop{IfIndex: key.IfIndex}] = true
+				break
+			}
+			nextHop, exist := server.getOspfv3NextHopNbr(spf.AreaId, key.IfIndex, full[2].ID)
+			if exist {
+				nextHops[nextHop] = true
+			}
+			break
+		}
+	}
+	return nextHops
+}
+
+func (server *OSPFServer) runOspfv3AreaSpf(areaId uint32, lsdb V3Lsdb) (spf V3AreaSpf, err error) {
+	spf.AreaId = areaId
+	spf.Root = getV3RouterVertexKey(server.getV3RouterId())
+	spf.SpfTree = make(map[VertexKey]TreeVertex)
+	spf.NextHops = make(map[VertexKey]map[V3NextHop]bool)
+	areaGraph := server.buildOspfv3AreaGraph(lsdb, areaId)
+	if _, exist := areaGraph[spf.Root]; !exist {
+		return spf, nil
+	}
+	err = server.runDijkstra(spf.Root, areaGraph, spf.SpfTree)
+	if err != nil {
+		return spf, err
+	}
+	for vKey, _ := range spf.SpfTree {
+		if vKey != spf.Root {
+			spf.NextHops[vKey] = server.computeOspfv3NextHops(spf, vKey)
+		}
+	}
+	return spf, nil
+}
+
+func addOspfv3Route(routingTbl map[string]V3RouteEnt, rEnt V3RouteEnt) {
+	prefix := rEnt.Prefix.String()
+	oldEnt, exist := routingTbl[prefix]
+	if exist {
+		if oldEnt.PathType == V3IntraAreaRoute && rEnt.PathType == V3InterAreaRoute {
+			return
+		}
+		if oldEnt.PathType == rEnt.PathType {
+			if oldEnt.Connected || oldEnt.Cost < rEnt.Cost {
+				return
+			}
+			if oldEnt.Cost == rEnt.Cost && !rEnt.Connected {
+				for nextHop, _ := range rEnt.NextHops {
+					oldEnt.NextHops[nextHop] = true
+				}
+				return
+			}
+		}
+	}
+	routingTbl[prefix] = rEnt
+}
+
+/*
+@fn computeOspfv3IntraAreaRoutes
+RFC 5340 4.8.3 Prefixes of the Intra-Area-Prefix LSAs are reached
+through the vertex referenced by the LSA.
+*/
+func (server *OSPFServer) computeOspfv3IntraAreaRoutes(spf V3AreaSpf, lsdb V3Lsdb, routingTbl map[string]V3RouteEnt) {
+	for lsaKey, lsa := range lsdb {
+		if lsaKey.LSType != V3IntraAreaPrefixLsa || !isV3LsaUsable(lsa) {
+			continue
+		}
+		body, err := decodeV3IntraAreaPrefixLsa(lsa.Body)
+		if err != nil {
+			continue
+		}
+		var vKey VertexKey
+		switch body.RefLSType {
+		case V3RouterLsa:
+			vKey = getV3RouterVertexKey(body.RefAdvRouter)
+		case V3NetworkLsa:
+			vKey = getV3NetworkVertexKey(body.RefLSId, body.RefAdvRouter)
+		default:
+			continue
+		}
+		tEnt, exist := spf.SpfTree[vKey]
+		if !exist {
+			continue
+		}
+		connected := vKey == spf.Root
+		nextHops := make(map[V3NextHop]bool)
+		if !connected {
+			connected = true
+			for nextHop, _ := range spf.NextHops[vKey] {
+				nextHops[nextHop] = true
+				if nextHop.Addr != "" {
+					connected = false
+				}
+			}
+			if len(nextHops) == 0 {
+				continue
+			}
+		}
+		for _, prefix := range body.Prefixes {
+			if prefix.PrefixOptions&V3PrefixNUBit != 0 {
+				continue
+			}
+			rEnt := V3RouteEnt{
+				Prefix:    prefix,
+				Cost:      uint32(tEnt.Distance) + uint32(prefix.Metric),
+				PathType:  V3IntraAreaRoute,
+				AreaId:    spf.AreaId,
+				Connected: connected,
+				NextHops:  make(map[V3NextHop]bool),
+			}
+			for nextHop, _ := range nextHops {
+				rEnt.NextHops[nextHop] = true
+			}
+			addOspfv3Route(routingTbl, rEnt)
+		}
+	}
+}
+
+/*
+@fn computeOspfv3InterAreaRoutes
+RFC 5340 4.8.4 Inter-Area-Prefix LSAs of the area border routers. An
+area border router only considers the ones of the backbone.
+*/
+func (server *OSPFServer) computeOspfv3InterAreaRoutes(spf V3AreaSpf, lsdb V3Lsdb, routingTbl map[string]V3RouteEnt) {
+	rtrId := server.getV3RouterId()
+	for lsaKey, lsa := range lsdb {
+		if lsaKey.LSType != V3InterAreaPrefixLsa || lsaKey.AdvRouter == rtrId ||
+			!isV3LsaUsable(lsa) {
+			continue
+		}
+		body, err := decodeV3InterAreaPrefixLsa(lsa.Body)
+		if err != nil || body.Metric >= LSInfinity ||
+			body.Prefix.PrefixOptions&V3PrefixNUBit != 0 {
+			continue
+		}
+		vKey := getV3RouterVertexKey(lsaKey.AdvRouter)
+		tEnt, exist := spf.SpfTree[vKey]
+		if !exist || len(spf.NextHops[vKey]) == 0 {
+			continue
+		}
+		rEnt := V3RouteEnt{
+			Prefix:   body.Prefix,
+			Cost:     uint32(tEnt.Distance) + body.Metric,
+			PathType: V3InterAreaRoute,
+			AreaId:   spf.AreaId,
+			NextHops: make(map[V3NextHop]bool),
+		}
+		for nextHop, _ := range spf.NextHops[vKey] {
+			rEnt.NextHops[nextHop] = true
+		}
+		addOspfv3Route(routingTbl, rEnt)
+	}
+}
+
+/*
+@fn ospfv3SpfCalculation
+Runs the SPF of every area, computes the routing table, installs the
+differences in RIBd and originates the Inter-Area-Prefix LSAs.
+*/
+func (server *OSPFServer) ospfv3SpfCalculation() {
+	routingTbl := make(map[string]V3RouteEnt)
+	spfs := make(map[uint32]V3AreaSpf)
+	for areaId, lsdb := range server.V3AreaLsdb {
+		spf, err := server.runOspfv3AreaSpf(areaId, lsdb)
+		if err != nil {
+			server.logger.Err(fmt.Sprintln("OSPFv3: SPF failed for area ", convertUint32ToIPv4(areaId), err))
+			continue
+		}
+		spfs[areaId] = spf
+		server.computeOspfv3IntraAreaRoutes(spf, lsdb, routingTbl)
+	}
+	for areaId, spf := range spfs {
+		if server.v3AreaBdrRtrStatus && areaId != 0 {
+			continue
+		}
+		server.computeOspfv3InterAreaRoutes(spf, server.V3AreaLsdb[areaId], routingTbl)
+	}
+	server.installOspfv3Routes(routingTbl)
+	server.originateOspfv3InterAreaLsas()
+}
+
+func isSameOspfv3Route(a V3RouteEnt, b V3RouteEnt) bool {
+	if a.Cost != b.Cost || a.Connected != b.Connected || len(a.NextHops) != len(b.NextHops) {
+		return false
+	}
+	for nextHop, _ := range a.NextHops {
+		if !b.NextHops[nextHop] {
+			return false
+		}
+	}
+	return true
+}
+
+func (server *OSPFServer) ribdOspfv3Route(prefix string, rEnt V3RouteEnt, nextHop V3NextHop, install bool) {
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return
+	}
+	if server.ribdClient.ClientHdl == nil {
+		server.logger.Err("Nil ribd handle. Can not update IPv6 route.")
+		return
+	}
+	cfg := ribd.IPv6Route{
+		DestinationNw: ipNet.IP.String(),
+		Protocol:      "OSPF",
+		Cost:          int32(rEnt.Cost),
+		NetworkMask:   net.IP(ipNet.Mask).String(),
+	}
+	nextHopInfo := ribd.NextHopInfo{
+		NextHopIp:     nextHop.Addr,
+		NextHopIntRef: strconv.Itoa(int(nextHop.IfIndex)),
+	}
+	cfg.NextHop = make([]*ribd.NextHopInfo, 0)
+	cfg.NextHop = append(cfg.NextHop, &nextHopInfo)
+	if install {
+		_, err = server.ribdClient.ClientHdl.CreateIPv6Route(&cfg)
+	} else {
+		_, err = server.ribdClient.ClientHdl.DeleteIPv6Route(&cfg)
+	}
+	if err != nil {
+		server.logger.Err(fmt.Sprintln("OSPFv3: RIBd update failed for ", prefix, nextHop, install, err))
+	}
+}
+
+/* Connected prefixes are owned by RIBd and never installed */
+func (server *OSPFServer) installOspfv3Routes(routingTbl map[string]V3RouteEnt) {
+	for prefix, oldEnt := range server.V3RoutingTbl {
+		newEnt, exist := routingTbl[prefix]
+		if exist && isSameOspfv3Route(oldEnt, newEnt) {
+			continue
+		}
+		if oldEnt.Connected {
+			continue
+		}
+		for nextHop, _ := range oldEnt.NextHops {
+			server.ribdOspfv3Route(prefix, oldEnt, nextHop, false)
+		}
+	}
+	for prefix, newEnt := range routingTbl {
+		oldEnt, exist := server.V3RoutingTbl[prefix]
+		if (exist && isSameOspfv3Route(oldEnt, newEnt)) || newEnt.Connected {
+			continue
+		}
+		server.logger.Info(fmt.Sprintln("OSPFv3: Install route ", prefix, "cost", newEnt.Cost, newEnt.PathType))
+		for nextHop, _ := range newEnt.NextHops {
+			server.ribdOspfv3Route(prefix, newEnt, nextHop, true)
+		}
+	}
+	server.V3RoutingTbl = routingTbl
+}
+
+func (server *OSPFServer) getOspfv3InterAreaLsId(areaId uint32, prefix string) uint32 {
+	key := V3InterAreaKey{
+		AreaId: areaId,
+		Prefix: prefix,
+	}
+	if lsId, exist := server.V3InterAreaLsIdMap[key]; exist {
+		return lsId
+	}
+	used := make(map[uint32]bool)
+	for k, lsId := range server.V3InterAreaLsIdMap {
+		if k.AreaId == areaId {
+			used[lsId] = true
+		}
+	}
+	lsId := uint32(1)
+	for used[lsId] {
+		lsId++
+	}
+	server.V3InterAreaLsIdMap[key] = lsId
+	return lsId
+}
+
+/*
+@fn originateOspfv3InterAreaLsas
+RFC 5340 4.4.3.4 An area border router advertises the intra-area
+routes of each area into the other areas, and the inter-area routes
+of the backbone into the non-backbone areas. Inter-Area-Prefix LSAs
+which are not needed anymore are flushed.
+*/
+func (server *OSPFServer) originateOspfv3InterAreaLsas() {
+	rtrId := server.getV3RouterId()
+	for areaId, lsdb := range server.V3AreaLsdb {
+		selfOrig := make(map[V3LsaKey]bool)
+		for prefix, rEnt := range server.V3RoutingTbl {
+			if !server.v3AreaBdrRtrStatus || rEnt.AreaId == areaId ||
+				rEnt.Cost >= LSInfinity ||
+				(rEnt.PathType == V3InterAreaRoute && areaId == 0) {
+				continue
+			}
+			body := V3InterAreaPrefixLsaBody{
+				Metric: rEnt.Cost,
+				Prefix: rEnt.Prefix,
+			}
+			lsId := server.getOspfv3InterAreaLsId(areaId, prefix)
+			selfOrig[V3LsaKey{LSType: V3InterAreaPrefixLsa, LSId: lsId, AdvRouter: rtrId}] = true
+			server.originateOspfv3Lsa(V3InterAreaPrefixLsa, lsId, encodeV3InterAreaPrefixLsa(body), areaId, V3IntfConfKey{})
+		}
+		for lsaKey, _ := range lsdb {
+			if lsaKey.LSType == V3InterAreaPrefixLsa && lsaKey.AdvRouter == rtrId && !selfOrig[lsaKey] {
+				server.flushOspfv3Lsa(lsdb, lsaKey, areaId, V3IntfConfKey{})
+			}
+		}
+		for key, lsId := range server.V3InterAreaLsIdMap {
+			lsaKey := V3LsaKey{
+				LSType:    V3InterAreaPrefixLsa,
+				LSId:      lsId,
+				AdvRouter: rtrId,
+			}
+			if key.AreaId == areaId && !selfOrig[lsaKey] {
+				delete(server.V3InterAreaLsIdMap, key)
+			}
+		}
+	}
+}
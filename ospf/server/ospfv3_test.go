@@ -0,0 +1,257 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+package server
+
+import (
+	"l3/ospf/config"
+	"net"
+	"testing"
+)
+
+func TestOspfv3HelloCodec(t *testing.T) {
+	hello := Ospfv3Hello{
+		IntfId:          0x01000005,
+		RtrPriority:     1,
+		Options:         OSPFV3_DEFAULT_OPTIONS,
+		HelloInterval:   10,
+		RtrDeadInterval: 40,
+		DRtrId:          0x0a000001,
+		BDRtrId:         0x0a000002,
+		NbrList:         []uint32{0x0a000001, 0x0a000002},
+	}
+	data := encodeOspfv3Hello(hello)
+	if len(data) != OSPFV3_HELLO_MIN_SIZE+8 {
+		t.Fatalf("Invalid hello length %d", len(data))
+	}
+	var dHello Ospfv3Hello
+	err := decodeOspfv3Hello(data, &dHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dHello.IntfId != hello.IntfId || dHello.Options != hello.Options ||
+		dHello.RtrDeadInterval != hello.RtrDeadInterval ||
+		dHello.BDRtrId != hello.BDRtrId || len(dHello.NbrList) != 2 ||
+		dHello.NbrList[1] != hello.NbrList[1] {
+		t.Errorf("Hello mismatch %+v %+v", hello, dHello)
+	}
+}
+
+func TestOspfv3PktChecksum(t *testing.T) {
+	srcIP := net.ParseIP("fe80::1")
+	dstIP := net.ParseIP(ALLSPFROUTERV6)
+	body := encodeOspfv3Hello(Ospfv3Hello{
+		HelloInterval:   10,
+		RtrDeadInterval: 40,
+	})
+	hdr := Ospfv3Header{
+		Version:  OSPF_VERSION_3,
+		PktType:  HelloType,
+		PktLen:   uint16(OSPFV3_HEADER_SIZE + len(body)),
+		RouterId: 0x0a000001,
+	}
+	pkt := append(encodeOspfv3Header(hdr), body...)
+	hdr.Checksum = computeOspfv3Checksum(srcIP, dstIP, pkt)
+	pkt = append(encodeOspfv3Header(hdr), body...)
+
+	var dHdr Ospfv3Header
+	data, err := validateOspfv3Pkt(srcIP, dstIP, pkt, &dHdr)
+	if err != nil || len(data) != len(body) || dHdr.RouterId != hdr.RouterId {
+		t.Errorf("Valid packet rejected %v", err)
+	}
+	_, err = validateOspfv3Pkt(net.ParseIP("fe80::2"), dstIP, pkt, &dHdr)
+	if err == nil {
+		t.Errorf("Packet with a different source accepted")
+	}
+}
+
+func TestOspfv3LsaCodec(t *testing.T) {
+	rtrLsa := V3RouterLsaBody{
+		Flags:   V3BitB,
+		Options: OSPFV3_DEFAULT_OPTIONS,
+		Links: []V3RouterLink{
+			{Type: V3TransitLink, Metric: 10, IntfId: 5, NbrIntfId: 7, NbrRtrId: 0x0a000002},
+		},
+	}
+	lsa := finalizeV3Lsa(V3Lsa{
+		Header: V3LsaHeader{
+			LSType:        V3RouterLsa,
+			AdvRouter:     0x0a000001,
+			LSSequenceNum: V3InitialSequenceNumber,
+		},
+		Body: encodeV3RouterLsa(rtrLsa),
+	})
+	lsas, err := decodeOspfv3LsaUpd(encodeOspfv3LsaUpd([]V3Lsa{lsa}, 1))
+	if err != nil || len(lsas) != 1 {
+		t.Fatalf("Unable to decode LSU %v", err)
+	}
+	if lsas[0].Header.LSAge != 1 || lsas[0].Header.LSChecksum != lsa.Header.LSChecksum {
+		t.Errorf("LSA header mismatch %+v", lsas[0].Header)
+	}
+	body, err := decodeV3RouterLsa(lsas[0].Body)
+	if err != nil || body.Flags != V3BitB || len(body.Links) != 1 ||
+		body.Links[0] != rtrLsa.Links[0] {
+		t.Errorf("Router LSA mismatch %+v %v", body, err)
+	}
+
+	data := encodeV3Lsa(lsa)
+	data[len(data)-1] ^= 0xff
+	if _, err := decodeV3Lsa(data); err == nil {
+		t.Errorf("LSA with invalid checksum accepted")
+	}
+
+	_, ipNet, _ := net.ParseCIDR("2001:db8:1:2::/64")
+	prefix := newV3Prefix(ipNet)
+	prefix.Metric = 20
+	iap := V3IntraAreaPrefixLsaBody{
+		RefLSType:    V3RouterLsa,
+		RefAdvRouter: 0x0a000001,
+		Prefixes:     []V3Prefix{prefix},
+	}
+	dIap, err := decodeV3IntraAreaPrefixLsa(encodeV3IntraAreaPrefixLsa(iap))
+	if err != nil || len(dIap.Prefixes) != 1 ||
+		dIap.Prefixes[0].String() != "2001:db8:1:2::/64" || dIap.Prefixes[0].Metric != 20 {
+		t.Errorf("Intra-area-prefix LSA mismatch %+v %v", dIap, err)
+	}
+}
+
+func TestOspfv3LsaCompare(t *testing.T) {
+	hdr := V3LsaHeader{
+		LSSequenceNum: V3InitialSequenceNumber + 1,
+		LSChecksum:    0x1000,
+		LSAge:         10,
+	}
+	older := hdr
+	older.LSSequenceNum = V3InitialSequenceNumber
+	if compareV3LsaInstance(hdr, older) != 1 || compareV3LsaInstance(older, hdr) != -1 {
+		t.Errorf("Sequence number comparison failed")
+	}
+	maxAge := hdr
+	maxAge.LSAge = config.MaxAge
+	if compareV3LsaInstance(maxAge, hdr) != 1 {
+		t.Errorf("MaxAge instance is not newer")
+	}
+	same := hdr
+	same.LSAge = 500
+	if compareV3LsaInstance(same, hdr) != 0 {
+		t.Errorf("Same instance is not detected")
+	}
+}
+
+func TestOspfv3DRElection(t *testing.T) {
+	tests := []struct {
+		cands []V3DRCand
+		dr    uint32
+		bdr   uint32
+	}{
+		{[]V3DRCand{{RouterId: 1, Priority: 1}, {RouterId: 2, Priority: 1}}, 2, 2},
+		{[]V3DRCand{{RouterId: 1, Priority: 1, DRtrId: 1}, {RouterId: 2, Priority: 1}}, 1, 2},
+		{[]V3DRCand{{RouterId: 1, Priority: 1, DRtrId: 1}, {RouterId: 2, Priority: 1, BDRtrId: 2},
+			{RouterId: 3, Priority: 5}}, 1, 2},
+		{[]V3DRCand{{RouterId: 1, Priority: 5, DRtrId: 1}, {RouterId: 2, Priority: 1, DRtrId: 2},
+			{RouterId: 3, Priority: 1}}, 1, 3},
+	}
+	for idx, test := range tests {
+		dr, bdr := electOspfv3DR(test.cands)
+		if dr != test.dr || bdr != test.bdr {
+			t.Errorf("%d: DR %d BDR %d, expected %d %d", idx, dr, bdr, test.dr, test.bdr)
+		}
+	}
+}
+
+func addOspfv3TestLsa(lsdb V3Lsdb, lsType uint16, lsId uint32, advRtr uint32, body []byte) {
+	lsa := finalizeV3Lsa(V3Lsa{
+		Header: V3LsaHeader{
+			LSType:        lsType,
+			LSId:          lsId,
+			AdvRouter:     advRtr,
+			LSSequenceNum: V3InitialSequenceNumber,
+		},
+		Body: body,
+	})
+	lsdb[getV3LsaKey(lsa.Header)] = lsa
+}
+
+/*
+10.0.0.1 --p2p(10)-- 10.0.0.2 --transit(5), DR 10.0.0.3-- 10.0.0.3
+10.0.0.3 advertises 2001:db8:3::/64 with metric 1.
+*/
+func TestOspfv3SpfIntraAreaRoutes(t *testing.T) {
+	server := getServerObject()
+	server.ospfGlobalConf.RouterId = []byte{10, 0, 0, 1}
+	key := V3IntfConfKey{
+		IfIndex: 11,
+	}
+	server.V3IntfConfMap[key] = V3IntfConf{
+		IfIndex:  11,
+		IfType:   config.NumberedP2P,
+		FSMState: config.P2P,
+	}
+	server.V3NbrMap[V3NbrKey{IfIndex: 11, RouterId: 0x0a000002}] = V3NbrEnt{
+		RouterId: 0x0a000002,
+		State:    config.NbrFull,
+		Addr:     net.ParseIP("fe80::2"),
+	}
+	lsdb := make(V3Lsdb)
+	addOspfv3TestLsa(lsdb, V3RouterLsa, 0, 0x0a000001, encodeV3RouterLsa(V3RouterLsaBody{
+		Links: []V3RouterLink{{Type: V3P2PLink, Metric: 10, IntfId: 11, NbrIntfId: 21, NbrRtrId: 0x0a000002}},
+	}))
+	addOspfv3TestLsa(lsdb, V3RouterLsa, 0, 0x0a000002, encodeV3RouterLsa(V3RouterLsaBody{
+		Links: []V3RouterLink{
+			{Type: V3P2PLink, Metric: 10, IntfId: 21, NbrIntfId: 11, NbrRtrId: 0x0a000001},
+			{Type: V3TransitLink, Metric: 5, IntfId: 22, NbrIntfId: 31, NbrRtrId: 0x0a000003},
+		},
+	}))
+	addOspfv3TestLsa(lsdb, V3RouterLsa, 0, 0x0a000003, encodeV3RouterLsa(V3RouterLsaBody{
+		Links: []V3RouterLink{{Type: V3TransitLink, Metric: 5, IntfId: 31, NbrIntfId: 31, NbrRtrId: 0x0a000003}},
+	}))
+	addOspfv3TestLsa(lsdb, V3NetworkLsa, 31, 0x0a000003, encodeV3NetworkLsa(V3NetworkLsaBody{
+		AttachedRtr: []uint32{0x0a000003, 0x0a000002},
+	}))
+	_, ipNet, _ := net.ParseCIDR("2001:db8:3::/64")
+	prefix := newV3Prefix(ipNet)
+	prefix.Metric = 1
+	addOspfv3TestLsa(lsdb, V3IntraAreaPrefixLsa, 0, 0x0a000003, encodeV3IntraAreaPrefixLsa(V3IntraAreaPrefixLsaBody{
+		RefLSType:    V3RouterLsa,
+		RefAdvRouter: 0x0a000003,
+		Prefixes:     []V3Prefix{prefix},
+	}))
+
+	spf, err := server.runOspfv3AreaSpf(0, lsdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routingTbl := make(map[string]V3RouteEnt)
+	server.computeOspfv3IntraAreaRoutes(spf, lsdb, routingTbl)
+	rEnt, exist := routingTbl["2001:db8:3::/64"]
+	if !exist {
+		t.Fatalf("Route not computed %+v", routingTbl)
+	}
+	nextHop := V3NextHop{
+		IfIndex: 11,
+		Addr:    "fe80::2",
+	}
+	if rEnt.Cost != 16 || rEnt.Connected || len(rEnt.NextHops) != 1 || !rEnt.NextHops[nextHop] {
+		t.Errorf("Invalid route %+v", rEnt)
+	}
+}
@@ -33,9 +33,12 @@ import (
 	nanomsg "github.com/op/go-nanomsg"
 	"io/ioutil"
 	"l3/ospf/config"
+	"os"
+	"os/signal"
 	"ribd"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 	"utils/dbutils"
 	"utils/ipcutils"
@@ -63,10 +66,11 @@ type RoutingTblKey struct {
 }
 
 type LsdbSliceEnt struct {
-	AreaId uint32
-	LSType uint8
-	LSId   uint32
-	AdvRtr uint32
+	AreaId  uint32
+	LSType  uint8
+	LSId    uint32
+	AdvRtr  uint32
+	IntfKey IntfConfKey // link of the link-local opaque LSA
 }
 
 type OSPFServer struct {
@@ -81,6 +85,7 @@ type OSPFServer struct {
 	GlobalConfigCh         chan config.GlobalConf
 	AreaConfigCh           chan config.AreaConf
 	IntfConfigCh           chan config.InterfaceConf
+	IntfAuthConfigCh       chan config.InterfaceConf
 	IfMetricConfCh         chan config.IfMetricConf
 	GlobalConfigRetCh      chan error
 	AreaConfigRetCh        chan error
@@ -89,6 +94,21 @@ type OSPFServer struct {
 	LsdbSlice              []LsdbSliceEnt
 	LsdbStateTimer         *time.Timer
 	AreaSelfOrigLsa        map[LsdbKey]SelfOrigLsa
+	NssaTranslatedLsaMap   map[LsaKey]bool
+	IntfOpaqueLsdb         map[IntfConfKey]OpaqueLsdb
+	OpaqueLsaCh            chan OpaqueLsaMsg
+	opaqueAppMap           map[uint8]OpaqueApp
+	opaqueAppMutex         sync.RWMutex
+	TELinkLsaMap           map[IntfConfKey]TELinkLsaEnt
+	TERtrAddrAreaMap       map[uint32]bool
+	TEDatabase             map[LsaKey]TELsaInfo
+	GRHelperMap            map[NeighborConfKey]GRHelperEnt
+	grHelperExpiryCh       chan NeighborConfKey
+	grHelperMutex          sync.RWMutex
+	grRestart              GRRestartState
+	grPlannedRestartCh     chan bool
+	grPlannedRestartRetCh  chan grDbEntry
+	grRoutesCh             chan map[RoutingTblEntryKey]GlobalRoutingTblEntry
 	LsdbUpdateCh           chan LsdbUpdateMsg
 	LsaUpdateRetCodeCh     chan bool
 	IntfStateChangeCh      chan NetworkLSAChangeMsg
@@ -156,17 +176,68 @@ type OSPFServer struct {
 
 	SummaryLsDb map[LsdbKey]SummaryLsaMap
 
-	StartCalcSPFCh chan bool
-	DoneCalcSPFCh  chan bool
-	AreaGraph      map[VertexKey]Vertex
-	SPFTree        map[VertexKey]TreeVertex
-	AreaStubs      map[VertexKey]StubVertex
+	StartCalcSPFCh      chan SpfCalcMsg
+	DoneCalcSPFCh       chan bool
+	AreaGraph           map[VertexKey]Vertex
+	SPFTree             map[VertexKey]TreeVertex
+	AreaStubs           map[VertexKey]StubVertex
+	IntraAreaRoutingTbl map[AreaIdKey]AreaRoutingTbl // intra area routes of the last full SPF
+
+	// SPF and LSA throttling, owned by the LSDB goroutine
+	spfThrottle        ospfThrottle
+	spfPending         *SpfCalcMsg
+	spfStats           SpfStats
+	spfStatsMutex      sync.RWMutex
+	lsaThrottle        ospfThrottle
+	lsaGenPending      lsaGenPendingEnt
+	lsaArrivalMap      map[lsaArrivalKey]time.Time
+	lsaArrivalInterval time.Duration
+
+	// Area ranges and ASBR summary addresses
+	AreaRangeConfigCh   chan AreaRangeMsg
+	SummaryAddrConfigCh chan SummaryAddrMsg
+	areaRangeChangeCh   chan bool
+	summaryAddrChangeCh chan bool
+	AreaRangeMap        map[AreaRangeKey]AreaRangeEnt
+	areaRangeMutex      sync.RWMutex
+	SummaryAddrMap      map[SummaryAddrKey]SummaryAddrEnt
+	summaryAddrMutex    sync.RWMutex
+	extRouteMap         map[SummaryAddrKey]RouteMdata // owned by the LSDB goroutine
+	extLsaMap           map[SummaryAddrKey]RouteMdata
+	extDiscardMap       map[SummaryAddrKey]bool
+
+	// Virtual links
+	VirtLinkConfigCh chan VirtLinkMsg
+	virtLinkChangeCh chan bool // end points changed by SPF
+	virtLinkLsaCh    chan bool // virtual adjacency or cost changed
+	VirtLinkMap      map[VirtLinkKey]VirtLinkEnt
+	virtLinkMutex    sync.RWMutex
 
 	dbHdl        *dbutils.DBUtil
 	DbReadConfig chan bool
 	DbRouteOp    chan DbRouteMsg
 	DbLsdbOp     chan DbLsdbMsg
 	DbEventOp    chan DbEventMsg
+
+	// OSPFv3 instance, owned by the StartOspfv3Server event loop
+	V3IntfConfMap      map[V3IntfConfKey]V3IntfConf
+	V3IntfTxMap        map[V3IntfConfKey]IntfTxHandle
+	V3IntfRxMap        map[V3IntfConfKey]IntfRxHandle
+	V3IntfAddrMap      map[int32]V3IntfAddr
+	V3NbrMap           map[V3NbrKey]V3NbrEnt
+	V3AreaLsdb         map[uint32]V3Lsdb
+	V3AsLsdb           V3Lsdb
+	V3RoutingTbl       map[string]V3RouteEnt
+	V3InterAreaLsIdMap map[V3InterAreaKey]uint32
+	v3OrigPending      map[uint32]bool
+	v3ForceOrig        map[V3LsaKey]bool
+	v3SpfPending       bool
+	v3AreaBdrRtrStatus bool
+	v3Mutex            sync.RWMutex
+	Ospfv3IntfConfigCh chan config.Ospfv3IntfConf
+	Ospfv3IntfDeleteCh chan int32
+	v3IntfNotifyCh     chan V3IntfNotifyMsg
+	v3RxPktCh          chan V3RxPktMsg
 }
 
 func NewOSPFServer(logger *logging.Writer) *OSPFServer {
@@ -175,6 +246,7 @@ func NewOSPFServer(logger *logging.Writer) *OSPFServer {
 	ospfServer.GlobalConfigCh = make(chan config.GlobalConf)
 	ospfServer.AreaConfigCh = make(chan config.AreaConf)
 	ospfServer.IntfConfigCh = make(chan config.InterfaceConf)
+	ospfServer.IntfAuthConfigCh = make(chan config.InterfaceConf)
 	ospfServer.IfMetricConfCh = make(chan config.IfMetricConf)
 	ospfServer.GlobalConfigRetCh = make(chan error)
 	ospfServer.AreaConfigRetCh = make(chan error)
@@ -189,6 +261,13 @@ func NewOSPFServer(logger *logging.Writer) *OSPFServer {
 	ospfServer.IntfRxMap = make(map[IntfConfKey]IntfRxHandle)
 	ospfServer.AreaLsdb = make(map[LsdbKey]LSDatabase)
 	ospfServer.AreaSelfOrigLsa = make(map[LsdbKey]SelfOrigLsa)
+	ospfServer.NssaTranslatedLsaMap = make(map[LsaKey]bool)
+	ospfServer.IntfOpaqueLsdb = make(map[IntfConfKey]OpaqueLsdb)
+	ospfServer.OpaqueLsaCh = make(chan OpaqueLsaMsg)
+	ospfServer.opaqueAppMap = make(map[uint8]OpaqueApp)
+	ospfServer.TELinkLsaMap = make(map[IntfConfKey]TELinkLsaEnt)
+	ospfServer.TERtrAddrAreaMap = make(map[uint32]bool)
+	ospfServer.TEDatabase = make(map[LsaKey]TELsaInfo)
 	ospfServer.IntfStateChangeCh = make(chan NetworkLSAChangeMsg)
 	ospfServer.NetworkDRChangeCh = make(chan DrChangeMsg)
 	ospfServer.CreateNetworkLSACh = make(chan ospfNbrMdata)
@@ -242,8 +321,32 @@ func NewOSPFServer(logger *logging.Writer) *OSPFServer {
 	ospfServer.TempGlobalRoutingTbl = make(map[RoutingTblEntryKey]GlobalRoutingTblEntry)
 	//ospfServer.OldRoutingTbl = make(map[AreaIdKey]AreaRoutingTbl)
 	ospfServer.TempAreaRoutingTbl = make(map[AreaIdKey]AreaRoutingTbl)
-	ospfServer.StartCalcSPFCh = make(chan bool)
+	ospfServer.StartCalcSPFCh = make(chan SpfCalcMsg)
 	ospfServer.DoneCalcSPFCh = make(chan bool)
+	ospfServer.lsaArrivalMap = make(map[lsaArrivalKey]time.Time)
+	ospfServer.initThrottleConfDefault()
+	ospfServer.initThrottleTimers()
+	ospfServer.AreaRangeConfigCh = make(chan AreaRangeMsg)
+	ospfServer.SummaryAddrConfigCh = make(chan SummaryAddrMsg)
+	ospfServer.areaRangeChangeCh = make(chan bool, 1)
+	ospfServer.summaryAddrChangeCh = make(chan bool, 1)
+	ospfServer.AreaRangeMap = make(map[AreaRangeKey]AreaRangeEnt)
+	ospfServer.SummaryAddrMap = make(map[SummaryAddrKey]SummaryAddrEnt)
+	ospfServer.extRouteMap = make(map[SummaryAddrKey]RouteMdata)
+	ospfServer.extLsaMap = make(map[SummaryAddrKey]RouteMdata)
+	ospfServer.extDiscardMap = make(map[SummaryAddrKey]bool)
+	ospfServer.VirtLinkConfigCh = make(chan VirtLinkMsg)
+	ospfServer.virtLinkChangeCh = make(chan bool, 1)
+	ospfServer.virtLinkLsaCh = make(chan bool, 1)
+	ospfServer.VirtLinkMap = make(map[VirtLinkKey]VirtLinkEnt)
+	ospfServer.RegisterOpaqueApp(TEOpaqueType, &teOpaqueApp{server: ospfServer})
+	ospfServer.GRHelperMap = make(map[NeighborConfKey]GRHelperEnt)
+	ospfServer.grHelperExpiryCh = make(chan NeighborConfKey)
+	ospfServer.grPlannedRestartCh = make(chan bool)
+	ospfServer.grPlannedRestartRetCh = make(chan grDbEntry)
+	ospfServer.grRoutesCh = make(chan map[RoutingTblEntryKey]GlobalRoutingTblEntry)
+	ospfServer.RegisterOpaqueApp(GraceOpaqueType, &grOpaqueApp{server: ospfServer})
+	ospfServer.initOspfv3Server()
 
 	return ospfServer
 }
@@ -315,6 +418,32 @@ func (server *OSPFServer) ConnectToClients(paramsFile string) {
 	}
 }
 
+/*
+@fn sigHandler
+ospfd is stopped with SIGHUP. A planned graceful restart is
+started before exiting when it is enabled.
+*/
+func (server *OSPFServer) sigHandler(sigChan <-chan os.Signal) {
+	sig := <-sigChan
+	switch sig {
+	case syscall.SIGHUP:
+		server.logger.Info("Received SIGHUP signal")
+		if server.isGRSupported() {
+			err := server.startPlannedRestart()
+			if err != nil {
+				server.logger.Err(fmt.Sprintln("GR: Planned restart failed ", err))
+			}
+		}
+		server.clearRunningMarkerInDB()
+		if server.dbHdl != nil {
+			server.dbHdl.Disconnect()
+		}
+		os.Exit(0)
+	default:
+		server.logger.Err(fmt.Sprintln("Unhandled signal : ", sig))
+	}
+}
+
 func (server *OSPFServer) InitServer(paramFile string) {
 	server.logger.Info(fmt.Sprintln("Starting Ospf Server"))
 	server.initOspfGlobalConfDefault()
@@ -333,6 +462,10 @@ func (server *OSPFServer) InitServer(paramFile string) {
 		server.logger.Err(fmt.Sprintln("DB Initialization faliure err:", err))
 	}
 	go server.StartDBListener()
+	sigChan := make(chan os.Signal, 1)
+	signalList := []os.Signal{syscall.SIGHUP}
+	signal.Notify(sigChan, signalList...)
+	go server.sigHandler(sigChan)
 	/*
 	   server.logger.Info("Listen for RIBd updates")
 	   server.listenForRIBUpdates(ribdCommonDefs.PUB_SOCKET_ADDR)
@@ -345,6 +478,7 @@ func (server *OSPFServer) InitServer(paramFile string) {
 	}
 
 	go server.spfCalculation()
+	go server.StartOspfv3Server()
 	if server.dbHdl != nil {
 		// Read DB for config objects in case of restarts
 		server.DbReadConfig <- true
@@ -376,12 +510,26 @@ func (server *OSPFServer) StartServer(paramFile string) {
 				//Handle Intf Configuration
 			}
 		//	server.IntfConfigRetCh <- err
+		case ifConf := <-server.IntfAuthConfigCh:
+			server.logger.Info(fmt.Sprintln("Received call for performing Intf Auth Configuration", ifConf.IfIpAddress))
+			server.processIntfAuthConfig(ifConf)
 		case ifMetricConf := <-server.IfMetricConfCh:
 			server.logger.Info(fmt.Sprintln("Received call for preforming Intf Metric Configuration", ifMetricConf))
 			err := server.processIfMetricConfig(ifMetricConf)
 			if err == nil {
 
 			}
+		case msg := <-server.AreaRangeConfigCh:
+			server.logger.Info(fmt.Sprintln("Received call for performing Area Range Configuration", msg))
+			server.processAreaRangeConfig(msg)
+		case msg := <-server.SummaryAddrConfigCh:
+			server.logger.Info(fmt.Sprintln("Received call for performing Summary Address Configuration", msg))
+			server.processSummaryAddrConfig(msg)
+		case msg := <-server.VirtLinkConfigCh:
+			server.logger.Info(fmt.Sprintln("Received call for performing Virtual Link Configuration", msg))
+			server.processVirtLinkConfig(msg)
+		case <-server.virtLinkChangeCh:
+			server.processVirtLinkChange()
 		case asicdrxBuf := <-server.asicdSubSocketCh:
 			server.processAsicdNotification(asicdrxBuf)
 		case <-server.asicdSubSocketErrCh:
@@ -140,7 +140,10 @@ func computeCheckSum(pkt []byte) uint16 {
 		csum += uint32(pkt[i]) << 8
 		csum += uint32(pkt[i+1])
 	}
-	chkSum := ^uint16((csum >> 16) + csum)
+	for (csum >> 16) != 0 {
+		csum = (csum >> 16) + (csum & 0xffff)
+	}
+	chkSum := ^uint16(csum)
 	return chkSum
 }
 
@@ -51,7 +51,8 @@ struct Routes {
 	17: bool NetworkStatement,
 	18: string RouteOrigin,
 	19: int Weight,
-	20: int IPAddrType
+	20: int IPAddrType,
+	21: string Vrf
 }
 struct RoutesGetInfo {
 	1: int StartIdx,
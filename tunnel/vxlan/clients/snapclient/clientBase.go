@@ -141,6 +141,10 @@ func (intf VXLANSnapClient) ConnectToClients(clientFile string) {
 	go intf.createRIBdSubscriber()
 	// need to listen for por vlan membership notifications
 	go intf.createASICdSubscriber()
+	// need to advertise the local vteps and macs to, and listen for the remote
+	// vteps and macs from the BGP EVPN routes
+	createEvpnPublisher()
+	go intf.createBGPdSubscriber()
 }
 
 func asicDGetLoopbackInfo() (success bool, lbname string, mac net.HardwareAddr, ip net.IP) {
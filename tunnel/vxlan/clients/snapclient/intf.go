@@ -20,6 +20,8 @@ type VXLANSnapClient struct {
 	asicdSubSocket      *nanomsg.SubSocket
 	asicdSubSocketCh    chan []byte
 	asicdSubSocketErrCh chan error
+	bgpdSubSocketCh     chan []byte
+	bgpdSubSocketErrCh  chan error
 }
 
 func NewVXLANSnapClient(l *logging.Writer) *VXLANSnapClient {
@@ -30,6 +32,8 @@ func NewVXLANSnapClient(l *logging.Writer) *VXLANSnapClient {
 		ribdSubSocketErrCh:  make(chan error, 0),
 		asicdSubSocketCh:    make(chan []byte, 0),
 		asicdSubSocketErrCh: make(chan error, 0),
+		bgpdSubSocketCh:     make(chan []byte, 0),
+		bgpdSubSocketErrCh:  make(chan error, 0),
 	}
 
 	go client.ClientChanListener()
@@ -49,6 +53,10 @@ func (intf VXLANSnapClient) ClientChanListener() {
 			intf.processRibdNotification(rxBuf)
 		case <-intf.ribdSubSocketErrCh:
 			continue
+		case rxBuf := <-intf.bgpdSubSocketCh:
+			intf.processBgpdNotification(rxBuf)
+		case <-intf.bgpdSubSocketErrCh:
+			continue
 		}
 	}
 }
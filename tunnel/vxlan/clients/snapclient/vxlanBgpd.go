@@ -0,0 +1,175 @@
+package snapclient
+
+import (
+	"encoding/json"
+	"fmt"
+	nanomsg "github.com/op/go-nanomsg"
+	vxlan "l3/tunnel/vxlan/protocol"
+	"l3/tunnel/vxlan/vxlanCommonDefs"
+	"net"
+)
+
+// publisher of the local vteps and macs advertised by bgpd in the EVPN routes
+var evpnPub *nanomsg.PubSocket
+
+func createEvpnPublisher() error {
+	address := vxlanCommonDefs.PUB_SOCKET_ADDR
+	pub, err := nanomsg.NewPubSocket()
+	if err != nil {
+		logger.Err(fmt.Sprintln("Failed to create EVPN publish socket, error:", err))
+		return err
+	}
+
+	if _, err = pub.Bind(address); err != nil {
+		logger.Err(fmt.Sprintln("Failed to bind EVPN publish socket, address:", address, "error:", err))
+		return err
+	}
+
+	if err = pub.SetSendBuffer(1024 * 1024); err != nil {
+		logger.Err(fmt.Sprintln("Failed to set the buffer size for EVPN publish socket, error:", err))
+		return err
+	}
+	evpnPub = pub
+	return nil
+}
+
+func publishEvpnNotification(msgType uint16, msgInfo interface{}) {
+	if evpnPub == nil {
+		return
+	}
+
+	msgBuf, err := json.Marshal(msgInfo)
+	if err != nil {
+		logger.Err(fmt.Sprintln("Unable to marshal EVPN msg:", msgInfo))
+		return
+	}
+	buf, err := json.Marshal(vxlanCommonDefs.VxlanNotifyMsg{MsgType: msgType, MsgBuf: msgBuf})
+	if err != nil {
+		logger.Err(fmt.Sprintln("Unable to marshal EVPN notification:", msgType))
+		return
+	}
+	if _, err = evpnPub.Send(buf, nanomsg.DontWait); err != nil {
+		logger.Err(fmt.Sprintln("Failed to publish EVPN notification", msgType, "error:", err))
+	}
+}
+
+func (intf VXLANSnapClient) createBGPdSubscriber() error {
+	logger.Info("Listen for BGPd EVPN updates")
+	address := vxlanCommonDefs.PUB_SOCKET_BGPD_ADDR
+	sub, err := nanomsg.NewSubSocket()
+	if err != nil {
+		logger.Err(fmt.Sprintln("Failed to create BGPd subscribe socket, error:", err))
+		return err
+	}
+
+	if _, err = sub.Connect(address); err != nil {
+		logger.Err(fmt.Sprintln("Failed to connect to BGPd publisher socket, address:", address, "error:", err))
+		return err
+	}
+
+	if err = sub.Subscribe(""); err != nil {
+		logger.Err(fmt.Sprintln("Failed to subscribe to \"\" on BGPd subscribe socket, error:", err))
+		return err
+	}
+
+	logger.Info(fmt.Sprintln("Connected to BGPd publisher at address:", address))
+	if err = sub.SetRecvBuffer(1024 * 1024); err != nil {
+		logger.Err(fmt.Sprintln("Failed to set the buffer size for BGPd publisher socket, error:", err))
+		return err
+	}
+
+	for {
+		rxBuf, err := sub.Recv(0)
+		if err != nil {
+			logger.Err(fmt.Sprintln("Recv on BGPd subscriber socket failed with error:", err))
+			intf.bgpdSubSocketErrCh <- err
+			continue
+		}
+		intf.bgpdSubSocketCh <- rxBuf
+	}
+	return nil
+}
+
+func (intf VXLANSnapClient) processBgpdNotification(rxBuf []byte) error {
+	var msg vxlanCommonDefs.VxlanNotifyMsg
+	err := json.Unmarshal(rxBuf, &msg)
+	if err != nil {
+		logger.Err(fmt.Sprintln("Unable to unmarshal rxBuf:", rxBuf))
+		return err
+	}
+	switch msg.MsgType {
+	case vxlanCommonDefs.NOTIFY_REMOTE_VTEP_CREATED, vxlanCommonDefs.NOTIFY_REMOTE_VTEP_DELETED:
+		var msgInfo vxlanCommonDefs.VtepNotifyMsgInfo
+		err = json.Unmarshal(msg.MsgBuf, &msgInfo)
+		if err != nil {
+			logger.Err(fmt.Sprintln("Unable to unmarshal msg:", msg.MsgBuf))
+			return err
+		}
+		logger.Info(fmt.Sprintln("Received EVPN remote vtep", msg.MsgType, msgInfo))
+		command := vxlan.VxlanCommandCreate
+		if msg.MsgType == vxlanCommonDefs.NOTIFY_REMOTE_VTEP_DELETED {
+			command = vxlan.VxlanCommandDelete
+		}
+		serverchannels.VxlanEvpnVtepUpdate <- vxlan.VxlanEvpnVtep{
+			Command: command,
+			Vni:     msgInfo.Vni,
+			VtepIp:  net.ParseIP(msgInfo.VtepIp),
+		}
+
+	case vxlanCommonDefs.NOTIFY_REMOTE_MAC_CREATED, vxlanCommonDefs.NOTIFY_REMOTE_MAC_DELETED:
+		var msgInfo vxlanCommonDefs.MacNotifyMsgInfo
+		err = json.Unmarshal(msg.MsgBuf, &msgInfo)
+		if err != nil {
+			logger.Err(fmt.Sprintln("Unable to unmarshal msg:", msg.MsgBuf))
+			return err
+		}
+		logger.Info(fmt.Sprintln("Received EVPN remote mac", msg.MsgType, msgInfo))
+		mac, err := net.ParseMAC(msgInfo.Mac)
+		if err != nil {
+			logger.Err(fmt.Sprintln("Invalid EVPN remote mac:", msgInfo.Mac))
+			return err
+		}
+		command := vxlan.VxlanCommandCreate
+		if msg.MsgType == vxlanCommonDefs.NOTIFY_REMOTE_MAC_DELETED {
+			command = vxlan.VxlanCommandDelete
+		}
+		serverchannels.VxlanEvpnMacUpdate <- vxlan.VxlanEvpnMac{
+			Command: command,
+			Vni:     msgInfo.Vni,
+			Mac:     mac,
+			Ip:      net.ParseIP(msgInfo.Ip),
+			VtepIp:  net.ParseIP(msgInfo.VtepIp),
+		}
+	}
+	return nil
+}
+
+func (intf VXLANSnapClient) CreateEvpnLocalVtep(vtep *vxlan.VtepDbEntry) {
+	publishEvpnNotification(vxlanCommonDefs.NOTIFY_LOCAL_VTEP_CREATED, vxlanCommonDefs.VtepNotifyMsgInfo{
+		Vni:    vtep.Vni,
+		VtepIp: vtep.SrcIp.String(),
+	})
+}
+
+func (intf VXLANSnapClient) DeleteEvpnLocalVtep(vtep *vxlan.VtepDbEntry) {
+	publishEvpnNotification(vxlanCommonDefs.NOTIFY_LOCAL_VTEP_DELETED, vxlanCommonDefs.VtepNotifyMsgInfo{
+		Vni:    vtep.Vni,
+		VtepIp: vtep.SrcIp.String(),
+	})
+}
+
+func (intf VXLANSnapClient) LearnEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP) {
+	publishEvpnNotification(vxlanCommonDefs.NOTIFY_LOCAL_MAC_LEARNED, vxlanCommonDefs.MacNotifyMsgInfo{
+		Vni:    vni,
+		Mac:    mac.String(),
+		VtepIp: vtepIp.String(),
+	})
+}
+
+func (intf VXLANSnapClient) DeleteEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP) {
+	publishEvpnNotification(vxlanCommonDefs.NOTIFY_LOCAL_MAC_DELETED, vxlanCommonDefs.MacNotifyMsgInfo{
+		Vni:    vni,
+		Mac:    mac.String(),
+		VtepIp: vtepIp.String(),
+	})
+}
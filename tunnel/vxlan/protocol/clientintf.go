@@ -32,6 +32,11 @@ type VXLANClientIntf interface {
 	GetIntfInfo(name string, intfchan chan<- MachineEvent)
 	GetNextHopInfo(ip net.IP, nexthopchan chan<- MachineEvent)
 	ResolveNextHopMac(nextHopIp net.IP, nexthopmacchan chan<- MachineEvent)
+	// evpn
+	CreateEvpnLocalVtep(vtep *VtepDbEntry)
+	DeleteEvpnLocalVtep(vtep *VtepDbEntry)
+	LearnEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP)
+	DeleteEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP)
 }
 
 type BaseClientIntf struct {
@@ -95,3 +100,15 @@ func (b BaseClientIntf) GetNextHopInfo(ip net.IP, nexthopchan chan<- MachineEven
 func (b BaseClientIntf) ResolveNextHopMac(nextHopIp net.IP, nexthopmacchan chan<- MachineEvent) {
 
 }
+func (b BaseClientIntf) CreateEvpnLocalVtep(vtep *VtepDbEntry) {
+
+}
+func (b BaseClientIntf) DeleteEvpnLocalVtep(vtep *VtepDbEntry) {
+
+}
+func (b BaseClientIntf) LearnEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP) {
+
+}
+func (b BaseClientIntf) DeleteEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP) {
+
+}
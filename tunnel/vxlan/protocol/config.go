@@ -51,6 +51,8 @@ type VxLanConfigChannels struct {
 	VxlanNextHopUpdate        chan VxlanNextHopIp
 	VxlanPortCreate           chan PortConfig
 	Vxlanintfinfo             chan VxlanIntfInfo
+	VxlanEvpnVtepUpdate       chan VxlanEvpnVtep
+	VxlanEvpnMacUpdate        chan VxlanEvpnMac
 }
 
 type VxlanIntfInfo struct {
@@ -122,6 +124,7 @@ type VtepConfig struct {
 	TunnelSrcMac          net.HardwareAddr //Src Mac assigned to the VTEP within this VxLAN. If an address is not assigned the the local switch address will be used.
 	TunnelDstMac          net.HardwareAddr // Optional - may be looked up based on TunnelNextHopIp
 	TunnelNextHopIP       net.IP           // NextHopIP is used to find the DMAC for the tunnel within Asicd
+	EvpnRemote            bool             // VTEP to a remote VTEP learned from the BGP EVPN routes
 }
 
 func ConvertInt32ToBool(val int32) bool {
@@ -363,6 +366,14 @@ func (s *VXLANServer) ConfigListener() {
                                         logger.Info("Saving Port Config to db", *portcfg)
 					PortConfigMap[port.IfIndex] = portcfg
 				}
+			case evpnvtep := <-cc.VxlanEvpnVtepUpdate:
+				// remote vteps learned from BGP
+				s.HandleEvpnVtepUpdate(evpnvtep)
+
+			case evpnmac := <-cc.VxlanEvpnMacUpdate:
+				// remote macs learned from BGP
+				s.HandleEvpnMacUpdate(evpnmac)
+
 			case intfinfo := <-cc.Vxlanintfinfo:
 				for _, vtep := range GetVtepDB() {
 					logger.Info(fmt.Sprintln("received intf info", intfinfo, vtep))
@@ -0,0 +1,326 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// evpn.go
+// VTEPs and MACs learned from the BGP EVPN routes.  A VTEP configured without a
+// destination ip is the local EVPN VTEP of its VNI, the VTEPs to the remote VTEPs
+// learned from BGP are created from its config.
+package vxlan
+
+import (
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"net"
+	"sync"
+)
+
+// VxlanEvpnVtep:
+// remote VTEP learned from the EVPN inclusive multicast route
+type VxlanEvpnVtep struct {
+	Command int
+	Vni     uint32
+	VtepIp  net.IP
+}
+
+// VxlanEvpnMac:
+// remote MAC learned from the EVPN MAC/IP advertisement route
+type VxlanEvpnMac struct {
+	Command int
+	Vni     uint32
+	Mac     net.HardwareAddr
+	Ip      net.IP
+	VtepIp  net.IP
+}
+
+type EvpnVtepKey struct {
+	Vni    uint32
+	VtepIp string
+}
+
+type EvpnMacKey struct {
+	Vni uint32
+	Mac string
+}
+
+// evpnVtepEntry:
+// remote VTEP is kept as long as the inclusive multicast route or one of the
+// MACs behind it is known
+type evpnVtepEntry struct {
+	// name of the vtep created to the remote vtep, empty until the local
+	// EVPN vtep of the vni is resolved
+	name string
+	imet bool
+	macs int
+}
+
+type EvpnFdbEntry struct {
+	Mac    net.HardwareAddr
+	Ip     net.IP
+	VtepIp net.IP
+}
+
+// remote vteps learned from BGP
+var evpnVtepDB map[EvpnVtepKey]*evpnVtepEntry
+
+// remote macs learned from BGP
+var evpnFdbDB map[EvpnMacKey]*EvpnFdbEntry
+
+// macs learned from the local hosts
+var evpnLocalMacDB map[EvpnMacKey]net.IP
+
+// the evpn dbs are accessed from the config listener, the vtep fsm
+// and the vtep packet listeners
+var evpnMutex sync.Mutex
+
+// IsEvpnVtep:
+// the local EVPN vtep of a vni is configured without a destination ip
+func (vtep *VtepDbEntry) IsEvpnVtep() bool {
+	return vtep.DstIp == nil || vtep.DstIp.IsUnspecified()
+}
+
+// GetEvpnFdbDB:
+// returns a copy of the remote macs learned from BGP
+func GetEvpnFdbDB() map[EvpnMacKey]EvpnFdbEntry {
+	defer evpnMutex.Unlock()
+	evpnMutex.Lock()
+	fdb := make(map[EvpnMacKey]EvpnFdbEntry, len(evpnFdbDB))
+	for key, entry := range evpnFdbDB {
+		fdb[key] = *entry
+	}
+	return fdb
+}
+
+func getEvpnVtep(vni uint32) *VtepDbEntry {
+	for _, vtep := range GetVtepDB() {
+		if vtep.Vni == vni && vtep.IsEvpnVtep() {
+			return vtep
+		}
+	}
+	return nil
+}
+
+// provisionEvpnRemoteVtep:
+// create the vtep to the remote vtep from the config of the local EVPN vtep
+func provisionEvpnRemoteVtep(key EvpnVtepKey, entry *evpnVtepEntry) {
+	if entry.name != "" {
+		return
+	}
+
+	local := getEvpnVtep(key.Vni)
+	if local == nil || local.SrcIp == nil {
+		return
+	}
+
+	c := &VtepConfig{
+		Vni:          key.Vni,
+		VtepName:     fmt.Sprintf("%s-%s", local.VtepName, key.VtepIp),
+		SrcIfName:    local.SrcIfName,
+		UDP:          local.UDP,
+		TTL:          local.TTL,
+		TunnelSrcIp:  local.SrcIp,
+		TunnelDstIp:  net.ParseIP(key.VtepIp),
+		VlanId:       local.VlanId,
+		TunnelSrcMac: local.SrcMac,
+		EvpnRemote:   true,
+	}
+	logger.Info(fmt.Sprintln("EVPN: create vtep", c.VtepName, "to remote vtep", key.VtepIp, "vni", key.Vni))
+	CreateVtep(c)
+	entry.name = c.VtepName
+}
+
+func deprovisionEvpnRemoteVtep(entry *evpnVtepEntry) {
+	if entry.name == "" {
+		return
+	}
+
+	logger.Info(fmt.Sprintln("EVPN: delete vtep", entry.name))
+	DeleteVtep(&VtepConfig{VtepName: entry.name})
+	entry.name = ""
+}
+
+func getEvpnRemoteVtep(key EvpnVtepKey) *evpnVtepEntry {
+	entry, ok := evpnVtepDB[key]
+	if !ok {
+		entry = &evpnVtepEntry{}
+		evpnVtepDB[key] = entry
+	}
+	return entry
+}
+
+// releaseEvpnRemoteVtep:
+// delete the remote vtep once nothing refers to it
+func releaseEvpnRemoteVtep(key EvpnVtepKey) {
+	entry, ok := evpnVtepDB[key]
+	if !ok || entry.imet || entry.macs > 0 {
+		return
+	}
+	deprovisionEvpnRemoteVtep(entry)
+	delete(evpnVtepDB, key)
+}
+
+// evpnLocalVtepResolved:
+// the source info of the local EVPN vtep is known, lets advertise it and create
+// the vteps to the remote vteps learned before
+func evpnLocalVtepResolved(vtep *VtepDbEntry) {
+	defer evpnMutex.Unlock()
+	evpnMutex.Lock()
+
+	logger.Info(fmt.Sprintln("EVPN: local vtep", vtep.VtepName, "vni", vtep.Vni, "src ip", vtep.SrcIp))
+	for _, client := range ClientIntf {
+		client.CreateEvpnLocalVtep(vtep)
+	}
+	for key, entry := range evpnVtepDB {
+		if key.Vni == vtep.Vni {
+			provisionEvpnRemoteVtep(key, entry)
+		}
+	}
+}
+
+// evpnLocalVtepDeleted:
+// delete the vteps to the remote vteps and forget the local macs of the vni
+func evpnLocalVtepDeleted(vtep *VtepDbEntry) {
+	defer evpnMutex.Unlock()
+	evpnMutex.Lock()
+
+	for key, entry := range evpnVtepDB {
+		if key.Vni == vtep.Vni {
+			deprovisionEvpnRemoteVtep(entry)
+		}
+	}
+	for key := range evpnLocalMacDB {
+		if key.Vni == vtep.Vni {
+			delete(evpnLocalMacDB, key)
+		}
+	}
+	for _, client := range ClientIntf {
+		client.DeleteEvpnLocalVtep(vtep)
+	}
+}
+
+// HandleEvpnVtepUpdate:
+// Handle the remote vteps learned from BGP
+func (s *VXLANServer) HandleEvpnVtepUpdate(update VxlanEvpnVtep) {
+	defer evpnMutex.Unlock()
+	evpnMutex.Lock()
+
+	key := EvpnVtepKey{
+		Vni:    update.Vni,
+		VtepIp: update.VtepIp.String(),
+	}
+	if update.Command == VxlanCommandCreate {
+		entry := getEvpnRemoteVtep(key)
+		entry.imet = true
+		provisionEvpnRemoteVtep(key, entry)
+	} else if entry, ok := evpnVtepDB[key]; ok {
+		entry.imet = false
+		releaseEvpnRemoteVtep(key)
+	}
+}
+
+// HandleEvpnMacUpdate:
+// Handle the remote macs learned from BGP.  A mac which moved from a local host
+// to a remote vtep is no longer a local mac.
+func (s *VXLANServer) HandleEvpnMacUpdate(update VxlanEvpnMac) {
+	defer evpnMutex.Unlock()
+	evpnMutex.Lock()
+
+	macKey := EvpnMacKey{
+		Vni: update.Vni,
+		Mac: update.Mac.String(),
+	}
+	old, exists := evpnFdbDB[macKey]
+	if update.Command == VxlanCommandCreate {
+		key := EvpnVtepKey{
+			Vni:    update.Vni,
+			VtepIp: update.VtepIp.String(),
+		}
+		entry := getEvpnRemoteVtep(key)
+		entry.macs++
+		provisionEvpnRemoteVtep(key, entry)
+		evpnFdbDB[macKey] = &EvpnFdbEntry{
+			Mac:    update.Mac,
+			Ip:     update.Ip,
+			VtepIp: update.VtepIp,
+		}
+
+		if vtepIp, ok := evpnLocalMacDB[macKey]; ok {
+			delete(evpnLocalMacDB, macKey)
+			for _, client := range ClientIntf {
+				client.DeleteEvpnLocalMac(update.Vni, update.Mac, vtepIp)
+			}
+		}
+	} else {
+		delete(evpnFdbDB, macKey)
+	}
+
+	if exists {
+		key := EvpnVtepKey{
+			Vni:    update.Vni,
+			VtepIp: old.VtepIp.String(),
+		}
+		if entry, ok := evpnVtepDB[key]; ok {
+			entry.macs--
+			releaseEvpnRemoteVtep(key)
+		}
+	}
+}
+
+// learnEvpnLocalMac:
+// learn the source mac of the frames sent from the local hosts to the remote
+// vteps of an EVPN vni, the new macs are advertised by the clients
+func (vtep *VtepDbEntry) learnEvpnLocalMac(packet gopacket.Packet) {
+	if !vtep.EvpnRemote {
+		return
+	}
+
+	ethernetL := packet.Layer(layers.LayerTypeEthernet)
+	if ethernetL == nil {
+		return
+	}
+	mac := ethernetL.(*layers.Ethernet).SrcMAC
+	// group bit is never set in a valid source mac
+	if len(mac) != 6 || mac[0]&0x01 != 0 {
+		return
+	}
+
+	defer evpnMutex.Unlock()
+	evpnMutex.Lock()
+
+	key := EvpnMacKey{
+		Vni: vtep.Vni,
+		Mac: mac.String(),
+	}
+	if _, ok := evpnLocalMacDB[key]; ok {
+		return
+	}
+	if _, ok := evpnFdbDB[key]; ok {
+		return
+	}
+
+	logger.Info(fmt.Sprintln("EVPN: learned local mac", mac, "vni", vtep.Vni))
+	evpnLocalMacDB[key] = vtep.SrcIp
+	for _, client := range ClientIntf {
+		client.LearnEvpnLocalMac(vtep.Vni, mac, vtep.SrcIp)
+	}
+}
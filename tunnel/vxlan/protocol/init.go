@@ -24,7 +24,9 @@
 // init.go
 package vxlan
 
-import ()
+import (
+	"net"
+)
 
 func init() {
 	// initialize the various db maps
@@ -35,6 +37,10 @@ func init() {
 	PortConfigMap = make(map[int32]*PortConfig, 0)
 	portDB = make(map[string]*VxlanPort, 0)
 
+	evpnVtepDB = make(map[EvpnVtepKey]*evpnVtepEntry, 0)
+	evpnFdbDB = make(map[EvpnMacKey]*EvpnFdbEntry, 0)
+	evpnLocalMacDB = make(map[EvpnMacKey]net.IP, 0)
+
 	VxlanVtepMachineStrStateMapInit()
 
 }
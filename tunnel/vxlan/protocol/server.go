@@ -110,6 +110,8 @@ func NewVXLANServer(l *logging.Writer, paramspath string) *VXLANServer {
 				VxlanAccessPortVlanUpdate: make(chan VxlanAccessPortVlan, 0),
 				VxlanNextHopUpdate:        make(chan VxlanNextHopIp, 0),
 				VxlanPortCreate:           make(chan PortConfig, 0),
+				VxlanEvpnVtepUpdate:       make(chan VxlanEvpnVtep, 0),
+				VxlanEvpnMacUpdate:        make(chan VxlanEvpnMac, 0),
 			},
 		}
 
@@ -72,6 +72,9 @@ type VtepDbEntry struct {
 	// Enable/Disable state
 	Enable bool
 
+	// vtep to a remote vtep learned from the BGP EVPN routes
+	EvpnRemote bool
+
 	// handle name used to rx/tx packets to linux if
 	VtepHandleName string
 	// handle used to rx/tx packets to linux if
@@ -168,15 +171,16 @@ func NewVtepDbEntry(c *VtepConfig) *VtepDbEntry {
 		VtepName:       c.VtepName,
 		VtepHandleName: c.VtepName + "Int",
 		//VtepName:  c.VtepName,
-		SrcIfName: c.SrcIfName,
-		UDP:       c.UDP,
-		TTL:       c.TTL,
-		DstIp:     c.TunnelDstIp,
-		SrcIp:     c.TunnelSrcIp,
-		SrcMac:    c.TunnelSrcMac,
-		DstMac:    c.TunnelDstMac,
-		VlanId:    c.VlanId,
-		Enable:    true,
+		SrcIfName:  c.SrcIfName,
+		UDP:        c.UDP,
+		TTL:        c.TTL,
+		DstIp:      c.TunnelDstIp,
+		SrcIp:      c.TunnelSrcIp,
+		SrcMac:     c.TunnelSrcMac,
+		DstMac:     c.TunnelDstMac,
+		VlanId:     c.VlanId,
+		Enable:     true,
+		EvpnRemote: c.EvpnRemote,
 	}
 
 	return vtep
@@ -239,6 +243,9 @@ func DeleteVtep(c *VtepConfig) {
 
 	vtep := GetVtepDBEntry(key)
 	if vtep != nil {
+		if vtep.IsEvpnVtep() {
+			evpnLocalVtepDeleted(vtep)
+		}
 		DeProvisionVtep(vtep, true)
 		if vtep.VxlanVtepMachineFsm != nil {
 			vtep.VxlanVtepMachineFsm.Stop()
@@ -313,6 +320,7 @@ func (vtep *VtepDbEntry) createVtepSenderListener() error {
 			case packet, ok := <-rxchan:
 				if ok {
 					if !vtep.filterPacket(packet) {
+						vtep.learnEvpnLocalMac(packet)
 						go vtep.encapAndDispatchPkt(packet)
 					}
 				} else {
@@ -283,6 +283,13 @@ func (vm *VxlanVtepMachine) VxlanVtepInterface(m fsm.Machine, data interface{})
 		vtep.SrcIfIndex = intfinfo.IfIndex
 		logger.Info(fmt.Sprintf("%s: resolved srcip %s src mac %s from intf %s", strings.TrimRight(vtep.VtepName, "Int"), vtep.SrcIp, vtep.SrcMac, vtep.SrcIfName))
 	}
+	// the local EVPN vtep has no destination, the vteps to the remote vteps
+	// learned from BGP are created from it
+	if vtep.IsEvpnVtep() {
+		vtep.retrytimer.Stop()
+		evpnLocalVtepResolved(vtep)
+		return VxlanVtepStateInterface
+	}
 	// lets resolve the next hop ip and intf
 	for _, client := range ClientIntf {
 		client.GetNextHopInfo(vtep.DstIp, vm.VxlanVtepEvents)
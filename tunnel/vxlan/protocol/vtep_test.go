@@ -17,6 +17,7 @@ var vtepcreatedone chan bool
 var vxlancreatedone chan bool
 var vtepdeletedone chan bool
 var vxlandeletedone chan bool
+var evpnlocalmacdeleted chan EvpnMacKey
 
 type mockintf struct {
 	//BaseClientIntf
@@ -150,6 +151,20 @@ func (b mockintf) ResolveNextHopMac(nextHopIp net.IP, nexthopmacchan chan<- Mach
 	}
 }
 
+func (b mockintf) CreateEvpnLocalVtep(vtep *VtepDbEntry) {
+	logger.Info("MOCK: Calling CreateEvpnLocalVtep")
+}
+func (b mockintf) DeleteEvpnLocalVtep(vtep *VtepDbEntry) {
+	logger.Info("MOCK: Calling DeleteEvpnLocalVtep")
+}
+func (b mockintf) LearnEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP) {
+	logger.Info("MOCK: Calling LearnEvpnLocalMac")
+}
+func (b mockintf) DeleteEvpnLocalMac(vni uint32, mac net.HardwareAddr, vtepIp net.IP) {
+	logger.Info("MOCK: Calling DeleteEvpnLocalMac")
+	evpnlocalmacdeleted <- EvpnMacKey{Vni: vni, Mac: mac.String()}
+}
+
 func MockFuncRxTx(vtep *VtepDbEntry) {
 	logger.Info(fmt.Sprintf("MOCK: going to listen on interface %s", vtep.VtepName))
 }
@@ -160,6 +175,7 @@ func setup() {
 	vtepdeletedone = make(chan bool, 1)
 	vxlancreatedone = make(chan bool, 1)
 	vxlandeletedone = make(chan bool, 1)
+	evpnlocalmacdeleted = make(chan EvpnMacKey, 1)
 }
 
 func teardown() {
@@ -169,6 +185,7 @@ func teardown() {
 	close(vxlancreatedone)
 	close(vtepdeletedone)
 	close(vxlandeletedone)
+	close(evpnlocalmacdeleted)
 	exec.Command("/bin/rm", "UsrConfDb.db")
 	DeRegisterClients()
 	SetLogger(nil)
@@ -894,3 +911,65 @@ func TestFSMResolveNextHopMacFailVtepVxlanCreate(t *testing.T) {
 		t.Errorf("Vtep db not empty as expected")
 	}
 }
+
+// TestEvpnRemoteVtepRefCount:
+// Test the remote vtep learned from BGP is kept until both the inclusive
+// multicast route and the macs behind it are withdrawn
+func TestEvpnRemoteVtepRefCount(t *testing.T) {
+
+	// setup common test info
+	setup()
+	defer teardown()
+
+	RegisterClients(mockintf{})
+
+	s := &VXLANServer{}
+	vtepIp := net.ParseIP("100.1.1.2")
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	key := EvpnVtepKey{
+		Vni:    100,
+		VtepIp: vtepIp.String(),
+	}
+	macKey := EvpnMacKey{
+		Vni: 100,
+		Mac: mac.String(),
+	}
+
+	// mac learned from a local host moves to the remote vtep
+	evpnLocalMacDB[macKey] = net.ParseIP("100.1.1.1")
+
+	s.HandleEvpnVtepUpdate(VxlanEvpnVtep{Command: VxlanCommandCreate, Vni: 100, VtepIp: vtepIp})
+	s.HandleEvpnMacUpdate(VxlanEvpnMac{Command: VxlanCommandCreate, Vni: 100, Mac: mac, VtepIp: vtepIp})
+
+	if deleted := <-evpnlocalmacdeleted; deleted != macKey {
+		t.Errorf("Local mac delete not as expected expected[%v] actual[%v]", macKey, deleted)
+	}
+	if _, ok := evpnLocalMacDB[macKey]; ok {
+		t.Errorf("Local mac db not empty as expected")
+	}
+
+	entry, ok := evpnVtepDB[key]
+	if !ok || !entry.imet || entry.macs != 1 {
+		t.Fatalf("Remote vtep not as expected %v", entry)
+	}
+	// no local evpn vtep, the vtep to the remote vtep is pending
+	if entry.name != "" {
+		t.Errorf("Remote vtep created without local evpn vtep %s", entry.name)
+	}
+	if fdb := GetEvpnFdbDB(); len(fdb) != 1 || !fdb[macKey].VtepIp.Equal(vtepIp) {
+		t.Errorf("Fdb not as expected %v", fdb)
+	}
+
+	s.HandleEvpnVtepUpdate(VxlanEvpnVtep{Command: VxlanCommandDelete, Vni: 100, VtepIp: vtepIp})
+	if _, ok := evpnVtepDB[key]; !ok {
+		t.Errorf("Remote vtep deleted while a mac refers to it")
+	}
+
+	s.HandleEvpnMacUpdate(VxlanEvpnMac{Command: VxlanCommandDelete, Vni: 100, Mac: mac, VtepIp: vtepIp})
+	if _, ok := evpnVtepDB[key]; ok {
+		t.Errorf("Remote vtep db not empty as expected")
+	}
+	if len(GetEvpnFdbDB()) != 0 {
+		t.Errorf("Fdb not empty as expected")
+	}
+}
@@ -0,0 +1,62 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// defs.go
+// Definitions shared by vxland and bgpd for the EVPN control plane of the VXLAN tunnels.
+package vxlanCommonDefs
+
+const (
+	// vxland publishes the local VTEPs and the locally learned MACs
+	PUB_SOCKET_ADDR = "ipc:///tmp/vxland.ipc"
+	// bgpd publishes the remote VTEPs and MACs learned from the EVPN routes
+	PUB_SOCKET_BGPD_ADDR = "ipc:///tmp/bgpd_vxland.ipc"
+
+	NOTIFY_LOCAL_VTEP_CREATED  = 1
+	NOTIFY_LOCAL_VTEP_DELETED  = 2
+	NOTIFY_LOCAL_MAC_LEARNED   = 3
+	NOTIFY_LOCAL_MAC_DELETED   = 4
+	NOTIFY_REMOTE_VTEP_CREATED = 5
+	NOTIFY_REMOTE_VTEP_DELETED = 6
+	NOTIFY_REMOTE_MAC_CREATED  = 7
+	NOTIFY_REMOTE_MAC_DELETED  = 8
+)
+
+type VxlanNotifyMsg struct {
+	MsgType uint16
+	MsgBuf  []byte
+}
+
+// VtepNotifyMsgInfo is a VTEP of a VNI, VtepIp is the source IP of the local VTEP or the
+// originating router IP of the inclusive multicast route of the remote VTEP.
+type VtepNotifyMsgInfo struct {
+	Vni    uint32
+	VtepIp string
+}
+
+// MacNotifyMsgInfo is a MAC of a VNI behind a VTEP. The IP is optional.
+type MacNotifyMsgInfo struct {
+	Vni    uint32
+	Mac    string
+	Ip     string
+	VtepIp string
+}